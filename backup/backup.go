@@ -0,0 +1,179 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/libramusic/libracore"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/storage"
+)
+
+// FormatVersion is the version of the archive layout written by Create.
+// It must be incremented whenever the layout changes in an incompatible way.
+const FormatVersion = 1
+
+const (
+	manifestName = "manifest.json"
+	dataDir      = "data"
+	storageDir   = "storage"
+)
+
+type Manifest struct {
+	FormatVersion   int            `json:"format_version"`
+	LibraVersion    string         `json:"libra_version"`
+	SchemaVersion   uint64         `json:"schema_version"`
+	Engine          string         `json:"engine"`
+	CreationDate    int64          `json:"creation_date"`
+	Counts          map[string]int `json:"counts"`
+	IncludesStorage bool           `json:"includes_storage"`
+}
+
+type Options struct {
+	// Whether to include the contents of the storage directory.
+	IncludeStorage bool
+}
+
+// Create writes a gzipped tar archive of the entire database to w.
+// Every table is stored as JSON Lines under data/, preceded by a manifest.
+func Create(ctx context.Context, database db.Database, w io.Writer, opts Options) (Manifest, error) {
+	schemaVersion, dirty, err := database.MigrationVersion(ctx)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return Manifest{}, err
+	}
+	if dirty {
+		return Manifest{}, errors.New("database is in dirty state")
+	}
+
+	manifest := Manifest{
+		FormatVersion:   FormatVersion,
+		LibraVersion:    libracore.LibraVersion.String(),
+		SchemaVersion:   schemaVersion,
+		Engine:          database.EngineName(),
+		CreationDate:    time.Now().Unix(),
+		Counts:          map[string]int{},
+		IncludesStorage: opts.IncludeStorage,
+	}
+
+	// Dump every table before writing anything so the manifest can lead the archive.
+	dumps := make([][]byte, len(tables))
+	for i, t := range tables {
+		data, count, err := t.dump(ctx, database)
+		if err != nil {
+			return manifest, err
+		}
+		dumps[i] = data
+		manifest.Counts[t.name] = count
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	if err := writeFile(tw, manifestName, manifestData); err != nil {
+		return manifest, err
+	}
+	for i, t := range tables {
+		if err := writeFile(tw, path.Join(dataDir, t.name+".jsonl"), dumps[i]); err != nil {
+			return manifest, err
+		}
+	}
+
+	if opts.IncludeStorage {
+		if err := writeStorage(tw); err != nil {
+			return manifest, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return manifest, err
+	}
+	return manifest, gw.Close()
+}
+
+func writeFile(tw *tar.Writer, name string, data []byte) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+func writeStorage(tw *tar.Writer) error {
+	root, err := storage.Path()
+	if err != nil {
+		return err
+	}
+
+	err = filepath.WalkDir(root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		err = tw.WriteHeader(&tar.Header{
+			Name:    path.Join(storageDir, filepath.ToSlash(rel)),
+			Mode:    0o644,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// dumpRows encodes rows as JSON Lines.
+func dumpRows[T any](rows []T) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,234 @@
+package backup_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/goccy/go-json"
+
+	"github.com/libramusic/libracore/backup"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+)
+
+// newDatabase returns an empty SQLite database that is closed when the test ends.
+func newDatabase(t *testing.T) db.Database {
+	t.Helper()
+
+	database := db.Registry["sqlite"].WithDSN(filepath.Join(t.TempDir(), "libra.db"))
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+// fill stores a user who has favorited one of two tracks, and an album with both.
+func fill(t *testing.T, database db.Database) {
+	t.Helper()
+	ctx := context.Background()
+
+	user := media.DatabaseUser{User: media.User{ID: "user0000001", Username: "alice", Email: "alice@example.com"}}
+	if err := database.CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	for _, track := range []media.Track{
+		{ID: "track000001", Title: "One", Duration: 200, AlbumIDs: []string{"album000001"}},
+		{ID: "track000002", Title: "Two", Duration: 300, AlbumIDs: []string{"album000001"}},
+	} {
+		if err := database.AddTrack(ctx, track); err != nil {
+			t.Fatal(err)
+		}
+	}
+	album := media.Album{ID: "album000001", Title: "Album", TrackIDs: []string{"track000001", "track000002"}}
+	if err := database.AddAlbum(ctx, album); err != nil {
+		t.Fatal(err)
+	}
+	favorite := media.Favorite{UserID: user.ID, PlayableType: "track", PlayableID: "track000002", FavoritedAt: 1}
+	if _, err := database.AddFavorite(ctx, favorite); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// create backs up the database and returns the archive.
+func create(t *testing.T, database db.Database) []byte {
+	t.Helper()
+
+	var archive bytes.Buffer
+	if _, err := backup.Create(context.Background(), database, &archive, backup.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	return archive.Bytes()
+}
+
+// archive returns a gzipped tar archive of the files, in the order of the names.
+func archive(t *testing.T, names []string, files map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, name := range names {
+		header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// manifestArchive returns an archive with only the manifest, changed by change.
+func manifestArchive(t *testing.T, change func(manifest *backup.Manifest)) []byte {
+	t.Helper()
+
+	manifest := backup.Manifest{FormatVersion: backup.FormatVersion, Counts: map[string]int{}}
+	change(&manifest)
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return archive(t, []string{"manifest.json"}, map[string][]byte{"manifest.json": data})
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := newDatabase(t)
+	fill(t, source)
+
+	target := newDatabase(t)
+	manifest, err := backup.Restore(ctx, target, bytes.NewReader(create(t, source)), backup.RestoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Counts["users"] != 1 || manifest.Counts["tracks"] != 2 || manifest.Counts["favorites"] != 1 {
+		t.Errorf("manifest counts are %v", manifest.Counts)
+	}
+
+	user, err := target.User(ctx, "user0000001")
+	if err != nil || user.Username != "alice" || user.Email != "alice@example.com" {
+		t.Errorf("restored user is %+v, %v", user, err)
+	}
+	track, err := target.Track(ctx, "track000002")
+	if err != nil || track.Title != "Two" || track.Duration != 300 {
+		t.Errorf("restored track is %+v, %v", track, err)
+	}
+	album, err := target.Album(ctx, "album000001")
+	if err != nil || len(album.TrackIDs) != 2 {
+		t.Errorf("restored album is %+v, %v", album, err)
+	}
+	favorites, err := target.Favorites(ctx, "user0000001", "", -1, 0)
+	if err != nil || len(favorites) != 1 || favorites[0].PlayableID != "track000002" {
+		t.Errorf("restored favorites are %+v, %v", favorites, err)
+	}
+
+	// A backup of the restored database has the same rows.
+	restored, err := backup.Restore(ctx, newDatabase(t), bytes.NewReader(create(t, target)), backup.RestoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for table, count := range manifest.Counts {
+		if restored.Counts[table] != count {
+			t.Errorf("%s has %d rows after restoring twice, want %d", table, restored.Counts[table], count)
+		}
+	}
+}
+
+func TestRestoreIntoNonEmptyDatabase(t *testing.T) {
+	ctx := context.Background()
+	source := newDatabase(t)
+	fill(t, source)
+	data := create(t, source)
+
+	target := newDatabase(t)
+	if err := target.AddTrack(ctx, media.Track{ID: "track000001", Title: "Changed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := backup.Restore(ctx, target, bytes.NewReader(data), backup.RestoreOptions{})
+	if !errors.Is(err, backup.ErrDatabaseNotEmpty) {
+		t.Fatalf("restoring into a non-empty database: %v, want %v", err, backup.ErrDatabaseNotEmpty)
+	}
+	if track, err := target.Track(ctx, "track000001"); err != nil || track.Title != "Changed" {
+		t.Errorf("refused restore changed the track to %+v, %v", track, err)
+	}
+	if _, err := target.Track(ctx, "track000002"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("refused restore added a track: %v", err)
+	}
+
+	// With --force, rows with the same IDs are overwritten.
+	if _, err := backup.Restore(ctx, target, bytes.NewReader(data), backup.RestoreOptions{Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	if track, err := target.Track(ctx, "track000001"); err != nil || track.Title != "One" {
+		t.Errorf("forced restore left the track as %+v, %v", track, err)
+	}
+	if _, err := target.Track(ctx, "track000002"); err != nil {
+		t.Errorf("forced restore didn't add the other track: %v", err)
+	}
+}
+
+func TestRestoreRejectsInvalidArchives(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		archive []byte
+		want    error
+	}{
+		{"not gzipped", []byte("not an archive"), backup.ErrInvalidArchive},
+		{
+			"manifest not first",
+			archive(t, []string{"data/users.jsonl", "manifest.json"}, map[string][]byte{
+				"data/users.jsonl": nil,
+				"manifest.json":    []byte(`{"format_version":1,"counts":{}}`),
+			}),
+			backup.ErrInvalidArchive,
+		},
+		{
+			"unparsable manifest",
+			archive(t, []string{"manifest.json"}, map[string][]byte{"manifest.json": []byte("{")}),
+			backup.ErrInvalidArchive,
+		},
+		{"missing counts", manifestArchive(t, func(m *backup.Manifest) { m.Counts = nil }), backup.ErrInvalidArchive},
+		{
+			"newer format",
+			manifestArchive(t, func(m *backup.Manifest) { m.FormatVersion = backup.FormatVersion + 1 }),
+			backup.ErrUnsupportedFormat,
+		},
+		{
+			"no format",
+			manifestArchive(t, func(m *backup.Manifest) { m.FormatVersion = 0 }),
+			backup.ErrUnsupportedFormat,
+		},
+		{
+			"newer schema",
+			manifestArchive(t, func(m *backup.Manifest) { m.SchemaVersion = 1 << 40 }),
+			backup.ErrNewerSchema,
+		},
+		{
+			"counts that don't match the data",
+			manifestArchive(t, func(m *backup.Manifest) { m.Counts["tracks"] = 2 }),
+			backup.ErrVerificationFailed,
+		},
+	}
+	for _, test := range tests {
+		_, err := backup.Restore(ctx, newDatabase(t), bytes.NewReader(test.archive), backup.RestoreOptions{})
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-json"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/storage"
+)
+
+var (
+	ErrInvalidArchive     = errors.New("invalid backup archive")
+	ErrUnsupportedFormat  = errors.New("unsupported backup format version")
+	ErrNewerSchema        = errors.New("backup was created with a newer database schema")
+	ErrDatabaseNotEmpty   = errors.New("database is not empty")
+	ErrVerificationFailed = errors.New("restored row counts do not match the manifest")
+)
+
+type RestoreOptions struct {
+	// Allows restoring into a non-empty database.
+	// Existing rows with the same IDs are overwritten.
+	Force bool
+}
+
+// Restore reads an archive created by Create from r into the database.
+// The database is migrated to the latest schema before any rows are written.
+func Restore(ctx context.Context, database db.Database, r io.Reader, opts RestoreOptions) (Manifest, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+
+	manifest, err := readManifest(tr)
+	if err != nil {
+		return manifest, err
+	}
+
+	if err := database.MigrateUp(-1); err != nil {
+		return manifest, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	schemaVersion, _, err := database.MigrationVersion(ctx)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return manifest, err
+	}
+	if manifest.SchemaVersion > schemaVersion {
+		return manifest, fmt.Errorf(
+			"%w: backup is at version %d but this build only supports up to %d",
+			ErrNewerSchema,
+			manifest.SchemaVersion,
+			schemaVersion,
+		)
+	}
+
+	if !opts.Force {
+		for _, t := range tables {
+			count, err := t.count(ctx, database)
+			if err != nil {
+				return manifest, err
+			}
+			if count > 0 {
+				return manifest, fmt.Errorf("%w: %s has %d rows", ErrDatabaseNotEmpty, t.name, count)
+			}
+		}
+	}
+
+	restored := map[string]int{}
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return manifest, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		switch dir, name := path.Split(header.Name); dir {
+		case dataDir + "/":
+			for _, t := range tables {
+				if name != t.name+".jsonl" {
+					continue
+				}
+				count, err := t.restore(ctx, database, tr, opts.Force)
+				if err != nil {
+					return manifest, err
+				}
+				restored[t.name] = count
+			}
+		default:
+			if manifest.IncludesStorage && strings.HasPrefix(header.Name, storageDir+"/") {
+				if err := restoreStorageFile(tr, strings.TrimPrefix(header.Name, storageDir+"/")); err != nil {
+					return manifest, err
+				}
+			}
+		}
+	}
+
+	for table, count := range manifest.Counts {
+		if restored[table] != count {
+			return manifest, fmt.Errorf(
+				"%w: expected %d %s but restored %d",
+				ErrVerificationFailed,
+				count,
+				table,
+				restored[table],
+			)
+		}
+	}
+
+	return manifest, nil
+}
+
+// readManifest reads and validates the manifest, which must be the first entry in the archive.
+func readManifest(tr *tar.Reader) (Manifest, error) {
+	var manifest Manifest
+
+	header, err := tr.Next()
+	if err != nil {
+		return manifest, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+	if header.Name != manifestName {
+		return manifest, fmt.Errorf("%w: missing %s", ErrInvalidArchive, manifestName)
+	}
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return manifest, fmt.Errorf("%w: failed to parse manifest: %w", ErrInvalidArchive, err)
+	}
+
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > FormatVersion {
+		return manifest, fmt.Errorf("%w: %d", ErrUnsupportedFormat, manifest.FormatVersion)
+	}
+	if manifest.Counts == nil {
+		return manifest, fmt.Errorf("%w: manifest is missing counts", ErrInvalidArchive)
+	}
+
+	return manifest, nil
+}
+
+func restoreStorageFile(r io.Reader, name string) error {
+	root, err := storage.Path()
+	if err != nil {
+		return err
+	}
+
+	// Refuse entries that would escape the storage directory.
+	target := filepath.Join(root, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(root, target); err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("%w: illegal storage path %q", ErrInvalidArchive, name)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
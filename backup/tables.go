@@ -0,0 +1,348 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/goccy/go-json"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+)
+
+type table struct {
+	name string
+	// Returns the table encoded as JSON Lines and the number of rows.
+	dump func(ctx context.Context, database db.Database) ([]byte, int, error)
+	// Returns the number of rows currently in the table.
+	count func(ctx context.Context, database db.Database) (int, error)
+	// Decodes JSON Lines from r into the table and returns the number of rows restored.
+	// When overwrite is set, rows that already exist are replaced instead of causing an error.
+	restore func(ctx context.Context, database db.Database, r io.Reader, overwrite bool) (int, error)
+}
+
+var tables = []table{
+	newTable("users",
+		func(database db.Database) func(context.Context) ([]media.DatabaseUser, error) { return database.Users },
+		func(ctx context.Context, database db.Database, user media.DatabaseUser, overwrite bool) error {
+			return upsert(overwrite,
+				func() error { _, err := database.User(ctx, user.ID); return err },
+				func() error { return database.CreateUser(ctx, user) },
+				func() error { return database.UpdateUser(ctx, user) },
+			)
+		},
+	),
+	newTable("auth_providers",
+		func(database db.Database) func(context.Context) ([]db.ProviderLink, error) {
+			return database.ProviderLinks
+		},
+		func(ctx context.Context, database db.Database, link db.ProviderLink, overwrite bool) error {
+			if overwrite {
+				if err := database.DisconnectProviderAccount(ctx, link.Provider, link.UserID); err != nil {
+					return err
+				}
+			}
+			return database.LinkProviderAccount(ctx, link.Provider, link.UserID, link.ProviderUserID)
+		},
+	),
+	newTable("tracks",
+		func(database db.Database) func(context.Context) ([]media.Track, error) { return database.AllTracks },
+		func(ctx context.Context, database db.Database, track media.Track, overwrite bool) error {
+			return upsert(overwrite,
+				func() error { _, err := database.Track(ctx, track.ID); return err },
+				func() error { return database.AddTrack(ctx, track) },
+				func() error { return database.UpdateTrack(ctx, track) },
+			)
+		},
+	),
+	newTable("albums",
+		func(database db.Database) func(context.Context) ([]media.Album, error) { return database.AllAlbums },
+		func(ctx context.Context, database db.Database, album media.Album, overwrite bool) error {
+			return upsert(overwrite,
+				func() error { _, err := database.Album(ctx, album.ID); return err },
+				func() error { return database.AddAlbum(ctx, album) },
+				func() error { return database.UpdateAlbum(ctx, album) },
+			)
+		},
+	),
+	newTable("videos",
+		func(database db.Database) func(context.Context) ([]media.Video, error) { return database.AllVideos },
+		func(ctx context.Context, database db.Database, video media.Video, overwrite bool) error {
+			return upsert(overwrite,
+				func() error { _, err := database.Video(ctx, video.ID); return err },
+				func() error { return database.AddVideo(ctx, video) },
+				func() error { return database.UpdateVideo(ctx, video) },
+			)
+		},
+	),
+	newTable("artists",
+		func(database db.Database) func(context.Context) ([]media.Artist, error) { return database.AllArtists },
+		func(ctx context.Context, database db.Database, artist media.Artist, overwrite bool) error {
+			return upsert(overwrite,
+				func() error { _, err := database.Artist(ctx, artist.ID); return err },
+				func() error { return database.AddArtist(ctx, artist) },
+				func() error { return database.UpdateArtist(ctx, artist) },
+			)
+		},
+	),
+	newTable("playlists",
+		func(database db.Database) func(context.Context) ([]media.Playlist, error) {
+			return database.AllPlaylists
+		},
+		func(ctx context.Context, database db.Database, playlist media.Playlist, overwrite bool) error {
+			// Backups made before playlists could be shared have neither visibility nor entries.
+			if playlist.Visibility == "" {
+				playlist.Visibility = media.PlaylistVisibilityPublic
+			}
+			if playlist.Entries == nil {
+				playlist.Entries = media.ReconcileEntries(nil, playlist.TrackIDs, playlist.UserID, playlist.AdditionDate)
+			}
+			// Backups made before smart playlists only have standard ones.
+			if playlist.Kind == "" {
+				playlist.Kind = media.PlaylistKindStandard
+			}
+			return upsert(overwrite,
+				func() error { _, err := database.Playlist(ctx, playlist.ID); return err },
+				func() error { return database.AddPlaylist(ctx, playlist) },
+				func() error { return database.UpdatePlaylist(ctx, playlist) },
+			)
+		},
+	),
+	newTable("listens",
+		func(database db.Database) func(context.Context) ([]media.Listen, error) { return database.AllListens },
+		func(ctx context.Context, database db.Database, listen media.Listen, overwrite bool) error {
+			// Listens are never updated, so an existing listen is kept as is.
+			return upsert(overwrite,
+				func() error { _, err := database.Listen(ctx, listen.ID); return err },
+				func() error { return database.AddListen(ctx, listen) },
+				func() error { return nil },
+			)
+		},
+	),
+	newTable("favorites",
+		func(database db.Database) func(context.Context) ([]media.Favorite, error) {
+			return database.AllFavorites
+		},
+		func(ctx context.Context, database db.Database, favorite media.Favorite, _ bool) error {
+			// Favorite counts are restored with the playables, so they aren't incremented here.
+			_, err := database.AddFavorite(ctx, favorite)
+			return err
+		},
+	),
+	newTable("listenbrainz_tokens",
+		func(database db.Database) func(context.Context) ([]db.ListenBrainzToken, error) {
+			return database.ListenBrainzTokens
+		},
+		func(ctx context.Context, database db.Database, token db.ListenBrainzToken, _ bool) error {
+			// Backups made before tokens were hashed only have the raw token, so those users have to reset it.
+			if token.TokenHash == "" {
+				return nil
+			}
+			// Setting a token always replaces the user's previous one.
+			return database.SetListenBrainzToken(ctx, token.UserID, token.TokenHash, token.CreationDate)
+		},
+	),
+	newTable("scrobble_queue",
+		func(database db.Database) func(context.Context) ([]db.Scrobble, error) { return database.AllScrobbles },
+		func(ctx context.Context, database db.Database, scrobble db.Scrobble, overwrite bool) error {
+			return upsert(overwrite,
+				func() error { _, err := database.Scrobble(ctx, scrobble.ID); return err },
+				func() error { return database.AddScrobble(ctx, scrobble) },
+				func() error { return database.UpdateScrobble(ctx, scrobble) },
+			)
+		},
+	),
+	newTable("scrobbler_links",
+		func(database db.Database) func(context.Context) ([]db.ScrobblerLink, error) {
+			return database.ScrobblerLinks
+		},
+		func(ctx context.Context, database db.Database, link db.ScrobblerLink, _ bool) error {
+			// Setting a link always replaces the user's previous token for the scrobbler.
+			return database.SetScrobblerLink(ctx, link)
+		},
+	),
+	newTable("play_queues",
+		func(database db.Database) func(context.Context) ([]media.PlayQueue, error) {
+			return database.AllPlayQueues
+		},
+		func(ctx context.Context, database db.Database, queue media.PlayQueue, _ bool) error {
+			// Setting a queue always replaces the user's previous one.
+			return database.SetPlayQueue(ctx, queue)
+		},
+	),
+	newTable("blacklisted_tokens",
+		func(database db.Database) func(context.Context) ([]db.BlacklistedToken, error) {
+			return database.BlacklistedTokens
+		},
+		func(ctx context.Context, database db.Database, token db.BlacklistedToken, _ bool) error {
+			blacklisted, err := database.IsTokenBlacklisted(ctx, token.Token)
+			if err != nil || blacklisted {
+				return err
+			}
+			return database.BlacklistToken(ctx, token.Token, token.Expiration)
+		},
+	),
+	newTable("refresh_tokens",
+		func(database db.Database) func(context.Context) ([]db.RefreshToken, error) {
+			return database.RefreshTokens
+		},
+		func(ctx context.Context, database db.Database, token db.RefreshToken, _ bool) error {
+			// Refresh tokens that are already in the database are kept as they are.
+			_, err := database.RefreshToken(ctx, token.TokenHash)
+			if !errors.Is(err, db.ErrNotFound) {
+				return err
+			}
+			return database.AddRefreshToken(ctx, token)
+		},
+	),
+	newTable("sessions",
+		func(database db.Database) func(context.Context) ([]db.Session, error) { return database.Sessions },
+		func(ctx context.Context, database db.Database, session db.Session, overwrite bool) error {
+			return upsert(overwrite,
+				func() error { _, err := database.Session(ctx, session.ID); return err },
+				func() error { return database.AddSession(ctx, session) },
+				func() error { return database.UpdateSession(ctx, session) },
+			)
+		},
+	),
+	newTable("totp_secrets",
+		func(database db.Database) func(context.Context) ([]db.TOTPSecret, error) { return database.TOTPSecrets },
+		func(ctx context.Context, database db.Database, secret db.TOTPSecret, overwrite bool) error {
+			return upsert(overwrite,
+				func() error { _, err := database.TOTPSecret(ctx, secret.UserID); return err },
+				func() error { return database.SetTOTPSecret(ctx, secret) },
+				func() error { return database.SetTOTPSecret(ctx, secret) },
+			)
+		},
+	),
+	newTable("recovery_codes",
+		func(database db.Database) func(context.Context) ([]db.RecoveryCode, error) {
+			return database.RecoveryCodes
+		},
+		func(ctx context.Context, database db.Database, code db.RecoveryCode, overwrite bool) error {
+			// Recovery codes are only ever added and deleted, so replacing one means deleting it first.
+			if overwrite {
+				if _, err := database.UseRecoveryCode(ctx, code.UserID, code.CodeHash); err != nil {
+					return err
+				}
+			}
+			return database.AddRecoveryCode(ctx, code)
+		},
+	),
+	newTable("webauthn_credentials",
+		func(database db.Database) func(context.Context) ([]db.WebAuthnCredential, error) {
+			return database.WebAuthnCredentials
+		},
+		func(ctx context.Context, database db.Database, credential db.WebAuthnCredential, overwrite bool) error {
+			return upsert(overwrite,
+				func() error { _, err := database.WebAuthnCredential(ctx, credential.ID); return err },
+				func() error { return database.AddWebAuthnCredential(ctx, credential) },
+				func() error { return database.UpdateWebAuthnCredential(ctx, credential) },
+			)
+		},
+	),
+	newTable("access_tokens",
+		func(database db.Database) func(context.Context) ([]db.AccessToken, error) {
+			return database.AccessTokens
+		},
+		func(ctx context.Context, database db.Database, token db.AccessToken, overwrite bool) error {
+			return upsert(overwrite,
+				func() error { _, err := database.AccessToken(ctx, token.ID); return err },
+				func() error { return database.AddAccessToken(ctx, token) },
+				func() error { return database.UpdateAccessToken(ctx, token) },
+			)
+		},
+	),
+	newTable("email_tokens",
+		func(database db.Database) func(context.Context) ([]db.EmailToken, error) { return database.EmailTokens },
+		func(ctx context.Context, database db.Database, token db.EmailToken, _ bool) error {
+			// Email tokens that are already in the database are kept as they are.
+			_, err := database.EmailTokenByHash(ctx, token.TokenHash)
+			if !errors.Is(err, db.ErrNotFound) {
+				return err
+			}
+			return database.AddEmailToken(ctx, token)
+		},
+	),
+	newTable("email_verifications",
+		func(database db.Database) func(context.Context) ([]db.EmailVerification, error) {
+			return database.EmailVerifications
+		},
+		func(ctx context.Context, database db.Database, verification db.EmailVerification, overwrite bool) error {
+			return upsert(overwrite,
+				func() error { _, err := database.EmailVerification(ctx, verification.UserID); return err },
+				func() error { return database.SetEmailVerification(ctx, verification) },
+				func() error { return database.SetEmailVerification(ctx, verification) },
+			)
+		},
+	),
+	newTable("audit_log",
+		func(database db.Database) func(context.Context) ([]db.AuditEvent, error) {
+			return database.AllAuditEvents
+		},
+		func(ctx context.Context, database db.Database, event db.AuditEvent, overwrite bool) error {
+			// Audit events are never updated, so an existing event is kept as is.
+			return upsert(overwrite,
+				func() error { _, err := database.AuditEvent(ctx, event.ID); return err },
+				func() error { return database.AddAuditEvent(ctx, event) },
+				func() error { return nil },
+			)
+		},
+	),
+}
+
+func newTable[T any](
+	name string,
+	list func(database db.Database) func(ctx context.Context) ([]T, error),
+	put func(ctx context.Context, database db.Database, row T, overwrite bool) error,
+) table {
+	return table{
+		name: name,
+		dump: func(ctx context.Context, database db.Database) ([]byte, int, error) {
+			rows, err := list(database)(ctx)
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to read %s: %w", name, err)
+			}
+			data, err := dumpRows(rows)
+			return data, len(rows), err
+		},
+		count: func(ctx context.Context, database db.Database) (int, error) {
+			rows, err := list(database)(ctx)
+			return len(rows), err
+		},
+		restore: func(ctx context.Context, database db.Database, r io.Reader, overwrite bool) (int, error) {
+			dec := json.NewDecoder(r)
+			restored := 0
+			for {
+				var row T
+				err := dec.Decode(&row)
+				if errors.Is(err, io.EOF) {
+					return restored, nil
+				}
+				if err != nil {
+					return restored, fmt.Errorf("failed to parse %s: %w", name, err)
+				}
+				if err := put(ctx, database, row, overwrite); err != nil {
+					return restored, fmt.Errorf("failed to restore %s: %w", name, err)
+				}
+				restored++
+			}
+		},
+	}
+}
+
+// upsert adds a row, or updates it if it already exists and overwrite is set.
+func upsert(overwrite bool, get, add, update func() error) error {
+	if overwrite {
+		err := get()
+		if err == nil {
+			return update()
+		}
+		if !errors.Is(err, db.ErrNotFound) {
+			return err
+		}
+	}
+	return add()
+}
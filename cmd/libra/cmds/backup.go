@@ -0,0 +1,97 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+
+	"github.com/libramusic/libracore/backup"
+	"github.com/libramusic/libracore/db"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup [file]",
+	Short: "Back up the database",
+	Long: `Back up the database to an engine-independent archive.
+The archive can be restored into any supported database engine with 'libra restore'.
+If 'file' is not given, a timestamped file is created in the current directory.`,
+	Args:     cobra.MaximumNArgs(1),
+	PreRunE:  connectDatabase,
+	PostRunE: closeDatabase,
+	Run: func(cmd *cobra.Command, args []string) {
+		includeStorage, _ := cmd.Flags().GetBool("include-storage")
+
+		name := "libra-backup-" + time.Now().Format("20060102-150405") + ".tar.gz"
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		file, err := os.Create(name)
+		if err != nil {
+			log.Fatal("Error creating backup file", "err", err)
+		}
+		manifest, err := backup.Create(context.Background(), db.DB, file, backup.Options{
+			IncludeStorage: includeStorage,
+		})
+		if err != nil {
+			file.Close()
+			os.Remove(name)
+			log.Fatal("Error creating backup", "err", err)
+		}
+		if err := file.Close(); err != nil {
+			log.Fatal("Error writing backup file", "err", err)
+		}
+
+		printCounts(manifest.Counts)
+		fmt.Println("Backup written to", name)
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Restore the database from a backup",
+	Long: `Restore the database from an archive created by 'libra backup'.
+The database is migrated to the latest schema first.
+Restoring into a non-empty database requires --force, which overwrites rows with the same IDs.`,
+	Args:     cobra.ExactArgs(1),
+	PreRunE:  connectDatabase,
+	PostRunE: closeDatabase,
+	Run: func(cmd *cobra.Command, args []string) {
+		force, _ := cmd.Flags().GetBool("force")
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			log.Fatal("Error opening backup file", "err", err)
+		}
+		defer file.Close()
+
+		manifest, err := backup.Restore(context.Background(), db.DB, file, backup.RestoreOptions{Force: force})
+		if err != nil {
+			log.Fatal("Error restoring backup", "err", err)
+		}
+
+		printCounts(manifest.Counts)
+		fmt.Printf("Restored backup from LibraCore v%s (%s, schema version %d)\n",
+			manifest.LibraVersion, manifest.Engine, manifest.SchemaVersion)
+	},
+}
+
+func printCounts(counts map[string]int) {
+	for _, table := range slices.Sorted(maps.Keys(counts)) {
+		fmt.Printf("  %-20s %d\n", table, counts[table])
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+
+	backupCmd.Flags().Bool("include-storage", false, "include the contents of the storage directory")
+	restoreCmd.Flags().Bool("force", false, "restore into a non-empty database, overwriting existing rows")
+}
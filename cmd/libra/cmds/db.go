@@ -0,0 +1,125 @@
+package cmds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+
+	"github.com/libramusic/libracore/db"
+)
+
+func connectDatabase(_ *cobra.Command, _ []string) error {
+	err := db.Connect()
+	if err != nil {
+		return fmt.Errorf("database connection failed: %w", err)
+	}
+	log.Info("Connected to database", "engine", db.DB.EngineName())
+	return nil
+}
+
+func closeDatabase(_ *cobra.Command, _ []string) error {
+	if db.DB != nil {
+		err := db.DB.Close()
+		if err != nil {
+			return fmt.Errorf("error closing database connection: %w", err)
+		}
+		log.Info("Database connection closed")
+	}
+	return nil
+}
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Manage the database",
+	Long:  `Manage the database.`,
+}
+
+var transferCmd = &cobra.Command{
+	Use:   "transfer",
+	Short: "Copy all data from one database engine to another",
+	Long: `Copy all data from one database engine to another.
+Connection settings are read from the config file unless a DSN is given.
+The target database is migrated to the latest schema before copying.
+Rows that already exist in the target are skipped, so an interrupted transfer can be resumed by running it again.`,
+	Example:           `libra db transfer --from sqlite --to postgresql`,
+	Args:              cobra.NoArgs,
+	ValidArgsFunction: cobra.NoFileCompletions,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		fromDSN, _ := cmd.Flags().GetString("from-dsn")
+		toDSN, _ := cmd.Flags().GetString("to-dsn")
+		batchSize, _ := cmd.Flags().GetInt("batch-size")
+
+		// Compare the engines rather than their names, which can be aliases like sqlite and sqlite3.
+		fromEngine, err := db.Engine(from)
+		if err != nil {
+			return fmt.Errorf("invalid source engine %q: %w", from, err)
+		}
+		toEngine, err := db.Engine(to)
+		if err != nil {
+			return fmt.Errorf("invalid target engine %q: %w", to, err)
+		}
+		if fromEngine == toEngine && fromDSN == toDSN {
+			return errors.New("source and target databases must be different")
+		}
+
+		source, err := db.Open(from, fromDSN)
+		if err != nil {
+			return fmt.Errorf("source database connection failed: %w", err)
+		}
+		defer source.Close()
+		log.Info("Connected to source database", "engine", source.EngineName())
+
+		target, err := db.Open(to, toDSN)
+		if err != nil {
+			return fmt.Errorf("target database connection failed: %w", err)
+		}
+		defer target.Close()
+		log.Info("Connected to target database", "engine", target.EngineName())
+
+		results, err := db.Transfer(context.Background(), source, target, db.TransferOptions{
+			BatchSize: batchSize,
+			Progress: func(progress db.TransferProgress) {
+				fmt.Printf(
+					"%s: %d/%d (%d copied, %d skipped)\n",
+					progress.Table,
+					progress.Copied+progress.Skipped,
+					progress.Total,
+					progress.Copied,
+					progress.Skipped,
+				)
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("error transferring database: %w", err)
+		}
+
+		fmt.Println("Verification:")
+		for _, result := range results {
+			fmt.Printf("  %-20s source=%d target=%d\n", result.Table, result.Source, result.Target)
+		}
+		fmt.Println("Database transfer complete")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(transferCmd)
+
+	transferCmd.Flags().String("from", "", "source database engine (sqlite|postgresql|mysql)")
+	transferCmd.Flags().String("to", "", "target database engine (sqlite|postgresql|mysql)")
+	transferCmd.Flags().String("from-dsn", "", "source connection string (defaults to the config file)")
+	transferCmd.Flags().String("to-dsn", "", "target connection string (defaults to the config file)")
+	transferCmd.Flags().Int("batch-size", db.DefaultTransferBatchSize, "number of rows copied in each transaction")
+	_ = transferCmd.MarkFlagRequired("from")
+	_ = transferCmd.MarkFlagRequired("to")
+	engines := cobra.FixedCompletions([]string{"sqlite", "postgresql", "mysql"}, cobra.ShellCompDirectiveNoFileComp)
+	_ = transferCmd.RegisterFlagCompletionFunc("from", engines)
+	_ = transferCmd.RegisterFlagCompletionFunc("to", engines)
+}
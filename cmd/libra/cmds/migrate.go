@@ -1,8 +1,11 @@
 package cmds
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/charmbracelet/log"
 	"github.com/spf13/cobra"
@@ -10,29 +13,15 @@ import (
 	"github.com/libramusic/libracore/db"
 )
 
+var dryRun bool
+
 var migrateCmd = &cobra.Command{
 	Use:   "migrate",
 	Short: "Migrate the database",
 	Long: `Migrate the database.
 Uses your database connection string from the config file.`,
-	PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
-		err := db.Connect()
-		if err != nil {
-			return fmt.Errorf("database connection failed: %w", err)
-		}
-		log.Info("Connected to database", "engine", db.DB.EngineName())
-		return nil
-	},
-	PersistentPostRunE: func(_ *cobra.Command, _ []string) error {
-		if db.DB != nil {
-			err := db.DB.Close()
-			if err != nil {
-				return fmt.Errorf("error closing database connection: %w", err)
-			}
-			log.Info("Database connection closed")
-		}
-		return nil
-	},
+	PersistentPreRunE:  connectDatabase,
+	PersistentPostRunE: closeDatabase,
 }
 
 var upCmd = &cobra.Command{
@@ -52,6 +41,14 @@ Use 'steps' to specify the number of steps to migrate.`,
 				return
 			}
 		}
+		if dryRun {
+			plan, err := db.PlanMigrations(context.Background(), db.DB, steps, true)
+			if err != nil {
+				log.Fatal("Error planning migration", "err", err)
+			}
+			printMigrationPlan(plan)
+			return
+		}
 		err := db.DB.MigrateUp(steps)
 		if err != nil {
 			log.Fatal("Error migrating database", "err", err)
@@ -77,6 +74,14 @@ Use 'steps' to specify the number of steps to migrate.`,
 				return
 			}
 		}
+		if dryRun {
+			plan, err := db.PlanMigrations(context.Background(), db.DB, steps, false)
+			if err != nil {
+				log.Fatal("Error planning migration", "err", err)
+			}
+			printMigrationPlan(plan)
+			return
+		}
 		err := db.DB.MigrateDown(steps)
 		if err != nil {
 			log.Fatal("Error migrating database", "err", err)
@@ -85,8 +90,116 @@ Use 'steps' to specify the number of steps to migrate.`,
 	},
 }
 
+var gotoCmd = &cobra.Command{
+	Use:   "goto <version>",
+	Short: "Migrate the database up or down to a specific version",
+	Long: `Migrate the database up or down to a specific version.
+Use version 0 to revert every migration.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: cobra.NoFileCompletions,
+	Run: func(_ *cobra.Command, args []string) {
+		version, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			fmt.Println("Error: version must be a non-negative integer")
+			return
+		}
+		if dryRun {
+			plan, err := db.PlanMigrateTo(context.Background(), db.DB, version)
+			if err != nil {
+				log.Fatal("Error planning migration", "err", err)
+			}
+			printMigrationPlan(plan)
+			return
+		}
+		err = db.MigrateTo(context.Background(), db.DB, version)
+		if err != nil {
+			log.Fatal("Error migrating database", "err", err)
+		}
+		fmt.Println("Database migration complete")
+	},
+}
+
+var statusCmd = &cobra.Command{
+	Use:               "status",
+	Short:             "Show the migration status of the database",
+	Long:              `Show the current schema version, whether the last migration failed, and any pending migrations.`,
+	Args:              cobra.NoArgs,
+	ValidArgsFunction: cobra.NoFileCompletions,
+	Run: func(_ *cobra.Command, _ []string) {
+		version, dirty, err := db.CurrentMigrationVersion(context.Background(), db.DB)
+		if err != nil {
+			log.Fatal("Error getting migration version", "err", err)
+		}
+		pending, err := db.PendingMigrations(context.Background(), db.DB)
+		if err != nil {
+			log.Fatal("Error getting pending migrations", "err", err)
+		}
+
+		fmt.Println("Engine: ", db.DB.EngineName())
+		fmt.Println("Version:", version)
+		fmt.Println("Dirty:  ", dirty)
+		if dirty {
+			fmt.Println("The last migration failed. Fix the database manually, then run 'libra migrate force <version>'.")
+		}
+		if len(pending) == 0 {
+			fmt.Println("No pending migrations")
+			return
+		}
+		fmt.Println("Pending migrations:")
+		for _, migration := range pending {
+			fmt.Printf("  %d %s\n", migration.Version, migration.Name())
+		}
+	},
+}
+
+var forceCmd = &cobra.Command{
+	Use:   "force <version>",
+	Short: "Set the migration version without running migrations",
+	Long: `Set the migration version without running migrations and clear the dirty flag.
+Use this to recover after fixing a database left dirty by a failed migration.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		// Forcing doesn't run any migrations, so there's no SQL to print.
+		if dryRun {
+			return errors.New("--dry-run can't be used with force")
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	ValidArgsFunction: cobra.NoFileCompletions,
+	Run: func(_ *cobra.Command, args []string) {
+		version, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			fmt.Println("Error: version must be a non-negative integer")
+			return
+		}
+		err = db.ForceMigrationVersion(context.Background(), db.DB, version)
+		if err != nil {
+			log.Fatal("Error forcing migration version", "err", err)
+		}
+		fmt.Println("Migration version set to", version)
+	},
+}
+
+func printMigrationPlan(plan []db.Migration) {
+	if len(plan) == 0 {
+		fmt.Println("No migrations to run")
+		return
+	}
+	for _, migration := range plan {
+		content, err := db.MigrationSQL(db.DB, migration)
+		if err != nil {
+			log.Fatal("Error reading migration", "err", err)
+		}
+		fmt.Printf("-- %s\n%s\n", migration.File, strings.TrimSpace(content))
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(migrateCmd)
 	migrateCmd.AddCommand(upCmd)
 	migrateCmd.AddCommand(downCmd)
+	migrateCmd.AddCommand(gotoCmd)
+	migrateCmd.AddCommand(statusCmd)
+	migrateCmd.AddCommand(forceCmd)
+
+	migrateCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print the SQL that would run without applying it")
 }
@@ -0,0 +1,134 @@
+package cmds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/playlistfile"
+)
+
+var playlistCmd = &cobra.Command{
+	Use:   "playlist",
+	Short: "Manage playlists",
+	Long:  `Manage playlists.`,
+}
+
+var playlistImportCmd = &cobra.Command{
+	Use:   "import <file>...",
+	Short: "Import playlist files",
+	Long: `Import M3U8, XSPF, PLS and JSPF playlist files as private playlists owned by a user.
+Entries are matched against the library by path or stream URL, ISRC, MusicBrainz recording ID,
+and finally by title, artist and duration. Entries that match no track are left out and listed.
+The format of each file is detected from its extension or content unless --format is given.
+Playlists are titled after the title in the file, or the file name if it has none.`,
+	Example:  `libra playlist import --user alice ~/Music/Playlists/*.m3u8`,
+	Args:     cobra.MinimumNArgs(1),
+	PreRunE:  connectDatabase,
+	PostRunE: closeDatabase,
+	Run: func(cmd *cobra.Command, args []string) {
+		username, _ := cmd.Flags().GetString("user")
+		formatName, _ := cmd.Flags().GetString("format")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		ctx := context.Background()
+
+		user, err := db.DB.UserByUsername(ctx, username)
+		if errors.Is(err, db.ErrNotFound) {
+			log.Fatal("User not found", "username", username)
+		}
+		if err != nil {
+			log.Fatal("Error getting user", "err", err, "username", username)
+		}
+
+		matcher, err := playlistfile.NewMatcher(ctx)
+		if err != nil {
+			log.Fatal("Error getting tracks to match", "err", err)
+		}
+
+		failed := false
+		for _, name := range args {
+			if err := importPlaylistFile(ctx, matcher, user.ID, name, formatName, dryRun); err != nil {
+				log.Error("Error importing playlist", "err", err, "file", name)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func importPlaylistFile(
+	ctx context.Context,
+	matcher *playlistfile.Matcher,
+	userID, name, formatName string,
+	dryRun bool,
+) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	format, err := playlistfile.FormatByName(filepath.Ext(name))
+	if err != nil {
+		format = playlistfile.Detect(data)
+	}
+	if formatName != "" {
+		if format, err = playlistfile.FormatByName(formatName); err != nil {
+			return err
+		}
+	}
+	file, err := format.Parse(data)
+	if err != nil {
+		return fmt.Errorf("invalid %s file: %w", format.Name, err)
+	}
+	if file.Title == "" {
+		file.Title = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	}
+
+	var unmatched []playlistfile.Unmatched
+	if dryRun {
+		var trackIDs []string
+		trackIDs, unmatched = matcher.Match(file.Entries)
+		fmt.Printf("%s: %q would have %d of %d tracks\n", name, file.Title, len(trackIDs), len(file.Entries))
+	} else {
+		result, err := playlistfile.Import(ctx, matcher, userID, "", file)
+		if err != nil {
+			return err
+		}
+		unmatched = result.Unmatched
+		fmt.Printf("%s: imported %q (%s) with %d of %d tracks\n",
+			name, file.Title, result.Playlist.ID, len(result.Playlist.TrackIDs), len(file.Entries))
+	}
+
+	for _, u := range unmatched {
+		description := u.Entry.Location
+		if u.Entry.Title != "" {
+			description = strings.TrimPrefix(u.Entry.Artist+" - "+u.Entry.Title, " - ")
+		}
+		fmt.Printf("  unmatched #%d: %s\n", u.Position+1, description)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(playlistCmd)
+	playlistCmd.AddCommand(playlistImportCmd)
+
+	playlistImportCmd.Flags().String("user", "", "username of the user who will own the playlists")
+	_ = playlistImportCmd.MarkFlagRequired("user")
+	playlistImportCmd.Flags().String("format", "", "format of the files (m3u8|xspf|pls|jspf)")
+	_ = playlistImportCmd.RegisterFlagCompletionFunc(
+		"format",
+		cobra.FixedCompletions([]string{"m3u8", "xspf", "pls", "jspf"}, cobra.ShellCompDirectiveNoFileComp),
+	)
+	playlistImportCmd.Flags().Bool("dry-run", false, "only report how the files would be matched")
+}
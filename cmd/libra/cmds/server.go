@@ -20,10 +20,12 @@ import (
 
 	"github.com/libramusic/libracore/config"
 	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/scrobble"
 	"github.com/libramusic/libracore/server"
 	"github.com/libramusic/libracore/server/metrics"
 	"github.com/libramusic/libracore/server/routes/auth"
 	"github.com/libramusic/libracore/server/routes/auth/providers"
+	"github.com/libramusic/libracore/smartplaylist"
 	"github.com/libramusic/libracore/storage"
 )
 
@@ -102,6 +104,11 @@ var serverCmd = &cobra.Command{
 
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 		defer stop()
+
+		go scrobble.Run(ctx)
+		go smartplaylist.Run(ctx)
+		go auth.SweepAuthState(ctx)
+
 		errCh := make(chan error, 1)
 		go func() {
 			if err := e.Start(fmt.Sprintf(":%d", config.Conf.Application.Port)); !errors.Is(err, http.ErrServerClosed) {
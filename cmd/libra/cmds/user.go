@@ -0,0 +1,81 @@
+package cmds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/server/routes/auth"
+)
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage users",
+	Long:  `Manage users.`,
+}
+
+var userReset2FACmd = &cobra.Command{
+	Use:   "reset-2fa <username>",
+	Short: "Turn off two-factor authentication for a user",
+	Long: `Turn off two-factor authentication for a user who lost access to their authenticator app,
+recovery codes or passkeys. Their TOTP secret, recovery codes and passkeys are deleted,
+so they can log in with their password alone and set up two-factor authentication again.`,
+	Example:  `libra user reset-2fa alice`,
+	Args:     cobra.ExactArgs(1),
+	PreRunE:  connectDatabase,
+	PostRunE: closeDatabase,
+	Run: func(_ *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		user, err := db.DB.UserByUsername(ctx, args[0])
+		if errors.Is(err, db.ErrNotFound) {
+			log.Fatal("User not found", "username", args[0])
+		}
+		if err != nil {
+			log.Fatal("Error getting user", "err", err, "username", args[0])
+		}
+
+		if err := auth.ResetTwoFactor(ctx, user.ID); err != nil {
+			log.Fatal("Error resetting two-factor authentication", "err", err, "username", user.Username)
+		}
+		fmt.Printf("Two-factor authentication is off for %s\n", user.Username)
+	},
+}
+
+var userSetRoleCmd = &cobra.Command{
+	Use:   "set-role <username> <role>",
+	Short: "Change the role of a user",
+	Long: `Change the role of a user to owner, admin, member or guest.
+Making a user the owner makes the previous owner an admin. This is how existing servers get an owner,
+since only the first user to register becomes one automatically.`,
+	Example:  `libra user set-role alice owner`,
+	Args:     cobra.ExactArgs(2),
+	PreRunE:  connectDatabase,
+	PostRunE: closeDatabase,
+	Run: func(_ *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		user, err := db.DB.UserByUsername(ctx, args[0])
+		if errors.Is(err, db.ErrNotFound) {
+			log.Fatal("User not found", "username", args[0])
+		}
+		if err != nil {
+			log.Fatal("Error getting user", "err", err, "username", args[0])
+		}
+
+		if err := auth.AssignRole(ctx, user, args[1]); err != nil {
+			log.Fatal("Error changing role", "err", err, "username", user.Username, "role", args[1])
+		}
+		fmt.Printf("%s is now %s\n", user.Username, args[1])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(userCmd)
+	userCmd.AddCommand(userReset2FACmd)
+	userCmd.AddCommand(userSetRoleCmd)
+}
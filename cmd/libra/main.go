@@ -12,5 +12,7 @@ func main() {
 	executablePath, _ = filepath.EvalSymlinks(executablePath)
 	_ = os.Chdir(filepath.Dir(executablePath))
 
-	_ = cmds.Execute()
+	if err := cmds.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
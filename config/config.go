@@ -5,13 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"math"
+	"net"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/c2h5oh/datasize"
 	"github.com/charmbracelet/log"
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/libramusic/taurus/v2"
 
@@ -29,11 +33,37 @@ const EnvPrefix = "LIBRA"
 var DataDir string
 
 type ApplicationConfig struct {
-	Port       int      `yaml:"port"`
-	PublicURL  string   `yaml:"public_url"`
-	SourceID   string   `yaml:"source_id"`
-	SourceName string   `yaml:"source_name"`
-	MediaTypes []string `yaml:"media_types"`
+	Port           int      `yaml:"port"`
+	PublicURL      string   `yaml:"public_url"`
+	SourceID       string   `yaml:"source_id"`
+	SourceName     string   `yaml:"source_name"`
+	MediaTypes     []string `yaml:"media_types"`
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// TrustedProxyRanges parses TrustedProxies. Single addresses are ranges of one address.
+func (c ApplicationConfig) TrustedProxyRanges() ([]*net.IPNet, error) {
+	ranges := make([]*net.IPNet, 0, len(c.TrustedProxies))
+	for _, proxy := range c.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			ranges = append(ranges, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipRange, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		ranges = append(ranges, ipRange)
+	}
+	return ranges, nil
 }
 
 type JWTAuthConfig struct {
@@ -43,13 +73,69 @@ type JWTAuthConfig struct {
 	AccessTokenExpiration  time.Duration `yaml:"access_token_expiration"`
 }
 
+type LoginThrottlingAuthConfig struct {
+	Enabled           bool          `yaml:"enabled"`
+	FreeAttempts      int           `yaml:"free_attempts"`
+	InitialBackoff    time.Duration `yaml:"initial_backoff"`
+	MaxBackoff        time.Duration `yaml:"max_backoff"`
+	LockoutAttempts   int           `yaml:"lockout_attempts"`
+	IPLockoutAttempts int           `yaml:"ip_lockout_attempts"`
+	LockoutDuration   time.Duration `yaml:"lockout_duration"`
+	FailureWindow     time.Duration `yaml:"failure_window"`
+	CaptchaAttempts   int           `yaml:"captcha_attempts"`
+}
+
+type PasswordPolicyAuthConfig struct {
+	MinLength             int    `yaml:"min_length"`
+	MaxLength             int    `yaml:"max_length"`
+	DisallowUsername      bool   `yaml:"disallow_username"`
+	BreachedPasswordsFile string `yaml:"breached_passwords_file"`
+}
+
+type PasswordHashingAuthConfig struct {
+	Algorithm           string            `yaml:"algorithm"`
+	Argon2idMemory      datasize.ByteSize `yaml:"argon2id_memory"`
+	Argon2idIterations  int               `yaml:"argon2id_iterations"`
+	Argon2idParallelism int               `yaml:"argon2id_parallelism"`
+	BcryptCost          int               `yaml:"bcrypt_cost"`
+}
+
+// Validate reports settings that would fail to hash passwords or make weak hashes.
+func (c PasswordHashingAuthConfig) Validate() error {
+	switch c.Algorithm {
+	case "argon2id", "bcrypt":
+	default:
+		return fmt.Errorf("algorithm must be argon2id or bcrypt, got %q", c.Algorithm)
+	}
+	// Argon2id needs at least 8 KiB of memory per lane, and takes the settings as 32-bit and 8-bit integers.
+	if c.Argon2idParallelism < 1 || c.Argon2idParallelism > math.MaxUint8 {
+		return fmt.Errorf("argon2id_parallelism must be between 1 and 255, got %d", c.Argon2idParallelism)
+	}
+	if c.Argon2idIterations < 1 || uint64(c.Argon2idIterations) > math.MaxUint32 {
+		return fmt.Errorf("argon2id_iterations must be at least 1 and fit in 32 bits, got %d", c.Argon2idIterations)
+	}
+	minMemory := datasize.ByteSize(8*c.Argon2idParallelism) * datasize.KB
+	if c.Argon2idMemory < minMemory || c.Argon2idMemory/datasize.KB > math.MaxUint32 {
+		return fmt.Errorf("argon2id_memory must be between %s and 4TB, got %s", minMemory.HR(), c.Argon2idMemory.HR())
+	}
+	if c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt_cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, c.BcryptCost)
+	}
+	return nil
+}
+
 type AuthConfig struct {
-	JWT                        JWTAuthConfig            `yaml:"jwt"`
-	Providers                  []providers.AuthProvider `yaml:"providers"`
-	GlobalAPIRoutesRequireAuth bool                     `yaml:"global_api_routes_require_auth"`
-	UserAPIRoutesRequireAuth   bool                     `yaml:"user_api_routes_require_auth"`
-	UserAPIRequireSameUseUser  bool                     `yaml:"user_api_require_same_user"`
-	DisableAccountCreation     bool                     `yaml:"disable_account_creation"`
+	JWT                         JWTAuthConfig             `yaml:"jwt"`
+	Providers                   []providers.AuthProvider  `yaml:"providers"`
+	GlobalAPIRoutesRequireAuth  bool                      `yaml:"global_api_routes_require_auth"`
+	UserAPIRoutesRequireAuth    bool                      `yaml:"user_api_routes_require_auth"`
+	UserAPIRequireSameUseUser   bool                      `yaml:"user_api_require_same_user"`
+	DisableAccountCreation      bool                      `yaml:"disable_account_creation"`
+	EmailVerificationExpiration time.Duration             `yaml:"email_verification_expiration"`
+	PasswordResetExpiration     time.Duration             `yaml:"password_reset_expiration"`
+	LoginThrottling             LoginThrottlingAuthConfig `yaml:"login_throttling"`
+	PasswordPolicy              PasswordPolicyAuthConfig  `yaml:"password_policy"`
+	PasswordHashing             PasswordHashingAuthConfig `yaml:"password_hashing"`
 }
 
 type GeneralConfig struct {
@@ -85,6 +171,38 @@ type StorageConfig struct {
 	MinimumAgeThreshold time.Duration     `yaml:"minimum_age_threshold"`
 }
 
+type ScrobblerConfig struct {
+	URL       string `yaml:"url"`
+	APIKey    string `yaml:"api_key"`
+	APISecret string `yaml:"api_secret"`
+}
+
+type ScrobblingConfig struct {
+	Enabled        bool            `yaml:"enabled"`
+	PollInterval   time.Duration   `yaml:"poll_interval"`
+	MaxAttempts    int             `yaml:"max_attempts"`
+	InitialBackoff time.Duration   `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration   `yaml:"max_backoff"`
+	ListenBrainz   ScrobblerConfig `yaml:"listenbrainz"`
+	LastFM         ScrobblerConfig `yaml:"lastfm"`
+}
+
+type SmartPlaylistsConfig struct {
+	MaxTracks       int           `yaml:"max_tracks"`
+	StaleAfter      time.Duration `yaml:"stale_after"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+type MailConfig struct {
+	Host       string `yaml:"host"`
+	Port       int    `yaml:"port"`
+	Username   string `yaml:"username"`
+	Password   string `yaml:"password"`
+	From       string `yaml:"from"`
+	Encryption string `yaml:"encryption"`
+	LogOnly    bool   `yaml:"log_only"`
+}
+
 type SQLiteDatabaseConfig struct {
 	Path string `yaml:"path"`
 }
@@ -98,20 +216,33 @@ type PostgreSQLDatabaseConfig struct {
 	Params string `yaml:"params"`
 }
 
+type MySQLDatabaseConfig struct {
+	Host   string `yaml:"host"`
+	Port   int    `yaml:"port"`
+	User   string `yaml:"user"`
+	Pass   string `yaml:"pass"`
+	DBName string `yaml:"db_name"`
+	Params string `yaml:"params"`
+}
+
 type DatabaseConfig struct {
 	Engine     string                   `yaml:"engine"`
 	SQLite     SQLiteDatabaseConfig     `yaml:"sqlite"`
 	PostgreSQL PostgreSQLDatabaseConfig `yaml:"postgresql"`
+	MySQL      MySQLDatabaseConfig      `yaml:"mysql"`
 }
 
 type Config struct {
-	Application   ApplicationConfig   `yaml:"application"`
-	Auth          AuthConfig          `yaml:"auth"`
-	General       GeneralConfig       `yaml:"general"`
-	SourceScripts SourceScriptsConfig `yaml:"source_scripts"`
-	Logs          LogsConfig          `yaml:"logs"`
-	Storage       StorageConfig       `yaml:"storage"`
-	Database      DatabaseConfig      `yaml:"database"`
+	Application    ApplicationConfig    `yaml:"application"`
+	Auth           AuthConfig           `yaml:"auth"`
+	General        GeneralConfig        `yaml:"general"`
+	SourceScripts  SourceScriptsConfig  `yaml:"source_scripts"`
+	Logs           LogsConfig           `yaml:"logs"`
+	Storage        StorageConfig        `yaml:"storage"`
+	Scrobbling     ScrobblingConfig     `yaml:"scrobbling"`
+	SmartPlaylists SmartPlaylistsConfig `yaml:"smart_playlists"`
+	Mail           MailConfig           `yaml:"mail"`
+	Database       DatabaseConfig       `yaml:"database"`
 }
 
 func LoadConfig() error {
@@ -154,6 +285,13 @@ func LoadConfig() error {
 		log.Warn("Failed to read and merge flags", "err", err)
 	}
 
+	if _, err := Conf.Application.TrustedProxyRanges(); err != nil {
+		return fmt.Errorf("invalid application.trusted_proxies: %w", err)
+	}
+	if err := Conf.Auth.PasswordHashing.Validate(); err != nil {
+		return fmt.Errorf("invalid auth.password_hashing: %w", err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,72 @@
+package config_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/c2h5oh/datasize"
+
+	"github.com/libramusic/libracore/config"
+)
+
+func TestPasswordHashingValidate(t *testing.T) {
+	valid := config.PasswordHashingAuthConfig{
+		Algorithm:           "argon2id",
+		Argon2idMemory:      19 * datasize.MB,
+		Argon2idIterations:  2,
+		Argon2idParallelism: 1,
+		BcryptCost:          10,
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("default settings are invalid: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(c *config.PasswordHashingAuthConfig)
+	}{
+		{"unknown algorithm", func(c *config.PasswordHashingAuthConfig) { c.Algorithm = "md5" }},
+		{"no parallelism", func(c *config.PasswordHashingAuthConfig) { c.Argon2idParallelism = 0 }},
+		{"parallelism over 255", func(c *config.PasswordHashingAuthConfig) { c.Argon2idParallelism = 256 }},
+		{"no iterations", func(c *config.PasswordHashingAuthConfig) { c.Argon2idIterations = 0 }},
+		{"negative iterations", func(c *config.PasswordHashingAuthConfig) { c.Argon2idIterations = -1 }},
+		{"no memory", func(c *config.PasswordHashingAuthConfig) { c.Argon2idMemory = 0 }},
+		{"less memory than 8 KB per lane", func(c *config.PasswordHashingAuthConfig) {
+			c.Argon2idParallelism = 4
+			c.Argon2idMemory = 31 * datasize.KB
+		}},
+		{"memory over 32 bits of KB", func(c *config.PasswordHashingAuthConfig) { c.Argon2idMemory = 4 * datasize.PB }},
+		{"bcrypt cost too low", func(c *config.PasswordHashingAuthConfig) { c.BcryptCost = 3 }},
+		{"bcrypt cost too high", func(c *config.PasswordHashingAuthConfig) { c.BcryptCost = 32 }},
+	}
+	for _, test := range tests {
+		conf := valid
+		test.change(&conf)
+		if err := conf.Validate(); err == nil {
+			t.Errorf("%s: settings are valid", test.name)
+		}
+	}
+}
+
+func TestTrustedProxyRanges(t *testing.T) {
+	conf := config.ApplicationConfig{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1", "2001:db8::1"}}
+	ranges, err := conf.TrustedProxyRanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ipRange := range ranges {
+		got = append(got, ipRange.String())
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.1/32", "2001:db8::1/128"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, proxy := range []string{"proxy.example.com", "10.0.0.0/33", ""} {
+		conf := config.ApplicationConfig{TrustedProxies: []string{proxy}}
+		if _, err := conf.TrustedProxyRanges(); err == nil {
+			t.Errorf("%q is a valid trusted proxy", proxy)
+		}
+	}
+}
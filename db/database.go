@@ -4,6 +4,7 @@ import (
 	"context"
 	"embed"
 	"errors"
+	"fmt"
 	"io/fs"
 	"slices"
 	"strings"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/libramusic/libracore/config"
 	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/rules"
 )
 
 var (
@@ -26,28 +28,218 @@ var (
 	ErrTooMany           = errors.New("too many found in database")
 	ErrAlreadyConnected  = errors.New("database already connected")
 	ErrUnsupportedEngine = errors.New("unsupported database engine")
+	ErrRevisionMismatch  = errors.New("revision does not match")
 )
 
+// ProviderLink is a row of the auth_providers table.
+type ProviderLink struct {
+	UserID         string `json:"user_id"`
+	Provider       string `json:"provider"`
+	ProviderUserID string `json:"provider_user_id"`
+}
+
+// ListenBrainzToken is a row of the listenbrainz_tokens table.
+// Only the SHA-256 hash of the token is stored.
+type ListenBrainzToken struct {
+	UserID       string `json:"user_id"`
+	TokenHash    string `json:"token_hash"`
+	CreationDate int64  `json:"creation_date"`
+}
+
+// Scrobble is a listen queued to be forwarded to an external scrobbler.
+// Scrobbles that could not be delivered are kept with Failed set so they can be inspected and retried.
+type Scrobble struct {
+	ID            string `json:"id"`
+	UserID        string `json:"user_id"`
+	ListenID      string `json:"listen_id"`
+	Scrobbler     string `json:"scrobbler"`
+	Attempts      int    `json:"attempts"`
+	NextAttemptAt int64  `json:"next_attempt_at"`
+	LastError     string `json:"last_error"`
+	Failed        bool   `json:"failed"`
+	CreationDate  int64  `json:"creation_date"`
+}
+
+// ScrobblerLink is a row of the scrobbler_links table, which holds the token a user linked a scrobbler with.
+// The token has to be sent to the scrobbler with every listen, so unlike the server's own tokens it isn't hashed.
+type ScrobblerLink struct {
+	UserID       string `json:"user_id"`
+	Scrobbler    string `json:"scrobbler"`
+	Token        string `json:"token"`
+	CreationDate int64  `json:"creation_date"`
+}
+
+// BlacklistedToken is a row of the blacklisted_tokens table.
+type BlacklistedToken struct {
+	Token      string    `json:"token"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// RefreshToken is a row of the refresh_tokens table. Only the SHA-256 hash of the token is stored.
+// Every refresh token replaces the one it was exchanged for, and they make up a family that starts at a login.
+type RefreshToken struct {
+	TokenHash    string `json:"token_hash"`
+	UserID       string `json:"user_id"`
+	FamilyID     string `json:"family_id"`
+	CreationDate int64  `json:"creation_date"`
+	Expiration   int64  `json:"expiration"`
+	// When the token was exchanged for a new one, or 0 if it wasn't yet.
+	UsedAt  int64 `json:"used_at"`
+	Revoked bool  `json:"revoked"`
+}
+
+// Session is a row of the sessions table. A session starts when a user logs in on a device,
+// and its ID is the family ID of the refresh tokens and access tokens issued to that device.
+type Session struct {
+	ID         string `json:"id"`
+	UserID     string `json:"user_id"`
+	DeviceName string `json:"device_name"`
+	UserAgent  string `json:"user_agent"`
+	IP         string `json:"ip"`
+
+	CreationDate int64 `json:"creation_date"`
+	LastUsed     int64 `json:"last_used"`
+
+	// When the session ends unless its refresh token is used before.
+	Expiration int64 `json:"expiration"`
+}
+
+// TOTPSecret is a row of the totp_secrets table.
+type TOTPSecret struct {
+	UserID string `json:"user_id"`
+
+	// The base32-encoded key the user's authenticator app generates codes with.
+	Secret string `json:"secret"`
+
+	// Whether the user confirmed the secret with a code. Until then, logging in doesn't require a code.
+	Enabled bool `json:"enabled"`
+
+	// The time step of the last code that was used, so codes can't be used twice.
+	LastStep     int64 `json:"last_step"`
+	CreationDate int64 `json:"creation_date"`
+}
+
+// RecoveryCode is a row of the recovery_codes table. Only the SHA-256 hash of the code is stored.
+type RecoveryCode struct {
+	UserID   string `json:"user_id"`
+	CodeHash string `json:"code_hash"`
+}
+
+// WebAuthnCredential is a row of the webauthn_credentials table.
+type WebAuthnCredential struct {
+	// The base64url-encoded credential ID.
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+
+	// The credential as stored by the WebAuthn library, encoded as JSON.
+	// It includes the public key and signature counter.
+	Credential   string `json:"credential"`
+	CreationDate int64  `json:"creation_date"`
+	LastUsed     int64  `json:"last_used"`
+}
+
+// AccessToken is a row of the access_tokens table. Personal access tokens authenticate scripts and integrations
+// in place of the user's password. Only the SHA-256 hash of the token is stored.
+type AccessToken struct {
+	ID        string   `json:"id"`
+	UserID    string   `json:"user_id"`
+	Name      string   `json:"name"`
+	TokenHash string   `json:"token_hash"`
+	Scopes    []string `json:"scopes"`
+
+	CreationDate int64 `json:"creation_date"`
+
+	// When the token expires, or 0 if it never does.
+	Expiration int64 `json:"expiration"`
+	LastUsed   int64 `json:"last_used"`
+}
+
+// EmailToken is a row of the email_tokens table. Email tokens are mailed to users to verify their address,
+// reset their password or confirm a new address. Only the SHA-256 hash of the token is stored.
+type EmailToken struct {
+	ID        string `json:"id"`
+	UserID    string `json:"user_id"`
+	Purpose   string `json:"purpose"`
+	TokenHash string `json:"token_hash"`
+
+	// The address the token was sent to, which becomes the user's address when confirming a new one.
+	Email        string `json:"email"`
+	CreationDate int64  `json:"creation_date"`
+	Expiration   int64  `json:"expiration"`
+}
+
+// EmailVerification is a row of the email_verifications table.
+// The user's email address is verified if it's the one recorded here.
+type EmailVerification struct {
+	UserID           string `json:"user_id"`
+	Email            string `json:"email"`
+	VerificationDate int64  `json:"verification_date"`
+}
+
+// AuditEvent is a row of the audit_log table, which records security-relevant events such as account lockouts.
+type AuditEvent struct {
+	ID     string `json:"id"`
+	Action string `json:"action"`
+
+	// The user the event is about, if known, and the username it was about.
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	IP       string `json:"ip"`
+	Details  string `json:"details"`
+
+	CreationDate int64 `json:"creation_date"`
+}
+
+// Database is implemented by every database engine.
+//
+// Methods ending in Page return up to limit rows ordered by primary key, after skipping the first offset rows.
+// A negative limit returns every row, like the method without Page does.
 type Database interface {
 	EngineName() string
 	Satisfies(engine string) bool
+	// Returns a new, unconnected instance of the engine that connects using dsn
+	// instead of the config file. An empty dsn falls back to the config file.
+	WithDSN(dsn string) Database
 
 	Connect() error
 	// Closes the database connection.
 	// Subsequent calls will always return nil.
 	Close() error
 
+	// Runs fn in a transaction, which is committed if fn returns nil and rolled back otherwise.
+	// Methods called with the context passed to fn are part of the transaction,
+	// and so is a transaction started with it.
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+	// Returns the number of rows in one of the tables copied by Transfer.
+	CountRows(ctx context.Context, table string) (int, error)
+
+	// Returns the path of the engine's migrations inside the embedded migrations filesystem.
+	MigrationsDir() string
+	// Sets the schema version and clears the dirty flag without running any migrations.
+	ForceVersion(ctx context.Context, version uint64) error
+	// Returns the current schema version and whether the last migration failed.
+	// Returns ErrNotFound if no migrations have been applied.
+	MigrationVersion(ctx context.Context) (uint64, bool, error)
 	MigrateUp(steps int) error
 	MigrateDown(steps int) error
 
 	AllTracks(ctx context.Context) ([]media.Track, error)
+	AllTracksPage(ctx context.Context, limit, offset int) ([]media.Track, error)
 	Tracks(ctx context.Context, userID string) ([]media.Track, error)
 	Track(ctx context.Context, id string) (media.Track, error)
+	// Returns the track whose additional metadata has the MusicBrainz recording ID, ignoring case.
+	TrackByRecordingMBID(ctx context.Context, mbid string) (media.Track, error)
+	// Returns the track with the ISRC, ignoring case.
+	TrackByISRC(ctx context.Context, isrc string) (media.Track, error)
+	// Returns the tracks with the title, ignoring case.
+	TracksByTitle(ctx context.Context, title string) ([]media.Track, error)
 	AddTrack(ctx context.Context, track media.Track) error
 	UpdateTrack(ctx context.Context, track media.Track) error
 	DeleteTrack(ctx context.Context, id string) error
 
 	AllAlbums(ctx context.Context) ([]media.Album, error)
+	AllAlbumsPage(ctx context.Context, limit, offset int) ([]media.Album, error)
 	Albums(ctx context.Context, userID string) ([]media.Album, error)
 	Album(ctx context.Context, id string) (media.Album, error)
 	AddAlbum(ctx context.Context, album media.Album) error
@@ -55,6 +247,7 @@ type Database interface {
 	DeleteAlbum(ctx context.Context, id string) error
 
 	AllVideos(ctx context.Context) ([]media.Video, error)
+	AllVideosPage(ctx context.Context, limit, offset int) ([]media.Video, error)
 	Videos(ctx context.Context, userID string) ([]media.Video, error)
 	Video(ctx context.Context, id string) (media.Video, error)
 	AddVideo(ctx context.Context, video media.Video) error
@@ -62,6 +255,7 @@ type Database interface {
 	DeleteVideo(ctx context.Context, id string) error
 
 	AllArtists(ctx context.Context) ([]media.Artist, error)
+	AllArtistsPage(ctx context.Context, limit, offset int) ([]media.Artist, error)
 	Artists(ctx context.Context, userID string) ([]media.Artist, error)
 	Artist(ctx context.Context, id string) (media.Artist, error)
 	AddArtist(ctx context.Context, artist media.Artist) error
@@ -69,13 +263,21 @@ type Database interface {
 	DeleteArtist(ctx context.Context, id string) error
 
 	AllPlaylists(ctx context.Context) ([]media.Playlist, error)
+	AllPlaylistsPage(ctx context.Context, limit, offset int) ([]media.Playlist, error)
 	Playlists(ctx context.Context, userID string) ([]media.Playlist, error)
 	Playlist(ctx context.Context, id string) (media.Playlist, error)
 	AddPlaylist(ctx context.Context, playlist media.Playlist) error
 	UpdatePlaylist(ctx context.Context, playlist media.Playlist) error
+	// Stores the playlist only if its stored revision is still revision.
+	// Returns ErrRevisionMismatch if the playlist was changed since then or doesn't exist.
+	UpdatePlaylistAtRevision(ctx context.Context, playlist media.Playlist, revision int) error
+	// Returns the IDs of the tracks selected by a smart playlist's rules, in order.
+	// Fields like last_played use the owner's listens and favorites. At most maxTracks IDs are returned.
+	SmartPlaylistTrackIDs(ctx context.Context, ownerID string, query rules.Query, maxTracks int) ([]string, error)
 	DeletePlaylist(ctx context.Context, id string) error
 
 	Users(ctx context.Context) ([]media.DatabaseUser, error)
+	UsersPage(ctx context.Context, limit, offset int) ([]media.DatabaseUser, error)
 	User(ctx context.Context, id string) (media.DatabaseUser, error)
 	UserByUsername(ctx context.Context, username string) (media.DatabaseUser, error)
 	CreateUser(ctx context.Context, user media.DatabaseUser) error
@@ -88,10 +290,158 @@ type Database interface {
 	IsProviderLinked(ctx context.Context, provider, userID string) (bool, error)
 	LinkProviderAccount(ctx context.Context, provider, userID, providerUserID string) error
 	DisconnectProviderAccount(ctx context.Context, provider, userID string) error
+	ProviderLinks(ctx context.Context) ([]ProviderLink, error)
+	ProviderLinksPage(ctx context.Context, limit, offset int) ([]ProviderLink, error)
+
+	AllListens(ctx context.Context) ([]media.Listen, error)
+	AllListensPage(ctx context.Context, limit, offset int) ([]media.Listen, error)
+	// Returns the user's listens, most recent first.
+	Listens(ctx context.Context, userID string, limit, offset int) ([]media.Listen, error)
+	// Returns up to limit of the user's listens that happened strictly between minTS and maxTS,
+	// most recent first. A bound of 0 is ignored.
+	ListensBetween(ctx context.Context, userID string, minTS, maxTS int64, limit int) ([]media.Listen, error)
+	CountListens(ctx context.Context, userID string) (int, error)
+	Listen(ctx context.Context, id string) (media.Listen, error)
+	AddListen(ctx context.Context, listen media.Listen) error
+	// Adds delta to the listen count of a playable.
+	// For videos, the watch count is incremented instead.
+	IncrementListenCount(ctx context.Context, playableType, id string, delta int) error
+
+	AllFavorites(ctx context.Context) ([]media.Favorite, error)
+	AllFavoritesPage(ctx context.Context, limit, offset int) ([]media.Favorite, error)
+	// Returns the user's favorites, most recently favorited first.
+	// An empty playableType returns favorites of every type.
+	Favorites(ctx context.Context, userID, playableType string, limit, offset int) ([]media.Favorite, error)
+	CountFavorites(ctx context.Context, userID, playableType string) (int, error)
+	// Returns the IDs of every playable of the given type the user has favorited.
+	FavoriteIDs(ctx context.Context, userID, playableType string) ([]string, error)
+	IsFavorite(ctx context.Context, userID, playableType, id string) (bool, error)
+	// Adds a favorite and reports whether it was added. Adding an existing favorite does nothing.
+	AddFavorite(ctx context.Context, favorite media.Favorite) (bool, error)
+	// Removes a favorite and reports whether it existed.
+	RemoveFavorite(ctx context.Context, userID, playableType, id string) (bool, error)
+	// Removes every user's favorite of a playable, for when the playable is deleted.
+	RemovePlayableFavorites(ctx context.Context, playableType, id string) error
+	// Adds delta to the favorite count of a playable.
+	IncrementFavoriteCount(ctx context.Context, playableType, id string, delta int) error
+
+	// Returns the hash of the user's ListenBrainz token.
+	ListenBrainzToken(ctx context.Context, userID string) (string, error)
+	ListenBrainzTokenUserID(ctx context.Context, tokenHash string) (string, error)
+	// Sets the hash of the user's ListenBrainz token, replacing the previous one.
+	SetListenBrainzToken(ctx context.Context, userID, tokenHash string, creationDate int64) error
+	ListenBrainzTokens(ctx context.Context) ([]ListenBrainzToken, error)
+	ListenBrainzTokensPage(ctx context.Context, limit, offset int) ([]ListenBrainzToken, error)
+
+	AllScrobbles(ctx context.Context) ([]Scrobble, error)
+	AllScrobblesPage(ctx context.Context, limit, offset int) ([]Scrobble, error)
+	// Returns up to limit scrobbles that haven't failed and are due at or before now, oldest first.
+	DueScrobbles(ctx context.Context, now int64, limit int) ([]Scrobble, error)
+	FailedScrobbles(ctx context.Context, userID string) ([]Scrobble, error)
+	Scrobble(ctx context.Context, id string) (Scrobble, error)
+	AddScrobble(ctx context.Context, scrobble Scrobble) error
+	UpdateScrobble(ctx context.Context, scrobble Scrobble) error
+	DeleteScrobble(ctx context.Context, id string) error
+
+	ScrobblerLinks(ctx context.Context) ([]ScrobblerLink, error)
+	ScrobblerLinksPage(ctx context.Context, limit, offset int) ([]ScrobblerLink, error)
+	UserScrobblerLinks(ctx context.Context, userID string) ([]ScrobblerLink, error)
+	ScrobblerLink(ctx context.Context, userID, scrobbler string) (ScrobblerLink, error)
+	// Stores the link, replacing the user's previous token for the scrobbler.
+	SetScrobblerLink(ctx context.Context, link ScrobblerLink) error
+	DeleteScrobblerLink(ctx context.Context, userID, scrobbler string) error
+
+	AllPlayQueues(ctx context.Context) ([]media.PlayQueue, error)
+	AllPlayQueuesPage(ctx context.Context, limit, offset int) ([]media.PlayQueue, error)
+	PlayQueue(ctx context.Context, userID string) (media.PlayQueue, error)
+	// Stores the user's play queue, replacing the previous one.
+	SetPlayQueue(ctx context.Context, queue media.PlayQueue) error
+	// Stores the user's play queue if the stored one is at the given revision, or if there is none and revision is 0.
+	// Returns ErrRevisionMismatch otherwise.
+	SavePlayQueueAtRevision(ctx context.Context, queue media.PlayQueue, revision int) error
 
 	BlacklistToken(ctx context.Context, token string, expiration time.Time) error
+	// Deletes expired blacklisted tokens, refresh tokens and sessions.
 	CleanExpiredTokens(ctx context.Context) error
 	IsTokenBlacklisted(ctx context.Context, token string) (bool, error)
+	BlacklistedTokens(ctx context.Context) ([]BlacklistedToken, error)
+	BlacklistedTokensPage(ctx context.Context, limit, offset int) ([]BlacklistedToken, error)
+
+	RefreshTokens(ctx context.Context) ([]RefreshToken, error)
+	RefreshTokensPage(ctx context.Context, limit, offset int) ([]RefreshToken, error)
+	RefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error)
+	AddRefreshToken(ctx context.Context, token RefreshToken) error
+	// Marks a refresh token as used, unless it was already used or revoked.
+	// Returns false if it was, so each refresh token can only be used once.
+	UseRefreshToken(ctx context.Context, tokenHash string, usedAt int64) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+
+	Sessions(ctx context.Context) ([]Session, error)
+	SessionsPage(ctx context.Context, limit, offset int) ([]Session, error)
+	UserSessions(ctx context.Context, userID string) ([]Session, error)
+	Session(ctx context.Context, id string) (Session, error)
+	AddSession(ctx context.Context, session Session) error
+	// Updates everything but the session's user and creation date.
+	UpdateSession(ctx context.Context, session Session) error
+	DeleteSession(ctx context.Context, id string) error
+
+	TOTPSecrets(ctx context.Context) ([]TOTPSecret, error)
+	TOTPSecretsPage(ctx context.Context, limit, offset int) ([]TOTPSecret, error)
+	TOTPSecret(ctx context.Context, userID string) (TOTPSecret, error)
+	// Stores the user's TOTP secret, replacing the previous one.
+	SetTOTPSecret(ctx context.Context, secret TOTPSecret) error
+	// Records that a code for the given time step was used, unless a code for it or a later step already was.
+	// Returns false if one was, so each code can only be used once.
+	UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error)
+	DeleteTOTPSecret(ctx context.Context, userID string) error
+
+	RecoveryCodes(ctx context.Context) ([]RecoveryCode, error)
+	RecoveryCodesPage(ctx context.Context, limit, offset int) ([]RecoveryCode, error)
+	AddRecoveryCode(ctx context.Context, code RecoveryCode) error
+	// Deletes the user's recovery code. Returns false if the user has no such code.
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+	DeleteRecoveryCodes(ctx context.Context, userID string) error
+
+	WebAuthnCredentials(ctx context.Context) ([]WebAuthnCredential, error)
+	WebAuthnCredentialsPage(ctx context.Context, limit, offset int) ([]WebAuthnCredential, error)
+	UserWebAuthnCredentials(ctx context.Context, userID string) ([]WebAuthnCredential, error)
+	WebAuthnCredential(ctx context.Context, id string) (WebAuthnCredential, error)
+	AddWebAuthnCredential(ctx context.Context, credential WebAuthnCredential) error
+	// Updates the credential's name, stored credential and last use.
+	UpdateWebAuthnCredential(ctx context.Context, credential WebAuthnCredential) error
+	DeleteWebAuthnCredential(ctx context.Context, id string) error
+
+	AccessTokens(ctx context.Context) ([]AccessToken, error)
+	AccessTokensPage(ctx context.Context, limit, offset int) ([]AccessToken, error)
+	UserAccessTokens(ctx context.Context, userID string) ([]AccessToken, error)
+	AccessToken(ctx context.Context, id string) (AccessToken, error)
+	AccessTokenByHash(ctx context.Context, tokenHash string) (AccessToken, error)
+	AddAccessToken(ctx context.Context, token AccessToken) error
+	// Updates the token's name, scopes, expiration and last use.
+	UpdateAccessToken(ctx context.Context, token AccessToken) error
+	DeleteAccessToken(ctx context.Context, id string) error
+
+	EmailTokens(ctx context.Context) ([]EmailToken, error)
+	EmailTokensPage(ctx context.Context, limit, offset int) ([]EmailToken, error)
+	EmailTokenByHash(ctx context.Context, tokenHash string) (EmailToken, error)
+	AddEmailToken(ctx context.Context, token EmailToken) error
+	// Deletes the token. Returns false if it was already deleted, so each token can only be used once.
+	UseEmailToken(ctx context.Context, id string) (bool, error)
+	// Deletes the user's tokens for the purpose.
+	DeleteEmailTokens(ctx context.Context, userID, purpose string) error
+
+	EmailVerifications(ctx context.Context) ([]EmailVerification, error)
+	EmailVerificationsPage(ctx context.Context, limit, offset int) ([]EmailVerification, error)
+	EmailVerification(ctx context.Context, userID string) (EmailVerification, error)
+	// Records that the user verified the address, replacing the previous one.
+	SetEmailVerification(ctx context.Context, verification EmailVerification) error
+
+	AllAuditEvents(ctx context.Context) ([]AuditEvent, error)
+	AllAuditEventsPage(ctx context.Context, limit, offset int) ([]AuditEvent, error)
+	// Returns the most recent events first.
+	AuditEvents(ctx context.Context, limit, offset int) ([]AuditEvent, error)
+	AuditEvent(ctx context.Context, id string) (AuditEvent, error)
+	AddAuditEvent(ctx context.Context, event AuditEvent) error
 }
 
 func Connect() error {
@@ -108,8 +458,99 @@ func Connect() error {
 	return ErrUnsupportedEngine
 }
 
+// tables lists every table holding data, in the order Transfer copies them.
+var tables = []string{
+	"users",
+	"auth_providers",
+	"tracks",
+	"albums",
+	"videos",
+	"artists",
+	"playlists",
+	"listens",
+	"favorites",
+	"listenbrainz_tokens",
+	"scrobble_queue",
+	"scrobbler_links",
+	"play_queues",
+	"blacklisted_tokens",
+	"refresh_tokens",
+	"sessions",
+	"totp_secrets",
+	"recovery_codes",
+	"webauthn_credentials",
+	"access_tokens",
+	"email_tokens",
+	"email_verifications",
+	"audit_log",
+}
+
+// checkTable returns an error unless table is in tables, so its name is safe to put in a query.
+func checkTable(table string) error {
+	if !slices.Contains(tables, table) {
+		return fmt.Errorf("unknown table %q", table)
+	}
+	return nil
+}
+
+// favoriteCountTable returns the table holding the favorite count for a playable type.
+func favoriteCountTable(playableType string) (string, error) {
+	switch playableType {
+	case "track":
+		return "tracks", nil
+	case "album":
+		return "albums", nil
+	case "video":
+		return "videos", nil
+	case "artist":
+		return "artists", nil
+	case "playlist":
+		return "playlists", nil
+	}
+	return "", fmt.Errorf("unsupported playable type %q", playableType)
+}
+
+// listenCountColumn returns the table and column holding the listen count for a playable type.
+func listenCountColumn(playableType string) (string, string, error) {
+	switch playableType {
+	case "track":
+		return "tracks", "listen_count", nil
+	case "album":
+		return "albums", "listen_count", nil
+	case "video":
+		return "videos", "watch_count", nil
+	case "artist":
+		return "artists", "listen_count", nil
+	case "playlist":
+		return "playlists", "listen_count", nil
+	}
+	return "", "", fmt.Errorf("unsupported playable type %q", playableType)
+}
+
+// Open connects to a new instance of the given engine without touching DB.
+// An empty dsn uses the connection settings from the config file.
+func Open(engine, dsn string) (Database, error) {
+	db, err := Engine(engine)
+	if err != nil {
+		return nil, err
+	}
+	instance := db.WithDSN(dsn)
+	return instance, instance.Connect()
+}
+
+// Engine returns the registered engine with the given name or alias, such as "sqlite3" for SQLite.
+func Engine(name string) (Database, error) {
+	for _, db := range Registry {
+		if db.Satisfies(name) {
+			return db, nil
+		}
+	}
+	return nil, ErrUnsupportedEngine
+}
+
 // TODO: Add a way to filter the types of playables that are returned so we don't perform unnecessary database queries.
-func AllPlayables(ctx context.Context) ([]media.Playable, error) {
+// Playlists are only included if they're listed for viewerID, which is empty for unauthenticated requests.
+func AllPlayables(ctx context.Context, viewerID string) ([]media.Playable, error) {
 	var playables []media.Playable
 
 	tracks, err := DB.AllTracks(ctx)
@@ -149,14 +590,17 @@ func AllPlayables(ctx context.Context) ([]media.Playable, error) {
 		return nil, err
 	}
 	for _, playlist := range playlists {
-		playables = append(playables, playlist)
+		if playlist.IsListedFor(viewerID) {
+			playables = append(playables, playlist)
+		}
 	}
 
 	return playables, nil
 }
 
 // TODO: Add a way to filter the types of playables that are returned so we don't perform unnecessary database queries.
-func Playables(ctx context.Context, userID string) ([]media.Playable, error) {
+// Playlists are only included if they're listed for viewerID, which is empty for unauthenticated requests.
+func Playables(ctx context.Context, userID, viewerID string) ([]media.Playable, error) {
 	var playables []media.Playable
 
 	tracks, err := DB.Tracks(ctx, userID)
@@ -196,7 +640,9 @@ func Playables(ctx context.Context, userID string) ([]media.Playable, error) {
 		return nil, err
 	}
 	for _, playlist := range playlists {
-		playables = append(playables, playlist)
+		if playlist.IsListedFor(viewerID) {
+			playables = append(playables, playlist)
+		}
 	}
 
 	return playables, nil
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/libramusic/libracore/rules"
+)
+
+func TestDialectSQL(t *testing.T) {
+	query, err := rules.Parse(`NOT tag = "jazz" AND title ~ "blue" AND release_date <= 1990 ` +
+		"ORDER BY listen_count DESC, random LIMIT 500")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const (
+		listenCount = "COALESCE(tracks.listen_count, 0)"
+		title       = "COALESCE(tracks.title, '')"
+		releaseDate = "NULLIF(tracks.release_date, '')"
+	)
+
+	tests := []struct {
+		dialect rules.Dialect
+		want    string
+	}{
+		{
+			sqliteDialect{},
+			"SELECT tracks.id FROM tracks WHERE (((NOT EXISTS (SELECT 1 FROM json_each(COALESCE(tracks.tags, '[]')) " +
+				"WHERE json_each.value = ?)) AND instr(lower(" + title + "), lower(?)) > 0) AND (" + releaseDate +
+				" < ?)) ORDER BY " + listenCount + " DESC, random(), tracks.id LIMIT 100",
+		},
+		{
+			postgreSQLDialect{},
+			"SELECT tracks.id FROM tracks WHERE (((NOT $1::text = ANY(COALESCE(tracks.tags, '{}'))) AND " +
+				"strpos(lower(" + title + "), lower($2::text)) > 0) AND (" + releaseDate + " < $3)) ORDER BY " +
+				listenCount + " DESC, random(), tracks.id LIMIT 100",
+		},
+		{
+			mySQLDialect{},
+			"SELECT tracks.id FROM tracks WHERE (((NOT JSON_CONTAINS(COALESCE(tracks.tags, JSON_ARRAY()), " +
+				"JSON_QUOTE(?))) AND INSTR(LOWER(" + title + "), LOWER(?)) > 0) AND (" + releaseDate + " < ?)) " +
+				"ORDER BY " + listenCount + " DESC, RAND(), tracks.id LIMIT 100",
+		},
+	}
+	for _, test := range tests {
+		statement, args := query.SQL(test.dialect, "user0000001", 100, time.Now())
+		if statement != test.want {
+			t.Errorf("%T:\ngot  %s\nwant %s", test.dialect, statement, test.want)
+		}
+		if want := []any{"jazz", "blue", "1991"}; !reflect.DeepEqual(args, want) {
+			t.Errorf("%T: got arguments %v, want %v", test.dialect, args, want)
+		}
+	}
+}
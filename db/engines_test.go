@@ -0,0 +1,598 @@
+//go:build integration
+
+package db_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/rules"
+)
+
+// testEngines returns a connected, fully migrated database for each engine that can be tested.
+// SQLite always is, and PostgreSQL and MySQL are when LIBRA_TEST_POSTGRESQL_DSN and LIBRA_TEST_MYSQL_DSN are set.
+// Those databases are migrated all the way down first, so they must be ones the tests can wipe.
+func testEngines(t *testing.T) map[string]db.Database {
+	t.Helper()
+
+	dsns := map[string]string{
+		"sqlite":     filepath.Join(t.TempDir(), "libra.db"),
+		"postgresql": os.Getenv("LIBRA_TEST_POSTGRESQL_DSN"),
+		"mysql":      os.Getenv("LIBRA_TEST_MYSQL_DSN"),
+	}
+
+	engines := map[string]db.Database{}
+	for name, dsn := range dsns {
+		engine, ok := db.Registry[name]
+		if !ok || dsn == "" {
+			continue
+		}
+		database := engine.WithDSN(dsn)
+		if err := database.Connect(); err != nil {
+			t.Fatalf("%s: connecting: %v", name, err)
+		}
+		t.Cleanup(func() { database.Close() })
+
+		// Start from an empty schema, in case the database was used before.
+		if err := database.MigrateDown(-1); err != nil {
+			t.Fatalf("%s: migrating down: %v", name, err)
+		}
+		if err := database.MigrateUp(-1); err != nil {
+			t.Fatalf("%s: migrating up: %v", name, err)
+		}
+		engines[name] = database
+	}
+	return engines
+}
+
+func latestMigration(t *testing.T, database db.Database) uint64 {
+	t.Helper()
+
+	migrations, err := db.Migrations(database, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+func assertVersion(t *testing.T, database db.Database, want uint64) {
+	t.Helper()
+
+	version, dirty, err := db.CurrentMigrationVersion(context.Background(), database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != want || dirty {
+		t.Errorf("at version %d (dirty %t), want %d", version, dirty, want)
+	}
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	for name, database := range testEngines(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			latest := latestMigration(t, database)
+			assertVersion(t, database, latest)
+
+			if err := database.MigrateDown(1); err != nil {
+				t.Fatal(err)
+			}
+			assertVersion(t, database, latest-1)
+
+			if err := database.MigrateDown(-1); err != nil {
+				t.Fatal(err)
+			}
+			assertVersion(t, database, 0)
+			if _, err := database.Users(ctx); err == nil {
+				t.Error("users table still exists after migrating all the way down")
+			}
+
+			if err := database.MigrateUp(2); err != nil {
+				t.Fatal(err)
+			}
+			assertVersion(t, database, 2)
+			if err := db.MigrateTo(ctx, database, latest); err != nil {
+				t.Fatal(err)
+			}
+			assertVersion(t, database, latest)
+
+			if err := db.ForceMigrationVersion(ctx, database, latest-1); err != nil {
+				t.Fatal(err)
+			}
+			assertVersion(t, database, latest-1)
+			if err := db.ForceMigrationVersion(ctx, database, latest); err != nil {
+				t.Fatal(err)
+			}
+			assertVersion(t, database, latest)
+		})
+	}
+}
+
+func TestUserRoundTrip(t *testing.T) {
+	for name, database := range testEngines(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			user := media.DatabaseUser{
+				User: media.User{
+					ID:              "user0000001",
+					Username:        "alice",
+					Email:           "alice@example.com",
+					DisplayName:     "Alice",
+					Description:     "Hi",
+					ListenedTo:      map[string]int{"track0000001": 3},
+					Favorites:       []string{"track0000001"},
+					PublicViewCount: 7,
+					CreationDate:    1700000000,
+					Permissions:     map[string]string{"role": "owner"},
+					LinkedArtistID:  "artist00001",
+					LinkedSources:   map[string]string{"spotify": "alice"},
+				},
+				PasswordHash: "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$a2V5",
+			}
+			if err := database.CreateUser(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := database.User(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, user) {
+				t.Errorf("got %+v, want %+v", got, user)
+			}
+			for _, login := range []string{"alice", "alice@example.com"} {
+				if got, err := database.UserByUsername(ctx, login); err != nil || got.ID != user.ID {
+					t.Errorf("UserByUsername(%q) = %q, %v", login, got.ID, err)
+				}
+			}
+
+			user.DisplayName = "Alice A."
+			user.Favorites = nil
+			if err := database.UpdateUser(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := database.User(ctx, user.ID); err != nil || got.DisplayName != "Alice A." ||
+				len(got.Favorites) != 0 {
+				t.Errorf("after updating, got %+v, %v", got, err)
+			}
+
+			if err := database.DeleteUser(ctx, user.ID); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := database.User(ctx, user.ID); !errors.Is(err, db.ErrNotFound) {
+				t.Errorf("after deleting, got %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestTrackRoundTrip(t *testing.T) {
+	for name, database := range testEngines(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			track := media.Track{
+				ID:             "track0000001",
+				UserID:         "user0000001",
+				ISRC:           "USSKG1912345",
+				Title:          "Lorem",
+				ArtistIDs:      []string{"artist00001", "artist00002"},
+				AlbumIDs:       []string{"album000001"},
+				PrimaryAlbumID: "album000001",
+				TrackNumber:    1,
+				Duration:       300,
+				Description:    "Lorem ipsum",
+				ReleaseDate:    "2023-10-01",
+				Lyrics:         map[string]string{"en": "la la la"},
+				ListenCount:    150,
+				FavoriteCount:  5,
+				AdditionDate:   1634296980,
+				Tags:           []string{"rock"},
+				AdditionalMeta: map[string]any{"bpm": "120"},
+				Permissions:    map[string]string{"user0000002": "editor"},
+				LinkedItemIDs:  []string{"video000001"},
+				ContentSource:  "local",
+				MetadataSource: "local",
+				LyricSources:   map[string]string{"en": "lrclib"},
+			}
+			if err := database.AddTrack(ctx, track); err != nil {
+				t.Fatal(err)
+			}
+			got, err := database.Track(ctx, track.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, track) {
+				t.Errorf("got %+v, want %+v", got, track)
+			}
+
+			if err := database.IncrementListenCount(ctx, "track", track.ID, 2); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := database.Track(ctx, track.ID); err != nil || got.ListenCount != 152 {
+				t.Errorf("listen count is %d, %v, want 152", got.ListenCount, err)
+			}
+
+			if err := database.DeleteTrack(ctx, track.ID); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := database.Track(ctx, track.ID); !errors.Is(err, db.ErrNotFound) {
+				t.Errorf("after deleting, got %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestTrackLookups(t *testing.T) {
+	for name, database := range testEngines(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			tracks := []media.Track{
+				{
+					ID:             "track000001",
+					ISRC:           "USSKG1912345",
+					Title:          "Lorem",
+					AdditionalMeta: map[string]any{"musicbrainz_recording_id": "8f3471b5-7e6a-48da-86a9-c1c07a0f47ae"},
+				},
+				{ID: "track000002", Title: "lorem"},
+				{ID: "track000003", Title: "Ipsum"},
+			}
+			for _, track := range tracks {
+				if err := database.AddTrack(ctx, track); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := database.TrackByRecordingMBID(ctx, "8F3471B5-7E6A-48DA-86A9-C1C07A0F47AE")
+			if err != nil || got.ID != tracks[0].ID {
+				t.Errorf("TrackByRecordingMBID = %q, %v", got.ID, err)
+			}
+			if got, err := database.TrackByISRC(ctx, "uskg1912345"); !errors.Is(err, db.ErrNotFound) {
+				t.Errorf("TrackByISRC of an unknown ISRC = %q, %v, want ErrNotFound", got.ID, err)
+			}
+			if got, err := database.TrackByISRC(ctx, "usskg1912345"); err != nil || got.ID != tracks[0].ID {
+				t.Errorf("TrackByISRC = %q, %v", got.ID, err)
+			}
+
+			byTitle, err := database.TracksByTitle(ctx, "LOREM")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, track := range byTitle {
+				ids = append(ids, track.ID)
+			}
+			if !slices.Equal(ids, []string{"track000001", "track000002"}) {
+				t.Errorf("TracksByTitle = %v", ids)
+			}
+		})
+	}
+}
+
+func TestListensAreOrderedAndPaged(t *testing.T) {
+	for name, database := range testEngines(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for i, id := range []string{"listen00001", "listen00002", "listen00003"} {
+				listen := media.Listen{
+					ID:           id,
+					UserID:       "user0000001",
+					PlayableID:   "track0000001",
+					PlayableType: "track",
+					ListenedAt:   1700000000 + int64(i),
+				}
+				if err := database.AddListen(ctx, listen); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			listens, err := database.Listens(ctx, "user0000001", 2, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(listens) != 2 || listens[0].ID != "listen00002" || listens[1].ID != "listen00001" {
+				t.Errorf("got %+v, want listen00002 and listen00001", listens)
+			}
+			if count, err := database.CountListens(ctx, "user0000001"); err != nil || count != 3 {
+				t.Errorf("counted %d listens, %v, want 3", count, err)
+			}
+		})
+	}
+}
+
+func TestEmailTokensCanOnlyBeUsedOnce(t *testing.T) {
+	for name, database := range testEngines(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			token := db.EmailToken{
+				ID:           "token000001",
+				UserID:       "user0000001",
+				Purpose:      "password_reset",
+				TokenHash:    "0123456789abcdef",
+				Email:        "alice@example.com",
+				CreationDate: time.Now().Unix(),
+				Expiration:   time.Now().Add(time.Hour).Unix(),
+			}
+			if err := database.AddEmailToken(ctx, token); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := database.EmailTokenByHash(ctx, token.TokenHash); err != nil || got != token {
+				t.Errorf("got %+v, %v, want %+v", got, err, token)
+			}
+
+			used, err := database.UseEmailToken(ctx, token.ID)
+			if err != nil || !used {
+				t.Fatalf("first use: %t, %v", used, err)
+			}
+			if used, err := database.UseEmailToken(ctx, token.ID); err != nil || used {
+				t.Errorf("second use: %t, %v, want false", used, err)
+			}
+		})
+	}
+}
+
+func TestScrobblerLinksAreReplaced(t *testing.T) {
+	for name, database := range testEngines(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			link := db.ScrobblerLink{UserID: "user0000001", Scrobbler: "lastfm", Token: "old", CreationDate: 1}
+			if err := database.SetScrobblerLink(ctx, link); err != nil {
+				t.Fatal(err)
+			}
+			link.Token = "new"
+			if err := database.SetScrobblerLink(ctx, link); err != nil {
+				t.Fatal(err)
+			}
+			other := db.ScrobblerLink{UserID: "user0000001", Scrobbler: "listenbrainz", Token: "lb", CreationDate: 2}
+			if err := database.SetScrobblerLink(ctx, other); err != nil {
+				t.Fatal(err)
+			}
+
+			links, err := database.UserScrobblerLinks(ctx, link.UserID)
+			if err != nil || !reflect.DeepEqual(links, []db.ScrobblerLink{link, other}) {
+				t.Errorf("got %+v, %v, want %+v", links, err, []db.ScrobblerLink{link, other})
+			}
+
+			if err := database.DeleteScrobblerLink(ctx, link.UserID, link.Scrobbler); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := database.ScrobblerLink(ctx, link.UserID, link.Scrobbler); !errors.Is(err, db.ErrNotFound) {
+				t.Errorf("after deleting, got %v, want ErrNotFound", err)
+			}
+			if got, err := database.ScrobblerLink(ctx, other.UserID, other.Scrobbler); err != nil || got != other {
+				t.Errorf("got %+v, %v, want %+v", got, err, other)
+			}
+		})
+	}
+}
+
+func TestPlayQueuesAreSavedAtRevisions(t *testing.T) {
+	for name, database := range testEngines(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			queue := media.PlayQueue{
+				UserID:  "user0000001",
+				Items:   []media.QueueItem{{PlayableType: "track", PlayableID: "track000001"}},
+				Repeat:  media.RepeatOff,
+				Context: media.QueueContext{Type: "album", ID: "album000001"},
+				Device:  "Phone",
+			}
+
+			// A queue saved at revision 0 must be the first one.
+			queue.Revision = 1
+			if err := database.SavePlayQueueAtRevision(ctx, queue, 0); err != nil {
+				t.Fatal(err)
+			}
+			other := queue
+			other.Device = "Laptop"
+			if err := database.SavePlayQueueAtRevision(ctx, other, 0); !errors.Is(err, db.ErrRevisionMismatch) {
+				t.Errorf("saving another first queue: got %v, want ErrRevisionMismatch", err)
+			}
+
+			queue.Revision = 2
+			queue.PositionMS = 73500
+			if err := database.SavePlayQueueAtRevision(ctx, queue, 1); err != nil {
+				t.Fatal(err)
+			}
+			other.Revision = 3
+			if err := database.SavePlayQueueAtRevision(ctx, other, 1); !errors.Is(err, db.ErrRevisionMismatch) {
+				t.Errorf("saving at a stale revision: got %v, want ErrRevisionMismatch", err)
+			}
+
+			if got, err := database.PlayQueue(ctx, queue.UserID); err != nil || !reflect.DeepEqual(got, queue) {
+				t.Errorf("got %+v, %v, want %+v", got, err, queue)
+			}
+		})
+	}
+}
+
+func TestAuditEventsAreNewestFirst(t *testing.T) {
+	for name, database := range testEngines(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			events := []db.AuditEvent{
+				{ID: "event000001", Action: "account_locked", Username: "alice", IP: "192.0.2.1", CreationDate: 1},
+				{ID: "event000002", Action: "ip_locked", Username: "bob", IP: "192.0.2.2", CreationDate: 2},
+			}
+			for _, event := range events {
+				if err := database.AddAuditEvent(ctx, event); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := database.AuditEvents(ctx, 10, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, []db.AuditEvent{events[1], events[0]}) {
+				t.Errorf("got %+v", got)
+			}
+			if got, err := database.AuditEvent(ctx, "event000001"); err != nil || got != events[0] {
+				t.Errorf("got %+v, %v, want %+v", got, err, events[0])
+			}
+		})
+	}
+}
+
+func TestSmartPlaylistRules(t *testing.T) {
+	for name, database := range testEngines(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			tracks := []media.Track{
+				{ID: "track0000001", Title: "A", Tags: []string{"jazz"}, ReleaseDate: "1990-12-31", AdditionDate: 1},
+				{ID: "track0000002", Title: "B", ReleaseDate: "1991-01-01", AdditionDate: 2},
+				{ID: "track0000003", Title: "C", Tags: []string{"rock"}, AdditionDate: 3},
+			}
+			for _, track := range tracks {
+				if err := database.AddTrack(ctx, track); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tests := []struct {
+				rules string
+				want  []string
+			}{
+				// Tracks without tags don't have any, so they're selected by negated tag conditions.
+				{`NOT tag = "jazz"`, []string{"track0000002", "track0000003"}},
+				{`tag != "jazz"`, []string{"track0000002", "track0000003"}},
+				{`tag = "jazz" OR tag = "rock"`, []string{"track0000001", "track0000003"}},
+				// Years cover every day of them, and tracks without a release date never match.
+				{"release_date <= 1990", []string{"track0000001"}},
+				{"release_date > 1990", []string{"track0000002"}},
+				{"release_date != 1990", []string{"track0000002"}},
+				{`release_date = "1990-12"`, []string{"track0000001"}},
+				{"release_date <= 9999", []string{"track0000001", "track0000002"}},
+				{`title ~ "b" OR title = "C" ORDER BY title DESC LIMIT 1`, []string{"track0000003"}},
+			}
+			for _, test := range tests {
+				query, err := rules.Parse(test.rules)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids, err := database.SmartPlaylistTrackIDs(ctx, "user0000001", query, 0)
+				if err != nil {
+					t.Fatalf("%q: %v", test.rules, err)
+				}
+				if !slices.Equal(ids, test.want) {
+					t.Errorf("%q: got %v, want %v", test.rules, ids, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTransactionsCommitOrRollBack(t *testing.T) {
+	for name, database := range testEngines(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			errRollback := errors.New("roll back")
+			err := database.Transaction(ctx, func(ctx context.Context) error {
+				if err := database.AddTrack(ctx, media.Track{ID: "track0000001"}); err != nil {
+					return err
+				}
+				// A transaction started inside another one is part of it.
+				return database.Transaction(ctx, func(ctx context.Context) error {
+					if err := database.AddTrack(ctx, media.Track{ID: "track0000002"}); err != nil {
+						return err
+					}
+					return errRollback
+				})
+			})
+			if !errors.Is(err, errRollback) {
+				t.Fatalf("got %v, want the error of the transaction", err)
+			}
+			if count, err := database.CountRows(ctx, "tracks"); err != nil || count != 0 {
+				t.Fatalf("%d tracks, %v after rolling back", count, err)
+			}
+
+			err = database.Transaction(ctx, func(ctx context.Context) error {
+				if err := database.AddTrack(ctx, media.Track{ID: "track0000001"}); err != nil {
+					return err
+				}
+				return database.AddListen(ctx, media.Listen{ID: "listen00001", PlayableID: "track0000001"})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, table := range []string{"tracks", "listens"} {
+				if count, err := database.CountRows(ctx, table); err != nil || count != 1 {
+					t.Errorf("%d rows in %s, %v after committing", count, table, err)
+				}
+			}
+
+			if _, err := database.CountRows(ctx, "tracks; DROP TABLE users"); err == nil {
+				t.Error("counted the rows of an unknown table")
+			}
+		})
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	for name, target := range testEngines(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			source := db.Registry["sqlite"].WithDSN(filepath.Join(t.TempDir(), "source.db"))
+			if err := source.Connect(); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { source.Close() })
+
+			var ids []string
+			for i := range 5 {
+				track := media.Track{ID: fmt.Sprintf("track%07d", i+1), Title: "Track", AdditionDate: int64(i)}
+				if err := source.AddTrack(ctx, track); err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, track.ID)
+			}
+			// A row that's already in the target is skipped.
+			if err := target.AddTrack(ctx, media.Track{ID: ids[2], Title: "Track"}); err != nil {
+				t.Fatal(err)
+			}
+
+			var batches int
+			opts := db.TransferOptions{BatchSize: 2, Progress: func(progress db.TransferProgress) {
+				if progress.Table == "tracks" {
+					batches++
+				}
+			}}
+			results, err := db.Transfer(ctx, source, target, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tracks := results[slices.IndexFunc(results, func(r db.TransferResult) bool { return r.Table == "tracks" })]
+			want := db.TransferResult{Table: "tracks", Source: 5, Target: 5, Copied: 4, Skipped: 1}
+			if tracks != want || batches != 3 {
+				t.Errorf("got %+v in %d batches, want %+v in 3", tracks, batches, want)
+			}
+
+			page, err := target.AllTracksPage(ctx, 2, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page) != 2 || page[0].ID != ids[3] || page[1].ID != ids[4] {
+				t.Errorf("got page %+v, want %v", page, ids[3:])
+			}
+
+			// Transferring again copies nothing.
+			results, err = db.Transfer(ctx, source, target, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, result := range results {
+				if result.Copied != 0 || result.Source != result.Target {
+					t.Errorf("transferring again: %+v", result)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var ErrUnknownMigration = errors.New("unknown migration version")
+
+type Migration struct {
+	Version uint64
+	// The migration file name, e.g. 000001_initialize.up.sql.
+	File string
+	Up   bool
+}
+
+// Name returns the descriptive part of the migration file name.
+func (m Migration) Name() string {
+	_, name, _ := strings.Cut(m.File, "_")
+	name = strings.TrimSuffix(name, ".sql")
+	name = strings.TrimSuffix(name, ".up")
+	return strings.TrimSuffix(name, ".down")
+}
+
+// Migrations returns the embedded migrations for the engine in the order they are applied.
+func Migrations(database Database, up bool) ([]Migration, error) {
+	entries, err := migrationsFS.ReadDir(database.MigrationsDir())
+	if err != nil {
+		return nil, err
+	}
+
+	files := OrderedMigrationFiles(entries, up)
+	migrations := make([]Migration, 0, len(files))
+	for _, file := range files {
+		version, err := strconv.ParseUint(strings.Split(file, "_")[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file %q: %w", file, err)
+		}
+		migrations = append(migrations, Migration{Version: version, File: file, Up: up})
+	}
+	return migrations, nil
+}
+
+// MigrationSQL returns the SQL that the migration runs.
+func MigrationSQL(database Database, migration Migration) (string, error) {
+	content, err := migrationsFS.ReadFile(path.Join(database.MigrationsDir(), migration.File))
+	return string(content), err
+}
+
+// CurrentMigrationVersion returns the schema version of the database.
+// A database without any applied migrations is at version 0.
+func CurrentMigrationVersion(ctx context.Context, database Database) (uint64, bool, error) {
+	version, dirty, err := database.MigrationVersion(ctx)
+	if errors.Is(err, ErrNotFound) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// PendingMigrations returns the up migrations that have not been applied yet.
+func PendingMigrations(ctx context.Context, database Database) ([]Migration, error) {
+	return PlanMigrations(ctx, database, -1, true)
+}
+
+// PlanMigrations returns the migrations that MigrateUp or MigrateDown would run for the given steps.
+// A negative number of steps means all migrations.
+func PlanMigrations(ctx context.Context, database Database, steps int, up bool) ([]Migration, error) {
+	current, _, err := CurrentMigrationVersion(ctx, database)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := Migrations(database, up)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []Migration
+	for _, migration := range migrations {
+		if up && migration.Version <= current {
+			continue
+		}
+		if !up && migration.Version > current {
+			continue
+		}
+		if steps >= 0 && len(plan) >= steps {
+			break
+		}
+		plan = append(plan, migration)
+	}
+	return plan, nil
+}
+
+// PlanMigrateTo returns the migrations that MigrateTo would run to reach version.
+func PlanMigrateTo(ctx context.Context, database Database, version uint64) ([]Migration, error) {
+	current, _, err := CurrentMigrationVersion(ctx, database)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateMigrationVersion(database, version); err != nil {
+		return nil, err
+	}
+
+	up := version > current
+	migrations, err := PlanMigrations(ctx, database, -1, up)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []Migration
+	for _, migration := range migrations {
+		if up && migration.Version > version {
+			break
+		}
+		if !up && migration.Version <= version {
+			break
+		}
+		plan = append(plan, migration)
+	}
+	return plan, nil
+}
+
+// MigrateTo migrates the database up or down until it reaches version.
+func MigrateTo(ctx context.Context, database Database, version uint64) error {
+	current, _, err := CurrentMigrationVersion(ctx, database)
+	if err != nil {
+		return err
+	}
+	plan, err := PlanMigrateTo(ctx, database, version)
+	if err != nil {
+		return err
+	}
+
+	if len(plan) == 0 {
+		return nil
+	}
+	if version > current {
+		return database.MigrateUp(len(plan))
+	}
+	return database.MigrateDown(len(plan))
+}
+
+// ForceMigrationVersion sets the schema version without running migrations
+// so that a database left dirty by a failed migration can be recovered.
+func ForceMigrationVersion(ctx context.Context, database Database, version uint64) error {
+	if err := validateMigrationVersion(database, version); err != nil {
+		return err
+	}
+	return database.ForceVersion(ctx, version)
+}
+
+func validateMigrationVersion(database Database, version uint64) error {
+	if version == 0 {
+		return nil
+	}
+
+	migrations, err := Migrations(database, true)
+	if err != nil {
+		return err
+	}
+	for _, migration := range migrations {
+		if migration.Version == version {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %d", ErrUnknownMigration, version)
+}
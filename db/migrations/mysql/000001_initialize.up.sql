@@ -0,0 +1,127 @@
+CREATE TABLE IF NOT EXISTS tracks (
+  id VARCHAR(255) PRIMARY KEY,
+  user_id VARCHAR(255),
+  isrc TEXT,
+  title TEXT,
+  artist_ids JSON,
+  album_ids JSON,
+  primary_album_id TEXT,
+  track_number INT,
+  duration INT,
+  description TEXT,
+  release_date TEXT,
+  lyrics JSON,
+  listen_count INT,
+  favorite_count INT,
+  addition_date BIGINT,
+  tags JSON,
+  additional_meta JSON,
+  permissions JSON,
+  linked_item_ids JSON,
+  content_source TEXT,
+  metadata_source TEXT,
+  lyric_sources JSON
+);
+
+CREATE TABLE IF NOT EXISTS albums (
+  id VARCHAR(255) PRIMARY KEY,
+  user_id VARCHAR(255),
+  upc TEXT,
+  ean TEXT,
+  title TEXT,
+  artist_ids JSON,
+  track_ids JSON,
+  description TEXT,
+  release_date TEXT,
+  listen_count INT,
+  favorite_count INT,
+  addition_date BIGINT,
+  tags JSON,
+  additional_meta JSON,
+  permissions JSON,
+  linked_item_ids JSON,
+  metadata_source TEXT
+);
+
+CREATE TABLE IF NOT EXISTS videos (
+  id VARCHAR(255) PRIMARY KEY,
+  user_id VARCHAR(255),
+  title TEXT,
+  artist_ids JSON,
+  duration INT,
+  description TEXT,
+  release_date TEXT,
+  subtitles JSON,
+  watch_count INT,
+  favorite_count INT,
+  addition_date BIGINT,
+  tags JSON,
+  additional_meta JSON,
+  permissions JSON,
+  linked_item_ids JSON,
+  content_source TEXT,
+  metadata_source TEXT,
+  lyric_sources JSON
+);
+
+CREATE TABLE IF NOT EXISTS artists (
+  id VARCHAR(255) PRIMARY KEY,
+  user_id VARCHAR(255),
+  name TEXT,
+  album_ids JSON,
+  track_ids JSON,
+  description TEXT,
+  creation_date TEXT,
+  listen_count INT,
+  favorite_count INT,
+  addition_date BIGINT,
+  tags JSON,
+  additional_meta JSON,
+  permissions JSON,
+  linked_item_ids JSON,
+  metadata_source TEXT
+);
+
+CREATE TABLE IF NOT EXISTS playlists (
+  id VARCHAR(255) PRIMARY KEY,
+  user_id VARCHAR(255),
+  title TEXT,
+  track_ids JSON,
+  listen_count INT,
+  favorite_count INT,
+  description TEXT,
+  creation_date TEXT,
+  addition_date BIGINT,
+  tags JSON,
+  additional_meta JSON,
+  permissions JSON,
+  metadata_source TEXT
+);
+
+CREATE TABLE IF NOT EXISTS users (
+  id VARCHAR(255) PRIMARY KEY,
+  username VARCHAR(255) NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  password_hash TEXT NOT NULL,
+  display_name TEXT,
+  description TEXT,
+  listened_to JSON,
+  favorites JSON,
+  public_view_count INT,
+  creation_date BIGINT,
+  permissions JSON,
+  linked_artist_id TEXT,
+  linked_sources JSON
+);
+
+CREATE TABLE IF NOT EXISTS auth_providers (
+  user_id VARCHAR(255),
+  provider VARCHAR(255),
+  provider_user_id VARCHAR(255),
+  UNIQUE (user_id, provider)
+);
+
+CREATE TABLE IF NOT EXISTS blacklisted_tokens (
+  token VARCHAR(2048) CHARACTER SET ascii PRIMARY KEY,
+  expiration DATETIME
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS listens (
+  id VARCHAR(255) PRIMARY KEY,
+  user_id VARCHAR(255) NOT NULL,
+  playable_id VARCHAR(255) NOT NULL,
+  playable_type VARCHAR(32) NOT NULL,
+  listened_at BIGINT NOT NULL,
+  duration_played INT,
+  client TEXT,
+  source TEXT,
+  INDEX listens_user_id_listened_at (user_id, listened_at)
+);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS listenbrainz_tokens;
+
+ALTER TABLE listens
+  DROP COLUMN isrc,
+  DROP COLUMN recording_mbid,
+  DROP COLUMN release_name,
+  DROP COLUMN artist_name,
+  DROP COLUMN track_name;
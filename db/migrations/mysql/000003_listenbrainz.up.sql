@@ -0,0 +1,12 @@
+ALTER TABLE listens
+  ADD COLUMN track_name VARCHAR(1024) NOT NULL DEFAULT '',
+  ADD COLUMN artist_name VARCHAR(1024) NOT NULL DEFAULT '',
+  ADD COLUMN release_name VARCHAR(1024) NOT NULL DEFAULT '',
+  ADD COLUMN recording_mbid VARCHAR(36) NOT NULL DEFAULT '',
+  ADD COLUMN isrc VARCHAR(12) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS listenbrainz_tokens (
+  user_id VARCHAR(255) PRIMARY KEY,
+  token VARCHAR(255) NOT NULL UNIQUE,
+  creation_date BIGINT NOT NULL
+);
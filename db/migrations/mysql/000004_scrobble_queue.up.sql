@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS scrobble_queue (
+  id VARCHAR(255) PRIMARY KEY,
+  user_id VARCHAR(255) NOT NULL,
+  listen_id VARCHAR(255) NOT NULL,
+  scrobbler VARCHAR(255) NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at BIGINT NOT NULL,
+  last_error TEXT NOT NULL,
+  failed BOOLEAN NOT NULL DEFAULT FALSE,
+  creation_date BIGINT NOT NULL,
+  INDEX scrobble_queue_failed_next_attempt_at (failed, next_attempt_at)
+);
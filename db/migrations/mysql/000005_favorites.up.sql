@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS favorites (
+  user_id VARCHAR(255) NOT NULL,
+  playable_type VARCHAR(32) NOT NULL,
+  playable_id VARCHAR(255) NOT NULL,
+  favorited_at BIGINT NOT NULL,
+  PRIMARY KEY (user_id, playable_type, playable_id),
+  INDEX favorites_user_id_favorited_at (user_id, favorited_at)
+);
+
+-- Move the favorites stored on users into the new table.
+INSERT IGNORE INTO favorites (user_id, playable_type, playable_id, favorited_at)
+SELECT u.id, p.playable_type, p.id, 0
+FROM users u
+JOIN JSON_TABLE(COALESCE(u.favorites, JSON_ARRAY()), '$[*]' COLUMNS (id VARCHAR(255) PATH '$')) f
+JOIN (
+  SELECT 'track' AS playable_type, id FROM tracks
+  UNION ALL SELECT 'album', id FROM albums
+  UNION ALL SELECT 'video', id FROM videos
+  UNION ALL SELECT 'artist', id FROM artists
+  UNION ALL SELECT 'playlist', id FROM playlists
+) p ON CAST(p.id AS BINARY) = CAST(f.id AS BINARY);
+
+UPDATE tracks SET favorite_count = (
+  SELECT COUNT(*) FROM favorites WHERE playable_type = 'track' AND playable_id = tracks.id
+);
+UPDATE albums SET favorite_count = (
+  SELECT COUNT(*) FROM favorites WHERE playable_type = 'album' AND playable_id = albums.id
+);
+UPDATE videos SET favorite_count = (
+  SELECT COUNT(*) FROM favorites WHERE playable_type = 'video' AND playable_id = videos.id
+);
+UPDATE artists SET favorite_count = (
+  SELECT COUNT(*) FROM favorites WHERE playable_type = 'artist' AND playable_id = artists.id
+);
+UPDATE playlists SET favorite_count = (
+  SELECT COUNT(*) FROM favorites WHERE playable_type = 'playlist' AND playable_id = playlists.id
+);
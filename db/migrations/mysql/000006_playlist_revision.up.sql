@@ -0,0 +1 @@
+ALTER TABLE playlists ADD COLUMN revision INT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE playlists DROP COLUMN entries;
+ALTER TABLE playlists DROP COLUMN visibility;
@@ -0,0 +1,22 @@
+ALTER TABLE playlists ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'public';
+ALTER TABLE playlists ADD COLUMN entries JSON;
+
+-- Attribute the tracks already in playlists to the playlists' owners.
+-- JSON_ARRAYAGG doesn't keep the order of the tracks, so the array is built with GROUP_CONCAT.
+SET SESSION group_concat_max_len = 4294967295;
+UPDATE playlists p
+JOIN (
+  SELECT pl.id, CONCAT('[', GROUP_CONCAT(
+    JSON_OBJECT('track_id', t.track_id, 'added_by', pl.user_id, 'added_at', pl.addition_date)
+    ORDER BY t.position SEPARATOR ','
+  ), ']') AS entries
+  FROM playlists pl
+  JOIN JSON_TABLE(
+    COALESCE(pl.track_ids, JSON_ARRAY()), '$[*]' COLUMNS (position FOR ORDINALITY, track_id VARCHAR(255) PATH '$')
+  ) t
+  GROUP BY pl.id
+) e ON e.id = p.id
+SET p.entries = CAST(e.entries AS JSON);
+UPDATE playlists SET entries = JSON_ARRAY() WHERE entries IS NULL;
+
+ALTER TABLE playlists MODIFY entries JSON NOT NULL;
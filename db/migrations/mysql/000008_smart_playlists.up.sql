@@ -0,0 +1,6 @@
+ALTER TABLE playlists ADD COLUMN kind VARCHAR(16) NOT NULL DEFAULT 'standard';
+-- TEXT columns can't have a literal default, so the rules are filled in before they're required.
+ALTER TABLE playlists ADD COLUMN rules TEXT;
+UPDATE playlists SET rules = '';
+ALTER TABLE playlists MODIFY rules TEXT NOT NULL;
+ALTER TABLE playlists ADD COLUMN evaluated_at BIGINT NOT NULL DEFAULT 0;
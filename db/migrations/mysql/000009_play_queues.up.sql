@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS play_queues (
+  user_id VARCHAR(255) PRIMARY KEY,
+  items JSON NOT NULL,
+  current_index INT NOT NULL DEFAULT 0,
+  position_ms BIGINT NOT NULL DEFAULT 0,
+  shuffle BOOLEAN NOT NULL DEFAULT FALSE,
+  repeat_mode VARCHAR(16) NOT NULL DEFAULT 'off',
+  context_type VARCHAR(32) NOT NULL DEFAULT '',
+  context_id VARCHAR(255) NOT NULL DEFAULT '',
+  device VARCHAR(255) NOT NULL DEFAULT '',
+  revision INT NOT NULL DEFAULT 0,
+  updated_at BIGINT NOT NULL
+);
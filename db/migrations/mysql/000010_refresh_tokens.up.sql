@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  token_hash VARCHAR(64) CHARACTER SET ascii PRIMARY KEY,
+  user_id VARCHAR(255) NOT NULL,
+  family_id VARCHAR(255) NOT NULL,
+  creation_date BIGINT NOT NULL,
+  expiration BIGINT NOT NULL,
+  used_at BIGINT NOT NULL DEFAULT 0,
+  revoked BOOLEAN NOT NULL DEFAULT FALSE,
+  INDEX refresh_tokens_family_id (family_id)
+);
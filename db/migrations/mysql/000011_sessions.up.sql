@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS sessions (
+  id VARCHAR(255) PRIMARY KEY,
+  user_id VARCHAR(255) NOT NULL,
+  device_name VARCHAR(255) NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL,
+  ip VARCHAR(45) NOT NULL DEFAULT '',
+  creation_date BIGINT NOT NULL,
+  last_used BIGINT NOT NULL,
+  expiration BIGINT NOT NULL,
+  INDEX sessions_user_id (user_id)
+);
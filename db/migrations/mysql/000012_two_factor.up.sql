@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS totp_secrets (
+  user_id VARCHAR(255) PRIMARY KEY,
+  secret VARCHAR(255) NOT NULL,
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  last_step BIGINT NOT NULL DEFAULT 0,
+  creation_date BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+  user_id VARCHAR(255) NOT NULL,
+  code_hash VARCHAR(64) CHARACTER SET ascii NOT NULL,
+  PRIMARY KEY (user_id, code_hash)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+  id VARCHAR(1400) CHARACTER SET ascii PRIMARY KEY,
+  user_id VARCHAR(255) NOT NULL,
+  name VARCHAR(255) NOT NULL DEFAULT '',
+  credential JSON NOT NULL,
+  creation_date BIGINT NOT NULL,
+  last_used BIGINT NOT NULL DEFAULT 0,
+  INDEX webauthn_credentials_user_id (user_id)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS access_tokens (
+  id VARCHAR(255) PRIMARY KEY,
+  user_id VARCHAR(255) NOT NULL,
+  name VARCHAR(255) NOT NULL DEFAULT '',
+  token_hash VARCHAR(64) CHARACTER SET ascii NOT NULL UNIQUE,
+  scopes JSON NOT NULL,
+  creation_date BIGINT NOT NULL,
+  expiration BIGINT NOT NULL DEFAULT 0,
+  last_used BIGINT NOT NULL DEFAULT 0,
+  INDEX access_tokens_user_id (user_id)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS email_tokens (
+  id VARCHAR(255) PRIMARY KEY,
+  user_id VARCHAR(255) NOT NULL,
+  purpose VARCHAR(32) NOT NULL,
+  token_hash VARCHAR(64) CHARACTER SET ascii NOT NULL UNIQUE,
+  email VARCHAR(255) NOT NULL,
+  creation_date BIGINT NOT NULL,
+  expiration BIGINT NOT NULL,
+  INDEX email_tokens_user_id (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS email_verifications (
+  user_id VARCHAR(255) PRIMARY KEY,
+  email VARCHAR(255) NOT NULL,
+  verification_date BIGINT NOT NULL
+);
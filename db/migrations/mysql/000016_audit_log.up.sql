@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+  id VARCHAR(255) PRIMARY KEY,
+  action VARCHAR(64) NOT NULL,
+  user_id VARCHAR(255) NOT NULL,
+  username VARCHAR(255) NOT NULL,
+  ip VARCHAR(64) NOT NULL,
+  details TEXT NOT NULL,
+  creation_date BIGINT NOT NULL,
+  INDEX audit_log_creation_date (creation_date)
+);
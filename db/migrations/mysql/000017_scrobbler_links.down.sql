@@ -0,0 +1 @@
+DROP TABLE IF EXISTS scrobbler_links;
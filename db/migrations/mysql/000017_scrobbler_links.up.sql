@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS scrobbler_links (
+  user_id VARCHAR(255) NOT NULL,
+  scrobbler VARCHAR(255) NOT NULL,
+  token TEXT NOT NULL,
+  creation_date BIGINT NOT NULL,
+  PRIMARY KEY (user_id, scrobbler)
+);
+
+-- Scrobbler tokens used to be kept in the users' linked sources.
+INSERT INTO scrobbler_links (user_id, scrobbler, token, creation_date)
+SELECT id, 'lastfm', JSON_UNQUOTE(JSON_EXTRACT(linked_sources, '$.lastfm')), UNIX_TIMESTAMP()
+FROM users
+WHERE JSON_TYPE(linked_sources) = 'OBJECT' AND JSON_UNQUOTE(JSON_EXTRACT(linked_sources, '$.lastfm')) != '';
+
+INSERT INTO scrobbler_links (user_id, scrobbler, token, creation_date)
+SELECT id, 'listenbrainz', JSON_UNQUOTE(JSON_EXTRACT(linked_sources, '$.listenbrainz')), UNIX_TIMESTAMP()
+FROM users
+WHERE JSON_TYPE(linked_sources) = 'OBJECT' AND JSON_UNQUOTE(JSON_EXTRACT(linked_sources, '$.listenbrainz')) != '';
+
+UPDATE users SET linked_sources = JSON_REMOVE(linked_sources, '$.lastfm', '$.listenbrainz')
+WHERE JSON_TYPE(linked_sources) = 'OBJECT';
@@ -0,0 +1,4 @@
+-- Hashed tokens can't be turned back into tokens, so users have to reset theirs.
+DELETE FROM listenbrainz_tokens;
+
+ALTER TABLE listenbrainz_tokens RENAME COLUMN token_hash TO token;
@@ -0,0 +1,4 @@
+-- ListenBrainz tokens are stored hashed like the server's other tokens.
+ALTER TABLE listenbrainz_tokens RENAME COLUMN token TO token_hash;
+
+UPDATE listenbrainz_tokens SET token_hash = SHA2(token_hash, 256);
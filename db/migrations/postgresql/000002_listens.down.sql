@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS listens_user_id_listened_at;
+DROP TABLE IF EXISTS listens;
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+DROP TABLE IF EXISTS listenbrainz_tokens;
+
+ALTER TABLE listens
+  DROP COLUMN IF EXISTS isrc,
+  DROP COLUMN IF EXISTS recording_mbid,
+  DROP COLUMN IF EXISTS release_name,
+  DROP COLUMN IF EXISTS artist_name,
+  DROP COLUMN IF EXISTS track_name;
+
+COMMIT;
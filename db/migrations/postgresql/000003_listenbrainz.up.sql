@@ -0,0 +1,16 @@
+BEGIN;
+
+ALTER TABLE listens
+  ADD COLUMN IF NOT EXISTS track_name TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS artist_name TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS release_name TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS recording_mbid TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS isrc TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS listenbrainz_tokens (
+  user_id TEXT PRIMARY KEY,
+  token TEXT NOT NULL UNIQUE,
+  creation_date BIGINT NOT NULL
+);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS scrobble_queue_failed_next_attempt_at;
+DROP TABLE IF EXISTS scrobble_queue;
+
+COMMIT;
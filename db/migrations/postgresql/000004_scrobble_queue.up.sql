@@ -0,0 +1,17 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS scrobble_queue (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  listen_id TEXT NOT NULL,
+  scrobbler TEXT NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at BIGINT NOT NULL,
+  last_error TEXT NOT NULL DEFAULT '',
+  failed BOOLEAN NOT NULL DEFAULT FALSE,
+  creation_date BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS scrobble_queue_failed_next_attempt_at ON scrobble_queue (failed, next_attempt_at);
+
+COMMIT;
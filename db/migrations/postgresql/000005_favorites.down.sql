@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS favorites_user_id_favorited_at;
+DROP TABLE IF EXISTS favorites;
+
+COMMIT;
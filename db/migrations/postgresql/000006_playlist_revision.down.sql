@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE playlists DROP COLUMN IF EXISTS revision;
+
+COMMIT;
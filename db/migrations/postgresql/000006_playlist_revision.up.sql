@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS revision INT NOT NULL DEFAULT 0;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE playlists DROP COLUMN IF EXISTS entries;
+ALTER TABLE playlists DROP COLUMN IF EXISTS visibility;
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'public';
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS entries jsonb NOT NULL DEFAULT '[]';
+
+-- Attribute the tracks already in playlists to the playlists' owners.
+UPDATE playlists SET entries = COALESCE((
+  SELECT jsonb_agg(
+    jsonb_build_object('track_id', t.track_id, 'added_by', playlists.user_id, 'added_at', playlists.addition_date)
+    ORDER BY t.position
+  )
+  FROM unnest(playlists.track_ids) WITH ORDINALITY AS t (track_id, position)
+), '[]');
+
+COMMIT;
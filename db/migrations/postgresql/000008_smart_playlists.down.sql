@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE playlists DROP COLUMN IF EXISTS evaluated_at;
+ALTER TABLE playlists DROP COLUMN IF EXISTS rules;
+ALTER TABLE playlists DROP COLUMN IF EXISTS kind;
+
+COMMIT;
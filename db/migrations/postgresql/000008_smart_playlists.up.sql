@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'standard';
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS rules TEXT NOT NULL DEFAULT '';
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS evaluated_at BIGINT NOT NULL DEFAULT 0;
+
+COMMIT;
@@ -0,0 +1,17 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS play_queues (
+  user_id TEXT PRIMARY KEY,
+  items jsonb NOT NULL DEFAULT '[]',
+  current_index INT NOT NULL DEFAULT 0,
+  position_ms BIGINT NOT NULL DEFAULT 0,
+  shuffle BOOLEAN NOT NULL DEFAULT FALSE,
+  repeat_mode TEXT NOT NULL DEFAULT 'off',
+  context_type TEXT NOT NULL DEFAULT '',
+  context_id TEXT NOT NULL DEFAULT '',
+  device TEXT NOT NULL DEFAULT '',
+  revision INT NOT NULL DEFAULT 0,
+  updated_at BIGINT NOT NULL
+);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS totp_secrets;
+
+COMMIT;
@@ -0,0 +1,17 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS totp_secrets (
+  user_id TEXT PRIMARY KEY,
+  secret TEXT NOT NULL,
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  last_step BIGINT NOT NULL DEFAULT 0,
+  creation_date BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+  user_id TEXT NOT NULL,
+  code_hash TEXT NOT NULL,
+  PRIMARY KEY (user_id, code_hash)
+);
+
+COMMIT;
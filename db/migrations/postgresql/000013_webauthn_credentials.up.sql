@@ -0,0 +1,14 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  name TEXT NOT NULL DEFAULT '',
+  credential jsonb NOT NULL,
+  creation_date BIGINT NOT NULL,
+  last_used BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS webauthn_credentials_user_id ON webauthn_credentials (user_id);
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS access_tokens (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  name TEXT NOT NULL DEFAULT '',
+  token_hash TEXT NOT NULL UNIQUE,
+  scopes jsonb NOT NULL DEFAULT '[]',
+  creation_date BIGINT NOT NULL,
+  expiration BIGINT NOT NULL DEFAULT 0,
+  last_used BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS access_tokens_user_id ON access_tokens (user_id);
+
+COMMIT;
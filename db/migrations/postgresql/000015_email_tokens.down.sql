@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS email_verifications;
+DROP TABLE IF EXISTS email_tokens;
+
+COMMIT;
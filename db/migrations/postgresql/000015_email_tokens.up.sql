@@ -0,0 +1,21 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS email_tokens (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  purpose TEXT NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE,
+  email TEXT NOT NULL,
+  creation_date BIGINT NOT NULL,
+  expiration BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS email_tokens_user_id ON email_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS email_verifications (
+  user_id TEXT PRIMARY KEY,
+  email TEXT NOT NULL,
+  verification_date BIGINT NOT NULL
+);
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS audit_log (
+  id TEXT PRIMARY KEY,
+  action TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  username TEXT NOT NULL,
+  ip TEXT NOT NULL,
+  details TEXT NOT NULL,
+  creation_date BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_creation_date ON audit_log (creation_date);
+
+COMMIT;
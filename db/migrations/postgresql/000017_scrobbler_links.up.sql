@@ -0,0 +1,21 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS scrobbler_links (
+  user_id TEXT NOT NULL,
+  scrobbler TEXT NOT NULL,
+  token TEXT NOT NULL,
+  creation_date BIGINT NOT NULL,
+  PRIMARY KEY (user_id, scrobbler)
+);
+
+-- Scrobbler tokens used to be kept in the users' linked sources.
+INSERT INTO scrobbler_links (user_id, scrobbler, token, creation_date)
+SELECT users.id, scrobbler.key, scrobbler.value, EXTRACT(EPOCH FROM now())::BIGINT
+FROM users, jsonb_each_text(users.linked_sources) AS scrobbler
+WHERE jsonb_typeof(users.linked_sources) = 'object'
+  AND scrobbler.key IN ('lastfm', 'listenbrainz') AND scrobbler.value != '';
+
+UPDATE users SET linked_sources = linked_sources - 'lastfm' - 'listenbrainz'
+WHERE jsonb_typeof(linked_sources) = 'object';
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- Hashed tokens can't be turned back into tokens, so users have to reset theirs.
+DELETE FROM listenbrainz_tokens;
+
+ALTER TABLE listenbrainz_tokens RENAME COLUMN token_hash TO token;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- ListenBrainz tokens are stored hashed like the server's other tokens.
+ALTER TABLE listenbrainz_tokens RENAME COLUMN token TO token_hash;
+
+UPDATE listenbrainz_tokens SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+COMMIT;
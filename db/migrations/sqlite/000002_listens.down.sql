@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS listens_user_id_listened_at;
+DROP TABLE IF EXISTS listens;
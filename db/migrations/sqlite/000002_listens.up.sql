@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS listens (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  playable_id TEXT NOT NULL,
+  playable_type TEXT NOT NULL,
+  listened_at INTEGER NOT NULL,
+  duration_played INTEGER,
+  client TEXT,
+  source TEXT
+);
+
+CREATE INDEX IF NOT EXISTS listens_user_id_listened_at ON listens (user_id, listened_at);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS listenbrainz_tokens;
+
+ALTER TABLE listens DROP COLUMN isrc;
+ALTER TABLE listens DROP COLUMN recording_mbid;
+ALTER TABLE listens DROP COLUMN release_name;
+ALTER TABLE listens DROP COLUMN artist_name;
+ALTER TABLE listens DROP COLUMN track_name;
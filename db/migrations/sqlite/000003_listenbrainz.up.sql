@@ -0,0 +1,11 @@
+ALTER TABLE listens ADD COLUMN track_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE listens ADD COLUMN artist_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE listens ADD COLUMN release_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE listens ADD COLUMN recording_mbid TEXT NOT NULL DEFAULT '';
+ALTER TABLE listens ADD COLUMN isrc TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS listenbrainz_tokens (
+  user_id TEXT PRIMARY KEY,
+  token TEXT NOT NULL UNIQUE,
+  creation_date INTEGER NOT NULL
+);
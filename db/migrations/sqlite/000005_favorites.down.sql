@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS favorites_user_id_favorited_at;
+DROP TABLE IF EXISTS favorites;
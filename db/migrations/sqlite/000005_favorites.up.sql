@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS favorites (
+  user_id TEXT NOT NULL,
+  playable_type TEXT NOT NULL,
+  playable_id TEXT NOT NULL,
+  favorited_at INTEGER NOT NULL,
+  PRIMARY KEY (user_id, playable_type, playable_id)
+);
+
+CREATE INDEX IF NOT EXISTS favorites_user_id_favorited_at ON favorites (user_id, favorited_at);
+
+-- Move the favorites stored on users into the new table.
+WITH playables (playable_type, id) AS (
+  SELECT 'track', id FROM tracks
+  UNION ALL SELECT 'album', id FROM albums
+  UNION ALL SELECT 'video', id FROM videos
+  UNION ALL SELECT 'artist', id FROM artists
+  UNION ALL SELECT 'playlist', id FROM playlists
+)
+INSERT OR IGNORE INTO favorites (user_id, playable_type, playable_id, favorited_at)
+SELECT u.id, p.playable_type, p.id, 0
+FROM users u
+JOIN json_each(CASE WHEN json_valid(u.favorites) THEN u.favorites ELSE '[]' END) f
+JOIN playables p ON p.id = f.value;
+
+UPDATE tracks SET favorite_count = (
+  SELECT COUNT(*) FROM favorites WHERE playable_type = 'track' AND playable_id = tracks.id
+);
+UPDATE albums SET favorite_count = (
+  SELECT COUNT(*) FROM favorites WHERE playable_type = 'album' AND playable_id = albums.id
+);
+UPDATE videos SET favorite_count = (
+  SELECT COUNT(*) FROM favorites WHERE playable_type = 'video' AND playable_id = videos.id
+);
+UPDATE artists SET favorite_count = (
+  SELECT COUNT(*) FROM favorites WHERE playable_type = 'artist' AND playable_id = artists.id
+);
+UPDATE playlists SET favorite_count = (
+  SELECT COUNT(*) FROM favorites WHERE playable_type = 'playlist' AND playable_id = playlists.id
+);
@@ -0,0 +1 @@
+ALTER TABLE playlists DROP COLUMN revision;
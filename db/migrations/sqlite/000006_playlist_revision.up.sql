@@ -0,0 +1 @@
+ALTER TABLE playlists ADD COLUMN revision INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,9 @@
+ALTER TABLE playlists ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
+ALTER TABLE playlists ADD COLUMN entries TEXT NOT NULL DEFAULT '[]';
+
+-- Attribute the tracks already in playlists to the playlists' owners.
+UPDATE playlists SET entries = (
+  SELECT json_group_array(json_object('track_id', t.value, 'added_by', playlists.user_id, 'added_at', playlists.addition_date))
+  FROM (SELECT value FROM json_each(playlists.track_ids) ORDER BY key) t
+)
+WHERE json_valid(track_ids);
@@ -0,0 +1,3 @@
+ALTER TABLE playlists DROP COLUMN evaluated_at;
+ALTER TABLE playlists DROP COLUMN rules;
+ALTER TABLE playlists DROP COLUMN kind;
@@ -0,0 +1,3 @@
+ALTER TABLE playlists ADD COLUMN kind TEXT NOT NULL DEFAULT 'standard';
+ALTER TABLE playlists ADD COLUMN rules TEXT NOT NULL DEFAULT '';
+ALTER TABLE playlists ADD COLUMN evaluated_at INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS play_queues (
+  user_id TEXT PRIMARY KEY,
+  items TEXT NOT NULL DEFAULT '[]',
+  current_index INTEGER NOT NULL DEFAULT 0,
+  position_ms INTEGER NOT NULL DEFAULT 0,
+  shuffle BOOLEAN NOT NULL DEFAULT FALSE,
+  repeat_mode TEXT NOT NULL DEFAULT 'off',
+  context_type TEXT NOT NULL DEFAULT '',
+  context_id TEXT NOT NULL DEFAULT '',
+  device TEXT NOT NULL DEFAULT '',
+  revision INTEGER NOT NULL DEFAULT 0,
+  updated_at INTEGER NOT NULL
+);
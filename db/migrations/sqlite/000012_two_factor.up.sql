@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS totp_secrets (
+  user_id TEXT PRIMARY KEY,
+  secret TEXT NOT NULL,
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  last_step INTEGER NOT NULL DEFAULT 0,
+  creation_date INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+  user_id TEXT NOT NULL,
+  code_hash TEXT NOT NULL,
+  PRIMARY KEY (user_id, code_hash)
+);
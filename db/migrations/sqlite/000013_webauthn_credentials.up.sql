@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  name TEXT NOT NULL DEFAULT '',
+  credential TEXT NOT NULL,
+  creation_date INTEGER NOT NULL,
+  last_used INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS webauthn_credentials_user_id ON webauthn_credentials (user_id);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS email_tokens (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  purpose TEXT NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE,
+  email TEXT NOT NULL,
+  creation_date INTEGER NOT NULL,
+  expiration INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS email_tokens_user_id ON email_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS email_verifications (
+  user_id TEXT PRIMARY KEY,
+  email TEXT NOT NULL,
+  verification_date INTEGER NOT NULL
+);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS scrobbler_links (
+  user_id TEXT NOT NULL,
+  scrobbler TEXT NOT NULL,
+  token TEXT NOT NULL,
+  creation_date INTEGER NOT NULL,
+  PRIMARY KEY (user_id, scrobbler)
+);
+
+-- Scrobbler tokens used to be kept in the users' linked sources.
+INSERT INTO scrobbler_links (user_id, scrobbler, token, creation_date)
+SELECT users.id, scrobbler.key, scrobbler.value, CAST(strftime('%s', 'now') AS INTEGER)
+FROM users, json_each(users.linked_sources) AS scrobbler
+WHERE json_valid(users.linked_sources) AND json_type(users.linked_sources) = 'object'
+  AND scrobbler.key IN ('lastfm', 'listenbrainz') AND scrobbler.value != '';
+
+UPDATE users SET linked_sources = json_remove(linked_sources, '$.lastfm', '$.listenbrainz')
+WHERE json_valid(linked_sources) AND json_type(linked_sources) = 'object';
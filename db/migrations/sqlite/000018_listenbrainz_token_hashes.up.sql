@@ -0,0 +1,5 @@
+-- ListenBrainz tokens are stored hashed like the server's other tokens.
+-- sha256_hex is registered on every connection by the SQLite engine.
+ALTER TABLE listenbrainz_tokens RENAME COLUMN token TO token_hash;
+
+UPDATE listenbrainz_tokens SET token_hash = sha256_hex(token_hash);
@@ -0,0 +1,2552 @@
+//go:build mysql_db || !(no_mysql_db || no_dbs)
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/go-sql-driver/mysql"
+	"github.com/goccy/go-json"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/rules"
+)
+
+type MySQLDatabase struct {
+	pool      *sql.DB
+	closeOnce sync.Once
+	dsn       string
+
+	// The configuration the pool was opened with, which migrations open their own connection with.
+	cfg *mysql.Config
+}
+
+func (*MySQLDatabase) EngineName() string {
+	return "MySQL"
+}
+
+func (*MySQLDatabase) Satisfies(engine string) bool {
+	return slices.Contains([]string{
+		"mysql",
+		"mariadb",
+	}, strings.ToLower(engine))
+}
+
+func (*MySQLDatabase) WithDSN(dsn string) Database {
+	return &MySQLDatabase{dsn: dsn}
+}
+
+func (db *MySQLDatabase) Connect() error {
+	log.Info("Connecting to MySQL...")
+	dsn := fmt.Sprintf(
+		"%s:%s@tcp(%s:%d)/%s?%s",
+		config.Conf.Database.MySQL.User,
+		config.Conf.Database.MySQL.Pass,
+		config.Conf.Database.MySQL.Host,
+		config.Conf.Database.MySQL.Port,
+		config.Conf.Database.MySQL.DBName,
+		config.Conf.Database.MySQL.Params,
+	)
+	if db.dsn != "" {
+		dsn = db.dsn
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return err
+	}
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	db.cfg = cfg
+
+	pool, err := sql.Open("mysql", cfg.FormatDSN())
+	db.pool = pool
+	if err != nil {
+		return err
+	}
+	if err = pool.PingContext(context.Background()); err != nil {
+		return err
+	}
+
+	// If the migrations table doesn't exist, create it and run migrations.
+	exists, err := db.migrationsTableExists(context.Background())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := db.createMigrationsTable(context.Background()); err != nil {
+			return err
+		}
+		if err := db.MigrateUp(-1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// openMigrationConn opens a connection that runs migration files, which contain multiple statements.
+// The pool doesn't allow them, so a query that's been injected into can't run statements of its own.
+func (db *MySQLDatabase) openMigrationConn() (*sql.DB, error) {
+	cfg := db.cfg.Clone()
+	cfg.MultiStatements = true
+	conn, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	conn.SetMaxOpenConns(1)
+	return conn, nil
+}
+
+func (db *MySQLDatabase) Close() error {
+	var err error
+	db.closeOnce.Do(func() {
+		log.Info("Closing MySQL connection...")
+		err = db.pool.Close()
+	})
+	return err
+}
+
+// mySQLTxKey is the context key of a transaction of db.
+type mySQLTxKey struct{ db *MySQLDatabase }
+
+// mySQLQuerier runs queries on the pool or in a transaction.
+type mySQLQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (db *MySQLDatabase) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(mySQLTxKey{db}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := db.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return normalizeMySQLError(err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, mySQLTxKey{db}, tx)); err != nil {
+		return err
+	}
+	return normalizeMySQLError(tx.Commit())
+}
+
+// querier returns the transaction ctx is in, or the pool if it isn't in one.
+func (db *MySQLDatabase) querier(ctx context.Context) mySQLQuerier {
+	if tx, ok := ctx.Value(mySQLTxKey{db}).(*sql.Tx); ok {
+		return tx
+	}
+	return db.pool
+}
+
+// mySQLLimit returns the argument of a LIMIT clause. MySQL has no way to leave out the limit
+// when there's an offset, so a negative limit is replaced with one larger than any table.
+func mySQLLimit(limit int) int64 {
+	if limit < 0 {
+		return math.MaxInt64
+	}
+	return int64(limit)
+}
+
+func (db *MySQLDatabase) CountRows(ctx context.Context, table string) (int, error) {
+	if err := checkTable(table); err != nil {
+		return 0, err
+	}
+	var count int
+	err := db.querier(ctx).QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table+`;`).Scan(&count)
+	return count, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) migrationsTableExists(ctx context.Context) (bool, error) {
+	var exists bool
+	err := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT EXISTS (
+            SELECT 1 FROM information_schema.tables
+            WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'
+        );
+    `).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return exists, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) createMigrationsTable(ctx context.Context) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version BIGINT PRIMARY KEY,
+            dirty BOOLEAN
+        );
+    `)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) currentVersion(ctx context.Context) (uint64, bool, error) {
+	var version uint64
+	var dirty bool
+	err := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT version, dirty FROM schema_migrations
+        ORDER BY version DESC LIMIT 1;
+    `).Scan(&version, &dirty)
+	if err != nil {
+		return 0, false, normalizeMySQLError(err)
+	}
+
+	return version, dirty, nil
+}
+
+func (db *MySQLDatabase) setVersion(ctx context.Context, version uint64, dirty bool) error {
+	// Statements with placeholders are prepared on the server, which only accepts one statement at a time.
+	tx, err := db.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return normalizeMySQLError(err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations;`); err != nil {
+		return normalizeMySQLError(err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+        INSERT INTO schema_migrations (version, dirty) VALUES (?, ?);
+    `, version, dirty); err != nil {
+		return normalizeMySQLError(err)
+	}
+	return normalizeMySQLError(tx.Commit())
+}
+
+func (*MySQLDatabase) MigrationsDir() string {
+	return "migrations/mysql"
+}
+
+func (db *MySQLDatabase) ForceVersion(ctx context.Context, version uint64) error {
+	if err := db.createMigrationsTable(ctx); err != nil {
+		return err
+	}
+	return db.setVersion(ctx, version, false)
+}
+
+func (db *MySQLDatabase) MigrationVersion(ctx context.Context) (uint64, bool, error) {
+	return db.currentVersion(ctx)
+}
+
+func (db *MySQLDatabase) MigrateUp(steps int) error {
+	if err := db.createMigrationsTable(context.Background()); err != nil {
+		return err
+	}
+
+	currentVersion, dirty, err := db.currentVersion(context.Background())
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if dirty {
+		return errors.New("database is in dirty state")
+	}
+
+	conn, err := db.openMigrationConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	entries, err := migrationsFS.ReadDir(db.MigrationsDir())
+	if err != nil {
+		return err
+	}
+	files := OrderedMigrationFiles(entries, true)
+
+	appliedCount := 0
+	for _, file := range files {
+		versionStr := strings.Split(file, "_")[0]
+		version, err := strconv.ParseUint(versionStr, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		if version <= currentVersion {
+			continue
+		}
+
+		if steps >= 0 && appliedCount >= steps {
+			break
+		}
+
+		// Set dirty flag before applying migration.
+		if err := db.setVersion(context.Background(), version, true); err != nil {
+			return err
+		}
+
+		// Read and execute migration.
+		content, err := migrationsFS.ReadFile(filepath.Join(db.MigrationsDir(), file))
+		if err != nil {
+			return err
+		}
+
+		_, err = conn.ExecContext(context.Background(), string(content))
+		if err != nil {
+			return normalizeMySQLError(err)
+		}
+
+		// Clear dirty flag after successful migration.
+		if err := db.setVersion(context.Background(), version, false); err != nil {
+			return err
+		}
+
+		appliedCount++
+	}
+
+	return nil
+}
+
+func (db *MySQLDatabase) MigrateDown(steps int) error {
+	if err := db.createMigrationsTable(context.Background()); err != nil {
+		return err
+	}
+
+	currentVersion, dirty, err := db.currentVersion(context.Background())
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if dirty {
+		return errors.New("database is in dirty state")
+	}
+
+	conn, err := db.openMigrationConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	entries, err := migrationsFS.ReadDir(db.MigrationsDir())
+	if err != nil {
+		return err
+	}
+	files := OrderedMigrationFiles(entries, false)
+
+	appliedCount := 0
+	for i, file := range files {
+		versionStr := strings.Split(file, "_")[0]
+		version, err := strconv.ParseUint(versionStr, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		if version > currentVersion {
+			continue
+		}
+
+		if steps >= 0 && appliedCount >= steps {
+			break
+		}
+
+		// Set dirty flag before applying migration.
+		if err := db.setVersion(context.Background(), version, true); err != nil {
+			return err
+		}
+
+		// Read and execute migration.
+		content, err := migrationsFS.ReadFile(filepath.Join(db.MigrationsDir(), file))
+		if err != nil {
+			return err
+		}
+
+		_, err = conn.ExecContext(context.Background(), string(content))
+		if err != nil {
+			return normalizeMySQLError(err)
+		}
+
+		// Set version to previous migration and clear dirty flag.
+		prevVersion := uint64(0)
+		if i < len(files)-1 {
+			prevVersionStr := strings.Split(files[i+1], "_")[0]
+			prevVersion, err = strconv.ParseUint(prevVersionStr, 10, 64)
+			if err != nil {
+				return err
+			}
+		}
+		if err := db.setVersion(context.Background(), prevVersion, false); err != nil {
+			return err
+		}
+
+		appliedCount++
+	}
+
+	return nil
+}
+
+func (db *MySQLDatabase) AllTracks(ctx context.Context) ([]media.Track, error) {
+	return db.AllTracksPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) AllTracksPage(ctx context.Context, limit, offset int) ([]media.Track, error) {
+	var tracks []media.Track
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
+        FROM tracks ORDER BY id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return tracks, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		track, err := scanMySQLTrack(rows)
+		if err != nil {
+			return tracks, normalizeMySQLError(err)
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) Tracks(ctx context.Context, userID string) ([]media.Track, error) {
+	var tracks []media.Track
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
+        FROM tracks WHERE user_id=?;
+    `, userID)
+	if err != nil {
+		return tracks, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		track, err := scanMySQLTrack(rows)
+		if err != nil {
+			return tracks, normalizeMySQLError(err)
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) Track(ctx context.Context, id string) (media.Track, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
+        FROM tracks WHERE id=?;
+    `, id)
+	track, err := scanMySQLTrack(row)
+	return track, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) TrackByRecordingMBID(ctx context.Context, mbid string) (media.Track, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
+        FROM tracks WHERE LOWER(additional_meta->>'$.musicbrainz_recording_id')=LOWER(?) ORDER BY id LIMIT 1;
+    `, mbid)
+	track, err := scanMySQLTrack(row)
+	return track, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) TrackByISRC(ctx context.Context, isrc string) (media.Track, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
+        FROM tracks WHERE UPPER(isrc)=UPPER(?) ORDER BY id LIMIT 1;
+    `, isrc)
+	track, err := scanMySQLTrack(row)
+	return track, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) TracksByTitle(ctx context.Context, title string) ([]media.Track, error) {
+	var tracks []media.Track
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
+        FROM tracks WHERE LOWER(title)=LOWER(?) ORDER BY id;
+    `, title)
+	if err != nil {
+		return tracks, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		track, err := scanMySQLTrack(rows)
+		if err != nil {
+			return tracks, normalizeMySQLError(err)
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) AddTrack(ctx context.Context, track media.Track) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO tracks (
+            id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
+        ) VALUES (
+            ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+        );
+    `, track.ID, track.UserID, track.ISRC, track.Title, mysqlJSON{track.ArtistIDs}, mysqlJSON{track.AlbumIDs}, track.PrimaryAlbumID, track.TrackNumber, track.Duration, track.Description, track.ReleaseDate, mysqlJSON{track.Lyrics}, track.ListenCount, track.FavoriteCount, track.AdditionDate, mysqlJSON{track.Tags}, mysqlJSON{track.AdditionalMeta}, mysqlJSON{track.Permissions}, mysqlJSON{track.LinkedItemIDs}, track.ContentSource, track.MetadataSource, mysqlJSON{track.LyricSources})
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UpdateTrack(ctx context.Context, track media.Track) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        UPDATE tracks
+        SET user_id=?, isrc=?, title=?, artist_ids=?, album_ids=?, primary_album_id=?, track_number=?, duration=?, description=?, release_date=?, lyrics=?, listen_count=?, favorite_count=?, addition_date=?, tags=?, additional_meta=?, permissions=?, linked_item_ids=?, content_source=?, metadata_source=?, lyric_sources=?
+        WHERE id=?;
+    `, track.UserID, track.ISRC, track.Title, mysqlJSON{track.ArtistIDs}, mysqlJSON{track.AlbumIDs}, track.PrimaryAlbumID, track.TrackNumber, track.Duration, track.Description, track.ReleaseDate, mysqlJSON{track.Lyrics}, track.ListenCount, track.FavoriteCount, track.AdditionDate, mysqlJSON{track.Tags}, mysqlJSON{track.AdditionalMeta}, mysqlJSON{track.Permissions}, mysqlJSON{track.LinkedItemIDs}, track.ContentSource, track.MetadataSource, mysqlJSON{track.LyricSources}, track.ID)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) DeleteTrack(ctx context.Context, id string) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `DELETE FROM tracks WHERE id=?;`, id)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AllAlbums(ctx context.Context) ([]media.Album, error) {
+	return db.AllAlbumsPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) AllAlbumsPage(ctx context.Context, limit, offset int) ([]media.Album, error) {
+	var albums []media.Album
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, upc, ean, title, artist_ids, track_ids, description, release_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
+        FROM albums ORDER BY id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return albums, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		album, err := scanMySQLAlbum(rows)
+		if err != nil {
+			return albums, normalizeMySQLError(err)
+		}
+		albums = append(albums, album)
+	}
+	return albums, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) Albums(ctx context.Context, userID string) ([]media.Album, error) {
+	var albums []media.Album
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, upc, ean, title, artist_ids, track_ids, description, release_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
+        FROM albums WHERE user_id=?;
+    `, userID)
+	if err != nil {
+		return albums, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		album, err := scanMySQLAlbum(rows)
+		if err != nil {
+			return albums, normalizeMySQLError(err)
+		}
+		albums = append(albums, album)
+	}
+	return albums, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) Album(ctx context.Context, id string) (media.Album, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, user_id, upc, ean, title, artist_ids, track_ids, description, release_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
+        FROM albums WHERE id=?;
+    `, id)
+	album, err := scanMySQLAlbum(row)
+	return album, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AddAlbum(ctx context.Context, album media.Album) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO albums (
+            id, user_id, upc, ean, title, artist_ids, track_ids, description, release_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
+        ) VALUES (
+            ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+        );
+    `, album.ID, album.UserID, album.UPC, album.EAN, album.Title, mysqlJSON{album.ArtistIDs}, mysqlJSON{album.TrackIDs}, album.Description, album.ReleaseDate, album.ListenCount, album.FavoriteCount, album.AdditionDate, mysqlJSON{album.Tags}, mysqlJSON{album.AdditionalMeta}, mysqlJSON{album.Permissions}, mysqlJSON{album.LinkedItemIDs}, album.MetadataSource)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UpdateAlbum(ctx context.Context, album media.Album) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        UPDATE albums
+        SET user_id=?, upc=?, ean=?, title=?, artist_ids=?, track_ids=?, description=?, release_date=?, listen_count=?, favorite_count=?, addition_date=?, tags=?, additional_meta=?, permissions=?, linked_item_ids=?, metadata_source=?
+        WHERE id=?;
+    `, album.UserID, album.UPC, album.EAN, album.Title, mysqlJSON{album.ArtistIDs}, mysqlJSON{album.TrackIDs}, album.Description, album.ReleaseDate, album.ListenCount, album.FavoriteCount, album.AdditionDate, mysqlJSON{album.Tags}, mysqlJSON{album.AdditionalMeta}, mysqlJSON{album.Permissions}, mysqlJSON{album.LinkedItemIDs}, album.MetadataSource, album.ID)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) DeleteAlbum(ctx context.Context, id string) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `DELETE FROM albums WHERE id=?;`, id)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AllVideos(ctx context.Context) ([]media.Video, error) {
+	return db.AllVideosPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) AllVideosPage(ctx context.Context, limit, offset int) ([]media.Video, error) {
+	var videos []media.Video
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, title, artist_ids, duration, description, release_date, subtitles, watch_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
+        FROM videos ORDER BY id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return videos, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		video, err := scanMySQLVideo(rows)
+		if err != nil {
+			return videos, normalizeMySQLError(err)
+		}
+		videos = append(videos, video)
+	}
+	return videos, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) Videos(ctx context.Context, userID string) ([]media.Video, error) {
+	var videos []media.Video
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, title, artist_ids, duration, description, release_date, subtitles, watch_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
+        FROM videos WHERE user_id=?;
+    `, userID)
+	if err != nil {
+		return videos, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		video, err := scanMySQLVideo(rows)
+		if err != nil {
+			return videos, normalizeMySQLError(err)
+		}
+		videos = append(videos, video)
+	}
+	return videos, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) Video(ctx context.Context, id string) (media.Video, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, user_id, title, artist_ids, duration, description, release_date, subtitles, watch_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
+        FROM videos WHERE id=?;
+    `, id)
+	video, err := scanMySQLVideo(row)
+	return video, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AddVideo(ctx context.Context, video media.Video) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO videos (
+            id, user_id, title, artist_ids, duration, description, release_date, subtitles, watch_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
+        ) VALUES (
+            ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+        );
+    `, video.ID, video.UserID, video.Title, mysqlJSON{video.ArtistIDs}, video.Duration, video.Description, video.ReleaseDate, mysqlJSON{video.Subtitles}, video.WatchCount, video.FavoriteCount, video.AdditionDate, mysqlJSON{video.Tags}, mysqlJSON{video.AdditionalMeta}, mysqlJSON{video.Permissions}, mysqlJSON{video.LinkedItemIDs}, video.ContentSource, video.MetadataSource, mysqlJSON{video.LyricSources})
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UpdateVideo(ctx context.Context, video media.Video) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        UPDATE videos
+        SET user_id=?, title=?, artist_ids=?, duration=?, description=?, release_date=?, subtitles=?, watch_count=?, favorite_count=?, addition_date=?, tags=?, additional_meta=?, permissions=?, linked_item_ids=?, content_source=?, metadata_source=?, lyric_sources=?
+        WHERE id=?;
+    `, video.UserID, video.Title, mysqlJSON{video.ArtistIDs}, video.Duration, video.Description, video.ReleaseDate, mysqlJSON{video.Subtitles}, video.WatchCount, video.FavoriteCount, video.AdditionDate, mysqlJSON{video.Tags}, mysqlJSON{video.AdditionalMeta}, mysqlJSON{video.Permissions}, mysqlJSON{video.LinkedItemIDs}, video.ContentSource, video.MetadataSource, mysqlJSON{video.LyricSources}, video.ID)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) DeleteVideo(ctx context.Context, id string) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `DELETE FROM videos WHERE id=?;`, id)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AllArtists(ctx context.Context) ([]media.Artist, error) {
+	return db.AllArtistsPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) AllArtistsPage(ctx context.Context, limit, offset int) ([]media.Artist, error) {
+	var artists []media.Artist
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, name, album_ids, track_ids, description, creation_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
+        FROM artists ORDER BY id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return artists, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		artist, err := scanMySQLArtist(rows)
+		if err != nil {
+			return artists, normalizeMySQLError(err)
+		}
+		artists = append(artists, artist)
+	}
+	return artists, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) Artists(ctx context.Context, userID string) ([]media.Artist, error) {
+	var artists []media.Artist
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, name, album_ids, track_ids, description, creation_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
+        FROM artists WHERE user_id=?;
+    `, userID)
+	if err != nil {
+		return artists, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		artist, err := scanMySQLArtist(rows)
+		if err != nil {
+			return artists, normalizeMySQLError(err)
+		}
+		artists = append(artists, artist)
+	}
+	return artists, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) Artist(ctx context.Context, id string) (media.Artist, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, user_id, name, album_ids, track_ids, description, creation_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
+        FROM artists WHERE id=?;
+    `, id)
+	artist, err := scanMySQLArtist(row)
+	return artist, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AddArtist(ctx context.Context, artist media.Artist) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO artists (
+            id, user_id, name, album_ids, track_ids, description, creation_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
+        ) VALUES (
+            ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+        );
+    `, artist.ID, artist.UserID, artist.Name, mysqlJSON{artist.AlbumIDs}, mysqlJSON{artist.TrackIDs}, artist.Description, artist.CreationDate, artist.ListenCount, artist.FavoriteCount, artist.AdditionDate, mysqlJSON{artist.Tags}, mysqlJSON{artist.AdditionalMeta}, mysqlJSON{artist.Permissions}, mysqlJSON{artist.LinkedItemIDs}, artist.MetadataSource)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UpdateArtist(ctx context.Context, artist media.Artist) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        UPDATE artists
+        SET user_id=?, name=?, album_ids=?, track_ids=?, description=?, creation_date=?, listen_count=?, favorite_count=?, addition_date=?, tags=?, additional_meta=?, permissions=?, linked_item_ids=?, metadata_source=?
+        WHERE id=?;
+    `, artist.UserID, artist.Name, mysqlJSON{artist.AlbumIDs}, mysqlJSON{artist.TrackIDs}, artist.Description, artist.CreationDate, artist.ListenCount, artist.FavoriteCount, artist.AdditionDate, mysqlJSON{artist.Tags}, mysqlJSON{artist.AdditionalMeta}, mysqlJSON{artist.Permissions}, mysqlJSON{artist.LinkedItemIDs}, artist.MetadataSource, artist.ID)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) DeleteArtist(ctx context.Context, id string) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `DELETE FROM artists WHERE id=?;`, id)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AllPlaylists(ctx context.Context) ([]media.Playlist, error) {
+	return db.AllPlaylistsPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) AllPlaylistsPage(ctx context.Context, limit, offset int) ([]media.Playlist, error) {
+	var playlists []media.Playlist
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source, revision, entries, visibility, kind, rules, evaluated_at
+        FROM playlists ORDER BY id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return playlists, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		playlist, err := scanMySQLPlaylist(rows)
+		if err != nil {
+			return playlists, normalizeMySQLError(err)
+		}
+		playlists = append(playlists, playlist)
+	}
+	return playlists, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) Playlists(ctx context.Context, userID string) ([]media.Playlist, error) {
+	var playlists []media.Playlist
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source, revision, entries, visibility, kind, rules, evaluated_at
+        FROM playlists WHERE user_id=?;
+    `, userID)
+	if err != nil {
+		return playlists, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		playlist, err := scanMySQLPlaylist(rows)
+		if err != nil {
+			return playlists, normalizeMySQLError(err)
+		}
+		playlists = append(playlists, playlist)
+	}
+	return playlists, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) Playlist(ctx context.Context, id string) (media.Playlist, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source, revision, entries, visibility, kind, rules, evaluated_at
+        FROM playlists WHERE id=?;
+    `, id)
+	playlist, err := scanMySQLPlaylist(row)
+	return playlist, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AddPlaylist(ctx context.Context, playlist media.Playlist) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO playlists (
+            id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source, revision, entries, visibility, kind, rules, evaluated_at
+        ) VALUES (
+            ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+        );
+    `, playlist.ID, playlist.UserID, playlist.Title, mysqlJSON{playlist.TrackIDs}, playlist.ListenCount, playlist.FavoriteCount, playlist.Description, playlist.CreationDate, playlist.AdditionDate, mysqlJSON{playlist.Tags}, mysqlJSON{playlist.AdditionalMeta}, mysqlJSON{playlist.Permissions}, playlist.MetadataSource, playlist.Revision, mysqlJSON{playlist.Entries}, playlist.Visibility, playlist.Kind, playlist.Rules, playlist.EvaluatedAt)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UpdatePlaylist(ctx context.Context, playlist media.Playlist) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        UPDATE playlists
+        SET user_id=?, title=?, track_ids=?, listen_count=?, favorite_count=?, description=?, creation_date=?, addition_date=?, tags=?, additional_meta=?, permissions=?, metadata_source=?, revision=?, entries=?, visibility=?, kind=?, rules=?, evaluated_at=?
+        WHERE id=?;
+    `, playlist.UserID, playlist.Title, mysqlJSON{playlist.TrackIDs}, playlist.ListenCount, playlist.FavoriteCount, playlist.Description, playlist.CreationDate, playlist.AdditionDate, mysqlJSON{playlist.Tags}, mysqlJSON{playlist.AdditionalMeta}, mysqlJSON{playlist.Permissions}, playlist.MetadataSource, playlist.Revision, mysqlJSON{playlist.Entries}, playlist.Visibility, playlist.Kind, playlist.Rules, playlist.EvaluatedAt, playlist.ID)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UpdatePlaylistAtRevision(ctx context.Context, playlist media.Playlist, revision int) error {
+	result, err := db.querier(ctx).ExecContext(ctx, `
+        UPDATE playlists
+        SET user_id=?, title=?, track_ids=?, listen_count=?, favorite_count=?, description=?, creation_date=?, addition_date=?, tags=?, additional_meta=?, permissions=?, metadata_source=?, revision=?, entries=?, visibility=?, kind=?, rules=?, evaluated_at=?
+        WHERE id=? AND revision=?;
+    `, playlist.UserID, playlist.Title, mysqlJSON{playlist.TrackIDs}, playlist.ListenCount, playlist.FavoriteCount, playlist.Description, playlist.CreationDate, playlist.AdditionDate, mysqlJSON{playlist.Tags}, mysqlJSON{playlist.AdditionalMeta}, mysqlJSON{playlist.Permissions}, playlist.MetadataSource, playlist.Revision, mysqlJSON{playlist.Entries}, playlist.Visibility, playlist.Kind, playlist.Rules, playlist.EvaluatedAt, playlist.ID, revision)
+	if err != nil {
+		return normalizeMySQLError(err)
+	}
+	// The revision always changes, so a matched row is always reported as affected.
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return normalizeMySQLError(err)
+	}
+	if affected == 0 {
+		return ErrRevisionMismatch
+	}
+	return nil
+}
+
+func (db *MySQLDatabase) SmartPlaylistTrackIDs(
+	ctx context.Context,
+	ownerID string,
+	query rules.Query,
+	maxTracks int,
+) ([]string, error) {
+	statement, args := query.SQL(mySQLDialect{}, ownerID, maxTracks, time.Now())
+	rows, err := db.querier(ctx).QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, normalizeMySQLError(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) DeletePlaylist(ctx context.Context, id string) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `DELETE FROM playlists WHERE id=?;`, id)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) Users(ctx context.Context) ([]media.DatabaseUser, error) {
+	return db.UsersPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) UsersPage(ctx context.Context, limit, offset int) ([]media.DatabaseUser, error) {
+	var users []media.DatabaseUser
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, username, email, password_hash, display_name, description, listened_to, favorites, public_view_count, creation_date, permissions, linked_artist_id, linked_sources
+        FROM users ORDER BY id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return users, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		user, err := scanMySQLUser(rows)
+		if err != nil {
+			return users, normalizeMySQLError(err)
+		}
+		users = append(users, user)
+	}
+	return users, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) User(ctx context.Context, id string) (media.DatabaseUser, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, username, email, password_hash, display_name, description, listened_to, favorites, public_view_count, creation_date, permissions, linked_artist_id, linked_sources
+        FROM users WHERE id=?;
+    `, id)
+	user, err := scanMySQLUser(row)
+	return user, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UserByUsername(ctx context.Context, username string) (media.DatabaseUser, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, username, email, password_hash, display_name, description, listened_to, favorites, public_view_count, creation_date, permissions, linked_artist_id, linked_sources
+        FROM users WHERE username=? OR email=?;
+    `, strings.ToLower(username), strings.ToLower(username))
+	user, err := scanMySQLUser(row)
+	return user, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) CreateUser(ctx context.Context, user media.DatabaseUser) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO users (
+            id, username, email, password_hash, display_name, description, listened_to, favorites, public_view_count, creation_date, permissions, linked_artist_id, linked_sources
+        ) VALUES (
+            ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+        );
+    `, user.ID, user.Username, user.Email, user.PasswordHash, user.DisplayName, user.Description, mysqlJSON{user.ListenedTo}, mysqlJSON{user.Favorites}, user.PublicViewCount, user.CreationDate, mysqlJSON{user.Permissions}, user.LinkedArtistID, mysqlJSON{user.LinkedSources})
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UpdateUser(ctx context.Context, user media.DatabaseUser) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        UPDATE users
+        SET username=?, email=?, password_hash=?, display_name=?, description=?, listened_to=?, favorites=?, public_view_count=?, creation_date=?, permissions=?, linked_artist_id=?, linked_sources=?
+        WHERE id=?;
+    `, user.Username, user.Email, user.PasswordHash, user.DisplayName, user.Description, mysqlJSON{user.ListenedTo}, mysqlJSON{user.Favorites}, user.PublicViewCount, user.CreationDate, mysqlJSON{user.Permissions}, user.LinkedArtistID, mysqlJSON{user.LinkedSources}, user.ID)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) DeleteUser(ctx context.Context, id string) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `DELETE FROM users WHERE id=?;`, id)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) ProviderUser(
+	ctx context.Context,
+	provider, providerUserID string,
+) (media.DatabaseUser, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT u.id, u.username, u.email, u.password_hash, u.display_name, u.description, u.listened_to, u.favorites, u.public_view_count, u.creation_date, u.permissions, u.linked_artist_id, u.linked_sources
+        FROM users u
+        JOIN auth_providers p ON u.id = p.user_id
+        WHERE p.provider = ? AND p.provider_user_id = ?;
+    `, provider, providerUserID)
+	user, err := scanMySQLUser(row)
+	return user, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) IsProviderLinked(ctx context.Context, provider, userID string) (bool, error) {
+	var exists bool
+	err := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT EXISTS(
+            SELECT 1 FROM auth_providers WHERE user_id = ? AND provider = ?
+        );
+    `, userID, provider).Scan(&exists)
+	return exists, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) LinkProviderAccount(ctx context.Context, provider, userID, providerUserID string) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO auth_providers (user_id, provider, provider_user_id)
+        VALUES (?, ?, ?);
+    `, userID, provider, providerUserID)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) DisconnectProviderAccount(ctx context.Context, provider, userID string) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        DELETE FROM auth_providers WHERE user_id = ? AND provider = ?;
+    `, userID, provider)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UsernameExists(ctx context.Context, username string) (bool, error) {
+	var exists bool
+	err := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT EXISTS(SELECT 1 FROM users
+        WHERE username=?);
+    `, strings.ToLower(username)).
+		Scan(&exists)
+	return exists, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) EmailExists(ctx context.Context, email string) (bool, error) {
+	var exists bool
+	err := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT EXISTS(SELECT 1 FROM users
+        WHERE email=?);
+    `, strings.ToLower(email)).
+		Scan(&exists)
+	return exists, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AllListens(ctx context.Context) ([]media.Listen, error) {
+	return db.AllListensPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) AllListensPage(ctx context.Context, limit, offset int) ([]media.Listen, error) {
+	var listens []media.Listen
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, playable_id, playable_type, listened_at, duration_played, client, source,
+            track_name, artist_name, release_name, recording_mbid, isrc
+        FROM listens ORDER BY id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return listens, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		listen, err := scanMySQLListen(rows)
+		if err != nil {
+			return listens, normalizeMySQLError(err)
+		}
+		listens = append(listens, listen)
+	}
+	return listens, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) Listens(ctx context.Context, userID string, limit, offset int) ([]media.Listen, error) {
+	var listens []media.Listen
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, playable_id, playable_type, listened_at, duration_played, client, source,
+            track_name, artist_name, release_name, recording_mbid, isrc
+        FROM listens WHERE user_id=?
+        ORDER BY listened_at DESC, id DESC
+        LIMIT ? OFFSET ?;
+    `, userID, limit, offset)
+	if err != nil {
+		return listens, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		listen, err := scanMySQLListen(rows)
+		if err != nil {
+			return listens, normalizeMySQLError(err)
+		}
+		listens = append(listens, listen)
+	}
+	return listens, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) ListensBetween(
+	ctx context.Context,
+	userID string,
+	minTS, maxTS int64,
+	limit int,
+) ([]media.Listen, error) {
+	var listens []media.Listen
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, playable_id, playable_type, listened_at, duration_played, client, source,
+            track_name, artist_name, release_name, recording_mbid, isrc
+        FROM listens
+        WHERE user_id=? AND (? = 0 OR listened_at > ?) AND (? = 0 OR listened_at < ?)
+        ORDER BY listened_at DESC, id DESC
+        LIMIT ?;
+    `, userID, minTS, minTS, maxTS, maxTS, limit)
+	if err != nil {
+		return listens, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		listen, err := scanMySQLListen(rows)
+		if err != nil {
+			return listens, normalizeMySQLError(err)
+		}
+		listens = append(listens, listen)
+	}
+	return listens, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) CountListens(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := db.querier(ctx).QueryRowContext(ctx, `SELECT COUNT(*) FROM listens WHERE user_id=?;`, userID).Scan(&count)
+	return count, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) Listen(ctx context.Context, id string) (media.Listen, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, user_id, playable_id, playable_type, listened_at, duration_played, client, source,
+            track_name, artist_name, release_name, recording_mbid, isrc
+        FROM listens WHERE id=?;
+    `, id)
+	listen, err := scanMySQLListen(row)
+	return listen, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AddListen(ctx context.Context, listen media.Listen) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO listens (
+            id, user_id, playable_id, playable_type, listened_at, duration_played, client, source,
+            track_name, artist_name, release_name, recording_mbid, isrc
+        )
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+    `, listen.ID, listen.UserID, listen.PlayableID, listen.PlayableType, listen.ListenedAt, listen.DurationPlayed, listen.Client, listen.Source, listen.TrackName, listen.ArtistName, listen.ReleaseName, listen.RecordingMBID, listen.ISRC)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) IncrementListenCount(ctx context.Context, playableType, id string, delta int) error {
+	table, column, err := listenCountColumn(playableType)
+	if err != nil {
+		return err
+	}
+	_, err = db.querier(ctx).ExecContext(ctx,
+		`UPDATE `+table+` SET `+column+` = COALESCE(`+column+`, 0) + ? WHERE id=?;`,
+		delta,
+		id,
+	)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AllFavorites(ctx context.Context) ([]media.Favorite, error) {
+	return db.AllFavoritesPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) AllFavoritesPage(ctx context.Context, limit, offset int) ([]media.Favorite, error) {
+	var favorites []media.Favorite
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT user_id, playable_id, playable_type, favorited_at FROM favorites
+        ORDER BY user_id, playable_type, playable_id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return favorites, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		favorite, err := scanMySQLFavorite(rows)
+		if err != nil {
+			return favorites, normalizeMySQLError(err)
+		}
+		favorites = append(favorites, favorite)
+	}
+	return favorites, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) Favorites(
+	ctx context.Context,
+	userID, playableType string,
+	limit, offset int,
+) ([]media.Favorite, error) {
+	var favorites []media.Favorite
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT user_id, playable_id, playable_type, favorited_at
+        FROM favorites WHERE user_id=? AND (? = '' OR playable_type=?)
+        ORDER BY favorited_at DESC, playable_type, playable_id
+        LIMIT ? OFFSET ?;
+    `, userID, playableType, playableType, limit, offset)
+	if err != nil {
+		return favorites, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		favorite, err := scanMySQLFavorite(rows)
+		if err != nil {
+			return favorites, normalizeMySQLError(err)
+		}
+		favorites = append(favorites, favorite)
+	}
+	return favorites, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) CountFavorites(ctx context.Context, userID, playableType string) (int, error) {
+	var count int
+	err := db.querier(ctx).QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM favorites WHERE user_id=? AND (? = '' OR playable_type=?);`,
+		userID,
+		playableType,
+		playableType,
+	).Scan(&count)
+	return count, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) FavoriteIDs(ctx context.Context, userID, playableType string) ([]string, error) {
+	var ids []string
+	rows, err := db.querier(ctx).QueryContext(ctx,
+		`SELECT playable_id FROM favorites WHERE user_id=? AND playable_type=?;`,
+		userID,
+		playableType,
+	)
+	if err != nil {
+		return ids, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return ids, normalizeMySQLError(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) IsFavorite(ctx context.Context, userID, playableType, id string) (bool, error) {
+	var exists bool
+	err := db.querier(ctx).QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM favorites WHERE user_id=? AND playable_type=? AND playable_id=?);`,
+		userID,
+		playableType,
+		id,
+	).Scan(&exists)
+	return exists, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AddFavorite(ctx context.Context, favorite media.Favorite) (bool, error) {
+	result, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT IGNORE INTO favorites (user_id, playable_id, playable_type, favorited_at)
+        VALUES (?, ?, ?, ?);
+    `, favorite.UserID, favorite.PlayableID, favorite.PlayableType, favorite.FavoritedAt)
+	if err != nil {
+		return false, normalizeMySQLError(err)
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) RemoveFavorite(ctx context.Context, userID, playableType, id string) (bool, error) {
+	result, err := db.querier(ctx).ExecContext(ctx,
+		`DELETE FROM favorites WHERE user_id=? AND playable_type=? AND playable_id=?;`,
+		userID,
+		playableType,
+		id,
+	)
+	if err != nil {
+		return false, normalizeMySQLError(err)
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) RemovePlayableFavorites(ctx context.Context, playableType, id string) error {
+	_, err := db.querier(ctx).ExecContext(ctx,
+		`DELETE FROM favorites WHERE playable_type=? AND playable_id=?;`,
+		playableType,
+		id,
+	)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) IncrementFavoriteCount(ctx context.Context, playableType, id string, delta int) error {
+	table, err := favoriteCountTable(playableType)
+	if err != nil {
+		return err
+	}
+	_, err = db.querier(ctx).ExecContext(ctx,
+		`UPDATE `+table+` SET favorite_count = GREATEST(COALESCE(favorite_count, 0) + ?, 0) WHERE id=?;`,
+		delta,
+		id,
+	)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) ListenBrainzToken(ctx context.Context, userID string) (string, error) {
+	var tokenHash string
+	err := db.querier(ctx).QueryRowContext(ctx, `SELECT token_hash FROM listenbrainz_tokens WHERE user_id=?;`, userID).
+		Scan(&tokenHash)
+	return tokenHash, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) ListenBrainzTokenUserID(ctx context.Context, tokenHash string) (string, error) {
+	var userID string
+	err := db.querier(ctx).
+		QueryRowContext(ctx, `SELECT user_id FROM listenbrainz_tokens WHERE token_hash=?;`, tokenHash).
+		Scan(&userID)
+	return userID, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) SetListenBrainzToken(ctx context.Context, userID, tokenHash string, creationDate int64) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO listenbrainz_tokens (user_id, token_hash, creation_date)
+        VALUES (?, ?, ?)
+        ON DUPLICATE KEY UPDATE token_hash=VALUES(token_hash), creation_date=VALUES(creation_date);
+    `, userID, tokenHash, creationDate)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) ListenBrainzTokens(ctx context.Context) ([]ListenBrainzToken, error) {
+	return db.ListenBrainzTokensPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) ListenBrainzTokensPage(ctx context.Context, limit, offset int) ([]ListenBrainzToken, error) {
+	var tokens []ListenBrainzToken
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT user_id, token_hash, creation_date FROM listenbrainz_tokens
+        ORDER BY user_id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return tokens, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		token := ListenBrainzToken{}
+		if err := rows.Scan(&token.UserID, &token.TokenHash, &token.CreationDate); err != nil {
+			return tokens, normalizeMySQLError(err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) AllScrobbles(ctx context.Context) ([]Scrobble, error) {
+	return db.AllScrobblesPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) AllScrobblesPage(ctx context.Context, limit, offset int) ([]Scrobble, error) {
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, listen_id, scrobbler, attempts, next_attempt_at, last_error, failed, creation_date
+        FROM scrobble_queue ORDER BY id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	return collectMySQLScrobbles(rows)
+}
+
+func (db *MySQLDatabase) DueScrobbles(ctx context.Context, now int64, limit int) ([]Scrobble, error) {
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, listen_id, scrobbler, attempts, next_attempt_at, last_error, failed, creation_date
+        FROM scrobble_queue WHERE failed=FALSE AND next_attempt_at <= ?
+        ORDER BY next_attempt_at, creation_date
+        LIMIT ?;
+    `, now, limit)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	return collectMySQLScrobbles(rows)
+}
+
+func (db *MySQLDatabase) FailedScrobbles(ctx context.Context, userID string) ([]Scrobble, error) {
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, listen_id, scrobbler, attempts, next_attempt_at, last_error, failed, creation_date
+        FROM scrobble_queue WHERE user_id=? AND failed=TRUE
+        ORDER BY creation_date DESC;
+    `, userID)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	return collectMySQLScrobbles(rows)
+}
+
+func (db *MySQLDatabase) Scrobble(ctx context.Context, id string) (Scrobble, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, user_id, listen_id, scrobbler, attempts, next_attempt_at, last_error, failed, creation_date
+        FROM scrobble_queue WHERE id=?;
+    `, id)
+	scrobble, err := scanMySQLScrobble(row)
+	return scrobble, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AddScrobble(ctx context.Context, scrobble Scrobble) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO scrobble_queue (id, user_id, listen_id, scrobbler, attempts, next_attempt_at, last_error, failed, creation_date)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
+    `, scrobble.ID, scrobble.UserID, scrobble.ListenID, scrobble.Scrobbler, scrobble.Attempts, scrobble.NextAttemptAt, scrobble.LastError, scrobble.Failed, scrobble.CreationDate)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UpdateScrobble(ctx context.Context, scrobble Scrobble) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        UPDATE scrobble_queue
+        SET user_id=?, listen_id=?, scrobbler=?, attempts=?, next_attempt_at=?, last_error=?, failed=?, creation_date=?
+        WHERE id=?;
+    `, scrobble.UserID, scrobble.ListenID, scrobble.Scrobbler, scrobble.Attempts, scrobble.NextAttemptAt, scrobble.LastError, scrobble.Failed, scrobble.CreationDate, scrobble.ID)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) DeleteScrobble(ctx context.Context, id string) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `DELETE FROM scrobble_queue WHERE id=?;`, id)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) ScrobblerLinks(ctx context.Context) ([]ScrobblerLink, error) {
+	return db.ScrobblerLinksPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) ScrobblerLinksPage(ctx context.Context, limit, offset int) ([]ScrobblerLink, error) {
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT user_id, scrobbler, token, creation_date FROM scrobbler_links
+        ORDER BY user_id, scrobbler LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	return collectMySQLScrobblerLinks(rows)
+}
+
+func (db *MySQLDatabase) UserScrobblerLinks(ctx context.Context, userID string) ([]ScrobblerLink, error) {
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT user_id, scrobbler, token, creation_date FROM scrobbler_links
+        WHERE user_id=? ORDER BY scrobbler;
+    `, userID)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	return collectMySQLScrobblerLinks(rows)
+}
+
+func (db *MySQLDatabase) ScrobblerLink(ctx context.Context, userID, scrobbler string) (ScrobblerLink, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT user_id, scrobbler, token, creation_date FROM scrobbler_links WHERE user_id=? AND scrobbler=?;
+    `, userID, scrobbler)
+	link, err := scanMySQLScrobblerLink(row)
+	return link, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) SetScrobblerLink(ctx context.Context, link ScrobblerLink) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO scrobbler_links (user_id, scrobbler, token, creation_date) VALUES (?, ?, ?, ?)
+        ON DUPLICATE KEY UPDATE token=VALUES(token), creation_date=VALUES(creation_date);
+    `, link.UserID, link.Scrobbler, link.Token, link.CreationDate)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) DeleteScrobblerLink(ctx context.Context, userID, scrobbler string) error {
+	_, err := db.querier(ctx).ExecContext(ctx,
+		`DELETE FROM scrobbler_links WHERE user_id=? AND scrobbler=?;`, userID, scrobbler)
+	return normalizeMySQLError(err)
+}
+
+func collectMySQLScrobblerLinks(rows *sql.Rows) ([]ScrobblerLink, error) {
+	defer rows.Close()
+	var links []ScrobblerLink
+	for rows.Next() {
+		link, err := scanMySQLScrobblerLink(rows)
+		if err != nil {
+			return links, normalizeMySQLError(err)
+		}
+		links = append(links, link)
+	}
+	return links, normalizeMySQLError(rows.Err())
+}
+
+func scanMySQLScrobblerLink(row mysqlScanner) (ScrobblerLink, error) {
+	link := ScrobblerLink{}
+	err := row.Scan(&link.UserID, &link.Scrobbler, &link.Token, &link.CreationDate)
+	return link, err
+}
+
+func (db *MySQLDatabase) AllPlayQueues(ctx context.Context) ([]media.PlayQueue, error) {
+	return db.AllPlayQueuesPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) AllPlayQueuesPage(ctx context.Context, limit, offset int) ([]media.PlayQueue, error) {
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT user_id, items, current_index, position_ms, shuffle, repeat_mode, context_type, context_id, device, revision, updated_at
+        FROM play_queues ORDER BY user_id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	var queues []media.PlayQueue
+	for rows.Next() {
+		queue, err := scanMySQLPlayQueue(rows)
+		if err != nil {
+			return queues, normalizeMySQLError(err)
+		}
+		queues = append(queues, queue)
+	}
+	return queues, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) PlayQueue(ctx context.Context, userID string) (media.PlayQueue, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT user_id, items, current_index, position_ms, shuffle, repeat_mode, context_type, context_id, device, revision, updated_at
+        FROM play_queues WHERE user_id=?;
+    `, userID)
+	queue, err := scanMySQLPlayQueue(row)
+	return queue, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) SetPlayQueue(ctx context.Context, queue media.PlayQueue) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO play_queues (user_id, items, current_index, position_ms, shuffle, repeat_mode, context_type, context_id, device, revision, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        ON DUPLICATE KEY UPDATE items=VALUES(items), current_index=VALUES(current_index), position_ms=VALUES(position_ms), shuffle=VALUES(shuffle), repeat_mode=VALUES(repeat_mode), context_type=VALUES(context_type), context_id=VALUES(context_id), device=VALUES(device), revision=VALUES(revision), updated_at=VALUES(updated_at);
+    `, queue.UserID, mysqlJSON{queue.Items}, queue.CurrentIndex, queue.PositionMS, queue.Shuffle, queue.Repeat, queue.Context.Type, queue.Context.ID, queue.Device, queue.Revision, queue.UpdatedAt)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) SavePlayQueueAtRevision(ctx context.Context, queue media.PlayQueue, revision int) error {
+	var result sql.Result
+	var err error
+	if revision == 0 {
+		// The queue must not exist yet, so a queue another device stored in the meantime isn't replaced.
+		result, err = db.querier(ctx).ExecContext(ctx, `
+            INSERT IGNORE INTO play_queues (user_id, items, current_index, position_ms, shuffle, repeat_mode, context_type, context_id, device, revision, updated_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+        `, queue.UserID, mysqlJSON{queue.Items}, queue.CurrentIndex, queue.PositionMS, queue.Shuffle, queue.Repeat, queue.Context.Type, queue.Context.ID, queue.Device, queue.Revision, queue.UpdatedAt)
+	} else {
+		// The revision always changes, so a matched row is always reported as affected.
+		result, err = db.querier(ctx).ExecContext(ctx, `
+            UPDATE play_queues
+            SET items=?, current_index=?, position_ms=?, shuffle=?, repeat_mode=?, context_type=?, context_id=?, device=?, revision=?, updated_at=?
+            WHERE user_id=? AND revision=?;
+        `, mysqlJSON{queue.Items}, queue.CurrentIndex, queue.PositionMS, queue.Shuffle, queue.Repeat, queue.Context.Type, queue.Context.ID, queue.Device, queue.Revision, queue.UpdatedAt, queue.UserID, revision)
+	}
+	if err != nil {
+		return normalizeMySQLError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return normalizeMySQLError(err)
+	}
+	if affected == 0 {
+		return ErrRevisionMismatch
+	}
+	return nil
+}
+
+func (db *MySQLDatabase) BlacklistToken(ctx context.Context, token string, expiration time.Time) error {
+	_, err := db.querier(ctx).ExecContext(
+		ctx,
+		`INSERT INTO blacklisted_tokens (token, expiration) VALUES (?, ?);`,
+		token,
+		expiration.UTC(),
+	)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) CleanExpiredTokens(ctx context.Context) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `DELETE FROM blacklisted_tokens WHERE expiration < UTC_TIMESTAMP();`)
+	if err != nil {
+		return normalizeMySQLError(err)
+	}
+	_, err = db.querier(ctx).ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expiration < ?;`, time.Now().Unix())
+	if err != nil {
+		return normalizeMySQLError(err)
+	}
+	_, err = db.querier(ctx).ExecContext(ctx, `DELETE FROM sessions WHERE expiration < ?;`, time.Now().Unix())
+	if err != nil {
+		return normalizeMySQLError(err)
+	}
+	_, err = db.querier(ctx).ExecContext(ctx, `
+        DELETE FROM access_tokens
+        WHERE expiration != 0 AND expiration < ?;
+    `, time.Now().Unix())
+	if err != nil {
+		return normalizeMySQLError(err)
+	}
+	_, err = db.querier(ctx).ExecContext(ctx, `DELETE FROM email_tokens WHERE expiration < ?;`, time.Now().Unix())
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
+	var exists bool
+	err := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT EXISTS(SELECT 1 FROM blacklisted_tokens
+        WHERE token=?);
+    `, token).
+		Scan(&exists)
+	return exists, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) ProviderLinks(ctx context.Context) ([]ProviderLink, error) {
+	return db.ProviderLinksPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) ProviderLinksPage(ctx context.Context, limit, offset int) ([]ProviderLink, error) {
+	var links []ProviderLink
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT user_id, provider, provider_user_id FROM auth_providers
+        ORDER BY user_id, provider LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return links, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		link := ProviderLink{}
+		if err := rows.Scan(&link.UserID, &link.Provider, &link.ProviderUserID); err != nil {
+			return links, normalizeMySQLError(err)
+		}
+		links = append(links, link)
+	}
+	return links, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) BlacklistedTokens(ctx context.Context) ([]BlacklistedToken, error) {
+	return db.BlacklistedTokensPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) BlacklistedTokensPage(ctx context.Context, limit, offset int) ([]BlacklistedToken, error) {
+	var tokens []BlacklistedToken
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT token, expiration FROM blacklisted_tokens
+        ORDER BY token LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return tokens, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		token := BlacklistedToken{}
+		if err := rows.Scan(&token.Token, &token.Expiration); err != nil {
+			return tokens, normalizeMySQLError(err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) RefreshTokens(ctx context.Context) ([]RefreshToken, error) {
+	return db.RefreshTokensPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) RefreshTokensPage(ctx context.Context, limit, offset int) ([]RefreshToken, error) {
+	var tokens []RefreshToken
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT token_hash, user_id, family_id, creation_date, expiration, used_at, revoked FROM refresh_tokens
+        ORDER BY token_hash LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return tokens, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		token, err := scanMySQLRefreshToken(rows)
+		if err != nil {
+			return tokens, normalizeMySQLError(err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) RefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT token_hash, user_id, family_id, creation_date, expiration, used_at, revoked
+        FROM refresh_tokens WHERE token_hash=?;
+    `, tokenHash)
+	token, err := scanMySQLRefreshToken(row)
+	return token, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AddRefreshToken(ctx context.Context, token RefreshToken) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO refresh_tokens (token_hash, user_id, family_id, creation_date, expiration, used_at, revoked)
+        VALUES (?, ?, ?, ?, ?, ?, ?);
+    `, token.TokenHash, token.UserID, token.FamilyID, token.CreationDate, token.Expiration, token.UsedAt, token.Revoked)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UseRefreshToken(ctx context.Context, tokenHash string, usedAt int64) (bool, error) {
+	result, err := db.querier(ctx).ExecContext(ctx, `
+        UPDATE refresh_tokens SET used_at=? WHERE token_hash=? AND used_at=0 AND NOT revoked;
+    `, usedAt, tokenHash)
+	if err != nil {
+		return false, normalizeMySQLError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, normalizeMySQLError(err)
+	}
+	return affected > 0, nil
+}
+
+func (db *MySQLDatabase) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `UPDATE refresh_tokens SET revoked=TRUE WHERE family_id=?;`, familyID)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) Sessions(ctx context.Context) ([]Session, error) {
+	return db.SessionsPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) SessionsPage(ctx context.Context, limit, offset int) ([]Session, error) {
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, device_name, user_agent, ip, creation_date, last_used, expiration FROM sessions
+        ORDER BY id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	return collectMySQLSessions(rows)
+}
+
+func (db *MySQLDatabase) UserSessions(ctx context.Context, userID string) ([]Session, error) {
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, device_name, user_agent, ip, creation_date, last_used, expiration
+        FROM sessions WHERE user_id=? ORDER BY last_used DESC;
+    `, userID)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	return collectMySQLSessions(rows)
+}
+
+func (db *MySQLDatabase) Session(ctx context.Context, id string) (Session, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, user_id, device_name, user_agent, ip, creation_date, last_used, expiration
+        FROM sessions WHERE id=?;
+    `, id)
+	session, err := scanMySQLSession(row)
+	return session, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AddSession(ctx context.Context, session Session) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO sessions (id, user_id, device_name, user_agent, ip, creation_date, last_used, expiration)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+    `,
+		session.ID,
+		session.UserID,
+		session.DeviceName,
+		session.UserAgent,
+		session.IP,
+		session.CreationDate,
+		session.LastUsed,
+		session.Expiration,
+	)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UpdateSession(ctx context.Context, session Session) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        UPDATE sessions SET device_name=?, user_agent=?, ip=?, last_used=?, expiration=? WHERE id=?;
+    `, session.DeviceName, session.UserAgent, session.IP, session.LastUsed, session.Expiration, session.ID)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) DeleteSession(ctx context.Context, id string) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `DELETE FROM sessions WHERE id=?;`, id)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) TOTPSecrets(ctx context.Context) ([]TOTPSecret, error) {
+	return db.TOTPSecretsPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) TOTPSecretsPage(ctx context.Context, limit, offset int) ([]TOTPSecret, error) {
+	var secrets []TOTPSecret
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT user_id, secret, enabled, last_step, creation_date FROM totp_secrets
+        ORDER BY user_id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return secrets, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		secret, err := scanMySQLTOTPSecret(rows)
+		if err != nil {
+			return secrets, normalizeMySQLError(err)
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) TOTPSecret(ctx context.Context, userID string) (TOTPSecret, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT user_id, secret, enabled, last_step, creation_date FROM totp_secrets WHERE user_id=?;
+    `, userID)
+	secret, err := scanMySQLTOTPSecret(row)
+	return secret, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) SetTOTPSecret(ctx context.Context, secret TOTPSecret) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO totp_secrets (user_id, secret, enabled, last_step, creation_date)
+        VALUES (?, ?, ?, ?, ?)
+        ON DUPLICATE KEY UPDATE
+            secret=VALUES(secret), enabled=VALUES(enabled), last_step=VALUES(last_step),
+            creation_date=VALUES(creation_date);
+    `, secret.UserID, secret.Secret, secret.Enabled, secret.LastStep, secret.CreationDate)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	result, err := db.querier(ctx).ExecContext(ctx, `
+        UPDATE totp_secrets SET last_step=? WHERE user_id=? AND last_step<?;
+    `, step, userID, step)
+	if err != nil {
+		return false, normalizeMySQLError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, normalizeMySQLError(err)
+	}
+	return affected > 0, nil
+}
+
+func (db *MySQLDatabase) DeleteTOTPSecret(ctx context.Context, userID string) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `DELETE FROM totp_secrets WHERE user_id=?;`, userID)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) RecoveryCodes(ctx context.Context) ([]RecoveryCode, error) {
+	return db.RecoveryCodesPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) RecoveryCodesPage(ctx context.Context, limit, offset int) ([]RecoveryCode, error) {
+	var codes []RecoveryCode
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT user_id, code_hash FROM recovery_codes
+        ORDER BY user_id, code_hash LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return codes, normalizeMySQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		code := RecoveryCode{}
+		if err := rows.Scan(&code.UserID, &code.CodeHash); err != nil {
+			return codes, normalizeMySQLError(err)
+		}
+		codes = append(codes, code)
+	}
+	return codes, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) AddRecoveryCode(ctx context.Context, code RecoveryCode) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?);
+    `, code.UserID, code.CodeHash)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	result, err := db.querier(ctx).ExecContext(ctx, `
+        DELETE FROM recovery_codes WHERE user_id=? AND code_hash=?;
+    `, userID, codeHash)
+	if err != nil {
+		return false, normalizeMySQLError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, normalizeMySQLError(err)
+	}
+	return affected > 0, nil
+}
+
+func (db *MySQLDatabase) DeleteRecoveryCodes(ctx context.Context, userID string) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id=?;`, userID)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) WebAuthnCredentials(ctx context.Context) ([]WebAuthnCredential, error) {
+	return db.WebAuthnCredentialsPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) WebAuthnCredentialsPage(ctx context.Context, limit, offset int) ([]WebAuthnCredential, error) {
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, name, credential, creation_date, last_used FROM webauthn_credentials
+        ORDER BY id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	return collectMySQLWebAuthnCredentials(rows)
+}
+
+func (db *MySQLDatabase) UserWebAuthnCredentials(ctx context.Context, userID string) ([]WebAuthnCredential, error) {
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, name, credential, creation_date, last_used
+        FROM webauthn_credentials WHERE user_id=? ORDER BY creation_date;
+    `, userID)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	return collectMySQLWebAuthnCredentials(rows)
+}
+
+func (db *MySQLDatabase) WebAuthnCredential(ctx context.Context, id string) (WebAuthnCredential, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, user_id, name, credential, creation_date, last_used FROM webauthn_credentials WHERE id=?;
+    `, id)
+	credential, err := scanMySQLWebAuthnCredential(row)
+	return credential, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AddWebAuthnCredential(ctx context.Context, credential WebAuthnCredential) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO webauthn_credentials (id, user_id, name, credential, creation_date, last_used)
+        VALUES (?, ?, ?, ?, ?, ?);
+    `,
+		credential.ID,
+		credential.UserID,
+		credential.Name,
+		credential.Credential,
+		credential.CreationDate,
+		credential.LastUsed,
+	)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UpdateWebAuthnCredential(ctx context.Context, credential WebAuthnCredential) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        UPDATE webauthn_credentials SET name=?, credential=?, last_used=? WHERE id=?;
+    `, credential.Name, credential.Credential, credential.LastUsed, credential.ID)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) DeleteWebAuthnCredential(ctx context.Context, id string) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `DELETE FROM webauthn_credentials WHERE id=?;`, id)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AccessTokens(ctx context.Context) ([]AccessToken, error) {
+	return db.AccessTokensPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) AccessTokensPage(ctx context.Context, limit, offset int) ([]AccessToken, error) {
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, name, token_hash, scopes, creation_date, expiration, last_used FROM access_tokens
+        ORDER BY id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	return collectMySQLAccessTokens(rows)
+}
+
+func (db *MySQLDatabase) UserAccessTokens(ctx context.Context, userID string) ([]AccessToken, error) {
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, name, token_hash, scopes, creation_date, expiration, last_used
+        FROM access_tokens WHERE user_id=? ORDER BY creation_date;
+    `, userID)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	return collectMySQLAccessTokens(rows)
+}
+
+func (db *MySQLDatabase) AccessToken(ctx context.Context, id string) (AccessToken, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, user_id, name, token_hash, scopes, creation_date, expiration, last_used FROM access_tokens WHERE id=?;
+    `, id)
+	token, err := scanMySQLAccessToken(row)
+	return token, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AccessTokenByHash(ctx context.Context, tokenHash string) (AccessToken, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, user_id, name, token_hash, scopes, creation_date, expiration, last_used FROM access_tokens WHERE token_hash=?;
+    `, tokenHash)
+	token, err := scanMySQLAccessToken(row)
+	return token, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AddAccessToken(ctx context.Context, token AccessToken) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO access_tokens (id, user_id, name, token_hash, scopes, creation_date, expiration, last_used)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+    `,
+		token.ID,
+		token.UserID,
+		token.Name,
+		token.TokenHash,
+		mysqlJSON{token.Scopes},
+		token.CreationDate,
+		token.Expiration,
+		token.LastUsed,
+	)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UpdateAccessToken(ctx context.Context, token AccessToken) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        UPDATE access_tokens SET name=?, scopes=?, expiration=?, last_used=? WHERE id=?;
+    `, token.Name, mysqlJSON{token.Scopes}, token.Expiration, token.LastUsed, token.ID)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) DeleteAccessToken(ctx context.Context, id string) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `DELETE FROM access_tokens WHERE id=?;`, id)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) EmailTokens(ctx context.Context) ([]EmailToken, error) {
+	return db.EmailTokensPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) EmailTokensPage(ctx context.Context, limit, offset int) ([]EmailToken, error) {
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, user_id, purpose, token_hash, email, creation_date, expiration FROM email_tokens
+        ORDER BY id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	var tokens []EmailToken
+	defer rows.Close()
+	for rows.Next() {
+		token, err := scanMySQLEmailToken(rows)
+		if err != nil {
+			return tokens, normalizeMySQLError(err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) EmailTokenByHash(ctx context.Context, tokenHash string) (EmailToken, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, user_id, purpose, token_hash, email, creation_date, expiration FROM email_tokens WHERE token_hash=?;
+    `, tokenHash)
+	token, err := scanMySQLEmailToken(row)
+	return token, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AddEmailToken(ctx context.Context, token EmailToken) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO email_tokens (id, user_id, purpose, token_hash, email, creation_date, expiration)
+        VALUES (?, ?, ?, ?, ?, ?, ?);
+    `,
+		token.ID,
+		token.UserID,
+		token.Purpose,
+		token.TokenHash,
+		token.Email,
+		token.CreationDate,
+		token.Expiration,
+	)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) UseEmailToken(ctx context.Context, id string) (bool, error) {
+	result, err := db.querier(ctx).ExecContext(ctx, `DELETE FROM email_tokens WHERE id=?;`, id)
+	if err != nil {
+		return false, normalizeMySQLError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, normalizeMySQLError(err)
+	}
+	return affected > 0, nil
+}
+
+func (db *MySQLDatabase) DeleteEmailTokens(ctx context.Context, userID, purpose string) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        DELETE FROM email_tokens
+        WHERE user_id=? AND purpose=?;
+    `, userID, purpose)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) EmailVerifications(ctx context.Context) ([]EmailVerification, error) {
+	return db.EmailVerificationsPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) EmailVerificationsPage(ctx context.Context, limit, offset int) ([]EmailVerification, error) {
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT user_id, email, verification_date FROM email_verifications
+        ORDER BY user_id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	var verifications []EmailVerification
+	defer rows.Close()
+	for rows.Next() {
+		verification, err := scanMySQLEmailVerification(rows)
+		if err != nil {
+			return verifications, normalizeMySQLError(err)
+		}
+		verifications = append(verifications, verification)
+	}
+	return verifications, normalizeMySQLError(rows.Err())
+}
+
+func (db *MySQLDatabase) EmailVerification(ctx context.Context, userID string) (EmailVerification, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT user_id, email, verification_date FROM email_verifications WHERE user_id=?;
+    `, userID)
+	verification, err := scanMySQLEmailVerification(row)
+	return verification, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) SetEmailVerification(ctx context.Context, verification EmailVerification) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO email_verifications (user_id, email, verification_date) VALUES (?, ?, ?)
+        ON DUPLICATE KEY UPDATE email=VALUES(email), verification_date=VALUES(verification_date);
+    `, verification.UserID, verification.Email, verification.VerificationDate)
+	return normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AllAuditEvents(ctx context.Context) ([]AuditEvent, error) {
+	return db.AllAuditEventsPage(ctx, -1, 0)
+}
+
+func (db *MySQLDatabase) AllAuditEventsPage(ctx context.Context, limit, offset int) ([]AuditEvent, error) {
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, action, user_id, username, ip, details, creation_date FROM audit_log
+        ORDER BY id LIMIT ? OFFSET ?;
+    `, mySQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	return collectMySQLAuditEvents(rows)
+}
+
+func (db *MySQLDatabase) AuditEvents(ctx context.Context, limit, offset int) ([]AuditEvent, error) {
+	rows, err := db.querier(ctx).QueryContext(ctx, `
+        SELECT id, action, user_id, username, ip, details, creation_date FROM audit_log
+        ORDER BY creation_date DESC, id DESC
+        LIMIT ? OFFSET ?;
+    `, limit, offset)
+	if err != nil {
+		return nil, normalizeMySQLError(err)
+	}
+	return collectMySQLAuditEvents(rows)
+}
+
+func (db *MySQLDatabase) AuditEvent(ctx context.Context, id string) (AuditEvent, error) {
+	row := db.querier(ctx).QueryRowContext(ctx, `
+        SELECT id, action, user_id, username, ip, details, creation_date FROM audit_log WHERE id=?;
+    `, id)
+	event, err := scanMySQLAuditEvent(row)
+	return event, normalizeMySQLError(err)
+}
+
+func (db *MySQLDatabase) AddAuditEvent(ctx context.Context, event AuditEvent) error {
+	_, err := db.querier(ctx).ExecContext(ctx, `
+        INSERT INTO audit_log (id, action, user_id, username, ip, details, creation_date)
+        VALUES (?, ?, ?, ?, ?, ?, ?);
+    `,
+		event.ID,
+		event.Action,
+		event.UserID,
+		event.Username,
+		event.IP,
+		event.Details,
+		event.CreationDate,
+	)
+	return normalizeMySQLError(err)
+}
+
+func scanMySQLRefreshToken(row mysqlScanner) (RefreshToken, error) {
+	token := RefreshToken{}
+	err := row.Scan(
+		&token.TokenHash,
+		&token.UserID,
+		&token.FamilyID,
+		&token.CreationDate,
+		&token.Expiration,
+		&token.UsedAt,
+		&token.Revoked,
+	)
+	return token, err
+}
+
+func scanMySQLTOTPSecret(row mysqlScanner) (TOTPSecret, error) {
+	secret := TOTPSecret{}
+	err := row.Scan(&secret.UserID, &secret.Secret, &secret.Enabled, &secret.LastStep, &secret.CreationDate)
+	return secret, err
+}
+
+func scanMySQLWebAuthnCredential(row mysqlScanner) (WebAuthnCredential, error) {
+	credential := WebAuthnCredential{}
+	err := row.Scan(
+		&credential.ID,
+		&credential.UserID,
+		&credential.Name,
+		&credential.Credential,
+		&credential.CreationDate,
+		&credential.LastUsed,
+	)
+	return credential, err
+}
+
+func collectMySQLWebAuthnCredentials(rows *sql.Rows) ([]WebAuthnCredential, error) {
+	var credentials []WebAuthnCredential
+	defer rows.Close()
+	for rows.Next() {
+		credential, err := scanMySQLWebAuthnCredential(rows)
+		if err != nil {
+			return credentials, normalizeMySQLError(err)
+		}
+		credentials = append(credentials, credential)
+	}
+	return credentials, normalizeMySQLError(rows.Err())
+}
+
+func scanMySQLAccessToken(row mysqlScanner) (AccessToken, error) {
+	token := AccessToken{}
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.TokenHash,
+		mysqlJSON{&token.Scopes},
+		&token.CreationDate,
+		&token.Expiration,
+		&token.LastUsed,
+	)
+	return token, err
+}
+
+func collectMySQLAccessTokens(rows *sql.Rows) ([]AccessToken, error) {
+	var tokens []AccessToken
+	defer rows.Close()
+	for rows.Next() {
+		token, err := scanMySQLAccessToken(rows)
+		if err != nil {
+			return tokens, normalizeMySQLError(err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, normalizeMySQLError(rows.Err())
+}
+
+func scanMySQLEmailToken(row mysqlScanner) (EmailToken, error) {
+	token := EmailToken{}
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Purpose,
+		&token.TokenHash,
+		&token.Email,
+		&token.CreationDate,
+		&token.Expiration,
+	)
+	return token, err
+}
+
+func scanMySQLEmailVerification(row mysqlScanner) (EmailVerification, error) {
+	verification := EmailVerification{}
+	err := row.Scan(&verification.UserID, &verification.Email, &verification.VerificationDate)
+	return verification, err
+}
+
+func scanMySQLAuditEvent(row mysqlScanner) (AuditEvent, error) {
+	event := AuditEvent{}
+	err := row.Scan(
+		&event.ID,
+		&event.Action,
+		&event.UserID,
+		&event.Username,
+		&event.IP,
+		&event.Details,
+		&event.CreationDate,
+	)
+	return event, err
+}
+
+func collectMySQLAuditEvents(rows *sql.Rows) ([]AuditEvent, error) {
+	var events []AuditEvent
+	defer rows.Close()
+	for rows.Next() {
+		event, err := scanMySQLAuditEvent(rows)
+		if err != nil {
+			return events, normalizeMySQLError(err)
+		}
+		events = append(events, event)
+	}
+	return events, normalizeMySQLError(rows.Err())
+}
+
+func scanMySQLSession(row mysqlScanner) (Session, error) {
+	session := Session{}
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.DeviceName,
+		&session.UserAgent,
+		&session.IP,
+		&session.CreationDate,
+		&session.LastUsed,
+		&session.Expiration,
+	)
+	return session, err
+}
+
+func collectMySQLSessions(rows *sql.Rows) ([]Session, error) {
+	var sessions []Session
+	defer rows.Close()
+	for rows.Next() {
+		session, err := scanMySQLSession(rows)
+		if err != nil {
+			return sessions, normalizeMySQLError(err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, normalizeMySQLError(rows.Err())
+}
+
+// mysqlScanner is implemented by both *sql.Row and *sql.Rows.
+type mysqlScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMySQLTrack(row mysqlScanner) (media.Track, error) {
+	track := media.Track{}
+	err := row.Scan(
+		&track.ID,
+		&track.UserID,
+		&track.ISRC,
+		&track.Title,
+		mysqlJSON{&track.ArtistIDs},
+		mysqlJSON{&track.AlbumIDs},
+		&track.PrimaryAlbumID,
+		&track.TrackNumber,
+		&track.Duration,
+		&track.Description,
+		&track.ReleaseDate,
+		mysqlJSON{&track.Lyrics},
+		&track.ListenCount,
+		&track.FavoriteCount,
+		&track.AdditionDate,
+		mysqlJSON{&track.Tags},
+		mysqlJSON{&track.AdditionalMeta},
+		mysqlJSON{&track.Permissions},
+		mysqlJSON{&track.LinkedItemIDs},
+		&track.ContentSource,
+		&track.MetadataSource,
+		mysqlJSON{&track.LyricSources},
+	)
+	return track, err
+}
+
+func scanMySQLAlbum(row mysqlScanner) (media.Album, error) {
+	album := media.Album{}
+	err := row.Scan(
+		&album.ID,
+		&album.UserID,
+		&album.UPC,
+		&album.EAN,
+		&album.Title,
+		mysqlJSON{&album.ArtistIDs},
+		mysqlJSON{&album.TrackIDs},
+		&album.Description,
+		&album.ReleaseDate,
+		&album.ListenCount,
+		&album.FavoriteCount,
+		&album.AdditionDate,
+		mysqlJSON{&album.Tags},
+		mysqlJSON{&album.AdditionalMeta},
+		mysqlJSON{&album.Permissions},
+		mysqlJSON{&album.LinkedItemIDs},
+		&album.MetadataSource,
+	)
+	return album, err
+}
+
+func scanMySQLVideo(row mysqlScanner) (media.Video, error) {
+	video := media.Video{}
+	err := row.Scan(
+		&video.ID,
+		&video.UserID,
+		&video.Title,
+		mysqlJSON{&video.ArtistIDs},
+		&video.Duration,
+		&video.Description,
+		&video.ReleaseDate,
+		mysqlJSON{&video.Subtitles},
+		&video.WatchCount,
+		&video.FavoriteCount,
+		&video.AdditionDate,
+		mysqlJSON{&video.Tags},
+		mysqlJSON{&video.AdditionalMeta},
+		mysqlJSON{&video.Permissions},
+		mysqlJSON{&video.LinkedItemIDs},
+		&video.ContentSource,
+		&video.MetadataSource,
+		mysqlJSON{&video.LyricSources},
+	)
+	return video, err
+}
+
+func scanMySQLArtist(row mysqlScanner) (media.Artist, error) {
+	artist := media.Artist{}
+	err := row.Scan(
+		&artist.ID,
+		&artist.UserID,
+		&artist.Name,
+		mysqlJSON{&artist.AlbumIDs},
+		mysqlJSON{&artist.TrackIDs},
+		&artist.Description,
+		&artist.CreationDate,
+		&artist.ListenCount,
+		&artist.FavoriteCount,
+		&artist.AdditionDate,
+		mysqlJSON{&artist.Tags},
+		mysqlJSON{&artist.AdditionalMeta},
+		mysqlJSON{&artist.Permissions},
+		mysqlJSON{&artist.LinkedItemIDs},
+		&artist.MetadataSource,
+	)
+	return artist, err
+}
+
+func scanMySQLPlaylist(row mysqlScanner) (media.Playlist, error) {
+	playlist := media.Playlist{}
+	err := row.Scan(
+		&playlist.ID,
+		&playlist.UserID,
+		&playlist.Title,
+		mysqlJSON{&playlist.TrackIDs},
+		&playlist.ListenCount,
+		&playlist.FavoriteCount,
+		&playlist.Description,
+		&playlist.CreationDate,
+		&playlist.AdditionDate,
+		mysqlJSON{&playlist.Tags},
+		mysqlJSON{&playlist.AdditionalMeta},
+		mysqlJSON{&playlist.Permissions},
+		&playlist.MetadataSource,
+		&playlist.Revision,
+		mysqlJSON{&playlist.Entries},
+		&playlist.Visibility,
+		&playlist.Kind,
+		&playlist.Rules,
+		&playlist.EvaluatedAt,
+	)
+	return playlist, err
+}
+
+func scanMySQLListen(row mysqlScanner) (media.Listen, error) {
+	listen := media.Listen{}
+	err := row.Scan(
+		&listen.ID,
+		&listen.UserID,
+		&listen.PlayableID,
+		&listen.PlayableType,
+		&listen.ListenedAt,
+		&listen.DurationPlayed,
+		&listen.Client,
+		&listen.Source,
+		&listen.TrackName,
+		&listen.ArtistName,
+		&listen.ReleaseName,
+		&listen.RecordingMBID,
+		&listen.ISRC,
+	)
+	return listen, err
+}
+
+func scanMySQLFavorite(row mysqlScanner) (media.Favorite, error) {
+	favorite := media.Favorite{}
+	err := row.Scan(&favorite.UserID, &favorite.PlayableID, &favorite.PlayableType, &favorite.FavoritedAt)
+	return favorite, err
+}
+
+func scanMySQLScrobble(row mysqlScanner) (Scrobble, error) {
+	scrobble := Scrobble{}
+	err := row.Scan(
+		&scrobble.ID,
+		&scrobble.UserID,
+		&scrobble.ListenID,
+		&scrobble.Scrobbler,
+		&scrobble.Attempts,
+		&scrobble.NextAttemptAt,
+		&scrobble.LastError,
+		&scrobble.Failed,
+		&scrobble.CreationDate,
+	)
+	return scrobble, err
+}
+
+func collectMySQLScrobbles(rows *sql.Rows) ([]Scrobble, error) {
+	var scrobbles []Scrobble
+	defer rows.Close()
+	for rows.Next() {
+		scrobble, err := scanMySQLScrobble(rows)
+		if err != nil {
+			return scrobbles, normalizeMySQLError(err)
+		}
+		scrobbles = append(scrobbles, scrobble)
+	}
+	return scrobbles, normalizeMySQLError(rows.Err())
+}
+
+func scanMySQLPlayQueue(row mysqlScanner) (media.PlayQueue, error) {
+	queue := media.PlayQueue{}
+	err := row.Scan(
+		&queue.UserID,
+		mysqlJSON{&queue.Items},
+		&queue.CurrentIndex,
+		&queue.PositionMS,
+		&queue.Shuffle,
+		&queue.Repeat,
+		&queue.Context.Type,
+		&queue.Context.ID,
+		&queue.Device,
+		&queue.Revision,
+		&queue.UpdatedAt,
+	)
+	return queue, err
+}
+
+func scanMySQLUser(row mysqlScanner) (media.DatabaseUser, error) {
+	user := media.DatabaseUser{}
+	err := row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.PasswordHash,
+		&user.DisplayName,
+		&user.Description,
+		mysqlJSON{&user.ListenedTo},
+		mysqlJSON{&user.Favorites},
+		&user.PublicViewCount,
+		&user.CreationDate,
+		mysqlJSON{&user.Permissions},
+		&user.LinkedArtistID,
+		mysqlJSON{&user.LinkedSources},
+	)
+	return user, err
+}
+
+// mysqlJSON stores map and slice fields in JSON columns.
+// When scanning, v must be a pointer to the destination.
+type mysqlJSON struct {
+	v any
+}
+
+func (j mysqlJSON) Value() (driver.Value, error) {
+	data, err := json.Marshal(j.v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (j mysqlJSON) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(src, j.v)
+	case string:
+		return json.Unmarshal([]byte(src), j.v)
+	default:
+		return fmt.Errorf("unsupported JSON column type %T", src)
+	}
+}
+
+func normalizeMySQLError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func init() {
+	db := &MySQLDatabase{}
+	Registry["mysql"] = db
+}
+
+type mySQLDialect struct{}
+
+func (mySQLDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (mySQLDialect) ListContains(column, value string) string {
+	return "JSON_CONTAINS(COALESCE(" + column + ", JSON_ARRAY()), JSON_QUOTE(" + value + "))"
+}
+
+func (mySQLDialect) ContainsFold(expr, value string) string {
+	return "INSTR(LOWER(" + expr + "), LOWER(" + value + ")) > 0"
+}
+
+func (mySQLDialect) Random() string {
+	return "RAND()"
+}
@@ -15,15 +15,18 @@ import (
 
 	"github.com/charmbracelet/log"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/libramusic/libracore/config"
 	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/rules"
 )
 
 type PostgreSQLDatabase struct {
 	pool      *pgxpool.Pool
 	closeOnce sync.Once
+	dsn       string
 }
 
 func (*PostgreSQLDatabase) EngineName() string {
@@ -40,6 +43,10 @@ func (*PostgreSQLDatabase) Satisfies(engine string) bool {
 	}, strings.ToLower(engine))
 }
 
+func (*PostgreSQLDatabase) WithDSN(dsn string) Database {
+	return &PostgreSQLDatabase{dsn: dsn}
+}
+
 func (db *PostgreSQLDatabase) Connect() error {
 	log.Info("Connecting to PostgreSQL...")
 	connStr := fmt.Sprintf(
@@ -51,6 +58,9 @@ func (db *PostgreSQLDatabase) Connect() error {
 		config.Conf.Database.PostgreSQL.DBName,
 		config.Conf.Database.PostgreSQL.Params,
 	)
+	if db.dsn != "" {
+		connStr = db.dsn
+	}
 	pool, err := pgxpool.New(context.Background(), connStr)
 	db.pool = pool
 	if err != nil {
@@ -82,9 +92,56 @@ func (db *PostgreSQLDatabase) Close() error {
 	return nil
 }
 
+// postgreSQLTxKey is the context key of a transaction of db.
+type postgreSQLTxKey struct{ db *PostgreSQLDatabase }
+
+// postgreSQLQuerier runs queries on the pool or in a transaction.
+type postgreSQLQuerier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func (db *PostgreSQLDatabase) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(postgreSQLTxKey{db}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		return fn(context.WithValue(ctx, postgreSQLTxKey{db}, tx))
+	})
+	return normalizePostgreSQLError(err)
+}
+
+// querier returns the transaction ctx is in, or the pool if it isn't in one.
+func (db *PostgreSQLDatabase) querier(ctx context.Context) postgreSQLQuerier {
+	if tx, ok := ctx.Value(postgreSQLTxKey{db}).(pgx.Tx); ok {
+		return tx
+	}
+	return db.pool
+}
+
+// postgreSQLLimit returns the argument of a LIMIT clause, which is NULL for a negative limit so every row is returned.
+func postgreSQLLimit(limit int) any {
+	if limit < 0 {
+		return nil
+	}
+	return limit
+}
+
+func (db *PostgreSQLDatabase) CountRows(ctx context.Context, table string) (int, error) {
+	if err := checkTable(table); err != nil {
+		return 0, err
+	}
+	var count int
+	err := db.querier(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM `+table+`;`).Scan(&count)
+	return count, normalizePostgreSQLError(err)
+}
+
 func (db *PostgreSQLDatabase) migrationsTableExists(ctx context.Context) (bool, error) {
 	var exists bool
-	err := db.pool.QueryRow(ctx, `
+	err := db.querier(ctx).QueryRow(ctx, `
         SELECT EXISTS (
             SELECT 1 FROM information_schema.tables
             WHERE table_name = 'schema_migrations'
@@ -97,7 +154,7 @@ func (db *PostgreSQLDatabase) migrationsTableExists(ctx context.Context) (bool,
 }
 
 func (db *PostgreSQLDatabase) createMigrationsTable(ctx context.Context) error {
-	_, err := db.pool.Exec(ctx, `
+	_, err := db.querier(ctx).Exec(ctx, `
         CREATE TABLE IF NOT EXISTS schema_migrations (
             version BIGINT PRIMARY KEY,
             dirty BOOLEAN
@@ -109,7 +166,7 @@ func (db *PostgreSQLDatabase) createMigrationsTable(ctx context.Context) error {
 func (db *PostgreSQLDatabase) currentVersion(ctx context.Context) (uint64, bool, error) {
 	var version uint64
 	var dirty bool
-	err := db.pool.QueryRow(ctx, `
+	err := db.querier(ctx).QueryRow(ctx, `
         SELECT version, dirty FROM schema_migrations 
         ORDER BY version DESC LIMIT 1;
     `).Scan(&version, &dirty)
@@ -121,13 +178,34 @@ func (db *PostgreSQLDatabase) currentVersion(ctx context.Context) (uint64, bool,
 }
 
 func (db *PostgreSQLDatabase) setVersion(ctx context.Context, version uint64, dirty bool) error {
-	_, err := db.pool.Exec(ctx, `
-        DELETE FROM schema_migrations;
-        INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2);
-    `, version, dirty)
+	// Statements with arguments are prepared, and a prepared statement can only hold one command.
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations;`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+            INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2);
+        `, version, dirty)
+		return err
+	})
 	return normalizePostgreSQLError(err)
 }
 
+func (*PostgreSQLDatabase) MigrationsDir() string {
+	return "migrations/postgresql"
+}
+
+func (db *PostgreSQLDatabase) ForceVersion(ctx context.Context, version uint64) error {
+	if err := db.createMigrationsTable(ctx); err != nil {
+		return err
+	}
+	return db.setVersion(ctx, version, false)
+}
+
+func (db *PostgreSQLDatabase) MigrationVersion(ctx context.Context) (uint64, bool, error) {
+	return db.currentVersion(ctx)
+}
+
 func (db *PostgreSQLDatabase) MigrateUp(steps int) error {
 	if err := db.createMigrationsTable(context.Background()); err != nil {
 		return err
@@ -141,7 +219,7 @@ func (db *PostgreSQLDatabase) MigrateUp(steps int) error {
 		return errors.New("database is in dirty state")
 	}
 
-	entries, err := migrationsFS.ReadDir("migrations/postgresql")
+	entries, err := migrationsFS.ReadDir(db.MigrationsDir())
 	if err != nil {
 		return err
 	}
@@ -169,7 +247,7 @@ func (db *PostgreSQLDatabase) MigrateUp(steps int) error {
 		}
 
 		// Read and execute migration.
-		content, err := migrationsFS.ReadFile(filepath.Join("migrations/postgresql", file))
+		content, err := migrationsFS.ReadFile(filepath.Join(db.MigrationsDir(), file))
 		if err != nil {
 			return err
 		}
@@ -203,14 +281,14 @@ func (db *PostgreSQLDatabase) MigrateDown(steps int) error {
 		return errors.New("database is in dirty state")
 	}
 
-	entries, err := migrationsFS.ReadDir("migrations/postgresql")
+	entries, err := migrationsFS.ReadDir(db.MigrationsDir())
 	if err != nil {
 		return err
 	}
 	files := OrderedMigrationFiles(entries, false)
 
 	appliedCount := 0
-	for _, file := range files {
+	for i, file := range files {
 		versionStr := strings.Split(file, "_")[0]
 		version, err := strconv.ParseUint(versionStr, 10, 64)
 		if err != nil {
@@ -231,7 +309,7 @@ func (db *PostgreSQLDatabase) MigrateDown(steps int) error {
 		}
 
 		// Read and execute migration.
-		content, err := migrationsFS.ReadFile(filepath.Join("migrations/postgresql", file))
+		content, err := migrationsFS.ReadFile(filepath.Join(db.MigrationsDir(), file))
 		if err != nil {
 			return err
 		}
@@ -243,8 +321,8 @@ func (db *PostgreSQLDatabase) MigrateDown(steps int) error {
 
 		// Set version to previous migration and clear dirty flag.
 		prevVersion := uint64(0)
-		if appliedCount < len(files)-1 {
-			prevVersionStr := strings.Split(files[appliedCount+1], "_")[0]
+		if i < len(files)-1 {
+			prevVersionStr := strings.Split(files[i+1], "_")[0]
 			prevVersion, err = strconv.ParseUint(prevVersionStr, 10, 64)
 			if err != nil {
 				return err
@@ -261,11 +339,15 @@ func (db *PostgreSQLDatabase) MigrateDown(steps int) error {
 }
 
 func (db *PostgreSQLDatabase) AllTracks(ctx context.Context) ([]media.Track, error) {
+	return db.AllTracksPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) AllTracksPage(ctx context.Context, limit, offset int) ([]media.Track, error) {
 	var tracks []media.Track
-	rows, err := db.pool.Query(ctx, `
+	rows, err := db.querier(ctx).Query(ctx, `
         SELECT id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
-        FROM tracks;
-    `)
+        FROM tracks ORDER BY id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
 	if err != nil {
 		return tracks, normalizePostgreSQLError(err)
 	}
@@ -306,7 +388,7 @@ func (db *PostgreSQLDatabase) AllTracks(ctx context.Context) ([]media.Track, err
 
 func (db *PostgreSQLDatabase) Tracks(ctx context.Context, userID string) ([]media.Track, error) {
 	var tracks []media.Track
-	rows, err := db.pool.Query(ctx, `
+	rows, err := db.querier(ctx).Query(ctx, `
         SELECT id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
         FROM tracks WHERE user_id=$1;
     `, userID)
@@ -350,7 +432,7 @@ func (db *PostgreSQLDatabase) Tracks(ctx context.Context, userID string) ([]medi
 
 func (db *PostgreSQLDatabase) Track(ctx context.Context, id string) (media.Track, error) {
 	track := media.Track{}
-	row := db.pool.QueryRow(ctx, `
+	row := db.querier(ctx).QueryRow(ctx, `
         SELECT id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
         FROM tracks WHERE id=$1;
     `, id)
@@ -382,8 +464,46 @@ func (db *PostgreSQLDatabase) Track(ctx context.Context, id string) (media.Track
 	return track, normalizePostgreSQLError(err)
 }
 
+func (db *PostgreSQLDatabase) TrackByRecordingMBID(ctx context.Context, mbid string) (media.Track, error) {
+	row := db.querier(ctx).QueryRow(ctx, `
+        SELECT id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
+        FROM tracks WHERE lower(additional_meta->>'musicbrainz_recording_id')=lower($1) ORDER BY id LIMIT 1;
+    `, mbid)
+	track, err := scanPostgreSQLTrack(row)
+	return track, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) TrackByISRC(ctx context.Context, isrc string) (media.Track, error) {
+	row := db.querier(ctx).QueryRow(ctx, `
+        SELECT id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
+        FROM tracks WHERE upper(isrc)=upper($1) ORDER BY id LIMIT 1;
+    `, isrc)
+	track, err := scanPostgreSQLTrack(row)
+	return track, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) TracksByTitle(ctx context.Context, title string) ([]media.Track, error) {
+	var tracks []media.Track
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
+        FROM tracks WHERE lower(title)=lower($1) ORDER BY id;
+    `, title)
+	if err != nil {
+		return tracks, normalizePostgreSQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		track, err := scanPostgreSQLTrack(rows)
+		if err != nil {
+			return tracks, normalizePostgreSQLError(err)
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, normalizePostgreSQLError(rows.Err())
+}
+
 func (db *PostgreSQLDatabase) AddTrack(ctx context.Context, track media.Track) error {
-	_, err := db.pool.Exec(ctx, `
+	_, err := db.querier(ctx).Exec(ctx, `
         INSERT INTO tracks (
             id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
         ) VALUES (
@@ -394,7 +514,7 @@ func (db *PostgreSQLDatabase) AddTrack(ctx context.Context, track media.Track) e
 }
 
 func (db *PostgreSQLDatabase) UpdateTrack(ctx context.Context, track media.Track) error {
-	_, err := db.pool.Exec(ctx, `
+	_, err := db.querier(ctx).Exec(ctx, `
         UPDATE tracks
         SET user_id=$2, isrc=$3, title=$4, artist_ids=$5, album_ids=$6, primary_album_id=$7, track_number=$8, duration=$9, description=$10, release_date=$11, lyrics=$12, listen_count=$13, favorite_count=$14, addition_date=$15, tags=$16, additional_meta=$17, permissions=$18, linked_item_ids=$19, content_source=$20, metadata_source=$21, lyric_sources=$22
         WHERE id=$1;
@@ -403,16 +523,20 @@ func (db *PostgreSQLDatabase) UpdateTrack(ctx context.Context, track media.Track
 }
 
 func (db *PostgreSQLDatabase) DeleteTrack(ctx context.Context, id string) error {
-	_, err := db.pool.Exec(ctx, `DELETE FROM tracks WHERE id=$1;`, id)
+	_, err := db.querier(ctx).Exec(ctx, `DELETE FROM tracks WHERE id=$1;`, id)
 	return normalizePostgreSQLError(err)
 }
 
 func (db *PostgreSQLDatabase) AllAlbums(ctx context.Context) ([]media.Album, error) {
+	return db.AllAlbumsPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) AllAlbumsPage(ctx context.Context, limit, offset int) ([]media.Album, error) {
 	var albums []media.Album
-	rows, err := db.pool.Query(ctx, `
+	rows, err := db.querier(ctx).Query(ctx, `
         SELECT id, user_id, upc, ean, title, artist_ids, track_ids, description, release_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
-        FROM albums;
-    `)
+        FROM albums ORDER BY id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
 	if err != nil {
 		return albums, normalizePostgreSQLError(err)
 	}
@@ -448,7 +572,7 @@ func (db *PostgreSQLDatabase) AllAlbums(ctx context.Context) ([]media.Album, err
 
 func (db *PostgreSQLDatabase) Albums(ctx context.Context, userID string) ([]media.Album, error) {
 	var albums []media.Album
-	rows, err := db.pool.Query(ctx, `
+	rows, err := db.querier(ctx).Query(ctx, `
         SELECT id, user_id, upc, ean, title, artist_ids, track_ids, description, release_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
         FROM albums WHERE user_id=$1;
     `, userID)
@@ -487,7 +611,7 @@ func (db *PostgreSQLDatabase) Albums(ctx context.Context, userID string) ([]medi
 
 func (db *PostgreSQLDatabase) Album(ctx context.Context, id string) (media.Album, error) {
 	album := media.Album{}
-	row := db.pool.QueryRow(ctx, `
+	row := db.querier(ctx).QueryRow(ctx, `
         SELECT id, user_id, upc, ean, title, artist_ids, track_ids, description, release_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
         FROM albums WHERE id=$1;
     `, id)
@@ -514,7 +638,7 @@ func (db *PostgreSQLDatabase) Album(ctx context.Context, id string) (media.Album
 }
 
 func (db *PostgreSQLDatabase) AddAlbum(ctx context.Context, album media.Album) error {
-	_, err := db.pool.Exec(ctx, `
+	_, err := db.querier(ctx).Exec(ctx, `
         INSERT INTO albums (
             id, user_id, upc, ean, title, artist_ids, track_ids, description, release_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
         ) VALUES (
@@ -525,7 +649,7 @@ func (db *PostgreSQLDatabase) AddAlbum(ctx context.Context, album media.Album) e
 }
 
 func (db *PostgreSQLDatabase) UpdateAlbum(ctx context.Context, album media.Album) error {
-	_, err := db.pool.Exec(ctx, `
+	_, err := db.querier(ctx).Exec(ctx, `
         UPDATE albums
         SET user_id=$2, upc=$3, ean=$4, title=$5, artist_ids=$6, track_ids=$7, description=$8, release_date=$9, listen_count=$10, favorite_count=$11, addition_date=$12, tags=$13, additional_meta=$14, permissions=$15, linked_item_ids=$16, metadata_source=$17
         WHERE id=$1;
@@ -534,16 +658,20 @@ func (db *PostgreSQLDatabase) UpdateAlbum(ctx context.Context, album media.Album
 }
 
 func (db *PostgreSQLDatabase) DeleteAlbum(ctx context.Context, id string) error {
-	_, err := db.pool.Exec(ctx, `DELETE FROM albums WHERE id=$1;`, id)
+	_, err := db.querier(ctx).Exec(ctx, `DELETE FROM albums WHERE id=$1;`, id)
 	return normalizePostgreSQLError(err)
 }
 
 func (db *PostgreSQLDatabase) AllVideos(ctx context.Context) ([]media.Video, error) {
+	return db.AllVideosPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) AllVideosPage(ctx context.Context, limit, offset int) ([]media.Video, error) {
 	var videos []media.Video
-	rows, err := db.pool.Query(ctx, `
+	rows, err := db.querier(ctx).Query(ctx, `
         SELECT id, user_id, title, artist_ids, duration, description, release_date, subtitles, watch_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
-        FROM videos;
-    `)
+        FROM videos ORDER BY id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
 	if err != nil {
 		return videos, normalizePostgreSQLError(err)
 	}
@@ -580,7 +708,7 @@ func (db *PostgreSQLDatabase) AllVideos(ctx context.Context) ([]media.Video, err
 
 func (db *PostgreSQLDatabase) Videos(ctx context.Context, userID string) ([]media.Video, error) {
 	var videos []media.Video
-	rows, err := db.pool.Query(ctx, `
+	rows, err := db.querier(ctx).Query(ctx, `
         SELECT id, user_id, title, artist_ids, duration, description, release_date, subtitles, watch_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
         FROM videos WHERE user_id=$1;
     `, userID)
@@ -620,7 +748,7 @@ func (db *PostgreSQLDatabase) Videos(ctx context.Context, userID string) ([]medi
 
 func (db *PostgreSQLDatabase) Video(ctx context.Context, id string) (media.Video, error) {
 	video := media.Video{}
-	row := db.pool.QueryRow(ctx, `
+	row := db.querier(ctx).QueryRow(ctx, `
         SELECT id, user_id, title, artist_ids, duration, description, release_date, subtitles, watch_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
         FROM videos WHERE id=$1;
     `, id)
@@ -649,7 +777,7 @@ func (db *PostgreSQLDatabase) Video(ctx context.Context, id string) (media.Video
 }
 
 func (db *PostgreSQLDatabase) AddVideo(ctx context.Context, video media.Video) error {
-	_, err := db.pool.Exec(ctx, `
+	_, err := db.querier(ctx).Exec(ctx, `
         INSERT INTO videos (
             id, user_id, title, artist_ids, duration, description, release_date, subtitles, watch_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
         ) VALUES (
@@ -660,7 +788,7 @@ func (db *PostgreSQLDatabase) AddVideo(ctx context.Context, video media.Video) e
 }
 
 func (db *PostgreSQLDatabase) UpdateVideo(ctx context.Context, video media.Video) error {
-	_, err := db.pool.Exec(ctx, `
+	_, err := db.querier(ctx).Exec(ctx, `
         UPDATE videos
         SET user_id=$2, title=$3, artist_ids=$4, duration=$5, description=$6, release_date=$7, subtitles=$8, watch_count=$9, favorite_count=$10, addition_date=$11, tags=$12, additional_meta=$13, permissions=$14, linked_item_ids=$15, content_source=$16, metadata_source=$17, lyric_sources=$18
         WHERE id=$1;
@@ -669,16 +797,20 @@ func (db *PostgreSQLDatabase) UpdateVideo(ctx context.Context, video media.Video
 }
 
 func (db *PostgreSQLDatabase) DeleteVideo(ctx context.Context, id string) error {
-	_, err := db.pool.Exec(ctx, `DELETE FROM videos WHERE id=$1;`, id)
+	_, err := db.querier(ctx).Exec(ctx, `DELETE FROM videos WHERE id=$1;`, id)
 	return normalizePostgreSQLError(err)
 }
 
 func (db *PostgreSQLDatabase) AllArtists(ctx context.Context) ([]media.Artist, error) {
+	return db.AllArtistsPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) AllArtistsPage(ctx context.Context, limit, offset int) ([]media.Artist, error) {
 	var artists []media.Artist
-	rows, err := db.pool.Query(ctx, `
+	rows, err := db.querier(ctx).Query(ctx, `
         SELECT id, user_id, name, album_ids, track_ids, description, creation_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
-        FROM artists;
-    `)
+        FROM artists ORDER BY id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
 	if err != nil {
 		return artists, normalizePostgreSQLError(err)
 	}
@@ -712,7 +844,7 @@ func (db *PostgreSQLDatabase) AllArtists(ctx context.Context) ([]media.Artist, e
 
 func (db *PostgreSQLDatabase) Artists(ctx context.Context, userID string) ([]media.Artist, error) {
 	var artists []media.Artist
-	rows, err := db.pool.Query(ctx, `
+	rows, err := db.querier(ctx).Query(ctx, `
         SELECT id, user_id, name, album_ids, track_ids, description, creation_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
         FROM artists WHERE user_id=$1;
     `, userID)
@@ -749,7 +881,7 @@ func (db *PostgreSQLDatabase) Artists(ctx context.Context, userID string) ([]med
 
 func (db *PostgreSQLDatabase) Artist(ctx context.Context, id string) (media.Artist, error) {
 	artist := media.Artist{}
-	row := db.pool.QueryRow(ctx, `
+	row := db.querier(ctx).QueryRow(ctx, `
         SELECT id, user_id, name, album_ids, track_ids, description, creation_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
         FROM artists WHERE id=$1;
     `, id)
@@ -774,7 +906,7 @@ func (db *PostgreSQLDatabase) Artist(ctx context.Context, id string) (media.Arti
 }
 
 func (db *PostgreSQLDatabase) AddArtist(ctx context.Context, artist media.Artist) error {
-	_, err := db.pool.Exec(ctx, `
+	_, err := db.querier(ctx).Exec(ctx, `
         INSERT INTO artists (
             id, user_id, name, album_ids, track_ids, description, creation_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
         ) VALUES (
@@ -785,7 +917,7 @@ func (db *PostgreSQLDatabase) AddArtist(ctx context.Context, artist media.Artist
 }
 
 func (db *PostgreSQLDatabase) UpdateArtist(ctx context.Context, artist media.Artist) error {
-	_, err := db.pool.Exec(ctx, `
+	_, err := db.querier(ctx).Exec(ctx, `
         UPDATE artists
         SET user_id=$2, name=$3, album_ids=$4, track_ids=$5, description=$6, creation_date=$7, listen_count=$8, favorite_count=$9, addition_date=$10, tags=$11, additional_meta=$12, permissions=$13, linked_item_ids=$14, metadata_source=$15
         WHERE id=$1;
@@ -794,16 +926,20 @@ func (db *PostgreSQLDatabase) UpdateArtist(ctx context.Context, artist media.Art
 }
 
 func (db *PostgreSQLDatabase) DeleteArtist(ctx context.Context, id string) error {
-	_, err := db.pool.Exec(ctx, `DELETE FROM artists WHERE id=$1;`, id)
+	_, err := db.querier(ctx).Exec(ctx, `DELETE FROM artists WHERE id=$1;`, id)
 	return normalizePostgreSQLError(err)
 }
 
 func (db *PostgreSQLDatabase) AllPlaylists(ctx context.Context) ([]media.Playlist, error) {
+	return db.AllPlaylistsPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) AllPlaylistsPage(ctx context.Context, limit, offset int) ([]media.Playlist, error) {
 	var playlists []media.Playlist
-	rows, err := db.pool.Query(ctx, `
-        SELECT id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source
-        FROM playlists;
-    `)
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source, revision, entries, visibility, kind, rules, evaluated_at
+        FROM playlists ORDER BY id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
 	if err != nil {
 		return playlists, normalizePostgreSQLError(err)
 	}
@@ -824,6 +960,12 @@ func (db *PostgreSQLDatabase) AllPlaylists(ctx context.Context) ([]media.Playlis
 			&playlist.AdditionalMeta,
 			&playlist.Permissions,
 			&playlist.MetadataSource,
+			&playlist.Revision,
+			&playlist.Entries,
+			&playlist.Visibility,
+			&playlist.Kind,
+			&playlist.Rules,
+			&playlist.EvaluatedAt,
 		)
 		if err != nil {
 			return playlists, normalizePostgreSQLError(err)
@@ -835,8 +977,8 @@ func (db *PostgreSQLDatabase) AllPlaylists(ctx context.Context) ([]media.Playlis
 
 func (db *PostgreSQLDatabase) Playlists(ctx context.Context, userID string) ([]media.Playlist, error) {
 	var playlists []media.Playlist
-	rows, err := db.pool.Query(ctx, `
-        SELECT id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source, revision, entries, visibility, kind, rules, evaluated_at
         FROM playlists WHERE user_id=$1;
     `, userID)
 	if err != nil {
@@ -859,6 +1001,12 @@ func (db *PostgreSQLDatabase) Playlists(ctx context.Context, userID string) ([]m
 			&playlist.AdditionalMeta,
 			&playlist.Permissions,
 			&playlist.MetadataSource,
+			&playlist.Revision,
+			&playlist.Entries,
+			&playlist.Visibility,
+			&playlist.Kind,
+			&playlist.Rules,
+			&playlist.EvaluatedAt,
 		)
 		if err != nil {
 			return playlists, normalizePostgreSQLError(err)
@@ -870,8 +1018,8 @@ func (db *PostgreSQLDatabase) Playlists(ctx context.Context, userID string) ([]m
 
 func (db *PostgreSQLDatabase) Playlist(ctx context.Context, id string) (media.Playlist, error) {
 	playlist := media.Playlist{}
-	row := db.pool.QueryRow(ctx, `
-        SELECT id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source
+	row := db.querier(ctx).QueryRow(ctx, `
+        SELECT id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source, revision, entries, visibility, kind, rules, evaluated_at
         FROM playlists WHERE id=$1;
     `, id)
 	err := row.Scan(
@@ -888,41 +1036,85 @@ func (db *PostgreSQLDatabase) Playlist(ctx context.Context, id string) (media.Pl
 		&playlist.AdditionalMeta,
 		&playlist.Permissions,
 		&playlist.MetadataSource,
+		&playlist.Revision,
+		&playlist.Entries,
+		&playlist.Visibility,
+		&playlist.Kind,
+		&playlist.Rules,
+		&playlist.EvaluatedAt,
 	)
 	return playlist, normalizePostgreSQLError(err)
 }
 
 func (db *PostgreSQLDatabase) AddPlaylist(ctx context.Context, playlist media.Playlist) error {
-	_, err := db.pool.Exec(ctx, `
+	_, err := db.querier(ctx).Exec(ctx, `
         INSERT INTO playlists (
-            id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source
+            id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source, revision, entries, visibility, kind, rules, evaluated_at
         ) VALUES (
-            $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
+            $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19
         );
-    `, playlist.ID, playlist.UserID, playlist.Title, playlist.TrackIDs, playlist.ListenCount, playlist.FavoriteCount, playlist.Description, playlist.CreationDate, playlist.AdditionDate, playlist.Tags, playlist.AdditionalMeta, playlist.Permissions, playlist.MetadataSource)
+    `, playlist.ID, playlist.UserID, playlist.Title, playlist.TrackIDs, playlist.ListenCount, playlist.FavoriteCount, playlist.Description, playlist.CreationDate, playlist.AdditionDate, playlist.Tags, playlist.AdditionalMeta, playlist.Permissions, playlist.MetadataSource, playlist.Revision, playlist.Entries, playlist.Visibility, playlist.Kind, playlist.Rules, playlist.EvaluatedAt)
 	return normalizePostgreSQLError(err)
 }
 
 func (db *PostgreSQLDatabase) UpdatePlaylist(ctx context.Context, playlist media.Playlist) error {
-	_, err := db.pool.Exec(ctx, `
+	_, err := db.querier(ctx).Exec(ctx, `
         UPDATE playlists
-        SET user_id=$2, title=$3, track_ids=$4, listen_count=$5, favorite_count=$6, description=$7, creation_date=$8, addition_date=$9, tags=$10, additional_meta=$11, permissions=$12, metadata_source=$13
+        SET user_id=$2, title=$3, track_ids=$4, listen_count=$5, favorite_count=$6, description=$7, creation_date=$8, addition_date=$9, tags=$10, additional_meta=$11, permissions=$12, metadata_source=$13, revision=$14, entries=$15, visibility=$16, kind=$17, rules=$18, evaluated_at=$19
         WHERE id=$1;
-    `, playlist.ID, playlist.UserID, playlist.Title, playlist.TrackIDs, playlist.ListenCount, playlist.FavoriteCount, playlist.Description, playlist.CreationDate, playlist.AdditionDate, playlist.Tags, playlist.AdditionalMeta, playlist.Permissions, playlist.MetadataSource)
+    `, playlist.ID, playlist.UserID, playlist.Title, playlist.TrackIDs, playlist.ListenCount, playlist.FavoriteCount, playlist.Description, playlist.CreationDate, playlist.AdditionDate, playlist.Tags, playlist.AdditionalMeta, playlist.Permissions, playlist.MetadataSource, playlist.Revision, playlist.Entries, playlist.Visibility, playlist.Kind, playlist.Rules, playlist.EvaluatedAt)
 	return normalizePostgreSQLError(err)
 }
 
+func (db *PostgreSQLDatabase) UpdatePlaylistAtRevision(
+	ctx context.Context,
+	playlist media.Playlist,
+	revision int,
+) error {
+	tag, err := db.querier(ctx).Exec(ctx, `
+        UPDATE playlists
+        SET user_id=$2, title=$3, track_ids=$4, listen_count=$5, favorite_count=$6, description=$7, creation_date=$8, addition_date=$9, tags=$10, additional_meta=$11, permissions=$12, metadata_source=$13, revision=$14, entries=$15, visibility=$16, kind=$17, rules=$18, evaluated_at=$19
+        WHERE id=$1 AND revision=$20;
+    `, playlist.ID, playlist.UserID, playlist.Title, playlist.TrackIDs, playlist.ListenCount, playlist.FavoriteCount, playlist.Description, playlist.CreationDate, playlist.AdditionDate, playlist.Tags, playlist.AdditionalMeta, playlist.Permissions, playlist.MetadataSource, playlist.Revision, playlist.Entries, playlist.Visibility, playlist.Kind, playlist.Rules, playlist.EvaluatedAt, revision)
+	if err != nil {
+		return normalizePostgreSQLError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRevisionMismatch
+	}
+	return nil
+}
+
+func (db *PostgreSQLDatabase) SmartPlaylistTrackIDs(
+	ctx context.Context,
+	ownerID string,
+	query rules.Query,
+	maxTracks int,
+) ([]string, error) {
+	statement, args := query.SQL(postgreSQLDialect{}, ownerID, maxTracks, time.Now())
+	rows, err := db.querier(ctx).Query(ctx, statement, args...)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	return ids, normalizePostgreSQLError(err)
+}
+
 func (db *PostgreSQLDatabase) DeletePlaylist(ctx context.Context, id string) error {
-	_, err := db.pool.Exec(ctx, `DELETE FROM playlists WHERE id=$1;`, id)
+	_, err := db.querier(ctx).Exec(ctx, `DELETE FROM playlists WHERE id=$1;`, id)
 	return normalizePostgreSQLError(err)
 }
 
 func (db *PostgreSQLDatabase) Users(ctx context.Context) ([]media.DatabaseUser, error) {
+	return db.UsersPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) UsersPage(ctx context.Context, limit, offset int) ([]media.DatabaseUser, error) {
 	var users []media.DatabaseUser
-	rows, err := db.pool.Query(ctx, `
+	rows, err := db.querier(ctx).Query(ctx, `
         SELECT id, username, email, password_hash, display_name, description, listened_to, favorites, public_view_count, creation_date, permissions, linked_artist_id, linked_sources
-        FROM users;
-    `)
+        FROM users ORDER BY id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
 	if err != nil {
 		return users, normalizePostgreSQLError(err)
 	}
@@ -954,7 +1146,7 @@ func (db *PostgreSQLDatabase) Users(ctx context.Context) ([]media.DatabaseUser,
 
 func (db *PostgreSQLDatabase) User(ctx context.Context, id string) (media.DatabaseUser, error) {
 	user := media.DatabaseUser{}
-	row := db.pool.QueryRow(ctx, `
+	row := db.querier(ctx).QueryRow(ctx, `
         SELECT id, username, email, password_hash, display_name, description, listened_to, favorites, public_view_count, creation_date, permissions, linked_artist_id, linked_sources
         FROM users WHERE id=$1;
     `, id)
@@ -978,7 +1170,7 @@ func (db *PostgreSQLDatabase) User(ctx context.Context, id string) (media.Databa
 
 func (db *PostgreSQLDatabase) UserByUsername(ctx context.Context, username string) (media.DatabaseUser, error) {
 	user := media.DatabaseUser{}
-	row := db.pool.QueryRow(ctx, `
+	row := db.querier(ctx).QueryRow(ctx, `
         SELECT id, username, email, password_hash, display_name, description, listened_to, favorites, public_view_count, creation_date, permissions, linked_artist_id, linked_sources
         FROM users WHERE username=$1 OR email=$1;
     `, strings.ToLower(username))
@@ -1001,7 +1193,7 @@ func (db *PostgreSQLDatabase) UserByUsername(ctx context.Context, username strin
 }
 
 func (db *PostgreSQLDatabase) CreateUser(ctx context.Context, user media.DatabaseUser) error {
-	_, err := db.pool.Exec(ctx, `
+	_, err := db.querier(ctx).Exec(ctx, `
         INSERT INTO users (
             id, username, email, password_hash, display_name, description, listened_to, favorites, public_view_count, creation_date, permissions, linked_artist_id, linked_sources
         ) VALUES (
@@ -1012,7 +1204,7 @@ func (db *PostgreSQLDatabase) CreateUser(ctx context.Context, user media.Databas
 }
 
 func (db *PostgreSQLDatabase) UpdateUser(ctx context.Context, user media.DatabaseUser) error {
-	_, err := db.pool.Exec(ctx, `
+	_, err := db.querier(ctx).Exec(ctx, `
         UPDATE users
         SET username=$2, email=$3, password_hash=$4, display_name=$5, description=$6, listened_to=$7, favorites=$8, public_view_count=$9, creation_date=$10, permissions=$11, linked_artist_id=$12, linked_sources=$13
         WHERE id=$1;
@@ -1021,7 +1213,7 @@ func (db *PostgreSQLDatabase) UpdateUser(ctx context.Context, user media.Databas
 }
 
 func (db *PostgreSQLDatabase) DeleteUser(ctx context.Context, id string) error {
-	_, err := db.pool.Exec(ctx, `DELETE FROM users WHERE id=$1;`, id)
+	_, err := db.querier(ctx).Exec(ctx, `DELETE FROM users WHERE id=$1;`, id)
 	return normalizePostgreSQLError(err)
 }
 
@@ -1030,7 +1222,7 @@ func (db *PostgreSQLDatabase) ProviderUser(
 	provider, providerUserID string,
 ) (media.DatabaseUser, error) {
 	var user media.DatabaseUser
-	row := db.pool.QueryRow(ctx, `
+	row := db.querier(ctx).QueryRow(ctx, `
         SELECT u.id, u.username, u.email, u.password_hash, u.display_name, u.description, u.listened_to, u.favorites, u.public_view_count, u.creation_date, u.permissions, u.linked_artist_id, u.linked_sources
         FROM users u
         JOIN auth_providers p ON u.id = p.user_id
@@ -1056,7 +1248,7 @@ func (db *PostgreSQLDatabase) ProviderUser(
 
 func (db *PostgreSQLDatabase) IsProviderLinked(ctx context.Context, provider, userID string) (bool, error) {
 	var exists bool
-	err := db.pool.QueryRow(ctx, `
+	err := db.querier(ctx).QueryRow(ctx, `
         SELECT EXISTS(
             SELECT 1 FROM auth_providers WHERE user_id = $1 AND provider = $2
         );
@@ -1065,7 +1257,7 @@ func (db *PostgreSQLDatabase) IsProviderLinked(ctx context.Context, provider, us
 }
 
 func (db *PostgreSQLDatabase) LinkProviderAccount(ctx context.Context, provider, userID, providerUserID string) error {
-	_, err := db.pool.Exec(ctx, `
+	_, err := db.querier(ctx).Exec(ctx, `
         INSERT INTO auth_providers (user_id, provider, provider_user_id)
         VALUES ($1, $2, $3);
     `, userID, provider, providerUserID)
@@ -1073,7 +1265,7 @@ func (db *PostgreSQLDatabase) LinkProviderAccount(ctx context.Context, provider,
 }
 
 func (db *PostgreSQLDatabase) DisconnectProviderAccount(ctx context.Context, provider, userID string) error {
-	_, err := db.pool.Exec(ctx, `
+	_, err := db.querier(ctx).Exec(ctx, `
         DELETE FROM auth_providers WHERE user_id = $1 AND provider = $2;
     `, userID, provider)
 	return normalizePostgreSQLError(err)
@@ -1081,47 +1273,1443 @@ func (db *PostgreSQLDatabase) DisconnectProviderAccount(ctx context.Context, pro
 
 func (db *PostgreSQLDatabase) UsernameExists(ctx context.Context, username string) (bool, error) {
 	var exists bool
-	err := db.pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE username=$1);`, strings.ToLower(username)).
+	err := db.querier(ctx).QueryRow(ctx, `
+        SELECT EXISTS(SELECT 1 FROM users
+        WHERE username=$1);
+    `, strings.ToLower(username)).
 		Scan(&exists)
 	return exists, normalizePostgreSQLError(err)
 }
 
 func (db *PostgreSQLDatabase) EmailExists(ctx context.Context, email string) (bool, error) {
 	var exists bool
-	err := db.pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE email=$1);`, strings.ToLower(email)).
+	err := db.querier(ctx).QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE email=$1);`, strings.ToLower(email)).
 		Scan(&exists)
 	return exists, normalizePostgreSQLError(err)
 }
 
-func (db *PostgreSQLDatabase) BlacklistToken(ctx context.Context, token string, expiration time.Time) error {
-	_, err := db.pool.Exec(
-		ctx,
-		`INSERT INTO blacklisted_tokens (token, expiration) VALUES ($1, $2);`,
-		token,
-		expiration,
-	)
+func (db *PostgreSQLDatabase) AllListens(ctx context.Context) ([]media.Listen, error) {
+	return db.AllListensPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) AllListensPage(ctx context.Context, limit, offset int) ([]media.Listen, error) {
+	var listens []media.Listen
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, user_id, playable_id, playable_type, listened_at, duration_played, client, source,
+            track_name, artist_name, release_name, recording_mbid, isrc
+        FROM listens ORDER BY id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return listens, normalizePostgreSQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		listen, err := scanPostgreSQLListen(rows)
+		if err != nil {
+			return listens, normalizePostgreSQLError(err)
+		}
+		listens = append(listens, listen)
+	}
+	return listens, normalizePostgreSQLError(rows.Err())
+}
+
+func (db *PostgreSQLDatabase) Listens(
+	ctx context.Context,
+	userID string,
+	limit, offset int,
+) ([]media.Listen, error) {
+	var listens []media.Listen
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, user_id, playable_id, playable_type, listened_at, duration_played, client, source,
+            track_name, artist_name, release_name, recording_mbid, isrc
+        FROM listens WHERE user_id=$1
+        ORDER BY listened_at DESC, id DESC
+        LIMIT $2 OFFSET $3;
+    `, userID, limit, offset)
+	if err != nil {
+		return listens, normalizePostgreSQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		listen, err := scanPostgreSQLListen(rows)
+		if err != nil {
+			return listens, normalizePostgreSQLError(err)
+		}
+		listens = append(listens, listen)
+	}
+	return listens, normalizePostgreSQLError(rows.Err())
+}
+
+func (db *PostgreSQLDatabase) ListensBetween(
+	ctx context.Context,
+	userID string,
+	minTS, maxTS int64,
+	limit int,
+) ([]media.Listen, error) {
+	var listens []media.Listen
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, user_id, playable_id, playable_type, listened_at, duration_played, client, source,
+            track_name, artist_name, release_name, recording_mbid, isrc
+        FROM listens
+        WHERE user_id=$1 AND ($2 = 0 OR listened_at > $2) AND ($3 = 0 OR listened_at < $3)
+        ORDER BY listened_at DESC, id DESC
+        LIMIT $4;
+    `, userID, minTS, maxTS, limit)
+	if err != nil {
+		return listens, normalizePostgreSQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		listen, err := scanPostgreSQLListen(rows)
+		if err != nil {
+			return listens, normalizePostgreSQLError(err)
+		}
+		listens = append(listens, listen)
+	}
+	return listens, normalizePostgreSQLError(rows.Err())
+}
+
+func (db *PostgreSQLDatabase) CountListens(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := db.querier(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM listens WHERE user_id=$1;`, userID).Scan(&count)
+	return count, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) Listen(ctx context.Context, id string) (media.Listen, error) {
+	row := db.querier(ctx).QueryRow(ctx, `
+        SELECT id, user_id, playable_id, playable_type, listened_at, duration_played, client, source,
+            track_name, artist_name, release_name, recording_mbid, isrc
+        FROM listens WHERE id=$1;
+    `, id)
+	listen, err := scanPostgreSQLListen(row)
+	return listen, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) AddListen(ctx context.Context, listen media.Listen) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        INSERT INTO listens (
+            id, user_id, playable_id, playable_type, listened_at, duration_played, client, source,
+            track_name, artist_name, release_name, recording_mbid, isrc
+        )
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13);
+    `, listen.ID, listen.UserID, listen.PlayableID, listen.PlayableType, listen.ListenedAt, listen.DurationPlayed, listen.Client, listen.Source, listen.TrackName, listen.ArtistName, listen.ReleaseName, listen.RecordingMBID, listen.ISRC)
 	return normalizePostgreSQLError(err)
 }
 
-func (db *PostgreSQLDatabase) CleanExpiredTokens(ctx context.Context) error {
-	_, err := db.pool.Exec(ctx, `DELETE FROM blacklisted_tokens WHERE expiration < NOW();`)
+func (db *PostgreSQLDatabase) IncrementListenCount(ctx context.Context, playableType, id string, delta int) error {
+	table, column, err := listenCountColumn(playableType)
+	if err != nil {
+		return err
+	}
+	_, err = db.querier(ctx).Exec(ctx,
+		`UPDATE `+table+` SET `+column+` = COALESCE(`+column+`, 0) + $1 WHERE id=$2;`,
+		delta,
+		id,
+	)
 	return normalizePostgreSQLError(err)
 }
 
-func (db *PostgreSQLDatabase) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
+func (db *PostgreSQLDatabase) AllFavorites(ctx context.Context) ([]media.Favorite, error) {
+	return db.AllFavoritesPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) AllFavoritesPage(ctx context.Context, limit, offset int) ([]media.Favorite, error) {
+	var favorites []media.Favorite
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT user_id, playable_id, playable_type, favorited_at FROM favorites
+        ORDER BY user_id, playable_type, playable_id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return favorites, normalizePostgreSQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		favorite, err := scanPostgreSQLFavorite(rows)
+		if err != nil {
+			return favorites, normalizePostgreSQLError(err)
+		}
+		favorites = append(favorites, favorite)
+	}
+	return favorites, normalizePostgreSQLError(rows.Err())
+}
+
+func (db *PostgreSQLDatabase) Favorites(
+	ctx context.Context,
+	userID, playableType string,
+	limit, offset int,
+) ([]media.Favorite, error) {
+	var favorites []media.Favorite
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT user_id, playable_id, playable_type, favorited_at
+        FROM favorites WHERE user_id=$1 AND ($2 = '' OR playable_type=$2)
+        ORDER BY favorited_at DESC, playable_type, playable_id
+        LIMIT $3 OFFSET $4;
+    `, userID, playableType, limit, offset)
+	if err != nil {
+		return favorites, normalizePostgreSQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		favorite, err := scanPostgreSQLFavorite(rows)
+		if err != nil {
+			return favorites, normalizePostgreSQLError(err)
+		}
+		favorites = append(favorites, favorite)
+	}
+	return favorites, normalizePostgreSQLError(rows.Err())
+}
+
+func (db *PostgreSQLDatabase) CountFavorites(ctx context.Context, userID, playableType string) (int, error) {
+	var count int
+	err := db.querier(ctx).QueryRow(ctx,
+		`SELECT COUNT(*) FROM favorites WHERE user_id=$1 AND ($2 = '' OR playable_type=$2);`,
+		userID,
+		playableType,
+	).Scan(&count)
+	return count, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) FavoriteIDs(ctx context.Context, userID, playableType string) ([]string, error) {
+	var ids []string
+	rows, err := db.querier(ctx).Query(ctx,
+		`SELECT playable_id FROM favorites WHERE user_id=$1 AND playable_type=$2;`,
+		userID,
+		playableType,
+	)
+	if err != nil {
+		return ids, normalizePostgreSQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return ids, normalizePostgreSQLError(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, normalizePostgreSQLError(rows.Err())
+}
+
+func (db *PostgreSQLDatabase) IsFavorite(ctx context.Context, userID, playableType, id string) (bool, error) {
 	var exists bool
-	err := db.pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM blacklisted_tokens WHERE token=$1);`, token).Scan(&exists)
+	err := db.querier(ctx).QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM favorites WHERE user_id=$1 AND playable_type=$2 AND playable_id=$3);`,
+		userID,
+		playableType,
+		id,
+	).Scan(&exists)
 	return exists, normalizePostgreSQLError(err)
 }
 
-func normalizePostgreSQLError(err error) error {
-	if errors.Is(err, pgx.ErrNoRows) {
-		return ErrNotFound
+func (db *PostgreSQLDatabase) AddFavorite(ctx context.Context, favorite media.Favorite) (bool, error) {
+	tag, err := db.querier(ctx).Exec(ctx, `
+        INSERT INTO favorites (user_id, playable_id, playable_type, favorited_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT DO NOTHING;
+    `, favorite.UserID, favorite.PlayableID, favorite.PlayableType, favorite.FavoritedAt)
+	if err != nil {
+		return false, normalizePostgreSQLError(err)
 	}
-	return err
+	return tag.RowsAffected() > 0, nil
 }
 
-func init() {
-	db := &PostgreSQLDatabase{}
-	Registry["postgresql"] = db
+func (db *PostgreSQLDatabase) RemoveFavorite(ctx context.Context, userID, playableType, id string) (bool, error) {
+	tag, err := db.querier(ctx).Exec(ctx,
+		`DELETE FROM favorites WHERE user_id=$1 AND playable_type=$2 AND playable_id=$3;`,
+		userID,
+		playableType,
+		id,
+	)
+	if err != nil {
+		return false, normalizePostgreSQLError(err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (db *PostgreSQLDatabase) RemovePlayableFavorites(ctx context.Context, playableType, id string) error {
+	_, err := db.querier(ctx).Exec(ctx,
+		`DELETE FROM favorites WHERE playable_type=$1 AND playable_id=$2;`,
+		playableType,
+		id,
+	)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) IncrementFavoriteCount(ctx context.Context, playableType, id string, delta int) error {
+	table, err := favoriteCountTable(playableType)
+	if err != nil {
+		return err
+	}
+	_, err = db.querier(ctx).Exec(ctx,
+		`UPDATE `+table+` SET favorite_count = GREATEST(COALESCE(favorite_count, 0) + $1, 0) WHERE id=$2;`,
+		delta,
+		id,
+	)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) ListenBrainzToken(ctx context.Context, userID string) (string, error) {
+	var tokenHash string
+	err := db.querier(ctx).QueryRow(ctx, `SELECT token_hash FROM listenbrainz_tokens WHERE user_id=$1;`, userID).
+		Scan(&tokenHash)
+	return tokenHash, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) ListenBrainzTokenUserID(ctx context.Context, tokenHash string) (string, error) {
+	var userID string
+	err := db.querier(ctx).QueryRow(ctx, `SELECT user_id FROM listenbrainz_tokens WHERE token_hash=$1;`, tokenHash).
+		Scan(&userID)
+	return userID, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) SetListenBrainzToken(
+	ctx context.Context,
+	userID, tokenHash string,
+	creationDate int64,
+) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        INSERT INTO listenbrainz_tokens (user_id, token_hash, creation_date)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (user_id) DO UPDATE SET token_hash=EXCLUDED.token_hash, creation_date=EXCLUDED.creation_date;
+    `, userID, tokenHash, creationDate)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) ListenBrainzTokens(ctx context.Context) ([]ListenBrainzToken, error) {
+	return db.ListenBrainzTokensPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) ListenBrainzTokensPage(
+	ctx context.Context,
+	limit, offset int,
+) ([]ListenBrainzToken, error) {
+	var tokens []ListenBrainzToken
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT user_id, token_hash, creation_date FROM listenbrainz_tokens
+        ORDER BY user_id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return tokens, normalizePostgreSQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		token := ListenBrainzToken{}
+		if err := rows.Scan(&token.UserID, &token.TokenHash, &token.CreationDate); err != nil {
+			return tokens, normalizePostgreSQLError(err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, normalizePostgreSQLError(rows.Err())
+}
+
+func (db *PostgreSQLDatabase) AllScrobbles(ctx context.Context) ([]Scrobble, error) {
+	return db.AllScrobblesPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) AllScrobblesPage(ctx context.Context, limit, offset int) ([]Scrobble, error) {
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, user_id, listen_id, scrobbler, attempts, next_attempt_at, last_error, failed, creation_date
+        FROM scrobble_queue ORDER BY id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	return collectPostgreSQLScrobbles(rows)
+}
+
+func (db *PostgreSQLDatabase) DueScrobbles(ctx context.Context, now int64, limit int) ([]Scrobble, error) {
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, user_id, listen_id, scrobbler, attempts, next_attempt_at, last_error, failed, creation_date
+        FROM scrobble_queue WHERE failed=FALSE AND next_attempt_at <= $1
+        ORDER BY next_attempt_at, creation_date
+        LIMIT $2;
+    `, now, limit)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	return collectPostgreSQLScrobbles(rows)
+}
+
+func (db *PostgreSQLDatabase) FailedScrobbles(ctx context.Context, userID string) ([]Scrobble, error) {
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, user_id, listen_id, scrobbler, attempts, next_attempt_at, last_error, failed, creation_date
+        FROM scrobble_queue WHERE user_id=$1 AND failed=TRUE
+        ORDER BY creation_date DESC;
+    `, userID)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	return collectPostgreSQLScrobbles(rows)
+}
+
+func (db *PostgreSQLDatabase) Scrobble(ctx context.Context, id string) (Scrobble, error) {
+	row := db.querier(ctx).QueryRow(ctx, `
+        SELECT id, user_id, listen_id, scrobbler, attempts, next_attempt_at, last_error, failed, creation_date
+        FROM scrobble_queue WHERE id=$1;
+    `, id)
+	scrobble, err := scanPostgreSQLScrobble(row)
+	return scrobble, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) AddScrobble(ctx context.Context, scrobble Scrobble) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        INSERT INTO scrobble_queue (id, user_id, listen_id, scrobbler, attempts, next_attempt_at, last_error, failed, creation_date)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+    `, scrobble.ID, scrobble.UserID, scrobble.ListenID, scrobble.Scrobbler, scrobble.Attempts, scrobble.NextAttemptAt, scrobble.LastError, scrobble.Failed, scrobble.CreationDate)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) UpdateScrobble(ctx context.Context, scrobble Scrobble) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        UPDATE scrobble_queue
+        SET user_id=$2, listen_id=$3, scrobbler=$4, attempts=$5, next_attempt_at=$6, last_error=$7, failed=$8,
+            creation_date=$9
+        WHERE id=$1;
+    `, scrobble.ID, scrobble.UserID, scrobble.ListenID, scrobble.Scrobbler, scrobble.Attempts, scrobble.NextAttemptAt, scrobble.LastError, scrobble.Failed, scrobble.CreationDate)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) DeleteScrobble(ctx context.Context, id string) error {
+	_, err := db.querier(ctx).Exec(ctx, `DELETE FROM scrobble_queue WHERE id=$1;`, id)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) ScrobblerLinks(ctx context.Context) ([]ScrobblerLink, error) {
+	return db.ScrobblerLinksPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) ScrobblerLinksPage(ctx context.Context, limit, offset int) ([]ScrobblerLink, error) {
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT user_id, scrobbler, token, creation_date FROM scrobbler_links
+        ORDER BY user_id, scrobbler LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	return collectPostgreSQLScrobblerLinks(rows)
+}
+
+func (db *PostgreSQLDatabase) UserScrobblerLinks(ctx context.Context, userID string) ([]ScrobblerLink, error) {
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT user_id, scrobbler, token, creation_date FROM scrobbler_links
+        WHERE user_id=$1 ORDER BY scrobbler;
+    `, userID)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	return collectPostgreSQLScrobblerLinks(rows)
+}
+
+func (db *PostgreSQLDatabase) ScrobblerLink(ctx context.Context, userID, scrobbler string) (ScrobblerLink, error) {
+	row := db.querier(ctx).QueryRow(ctx, `
+        SELECT user_id, scrobbler, token, creation_date FROM scrobbler_links WHERE user_id=$1 AND scrobbler=$2;
+    `, userID, scrobbler)
+	link, err := scanPostgreSQLScrobblerLink(row)
+	return link, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) SetScrobblerLink(ctx context.Context, link ScrobblerLink) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        INSERT INTO scrobbler_links (user_id, scrobbler, token, creation_date) VALUES ($1, $2, $3, $4)
+        ON CONFLICT (user_id, scrobbler) DO UPDATE SET token=excluded.token, creation_date=excluded.creation_date;
+    `, link.UserID, link.Scrobbler, link.Token, link.CreationDate)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) DeleteScrobblerLink(ctx context.Context, userID, scrobbler string) error {
+	_, err := db.querier(ctx).Exec(ctx,
+		`DELETE FROM scrobbler_links WHERE user_id=$1 AND scrobbler=$2;`, userID, scrobbler)
+	return normalizePostgreSQLError(err)
+}
+
+func collectPostgreSQLScrobblerLinks(rows pgx.Rows) ([]ScrobblerLink, error) {
+	defer rows.Close()
+	var links []ScrobblerLink
+	for rows.Next() {
+		link, err := scanPostgreSQLScrobblerLink(rows)
+		if err != nil {
+			return links, normalizePostgreSQLError(err)
+		}
+		links = append(links, link)
+	}
+	return links, normalizePostgreSQLError(rows.Err())
+}
+
+func scanPostgreSQLScrobblerLink(row pgx.Row) (ScrobblerLink, error) {
+	link := ScrobblerLink{}
+	err := row.Scan(&link.UserID, &link.Scrobbler, &link.Token, &link.CreationDate)
+	return link, err
+}
+
+func (db *PostgreSQLDatabase) AllPlayQueues(ctx context.Context) ([]media.PlayQueue, error) {
+	return db.AllPlayQueuesPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) AllPlayQueuesPage(ctx context.Context, limit, offset int) ([]media.PlayQueue, error) {
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT user_id, items, current_index, position_ms, shuffle, repeat_mode, context_type, context_id, device, revision, updated_at
+        FROM play_queues ORDER BY user_id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	defer rows.Close()
+	var queues []media.PlayQueue
+	for rows.Next() {
+		queue, err := scanPostgreSQLPlayQueue(rows)
+		if err != nil {
+			return queues, normalizePostgreSQLError(err)
+		}
+		queues = append(queues, queue)
+	}
+	return queues, normalizePostgreSQLError(rows.Err())
+}
+
+func (db *PostgreSQLDatabase) PlayQueue(ctx context.Context, userID string) (media.PlayQueue, error) {
+	row := db.querier(ctx).QueryRow(ctx, `
+        SELECT user_id, items, current_index, position_ms, shuffle, repeat_mode, context_type, context_id, device, revision, updated_at
+        FROM play_queues WHERE user_id=$1;
+    `, userID)
+	queue, err := scanPostgreSQLPlayQueue(row)
+	return queue, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) SetPlayQueue(ctx context.Context, queue media.PlayQueue) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        INSERT INTO play_queues (user_id, items, current_index, position_ms, shuffle, repeat_mode, context_type, context_id, device, revision, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        ON CONFLICT (user_id) DO UPDATE SET items=EXCLUDED.items, current_index=EXCLUDED.current_index, position_ms=EXCLUDED.position_ms, shuffle=EXCLUDED.shuffle, repeat_mode=EXCLUDED.repeat_mode, context_type=EXCLUDED.context_type, context_id=EXCLUDED.context_id, device=EXCLUDED.device, revision=EXCLUDED.revision, updated_at=EXCLUDED.updated_at;
+    `, postgreSQLPlayQueueArgs(queue)...)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) SavePlayQueueAtRevision(
+	ctx context.Context,
+	queue media.PlayQueue,
+	revision int,
+) error {
+	statement := `
+        UPDATE play_queues
+        SET items=$2, current_index=$3, position_ms=$4, shuffle=$5, repeat_mode=$6, context_type=$7, context_id=$8, device=$9, revision=$10, updated_at=$11
+        WHERE user_id=$1 AND revision=$12;
+    `
+	args := append(postgreSQLPlayQueueArgs(queue), revision)
+	if revision == 0 {
+		// The queue must not exist yet, so a queue another device stored in the meantime isn't replaced.
+		statement = `
+        INSERT INTO play_queues (user_id, items, current_index, position_ms, shuffle, repeat_mode, context_type, context_id, device, revision, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        ON CONFLICT (user_id) DO NOTHING;
+    `
+		args = postgreSQLPlayQueueArgs(queue)
+	}
+
+	tag, err := db.querier(ctx).Exec(ctx, statement, args...)
+	if err != nil {
+		return normalizePostgreSQLError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRevisionMismatch
+	}
+	return nil
+}
+
+func (db *PostgreSQLDatabase) BlacklistToken(ctx context.Context, token string, expiration time.Time) error {
+	_, err := db.querier(ctx).Exec(
+		ctx,
+		`INSERT INTO blacklisted_tokens (token, expiration) VALUES ($1, $2);`,
+		token,
+		expiration,
+	)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) CleanExpiredTokens(ctx context.Context) error {
+	_, err := db.querier(ctx).Exec(ctx, `DELETE FROM blacklisted_tokens WHERE expiration < NOW();`)
+	if err != nil {
+		return normalizePostgreSQLError(err)
+	}
+	_, err = db.querier(ctx).Exec(ctx, `DELETE FROM refresh_tokens WHERE expiration < $1;`, time.Now().Unix())
+	if err != nil {
+		return normalizePostgreSQLError(err)
+	}
+	_, err = db.querier(ctx).Exec(ctx, `DELETE FROM sessions WHERE expiration < $1;`, time.Now().Unix())
+	if err != nil {
+		return normalizePostgreSQLError(err)
+	}
+	_, err = db.querier(ctx).Exec(ctx, `
+        DELETE FROM access_tokens
+        WHERE expiration != 0 AND expiration < $1;
+    `, time.Now().Unix())
+	if err != nil {
+		return normalizePostgreSQLError(err)
+	}
+	_, err = db.querier(ctx).Exec(ctx, `DELETE FROM email_tokens WHERE expiration < $1;`, time.Now().Unix())
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
+	var exists bool
+	err := db.querier(ctx).QueryRow(ctx, `
+        SELECT EXISTS(SELECT 1 FROM blacklisted_tokens
+        WHERE token=$1);
+    `, token).Scan(&exists)
+	return exists, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) ProviderLinks(ctx context.Context) ([]ProviderLink, error) {
+	return db.ProviderLinksPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) ProviderLinksPage(ctx context.Context, limit, offset int) ([]ProviderLink, error) {
+	var links []ProviderLink
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT user_id, provider, provider_user_id FROM auth_providers
+        ORDER BY user_id, provider LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return links, normalizePostgreSQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		link := ProviderLink{}
+		if err := rows.Scan(&link.UserID, &link.Provider, &link.ProviderUserID); err != nil {
+			return links, normalizePostgreSQLError(err)
+		}
+		links = append(links, link)
+	}
+	return links, normalizePostgreSQLError(rows.Err())
+}
+
+func (db *PostgreSQLDatabase) BlacklistedTokens(ctx context.Context) ([]BlacklistedToken, error) {
+	return db.BlacklistedTokensPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) BlacklistedTokensPage(
+	ctx context.Context,
+	limit, offset int,
+) ([]BlacklistedToken, error) {
+	var tokens []BlacklistedToken
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT token, expiration FROM blacklisted_tokens
+        ORDER BY token LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return tokens, normalizePostgreSQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		token := BlacklistedToken{}
+		if err := rows.Scan(&token.Token, &token.Expiration); err != nil {
+			return tokens, normalizePostgreSQLError(err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, normalizePostgreSQLError(rows.Err())
+}
+
+func (db *PostgreSQLDatabase) RefreshTokens(ctx context.Context) ([]RefreshToken, error) {
+	return db.RefreshTokensPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) RefreshTokensPage(ctx context.Context, limit, offset int) ([]RefreshToken, error) {
+	var tokens []RefreshToken
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT token_hash, user_id, family_id, creation_date, expiration, used_at, revoked FROM refresh_tokens
+        ORDER BY token_hash LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return tokens, normalizePostgreSQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		token, err := scanPostgreSQLRefreshToken(rows)
+		if err != nil {
+			return tokens, normalizePostgreSQLError(err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, normalizePostgreSQLError(rows.Err())
+}
+
+func (db *PostgreSQLDatabase) RefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := db.querier(ctx).QueryRow(ctx, `
+        SELECT token_hash, user_id, family_id, creation_date, expiration, used_at, revoked
+        FROM refresh_tokens WHERE token_hash=$1;
+    `, tokenHash)
+	token, err := scanPostgreSQLRefreshToken(row)
+	return token, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) AddRefreshToken(ctx context.Context, token RefreshToken) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        INSERT INTO refresh_tokens (token_hash, user_id, family_id, creation_date, expiration, used_at, revoked)
+        VALUES ($1, $2, $3, $4, $5, $6, $7);
+    `, token.TokenHash, token.UserID, token.FamilyID, token.CreationDate, token.Expiration, token.UsedAt, token.Revoked)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) UseRefreshToken(ctx context.Context, tokenHash string, usedAt int64) (bool, error) {
+	tag, err := db.querier(ctx).Exec(ctx, `
+        UPDATE refresh_tokens SET used_at=$1 WHERE token_hash=$2 AND used_at=0 AND NOT revoked;
+    `, usedAt, tokenHash)
+	if err != nil {
+		return false, normalizePostgreSQLError(err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (db *PostgreSQLDatabase) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	_, err := db.querier(ctx).Exec(ctx, `UPDATE refresh_tokens SET revoked=TRUE WHERE family_id=$1;`, familyID)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) Sessions(ctx context.Context) ([]Session, error) {
+	return db.SessionsPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) SessionsPage(ctx context.Context, limit, offset int) ([]Session, error) {
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, user_id, device_name, user_agent, ip, creation_date, last_used, expiration FROM sessions
+        ORDER BY id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	return collectPostgreSQLSessions(rows)
+}
+
+func (db *PostgreSQLDatabase) UserSessions(ctx context.Context, userID string) ([]Session, error) {
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, user_id, device_name, user_agent, ip, creation_date, last_used, expiration
+        FROM sessions WHERE user_id=$1 ORDER BY last_used DESC;
+    `, userID)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	return collectPostgreSQLSessions(rows)
+}
+
+func (db *PostgreSQLDatabase) Session(ctx context.Context, id string) (Session, error) {
+	row := db.querier(ctx).QueryRow(ctx, `
+        SELECT id, user_id, device_name, user_agent, ip, creation_date, last_used, expiration
+        FROM sessions WHERE id=$1;
+    `, id)
+	session, err := scanPostgreSQLSession(row)
+	return session, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) AddSession(ctx context.Context, session Session) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        INSERT INTO sessions (id, user_id, device_name, user_agent, ip, creation_date, last_used, expiration)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+    `,
+		session.ID,
+		session.UserID,
+		session.DeviceName,
+		session.UserAgent,
+		session.IP,
+		session.CreationDate,
+		session.LastUsed,
+		session.Expiration,
+	)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) UpdateSession(ctx context.Context, session Session) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        UPDATE sessions SET device_name=$1, user_agent=$2, ip=$3, last_used=$4, expiration=$5 WHERE id=$6;
+    `, session.DeviceName, session.UserAgent, session.IP, session.LastUsed, session.Expiration, session.ID)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) DeleteSession(ctx context.Context, id string) error {
+	_, err := db.querier(ctx).Exec(ctx, `DELETE FROM sessions WHERE id=$1;`, id)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) TOTPSecrets(ctx context.Context) ([]TOTPSecret, error) {
+	return db.TOTPSecretsPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) TOTPSecretsPage(ctx context.Context, limit, offset int) ([]TOTPSecret, error) {
+	var secrets []TOTPSecret
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT user_id, secret, enabled, last_step, creation_date FROM totp_secrets
+        ORDER BY user_id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return secrets, normalizePostgreSQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		secret, err := scanPostgreSQLTOTPSecret(rows)
+		if err != nil {
+			return secrets, normalizePostgreSQLError(err)
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, normalizePostgreSQLError(rows.Err())
+}
+
+func (db *PostgreSQLDatabase) TOTPSecret(ctx context.Context, userID string) (TOTPSecret, error) {
+	row := db.querier(ctx).QueryRow(ctx, `
+        SELECT user_id, secret, enabled, last_step, creation_date FROM totp_secrets WHERE user_id=$1;
+    `, userID)
+	secret, err := scanPostgreSQLTOTPSecret(row)
+	return secret, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) SetTOTPSecret(ctx context.Context, secret TOTPSecret) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        INSERT INTO totp_secrets (user_id, secret, enabled, last_step, creation_date)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (user_id) DO UPDATE SET
+            secret=excluded.secret, enabled=excluded.enabled, last_step=excluded.last_step,
+            creation_date=excluded.creation_date;
+    `, secret.UserID, secret.Secret, secret.Enabled, secret.LastStep, secret.CreationDate)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	tag, err := db.querier(ctx).Exec(ctx, `
+        UPDATE totp_secrets SET last_step=$1 WHERE user_id=$2 AND last_step<$3;
+    `, step, userID, step)
+	if err != nil {
+		return false, normalizePostgreSQLError(err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (db *PostgreSQLDatabase) DeleteTOTPSecret(ctx context.Context, userID string) error {
+	_, err := db.querier(ctx).Exec(ctx, `DELETE FROM totp_secrets WHERE user_id=$1;`, userID)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) RecoveryCodes(ctx context.Context) ([]RecoveryCode, error) {
+	return db.RecoveryCodesPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) RecoveryCodesPage(ctx context.Context, limit, offset int) ([]RecoveryCode, error) {
+	var codes []RecoveryCode
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT user_id, code_hash FROM recovery_codes
+        ORDER BY user_id, code_hash LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return codes, normalizePostgreSQLError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		code := RecoveryCode{}
+		if err := rows.Scan(&code.UserID, &code.CodeHash); err != nil {
+			return codes, normalizePostgreSQLError(err)
+		}
+		codes = append(codes, code)
+	}
+	return codes, normalizePostgreSQLError(rows.Err())
+}
+
+func (db *PostgreSQLDatabase) AddRecoveryCode(ctx context.Context, code RecoveryCode) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2);
+    `, code.UserID, code.CodeHash)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	tag, err := db.querier(ctx).Exec(ctx, `
+        DELETE FROM recovery_codes WHERE user_id=$1 AND code_hash=$2;
+    `, userID, codeHash)
+	if err != nil {
+		return false, normalizePostgreSQLError(err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (db *PostgreSQLDatabase) DeleteRecoveryCodes(ctx context.Context, userID string) error {
+	_, err := db.querier(ctx).Exec(ctx, `DELETE FROM recovery_codes WHERE user_id=$1;`, userID)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) WebAuthnCredentials(ctx context.Context) ([]WebAuthnCredential, error) {
+	return db.WebAuthnCredentialsPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) WebAuthnCredentialsPage(
+	ctx context.Context,
+	limit, offset int,
+) ([]WebAuthnCredential, error) {
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, user_id, name, credential, creation_date, last_used FROM webauthn_credentials
+        ORDER BY id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	return collectPostgreSQLWebAuthnCredentials(rows)
+}
+
+func (db *PostgreSQLDatabase) UserWebAuthnCredentials(
+	ctx context.Context,
+	userID string,
+) ([]WebAuthnCredential, error) {
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, user_id, name, credential, creation_date, last_used
+        FROM webauthn_credentials WHERE user_id=$1 ORDER BY creation_date;
+    `, userID)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	return collectPostgreSQLWebAuthnCredentials(rows)
+}
+
+func (db *PostgreSQLDatabase) WebAuthnCredential(ctx context.Context, id string) (WebAuthnCredential, error) {
+	row := db.querier(ctx).QueryRow(ctx, `
+        SELECT id, user_id, name, credential, creation_date, last_used FROM webauthn_credentials WHERE id=$1;
+    `, id)
+	credential, err := scanPostgreSQLWebAuthnCredential(row)
+	return credential, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) AddWebAuthnCredential(ctx context.Context, credential WebAuthnCredential) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        INSERT INTO webauthn_credentials (id, user_id, name, credential, creation_date, last_used)
+        VALUES ($1, $2, $3, $4, $5, $6);
+    `,
+		credential.ID,
+		credential.UserID,
+		credential.Name,
+		credential.Credential,
+		credential.CreationDate,
+		credential.LastUsed,
+	)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) UpdateWebAuthnCredential(ctx context.Context, credential WebAuthnCredential) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        UPDATE webauthn_credentials SET name=$1, credential=$2, last_used=$3 WHERE id=$4;
+    `, credential.Name, credential.Credential, credential.LastUsed, credential.ID)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) DeleteWebAuthnCredential(ctx context.Context, id string) error {
+	_, err := db.querier(ctx).Exec(ctx, `DELETE FROM webauthn_credentials WHERE id=$1;`, id)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) AccessTokens(ctx context.Context) ([]AccessToken, error) {
+	return db.AccessTokensPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) AccessTokensPage(ctx context.Context, limit, offset int) ([]AccessToken, error) {
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, user_id, name, token_hash, scopes, creation_date, expiration, last_used FROM access_tokens
+        ORDER BY id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	return collectPostgreSQLAccessTokens(rows)
+}
+
+func (db *PostgreSQLDatabase) UserAccessTokens(ctx context.Context, userID string) ([]AccessToken, error) {
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, user_id, name, token_hash, scopes, creation_date, expiration, last_used
+        FROM access_tokens WHERE user_id=$1 ORDER BY creation_date;
+    `, userID)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	return collectPostgreSQLAccessTokens(rows)
+}
+
+func (db *PostgreSQLDatabase) AccessToken(ctx context.Context, id string) (AccessToken, error) {
+	row := db.querier(ctx).QueryRow(ctx, `
+        SELECT id, user_id, name, token_hash, scopes, creation_date, expiration, last_used FROM access_tokens WHERE id=$1;
+    `, id)
+	token, err := scanPostgreSQLAccessToken(row)
+	return token, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) AccessTokenByHash(ctx context.Context, tokenHash string) (AccessToken, error) {
+	row := db.querier(ctx).QueryRow(ctx, `
+        SELECT id, user_id, name, token_hash, scopes, creation_date, expiration, last_used FROM access_tokens WHERE token_hash=$1;
+    `, tokenHash)
+	token, err := scanPostgreSQLAccessToken(row)
+	return token, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) AddAccessToken(ctx context.Context, token AccessToken) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        INSERT INTO access_tokens (id, user_id, name, token_hash, scopes, creation_date, expiration, last_used)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+    `,
+		token.ID,
+		token.UserID,
+		token.Name,
+		token.TokenHash,
+		token.Scopes,
+		token.CreationDate,
+		token.Expiration,
+		token.LastUsed,
+	)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) UpdateAccessToken(ctx context.Context, token AccessToken) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        UPDATE access_tokens SET name=$1, scopes=$2, expiration=$3, last_used=$4 WHERE id=$5;
+    `, token.Name, token.Scopes, token.Expiration, token.LastUsed, token.ID)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) DeleteAccessToken(ctx context.Context, id string) error {
+	_, err := db.querier(ctx).Exec(ctx, `DELETE FROM access_tokens WHERE id=$1;`, id)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) EmailTokens(ctx context.Context) ([]EmailToken, error) {
+	return db.EmailTokensPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) EmailTokensPage(ctx context.Context, limit, offset int) ([]EmailToken, error) {
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, user_id, purpose, token_hash, email, creation_date, expiration FROM email_tokens
+        ORDER BY id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	var tokens []EmailToken
+	defer rows.Close()
+	for rows.Next() {
+		token, err := scanPostgreSQLEmailToken(rows)
+		if err != nil {
+			return tokens, normalizePostgreSQLError(err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, normalizePostgreSQLError(rows.Err())
+}
+
+func (db *PostgreSQLDatabase) EmailTokenByHash(ctx context.Context, tokenHash string) (EmailToken, error) {
+	row := db.querier(ctx).QueryRow(ctx, `
+        SELECT id, user_id, purpose, token_hash, email, creation_date, expiration FROM email_tokens WHERE token_hash=$1;
+    `, tokenHash)
+	token, err := scanPostgreSQLEmailToken(row)
+	return token, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) AddEmailToken(ctx context.Context, token EmailToken) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        INSERT INTO email_tokens (id, user_id, purpose, token_hash, email, creation_date, expiration)
+        VALUES ($1, $2, $3, $4, $5, $6, $7);
+    `,
+		token.ID,
+		token.UserID,
+		token.Purpose,
+		token.TokenHash,
+		token.Email,
+		token.CreationDate,
+		token.Expiration,
+	)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) UseEmailToken(ctx context.Context, id string) (bool, error) {
+	tag, err := db.querier(ctx).Exec(ctx, `DELETE FROM email_tokens WHERE id=$1;`, id)
+	if err != nil {
+		return false, normalizePostgreSQLError(err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (db *PostgreSQLDatabase) DeleteEmailTokens(ctx context.Context, userID, purpose string) error {
+	_, err := db.querier(ctx).Exec(ctx, `DELETE FROM email_tokens WHERE user_id=$1 AND purpose=$2;`, userID, purpose)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) EmailVerifications(ctx context.Context) ([]EmailVerification, error) {
+	return db.EmailVerificationsPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) EmailVerificationsPage(
+	ctx context.Context,
+	limit, offset int,
+) ([]EmailVerification, error) {
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT user_id, email, verification_date FROM email_verifications
+        ORDER BY user_id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	var verifications []EmailVerification
+	defer rows.Close()
+	for rows.Next() {
+		verification, err := scanPostgreSQLEmailVerification(rows)
+		if err != nil {
+			return verifications, normalizePostgreSQLError(err)
+		}
+		verifications = append(verifications, verification)
+	}
+	return verifications, normalizePostgreSQLError(rows.Err())
+}
+
+func (db *PostgreSQLDatabase) EmailVerification(ctx context.Context, userID string) (EmailVerification, error) {
+	row := db.querier(ctx).QueryRow(ctx, `
+        SELECT user_id, email, verification_date FROM email_verifications WHERE user_id=$1;
+    `, userID)
+	verification, err := scanPostgreSQLEmailVerification(row)
+	return verification, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) SetEmailVerification(ctx context.Context, verification EmailVerification) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        INSERT INTO email_verifications (user_id, email, verification_date) VALUES ($1, $2, $3)
+        ON CONFLICT (user_id) DO UPDATE SET email=excluded.email, verification_date=excluded.verification_date;
+    `, verification.UserID, verification.Email, verification.VerificationDate)
+	return normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) AllAuditEvents(ctx context.Context) ([]AuditEvent, error) {
+	return db.AllAuditEventsPage(ctx, -1, 0)
+}
+
+func (db *PostgreSQLDatabase) AllAuditEventsPage(ctx context.Context, limit, offset int) ([]AuditEvent, error) {
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, action, user_id, username, ip, details, creation_date FROM audit_log
+        ORDER BY id LIMIT $1 OFFSET $2;
+    `, postgreSQLLimit(limit), offset)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	return collectPostgreSQLAuditEvents(rows)
+}
+
+func (db *PostgreSQLDatabase) AuditEvents(ctx context.Context, limit, offset int) ([]AuditEvent, error) {
+	rows, err := db.querier(ctx).Query(ctx, `
+        SELECT id, action, user_id, username, ip, details, creation_date FROM audit_log
+        ORDER BY creation_date DESC, id DESC
+        LIMIT $1 OFFSET $2;
+    `, limit, offset)
+	if err != nil {
+		return nil, normalizePostgreSQLError(err)
+	}
+	return collectPostgreSQLAuditEvents(rows)
+}
+
+func (db *PostgreSQLDatabase) AuditEvent(ctx context.Context, id string) (AuditEvent, error) {
+	row := db.querier(ctx).QueryRow(ctx, `
+        SELECT id, action, user_id, username, ip, details, creation_date FROM audit_log WHERE id=$1;
+    `, id)
+	event, err := scanPostgreSQLAuditEvent(row)
+	return event, normalizePostgreSQLError(err)
+}
+
+func (db *PostgreSQLDatabase) AddAuditEvent(ctx context.Context, event AuditEvent) error {
+	_, err := db.querier(ctx).Exec(ctx, `
+        INSERT INTO audit_log (id, action, user_id, username, ip, details, creation_date)
+        VALUES ($1, $2, $3, $4, $5, $6, $7);
+    `,
+		event.ID,
+		event.Action,
+		event.UserID,
+		event.Username,
+		event.IP,
+		event.Details,
+		event.CreationDate,
+	)
+	return normalizePostgreSQLError(err)
+}
+
+func scanPostgreSQLRefreshToken(row pgx.Row) (RefreshToken, error) {
+	token := RefreshToken{}
+	err := row.Scan(
+		&token.TokenHash,
+		&token.UserID,
+		&token.FamilyID,
+		&token.CreationDate,
+		&token.Expiration,
+		&token.UsedAt,
+		&token.Revoked,
+	)
+	return token, err
+}
+
+func scanPostgreSQLTOTPSecret(row pgx.Row) (TOTPSecret, error) {
+	secret := TOTPSecret{}
+	err := row.Scan(&secret.UserID, &secret.Secret, &secret.Enabled, &secret.LastStep, &secret.CreationDate)
+	return secret, err
+}
+
+func scanPostgreSQLWebAuthnCredential(row pgx.Row) (WebAuthnCredential, error) {
+	credential := WebAuthnCredential{}
+	err := row.Scan(
+		&credential.ID,
+		&credential.UserID,
+		&credential.Name,
+		&credential.Credential,
+		&credential.CreationDate,
+		&credential.LastUsed,
+	)
+	return credential, err
+}
+
+func collectPostgreSQLWebAuthnCredentials(rows pgx.Rows) ([]WebAuthnCredential, error) {
+	var credentials []WebAuthnCredential
+	defer rows.Close()
+	for rows.Next() {
+		credential, err := scanPostgreSQLWebAuthnCredential(rows)
+		if err != nil {
+			return credentials, normalizePostgreSQLError(err)
+		}
+		credentials = append(credentials, credential)
+	}
+	return credentials, normalizePostgreSQLError(rows.Err())
+}
+
+func scanPostgreSQLAccessToken(row pgx.Row) (AccessToken, error) {
+	token := AccessToken{}
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.TokenHash,
+		&token.Scopes,
+		&token.CreationDate,
+		&token.Expiration,
+		&token.LastUsed,
+	)
+	return token, err
+}
+
+func collectPostgreSQLAccessTokens(rows pgx.Rows) ([]AccessToken, error) {
+	var tokens []AccessToken
+	defer rows.Close()
+	for rows.Next() {
+		token, err := scanPostgreSQLAccessToken(rows)
+		if err != nil {
+			return tokens, normalizePostgreSQLError(err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, normalizePostgreSQLError(rows.Err())
+}
+
+func scanPostgreSQLEmailToken(row pgx.Row) (EmailToken, error) {
+	token := EmailToken{}
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Purpose,
+		&token.TokenHash,
+		&token.Email,
+		&token.CreationDate,
+		&token.Expiration,
+	)
+	return token, err
+}
+
+func scanPostgreSQLEmailVerification(row pgx.Row) (EmailVerification, error) {
+	verification := EmailVerification{}
+	err := row.Scan(&verification.UserID, &verification.Email, &verification.VerificationDate)
+	return verification, err
+}
+
+func scanPostgreSQLAuditEvent(row pgx.Row) (AuditEvent, error) {
+	event := AuditEvent{}
+	err := row.Scan(
+		&event.ID,
+		&event.Action,
+		&event.UserID,
+		&event.Username,
+		&event.IP,
+		&event.Details,
+		&event.CreationDate,
+	)
+	return event, err
+}
+
+func collectPostgreSQLAuditEvents(rows pgx.Rows) ([]AuditEvent, error) {
+	var events []AuditEvent
+	defer rows.Close()
+	for rows.Next() {
+		event, err := scanPostgreSQLAuditEvent(rows)
+		if err != nil {
+			return events, normalizePostgreSQLError(err)
+		}
+		events = append(events, event)
+	}
+	return events, normalizePostgreSQLError(rows.Err())
+}
+
+func scanPostgreSQLSession(row pgx.Row) (Session, error) {
+	session := Session{}
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.DeviceName,
+		&session.UserAgent,
+		&session.IP,
+		&session.CreationDate,
+		&session.LastUsed,
+		&session.Expiration,
+	)
+	return session, err
+}
+
+func collectPostgreSQLSessions(rows pgx.Rows) ([]Session, error) {
+	var sessions []Session
+	defer rows.Close()
+	for rows.Next() {
+		session, err := scanPostgreSQLSession(rows)
+		if err != nil {
+			return sessions, normalizePostgreSQLError(err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, normalizePostgreSQLError(rows.Err())
+}
+
+func scanPostgreSQLTrack(row pgx.Row) (media.Track, error) {
+	track := media.Track{}
+	err := row.Scan(
+		&track.ID,
+		&track.UserID,
+		&track.ISRC,
+		&track.Title,
+		&track.ArtistIDs,
+		&track.AlbumIDs,
+		&track.PrimaryAlbumID,
+		&track.TrackNumber,
+		&track.Duration,
+		&track.Description,
+		&track.ReleaseDate,
+		&track.Lyrics,
+		&track.ListenCount,
+		&track.FavoriteCount,
+		&track.AdditionDate,
+		&track.Tags,
+		&track.AdditionalMeta,
+		&track.Permissions,
+		&track.LinkedItemIDs,
+		&track.ContentSource,
+		&track.MetadataSource,
+		&track.LyricSources,
+	)
+	return track, err
+}
+
+func scanPostgreSQLListen(row pgx.Row) (media.Listen, error) {
+	listen := media.Listen{}
+	err := row.Scan(
+		&listen.ID,
+		&listen.UserID,
+		&listen.PlayableID,
+		&listen.PlayableType,
+		&listen.ListenedAt,
+		&listen.DurationPlayed,
+		&listen.Client,
+		&listen.Source,
+		&listen.TrackName,
+		&listen.ArtistName,
+		&listen.ReleaseName,
+		&listen.RecordingMBID,
+		&listen.ISRC,
+	)
+	return listen, err
+}
+
+func scanPostgreSQLFavorite(row pgx.Row) (media.Favorite, error) {
+	favorite := media.Favorite{}
+	err := row.Scan(&favorite.UserID, &favorite.PlayableID, &favorite.PlayableType, &favorite.FavoritedAt)
+	return favorite, err
+}
+
+func scanPostgreSQLScrobble(row pgx.Row) (Scrobble, error) {
+	scrobble := Scrobble{}
+	err := row.Scan(
+		&scrobble.ID,
+		&scrobble.UserID,
+		&scrobble.ListenID,
+		&scrobble.Scrobbler,
+		&scrobble.Attempts,
+		&scrobble.NextAttemptAt,
+		&scrobble.LastError,
+		&scrobble.Failed,
+		&scrobble.CreationDate,
+	)
+	return scrobble, err
+}
+
+func collectPostgreSQLScrobbles(rows pgx.Rows) ([]Scrobble, error) {
+	var scrobbles []Scrobble
+	defer rows.Close()
+	for rows.Next() {
+		scrobble, err := scanPostgreSQLScrobble(rows)
+		if err != nil {
+			return scrobbles, normalizePostgreSQLError(err)
+		}
+		scrobbles = append(scrobbles, scrobble)
+	}
+	return scrobbles, normalizePostgreSQLError(rows.Err())
+}
+
+func scanPostgreSQLPlayQueue(row pgx.Row) (media.PlayQueue, error) {
+	queue := media.PlayQueue{}
+	err := row.Scan(
+		&queue.UserID,
+		&queue.Items,
+		&queue.CurrentIndex,
+		&queue.PositionMS,
+		&queue.Shuffle,
+		&queue.Repeat,
+		&queue.Context.Type,
+		&queue.Context.ID,
+		&queue.Device,
+		&queue.Revision,
+		&queue.UpdatedAt,
+	)
+	return queue, err
+}
+
+// postgreSQLPlayQueueArgs returns the columns of a play queue in table order.
+func postgreSQLPlayQueueArgs(queue media.PlayQueue) []any {
+	return []any{
+		queue.UserID, queue.Items, queue.CurrentIndex, queue.PositionMS, queue.Shuffle, queue.Repeat,
+		queue.Context.Type, queue.Context.ID, queue.Device, queue.Revision, queue.UpdatedAt,
+	}
+}
+
+func normalizePostgreSQLError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func init() {
+	db := &PostgreSQLDatabase{}
+	Registry["postgresql"] = db
+}
+
+type postgreSQLDialect struct{}
+
+func (postgreSQLDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (postgreSQLDialect) ListContains(column, value string) string {
+	return value + "::text = ANY(COALESCE(" + column + ", '{}'))"
+}
+
+func (postgreSQLDialect) ContainsFold(expr, value string) string {
+	return "strpos(lower(" + expr + "), lower(" + value + "::text)) > 0"
+}
+
+func (postgreSQLDialect) Random() string {
+	return "random()"
 }
@@ -4,6 +4,8 @@ package db
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"path/filepath"
@@ -20,11 +22,13 @@ import (
 
 	"github.com/libramusic/libracore/config"
 	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/rules"
 )
 
 type SQLiteDatabase struct {
 	pool      *sqlitex.Pool
 	closeOnce sync.Once
+	dsn       string
 }
 
 func (*SQLiteDatabase) EngineName() string {
@@ -38,13 +42,19 @@ func (*SQLiteDatabase) Satisfies(engine string) bool {
 	}, strings.ToLower(engine))
 }
 
+func (*SQLiteDatabase) WithDSN(dsn string) Database {
+	return &SQLiteDatabase{dsn: dsn}
+}
+
 func (db *SQLiteDatabase) Connect() error {
 	log.Info("Connecting to SQLite...")
 	dbPath := config.Conf.Database.SQLite.Path
-	if !filepath.IsAbs(dbPath) && config.DataDir != "" {
+	if db.dsn != "" {
+		dbPath = db.dsn
+	} else if !filepath.IsAbs(dbPath) && config.DataDir != "" {
 		dbPath = filepath.Join(config.DataDir, dbPath)
 	}
-	pool, err := sqlitex.NewPool(dbPath, sqlitex.PoolOptions{})
+	pool, err := sqlitex.NewPool(dbPath, sqlitex.PoolOptions{PrepareConn: prepareSQLiteConn})
 	db.pool = pool
 	if err != nil {
 		return err
@@ -67,6 +77,18 @@ func (db *SQLiteDatabase) Connect() error {
 	return nil
 }
 
+// prepareSQLiteConn registers the functions migrations rely on that SQLite doesn't have.
+func prepareSQLiteConn(conn *sqlite.Conn) error {
+	return conn.CreateFunction("sha256_hex", &sqlite.FunctionImpl{
+		NArgs:         1,
+		Deterministic: true,
+		Scalar: func(_ sqlite.Context, args []sqlite.Value) (sqlite.Value, error) {
+			sum := sha256.Sum256([]byte(args[0].Text()))
+			return sqlite.TextValue(hex.EncodeToString(sum[:])), nil
+		},
+	})
+}
+
 func (db *SQLiteDatabase) Close() error {
 	var err error
 	db.closeOnce.Do(func() {
@@ -76,13 +98,77 @@ func (db *SQLiteDatabase) Close() error {
 	return err
 }
 
-func (db *SQLiteDatabase) migrationsTableExists(ctx context.Context) (bool, error) {
+// sqliteTxKey is the context key of the connection a transaction of db runs on.
+type sqliteTxKey struct{ db *SQLiteDatabase }
+
+func (db *SQLiteDatabase) Transaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(sqliteTxKey{db}).(*sqlite.Conn); ok {
+		return fn(ctx)
+	}
+
 	conn, err := db.pool.Take(ctx)
 	if err != nil {
-		return false, err
+		return err
 	}
 	defer db.pool.Put(conn)
 
+	// Take the write lock right away, so the transaction can't fail later because another one wrote first.
+	endFn, err := sqlitex.ImmediateTransaction(conn)
+	if err != nil {
+		return err
+	}
+	defer endFn(&err)
+
+	return fn(context.WithValue(ctx, sqliteTxKey{db}, conn))
+}
+
+// take returns the connection of the transaction ctx is in, or one from the pool if it isn't in one.
+func (db *SQLiteDatabase) take(ctx context.Context) (*sqlite.Conn, error) {
+	if conn, ok := ctx.Value(sqliteTxKey{db}).(*sqlite.Conn); ok {
+		return conn, nil
+	}
+	return db.pool.Take(ctx)
+}
+
+// put returns a connection from take to the pool, unless it belongs to the transaction ctx is in.
+func (db *SQLiteDatabase) put(ctx context.Context, conn *sqlite.Conn) {
+	if txConn, ok := ctx.Value(sqliteTxKey{db}).(*sqlite.Conn); ok && txConn == conn {
+		return
+	}
+	db.pool.Put(conn)
+}
+
+func (db *SQLiteDatabase) CountRows(ctx context.Context, table string) (int, error) {
+	if err := checkTable(table); err != nil {
+		return 0, err
+	}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer db.put(ctx, conn)
+
+	var count int
+	err = sqlitex.Execute(conn,
+		`SELECT COUNT(*) FROM `+table+`;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				count = stmt.ColumnInt(0)
+				return nil
+			},
+		},
+	)
+	return count, err
+}
+
+func (db *SQLiteDatabase) migrationsTableExists(ctx context.Context) (bool, error) {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer db.put(ctx, conn)
+
 	var exists bool
 	err = sqlitex.Execute(conn,
 		`SELECT name FROM sqlite_master WHERE type='table' AND name='schema_migrations';`,
@@ -97,11 +183,11 @@ func (db *SQLiteDatabase) migrationsTableExists(ctx context.Context) (bool, erro
 }
 
 func (db *SQLiteDatabase) createMigrationsTable(ctx context.Context) error {
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         CREATE TABLE IF NOT EXISTS schema_migrations (
@@ -114,11 +200,11 @@ func (db *SQLiteDatabase) createMigrationsTable(ctx context.Context) error {
 }
 
 func (db *SQLiteDatabase) currentVersion(ctx context.Context) (uint64, bool, error) {
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return 0, false, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	var version uint64
 	var dirty bool
@@ -148,11 +234,11 @@ func (db *SQLiteDatabase) currentVersion(ctx context.Context) (uint64, bool, err
 }
 
 func (db *SQLiteDatabase) setVersion(ctx context.Context, version uint64, dirty bool) error {
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn,
 		`DELETE FROM schema_migrations;`,
@@ -171,6 +257,21 @@ func (db *SQLiteDatabase) setVersion(ctx context.Context, version uint64, dirty
 	return err
 }
 
+func (*SQLiteDatabase) MigrationsDir() string {
+	return "migrations/sqlite"
+}
+
+func (db *SQLiteDatabase) ForceVersion(ctx context.Context, version uint64) error {
+	if err := db.createMigrationsTable(ctx); err != nil {
+		return err
+	}
+	return db.setVersion(ctx, version, false)
+}
+
+func (db *SQLiteDatabase) MigrationVersion(ctx context.Context) (uint64, bool, error) {
+	return db.currentVersion(ctx)
+}
+
 func (db *SQLiteDatabase) MigrateUp(steps int) error {
 	if err := db.createMigrationsTable(context.Background()); err != nil {
 		return err
@@ -184,7 +285,7 @@ func (db *SQLiteDatabase) MigrateUp(steps int) error {
 		return errors.New("database is in dirty state")
 	}
 
-	entries, err := migrationsFS.ReadDir("migrations/sqlite")
+	entries, err := migrationsFS.ReadDir(db.MigrationsDir())
 	if err != nil {
 		return err
 	}
@@ -212,7 +313,7 @@ func (db *SQLiteDatabase) MigrateUp(steps int) error {
 		}
 
 		// Read and execute migration.
-		content, err := migrationsFS.ReadFile(filepath.Join("migrations/sqlite", file))
+		content, err := migrationsFS.ReadFile(filepath.Join(db.MigrationsDir(), file))
 		if err != nil {
 			return err
 		}
@@ -251,14 +352,14 @@ func (db *SQLiteDatabase) MigrateDown(steps int) error {
 		return errors.New("database is in dirty state")
 	}
 
-	entries, err := migrationsFS.ReadDir("migrations/sqlite")
+	entries, err := migrationsFS.ReadDir(db.MigrationsDir())
 	if err != nil {
 		return err
 	}
 	files := OrderedMigrationFiles(entries, false)
 
 	appliedCount := 0
-	for _, file := range files {
+	for i, file := range files {
 		versionStr := strings.Split(file, "_")[0]
 		version, err := strconv.ParseUint(versionStr, 10, 64)
 		if err != nil {
@@ -279,7 +380,7 @@ func (db *SQLiteDatabase) MigrateDown(steps int) error {
 		}
 
 		// Read and execute migration.
-		content, err := migrationsFS.ReadFile(filepath.Join("migrations/sqlite", file))
+		content, err := migrationsFS.ReadFile(filepath.Join(db.MigrationsDir(), file))
 		if err != nil {
 			return err
 		}
@@ -296,8 +397,8 @@ func (db *SQLiteDatabase) MigrateDown(steps int) error {
 
 		// Set version to previous migration and clear dirty flag.
 		prevVersion := uint64(0)
-		if appliedCount < len(files)-1 {
-			prevVersionStr := strings.Split(files[appliedCount+1], "_")[0]
+		if i < len(files)-1 {
+			prevVersionStr := strings.Split(files[i+1], "_")[0]
 			prevVersion, err = strconv.ParseUint(prevVersionStr, 10, 64)
 			if err != nil {
 				return err
@@ -314,17 +415,21 @@ func (db *SQLiteDatabase) MigrateDown(steps int) error {
 }
 
 func (db *SQLiteDatabase) AllTracks(ctx context.Context) ([]media.Track, error) {
+	return db.AllTracksPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) AllTracksPage(ctx context.Context, limit, offset int) ([]media.Track, error) {
 	var tracks []media.Track
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return tracks, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         SELECT id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
-        FROM tracks;`,
+        FROM tracks ORDER BY id LIMIT ? OFFSET ?;`,
 		&sqlitex.ExecOptions{
 			ResultFunc: func(stmt *sqlite.Stmt) error {
 				track := media.Track{}
@@ -372,6 +477,7 @@ func (db *SQLiteDatabase) AllTracks(ctx context.Context) ([]media.Track, error)
 
 				return nil
 			},
+			Args: []any{limit, offset},
 		},
 	)
 
@@ -381,11 +487,11 @@ func (db *SQLiteDatabase) AllTracks(ctx context.Context) ([]media.Track, error)
 func (db *SQLiteDatabase) Tracks(ctx context.Context, userID string) ([]media.Track, error) {
 	var tracks []media.Track
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return tracks, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         SELECT id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
@@ -447,11 +553,11 @@ func (db *SQLiteDatabase) Tracks(ctx context.Context, userID string) ([]media.Tr
 func (db *SQLiteDatabase) Track(ctx context.Context, id string) (media.Track, error) {
 	track := media.Track{}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return track, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	scanned := false
 	err = sqlitex.Execute(conn, `
@@ -518,6 +624,64 @@ func (db *SQLiteDatabase) Track(ctx context.Context, id string) (media.Track, er
 	return track, nil
 }
 
+func (db *SQLiteDatabase) TrackByRecordingMBID(ctx context.Context, mbid string) (media.Track, error) {
+	tracks, err := db.tracksWhere(ctx, `
+        CASE WHEN json_valid(additional_meta)
+            THEN lower(json_extract(additional_meta, '$.musicbrainz_recording_id')) END = lower(?)
+        ORDER BY id LIMIT 1`, mbid)
+	if err != nil {
+		return media.Track{}, err
+	}
+	if len(tracks) == 0 {
+		return media.Track{}, ErrNotFound
+	}
+	return tracks[0], nil
+}
+
+func (db *SQLiteDatabase) TrackByISRC(ctx context.Context, isrc string) (media.Track, error) {
+	tracks, err := db.tracksWhere(ctx, `upper(isrc) = upper(?) ORDER BY id LIMIT 1`, isrc)
+	if err != nil {
+		return media.Track{}, err
+	}
+	if len(tracks) == 0 {
+		return media.Track{}, ErrNotFound
+	}
+	return tracks[0], nil
+}
+
+func (db *SQLiteDatabase) TracksByTitle(ctx context.Context, title string) ([]media.Track, error) {
+	return db.tracksWhere(ctx, `lower(title) = lower(?) ORDER BY id`, title)
+}
+
+// tracksWhere returns the tracks matching condition, which may also order and limit them.
+func (db *SQLiteDatabase) tracksWhere(ctx context.Context, condition string, args ...any) ([]media.Track, error) {
+	var tracks []media.Track
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return tracks, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, isrc, title, artist_ids, album_ids, primary_album_id, track_number, duration, description, release_date, lyrics, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
+        FROM tracks WHERE `+condition+`;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				track, err := scanSQLiteTrack(stmt)
+				if err != nil {
+					return err
+				}
+				tracks = append(tracks, track)
+				return nil
+			},
+			Args: args,
+		},
+	)
+
+	return tracks, err
+}
+
 func (db *SQLiteDatabase) AddTrack(ctx context.Context, track media.Track) error {
 	// Convert JSON fields to strings.
 	artistIDs, err := json.Marshal(track.ArtistIDs)
@@ -553,11 +717,11 @@ func (db *SQLiteDatabase) AddTrack(ctx context.Context, track media.Track) error
 		return fmt.Errorf("failed to marshal lyric_sources: %w", err)
 	}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         INSERT INTO tracks (
@@ -614,11 +778,11 @@ func (db *SQLiteDatabase) UpdateTrack(ctx context.Context, track media.Track) er
 		return fmt.Errorf("failed to marshal lyric_sources: %w", err)
 	}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         UPDATE tracks
@@ -644,11 +808,11 @@ func (db *SQLiteDatabase) UpdateTrack(ctx context.Context, track media.Track) er
 }
 
 func (db *SQLiteDatabase) DeleteTrack(ctx context.Context, id string) error {
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn,
 		`DELETE FROM tracks WHERE id = ?;`,
@@ -660,17 +824,21 @@ func (db *SQLiteDatabase) DeleteTrack(ctx context.Context, id string) error {
 }
 
 func (db *SQLiteDatabase) AllAlbums(ctx context.Context) ([]media.Album, error) {
+	return db.AllAlbumsPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) AllAlbumsPage(ctx context.Context, limit, offset int) ([]media.Album, error) {
 	var albums []media.Album
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return albums, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         SELECT id, user_id, upc, ean, title, artist_ids, track_ids, description, release_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
-        FROM albums;`,
+        FROM albums ORDER BY id LIMIT ? OFFSET ?;`,
 		&sqlitex.ExecOptions{
 			ResultFunc: func(stmt *sqlite.Stmt) error {
 				album := media.Album{}
@@ -709,6 +877,7 @@ func (db *SQLiteDatabase) AllAlbums(ctx context.Context) ([]media.Album, error)
 
 				return nil
 			},
+			Args: []any{limit, offset},
 		},
 	)
 
@@ -718,11 +887,11 @@ func (db *SQLiteDatabase) AllAlbums(ctx context.Context) ([]media.Album, error)
 func (db *SQLiteDatabase) Albums(ctx context.Context, userID string) ([]media.Album, error) {
 	var albums []media.Album
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return albums, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         SELECT id, user_id, upc, ean, title, artist_ids, track_ids, description, release_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
@@ -775,11 +944,11 @@ func (db *SQLiteDatabase) Albums(ctx context.Context, userID string) ([]media.Al
 func (db *SQLiteDatabase) Album(ctx context.Context, id string) (media.Album, error) {
 	album := media.Album{}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return album, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	scanned := false
 	err = sqlitex.Execute(conn, `
@@ -864,11 +1033,11 @@ func (db *SQLiteDatabase) AddAlbum(ctx context.Context, album media.Album) error
 		return fmt.Errorf("failed to marshal linked_item_ids: %w", err)
 	}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         INSERT INTO albums (
@@ -916,11 +1085,11 @@ func (db *SQLiteDatabase) UpdateAlbum(ctx context.Context, album media.Album) er
 		return fmt.Errorf("failed to marshal linked_item_ids: %w", err)
 	}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         UPDATE albums
@@ -942,11 +1111,11 @@ func (db *SQLiteDatabase) UpdateAlbum(ctx context.Context, album media.Album) er
 }
 
 func (db *SQLiteDatabase) DeleteAlbum(ctx context.Context, id string) error {
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn,
 		`DELETE FROM albums WHERE id = ?;`,
@@ -958,17 +1127,21 @@ func (db *SQLiteDatabase) DeleteAlbum(ctx context.Context, id string) error {
 }
 
 func (db *SQLiteDatabase) AllVideos(ctx context.Context) ([]media.Video, error) {
+	return db.AllVideosPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) AllVideosPage(ctx context.Context, limit, offset int) ([]media.Video, error) {
 	var videos []media.Video
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return videos, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         SELECT id, user_id, title, artist_ids, duration, description, release_date, subtitles, watch_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
-        FROM videos;`,
+        FROM videos ORDER BY id LIMIT ? OFFSET ?;`,
 		&sqlitex.ExecOptions{
 			ResultFunc: func(stmt *sqlite.Stmt) error {
 				video := media.Video{}
@@ -1010,6 +1183,7 @@ func (db *SQLiteDatabase) AllVideos(ctx context.Context) ([]media.Video, error)
 
 				return nil
 			},
+			Args: []any{limit, offset},
 		},
 	)
 
@@ -1019,11 +1193,11 @@ func (db *SQLiteDatabase) AllVideos(ctx context.Context) ([]media.Video, error)
 func (db *SQLiteDatabase) Videos(ctx context.Context, userID string) ([]media.Video, error) {
 	var videos []media.Video
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return videos, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         SELECT id, user_id, title, artist_ids, duration, description, release_date, subtitles, watch_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, content_source, metadata_source, lyric_sources
@@ -1079,11 +1253,11 @@ func (db *SQLiteDatabase) Videos(ctx context.Context, userID string) ([]media.Vi
 func (db *SQLiteDatabase) Video(ctx context.Context, id string) (media.Video, error) {
 	video := media.Video{}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return video, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	scanned := false
 	err = sqlitex.Execute(conn, `
@@ -1175,11 +1349,11 @@ func (db *SQLiteDatabase) AddVideo(ctx context.Context, video media.Video) error
 		return fmt.Errorf("failed to marshal lyric_sources: %w", err)
 	}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         INSERT INTO videos (
@@ -1232,11 +1406,11 @@ func (db *SQLiteDatabase) UpdateVideo(ctx context.Context, video media.Video) er
 		return fmt.Errorf("failed to marshal lyric_sources: %w", err)
 	}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         UPDATE videos
@@ -1260,11 +1434,11 @@ func (db *SQLiteDatabase) UpdateVideo(ctx context.Context, video media.Video) er
 }
 
 func (db *SQLiteDatabase) DeleteVideo(ctx context.Context, id string) error {
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn,
 		`DELETE FROM videos WHERE id = ?;`,
@@ -1276,17 +1450,21 @@ func (db *SQLiteDatabase) DeleteVideo(ctx context.Context, id string) error {
 }
 
 func (db *SQLiteDatabase) AllArtists(ctx context.Context) ([]media.Artist, error) {
+	return db.AllArtistsPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) AllArtistsPage(ctx context.Context, limit, offset int) ([]media.Artist, error) {
 	var artists []media.Artist
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return artists, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         SELECT id, user_id, name, album_ids, track_ids, description, creation_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
-        FROM artists;`,
+        FROM artists ORDER BY id LIMIT ? OFFSET ?;`,
 		&sqlitex.ExecOptions{
 			ResultFunc: func(stmt *sqlite.Stmt) error {
 				artist := media.Artist{}
@@ -1323,6 +1501,7 @@ func (db *SQLiteDatabase) AllArtists(ctx context.Context) ([]media.Artist, error
 
 				return nil
 			},
+			Args: []any{limit, offset},
 		},
 	)
 
@@ -1332,11 +1511,11 @@ func (db *SQLiteDatabase) AllArtists(ctx context.Context) ([]media.Artist, error
 func (db *SQLiteDatabase) Artists(ctx context.Context, userID string) ([]media.Artist, error) {
 	var artists []media.Artist
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return artists, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         SELECT id, user_id, name, album_ids, track_ids, description, creation_date, listen_count, favorite_count, addition_date, tags, additional_meta, permissions, linked_item_ids, metadata_source
@@ -1387,11 +1566,11 @@ func (db *SQLiteDatabase) Artists(ctx context.Context, userID string) ([]media.A
 func (db *SQLiteDatabase) Artist(ctx context.Context, id string) (media.Artist, error) {
 	artist := media.Artist{}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return artist, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	scanned := false
 	err = sqlitex.Execute(conn, `
@@ -1474,11 +1653,11 @@ func (db *SQLiteDatabase) AddArtist(ctx context.Context, artist media.Artist) er
 		return fmt.Errorf("failed to marshal linked_item_ids: %w", err)
 	}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         INSERT INTO artists (
@@ -1526,11 +1705,11 @@ func (db *SQLiteDatabase) UpdateArtist(ctx context.Context, artist media.Artist)
 		return fmt.Errorf("failed to marshal linked_item_ids: %w", err)
 	}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         UPDATE artists
@@ -1552,11 +1731,11 @@ func (db *SQLiteDatabase) UpdateArtist(ctx context.Context, artist media.Artist)
 }
 
 func (db *SQLiteDatabase) DeleteArtist(ctx context.Context, id string) error {
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn,
 		`DELETE FROM artists WHERE id = ?;`,
@@ -1568,17 +1747,21 @@ func (db *SQLiteDatabase) DeleteArtist(ctx context.Context, id string) error {
 }
 
 func (db *SQLiteDatabase) AllPlaylists(ctx context.Context) ([]media.Playlist, error) {
+	return db.AllPlaylistsPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) AllPlaylistsPage(ctx context.Context, limit, offset int) ([]media.Playlist, error) {
 	var playlists []media.Playlist
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return playlists, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
-        SELECT id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source
-        FROM playlists;`,
+        SELECT id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source, revision, entries, visibility, kind, rules, evaluated_at
+        FROM playlists ORDER BY id LIMIT ? OFFSET ?;`,
 		&sqlitex.ExecOptions{
 			ResultFunc: func(stmt *sqlite.Stmt) error {
 				playlist := media.Playlist{}
@@ -1604,11 +1787,20 @@ func (db *SQLiteDatabase) AllPlaylists(ctx context.Context) ([]media.Playlist, e
 					return fmt.Errorf("failed to parse permissions: %w", err)
 				}
 				playlist.MetadataSource = stmt.ColumnText(12)
+				playlist.Revision = stmt.ColumnInt(13)
+				if err := json.Unmarshal([]byte(stmt.ColumnText(14)), &playlist.Entries); err != nil {
+					return fmt.Errorf("failed to parse entries: %w", err)
+				}
+				playlist.Visibility = stmt.ColumnText(15)
+				playlist.Kind = stmt.ColumnText(16)
+				playlist.Rules = stmt.ColumnText(17)
+				playlist.EvaluatedAt = stmt.ColumnInt64(18)
 
 				playlists = append(playlists, playlist)
 
 				return nil
 			},
+			Args: []any{limit, offset},
 		},
 	)
 
@@ -1618,14 +1810,14 @@ func (db *SQLiteDatabase) AllPlaylists(ctx context.Context) ([]media.Playlist, e
 func (db *SQLiteDatabase) Playlists(ctx context.Context, userID string) ([]media.Playlist, error) {
 	var playlists []media.Playlist
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return playlists, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
-        SELECT id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source
+        SELECT id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source, revision, entries, visibility, kind, rules, evaluated_at
         FROM playlists WHERE user_id = ?;`,
 		&sqlitex.ExecOptions{
 			ResultFunc: func(stmt *sqlite.Stmt) error {
@@ -1652,6 +1844,14 @@ func (db *SQLiteDatabase) Playlists(ctx context.Context, userID string) ([]media
 					return fmt.Errorf("failed to parse permissions: %w", err)
 				}
 				playlist.MetadataSource = stmt.ColumnText(12)
+				playlist.Revision = stmt.ColumnInt(13)
+				if err := json.Unmarshal([]byte(stmt.ColumnText(14)), &playlist.Entries); err != nil {
+					return fmt.Errorf("failed to parse entries: %w", err)
+				}
+				playlist.Visibility = stmt.ColumnText(15)
+				playlist.Kind = stmt.ColumnText(16)
+				playlist.Rules = stmt.ColumnText(17)
+				playlist.EvaluatedAt = stmt.ColumnInt64(18)
 
 				playlists = append(playlists, playlist)
 
@@ -1667,15 +1867,15 @@ func (db *SQLiteDatabase) Playlists(ctx context.Context, userID string) ([]media
 func (db *SQLiteDatabase) Playlist(ctx context.Context, id string) (media.Playlist, error) {
 	playlist := media.Playlist{}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return playlist, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	scanned := false
 	err = sqlitex.Execute(conn, `
-        SELECT id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source
+        SELECT id, user_id, title, track_ids, listen_count, favorite_count, description, creation_date, addition_date, tags, additional_meta, permissions, metadata_source, revision, entries, visibility, kind, rules, evaluated_at
         FROM playlists WHERE id = ?;`,
 		&sqlitex.ExecOptions{
 			ResultFunc: func(stmt *sqlite.Stmt) error {
@@ -1705,6 +1905,14 @@ func (db *SQLiteDatabase) Playlist(ctx context.Context, id string) (media.Playli
 					return fmt.Errorf("failed to parse permissions: %w", err)
 				}
 				playlist.MetadataSource = stmt.ColumnText(12)
+				playlist.Revision = stmt.ColumnInt(13)
+				if err := json.Unmarshal([]byte(stmt.ColumnText(14)), &playlist.Entries); err != nil {
+					return fmt.Errorf("failed to parse entries: %w", err)
+				}
+				playlist.Visibility = stmt.ColumnText(15)
+				playlist.Kind = stmt.ColumnText(16)
+				playlist.Rules = stmt.ColumnText(17)
+				playlist.EvaluatedAt = stmt.ColumnInt64(18)
 
 				return nil
 			},
@@ -1739,19 +1947,24 @@ func (db *SQLiteDatabase) AddPlaylist(ctx context.Context, playlist media.Playli
 	if err != nil {
 		return fmt.Errorf("failed to marshal permissions: %w", err)
 	}
+	entries, err := json.Marshal(playlist.Entries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal entries: %w", err)
+	}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         INSERT INTO playlists (
             id, user_id, title, track_ids, listen_count, favorite_count, description,
-            creation_date, addition_date, tags, additional_meta, permissions, metadata_source
+            creation_date, addition_date, tags, additional_meta, permissions, metadata_source, revision,
+            entries, visibility, kind, rules, evaluated_at
         ) VALUES (
-            ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+            ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
         );`,
 		&sqlitex.ExecOptions{
 			Args: []any{
@@ -1759,6 +1972,8 @@ func (db *SQLiteDatabase) AddPlaylist(ctx context.Context, playlist media.Playli
 				playlist.ListenCount, playlist.FavoriteCount, playlist.Description,
 				playlist.CreationDate, playlist.AdditionDate, string(tags),
 				string(additionalMeta), string(permissions), playlist.MetadataSource,
+				playlist.Revision, string(entries), playlist.Visibility, playlist.Kind, playlist.Rules,
+				playlist.EvaluatedAt,
 			},
 		},
 	)
@@ -1767,56 +1982,112 @@ func (db *SQLiteDatabase) AddPlaylist(ctx context.Context, playlist media.Playli
 }
 
 func (db *SQLiteDatabase) UpdatePlaylist(ctx context.Context, playlist media.Playlist) error {
+	_, err := db.updatePlaylist(ctx, playlist, "")
+	return err
+}
+
+func (db *SQLiteDatabase) UpdatePlaylistAtRevision(ctx context.Context, playlist media.Playlist, revision int) error {
+	updated, err := db.updatePlaylist(ctx, playlist, " AND revision=?", revision)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrRevisionMismatch
+	}
+	return nil
+}
+
+// updatePlaylist stores the playlist if it matches condition and reports whether it was stored.
+func (db *SQLiteDatabase) updatePlaylist(
+	ctx context.Context,
+	playlist media.Playlist,
+	condition string,
+	args ...any,
+) (bool, error) {
 	// Convert JSON fields to strings.
 	trackIDs, err := json.Marshal(playlist.TrackIDs)
 	if err != nil {
-		return fmt.Errorf("failed to marshal track_ids: %w", err)
+		return false, fmt.Errorf("failed to marshal track_ids: %w", err)
 	}
 	tags, err := json.Marshal(playlist.Tags)
 	if err != nil {
-		return fmt.Errorf("failed to marshal tags: %w", err)
+		return false, fmt.Errorf("failed to marshal tags: %w", err)
 	}
 	additionalMeta, err := json.Marshal(playlist.AdditionalMeta)
 	if err != nil {
-		return fmt.Errorf("failed to marshal additional_meta: %w", err)
+		return false, fmt.Errorf("failed to marshal additional_meta: %w", err)
 	}
 	permissions, err := json.Marshal(playlist.Permissions)
 	if err != nil {
-		return fmt.Errorf("failed to marshal permissions: %w", err)
+		return false, fmt.Errorf("failed to marshal permissions: %w", err)
+	}
+	entries, err := json.Marshal(playlist.Entries)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal entries: %w", err)
 	}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
-		return err
+		return false, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         UPDATE playlists
         SET user_id=?, title=?, track_ids=?, listen_count=?, favorite_count=?, description=?,
             creation_date=?, addition_date=?, tags=?, additional_meta=?, permissions=?,
-            metadata_source=?
-        WHERE id=?;`,
+            metadata_source=?, revision=?, entries=?, visibility=?, kind=?, rules=?, evaluated_at=?
+        WHERE id=?`+condition+`;`,
 		&sqlitex.ExecOptions{
-			Args: []any{
+			Args: append([]any{
 				playlist.UserID, playlist.Title, string(trackIDs),
 				playlist.ListenCount, playlist.FavoriteCount, playlist.Description,
 				playlist.CreationDate, playlist.AdditionDate, string(tags),
 				string(additionalMeta), string(permissions), playlist.MetadataSource,
-				playlist.ID,
-			},
+				playlist.Revision, string(entries), playlist.Visibility, playlist.Kind, playlist.Rules,
+				playlist.EvaluatedAt, playlist.ID,
+			}, args...),
 		},
 	)
+	if err != nil {
+		return false, err
+	}
 
-	return err
+	return conn.Changes() > 0, nil
+}
+
+func (db *SQLiteDatabase) SmartPlaylistTrackIDs(
+	ctx context.Context,
+	ownerID string,
+	query rules.Query,
+	maxTracks int,
+) ([]string, error) {
+	var ids []string
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return ids, err
+	}
+	defer db.put(ctx, conn)
+
+	statement, args := query.SQL(sqliteDialect{}, ownerID, maxTracks, time.Now())
+	err = sqlitex.Execute(conn, statement, &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			ids = append(ids, stmt.ColumnText(0))
+			return nil
+		},
+		Args: args,
+	})
+
+	return ids, err
 }
 
 func (db *SQLiteDatabase) DeletePlaylist(ctx context.Context, id string) error {
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn,
 		`DELETE FROM playlists WHERE id = ?;`,
@@ -1828,17 +2099,21 @@ func (db *SQLiteDatabase) DeletePlaylist(ctx context.Context, id string) error {
 }
 
 func (db *SQLiteDatabase) Users(ctx context.Context) ([]media.DatabaseUser, error) {
+	return db.UsersPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) UsersPage(ctx context.Context, limit, offset int) ([]media.DatabaseUser, error) {
 	var users []media.DatabaseUser
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return users, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         SELECT id, username, email, password_hash, display_name, description, listened_to, favorites, public_view_count, creation_date, permissions, linked_artist_id, linked_sources
-        FROM users;`,
+        FROM users ORDER BY id LIMIT ? OFFSET ?;`,
 		&sqlitex.ExecOptions{
 			ResultFunc: func(stmt *sqlite.Stmt) error {
 				user := media.DatabaseUser{}
@@ -1869,6 +2144,7 @@ func (db *SQLiteDatabase) Users(ctx context.Context) ([]media.DatabaseUser, erro
 
 				return nil
 			},
+			Args: []any{limit, offset},
 		},
 	)
 
@@ -1878,11 +2154,11 @@ func (db *SQLiteDatabase) Users(ctx context.Context) ([]media.DatabaseUser, erro
 func (db *SQLiteDatabase) User(ctx context.Context, id string) (media.DatabaseUser, error) {
 	user := media.DatabaseUser{}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return user, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	scanned := false
 	err = sqlitex.Execute(conn, `
@@ -1935,16 +2211,16 @@ func (db *SQLiteDatabase) User(ctx context.Context, id string) (media.DatabaseUs
 func (db *SQLiteDatabase) UserByUsername(ctx context.Context, username string) (media.DatabaseUser, error) {
 	user := media.DatabaseUser{}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return user, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	scanned := false
 	err = sqlitex.Execute(conn, `
         SELECT id, username, email, password_hash, display_name, description, listened_to, favorites, public_view_count, creation_date, permissions, linked_artist_id, linked_sources
-        FROM users WHERE username = ?;`,
+        FROM users WHERE username = ? OR email = ?;`,
 		&sqlitex.ExecOptions{
 			ResultFunc: func(stmt *sqlite.Stmt) error {
 				if scanned {
@@ -1976,7 +2252,7 @@ func (db *SQLiteDatabase) UserByUsername(ctx context.Context, username string) (
 
 				return nil
 			},
-			Args: []any{username},
+			Args: []any{username, username},
 		},
 	)
 	if err != nil {
@@ -2008,11 +2284,11 @@ func (db *SQLiteDatabase) CreateUser(ctx context.Context, user media.DatabaseUse
 		return fmt.Errorf("failed to marshal linked_sources: %w", err)
 	}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         INSERT INTO users (
@@ -2053,11 +2329,11 @@ func (db *SQLiteDatabase) UpdateUser(ctx context.Context, user media.DatabaseUse
 		return fmt.Errorf("failed to marshal linked_sources: %w", err)
 	}
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         UPDATE users
@@ -2078,11 +2354,11 @@ func (db *SQLiteDatabase) UpdateUser(ctx context.Context, user media.DatabaseUse
 }
 
 func (db *SQLiteDatabase) DeleteUser(ctx context.Context, id string) error {
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn,
 		`DELETE FROM users WHERE id = ?;`,
@@ -2099,11 +2375,11 @@ func (db *SQLiteDatabase) ProviderUser(
 ) (media.DatabaseUser, error) {
 	var user media.DatabaseUser
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return user, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	scanned := false
 	err = sqlitex.Execute(conn, `
@@ -2158,11 +2434,11 @@ func (db *SQLiteDatabase) ProviderUser(
 func (db *SQLiteDatabase) IsProviderLinked(ctx context.Context, provider, userID string) (bool, error) {
 	var exists bool
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return exists, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn,
 		`SELECT EXISTS(SELECT 1 FROM auth_providers WHERE provider = ? AND user_id = ?);`,
@@ -2180,11 +2456,11 @@ func (db *SQLiteDatabase) IsProviderLinked(ctx context.Context, provider, userID
 }
 
 func (db *SQLiteDatabase) LinkProviderAccount(ctx context.Context, provider, userID, providerUserID string) error {
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         INSERT INTO auth_providers (user_id, provider, provider_user_id)
@@ -2198,11 +2474,11 @@ func (db *SQLiteDatabase) LinkProviderAccount(ctx context.Context, provider, use
 }
 
 func (db *SQLiteDatabase) DisconnectProviderAccount(ctx context.Context, provider, userID string) error {
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn, `
         DELETE FROM auth_providers WHERE user_id = ? AND provider = ?;`,
@@ -2217,11 +2493,11 @@ func (db *SQLiteDatabase) DisconnectProviderAccount(ctx context.Context, provide
 func (db *SQLiteDatabase) UsernameExists(ctx context.Context, username string) (bool, error) {
 	var exists bool
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return exists, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn,
 		`SELECT EXISTS(SELECT 1 FROM users WHERE username = ?);`,
@@ -2241,11 +2517,11 @@ func (db *SQLiteDatabase) UsernameExists(ctx context.Context, username string) (
 func (db *SQLiteDatabase) EmailExists(ctx context.Context, email string) (bool, error) {
 	var exists bool
 
-	conn, err := db.pool.Take(ctx)
+	conn, err := db.take(ctx)
 	if err != nil {
 		return exists, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn,
 		`SELECT EXISTS(SELECT 1 FROM users WHERE email = ?);`,
@@ -2262,63 +2538,2440 @@ func (db *SQLiteDatabase) EmailExists(ctx context.Context, email string) (bool,
 	return exists, err
 }
 
-func (db *SQLiteDatabase) BlacklistToken(ctx context.Context, token string, expiration time.Time) error {
-	conn, err := db.pool.Take(ctx)
+func (db *SQLiteDatabase) AllListens(ctx context.Context) ([]media.Listen, error) {
+	return db.AllListensPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) AllListensPage(ctx context.Context, limit, offset int) ([]media.Listen, error) {
+	var listens []media.Listen
+
+	conn, err := db.take(ctx)
 	if err != nil {
-		return err
+		return listens, err
 	}
-	defer db.pool.Put(conn)
+	defer db.put(ctx, conn)
 
-	err = sqlitex.Execute(conn,
-		`INSERT INTO blacklisted_tokens (token, expiration) VALUES (?, ?);`,
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, playable_id, playable_type, listened_at, duration_played, client, source,
+            track_name, artist_name, release_name, recording_mbid, isrc
+        FROM listens ORDER BY id LIMIT ? OFFSET ?;`,
 		&sqlitex.ExecOptions{
-			Args: []any{token, expiration.Format(time.DateTime)},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				listens = append(listens, scanSQLiteListen(stmt))
+				return nil
+			},
+			Args: []any{limit, offset},
 		},
 	)
 
-	return err
+	return listens, err
 }
 
-func (db *SQLiteDatabase) CleanExpiredTokens(ctx context.Context) error {
-	conn, err := db.pool.Take(ctx)
+func (db *SQLiteDatabase) Listens(ctx context.Context, userID string, limit, offset int) ([]media.Listen, error) {
+	var listens []media.Listen
+
+	conn, err := db.take(ctx)
 	if err != nil {
-		return err
+		return listens, err
 	}
-	defer db.pool.Put(conn)
-
-	err = sqlitex.Execute(conn,
-		`DELETE FROM blacklisted_tokens WHERE expiration < datetime('now');`,
-		nil,
-	)
+	defer db.put(ctx, conn)
 
-	return err
-}
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, playable_id, playable_type, listened_at, duration_played, client, source,
+            track_name, artist_name, release_name, recording_mbid, isrc
+        FROM listens WHERE user_id = ?
+        ORDER BY listened_at DESC, id DESC
+        LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				listens = append(listens, scanSQLiteListen(stmt))
+				return nil
+			},
+			Args: []any{userID, limit, offset},
+		},
+	)
+
+	return listens, err
+}
+
+func (db *SQLiteDatabase) ListensBetween(
+	ctx context.Context,
+	userID string,
+	minTS, maxTS int64,
+	limit int,
+) ([]media.Listen, error) {
+	var listens []media.Listen
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return listens, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, playable_id, playable_type, listened_at, duration_played, client, source,
+            track_name, artist_name, release_name, recording_mbid, isrc
+        FROM listens
+        WHERE user_id = ? AND (? = 0 OR listened_at > ?) AND (? = 0 OR listened_at < ?)
+        ORDER BY listened_at DESC, id DESC
+        LIMIT ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				listens = append(listens, scanSQLiteListen(stmt))
+				return nil
+			},
+			Args: []any{userID, minTS, minTS, maxTS, maxTS, limit},
+		},
+	)
+
+	return listens, err
+}
+
+func (db *SQLiteDatabase) CountListens(ctx context.Context, userID string) (int, error) {
+	var count int
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return count, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`SELECT COUNT(*) FROM listens WHERE user_id = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				count = stmt.ColumnInt(0)
+				return nil
+			},
+			Args: []any{userID},
+		},
+	)
+
+	return count, err
+}
+
+func (db *SQLiteDatabase) Listen(ctx context.Context, id string) (media.Listen, error) {
+	listen := media.Listen{}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return listen, err
+	}
+	defer db.put(ctx, conn)
+
+	scanned := false
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, playable_id, playable_type, listened_at, duration_played, client, source,
+            track_name, artist_name, release_name, recording_mbid, isrc
+        FROM listens WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				if scanned {
+					return ErrTooMany
+				}
+				scanned = true
+				listen = scanSQLiteListen(stmt)
+				return nil
+			},
+			Args: []any{id},
+		},
+	)
+	if err != nil {
+		return listen, err
+	}
+	if !scanned {
+		return listen, ErrNotFound
+	}
+
+	return listen, nil
+}
+
+func (db *SQLiteDatabase) AddListen(ctx context.Context, listen media.Listen) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        INSERT INTO listens (
+            id, user_id, playable_id, playable_type, listened_at, duration_played, client, source,
+            track_name, artist_name, release_name, recording_mbid, isrc
+        )
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{
+				listen.ID,
+				listen.UserID,
+				listen.PlayableID,
+				listen.PlayableType,
+				listen.ListenedAt,
+				listen.DurationPlayed,
+				listen.Client,
+				listen.Source,
+				listen.TrackName,
+				listen.ArtistName,
+				listen.ReleaseName,
+				listen.RecordingMBID,
+				listen.ISRC,
+			},
+		},
+	)
+
+	return err
+}
+
+func (db *SQLiteDatabase) IncrementListenCount(ctx context.Context, playableType, id string, delta int) error {
+	table, column, err := listenCountColumn(playableType)
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`UPDATE `+table+` SET `+column+` = COALESCE(`+column+`, 0) + ? WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{delta, id},
+		},
+	)
+
+	return err
+}
+
+func scanSQLiteTrack(stmt *sqlite.Stmt) (media.Track, error) {
+	track := media.Track{}
+
+	track.ID = stmt.ColumnText(0)
+	track.UserID = stmt.ColumnText(1)
+	track.ISRC = stmt.ColumnText(2)
+	track.Title = stmt.ColumnText(3)
+	if err := json.Unmarshal([]byte(stmt.ColumnText(4)), &track.ArtistIDs); err != nil {
+		return track, fmt.Errorf("failed to parse artist_ids: %w", err)
+	}
+	if err := json.Unmarshal([]byte(stmt.ColumnText(5)), &track.AlbumIDs); err != nil {
+		return track, fmt.Errorf("failed to parse album_ids: %w", err)
+	}
+	track.PrimaryAlbumID = stmt.ColumnText(6)
+	track.TrackNumber = stmt.ColumnInt(7)
+	track.Duration = stmt.ColumnInt(8)
+	track.Description = stmt.ColumnText(9)
+	track.ReleaseDate = stmt.ColumnText(10)
+	if err := json.Unmarshal([]byte(stmt.ColumnText(11)), &track.Lyrics); err != nil {
+		return track, fmt.Errorf("failed to parse lyrics: %w", err)
+	}
+	track.ListenCount = stmt.ColumnInt(12)
+	track.FavoriteCount = stmt.ColumnInt(13)
+	track.AdditionDate = stmt.ColumnInt64(14)
+	if err := json.Unmarshal([]byte(stmt.ColumnText(15)), &track.Tags); err != nil {
+		return track, fmt.Errorf("failed to parse tags: %w", err)
+	}
+	if err := json.Unmarshal([]byte(stmt.ColumnText(16)), &track.AdditionalMeta); err != nil {
+		return track, fmt.Errorf("failed to parse additional_meta: %w", err)
+	}
+	if err := json.Unmarshal([]byte(stmt.ColumnText(17)), &track.Permissions); err != nil {
+		return track, fmt.Errorf("failed to parse permissions: %w", err)
+	}
+	if err := json.Unmarshal([]byte(stmt.ColumnText(18)), &track.LinkedItemIDs); err != nil {
+		return track, fmt.Errorf("failed to parse linked_item_ids: %w", err)
+	}
+	track.ContentSource = stmt.ColumnText(19)
+	track.MetadataSource = stmt.ColumnText(20)
+	if err := json.Unmarshal([]byte(stmt.ColumnText(21)), &track.LyricSources); err != nil {
+		return track, fmt.Errorf("failed to parse lyric_sources: %w", err)
+	}
+
+	return track, nil
+}
+
+func scanSQLiteListen(stmt *sqlite.Stmt) media.Listen {
+	return media.Listen{
+		ID:             stmt.ColumnText(0),
+		UserID:         stmt.ColumnText(1),
+		PlayableID:     stmt.ColumnText(2),
+		PlayableType:   stmt.ColumnText(3),
+		ListenedAt:     stmt.ColumnInt64(4),
+		DurationPlayed: stmt.ColumnInt(5),
+		Client:         stmt.ColumnText(6),
+		Source:         stmt.ColumnText(7),
+		TrackName:      stmt.ColumnText(8),
+		ArtistName:     stmt.ColumnText(9),
+		ReleaseName:    stmt.ColumnText(10),
+		RecordingMBID:  stmt.ColumnText(11),
+		ISRC:           stmt.ColumnText(12),
+	}
+}
+
+func (db *SQLiteDatabase) AllFavorites(ctx context.Context) ([]media.Favorite, error) {
+	return db.AllFavoritesPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) AllFavoritesPage(ctx context.Context, limit, offset int) ([]media.Favorite, error) {
+	var favorites []media.Favorite
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return favorites, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT user_id, playable_id, playable_type, favorited_at FROM favorites
+        ORDER BY user_id, playable_type, playable_id LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				favorites = append(favorites, scanSQLiteFavorite(stmt))
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return favorites, err
+}
+
+func (db *SQLiteDatabase) Favorites(
+	ctx context.Context,
+	userID, playableType string,
+	limit, offset int,
+) ([]media.Favorite, error) {
+	var favorites []media.Favorite
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return favorites, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT user_id, playable_id, playable_type, favorited_at
+        FROM favorites WHERE user_id = ? AND (? = '' OR playable_type = ?)
+        ORDER BY favorited_at DESC, playable_type, playable_id
+        LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				favorites = append(favorites, scanSQLiteFavorite(stmt))
+				return nil
+			},
+			Args: []any{userID, playableType, playableType, limit, offset},
+		},
+	)
+
+	return favorites, err
+}
+
+func (db *SQLiteDatabase) CountFavorites(ctx context.Context, userID, playableType string) (int, error) {
+	var count int
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return count, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`SELECT COUNT(*) FROM favorites WHERE user_id = ? AND (? = '' OR playable_type = ?);`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				count = stmt.ColumnInt(0)
+				return nil
+			},
+			Args: []any{userID, playableType, playableType},
+		},
+	)
+
+	return count, err
+}
+
+func (db *SQLiteDatabase) FavoriteIDs(ctx context.Context, userID, playableType string) ([]string, error) {
+	var ids []string
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return ids, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`SELECT playable_id FROM favorites WHERE user_id = ? AND playable_type = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				ids = append(ids, stmt.ColumnText(0))
+				return nil
+			},
+			Args: []any{userID, playableType},
+		},
+	)
+
+	return ids, err
+}
+
+func (db *SQLiteDatabase) IsFavorite(ctx context.Context, userID, playableType, id string) (bool, error) {
+	var exists bool
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return exists, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`SELECT EXISTS(SELECT 1 FROM favorites WHERE user_id = ? AND playable_type = ? AND playable_id = ?);`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				exists = stmt.ColumnBool(0)
+				return nil
+			},
+			Args: []any{userID, playableType, id},
+		},
+	)
+
+	return exists, err
+}
+
+func (db *SQLiteDatabase) AddFavorite(ctx context.Context, favorite media.Favorite) (bool, error) {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        INSERT INTO favorites (user_id, playable_id, playable_type, favorited_at)
+        VALUES (?, ?, ?, ?)
+        ON CONFLICT DO NOTHING;`,
+		&sqlitex.ExecOptions{
+			Args: []any{favorite.UserID, favorite.PlayableID, favorite.PlayableType, favorite.FavoritedAt},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return conn.Changes() > 0, nil
+}
+
+func (db *SQLiteDatabase) RemoveFavorite(ctx context.Context, userID, playableType, id string) (bool, error) {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`DELETE FROM favorites WHERE user_id = ? AND playable_type = ? AND playable_id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{userID, playableType, id},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return conn.Changes() > 0, nil
+}
+
+func (db *SQLiteDatabase) RemovePlayableFavorites(ctx context.Context, playableType, id string) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn,
+		`DELETE FROM favorites WHERE playable_type = ? AND playable_id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{playableType, id},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) IncrementFavoriteCount(ctx context.Context, playableType, id string, delta int) error {
+	table, err := favoriteCountTable(playableType)
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`UPDATE `+table+` SET favorite_count = MAX(COALESCE(favorite_count, 0) + ?, 0) WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{delta, id},
+		},
+	)
+
+	return err
+}
+
+func scanSQLiteFavorite(stmt *sqlite.Stmt) media.Favorite {
+	return media.Favorite{
+		UserID:       stmt.ColumnText(0),
+		PlayableID:   stmt.ColumnText(1),
+		PlayableType: stmt.ColumnText(2),
+		FavoritedAt:  stmt.ColumnInt64(3),
+	}
+}
+
+func (db *SQLiteDatabase) ListenBrainzToken(ctx context.Context, userID string) (string, error) {
+	var tokenHash string
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return tokenHash, err
+	}
+	defer db.put(ctx, conn)
+
+	scanned := false
+	err = sqlitex.Execute(conn,
+		`SELECT token_hash FROM listenbrainz_tokens WHERE user_id = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				scanned = true
+				tokenHash = stmt.ColumnText(0)
+				return nil
+			},
+			Args: []any{userID},
+		},
+	)
+	if err != nil {
+		return tokenHash, err
+	}
+	if !scanned {
+		return tokenHash, ErrNotFound
+	}
+
+	return tokenHash, nil
+}
+
+func (db *SQLiteDatabase) ListenBrainzTokenUserID(ctx context.Context, tokenHash string) (string, error) {
+	var userID string
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return userID, err
+	}
+	defer db.put(ctx, conn)
+
+	scanned := false
+	err = sqlitex.Execute(conn,
+		`SELECT user_id FROM listenbrainz_tokens WHERE token_hash = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				scanned = true
+				userID = stmt.ColumnText(0)
+				return nil
+			},
+			Args: []any{tokenHash},
+		},
+	)
+	if err != nil {
+		return userID, err
+	}
+	if !scanned {
+		return userID, ErrNotFound
+	}
+
+	return userID, nil
+}
+
+func (db *SQLiteDatabase) SetListenBrainzToken(
+	ctx context.Context,
+	userID, tokenHash string,
+	creationDate int64,
+) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        INSERT INTO listenbrainz_tokens (user_id, token_hash, creation_date)
+        VALUES (?, ?, ?)
+        ON CONFLICT (user_id) DO UPDATE SET token_hash = excluded.token_hash, creation_date = excluded.creation_date;`,
+		&sqlitex.ExecOptions{
+			Args: []any{userID, tokenHash, creationDate},
+		},
+	)
+
+	return err
+}
+
+func (db *SQLiteDatabase) ListenBrainzTokens(ctx context.Context) ([]ListenBrainzToken, error) {
+	return db.ListenBrainzTokensPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) ListenBrainzTokensPage(ctx context.Context, limit, offset int) ([]ListenBrainzToken, error) {
+	var tokens []ListenBrainzToken
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return tokens, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`SELECT user_id, token_hash, creation_date FROM listenbrainz_tokens ORDER BY user_id LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				tokens = append(tokens, ListenBrainzToken{
+					UserID:       stmt.ColumnText(0),
+					TokenHash:    stmt.ColumnText(1),
+					CreationDate: stmt.ColumnInt64(2),
+				})
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return tokens, err
+}
+
+func (db *SQLiteDatabase) AllScrobbles(ctx context.Context) ([]Scrobble, error) {
+	return db.AllScrobblesPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) AllScrobblesPage(ctx context.Context, limit, offset int) ([]Scrobble, error) {
+	var scrobbles []Scrobble
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return scrobbles, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, listen_id, scrobbler, attempts, next_attempt_at, last_error, failed, creation_date
+        FROM scrobble_queue ORDER BY id LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				scrobbles = append(scrobbles, scanSQLiteScrobble(stmt))
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return scrobbles, err
+}
+
+func (db *SQLiteDatabase) DueScrobbles(ctx context.Context, now int64, limit int) ([]Scrobble, error) {
+	var scrobbles []Scrobble
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return scrobbles, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, listen_id, scrobbler, attempts, next_attempt_at, last_error, failed, creation_date
+        FROM scrobble_queue WHERE failed = FALSE AND next_attempt_at <= ?
+        ORDER BY next_attempt_at, creation_date
+        LIMIT ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				scrobbles = append(scrobbles, scanSQLiteScrobble(stmt))
+				return nil
+			},
+			Args: []any{now, limit},
+		},
+	)
+
+	return scrobbles, err
+}
+
+func (db *SQLiteDatabase) FailedScrobbles(ctx context.Context, userID string) ([]Scrobble, error) {
+	var scrobbles []Scrobble
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return scrobbles, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, listen_id, scrobbler, attempts, next_attempt_at, last_error, failed, creation_date
+        FROM scrobble_queue WHERE user_id = ? AND failed = TRUE
+        ORDER BY creation_date DESC;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				scrobbles = append(scrobbles, scanSQLiteScrobble(stmt))
+				return nil
+			},
+			Args: []any{userID},
+		},
+	)
+
+	return scrobbles, err
+}
+
+func (db *SQLiteDatabase) Scrobble(ctx context.Context, id string) (Scrobble, error) {
+	scrobble := Scrobble{}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return scrobble, err
+	}
+	defer db.put(ctx, conn)
+
+	scanned := false
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, listen_id, scrobbler, attempts, next_attempt_at, last_error, failed, creation_date
+        FROM scrobble_queue WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				if scanned {
+					return ErrTooMany
+				}
+				scanned = true
+				scrobble = scanSQLiteScrobble(stmt)
+				return nil
+			},
+			Args: []any{id},
+		},
+	)
+	if err != nil {
+		return scrobble, err
+	}
+	if !scanned {
+		return scrobble, ErrNotFound
+	}
+
+	return scrobble, nil
+}
+
+func (db *SQLiteDatabase) AddScrobble(ctx context.Context, scrobble Scrobble) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        INSERT INTO scrobble_queue (
+            id, user_id, listen_id, scrobbler, attempts, next_attempt_at, last_error, failed, creation_date
+        )
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{
+				scrobble.ID,
+				scrobble.UserID,
+				scrobble.ListenID,
+				scrobble.Scrobbler,
+				scrobble.Attempts,
+				scrobble.NextAttemptAt,
+				scrobble.LastError,
+				scrobble.Failed,
+				scrobble.CreationDate,
+			},
+		},
+	)
+
+	return err
+}
+
+func (db *SQLiteDatabase) UpdateScrobble(ctx context.Context, scrobble Scrobble) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        UPDATE scrobble_queue
+        SET user_id = ?, listen_id = ?, scrobbler = ?, attempts = ?, next_attempt_at = ?, last_error = ?, failed = ?,
+            creation_date = ?
+        WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{
+				scrobble.UserID,
+				scrobble.ListenID,
+				scrobble.Scrobbler,
+				scrobble.Attempts,
+				scrobble.NextAttemptAt,
+				scrobble.LastError,
+				scrobble.Failed,
+				scrobble.CreationDate,
+				scrobble.ID,
+			},
+		},
+	)
+
+	return err
+}
+
+func (db *SQLiteDatabase) DeleteScrobble(ctx context.Context, id string) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`DELETE FROM scrobble_queue WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{id},
+		},
+	)
+
+	return err
+}
+
+func scanSQLiteScrobble(stmt *sqlite.Stmt) Scrobble {
+	return Scrobble{
+		ID:            stmt.ColumnText(0),
+		UserID:        stmt.ColumnText(1),
+		ListenID:      stmt.ColumnText(2),
+		Scrobbler:     stmt.ColumnText(3),
+		Attempts:      stmt.ColumnInt(4),
+		NextAttemptAt: stmt.ColumnInt64(5),
+		LastError:     stmt.ColumnText(6),
+		Failed:        stmt.ColumnBool(7),
+		CreationDate:  stmt.ColumnInt64(8),
+	}
+}
+
+func (db *SQLiteDatabase) ScrobblerLinks(ctx context.Context) ([]ScrobblerLink, error) {
+	return db.ScrobblerLinksPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) ScrobblerLinksPage(ctx context.Context, limit, offset int) ([]ScrobblerLink, error) {
+	var links []ScrobblerLink
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return links, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT user_id, scrobbler, token, creation_date FROM scrobbler_links
+        ORDER BY user_id, scrobbler LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				links = append(links, scanSQLiteScrobblerLink(stmt))
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return links, err
+}
+
+func (db *SQLiteDatabase) UserScrobblerLinks(ctx context.Context, userID string) ([]ScrobblerLink, error) {
+	var links []ScrobblerLink
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return links, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT user_id, scrobbler, token, creation_date FROM scrobbler_links
+        WHERE user_id = ? ORDER BY scrobbler;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				links = append(links, scanSQLiteScrobblerLink(stmt))
+				return nil
+			},
+			Args: []any{userID},
+		},
+	)
+
+	return links, err
+}
+
+func (db *SQLiteDatabase) ScrobblerLink(ctx context.Context, userID, scrobbler string) (ScrobblerLink, error) {
+	link := ScrobblerLink{}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return link, err
+	}
+	defer db.put(ctx, conn)
+
+	scanned := false
+	err = sqlitex.Execute(conn, `
+        SELECT user_id, scrobbler, token, creation_date FROM scrobbler_links
+        WHERE user_id = ? AND scrobbler = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				scanned = true
+				link = scanSQLiteScrobblerLink(stmt)
+				return nil
+			},
+			Args: []any{userID, scrobbler},
+		},
+	)
+	if err != nil {
+		return link, err
+	}
+	if !scanned {
+		return link, ErrNotFound
+	}
+
+	return link, nil
+}
+
+func (db *SQLiteDatabase) SetScrobblerLink(ctx context.Context, link ScrobblerLink) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn, `
+        INSERT INTO scrobbler_links (user_id, scrobbler, token, creation_date) VALUES (?, ?, ?, ?)
+        ON CONFLICT (user_id, scrobbler) DO UPDATE SET
+            token = excluded.token, creation_date = excluded.creation_date;`,
+		&sqlitex.ExecOptions{
+			Args: []any{link.UserID, link.Scrobbler, link.Token, link.CreationDate},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) DeleteScrobblerLink(ctx context.Context, userID, scrobbler string) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn,
+		`DELETE FROM scrobbler_links WHERE user_id = ? AND scrobbler = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{userID, scrobbler},
+		},
+	)
+}
+
+func scanSQLiteScrobblerLink(stmt *sqlite.Stmt) ScrobblerLink {
+	return ScrobblerLink{
+		UserID:       stmt.ColumnText(0),
+		Scrobbler:    stmt.ColumnText(1),
+		Token:        stmt.ColumnText(2),
+		CreationDate: stmt.ColumnInt64(3),
+	}
+}
+
+func (db *SQLiteDatabase) AllPlayQueues(ctx context.Context) ([]media.PlayQueue, error) {
+	return db.AllPlayQueuesPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) AllPlayQueuesPage(ctx context.Context, limit, offset int) ([]media.PlayQueue, error) {
+	var queues []media.PlayQueue
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return queues, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT user_id, items, current_index, position_ms, shuffle, repeat_mode, context_type, context_id, device, revision, updated_at
+        FROM play_queues ORDER BY user_id LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				queue, err := scanSQLitePlayQueue(stmt)
+				if err != nil {
+					return err
+				}
+				queues = append(queues, queue)
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return queues, err
+}
+
+func (db *SQLiteDatabase) PlayQueue(ctx context.Context, userID string) (media.PlayQueue, error) {
+	queue := media.PlayQueue{}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return queue, err
+	}
+	defer db.put(ctx, conn)
+
+	scanned := false
+	err = sqlitex.Execute(conn, `
+        SELECT user_id, items, current_index, position_ms, shuffle, repeat_mode, context_type, context_id, device, revision, updated_at
+        FROM play_queues WHERE user_id = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				if scanned {
+					return ErrTooMany
+				}
+				scanned = true
+				queue, err = scanSQLitePlayQueue(stmt)
+				return err
+			},
+			Args: []any{userID},
+		},
+	)
+	if err != nil {
+		return queue, err
+	}
+	if !scanned {
+		return queue, ErrNotFound
+	}
+
+	return queue, nil
+}
+
+func (db *SQLiteDatabase) SetPlayQueue(ctx context.Context, queue media.PlayQueue) error {
+	_, err := db.writePlayQueue(ctx, queue, `
+        INSERT INTO play_queues (
+            user_id, items, current_index, position_ms, shuffle, repeat_mode, context_type, context_id, device, revision, updated_at
+        ) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11)
+        ON CONFLICT (user_id) DO UPDATE SET
+            items = excluded.items, current_index = excluded.current_index, position_ms = excluded.position_ms,
+            shuffle = excluded.shuffle, repeat_mode = excluded.repeat_mode, context_type = excluded.context_type,
+            context_id = excluded.context_id, device = excluded.device, revision = excluded.revision,
+            updated_at = excluded.updated_at;`)
+	return err
+}
+
+func (db *SQLiteDatabase) SavePlayQueueAtRevision(ctx context.Context, queue media.PlayQueue, revision int) error {
+	var changed bool
+	var err error
+	if revision == 0 {
+		// The queue must not exist yet, so a queue another device stored in the meantime isn't replaced.
+		changed, err = db.writePlayQueue(ctx, queue, `
+        INSERT INTO play_queues (
+            user_id, items, current_index, position_ms, shuffle, repeat_mode, context_type, context_id, device, revision, updated_at
+        ) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11)
+        ON CONFLICT (user_id) DO NOTHING;`)
+	} else {
+		changed, err = db.writePlayQueue(ctx, queue, `
+        UPDATE play_queues
+        SET items = ?2, current_index = ?3, position_ms = ?4, shuffle = ?5, repeat_mode = ?6, context_type = ?7,
+            context_id = ?8, device = ?9, revision = ?10, updated_at = ?11
+        WHERE user_id = ?1 AND revision = ?12;`, revision)
+	}
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return ErrRevisionMismatch
+	}
+	return nil
+}
+
+// writePlayQueue executes a statement that stores a play queue and reports whether a row was changed.
+// The statement gets the queue's columns as numbered arguments in table order, followed by args.
+func (db *SQLiteDatabase) writePlayQueue(
+	ctx context.Context,
+	queue media.PlayQueue,
+	statement string,
+	args ...any,
+) (bool, error) {
+	items, err := json.Marshal(queue.Items)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal items: %w", err)
+	}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, statement, &sqlitex.ExecOptions{
+		Args: append([]any{
+			queue.UserID, string(items), queue.CurrentIndex, queue.PositionMS, queue.Shuffle, queue.Repeat,
+			queue.Context.Type, queue.Context.ID, queue.Device, queue.Revision, queue.UpdatedAt,
+		}, args...),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return conn.Changes() > 0, nil
+}
+
+func scanSQLitePlayQueue(stmt *sqlite.Stmt) (media.PlayQueue, error) {
+	queue := media.PlayQueue{
+		UserID:       stmt.ColumnText(0),
+		CurrentIndex: stmt.ColumnInt(2),
+		PositionMS:   stmt.ColumnInt64(3),
+		Shuffle:      stmt.ColumnBool(4),
+		Repeat:       stmt.ColumnText(5),
+		Context:      media.QueueContext{Type: stmt.ColumnText(6), ID: stmt.ColumnText(7)},
+		Device:       stmt.ColumnText(8),
+		Revision:     stmt.ColumnInt(9),
+		UpdatedAt:    stmt.ColumnInt64(10),
+	}
+	if err := json.Unmarshal([]byte(stmt.ColumnText(1)), &queue.Items); err != nil {
+		return queue, fmt.Errorf("failed to parse items: %w", err)
+	}
+	return queue, nil
+}
+
+func (db *SQLiteDatabase) BlacklistToken(ctx context.Context, token string, expiration time.Time) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`INSERT INTO blacklisted_tokens (token, expiration) VALUES (?, ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{token, expiration.Format(time.DateTime)},
+		},
+	)
+
+	return err
+}
+
+func (db *SQLiteDatabase) CleanExpiredTokens(ctx context.Context) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`DELETE FROM blacklisted_tokens WHERE expiration < datetime('now');`,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = sqlitex.Execute(conn,
+		`DELETE FROM refresh_tokens WHERE expiration < ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{time.Now().Unix()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = sqlitex.Execute(conn,
+		`DELETE FROM sessions WHERE expiration < ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{time.Now().Unix()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = sqlitex.Execute(conn,
+		`DELETE FROM access_tokens WHERE expiration != 0 AND expiration < ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{time.Now().Unix()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = sqlitex.Execute(conn,
+		`DELETE FROM email_tokens WHERE expiration < ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{time.Now().Unix()},
+		},
+	)
+
+	return err
+}
+
+func (db *SQLiteDatabase) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
+	var exists bool
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return exists, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`SELECT EXISTS(SELECT 1 FROM blacklisted_tokens WHERE token = ?);`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				exists = stmt.ColumnBool(0)
+
+				return nil
+			},
+			Args: []any{token},
+		},
+	)
+
+	return exists, err
+}
+
+func (db *SQLiteDatabase) ProviderLinks(ctx context.Context) ([]ProviderLink, error) {
+	return db.ProviderLinksPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) ProviderLinksPage(ctx context.Context, limit, offset int) ([]ProviderLink, error) {
+	var links []ProviderLink
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return links, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`SELECT user_id, provider, provider_user_id FROM auth_providers ORDER BY user_id, provider LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				links = append(links, ProviderLink{
+					UserID:         stmt.ColumnText(0),
+					Provider:       stmt.ColumnText(1),
+					ProviderUserID: stmt.ColumnText(2),
+				})
+
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return links, err
+}
+
+func (db *SQLiteDatabase) BlacklistedTokens(ctx context.Context) ([]BlacklistedToken, error) {
+	return db.BlacklistedTokensPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) BlacklistedTokensPage(ctx context.Context, limit, offset int) ([]BlacklistedToken, error) {
+	var tokens []BlacklistedToken
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return tokens, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`SELECT token, expiration FROM blacklisted_tokens ORDER BY token LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				expiration, err := time.Parse(time.DateTime, stmt.ColumnText(1))
+				if err != nil {
+					return fmt.Errorf("failed to parse expiration: %w", err)
+				}
+				tokens = append(tokens, BlacklistedToken{
+					Token:      stmt.ColumnText(0),
+					Expiration: expiration,
+				})
+
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return tokens, err
+}
+
+func (db *SQLiteDatabase) RefreshTokens(ctx context.Context) ([]RefreshToken, error) {
+	return db.RefreshTokensPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) RefreshTokensPage(ctx context.Context, limit, offset int) ([]RefreshToken, error) {
+	var tokens []RefreshToken
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return tokens, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT token_hash, user_id, family_id, creation_date, expiration, used_at, revoked FROM refresh_tokens
+        ORDER BY token_hash LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				tokens = append(tokens, scanSQLiteRefreshToken(stmt))
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return tokens, err
+}
+
+func (db *SQLiteDatabase) RefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	token := RefreshToken{}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return token, err
+	}
+	defer db.put(ctx, conn)
+
+	scanned := false
+	err = sqlitex.Execute(conn, `
+        SELECT token_hash, user_id, family_id, creation_date, expiration, used_at, revoked
+        FROM refresh_tokens WHERE token_hash = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				if scanned {
+					return ErrTooMany
+				}
+				scanned = true
+				token = scanSQLiteRefreshToken(stmt)
+				return nil
+			},
+			Args: []any{tokenHash},
+		},
+	)
+	if err != nil {
+		return token, err
+	}
+	if !scanned {
+		return token, ErrNotFound
+	}
+
+	return token, nil
+}
+
+func (db *SQLiteDatabase) AddRefreshToken(ctx context.Context, token RefreshToken) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn, `
+        INSERT INTO refresh_tokens (token_hash, user_id, family_id, creation_date, expiration, used_at, revoked)
+        VALUES (?, ?, ?, ?, ?, ?, ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{
+				token.TokenHash, token.UserID, token.FamilyID, token.CreationDate, token.Expiration, token.UsedAt,
+				token.Revoked,
+			},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) UseRefreshToken(ctx context.Context, tokenHash string, usedAt int64) (bool, error) {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`UPDATE refresh_tokens SET used_at = ? WHERE token_hash = ? AND used_at = 0 AND NOT revoked;`,
+		&sqlitex.ExecOptions{
+			Args: []any{usedAt, tokenHash},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return conn.Changes() > 0, nil
+}
+
+func (db *SQLiteDatabase) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn,
+		`UPDATE refresh_tokens SET revoked = TRUE WHERE family_id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{familyID},
+		},
+	)
+}
+
+func scanSQLiteRefreshToken(stmt *sqlite.Stmt) RefreshToken {
+	return RefreshToken{
+		TokenHash:    stmt.ColumnText(0),
+		UserID:       stmt.ColumnText(1),
+		FamilyID:     stmt.ColumnText(2),
+		CreationDate: stmt.ColumnInt64(3),
+		Expiration:   stmt.ColumnInt64(4),
+		UsedAt:       stmt.ColumnInt64(5),
+		Revoked:      stmt.ColumnBool(6),
+	}
+}
+
+func (db *SQLiteDatabase) Sessions(ctx context.Context) ([]Session, error) {
+	return db.SessionsPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) SessionsPage(ctx context.Context, limit, offset int) ([]Session, error) {
+	var sessions []Session
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return sessions, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, device_name, user_agent, ip, creation_date, last_used, expiration FROM sessions
+        ORDER BY id LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				sessions = append(sessions, scanSQLiteSession(stmt))
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return sessions, err
+}
+
+func (db *SQLiteDatabase) UserSessions(ctx context.Context, userID string) ([]Session, error) {
+	var sessions []Session
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return sessions, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, device_name, user_agent, ip, creation_date, last_used, expiration
+        FROM sessions WHERE user_id = ? ORDER BY last_used DESC;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				sessions = append(sessions, scanSQLiteSession(stmt))
+				return nil
+			},
+			Args: []any{userID},
+		},
+	)
+
+	return sessions, err
+}
+
+func (db *SQLiteDatabase) Session(ctx context.Context, id string) (Session, error) {
+	session := Session{}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return session, err
+	}
+	defer db.put(ctx, conn)
+
+	scanned := false
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, device_name, user_agent, ip, creation_date, last_used, expiration
+        FROM sessions WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				if scanned {
+					return ErrTooMany
+				}
+				scanned = true
+				session = scanSQLiteSession(stmt)
+				return nil
+			},
+			Args: []any{id},
+		},
+	)
+	if err != nil {
+		return session, err
+	}
+	if !scanned {
+		return session, ErrNotFound
+	}
+
+	return session, nil
+}
+
+func (db *SQLiteDatabase) AddSession(ctx context.Context, session Session) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn, `
+        INSERT INTO sessions (id, user_id, device_name, user_agent, ip, creation_date, last_used, expiration)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{
+				session.ID, session.UserID, session.DeviceName, session.UserAgent, session.IP, session.CreationDate,
+				session.LastUsed, session.Expiration,
+			},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) UpdateSession(ctx context.Context, session Session) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn, `
+        UPDATE sessions SET device_name = ?, user_agent = ?, ip = ?, last_used = ?, expiration = ? WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{
+				session.DeviceName, session.UserAgent, session.IP, session.LastUsed, session.Expiration, session.ID,
+			},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) DeleteSession(ctx context.Context, id string) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn,
+		`DELETE FROM sessions WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{id},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) TOTPSecrets(ctx context.Context) ([]TOTPSecret, error) {
+	return db.TOTPSecretsPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) TOTPSecretsPage(ctx context.Context, limit, offset int) ([]TOTPSecret, error) {
+	var secrets []TOTPSecret
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return secrets, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT user_id, secret, enabled, last_step, creation_date FROM totp_secrets
+        ORDER BY user_id LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				secrets = append(secrets, scanSQLiteTOTPSecret(stmt))
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return secrets, err
+}
+
+func (db *SQLiteDatabase) TOTPSecret(ctx context.Context, userID string) (TOTPSecret, error) {
+	secret := TOTPSecret{}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return secret, err
+	}
+	defer db.put(ctx, conn)
+
+	scanned := false
+	err = sqlitex.Execute(conn,
+		`SELECT user_id, secret, enabled, last_step, creation_date FROM totp_secrets WHERE user_id = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				if scanned {
+					return ErrTooMany
+				}
+				scanned = true
+				secret = scanSQLiteTOTPSecret(stmt)
+				return nil
+			},
+			Args: []any{userID},
+		},
+	)
+	if err != nil {
+		return secret, err
+	}
+	if !scanned {
+		return secret, ErrNotFound
+	}
+
+	return secret, nil
+}
+
+func (db *SQLiteDatabase) SetTOTPSecret(ctx context.Context, secret TOTPSecret) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn, `
+        INSERT INTO totp_secrets (user_id, secret, enabled, last_step, creation_date) VALUES (?, ?, ?, ?, ?)
+        ON CONFLICT (user_id) DO UPDATE SET
+            secret = excluded.secret, enabled = excluded.enabled, last_step = excluded.last_step,
+            creation_date = excluded.creation_date;`,
+		&sqlitex.ExecOptions{
+			Args: []any{secret.UserID, secret.Secret, secret.Enabled, secret.LastStep, secret.CreationDate},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`UPDATE totp_secrets SET last_step = ? WHERE user_id = ? AND last_step < ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{step, userID, step},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return conn.Changes() > 0, nil
+}
+
+func (db *SQLiteDatabase) DeleteTOTPSecret(ctx context.Context, userID string) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn,
+		`DELETE FROM totp_secrets WHERE user_id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{userID},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) RecoveryCodes(ctx context.Context) ([]RecoveryCode, error) {
+	return db.RecoveryCodesPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) RecoveryCodesPage(ctx context.Context, limit, offset int) ([]RecoveryCode, error) {
+	var codes []RecoveryCode
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return codes, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`SELECT user_id, code_hash FROM recovery_codes ORDER BY user_id, code_hash LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				codes = append(codes, RecoveryCode{
+					UserID:   stmt.ColumnText(0),
+					CodeHash: stmt.ColumnText(1),
+				})
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return codes, err
+}
+
+func (db *SQLiteDatabase) AddRecoveryCode(ctx context.Context, code RecoveryCode) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn,
+		`INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{code.UserID, code.CodeHash},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn,
+		`DELETE FROM recovery_codes WHERE user_id = ? AND code_hash = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{userID, codeHash},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return conn.Changes() > 0, nil
+}
+
+func (db *SQLiteDatabase) DeleteRecoveryCodes(ctx context.Context, userID string) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn,
+		`DELETE FROM recovery_codes WHERE user_id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{userID},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) WebAuthnCredentials(ctx context.Context) ([]WebAuthnCredential, error) {
+	return db.WebAuthnCredentialsPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) WebAuthnCredentialsPage(
+	ctx context.Context,
+	limit, offset int,
+) ([]WebAuthnCredential, error) {
+	var credentials []WebAuthnCredential
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return credentials, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, name, credential, creation_date, last_used FROM webauthn_credentials
+        ORDER BY id LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				credentials = append(credentials, scanSQLiteWebAuthnCredential(stmt))
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return credentials, err
+}
+
+func (db *SQLiteDatabase) UserWebAuthnCredentials(ctx context.Context, userID string) ([]WebAuthnCredential, error) {
+	var credentials []WebAuthnCredential
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return credentials, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, name, credential, creation_date, last_used
+        FROM webauthn_credentials WHERE user_id = ? ORDER BY creation_date;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				credentials = append(credentials, scanSQLiteWebAuthnCredential(stmt))
+				return nil
+			},
+			Args: []any{userID},
+		},
+	)
+
+	return credentials, err
+}
+
+func (db *SQLiteDatabase) WebAuthnCredential(ctx context.Context, id string) (WebAuthnCredential, error) {
+	credential := WebAuthnCredential{}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return credential, err
+	}
+	defer db.put(ctx, conn)
+
+	scanned := false
+	err = sqlitex.Execute(conn,
+		`SELECT id, user_id, name, credential, creation_date, last_used FROM webauthn_credentials WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				if scanned {
+					return ErrTooMany
+				}
+				scanned = true
+				credential = scanSQLiteWebAuthnCredential(stmt)
+				return nil
+			},
+			Args: []any{id},
+		},
+	)
+	if err != nil {
+		return credential, err
+	}
+	if !scanned {
+		return credential, ErrNotFound
+	}
+
+	return credential, nil
+}
+
+func (db *SQLiteDatabase) AddWebAuthnCredential(ctx context.Context, credential WebAuthnCredential) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn,
+		`INSERT INTO webauthn_credentials (id, user_id, name, credential, creation_date, last_used) VALUES (?, ?, ?, ?, ?, ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{
+				credential.ID, credential.UserID, credential.Name, credential.Credential, credential.CreationDate,
+				credential.LastUsed,
+			},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) UpdateWebAuthnCredential(ctx context.Context, credential WebAuthnCredential) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn,
+		`UPDATE webauthn_credentials SET name = ?, credential = ?, last_used = ? WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{credential.Name, credential.Credential, credential.LastUsed, credential.ID},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) DeleteWebAuthnCredential(ctx context.Context, id string) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn,
+		`DELETE FROM webauthn_credentials WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{id},
+		},
+	)
+}
+
+func scanSQLiteWebAuthnCredential(stmt *sqlite.Stmt) WebAuthnCredential {
+	return WebAuthnCredential{
+		ID:           stmt.ColumnText(0),
+		UserID:       stmt.ColumnText(1),
+		Name:         stmt.ColumnText(2),
+		Credential:   stmt.ColumnText(3),
+		CreationDate: stmt.ColumnInt64(4),
+		LastUsed:     stmt.ColumnInt64(5),
+	}
+}
+
+func (db *SQLiteDatabase) AccessTokens(ctx context.Context) ([]AccessToken, error) {
+	return db.AccessTokensPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) AccessTokensPage(ctx context.Context, limit, offset int) ([]AccessToken, error) {
+	var tokens []AccessToken
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return tokens, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, name, token_hash, scopes, creation_date, expiration, last_used
+        FROM access_tokens ORDER BY id LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				token, err := scanSQLiteAccessToken(stmt)
+				if err != nil {
+					return err
+				}
+				tokens = append(tokens, token)
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return tokens, err
+}
+
+func (db *SQLiteDatabase) UserAccessTokens(ctx context.Context, userID string) ([]AccessToken, error) {
+	var tokens []AccessToken
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return tokens, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, name, token_hash, scopes, creation_date, expiration, last_used
+        FROM access_tokens WHERE user_id = ? ORDER BY creation_date;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				token, err := scanSQLiteAccessToken(stmt)
+				if err != nil {
+					return err
+				}
+				tokens = append(tokens, token)
+				return nil
+			},
+			Args: []any{userID},
+		},
+	)
+
+	return tokens, err
+}
+
+func (db *SQLiteDatabase) AccessToken(ctx context.Context, id string) (AccessToken, error) {
+	token := AccessToken{}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return token, err
+	}
+	defer db.put(ctx, conn)
+
+	scanned := false
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, name, token_hash, scopes, creation_date, expiration, last_used
+        FROM access_tokens WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				if scanned {
+					return ErrTooMany
+				}
+				scanned = true
+				token, err = scanSQLiteAccessToken(stmt)
+				return err
+			},
+			Args: []any{id},
+		},
+	)
+	if err != nil {
+		return token, err
+	}
+	if !scanned {
+		return token, ErrNotFound
+	}
+
+	return token, nil
+}
+
+func (db *SQLiteDatabase) AccessTokenByHash(ctx context.Context, tokenHash string) (AccessToken, error) {
+	token := AccessToken{}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return token, err
+	}
+	defer db.put(ctx, conn)
+
+	scanned := false
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, name, token_hash, scopes, creation_date, expiration, last_used
+        FROM access_tokens WHERE token_hash = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				if scanned {
+					return ErrTooMany
+				}
+				scanned = true
+				token, err = scanSQLiteAccessToken(stmt)
+				return err
+			},
+			Args: []any{tokenHash},
+		},
+	)
+	if err != nil {
+		return token, err
+	}
+	if !scanned {
+		return token, ErrNotFound
+	}
 
-func (db *SQLiteDatabase) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
-	var exists bool
+	return token, nil
+}
 
-	conn, err := db.pool.Take(ctx)
+func (db *SQLiteDatabase) AddAccessToken(ctx context.Context, token AccessToken) error {
+	scopes, err := json.Marshal(token.Scopes)
 	if err != nil {
-		return exists, err
+		return err
 	}
-	defer db.pool.Put(conn)
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn,
+		`INSERT INTO access_tokens (id, user_id, name, token_hash, scopes, creation_date, expiration, last_used) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{
+				token.ID, token.UserID, token.Name, token.TokenHash, string(scopes),
+				token.CreationDate, token.Expiration, token.LastUsed,
+			},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) UpdateAccessToken(ctx context.Context, token AccessToken) error {
+	scopes, err := json.Marshal(token.Scopes)
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn,
+		`UPDATE access_tokens SET name = ?, scopes = ?, expiration = ?, last_used = ? WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{token.Name, string(scopes), token.Expiration, token.LastUsed, token.ID},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) DeleteAccessToken(ctx context.Context, id string) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn,
+		`DELETE FROM access_tokens WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{id},
+		},
+	)
+}
+
+func scanSQLiteAccessToken(stmt *sqlite.Stmt) (AccessToken, error) {
+	token := AccessToken{
+		ID:           stmt.ColumnText(0),
+		UserID:       stmt.ColumnText(1),
+		Name:         stmt.ColumnText(2),
+		TokenHash:    stmt.ColumnText(3),
+		CreationDate: stmt.ColumnInt64(5),
+		Expiration:   stmt.ColumnInt64(6),
+		LastUsed:     stmt.ColumnInt64(7),
+	}
+	if err := json.Unmarshal([]byte(stmt.ColumnText(4)), &token.Scopes); err != nil {
+		return token, fmt.Errorf("failed to parse scopes: %w", err)
+	}
+	return token, nil
+}
+
+func (db *SQLiteDatabase) EmailTokens(ctx context.Context) ([]EmailToken, error) {
+	return db.EmailTokensPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) EmailTokensPage(ctx context.Context, limit, offset int) ([]EmailToken, error) {
+	var tokens []EmailToken
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return tokens, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, purpose, token_hash, email, creation_date, expiration FROM email_tokens
+        ORDER BY id LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				tokens = append(tokens, scanSQLiteEmailToken(stmt))
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return tokens, err
+}
+
+func (db *SQLiteDatabase) EmailTokenByHash(ctx context.Context, tokenHash string) (EmailToken, error) {
+	token := EmailToken{}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return token, err
+	}
+	defer db.put(ctx, conn)
+
+	scanned := false
+	err = sqlitex.Execute(conn, `
+        SELECT id, user_id, purpose, token_hash, email, creation_date, expiration
+        FROM email_tokens WHERE token_hash = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				if scanned {
+					return ErrTooMany
+				}
+				scanned = true
+				token = scanSQLiteEmailToken(stmt)
+				return nil
+			},
+			Args: []any{tokenHash},
+		},
+	)
+	if err != nil {
+		return token, err
+	}
+	if !scanned {
+		return token, ErrNotFound
+	}
+
+	return token, nil
+}
+
+func (db *SQLiteDatabase) AddEmailToken(ctx context.Context, token EmailToken) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn,
+		`INSERT INTO email_tokens (id, user_id, purpose, token_hash, email, creation_date, expiration) VALUES (?, ?, ?, ?, ?, ?, ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{
+				token.ID, token.UserID, token.Purpose, token.TokenHash, token.Email,
+				token.CreationDate, token.Expiration,
+			},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) UseEmailToken(ctx context.Context, id string) (bool, error) {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer db.put(ctx, conn)
 
 	err = sqlitex.Execute(conn,
-		`SELECT EXISTS(SELECT 1 FROM blacklisted_tokens WHERE token = ?);`,
+		`DELETE FROM email_tokens WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{id},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return conn.Changes() > 0, nil
+}
+
+func (db *SQLiteDatabase) DeleteEmailTokens(ctx context.Context, userID, purpose string) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn,
+		`DELETE FROM email_tokens WHERE user_id = ? AND purpose = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{userID, purpose},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) EmailVerifications(ctx context.Context) ([]EmailVerification, error) {
+	return db.EmailVerificationsPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) EmailVerificationsPage(ctx context.Context, limit, offset int) ([]EmailVerification, error) {
+	var verifications []EmailVerification
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return verifications, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT user_id, email, verification_date FROM email_verifications
+        ORDER BY user_id LIMIT ? OFFSET ?;`,
 		&sqlitex.ExecOptions{
 			ResultFunc: func(stmt *sqlite.Stmt) error {
-				exists = stmt.ColumnBool(0)
+				verifications = append(verifications, scanSQLiteEmailVerification(stmt))
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return verifications, err
+}
+
+func (db *SQLiteDatabase) EmailVerification(ctx context.Context, userID string) (EmailVerification, error) {
+	verification := EmailVerification{}
 
+	conn, err := db.take(ctx)
+	if err != nil {
+		return verification, err
+	}
+	defer db.put(ctx, conn)
+
+	scanned := false
+	err = sqlitex.Execute(conn,
+		`SELECT user_id, email, verification_date FROM email_verifications WHERE user_id = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				if scanned {
+					return ErrTooMany
+				}
+				scanned = true
+				verification = scanSQLiteEmailVerification(stmt)
 				return nil
 			},
-			Args: []any{token},
+			Args: []any{userID},
 		},
 	)
+	if err != nil {
+		return verification, err
+	}
+	if !scanned {
+		return verification, ErrNotFound
+	}
 
-	return exists, err
+	return verification, nil
+}
+
+func (db *SQLiteDatabase) SetEmailVerification(ctx context.Context, verification EmailVerification) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn, `
+        INSERT INTO email_verifications (user_id, email, verification_date) VALUES (?, ?, ?)
+        ON CONFLICT (user_id) DO UPDATE SET email = excluded.email, verification_date = excluded.verification_date;`,
+		&sqlitex.ExecOptions{
+			Args: []any{verification.UserID, verification.Email, verification.VerificationDate},
+		},
+	)
+}
+
+func (db *SQLiteDatabase) AllAuditEvents(ctx context.Context) ([]AuditEvent, error) {
+	return db.AllAuditEventsPage(ctx, -1, 0)
+}
+
+func (db *SQLiteDatabase) AllAuditEventsPage(ctx context.Context, limit, offset int) ([]AuditEvent, error) {
+	var events []AuditEvent
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return events, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT id, action, user_id, username, ip, details, creation_date FROM audit_log
+        ORDER BY id LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				events = append(events, scanSQLiteAuditEvent(stmt))
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return events, err
+}
+
+func (db *SQLiteDatabase) AuditEvents(ctx context.Context, limit, offset int) ([]AuditEvent, error) {
+	var events []AuditEvent
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return events, err
+	}
+	defer db.put(ctx, conn)
+
+	err = sqlitex.Execute(conn, `
+        SELECT id, action, user_id, username, ip, details, creation_date FROM audit_log
+        ORDER BY creation_date DESC, id DESC
+        LIMIT ? OFFSET ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				events = append(events, scanSQLiteAuditEvent(stmt))
+				return nil
+			},
+			Args: []any{limit, offset},
+		},
+	)
+
+	return events, err
+}
+
+func (db *SQLiteDatabase) AuditEvent(ctx context.Context, id string) (AuditEvent, error) {
+	event := AuditEvent{}
+
+	conn, err := db.take(ctx)
+	if err != nil {
+		return event, err
+	}
+	defer db.put(ctx, conn)
+
+	scanned := false
+	err = sqlitex.Execute(conn,
+		`SELECT id, action, user_id, username, ip, details, creation_date FROM audit_log WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				if scanned {
+					return ErrTooMany
+				}
+				scanned = true
+				event = scanSQLiteAuditEvent(stmt)
+				return nil
+			},
+			Args: []any{id},
+		},
+	)
+	if err != nil {
+		return event, err
+	}
+	if !scanned {
+		return event, ErrNotFound
+	}
+
+	return event, nil
+}
+
+func (db *SQLiteDatabase) AddAuditEvent(ctx context.Context, event AuditEvent) error {
+	conn, err := db.take(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.put(ctx, conn)
+
+	return sqlitex.Execute(conn,
+		`INSERT INTO audit_log (id, action, user_id, username, ip, details, creation_date) VALUES (?, ?, ?, ?, ?, ?, ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{
+				event.ID, event.Action, event.UserID, event.Username, event.IP, event.Details, event.CreationDate,
+			},
+		},
+	)
+}
+
+func scanSQLiteEmailToken(stmt *sqlite.Stmt) EmailToken {
+	return EmailToken{
+		ID:           stmt.ColumnText(0),
+		UserID:       stmt.ColumnText(1),
+		Purpose:      stmt.ColumnText(2),
+		TokenHash:    stmt.ColumnText(3),
+		Email:        stmt.ColumnText(4),
+		CreationDate: stmt.ColumnInt64(5),
+		Expiration:   stmt.ColumnInt64(6),
+	}
+}
+
+func scanSQLiteEmailVerification(stmt *sqlite.Stmt) EmailVerification {
+	return EmailVerification{
+		UserID:           stmt.ColumnText(0),
+		Email:            stmt.ColumnText(1),
+		VerificationDate: stmt.ColumnInt64(2),
+	}
+}
+
+func scanSQLiteAuditEvent(stmt *sqlite.Stmt) AuditEvent {
+	return AuditEvent{
+		ID:           stmt.ColumnText(0),
+		Action:       stmt.ColumnText(1),
+		UserID:       stmt.ColumnText(2),
+		Username:     stmt.ColumnText(3),
+		IP:           stmt.ColumnText(4),
+		Details:      stmt.ColumnText(5),
+		CreationDate: stmt.ColumnInt64(6),
+	}
+}
+
+func scanSQLiteTOTPSecret(stmt *sqlite.Stmt) TOTPSecret {
+	return TOTPSecret{
+		UserID:       stmt.ColumnText(0),
+		Secret:       stmt.ColumnText(1),
+		Enabled:      stmt.ColumnBool(2),
+		LastStep:     stmt.ColumnInt64(3),
+		CreationDate: stmt.ColumnInt64(4),
+	}
+}
+
+func scanSQLiteSession(stmt *sqlite.Stmt) Session {
+	return Session{
+		ID:           stmt.ColumnText(0),
+		UserID:       stmt.ColumnText(1),
+		DeviceName:   stmt.ColumnText(2),
+		UserAgent:    stmt.ColumnText(3),
+		IP:           stmt.ColumnText(4),
+		CreationDate: stmt.ColumnInt64(5),
+		LastUsed:     stmt.ColumnInt64(6),
+		Expiration:   stmt.ColumnInt64(7),
+	}
 }
 
 func init() {
 	db := &SQLiteDatabase{}
 	Registry["sqlite"] = db
 }
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (sqliteDialect) ListContains(column, value string) string {
+	return "EXISTS (SELECT 1 FROM json_each(COALESCE(" + column + ", '[]')) WHERE json_each.value = " + value + ")"
+}
+
+func (sqliteDialect) ContainsFold(expr, value string) string {
+	return "instr(lower(" + expr + "), lower(" + value + ")) > 0"
+}
+
+func (sqliteDialect) Random() string {
+	return "random()"
+}
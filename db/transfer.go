@@ -0,0 +1,337 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/libramusic/libracore/media"
+)
+
+const DefaultTransferBatchSize = 500
+
+// TransferProgress is reported after every batch copied by Transfer.
+type TransferProgress struct {
+	Table   string
+	Copied  int
+	Skipped int
+	Total   int
+}
+
+type TransferOptions struct {
+	// The number of rows read and written at a time. Each batch is written in its own transaction,
+	// and progress is reported after it. Defaults to DefaultTransferBatchSize.
+	BatchSize int
+	Progress  func(progress TransferProgress)
+}
+
+// TransferResult holds the verification counts for a single table.
+type TransferResult struct {
+	Table string
+	// The number of rows in the source database.
+	Source int
+	// The number of rows in the target database after the transfer.
+	Target int
+	// The number of rows copied during this transfer.
+	Copied int
+	// The number of rows that already existed in the target database.
+	Skipped int
+}
+
+// Transfer copies every table from one database into another.
+// The target database is migrated to the latest schema first.
+// Rows that already exist in the target are skipped, so an interrupted
+// transfer can be resumed by running it again.
+func Transfer(ctx context.Context, from, to Database, opts TransferOptions) ([]TransferResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultTransferBatchSize
+	}
+	if opts.Progress == nil {
+		opts.Progress = func(TransferProgress) {}
+	}
+
+	if err := to.MigrateUp(-1); err != nil {
+		return nil, fmt.Errorf("failed to migrate target database: %w", err)
+	}
+
+	results := make([]TransferResult, 0, len(tables))
+	for _, table := range tables {
+		result, err := tableTransfers[table](ctx, from, to, opts)
+		if err != nil {
+			return results, fmt.Errorf("failed to transfer %s: %w", table, err)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// tableTransfer copies one table from one database into another.
+type tableTransfer func(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error)
+
+// tableTransfers copies each of tables.
+var tableTransfers = map[string]tableTransfer{
+	"users":                transferUsers,
+	"auth_providers":       transferProviderLinks,
+	"tracks":               transferTracks,
+	"albums":               transferAlbums,
+	"videos":               transferVideos,
+	"artists":              transferArtists,
+	"playlists":            transferPlaylists,
+	"listens":              transferListens,
+	"favorites":            transferFavorites,
+	"listenbrainz_tokens":  transferListenBrainzTokens,
+	"scrobble_queue":       transferScrobbles,
+	"scrobbler_links":      transferScrobblerLinks,
+	"play_queues":          transferPlayQueues,
+	"blacklisted_tokens":   transferBlacklistedTokens,
+	"refresh_tokens":       transferRefreshTokens,
+	"sessions":             transferSessions,
+	"totp_secrets":         transferTOTPSecrets,
+	"recovery_codes":       transferRecoveryCodes,
+	"webauthn_credentials": transferWebAuthnCredentials,
+	"access_tokens":        transferAccessTokens,
+	"email_tokens":         transferEmailTokens,
+	"email_verifications":  transferEmailVerifications,
+	"audit_log":            transferAuditEvents,
+}
+
+func transferUsers(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "users", from.UsersPage, to.UsersPage, to.CreateUser,
+		func(user media.DatabaseUser) string { return user.ID }, opts)
+}
+
+func transferProviderLinks(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "auth_providers", from.ProviderLinksPage, to.ProviderLinksPage,
+		func(ctx context.Context, link ProviderLink) error {
+			return to.LinkProviderAccount(ctx, link.Provider, link.UserID, link.ProviderUserID)
+		},
+		func(link ProviderLink) string { return link.UserID + "\x00" + link.Provider }, opts)
+}
+
+func transferTracks(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "tracks", from.AllTracksPage, to.AllTracksPage, to.AddTrack,
+		func(track media.Track) string { return track.ID }, opts)
+}
+
+func transferAlbums(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "albums", from.AllAlbumsPage, to.AllAlbumsPage, to.AddAlbum,
+		func(album media.Album) string { return album.ID }, opts)
+}
+
+func transferVideos(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "videos", from.AllVideosPage, to.AllVideosPage, to.AddVideo,
+		func(video media.Video) string { return video.ID }, opts)
+}
+
+func transferArtists(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "artists", from.AllArtistsPage, to.AllArtistsPage, to.AddArtist,
+		func(artist media.Artist) string { return artist.ID }, opts)
+}
+
+func transferPlaylists(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "playlists", from.AllPlaylistsPage, to.AllPlaylistsPage, to.AddPlaylist,
+		func(playlist media.Playlist) string { return playlist.ID }, opts)
+}
+
+func transferListens(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "listens", from.AllListensPage, to.AllListensPage, to.AddListen,
+		func(listen media.Listen) string { return listen.ID }, opts)
+}
+
+func transferFavorites(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "favorites", from.AllFavoritesPage, to.AllFavoritesPage,
+		func(ctx context.Context, favorite media.Favorite) error {
+			_, err := to.AddFavorite(ctx, favorite)
+			return err
+		},
+		func(favorite media.Favorite) string {
+			return favorite.UserID + "\x00" + favorite.PlayableType + "\x00" + favorite.PlayableID
+		}, opts)
+}
+
+func transferListenBrainzTokens(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "listenbrainz_tokens", from.ListenBrainzTokensPage, to.ListenBrainzTokensPage,
+		func(ctx context.Context, token ListenBrainzToken) error {
+			return to.SetListenBrainzToken(ctx, token.UserID, token.TokenHash, token.CreationDate)
+		},
+		func(token ListenBrainzToken) string { return token.UserID }, opts)
+}
+
+func transferScrobbles(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "scrobble_queue", from.AllScrobblesPage, to.AllScrobblesPage, to.AddScrobble,
+		func(scrobble Scrobble) string { return scrobble.ID }, opts)
+}
+
+func transferScrobblerLinks(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "scrobbler_links", from.ScrobblerLinksPage, to.ScrobblerLinksPage,
+		to.SetScrobblerLink, func(link ScrobblerLink) string { return link.UserID + "\x00" + link.Scrobbler }, opts)
+}
+
+func transferPlayQueues(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "play_queues", from.AllPlayQueuesPage, to.AllPlayQueuesPage, to.SetPlayQueue,
+		func(queue media.PlayQueue) string { return queue.UserID }, opts)
+}
+
+func transferBlacklistedTokens(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "blacklisted_tokens", from.BlacklistedTokensPage, to.BlacklistedTokensPage,
+		func(ctx context.Context, token BlacklistedToken) error {
+			return to.BlacklistToken(ctx, token.Token, token.Expiration)
+		},
+		func(token BlacklistedToken) string { return token.Token }, opts)
+}
+
+func transferRefreshTokens(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "refresh_tokens", from.RefreshTokensPage, to.RefreshTokensPage,
+		to.AddRefreshToken, func(token RefreshToken) string { return token.TokenHash }, opts)
+}
+
+func transferSessions(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "sessions", from.SessionsPage, to.SessionsPage, to.AddSession,
+		func(session Session) string { return session.ID }, opts)
+}
+
+func transferTOTPSecrets(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "totp_secrets", from.TOTPSecretsPage, to.TOTPSecretsPage, to.SetTOTPSecret,
+		func(secret TOTPSecret) string { return secret.UserID }, opts)
+}
+
+func transferRecoveryCodes(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "recovery_codes", from.RecoveryCodesPage, to.RecoveryCodesPage,
+		to.AddRecoveryCode, func(code RecoveryCode) string { return code.UserID + "\x00" + code.CodeHash }, opts)
+}
+
+func transferWebAuthnCredentials(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "webauthn_credentials", from.WebAuthnCredentialsPage, to.WebAuthnCredentialsPage,
+		to.AddWebAuthnCredential, func(credential WebAuthnCredential) string { return credential.ID }, opts)
+}
+
+func transferAccessTokens(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "access_tokens", from.AccessTokensPage, to.AccessTokensPage,
+		to.AddAccessToken, func(token AccessToken) string { return token.ID }, opts)
+}
+
+func transferEmailTokens(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "email_tokens", from.EmailTokensPage, to.EmailTokensPage,
+		to.AddEmailToken, func(token EmailToken) string { return token.ID }, opts)
+}
+
+func transferEmailVerifications(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "email_verifications", from.EmailVerificationsPage, to.EmailVerificationsPage,
+		to.SetEmailVerification, func(verification EmailVerification) string { return verification.UserID }, opts)
+}
+
+func transferAuditEvents(ctx context.Context, from, to Database, opts TransferOptions) (TransferResult, error) {
+	return transferTable(ctx, from, to, "audit_log", from.AllAuditEventsPage, to.AllAuditEventsPage,
+		to.AddAuditEvent, func(event AuditEvent) string { return event.ID }, opts)
+}
+
+// transferTable copies the rows of a table into the target a batch at a time,
+// skipping rows whose key is already present in the target.
+// Each batch is written in its own transaction, so a failed transfer leaves no partial batch behind.
+func transferTable[T any](
+	ctx context.Context,
+	from, to Database,
+	table string,
+	listSource, listTarget func(ctx context.Context, limit, offset int) ([]T, error),
+	add func(ctx context.Context, row T) error,
+	key func(row T) string,
+	opts TransferOptions,
+) (TransferResult, error) {
+	result := TransferResult{Table: table}
+
+	var err error
+	result.Source, err = from.CountRows(ctx, table)
+	if err != nil {
+		return result, err
+	}
+
+	// Only the keys of the rows are kept in memory.
+	existing, err := tableKeys(ctx, listTarget, key, opts.BatchSize)
+	if err != nil {
+		return result, err
+	}
+	var sourceKeys []string
+
+	for offset := 0; ; offset += opts.BatchSize {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		rows, err := listSource(ctx, opts.BatchSize, offset)
+		if err != nil {
+			return result, err
+		}
+
+		copied := 0
+		err = to.Transaction(ctx, func(ctx context.Context) error {
+			for _, row := range rows {
+				sourceKeys = append(sourceKeys, key(row))
+				if _, ok := existing[key(row)]; ok {
+					continue
+				}
+				if err := add(ctx, row); err != nil {
+					return fmt.Errorf("row %q: %w", key(row), err)
+				}
+				copied++
+			}
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+		result.Copied += copied
+		result.Skipped += len(rows) - copied
+
+		if len(rows) > 0 {
+			opts.Progress(TransferProgress{
+				Table:   table,
+				Copied:  result.Copied,
+				Skipped: result.Skipped,
+				Total:   result.Source,
+			})
+		}
+		if len(rows) < opts.BatchSize {
+			break
+		}
+	}
+
+	// Verify that every source row made it into the target. The target may have rows of its own,
+	// so comparing the counts isn't enough.
+	result.Target, err = to.CountRows(ctx, table)
+	if err != nil {
+		return result, err
+	}
+	copied, err := tableKeys(ctx, listTarget, key, opts.BatchSize)
+	if err != nil {
+		return result, err
+	}
+	for _, k := range sourceKeys {
+		if _, ok := copied[k]; !ok {
+			return result, fmt.Errorf("verification failed: row %q of the source is missing from the target", k)
+		}
+	}
+
+	return result, nil
+}
+
+// tableKeys returns the keys of every row of a table, listing them a batch at a time.
+func tableKeys[T any](
+	ctx context.Context,
+	list func(ctx context.Context, limit, offset int) ([]T, error),
+	key func(row T) string,
+	batchSize int,
+) (map[string]struct{}, error) {
+	keys := map[string]struct{}{}
+	for offset := 0; ; offset += batchSize {
+		rows, err := list(ctx, batchSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			keys[key(row)] = struct{}{}
+		}
+		if len(rows) < batchSize {
+			return keys, nil
+		}
+	}
+}
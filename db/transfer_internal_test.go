@@ -0,0 +1,14 @@
+package db
+
+import "testing"
+
+func TestEveryTableIsTransferred(t *testing.T) {
+	for _, table := range tables {
+		if _, ok := tableTransfers[table]; !ok {
+			t.Errorf("%s isn't transferred", table)
+		}
+	}
+	if len(tableTransfers) != len(tables) {
+		t.Errorf("%d tables are transferred, but there are %d", len(tableTransfers), len(tables))
+	}
+}
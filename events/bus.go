@@ -0,0 +1,92 @@
+package events
+
+import (
+	"slices"
+	"sync"
+)
+
+// How many events a subscriber can fall behind before it's disconnected.
+const subscriptionBuffer = 256
+
+var (
+	subscriptions   = map[*Subscription]struct{}{}
+	subscriptionsMu sync.Mutex
+	lastID          uint64
+)
+
+// Subscription receives the events published for a user.
+type Subscription struct {
+	// Events receives the events in the order they were published.
+	// It's closed when the subscription is closed, or when the subscriber fell too far behind to catch up.
+	Events <-chan Event
+
+	events chan Event
+	userID string
+	types  []string
+	closed bool
+}
+
+// Subscribe returns a subscription to the events the user may receive.
+// If types isn't empty, only events whose type or category is in types are received.
+// The subscription must be closed when it's no longer needed.
+func Subscribe(userID string, types []string) *Subscription {
+	events := make(chan Event, subscriptionBuffer)
+	s := &Subscription{Events: events, events: events, userID: userID, types: types}
+
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+	subscriptions[s] = struct{}{}
+	return s
+}
+
+// Close stops the subscription. Closing it again does nothing.
+func (s *Subscription) Close() {
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+	s.close()
+}
+
+// CloseAll closes every subscription, which ends the event streams so the server can shut down.
+func CloseAll() {
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+	for s := range subscriptions {
+		s.close()
+	}
+}
+
+func (s *Subscription) close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(subscriptions, s)
+	close(s.events)
+}
+
+func (s *Subscription) wants(event Event) bool {
+	if len(s.types) > 0 && !slices.Contains(s.types, event.Type) && !slices.Contains(s.types, event.Category()) {
+		return false
+	}
+	return event.visibleTo(s.userID)
+}
+
+// Publish sends the event to every subscriber that may receive it. It never blocks,
+// so subscribers that don't keep up are disconnected instead of slowing down the publisher.
+func Publish(event Event) {
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+
+	lastID++
+	event.ID = lastID
+	for s := range subscriptions {
+		if !s.wants(event) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			s.close()
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package events_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/libramusic/libracore/events"
+	"github.com/libramusic/libracore/media"
+)
+
+func subscribe(t *testing.T, userID string, types ...string) *events.Subscription {
+	t.Helper()
+	s := events.Subscribe(userID, types)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// received returns the types of the events the subscription has received so far.
+func received(s *events.Subscription) []string {
+	var types []string
+	for {
+		select {
+		case event, ok := <-s.Events:
+			if !ok {
+				return types
+			}
+			types = append(types, event.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	all := subscribe(t, "alice")
+	playables := subscribe(t, "alice", "playable")
+	queue := subscribe(t, "alice", events.QueueChanged, events.ScanProgress)
+
+	events.Publish(events.ForEveryone(events.PlayableAdded, nil))
+	events.Publish(events.ForEveryone(events.QueueChanged, nil))
+	events.Publish(events.ForEveryone(events.PlayableDeleted, nil))
+	events.Publish(events.ForEveryone(events.DownloadProgress, nil))
+
+	tests := []struct {
+		name string
+		s    *events.Subscription
+		want []string
+	}{
+		{"all", all, []string{
+			events.PlayableAdded, events.QueueChanged, events.PlayableDeleted, events.DownloadProgress,
+		}},
+		{"category", playables, []string{events.PlayableAdded, events.PlayableDeleted}},
+		{"types", queue, []string{events.QueueChanged}},
+	}
+	for _, tt := range tests {
+		if got := received(tt.s); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: received %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPublishIDs(t *testing.T) {
+	s := subscribe(t, "alice")
+
+	events.Publish(events.ForEveryone(events.ScanProgress, nil))
+	events.Publish(events.ForEveryone(events.ScanProgress, nil))
+
+	first, second := <-s.Events, <-s.Events
+	if second.ID <= first.ID {
+		t.Errorf("event IDs %d and %d don't increase", first.ID, second.ID)
+	}
+}
+
+func TestForUser(t *testing.T) {
+	alice := subscribe(t, "alice")
+	bob := subscribe(t, "bob")
+
+	events.Publish(events.ForUser("alice", events.QueueChanged, nil))
+
+	if got := received(alice); !slices.Equal(got, []string{events.QueueChanged}) {
+		t.Errorf("alice received %v", got)
+	}
+	if got := received(bob); len(got) != 0 {
+		t.Errorf("bob received alice's events %v", got)
+	}
+}
+
+func TestPlayableEvent(t *testing.T) {
+	alice := subscribe(t, "alice")
+	bob := subscribe(t, "bob")
+	carol := subscribe(t, "carol")
+
+	playlist := media.Playlist{
+		ID:          "playlist",
+		UserID:      "alice",
+		Visibility:  media.PlaylistVisibilityPrivate,
+		Permissions: map[string]string{"bob": media.PlaylistRoleViewer},
+	}
+	events.Publish(events.PlayableEvent(events.PlayableUpdated, playlist, playlist.CanView))
+
+	for _, s := range []*events.Subscription{alice, bob} {
+		if got := received(s); !slices.Equal(got, []string{events.PlayableUpdated}) {
+			t.Errorf("user who can see the playlist received %v", got)
+		}
+	}
+	if got := received(carol); len(got) != 0 {
+		t.Errorf("carol received events about a private playlist %v", got)
+	}
+
+	// Playables without canView are visible to everyone.
+	events.Publish(events.PlayableEvent(events.PlayableAdded, media.Track{ID: "track"}, nil))
+	if got := received(carol); !slices.Equal(got, []string{events.PlayableAdded}) {
+		t.Errorf("carol received %v", got)
+	}
+}
+
+func TestPlayableEventData(t *testing.T) {
+	s := subscribe(t, "alice")
+
+	track := media.Track{ID: "track"}
+	events.Publish(events.PlayableEvent(events.PlayableUpdated, track, nil))
+	events.Publish(events.PlayableEvent(events.PlayableDeleted, track, nil))
+
+	updated := (<-s.Events).Data.(events.PlayableChange)
+	if updated.PlayableType != "track" || updated.PlayableID != "track" || updated.Playable == nil {
+		t.Errorf("updated event has data %+v", updated)
+	}
+	// Deleted playables are left out.
+	if deleted := (<-s.Events).Data.(events.PlayableChange); deleted.Playable != nil {
+		t.Errorf("deleted event includes the playable %+v", deleted.Playable)
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	slow := subscribe(t, "alice")
+	other := subscribe(t, "bob", events.QueueChanged)
+
+	// Nobody reads from slow, so it falls behind once its buffer is full.
+	for range 1000 {
+		events.Publish(events.ForEveryone(events.ScanProgress, nil))
+	}
+
+	count := 0
+	for range slow.Events {
+		count++
+	}
+	if count == 0 || count >= 1000 {
+		t.Errorf("slow subscriber received %d events before being dropped", count)
+	}
+
+	// Others keep receiving events, and closing a dropped subscription again does nothing.
+	events.Publish(events.ForEveryone(events.QueueChanged, nil))
+	if got := received(other); !slices.Equal(got, []string{events.QueueChanged}) {
+		t.Errorf("other subscriber received %v", got)
+	}
+	slow.Close()
+}
+
+func TestCloseAll(t *testing.T) {
+	s := events.Subscribe("alice", nil)
+
+	events.CloseAll()
+	if _, ok := <-s.Events; ok {
+		t.Error("subscription is open after CloseAll")
+	}
+	s.Close()
+}
@@ -0,0 +1,112 @@
+// Package events publishes what happens on the server to the clients that are connected to the event stream.
+package events
+
+import (
+	"strings"
+	"time"
+
+	"github.com/libramusic/libracore/media"
+)
+
+// Types of events.
+const (
+	PlayableAdded     = "playable.added"
+	PlayableUpdated   = "playable.updated"
+	PlayableDeleted   = "playable.deleted"
+	DownloadProgress  = "download.progress"
+	QueueChanged      = "queue.changed"
+	NowPlayingChanged = "now_playing.changed"
+	ScanProgress      = "scan.progress"
+)
+
+// Event is something that happened on the server.
+type Event struct {
+	// Increases with every event published since the server started.
+	ID uint64 `json:"id" example:"42"`
+
+	Type string `json:"type" example:"playable.added"`
+	Time int64  `json:"time" example:"1634296980"`
+	Data any    `json:"data"`
+
+	// visibleTo reports whether a user may receive the event.
+	visibleTo func(userID string) bool
+}
+
+// Category returns the part of the event's type before the dot, such as playable for playable.added.
+func (e Event) Category() string {
+	category, _, _ := strings.Cut(e.Type, ".")
+	return category
+}
+
+// PlayableChange is the data of playable.added, playable.updated and playable.deleted events.
+type PlayableChange struct {
+	PlayableType string `json:"playable_type" example:"track"`
+	PlayableID   string `json:"playable_id"   example:"7nTwkcl51u4"`
+
+	// The playable after the change. It's left out when the playable was deleted.
+	Playable media.Playable `json:"playable,omitempty"`
+}
+
+// Download is the data of download.progress events.
+type Download struct {
+	URL string `json:"url" example:"https://example.com/track.flac"`
+
+	// The number of bytes received so far.
+	Received int64 `json:"received" example:"1048576"`
+
+	// The size of the download in bytes, or -1 if it's unknown.
+	Total int64 `json:"total" example:"4194304"`
+	Done  bool  `json:"done"`
+
+	// Why the download failed. It's empty while the download is running and when it succeeded.
+	Error string `json:"error,omitempty"`
+}
+
+// NowPlaying is the data of now_playing.changed events.
+type NowPlaying struct {
+	UserID string `json:"user_id" example:"TPkrKcIZRRq"`
+
+	// What the user started playing, or null if they stopped.
+	Listen *media.Listen `json:"listen"`
+
+	// When the listen stops being reported as playing, unless it's replaced before.
+	ExpiresAt int64 `json:"expires_at,omitempty" example:"1634297280"`
+}
+
+// Scan is the data of scan.progress events.
+type Scan struct {
+	Source  string `json:"source"  example:"file:/music"`
+	Scanned int    `json:"scanned" example:"120"`
+
+	// The number of files to scan, or -1 if it isn't known yet.
+	Total int  `json:"total" example:"4000"`
+	Done  bool `json:"done"`
+}
+
+// ForEveryone returns an event that every user receives.
+func ForEveryone(eventType string, data any) Event {
+	return New(eventType, data, func(string) bool { return true })
+}
+
+// ForUser returns an event that only the given user receives.
+func ForUser(userID, eventType string, data any) Event {
+	return New(eventType, data, func(id string) bool { return id == userID })
+}
+
+// New returns an event that the users for whom visibleTo returns true receive.
+func New(eventType string, data any, visibleTo func(userID string) bool) Event {
+	return Event{Type: eventType, Time: time.Now().Unix(), Data: data, visibleTo: visibleTo}
+}
+
+// PlayableEvent returns a playable event that the users who can see the playable receive.
+// canView reports whether a user can see the playable, and everyone can if it's nil.
+func PlayableEvent(eventType string, playable media.Playable, canView func(userID string) bool) Event {
+	change := PlayableChange{PlayableType: playable.GetType(), PlayableID: playable.GetID()}
+	if eventType != PlayableDeleted {
+		change.Playable = playable
+	}
+	if canView == nil {
+		return ForEveryone(eventType, change)
+	}
+	return New(eventType, change, canView)
+}
@@ -0,0 +1,191 @@
+// Package mail sends emails to users, such as links to verify their address or reset their password.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/libramusic/libracore/config"
+)
+
+// Values of mail.encryption.
+const (
+	EncryptionSTARTTLS = "starttls"
+	EncryptionTLS      = "tls"
+	EncryptionNone     = "none"
+)
+
+// How long connecting to the SMTP server and sending an email may take.
+const sendTimeout = 30 * time.Second
+
+// ErrNotConfigured is returned when sending an email while neither an SMTP server nor mail.log_only is set.
+var ErrNotConfigured = errors.New("sending email is not configured")
+
+// Message is an email with a plain text and an HTML version of its body.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Enabled reports whether emails can be sent, or are logged instead.
+func Enabled() bool {
+	return config.Conf.Mail.Host != "" || config.Conf.Mail.LogOnly
+}
+
+// Send sends the message through the SMTP server in the config, or logs it if mail.log_only is on.
+func Send(ctx context.Context, msg Message) error {
+	conf := config.Conf.Mail
+	if conf.LogOnly {
+		log.Info("Email", "to", msg.To, "subject", msg.Subject, "body", msg.Text)
+		return nil
+	}
+	if conf.Host == "" {
+		return ErrNotConfigured
+	}
+
+	from, err := netmail.ParseAddress(conf.From)
+	if err != nil {
+		return fmt.Errorf("invalid mail.from: %w", err)
+	}
+	to, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+
+	data, err := compose(from, to, msg)
+	if err != nil {
+		return err
+	}
+	return send(ctx, conf, from.Address, to.Address, data)
+}
+
+// compose encodes the message as a multipart/alternative email.
+func compose(from, to *netmail.Address, msg Message) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	parts := []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	}
+	for _, part := range parts {
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+	headers := []struct{ name, value string }{
+		{"From", from.String()},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", msg.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", "<" + rand.Text() + "@" + domain + ">"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + strconv.Quote(writer.Boundary())},
+	}
+
+	var data bytes.Buffer
+	for _, header := range headers {
+		data.WriteString(header.name + ": " + header.value + "\r\n")
+	}
+	data.WriteString("\r\n")
+	data.Write(body.Bytes())
+	return data.Bytes(), nil
+}
+
+// send delivers the email data to the recipient through the SMTP server.
+func send(ctx context.Context, conf config.MailConfig, from, to string, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(conf.Host, strconv.Itoa(conf.Port))
+	tlsConfig := &tls.Config{ServerName: conf.Host, MinVersion: tls.VersionTLS12}
+
+	var conn net.Conn
+	var err error
+	switch conf.Encryption {
+	case EncryptionTLS:
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	case EncryptionSTARTTLS, EncryptionNone:
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	default:
+		return fmt.Errorf("unknown mail.encryption %q, expected starttls, tls or none", conf.Encryption)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	client, err := smtp.NewClient(conn, conf.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if conf.Encryption == EncryptionSTARTTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if conf.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", conf.Username, conf.Password, conf.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,221 @@
+package mail_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/mail"
+)
+
+// received is an email accepted by the SMTP stand-in.
+type received struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// startSMTPServer starts an SMTP stand-in on a free local port, which accepts every email and sends it on the
+// returned channel.
+func startSMTPServer(t *testing.T) (int, <-chan received) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	emails := make(chan received, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serveSMTP(textproto.NewConn(conn), emails)
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port, emails
+}
+
+func serveSMTP(conn *textproto.Conn, emails chan<- received) {
+	var email received
+	_ = conn.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		command, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(command) {
+		case "EHLO":
+			_ = conn.PrintfLine("250-localhost")
+			_ = conn.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			credentials, _ := strings.CutPrefix(arg, "PLAIN ")
+			decoded, _ := base64.StdEncoding.DecodeString(credentials)
+			email.auth = string(decoded)
+			_ = conn.PrintfLine("235 Authenticated")
+		case "MAIL":
+			email.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			_ = conn.PrintfLine("250 OK")
+		case "RCPT":
+			email.to = append(email.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			_ = conn.PrintfLine("250 OK")
+		case "DATA":
+			_ = conn.PrintfLine("354 Go ahead")
+			data, err := io.ReadAll(conn.DotReader())
+			if err != nil {
+				return
+			}
+			email.data = string(data)
+			_ = conn.PrintfLine("250 OK")
+			emails <- email
+		case "QUIT":
+			_ = conn.PrintfLine("221 Bye")
+			return
+		default:
+			_ = conn.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+func TestSendThroughSMTPServer(t *testing.T) {
+	port, emails := startSMTPServer(t)
+	config.Conf.Mail = config.MailConfig{
+		Host:       "127.0.0.1",
+		Port:       port,
+		Username:   "libra",
+		Password:   "secret",
+		From:       "Libra <libra@example.com>",
+		Encryption: mail.EncryptionNone,
+	}
+
+	msg, err := mail.Render(mail.TemplatePasswordReset, "alice@example.com", mail.TemplateData{
+		Username:   "alice",
+		ServerName: "Libra",
+		Link:       "https://libra.example.com/reset-password?token=abc",
+		ExpiresIn:  time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mail.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	var email received
+	select {
+	case email = <-emails:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the SMTP server didn't receive the email")
+	}
+
+	if email.auth != "\x00libra\x00secret" {
+		t.Errorf("authenticated with %q", email.auth)
+	}
+	if email.from != "libra@example.com" {
+		t.Errorf("sent from %q, want libra@example.com", email.from)
+	}
+	if len(email.to) != 1 || email.to[0] != "alice@example.com" {
+		t.Errorf("sent to %q, want alice@example.com", email.to)
+	}
+
+	parsed, err := netmail.ReadMessage(strings.NewReader(email.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "Reset your password" {
+		t.Errorf("subject is %q", subject)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type is %q, %v", mediaType, err)
+	}
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, want := range []string{"text/plain", "text/html"} {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contentType := part.Header.Get("Content-Type"); !strings.HasPrefix(contentType, want) {
+			t.Errorf("part has content type %q, want %s", contentType, want)
+		}
+		// The multipart reader decodes quoted-printable parts.
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(body), "https://libra.example.com/reset-password?token=abc") {
+			t.Errorf("%s part doesn't contain the link:\n%s", want, body)
+		}
+		if !strings.Contains(string(body), "expires in 1 hour") {
+			t.Errorf("%s part doesn't say when the link expires:\n%s", want, body)
+		}
+	}
+}
+
+func TestLogOnlyDoesNotConnect(t *testing.T) {
+	config.Conf.Mail = config.MailConfig{
+		// Nothing listens on port 1, so sending would fail.
+		Host:       "127.0.0.1",
+		Port:       1,
+		Encryption: mail.EncryptionNone,
+		LogOnly:    true,
+	}
+
+	err := mail.Send(context.Background(), mail.Message{To: "alice@example.com", Subject: "Hi", Text: "Hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendWithoutServer(t *testing.T) {
+	config.Conf.Mail = config.MailConfig{}
+
+	if mail.Enabled() {
+		t.Error("mail is enabled without a server")
+	}
+	err := mail.Send(context.Background(), mail.Message{To: "alice@example.com"})
+	if !errors.Is(err, mail.ErrNotConfigured) {
+		t.Errorf("got %v, want ErrNotConfigured", err)
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	msg, err := mail.Render(mail.TemplateVerifyEmail, "bob@example.com", mail.TemplateData{
+		Username:  "<b>bob</b>",
+		Link:      "https://libra.example.com/verify-email?token=a&b",
+		ExpiresIn: 48 * time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(msg.HTML, "<b>bob</b>") {
+		t.Errorf("HTML isn't escaped:\n%s", msg.HTML)
+	}
+	if !strings.Contains(msg.Text, "Hi <b>bob</b>,") {
+		t.Errorf("plain text is escaped:\n%s", msg.Text)
+	}
+	if !strings.Contains(msg.Text, "expires in 2 days") {
+		t.Errorf("expiry isn't formatted:\n%s", msg.Text)
+	}
+}
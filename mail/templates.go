@@ -0,0 +1,80 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Names of the templates emails are rendered from.
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplatePasswordReset = "password_reset"
+	TemplateChangeEmail   = "change_email"
+)
+
+// Each template has a plain text version, which also defines the subject, and an HTML version.
+//
+//go:embed templates
+var templateFS embed.FS
+
+// TemplateData is what templates are rendered with.
+type TemplateData struct {
+	Username   string
+	ServerName string
+	Link       string
+	ExpiresIn  time.Duration
+}
+
+var funcs = map[string]any{"duration": formatDuration}
+
+// Render renders the template with the given name into a message to the address to.
+func Render(name, to string, data TemplateData) (Message, error) {
+	text, err := texttemplate.New(name).Funcs(funcs).ParseFS(templateFS, "templates/"+name+".txt")
+	if err != nil {
+		return Message{}, err
+	}
+	html, err := htmltemplate.New(name).Funcs(funcs).ParseFS(templateFS, "templates/"+name+".html")
+	if err != nil {
+		return Message{}, err
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := text.ExecuteTemplate(&textBody, name+".txt", data); err != nil {
+		return Message{}, err
+	}
+	if err := html.ExecuteTemplate(&htmlBody, name+".html", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    textBody.String(),
+		HTML:    htmlBody.String(),
+	}, nil
+}
+
+// formatDuration formats a duration for people, such as "1 day" or "30 minutes", in the largest unit that fits evenly.
+func formatDuration(d time.Duration) string {
+	const day = 24 * time.Hour
+
+	n, unit := int64(d/time.Minute), "minute"
+	switch {
+	case d >= day && d%day == 0:
+		n, unit = int64(d/day), "day"
+	case d >= time.Hour && d%time.Hour == 0:
+		n, unit = int64(d/time.Hour), "hour"
+	}
+	if n == 1 {
+		return "1 " + unit
+	}
+	return strconv.FormatInt(n, 10) + " " + unit + "s"
+}
@@ -16,6 +16,10 @@ type Artist struct {
 	Permissions    map[string]string `json:"permissions"`
 	LinkedItemIDs  []string          `json:"linked_item_ids"`
 	MetadataSource string            `json:"metadata_source"`
+
+	// Whether the authenticated user has favorited the playable.
+	// Only set in API responses to authenticated users.
+	Favorited *bool `json:"favorited,omitempty"`
 }
 
 func (Artist) GetType() string {
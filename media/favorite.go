@@ -0,0 +1,9 @@
+package media
+
+// Favorite marks a playable as one of a user's favorites.
+type Favorite struct {
+	UserID       string `json:"user_id"       example:"TPkrKcIZRRq"`
+	PlayableID   string `json:"playable_id"   example:"7nTwkcl51u4"`
+	PlayableType string `json:"playable_type" example:"track"`
+	FavoritedAt  int64  `json:"favorited_at"  example:"1634296980"`
+}
@@ -0,0 +1,27 @@
+package media
+
+// Listen is a single playback of a playable by a user.
+// DurationPlayed is the number of seconds that were actually played.
+//
+// Listens submitted by scrobbling clients also keep the metadata they were submitted with.
+// If that metadata couldn't be matched to a track in the library, PlayableID is empty.
+type Listen struct {
+	ID             string `json:"id"              example:"aZ3kd9LmQ2x"`
+	UserID         string `json:"user_id"         example:"TPkrKcIZRRq"`
+	PlayableID     string `json:"playable_id"     example:"7nTwkcl51u4"`
+	PlayableType   string `json:"playable_type"   example:"track"`
+	ListenedAt     int64  `json:"listened_at"     example:"1634296980"`
+	DurationPlayed int    `json:"duration_played" example:"180"`
+	Client         string `json:"client"          example:"Libra Web"`
+	Source         string `json:"source"          example:"playlist"`
+	TrackName      string `json:"track_name"      example:"Lorem"`
+	ArtistName     string `json:"artist_name"     example:"Ipsum"`
+	ReleaseName    string `json:"release_name"    example:"Dolor"`
+	RecordingMBID  string `json:"recording_mbid"  example:"8f3471b5-7e6a-48da-86a9-c1c07a0f47ae"`
+	ISRC           string `json:"isrc"            example:"USSKG1912345"`
+}
+
+// IsMatched reports whether the listen is linked to a playable in the library.
+func (l Listen) IsMatched() bool {
+	return l.PlayableID != ""
+}
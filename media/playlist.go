@@ -1,5 +1,32 @@
 package media
 
+// Playlist visibilities.
+const (
+	// Only the owner and the users the playlist is shared with can see the playlist.
+	PlaylistVisibilityPrivate = "private"
+	// Anyone with a link can see the playlist, but it isn't listed.
+	PlaylistVisibilityUnlisted = "unlisted"
+	// Anyone can see the playlist, and it's listed with the owner's playables.
+	PlaylistVisibilityPublic = "public"
+)
+
+// Playlist kinds.
+const (
+	// The tracks of standard playlists are chosen by their owner and editors.
+	PlaylistKindStandard = "standard"
+	// The tracks of smart playlists are selected by their rules, which are re-evaluated when the playlist
+	// is read or saved and on a schedule.
+	PlaylistKindSmart = "smart"
+)
+
+// Roles a playlist can be shared with in its permissions, which map user IDs to roles.
+const (
+	// Viewers can see the playlist even if it's private.
+	PlaylistRoleViewer = "viewer"
+	// Editors can also add, move and remove the playlist's tracks.
+	PlaylistRoleEditor = "editor"
+)
+
 type Playlist struct {
 	ID             string            `json:"id"              example:"JpXHsNCAATt"`
 	UserID         string            `json:"user_id"         example:"TPkrKcIZRRq"`
@@ -14,6 +41,16 @@ type Playlist struct {
 	AdditionalMeta map[string]any    `json:"additional_meta"`
 	Permissions    map[string]string `json:"permissions"`
 	MetadataSource string            `json:"metadata_source"`
+	Revision       int               `json:"revision"        example:"3"`
+	Visibility     string            `json:"visibility"      example:"private"`
+	Entries        []PlaylistEntry   `json:"entries"`
+	Kind           string            `json:"kind"            example:"smart"`
+	Rules          string            `json:"rules"           example:"tag = \"jazz\" LIMIT 100"`
+	EvaluatedAt    int64             `json:"evaluated_at"    example:"1634296980"`
+
+	// Whether the authenticated user has favorited the playable.
+	// Only set in API responses to authenticated users.
+	Favorited *bool `json:"favorited,omitempty"`
 }
 
 func (Playlist) GetType() string {
@@ -67,3 +104,62 @@ func (p Playlist) GetViewCount() int {
 func (p Playlist) GetMetadataSource() string {
 	return p.MetadataSource
 }
+
+// CanView reports whether the user can see the playlist.
+// An empty user ID stands for an unauthenticated user.
+func (p Playlist) CanView(userID string) bool {
+	if p.Visibility != PlaylistVisibilityPrivate {
+		return true
+	}
+	return p.IsListedFor(userID)
+}
+
+// CanEdit reports whether the user can change the playlist's tracks.
+func (p Playlist) CanEdit(userID string) bool {
+	if userID == "" {
+		return false
+	}
+	return p.UserID == userID || p.Permissions[userID] == PlaylistRoleEditor
+}
+
+// IsListedFor reports whether the playlist shows up in listings seen by the user.
+// Unlisted playlists are only listed for their owner and the users they're shared with.
+func (p Playlist) IsListedFor(userID string) bool {
+	if p.Visibility == PlaylistVisibilityPublic {
+		return true
+	}
+	if userID == "" {
+		return false
+	}
+	role := p.Permissions[userID]
+	return p.UserID == userID || role == PlaylistRoleViewer || role == PlaylistRoleEditor
+}
+
+// PlaylistEntry is a track in a playlist along with who added it.
+type PlaylistEntry struct {
+	TrackID string `json:"track_id" example:"7nTwkcl51u4"`
+	// The user who added the track to the playlist.
+	AddedBy string `json:"added_by" example:"TPkrKcIZRRq"`
+	AddedAt int64  `json:"added_at" example:"1634296980"`
+}
+
+// ReconcileEntries returns entries for trackIDs, in order.
+// Entries for tracks that are still in the playlist are kept, so a track keeps its attribution
+// when it's moved. Entries for tracks that weren't in entries are attributed to userID at addedAt.
+func ReconcileEntries(entries []PlaylistEntry, trackIDs []string, userID string, addedAt int64) []PlaylistEntry {
+	available := map[string][]PlaylistEntry{}
+	for _, entry := range entries {
+		available[entry.TrackID] = append(available[entry.TrackID], entry)
+	}
+
+	reconciled := make([]PlaylistEntry, len(trackIDs))
+	for i, trackID := range trackIDs {
+		if existing := available[trackID]; len(existing) > 0 {
+			reconciled[i] = existing[0]
+			available[trackID] = existing[1:]
+			continue
+		}
+		reconciled[i] = PlaylistEntry{TrackID: trackID, AddedBy: userID, AddedAt: addedAt}
+	}
+	return reconciled
+}
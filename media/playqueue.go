@@ -0,0 +1,35 @@
+package media
+
+// Repeat modes of a play queue.
+const (
+	RepeatOff = "off"
+	RepeatAll = "all"
+	RepeatOne = "one"
+)
+
+// PlayQueue is what a user is listening to. It's kept on the server so playback can continue on another device.
+type PlayQueue struct {
+	UserID       string       `json:"user_id"       example:"TPkrKcIZRRq"`
+	Items        []QueueItem  `json:"items"`
+	CurrentIndex int          `json:"current_index" example:"2"`
+	PositionMS   int64        `json:"position_ms"   example:"73500"`
+	Shuffle      bool         `json:"shuffle"`
+	Repeat       string       `json:"repeat"        example:"off"`
+	Context      QueueContext `json:"context"`
+	Device       string       `json:"device"        example:"Phone"`
+	Revision     int          `json:"revision"      example:"12"`
+	UpdatedAt    int64        `json:"updated_at"    example:"1634296980"`
+}
+
+// QueueItem is a playable in a play queue.
+type QueueItem struct {
+	PlayableType string `json:"playable_type" example:"track"`
+	PlayableID   string `json:"playable_id"   example:"7nTwkcl51u4"`
+}
+
+// QueueContext is what a play queue was started from, such as an album or a playlist.
+// Both fields are empty for queues that were put together by hand.
+type QueueContext struct {
+	Type string `json:"type" example:"album"`
+	ID   string `json:"id"   example:"BhRpYVlrMo8"`
+}
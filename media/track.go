@@ -23,6 +23,10 @@ type Track struct {
 	ContentSource  string            `json:"content_source"`
 	MetadataSource string            `json:"metadata_source"`
 	LyricSources   map[string]string `json:"lyric_sources"`
+
+	// Whether the authenticated user has favorited the playable.
+	// Only set in API responses to authenticated users.
+	Favorited *bool `json:"favorited,omitempty"`
 }
 
 func (Track) GetType() string {
@@ -1,6 +1,9 @@
 package media
 
-import "strconv"
+import (
+	"slices"
+	"strconv"
+)
 
 type User struct {
 	ID              string            `json:"id"                example:"TPkrKcIZRRq"`
@@ -14,7 +17,7 @@ type User struct {
 	CreationDate    int64             `json:"creation_date"`
 	Permissions     map[string]string `json:"permissions"`
 	LinkedArtistID  string            `json:"linked_artist_id"  example:"h3r3VpPvSq8"`
-	LinkedSources   map[string]string `json:"linked_sources"`
+	LinkedSources   map[string]string `json:"-"`
 }
 
 type DatabaseUser struct {
@@ -69,4 +72,93 @@ func (User) IsTemporary() bool {
 	return false
 }
 
-type AdminPermissions struct{}
+// Roles of users, stored under the PermissionRole key of their permissions.
+// Users who can manage users can only change the roles of users whose role is lower than theirs.
+const (
+	// The owner can do everything, and is the only one who can make users admins.
+	// The first user to register becomes the owner.
+	RoleOwner = "owner"
+	RoleAdmin = "admin"
+	// Users are members unless given another role.
+	RoleMember = "member"
+	RoleGuest  = "guest"
+)
+
+// Roles lists the roles from the most to the least powerful.
+var Roles = []string{RoleOwner, RoleAdmin, RoleMember, RoleGuest}
+
+// PermissionRole is the key of a user's permissions that holds their role.
+const PermissionRole = "role"
+
+// Permissions that roles can be given.
+const (
+	// Change the roles of users with lower roles, and access and change other users' data.
+	PermissionManageUsers = "manage_users"
+	// Change the sources content is added from.
+	PermissionManageSources = "manage_sources"
+	// Add tracks, albums, videos and artists.
+	PermissionUpload = "upload"
+	// Change and delete content added by other users.
+	PermissionDeleteOthersContent = "delete_others_content"
+	// See listening statistics of other users and linked artists.
+	PermissionViewAnalytics = "view_analytics"
+)
+
+// Role returns the user's role.
+func (u User) Role() string {
+	role := u.Permissions[PermissionRole]
+	if !slices.Contains(Roles, role) {
+		return RoleMember
+	}
+	return role
+}
+
+// RoleOutranks reports whether role a is more powerful than role b.
+func RoleOutranks(a, b string) bool {
+	return slices.Index(Roles, a) < slices.Index(Roles, b)
+}
+
+// AdminPermissions is what users with a role are allowed to do. Each field is one of the permissions.
+type AdminPermissions struct {
+	ManageUsers         bool `json:"manage_users"          yaml:"manage_users"`
+	ManageSources       bool `json:"manage_sources"        yaml:"manage_sources"`
+	Upload              bool `json:"upload"                yaml:"upload"`
+	DeleteOthersContent bool `json:"delete_others_content" yaml:"delete_others_content"`
+	ViewAnalytics       bool `json:"view_analytics"        yaml:"view_analytics"`
+}
+
+// DefaultAdminPermissions returns the permissions of a role when they aren't configured.
+func DefaultAdminPermissions(role string) AdminPermissions {
+	switch role {
+	case RoleOwner, RoleAdmin:
+		return AdminPermissions{
+			ManageUsers:         true,
+			ManageSources:       true,
+			Upload:              true,
+			DeleteOthersContent: true,
+			ViewAnalytics:       true,
+		}
+	case RoleMember:
+		return AdminPermissions{Upload: true}
+	default:
+		return AdminPermissions{}
+	}
+}
+
+// Has reports whether the permissions include permission.
+func (p AdminPermissions) Has(permission string) bool {
+	switch permission {
+	case PermissionManageUsers:
+		return p.ManageUsers
+	case PermissionManageSources:
+		return p.ManageSources
+	case PermissionUpload:
+		return p.Upload
+	case PermissionDeleteOthersContent:
+		return p.DeleteOthersContent
+	case PermissionViewAnalytics:
+		return p.ViewAnalytics
+	default:
+		return false
+	}
+}
@@ -19,6 +19,10 @@ type Video struct {
 	ContentSource  string            `json:"content_source"`
 	MetadataSource string            `json:"metadata_source"`
 	LyricSources   map[string]string `json:"lyric_sources"`
+
+	// Whether the authenticated user has favorited the playable.
+	// Only set in API responses to authenticated users.
+	Favorited *bool `json:"favorited,omitempty"`
 }
 
 func (Video) GetType() string {
@@ -0,0 +1,73 @@
+package playlistfile
+
+import (
+	"io"
+
+	"github.com/goccy/go-json"
+)
+
+type jspfDocument struct {
+	Playlist jspfPlaylist `json:"playlist"`
+}
+
+type jspfPlaylist struct {
+	Title  string      `json:"title,omitempty"`
+	Tracks []jspfTrack `json:"track"`
+}
+
+type jspfTrack struct {
+	Locations   stringList `json:"location,omitempty"`
+	Identifiers stringList `json:"identifier,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	Creator     string     `json:"creator,omitempty"`
+	Album       string     `json:"album,omitempty"`
+
+	// Duration in milliseconds.
+	Duration int `json:"duration,omitempty"`
+}
+
+// stringList is a list of strings that may also be written as a single string,
+// which some services do for locations and identifiers.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = nonEmpty(s)
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+func parseJSPF(data []byte) (File, error) {
+	var document jspfDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return File{}, err
+	}
+
+	file := File{Title: document.Playlist.Title}
+	for _, track := range document.Playlist.Tracks {
+		file.Entries = append(file.Entries, xspfEntry(track.Locations, track.Identifiers, xspfTrackInfo{
+			title: track.Title, creator: track.Creator, album: track.Album, duration: track.Duration,
+		}))
+	}
+	return file, nil
+}
+
+func writeJSPF(w io.Writer, file File) error {
+	document := jspfDocument{Playlist: jspfPlaylist{Title: file.Title, Tracks: []jspfTrack{}}}
+	for _, entry := range file.Entries {
+		document.Playlist.Tracks = append(document.Playlist.Tracks, jspfTrack{
+			Locations:   nonEmpty(entry.Location),
+			Identifiers: xspfIdentifiers(entry),
+			Title:       entry.Title,
+			Creator:     entry.Artist,
+			Album:       entry.Album,
+			Duration:    entry.Duration * 1000,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
@@ -0,0 +1,129 @@
+package playlistfile
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/scrobble"
+)
+
+// The title of imported playlists whose files have none, if no title is given.
+const defaultTitle = "Imported playlist"
+
+// ImportResult describes a playlist created from a file.
+type ImportResult struct {
+	Playlist media.Playlist `json:"playlist"`
+	// The entries that matched no track and were left out of the playlist.
+	Unmatched []Unmatched `json:"unmatched"`
+}
+
+// Import creates a private playlist owned by userID from the entries of a file that match a library track.
+// The playlist is titled title if it isn't empty, or after the file otherwise.
+func Import(ctx context.Context, matcher *Matcher, userID, title string, file File) (ImportResult, error) {
+	trackIDs, unmatched := matcher.Match(file.Entries)
+
+	if title == "" {
+		title = file.Title
+	}
+	if title == "" {
+		title = defaultTitle
+	}
+
+	now := time.Now()
+	playlist := media.Playlist{
+		ID:             media.GenerateID(config.Conf.General.IDLength),
+		UserID:         userID,
+		Title:          title,
+		TrackIDs:       trackIDs,
+		CreationDate:   now.Format(time.DateOnly),
+		AdditionDate:   now.Unix(),
+		Tags:           []string{},
+		AdditionalMeta: map[string]any{},
+		Permissions:    map[string]string{},
+		Visibility:     media.PlaylistVisibilityPrivate,
+		Entries:        media.ReconcileEntries(nil, trackIDs, userID, now.Unix()),
+		Kind:           media.PlaylistKindStandard,
+	}
+	if err := db.DB.AddPlaylist(ctx, playlist); err != nil {
+		return ImportResult{}, err
+	}
+	return ImportResult{Playlist: playlist, Unmatched: unmatched}, nil
+}
+
+// Export describes the tracks of a playlist as a file.
+// The locations are the tracks' stream URLs on the server at baseURL, and tracks that no longer exist are left out.
+func Export(ctx context.Context, playlist media.Playlist, baseURL string) (File, error) {
+	file := File{Title: playlist.Title, Entries: []Entry{}}
+	artistNames := map[string]string{}
+	albumTitles := map[string]string{}
+
+	for _, trackID := range playlist.TrackIDs {
+		track, err := db.DB.Track(ctx, trackID)
+		if errors.Is(err, db.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return file, err
+		}
+
+		entry := Entry{
+			Location: StreamURL(baseURL, track.ID),
+			Title:    track.Title,
+			Duration: track.Duration,
+			ISRC:     track.ISRC,
+		}
+		entry.MBID, _ = track.AdditionalMeta[scrobble.RecordingMBIDMetaKey].(string)
+
+		var names []string
+		for _, artistID := range track.ArtistIDs {
+			name, err := cachedName(artistNames, artistID, func() (string, error) {
+				artist, err := db.DB.Artist(ctx, artistID)
+				return artist.Name, err
+			})
+			if err != nil {
+				return file, err
+			}
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+		entry.Artist = strings.Join(names, ", ")
+
+		if track.PrimaryAlbumID != "" {
+			entry.Album, err = cachedName(albumTitles, track.PrimaryAlbumID, func() (string, error) {
+				album, err := db.DB.Album(ctx, track.PrimaryAlbumID)
+				return album.Title, err
+			})
+			if err != nil {
+				return file, err
+			}
+		}
+
+		file.Entries = append(file.Entries, entry)
+	}
+	return file, nil
+}
+
+// StreamURL returns the URL a track is streamed from on the server at baseURL.
+func StreamURL(baseURL, trackID string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/api/v1/track/" + trackID + "/stream"
+}
+
+// cachedName returns the name with the given ID from names, getting and caching it with get if it isn't there.
+// Names of things that no longer exist are empty.
+func cachedName(names map[string]string, id string, get func() (string, error)) (string, error) {
+	if name, ok := names[id]; ok {
+		return name, nil
+	}
+	name, err := get()
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return "", err
+	}
+	names[id] = name
+	return name, nil
+}
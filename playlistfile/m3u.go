@@ -0,0 +1,79 @@
+package playlistfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// parseM3U reads extended M3U, as well as plain lists of locations.
+func parseM3U(data []byte) (File, error) {
+	var file File
+	var pending Entry
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#PLAYLIST:"):
+			file.Title = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+		case strings.HasPrefix(line, "#EXTINF:"):
+			pending = parseExtInf(strings.TrimPrefix(line, "#EXTINF:"))
+		case strings.HasPrefix(line, "#EXTALB:"):
+			pending.Album = strings.TrimSpace(strings.TrimPrefix(line, "#EXTALB:"))
+		case strings.HasPrefix(line, "#EXTART:"):
+			pending.Artist = strings.TrimSpace(strings.TrimPrefix(line, "#EXTART:"))
+		case strings.HasPrefix(line, "#"):
+			// Other directives and comments don't describe the tracks.
+		default:
+			pending.Location = line
+			file.Entries = append(file.Entries, pending)
+			pending = Entry{}
+		}
+	}
+	return file, scanner.Err()
+}
+
+// parseExtInf reads the value of an #EXTINF directive, which looks like
+// 300 tvg-name="x",Artist - Title with optional attributes after the duration.
+func parseExtInf(value string) Entry {
+	var entry Entry
+
+	info, name, _ := strings.Cut(value, ",")
+	durationText, _, _ := strings.Cut(strings.TrimSpace(info), " ")
+	if duration, err := strconv.ParseFloat(durationText, 64); err == nil && duration > 0 {
+		entry.Duration = int(duration + 0.5)
+	}
+	entry.Artist, entry.Title = splitArtistTitle(name)
+	return entry
+}
+
+func writeM3U(w io.Writer, file File) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "#EXTM3U")
+	if file.Title != "" {
+		fmt.Fprintln(out, "#PLAYLIST:"+singleLine(file.Title))
+	}
+	for _, entry := range file.Entries {
+		duration := entry.Duration
+		if duration == 0 {
+			duration = -1
+		}
+		fmt.Fprintf(out, "#EXTINF:%d,%s\n", duration, singleLine(displayName(entry)))
+		if entry.Album != "" {
+			fmt.Fprintln(out, "#EXTALB:"+singleLine(entry.Album))
+		}
+		fmt.Fprintln(out, singleLine(entry.Location))
+	}
+	return out.Flush()
+}
+
+// singleLine replaces line breaks, which would end a value in line-based formats.
+func singleLine(s string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(s)
+}
@@ -0,0 +1,235 @@
+package playlistfile
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/scrobble"
+)
+
+// Tracks whose durations differ by more than this many seconds aren't matched by name.
+const durationTolerance = 5
+
+var (
+	// Stream URLs of tracks, such as the ones in exported playlists.
+	streamURLPattern = regexp.MustCompile(`/track/([A-Za-z0-9_-]+)/stream(?:$|[?#])`)
+	// Parts of titles that differ between releases of a track, such as (Remastered 2011) or [Live].
+	bracketPattern = regexp.MustCompile(`\s*[(\[][^)\]]*[)\]]`)
+	// Featured artists, which are often left out of titles or listed as artists instead.
+	featuringPattern = regexp.MustCompile(`\s+(?:feat\.?|ft\.?|featuring)\s.*$`)
+)
+
+// Matcher matches the entries of playlist files against the tracks in the library.
+// Entries are matched by location first, then by ISRC, then by MusicBrainz recording ID,
+// and finally by title, artist and duration.
+type Matcher struct {
+	byID       map[string]bool
+	byPath     map[string]string
+	byFileName map[string][]string
+	byISRC     map[string]string
+	byMBID     map[string]string
+	byTitle    map[string][]matchCandidate
+}
+
+type matchCandidate struct {
+	track media.Track
+	// The normalized names of the track's artists.
+	artists []string
+}
+
+// Unmatched is an entry of a playlist file that matches no track in the library.
+type Unmatched struct {
+	// The position of the entry in the file, starting at 0.
+	Position int   `json:"position"`
+	Entry    Entry `json:"entry"`
+}
+
+// NewMatcher returns a matcher for the tracks that are currently in the library.
+func NewMatcher(ctx context.Context) (*Matcher, error) {
+	tracks, err := db.DB.AllTracks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	artists, err := db.DB.AllArtists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	artistNames := make(map[string]string, len(artists))
+	for _, artist := range artists {
+		artistNames[artist.ID] = normalizeName(artist.Name)
+	}
+
+	m := &Matcher{
+		byID:       map[string]bool{},
+		byPath:     map[string]string{},
+		byFileName: map[string][]string{},
+		byISRC:     map[string]string{},
+		byMBID:     map[string]string{},
+		byTitle:    map[string][]matchCandidate{},
+	}
+	for _, track := range tracks {
+		m.byID[track.ID] = true
+		if p := localPath(track.ContentSource); p != "" {
+			m.byPath[p] = track.ID
+			fileName := strings.ToLower(path.Base(p))
+			m.byFileName[fileName] = append(m.byFileName[fileName], track.ID)
+		}
+		if track.ISRC != "" {
+			m.byISRC[strings.ToUpper(track.ISRC)] = track.ID
+		}
+		if mbid, ok := track.AdditionalMeta[scrobble.RecordingMBIDMetaKey].(string); ok && mbid != "" {
+			m.byMBID[strings.ToLower(mbid)] = track.ID
+		}
+
+		candidate := matchCandidate{track: track}
+		for _, artistID := range track.ArtistIDs {
+			if name := artistNames[artistID]; name != "" {
+				candidate.artists = append(candidate.artists, name)
+			}
+		}
+		title := normalizeName(track.Title)
+		m.byTitle[title] = append(m.byTitle[title], candidate)
+	}
+	return m, nil
+}
+
+// Match returns the ID of the library track of every entry that has one, in order,
+// along with the entries that have none.
+func (m *Matcher) Match(entries []Entry) ([]string, []Unmatched) {
+	trackIDs := []string{}
+	unmatched := []Unmatched{}
+	for i, entry := range entries {
+		if trackID, ok := m.match(entry); ok {
+			trackIDs = append(trackIDs, trackID)
+		} else {
+			unmatched = append(unmatched, Unmatched{Position: i, Entry: entry})
+		}
+	}
+	return trackIDs, unmatched
+}
+
+func (m *Matcher) match(entry Entry) (string, bool) {
+	if trackID, ok := m.matchLocation(entry.Location); ok {
+		return trackID, true
+	}
+	if trackID, ok := m.byISRC[strings.ToUpper(entry.ISRC)]; ok && entry.ISRC != "" {
+		return trackID, true
+	}
+	if trackID, ok := m.byMBID[strings.ToLower(entry.MBID)]; ok && entry.MBID != "" {
+		return trackID, true
+	}
+	return m.matchName(entry)
+}
+
+// matchLocation matches stream URLs of library tracks and paths of local files.
+// Files are matched by name if no track has the same path, as long as the name is unique.
+func (m *Matcher) matchLocation(location string) (string, bool) {
+	if location == "" {
+		return "", false
+	}
+	if match := streamURLPattern.FindStringSubmatch(location); match != nil && m.byID[match[1]] {
+		return match[1], true
+	}
+
+	p := localPath(location)
+	if p == "" {
+		return "", false
+	}
+	if trackID, ok := m.byPath[p]; ok {
+		return trackID, true
+	}
+	if trackIDs := m.byFileName[strings.ToLower(path.Base(p))]; len(trackIDs) == 1 {
+		return trackIDs[0], true
+	}
+	return "", false
+}
+
+// matchName matches entries by title, artist and duration, ignoring differences in case, punctuation,
+// bracketed suffixes and featured artists. The track with the closest duration wins.
+func (m *Matcher) matchName(entry Entry) (string, bool) {
+	if entry.Title == "" {
+		return "", false
+	}
+	artist := normalizeName(entry.Artist)
+
+	var best matchCandidate
+	bestDifference := -1
+	for _, candidate := range m.byTitle[normalizeName(entry.Title)] {
+		if artist != "" && !artistMatches(artist, candidate.artists) {
+			continue
+		}
+		difference := 0
+		if entry.Duration > 0 && candidate.track.Duration > 0 {
+			difference = max(entry.Duration-candidate.track.Duration, candidate.track.Duration-entry.Duration)
+		}
+		if difference > durationTolerance {
+			continue
+		}
+		if bestDifference == -1 || difference < bestDifference {
+			best = candidate
+			bestDifference = difference
+		}
+	}
+	return best.track.ID, bestDifference != -1
+}
+
+// artistMatches reports whether the artist of an entry names one of a track's artists.
+// Entries often name several artists at once, like "Artist A & Artist B".
+func artistMatches(artist string, trackArtists []string) bool {
+	for _, trackArtist := range trackArtists {
+		if strings.Contains(artist, trackArtist) || strings.Contains(trackArtist, artist) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeName lowercases a title or artist name and removes what commonly differs between sources.
+func normalizeName(name string) string {
+	name = strings.ToLower(name)
+	name = bracketPattern.ReplaceAllString(name, "")
+	name = featuringPattern.ReplaceAllString(name, "")
+
+	var normalized strings.Builder
+	space := false
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && normalized.Len() > 0 {
+				normalized.WriteByte(' ')
+			}
+			normalized.WriteRune(r)
+			space = false
+		case r != '\'' && r != '’':
+			// Punctuation separates words, except for apostrophes within them.
+			space = true
+		}
+	}
+	return normalized.String()
+}
+
+// localPath returns the path of a local file from a file path, a file URL or a local file content source.
+// It returns an empty string for other locations, such as web URLs.
+func localPath(location string) string {
+	if strings.HasPrefix(location, "file://") {
+		u, err := url.Parse(location)
+		if err != nil {
+			return ""
+		}
+		location = u.Path
+	} else if strings.Contains(location, "://") {
+		return ""
+	}
+	location = strings.TrimPrefix(location, "file:")
+	location = strings.ReplaceAll(location, `\`, "/")
+	if location == "" {
+		return ""
+	}
+	return path.Clean(location)
+}
@@ -0,0 +1,128 @@
+// Package playlistfile reads and writes playlists in the file formats used by other players and services,
+// and matches their entries against the library.
+package playlistfile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+)
+
+var ErrUnknownFormat = errors.New("unknown playlist format")
+
+// Byte order marks are common in files written on Windows.
+const byteOrderMark = "\ufeff"
+
+// File is the content of a playlist file.
+type File struct {
+	// The title of the playlist, if the file has one.
+	Title   string
+	Entries []Entry
+}
+
+// Entry describes a track in a playlist file. Files differ in what they describe, so any field may be empty.
+type Entry struct {
+	// Where the track can be found, such as a file path or a URL.
+	Location string `json:"location"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	Album    string `json:"album"`
+	ISRC     string `json:"isrc"`
+
+	// Duration in seconds, or 0 if unknown.
+	Duration int `json:"duration"`
+	// The MusicBrainz recording ID.
+	MBID string `json:"mbid"`
+}
+
+// Format is a playlist file format.
+type Format struct {
+	Name        string
+	ContentType string
+	// The file name extension, without the dot.
+	Extension string
+
+	parse func(data []byte) (File, error)
+	write func(w io.Writer, file File) error
+}
+
+// Parse reads a playlist file in the format.
+func (f Format) Parse(data []byte) (File, error) {
+	return f.parse(bytes.TrimPrefix(data, []byte(byteOrderMark)))
+}
+
+// Write writes a playlist file in the format.
+func (f Format) Write(w io.Writer, file File) error {
+	return f.write(w, file)
+}
+
+var (
+	M3U8 = Format{
+		Name: "m3u8", ContentType: "audio/x-mpegurl; charset=utf-8", Extension: "m3u8",
+		parse: parseM3U, write: writeM3U,
+	}
+	XSPF = Format{
+		Name: "xspf", ContentType: "application/xspf+xml; charset=utf-8", Extension: "xspf",
+		parse: parseXSPF, write: writeXSPF,
+	}
+	PLS = Format{
+		Name: "pls", ContentType: "audio/x-scpls; charset=utf-8", Extension: "pls",
+		parse: parsePLS, write: writePLS,
+	}
+	JSPF = Format{
+		Name: "jspf", ContentType: "application/jspf+json", Extension: "jspf",
+		parse: parseJSPF, write: writeJSPF,
+	}
+)
+
+// Formats contains every supported format.
+var Formats = []Format{M3U8, XSPF, PLS, JSPF}
+
+// FormatByName returns the format with the given name or file name extension, ignoring case.
+// m3u is accepted as a name of M3U8.
+func FormatByName(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimPrefix(name, "."))
+	if name == "m3u" {
+		return M3U8, nil
+	}
+	for _, format := range Formats {
+		if format.Name == name {
+			return format, nil
+		}
+	}
+	return Format{}, ErrUnknownFormat
+}
+
+// Detect guesses the format of a playlist file from its content.
+// Files that don't look like any other format are read as M3U, which can be a plain list of paths.
+func Detect(data []byte) Format {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte(byteOrderMark)))
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return XSPF
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return JSPF
+	case bytes.HasPrefix(bytes.ToLower(trimmed), []byte("[playlist]")):
+		return PLS
+	}
+	return M3U8
+}
+
+// splitArtistTitle splits names like "Artist - Title", which is how most formats combine them.
+// If there's no separator, the whole name is the title.
+func splitArtistTitle(name string) (string, string) {
+	artist, title, ok := strings.Cut(name, " - ")
+	if !ok {
+		return "", strings.TrimSpace(name)
+	}
+	return strings.TrimSpace(artist), strings.TrimSpace(title)
+}
+
+// displayName combines the artist and title of an entry the way splitArtistTitle expects.
+func displayName(entry Entry) string {
+	if entry.Artist == "" {
+		return entry.Title
+	}
+	return entry.Artist + " - " + entry.Title
+}
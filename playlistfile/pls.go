@@ -0,0 +1,80 @@
+package playlistfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func parsePLS(data []byte) (File, error) {
+	entries := map[int]*Entry{}
+	entry := func(key, prefix string) (*Entry, bool) {
+		index, err := strconv.Atoi(strings.TrimPrefix(key, prefix))
+		if err != nil || !strings.HasPrefix(key, prefix) {
+			return nil, false
+		}
+		if entries[index] == nil {
+			entries[index] = &Entry{}
+		}
+		return entries[index], true
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if e, ok := entry(key, "file"); ok {
+			e.Location = value
+		} else if e, ok := entry(key, "title"); ok {
+			e.Artist, e.Title = splitArtistTitle(value)
+		} else if e, ok := entry(key, "length"); ok {
+			if duration, err := strconv.Atoi(value); err == nil && duration > 0 {
+				e.Duration = duration
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return File{}, err
+	}
+
+	var file File
+	indexes := make([]int, 0, len(entries))
+	for index := range entries {
+		indexes = append(indexes, index)
+	}
+	slices.Sort(indexes)
+	for _, index := range indexes {
+		// Titles and lengths without a file don't describe a track.
+		if entries[index].Location != "" {
+			file.Entries = append(file.Entries, *entries[index])
+		}
+	}
+	return file, nil
+}
+
+func writePLS(w io.Writer, file File) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "[playlist]")
+	for i, entry := range file.Entries {
+		duration := entry.Duration
+		if duration == 0 {
+			duration = -1
+		}
+		fmt.Fprintf(out, "File%d=%s\n", i+1, singleLine(entry.Location))
+		fmt.Fprintf(out, "Title%d=%s\n", i+1, singleLine(displayName(entry)))
+		fmt.Fprintf(out, "Length%d=%d\n", i+1, duration)
+	}
+	fmt.Fprintf(out, "NumberOfEntries=%d\n", len(file.Entries))
+	fmt.Fprintln(out, "Version=2")
+	return out.Flush()
+}
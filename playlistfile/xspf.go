@@ -0,0 +1,125 @@
+package playlistfile
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+const xspfNamespace = "http://xspf.org/ns/0/"
+
+// Identifiers of MusicBrainz recordings and ISRCs in XSPF and JSPF files.
+const (
+	musicBrainzRecordingPath = "musicbrainz.org/recording/"
+	isrcPrefix               = "isrc:"
+)
+
+type xspfPlaylist struct {
+	XMLName xml.Name `xml:"playlist"`
+
+	// Only set when writing, since files without the namespace are read too.
+	Namespace string      `xml:"xmlns,attr,omitempty"`
+	Version   string      `xml:"version,attr"`
+	Title     string      `xml:"title,omitempty"`
+	Tracks    []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Locations   []string `xml:"location"`
+	Identifiers []string `xml:"identifier"`
+	Title       string   `xml:"title,omitempty"`
+	Creator     string   `xml:"creator,omitempty"`
+	Album       string   `xml:"album,omitempty"`
+
+	// Duration in milliseconds.
+	Duration int `xml:"duration,omitempty"`
+}
+
+func parseXSPF(data []byte) (File, error) {
+	var playlist xspfPlaylist
+	if err := xml.Unmarshal(data, &playlist); err != nil {
+		return File{}, err
+	}
+
+	file := File{Title: strings.TrimSpace(playlist.Title)}
+	for _, track := range playlist.Tracks {
+		file.Entries = append(file.Entries, xspfEntry(track.Locations, track.Identifiers, xspfTrackInfo{
+			title: track.Title, creator: track.Creator, album: track.Album, duration: track.Duration,
+		}))
+	}
+	return file, nil
+}
+
+func writeXSPF(w io.Writer, file File) error {
+	playlist := xspfPlaylist{Namespace: xspfNamespace, Version: "1", Title: file.Title, Tracks: []xspfTrack{}}
+	for _, entry := range file.Entries {
+		playlist.Tracks = append(playlist.Tracks, xspfTrack{
+			Locations:   nonEmpty(entry.Location),
+			Identifiers: xspfIdentifiers(entry),
+			Title:       entry.Title,
+			Creator:     entry.Artist,
+			Album:       entry.Album,
+			Duration:    entry.Duration * 1000,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(playlist); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// xspfTrackInfo holds the fields XSPF and JSPF tracks have in common besides locations and identifiers.
+type xspfTrackInfo struct {
+	title, creator, album string
+	duration              int
+}
+
+// xspfEntry converts an XSPF or JSPF track. Only the first location is kept.
+func xspfEntry(locations, identifiers []string, info xspfTrackInfo) Entry {
+	entry := Entry{
+		Title:    strings.TrimSpace(info.title),
+		Artist:   strings.TrimSpace(info.creator),
+		Album:    strings.TrimSpace(info.album),
+		Duration: (info.duration + 500) / 1000,
+	}
+	if len(locations) > 0 {
+		entry.Location = strings.TrimSpace(locations[0])
+	}
+	for _, identifier := range identifiers {
+		identifier = strings.TrimSpace(identifier)
+		lower := strings.ToLower(identifier)
+		withoutScheme := strings.TrimPrefix(strings.TrimPrefix(lower, "https://"), "http://")
+		switch {
+		case strings.HasPrefix(withoutScheme, musicBrainzRecordingPath):
+			entry.MBID = strings.TrimSuffix(withoutScheme[len(musicBrainzRecordingPath):], "/")
+		case strings.HasPrefix(lower, isrcPrefix):
+			entry.ISRC = identifier[len(isrcPrefix):]
+		}
+	}
+	return entry
+}
+
+func xspfIdentifiers(entry Entry) []string {
+	var identifiers []string
+	if entry.MBID != "" {
+		identifiers = append(identifiers, "https://"+musicBrainzRecordingPath+entry.MBID)
+	}
+	if entry.ISRC != "" {
+		identifiers = append(identifiers, isrcPrefix+entry.ISRC)
+	}
+	return identifiers
+}
+
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
@@ -0,0 +1,156 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	// A number followed by a unit, such as 30d.
+	tokenDuration
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value string
+	// The byte offset of the token in the rules.
+	pos int
+}
+
+// keyword reports whether the token is the given keyword. Keywords are case-insensitive.
+func (t token) keyword(keyword string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of rules"
+	case tokenString:
+		return t.text
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+var operators = []string{"==", "!=", "<>", "<=", ">=", "!~", "=", "<", ">", "~"}
+
+// tokenize splits rules into tokens, ending with a tokenEOF token.
+func tokenize(rules string) ([]token, error) {
+	var tokens []token
+	for pos := 0; pos < len(rules); {
+		c := rules[pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLeftParen, text: "(", pos: pos})
+			pos++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRightParen, text: ")", pos: pos})
+			pos++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: pos})
+			pos++
+		case c == '"':
+			tok, err := lexString(rules, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			pos += len(tok.text)
+		case c == '-' || isDigit(c):
+			tok, err := lexNumber(rules, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			pos += len(tok.text)
+		case c == '_' || unicode.IsLetter(rune(c)):
+			end := pos
+			for end < len(rules) && (rules[end] == '_' || isDigit(rules[end]) || unicode.IsLetter(rune(rules[end]))) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: rules[pos:end], pos: pos})
+			pos = end
+		default:
+			tok, ok := lexOperator(rules, pos)
+			if !ok {
+				return nil, &ParseError{Pos: pos, Msg: fmt.Sprintf("unexpected character %q", c)}
+			}
+			tokens = append(tokens, tok)
+			pos += len(tok.text)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(rules)}), nil
+}
+
+func lexString(rules string, start int) (token, error) {
+	var value strings.Builder
+	for pos := start + 1; pos < len(rules); pos++ {
+		switch rules[pos] {
+		case '"':
+			return token{kind: tokenString, text: rules[start : pos+1], value: value.String(), pos: start}, nil
+		case '\\':
+			pos++
+			if pos == len(rules) {
+				break
+			}
+			if rules[pos] != '"' && rules[pos] != '\\' {
+				return token{}, &ParseError{Pos: pos - 1, Msg: fmt.Sprintf("unknown escape sequence \\%c", rules[pos])}
+			}
+			value.WriteByte(rules[pos])
+		default:
+			value.WriteByte(rules[pos])
+		}
+	}
+	return token{}, &ParseError{Pos: start, Msg: "unterminated string"}
+}
+
+func lexNumber(rules string, start int) (token, error) {
+	pos := start
+	if rules[pos] == '-' {
+		pos++
+	}
+	digits := pos
+	for pos < len(rules) && isDigit(rules[pos]) {
+		pos++
+	}
+	if pos == digits {
+		return token{}, &ParseError{Pos: start, Msg: "expected a number after -"}
+	}
+
+	number := rules[start:pos]
+	unitStart := pos
+	for pos < len(rules) && unicode.IsLetter(rune(rules[pos])) {
+		pos++
+	}
+	if pos == unitStart {
+		return token{kind: tokenNumber, text: number, value: number, pos: start}, nil
+	}
+	return token{kind: tokenDuration, text: rules[start:pos], value: number, pos: start}, nil
+}
+
+func lexOperator(rules string, start int) (token, bool) {
+	for _, operator := range operators {
+		if strings.HasPrefix(rules[start:], operator) {
+			return token{kind: tokenOperator, text: operator, pos: start}, true
+		}
+	}
+	return token{}, false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
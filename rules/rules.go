@@ -0,0 +1,348 @@
+package rules
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query selects tracks by their fields, for example:
+//
+//	tag = "jazz" AND release_date >= 1990 AND listen_count > 5 ORDER BY last_played DESC LIMIT 100
+//
+// Conditions compare a field with a value and can be combined with AND, OR, NOT and parentheses.
+// Timestamps can be compared with relative times such as 30d ago.
+type Query struct {
+	// Where selects the tracks. A nil Where selects every track.
+	Where Expr
+	// The order of the tracks. Tracks are ordered by when they were added if it's empty.
+	OrderBy []Order
+	// The maximum number of tracks, or 0 if there is none.
+	Limit int
+}
+
+// Order sorts tracks by a field, or randomly if the field is "random".
+type Order struct {
+	Field      string
+	Descending bool
+}
+
+// Expr is a condition on tracks.
+type Expr interface {
+	sql(t *translator) string
+}
+
+type andExpr struct {
+	left, right Expr
+}
+
+type orExpr struct {
+	left, right Expr
+}
+
+type notExpr struct {
+	expr Expr
+}
+
+type comparison struct {
+	field    field
+	operator string
+	value    value
+}
+
+type valueKind int
+
+const (
+	stringValue valueKind = iota
+	numberValue
+	boolValue
+	// A time relative to when the query is evaluated, stored as the number of seconds ago.
+	relativeValue
+)
+
+type value struct {
+	kind   valueKind
+	text   string
+	number int64
+	bool   bool
+}
+
+// ParseError describes why rules couldn't be parsed.
+type ParseError struct {
+	// The byte offset in the rules where the error was found.
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid rules at position %d: %s", e.Pos, e.Msg)
+}
+
+// Units of relative times.
+var durationUnits = map[string]time.Duration{
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"mo": 30 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// Parse parses and validates rules.
+func Parse(rules string) (Query, error) {
+	tokens, err := tokenize(rules)
+	if err != nil {
+		return Query{}, err
+	}
+	p := &parser{tokens: tokens}
+
+	var query Query
+	if !p.peek().keyword("order") && !p.peek().keyword("limit") {
+		if p.peek().kind == tokenEOF {
+			return Query{}, &ParseError{Pos: 0, Msg: "rules are empty"}
+		}
+		if query.Where, err = p.parseOr(); err != nil {
+			return Query{}, err
+		}
+	}
+	if p.peek().keyword("order") {
+		if query.OrderBy, err = p.parseOrderBy(); err != nil {
+			return Query{}, err
+		}
+	}
+	if p.peek().keyword("limit") {
+		if query.Limit, err = p.parseLimit(); err != nil {
+			return Query{}, err
+		}
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return Query{}, p.unexpected(tok, "AND, OR, ORDER BY or LIMIT")
+	}
+	return query, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) unexpected(tok token, expected string) error {
+	return &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("expected %s but found %s", expected, tok)}
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if !p.peek().keyword("not") {
+		return p.parsePrimary()
+	}
+	p.next()
+	expr, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	return notExpr{expr: expr}, nil
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	if p.peek().kind == tokenLeftParen {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.kind != tokenRightParen {
+			return nil, p.unexpected(tok, ")")
+		}
+		return expr, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Expr, error) {
+	tok := p.next()
+	if tok.kind != tokenIdent {
+		return nil, p.unexpected(tok, "a field")
+	}
+	f, ok := fields[strings.ToLower(tok.text)]
+	if !ok {
+		return nil, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("unknown field %s", tok)}
+	}
+
+	operatorToken := p.next()
+	if operatorToken.kind != tokenOperator {
+		return nil, p.unexpected(operatorToken, "an operator")
+	}
+	operator := normalizeOperator(operatorToken.text)
+	if !slices.Contains(f.kind.operators(), operator) {
+		return nil, &ParseError{
+			Pos: operatorToken.pos,
+			Msg: fmt.Sprintf("%s can't be compared with %s", tok.text, operatorToken.text),
+		}
+	}
+
+	v, err := p.parseValue(f, tok.text)
+	if err != nil {
+		return nil, err
+	}
+	return comparison{field: f, operator: operator, value: v}, nil
+}
+
+func (p *parser) parseValue(f field, name string) (value, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokenString && (f.kind == stringField || f.kind == listField):
+		return value{kind: stringValue, text: tok.value}, nil
+	case tok.kind == tokenString && f.kind == dateField:
+		if !isPartialDate(tok.value) {
+			return value{}, &ParseError{Pos: tok.pos, Msg: "dates must look like 2006, 2006-01 or 2006-01-02"}
+		}
+		return value{kind: stringValue, text: tok.value}, nil
+	case tok.kind == tokenNumber && f.kind == dateField:
+		// A number is a year.
+		year, err := strconv.Atoi(tok.value)
+		if err != nil || year < 0 || year > 9999 {
+			return value{}, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("%s isn't a year", tok)}
+		}
+		return value{kind: stringValue, text: fmt.Sprintf("%04d", year)}, nil
+	case tok.kind == tokenNumber && (f.kind == numberField || f.kind == timestampField):
+		number, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return value{}, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("%s is out of range", tok)}
+		}
+		return value{kind: numberValue, number: number}, nil
+	case tok.kind == tokenDuration && (f.kind == timestampField || f.kind == dateField):
+		return p.parseRelativeTime(tok)
+	case (tok.keyword("true") || tok.keyword("false")) && f.kind == boolField:
+		return value{kind: boolValue, bool: tok.keyword("true")}, nil
+	}
+	return value{}, p.unexpected(tok, f.kind.valueDescription()+" to compare "+name+" with")
+}
+
+func (p *parser) parseRelativeTime(tok token) (value, error) {
+	amount, err := strconv.ParseInt(tok.value, 10, 64)
+	unit, ok := durationUnits[strings.ToLower(tok.text[len(tok.value):])]
+	if err != nil || !ok || amount < 0 {
+		return value{}, &ParseError{
+			Pos: tok.pos,
+			Msg: fmt.Sprintf("%s isn't a duration like 12h, 30d, 2w, 6mo or 1y", tok),
+		}
+	}
+	if ago := p.next(); !ago.keyword("ago") {
+		return value{}, p.unexpected(ago, "AGO")
+	}
+	seconds := amount * int64(unit/time.Second)
+	if seconds/int64(unit/time.Second) != amount {
+		return value{}, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("%s is out of range", tok)}
+	}
+	return value{kind: relativeValue, number: seconds}, nil
+}
+
+func (p *parser) parseOrderBy() ([]Order, error) {
+	p.next()
+	if tok := p.next(); !tok.keyword("by") {
+		return nil, p.unexpected(tok, "BY")
+	}
+
+	var orders []Order
+	for {
+		tok := p.next()
+		if tok.kind != tokenIdent {
+			return nil, p.unexpected(tok, "a field to order by")
+		}
+		name := strings.ToLower(tok.text)
+		if f, ok := fields[name]; name != RandomOrder && (!ok || f.kind == listField) {
+			return nil, &ParseError{Pos: tok.pos, Msg: fmt.Sprintf("can't order by %s", tok)}
+		}
+
+		order := Order{Field: name}
+		if p.peek().keyword("asc") {
+			p.next()
+		} else if p.peek().keyword("desc") {
+			p.next()
+			order.Descending = true
+		}
+		orders = append(orders, order)
+
+		if p.peek().kind != tokenComma {
+			return orders, nil
+		}
+		p.next()
+	}
+}
+
+func (p *parser) parseLimit() (int, error) {
+	p.next()
+	tok := p.next()
+	if tok.kind != tokenNumber {
+		return 0, p.unexpected(tok, "the maximum number of tracks")
+	}
+	limit, err := strconv.Atoi(tok.value)
+	if err != nil || limit < 1 {
+		return 0, &ParseError{Pos: tok.pos, Msg: "the limit must be a positive number"}
+	}
+	return limit, nil
+}
+
+func normalizeOperator(operator string) string {
+	switch operator {
+	case "==":
+		return "="
+	case "<>":
+		return "!="
+	}
+	return operator
+}
+
+// isPartialDate reports whether s is a year, a month or a day, such as 2006, 2006-01 or 2006-01-02.
+func isPartialDate(s string) bool {
+	for _, layout := range []string{"2006", "2006-01", time.DateOnly} {
+		if _, err := time.Parse(layout, s); err == nil && len(s) == len(layout) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,273 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// numberedDialect numbers its placeholders like PostgreSQL, so tests can see which argument goes where.
+type numberedDialect struct{}
+
+func (numberedDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (numberedDialect) ListContains(column, value string) string {
+	return "contains(" + column + ", " + value + ")"
+}
+
+func (numberedDialect) ContainsFold(expr, value string) string {
+	return "fold(" + expr + ", " + value + ")"
+}
+
+func (numberedDialect) Random() string {
+	return "random()"
+}
+
+// positionalDialect uses the same placeholder for every argument, like SQLite and MySQL.
+type positionalDialect struct{ numberedDialect }
+
+func (positionalDialect) Placeholder(int) string {
+	return "?"
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		rules string
+		pos   int
+	}{
+		{"", 0},
+		{"   ", 0},
+		{`title = "jazz`, 8},
+		{`title = "a\nb"`, 10},
+		{"title = -", 8},
+		{"title # 1", 6},
+		{"genre = 1", 0},
+		{`title < "a"`, 6},
+		{`tag ~ "jazz"`, 4},
+		{"favorited > true", 10},
+		{"duration = \"long\"", 11},
+		{"title = 5", 8},
+		{"favorited = 1", 12},
+		{`release_date = "1990-13"`, 15},
+		{`release_date = "90"`, 15},
+		{"release_date = 10000", 15},
+		{"listen_count = 99999999999999999999", 15},
+		{"addition_date > 30x ago", 16},
+		{"addition_date > 30d", 19},
+		{"addition_date > -1d ago", 16},
+		{"last_played > 9999999999999999y ago", 14},
+		{`(title = "a"`, 12},
+		{`title = "a" title = "b"`, 12},
+		{`title = "a" AND`, 15},
+		{"ORDER title", 6},
+		{"ORDER BY tag", 9},
+		{"ORDER BY genre", 9},
+		{"ORDER BY title,", 15},
+		{"LIMIT 0", 6},
+		{"LIMIT -5", 6},
+		{"LIMIT ten", 6},
+		{"LIMIT 5 ORDER BY title", 8},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.rules)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%q: got %v, want a ParseError", test.rules, err)
+			continue
+		}
+		if parseErr.Pos != test.pos {
+			t.Errorf("%q: error %q at %d, want at %d", test.rules, parseErr.Msg, parseErr.Pos, test.pos)
+		}
+	}
+}
+
+func TestParseOperatorsAndKeywords(t *testing.T) {
+	tests := []struct {
+		rules string
+		want  Expr
+	}{
+		{`title == "a"`, comparison{fields["title"], "=", value{kind: stringValue, text: "a"}}},
+		{`title <> "a"`, comparison{fields["title"], "!=", value{kind: stringValue, text: "a"}}},
+		{`TITLE !~ "a\"b\\"`, comparison{fields["title"], "!~", value{kind: stringValue, text: `a"b\`}}},
+		{"listen_count >= -3", comparison{fields["listen_count"], ">=", value{kind: numberValue, number: -3}}},
+		{"favorited = TRUE", comparison{fields["favorited"], "=", value{kind: boolValue, bool: true}}},
+		{"release_date < 990", comparison{fields["release_date"], "<", value{kind: stringValue, text: "0990"}}},
+		{"last_played < 2W Ago", comparison{fields["last_played"], "<", value{kind: relativeValue, number: 1209600}}},
+		{
+			`not tag = "a" or tag = "b" and tag = "c"`,
+			orExpr{
+				notExpr{comparison{fields["tag"], "=", value{kind: stringValue, text: "a"}}},
+				andExpr{
+					comparison{fields["tag"], "=", value{kind: stringValue, text: "b"}},
+					comparison{fields["tag"], "=", value{kind: stringValue, text: "c"}},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		query, err := Parse(test.rules)
+		if err != nil {
+			t.Errorf("%q: %v", test.rules, err)
+			continue
+		}
+		// Fields hold functions, which can't be compared, so compare what they translate to instead.
+		got, _ := query.SQL(numberedDialect{}, "owner", 0, time.Unix(0, 0))
+		want, _ := Query{Where: test.want}.SQL(numberedDialect{}, "owner", 0, time.Unix(0, 0))
+		if got != want {
+			t.Errorf("%q:\ngot  %s\nwant %s", test.rules, got, want)
+		}
+	}
+}
+
+func TestParseOrderByAndLimit(t *testing.T) {
+	query, err := Parse("order by Listen_Count desc, title ASC, random limit 20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Query{
+		OrderBy: []Order{{Field: "listen_count", Descending: true}, {Field: "title"}, {Field: RandomOrder}},
+		Limit:   20,
+	}
+	if !reflect.DeepEqual(query, want) {
+		t.Errorf("got %+v, want %+v", query, want)
+	}
+}
+
+func TestNextPartialDate(t *testing.T) {
+	tests := []struct {
+		date, want string
+		ok         bool
+	}{
+		{"1990", "1991", true},
+		{"0999", "1000", true},
+		{"1990-12", "1991-01", true},
+		{"1990-01", "1990-02", true},
+		{"1990-12-31", "1991-01-01", true},
+		{"2024-02-28", "2024-02-29", true},
+		{"2023-02-28", "2023-03-01", true},
+		// There's nothing after the last year that can be written with four digits.
+		{"9999", "", false},
+		{"9999-12", "", false},
+		{"9999-12-31", "", false},
+		{"9999-12-30", "9999-12-31", true},
+	}
+	for _, test := range tests {
+		got, ok := nextPartialDate(test.date)
+		if got != test.want || ok != test.ok {
+			t.Errorf("nextPartialDate(%q) = %q, %t, want %q, %t", test.date, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestDateSQL(t *testing.T) {
+	const date = "NULLIF(tracks.release_date, '')"
+	now := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		rules string
+		where string
+		args  []any
+	}{
+		// Comparisons with a year cover all of it.
+		{"release_date = 1990", "(" + date + " >= $1 AND " + date + " < $2)", []any{"1990", "1991"}},
+		{"release_date != 1990", "(" + date + " < $1 OR " + date + " >= $2)", []any{"1990", "1991"}},
+		{"release_date <= 1990", "(" + date + " < $1)", []any{"1991"}},
+		{"release_date < 1990", "(" + date + " < $1)", []any{"1990"}},
+		{"release_date >= 1990", "(" + date + " >= $1)", []any{"1990"}},
+		{"release_date > 1990", "(" + date + " >= $1)", []any{"1991"}},
+		{`release_date <= "1990-02"`, "(" + date + " < $1)", []any{"1990-03"}},
+		{
+			`release_date = "2024-02-29"`,
+			"(" + date + " >= $1 AND " + date + " < $2)", []any{"2024-02-29", "2024-03-01"},
+		},
+		{
+			`release_date != "1990-12-31"`,
+			"(" + date + " < $1 OR " + date + " >= $2)", []any{"1990-12-31", "1991-01-01"},
+		},
+		// Relative times are days.
+		{"release_date >= 10d ago", "(" + date + " >= $1)", []any{"2024-02-29"}},
+		{"release_date <= 1d ago", "(" + date + " < $1)", []any{"2024-03-10"}},
+		// The last year has no next one to compare with, so it's open-ended.
+		{"release_date <= 9999", "(" + date + " IS NOT NULL)", nil},
+		{"release_date > 9999", "(1 = 0)", nil},
+		{"release_date = 9999", "(" + date + " >= $1)", []any{"9999"}},
+		{`release_date != "9999-12-31"`, "(" + date + " < $1)", []any{"9999-12-31"}},
+	}
+	for _, test := range tests {
+		query, err := Parse(test.rules)
+		if err != nil {
+			t.Errorf("%q: %v", test.rules, err)
+			continue
+		}
+		statement, args := query.SQL(numberedDialect{}, "owner", 0, now)
+		want := "SELECT tracks.id FROM tracks WHERE " + test.where +
+			" ORDER BY COALESCE(tracks.addition_date, 0), tracks.id"
+		if statement != want || !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%q:\ngot  %s %v\nwant %s %v", test.rules, statement, args, want, test.args)
+		}
+	}
+}
+
+func TestSQLPlaceholders(t *testing.T) {
+	query, err := Parse(`tag = "jazz" AND (title ~ "blue" OR NOT favorited = true) AND last_played < 30d ago ` +
+		"ORDER BY last_played DESC, random LIMIT 50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(100*86400, 0)
+
+	const (
+		lastPlayed = "COALESCE((SELECT MAX(listens.listened_at) FROM listens WHERE listens.user_id = %s " +
+			"AND listens.playable_type = 'track' AND listens.playable_id = tracks.id), 0)"
+		favorited = "EXISTS (SELECT 1 FROM favorites WHERE favorites.user_id = %s " +
+			"AND favorites.playable_type = 'track' AND favorites.playable_id = tracks.id)"
+	)
+	wantArgs := []any{"jazz", "blue", "owner", "owner", int64(70 * 86400), "owner"}
+
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{numberedDialect{}, "SELECT tracks.id FROM tracks WHERE ((contains(tracks.tags, $1) AND " +
+			"(fold(COALESCE(tracks.title, ''), $2) OR (NOT " + fmt.Sprintf(favorited, "$3") + "))) AND " +
+			"(" + fmt.Sprintf(lastPlayed, "$4") + " < $5)) ORDER BY " + fmt.Sprintf(lastPlayed, "$6") +
+			" DESC, random(), tracks.id LIMIT 50"},
+		{positionalDialect{}, "SELECT tracks.id FROM tracks WHERE ((contains(tracks.tags, ?) AND " +
+			"(fold(COALESCE(tracks.title, ''), ?) OR (NOT " + fmt.Sprintf(favorited, "?") + "))) AND " +
+			"(" + fmt.Sprintf(lastPlayed, "?") + " < ?)) ORDER BY " + fmt.Sprintf(lastPlayed, "?") +
+			" DESC, random(), tracks.id LIMIT 50"},
+	}
+	for _, test := range tests {
+		statement, args := query.SQL(test.dialect, "owner", 0, now)
+		if statement != test.want {
+			t.Errorf("%T:\ngot  %s\nwant %s", test.dialect, statement, test.want)
+		}
+		if !reflect.DeepEqual(args, wantArgs) {
+			t.Errorf("%T: got arguments %v, want %v", test.dialect, args, wantArgs)
+		}
+	}
+}
+
+func TestSQLLimit(t *testing.T) {
+	tests := []struct {
+		limit, maxTracks int
+		want             string
+	}{
+		{0, 0, ""},
+		{10, 0, " LIMIT 10"},
+		{0, 100, " LIMIT 100"},
+		{10, 100, " LIMIT 10"},
+		{1000, 100, " LIMIT 100"},
+	}
+	for _, test := range tests {
+		statement, _ := Query{Limit: test.limit}.SQL(positionalDialect{}, "owner", test.maxTracks, time.Now())
+		want := "SELECT tracks.id FROM tracks ORDER BY COALESCE(tracks.addition_date, 0), tracks.id" + test.want
+		if statement != want {
+			t.Errorf("limit %d with at most %d tracks: got %s", test.limit, test.maxTracks, statement)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package rules
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RandomOrder is the name to order by to shuffle the tracks.
+const RandomOrder = "random"
+
+// Dialect holds the parts of the SQL translation that differ between database engines.
+// Expressions must keep the order of their arguments, so values come after the columns they're compared with.
+type Dialect interface {
+	// Placeholder returns the placeholder of the nth argument, starting at 1.
+	Placeholder(n int) string
+	// ListContains returns a condition that holds if the list stored in column contains value.
+	// The list may be NULL, which contains nothing.
+	ListContains(column, value string) string
+	// ContainsFold returns a condition that holds if expr contains value, ignoring case.
+	ContainsFold(expr, value string) string
+	// Random returns an expression to order rows randomly by.
+	Random() string
+}
+
+type fieldKind int
+
+const (
+	stringField fieldKind = iota
+	listField
+	numberField
+	timestampField
+	// A date stored as text, which may be compared with partial dates such as years.
+	dateField
+	boolField
+)
+
+func (k fieldKind) operators() []string {
+	switch k {
+	case stringField:
+		return []string{"=", "!=", "~", "!~"}
+	case listField, boolField:
+		return []string{"=", "!="}
+	}
+	return []string{"=", "!=", "<", "<=", ">", ">="}
+}
+
+func (k fieldKind) valueDescription() string {
+	switch k {
+	case stringField, listField:
+		return "a string"
+	case numberField:
+		return "a number"
+	case timestampField:
+		return "a Unix timestamp or a relative time like 30d ago"
+	case dateField:
+		return "a year, a date string or a relative time like 30d ago"
+	}
+	return "true or false"
+}
+
+type field struct {
+	kind fieldKind
+	// Returns the SQL expression of the field in a query on the tracks table.
+	expr func(t *translator) string
+}
+
+func column(kind fieldKind, expr string) field {
+	return field{kind: kind, expr: func(*translator) string { return expr }}
+}
+
+// The fields rules can use. Listens and favorites are those of the playlist's owner.
+var fields = map[string]field{
+	"title":           column(stringField, "COALESCE(tracks.title, '')"),
+	"description":     column(stringField, "COALESCE(tracks.description, '')"),
+	"isrc":            column(stringField, "COALESCE(tracks.isrc, '')"),
+	"content_source":  column(stringField, "COALESCE(tracks.content_source, '')"),
+	"metadata_source": column(stringField, "COALESCE(tracks.metadata_source, '')"),
+	"tag":             column(listField, "tracks.tags"),
+	"artist_id":       column(listField, "tracks.artist_ids"),
+	"album_id":        column(listField, "tracks.album_ids"),
+	"duration":        column(numberField, "COALESCE(tracks.duration, 0)"),
+	"track_number":    column(numberField, "COALESCE(tracks.track_number, 0)"),
+	"listen_count":    column(numberField, "COALESCE(tracks.listen_count, 0)"),
+	"favorite_count":  column(numberField, "COALESCE(tracks.favorite_count, 0)"),
+	"addition_date":   column(timestampField, "COALESCE(tracks.addition_date, 0)"),
+	"release_date":    column(dateField, "NULLIF(tracks.release_date, '')"),
+	"last_played": {kind: timestampField, expr: func(t *translator) string {
+		return "COALESCE((SELECT MAX(listens.listened_at) FROM listens WHERE listens.user_id = " + t.owner() +
+			" AND listens.playable_type = 'track' AND listens.playable_id = tracks.id), 0)"
+	}},
+	"favorited": {kind: boolField, expr: func(t *translator) string {
+		return "EXISTS (SELECT 1 FROM favorites WHERE favorites.user_id = " + t.owner() +
+			" AND favorites.playable_type = 'track' AND favorites.playable_id = tracks.id)"
+	}},
+}
+
+type translator struct {
+	dialect Dialect
+	ownerID string
+	now     time.Time
+	args    []any
+}
+
+func (t *translator) arg(value any) string {
+	t.args = append(t.args, value)
+	return t.dialect.Placeholder(len(t.args))
+}
+
+func (t *translator) owner() string {
+	return t.arg(t.ownerID)
+}
+
+// SQL returns a statement that selects the IDs of the tracks matched by the query, and its arguments.
+// ownerID is the user whose listens and favorites are used, and relative times are relative to now.
+// At most maxTracks tracks are selected if it's positive, even if the query's limit is higher.
+func (q Query) SQL(dialect Dialect, ownerID string, maxTracks int, now time.Time) (string, []any) {
+	t := &translator{dialect: dialect, ownerID: ownerID, now: now}
+
+	var statement strings.Builder
+	statement.WriteString("SELECT tracks.id FROM tracks")
+	if q.Where != nil {
+		statement.WriteString(" WHERE ")
+		statement.WriteString(q.Where.sql(t))
+	}
+
+	statement.WriteString(" ORDER BY ")
+	if len(q.OrderBy) == 0 {
+		statement.WriteString("COALESCE(tracks.addition_date, 0), ")
+	}
+	for _, order := range q.OrderBy {
+		if order.Field == RandomOrder {
+			statement.WriteString(dialect.Random())
+		} else {
+			statement.WriteString(fields[order.Field].expr(t))
+		}
+		if order.Descending {
+			statement.WriteString(" DESC")
+		}
+		statement.WriteString(", ")
+	}
+	// Ties are broken by ID so the order doesn't change between evaluations.
+	statement.WriteString("tracks.id")
+
+	limit := q.Limit
+	if maxTracks > 0 && (limit == 0 || limit > maxTracks) {
+		limit = maxTracks
+	}
+	if limit > 0 {
+		statement.WriteString(" LIMIT " + strconv.Itoa(limit))
+	}
+
+	return statement.String(), t.args
+}
+
+func (e andExpr) sql(t *translator) string {
+	return "(" + e.left.sql(t) + " AND " + e.right.sql(t) + ")"
+}
+
+func (e orExpr) sql(t *translator) string {
+	return "(" + e.left.sql(t) + " OR " + e.right.sql(t) + ")"
+}
+
+func (e notExpr) sql(t *translator) string {
+	return "(NOT " + e.expr.sql(t) + ")"
+}
+
+func (c comparison) sql(t *translator) string {
+	expr := c.field.expr(t)
+	switch c.field.kind {
+	case listField:
+		return negateIf(c.operator == "!=", t.dialect.ListContains(expr, t.arg(c.value.text)))
+	case boolField:
+		return negateIf((c.operator == "=") != c.value.bool, expr)
+	case stringField:
+		if c.operator == "~" || c.operator == "!~" {
+			return negateIf(c.operator == "!~", t.dialect.ContainsFold(expr, t.arg(c.value.text)))
+		}
+		return "(" + expr + " " + c.operator + " " + t.arg(c.value.text) + ")"
+	case dateField:
+		return c.dateSQL(t, expr)
+	}
+
+	number := c.value.number
+	if c.value.kind == relativeValue {
+		number = t.now.Unix() - number
+	}
+	return "(" + expr + " " + c.operator + " " + t.arg(number) + ")"
+}
+
+// dateSQL compares a date with a year, month or day, which covers every date from its start up to the next one.
+// For example, release_date <= 1990 matches every date in 1990.
+func (c comparison) dateSQL(t *translator, expr string) string {
+	start := c.value.text
+	if c.value.kind == relativeValue {
+		start = t.now.Add(-time.Duration(c.value.number) * time.Second).UTC().Format(time.DateOnly)
+	}
+	end, ok := nextPartialDate(start)
+	if !ok {
+		// Nothing comes after the end of 9999, so every date from its start on is covered.
+		switch c.operator {
+		case "<=":
+			return "(" + expr + " IS NOT NULL)"
+		case ">":
+			return "(1 = 0)"
+		case "!=", "<":
+			return "(" + expr + " < " + t.arg(start) + ")"
+		}
+		return "(" + expr + " >= " + t.arg(start) + ")"
+	}
+
+	switch c.operator {
+	case "<":
+		return "(" + expr + " < " + t.arg(start) + ")"
+	case ">=":
+		return "(" + expr + " >= " + t.arg(start) + ")"
+	case "<=":
+		return "(" + expr + " < " + t.arg(end) + ")"
+	case ">":
+		return "(" + expr + " >= " + t.arg(end) + ")"
+	case "!=":
+		return "(" + expr + " < " + t.arg(start) + " OR " + expr + " >= " + t.arg(end) + ")"
+	}
+	return "(" + expr + " >= " + t.arg(start) + " AND " + expr + " < " + t.arg(end) + ")"
+}
+
+// nextPartialDate returns the year, month or day after date, which has the same precision.
+// It reports false if there is none, which is the case for the end of 9999 and dates that don't parse.
+func nextPartialDate(date string) (string, bool) {
+	for _, layout := range []string{"2006", "2006-01", time.DateOnly} {
+		parsed, err := time.Parse(layout, date)
+		if err != nil || len(date) != len(layout) {
+			continue
+		}
+		switch layout {
+		case "2006":
+			parsed = parsed.AddDate(1, 0, 0)
+		case "2006-01":
+			parsed = parsed.AddDate(0, 1, 0)
+		default:
+			parsed = parsed.AddDate(0, 0, 1)
+		}
+		if parsed.Year() > 9999 {
+			return "", false
+		}
+		return parsed.Format(layout), true
+	}
+	return "", false
+}
+
+func negateIf(negate bool, condition string) string {
+	if negate {
+		return "(NOT " + condition + ")"
+	}
+	return condition
+}
@@ -0,0 +1,174 @@
+package scrobble
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+)
+
+const (
+	// How many queued scrobbles are delivered per poll.
+	deliveryBatchSize = 100
+	// How long a single delivery may take.
+	deliveryTimeout = 30 * time.Second
+)
+
+// Enqueue queues a listen to be forwarded to every scrobbler the user has linked.
+func Enqueue(ctx context.Context, listen media.Listen) error {
+	if !config.Conf.Scrobbling.Enabled {
+		return nil
+	}
+
+	links, err := db.DB.UserScrobblerLinks(ctx, listen.UserID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, link := range links {
+		scrobbler, ok := Registry[link.Scrobbler]
+		if !ok || link.Token == "" || !scrobbler.Enabled() {
+			continue
+		}
+		err := db.DB.AddScrobble(ctx, db.Scrobble{
+			ID:            media.GenerateID(config.Conf.General.IDLength),
+			UserID:        listen.UserID,
+			ListenID:      listen.ID,
+			Scrobbler:     link.Scrobbler,
+			NextAttemptAt: now,
+			CreationDate:  now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run delivers queued scrobbles every poll interval until ctx is canceled.
+func Run(ctx context.Context) {
+	if !config.Conf.Scrobbling.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(max(config.Conf.Scrobbling.PollInterval, time.Second))
+	defer ticker.Stop()
+
+	for {
+		if err := DeliverDue(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Error("Error delivering scrobbles", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue delivers every queued scrobble that is due.
+func DeliverDue(ctx context.Context) error {
+	for {
+		scrobbles, err := db.DB.DueScrobbles(ctx, time.Now().Unix(), deliveryBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, scrobble := range scrobbles {
+			if err := deliver(ctx, scrobble); err != nil {
+				return err
+			}
+		}
+		if len(scrobbles) < deliveryBatchSize {
+			return nil
+		}
+	}
+}
+
+// Retry queues a failed scrobble to be delivered again as soon as possible.
+func Retry(ctx context.Context, scrobble db.Scrobble) error {
+	scrobble.Failed = false
+	scrobble.Attempts = 0
+	scrobble.NextAttemptAt = time.Now().Unix()
+	return db.DB.UpdateScrobble(ctx, scrobble)
+}
+
+// deliver attempts to forward a single scrobble. Delivery failures are recorded on the scrobble
+// and only errors from the database are returned.
+func deliver(ctx context.Context, scrobble db.Scrobble) error {
+	scrobbler, ok := Registry[scrobble.Scrobbler]
+	if !ok {
+		return fail(ctx, scrobble, ErrUnknownScrobbler, true)
+	}
+
+	listen, err := db.DB.Listen(ctx, scrobble.ListenID)
+	if errors.Is(err, db.ErrNotFound) {
+		return db.DB.DeleteScrobble(ctx, scrobble.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	link, err := db.DB.ScrobblerLink(ctx, scrobble.UserID, scrobble.Scrobbler)
+	if errors.Is(err, db.ErrNotFound) || (err == nil && link.Token == "") {
+		// The user unlinked the scrobbler after the listen was queued.
+		return db.DB.DeleteScrobble(ctx, scrobble.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	track, err := Describe(ctx, listen)
+	if err != nil {
+		return err
+	}
+
+	submitCtx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	err = scrobbler.Submit(submitCtx, link.Token, track, listen.ListenedAt)
+	cancel()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fail(ctx, scrobble, err, errors.Is(err, ErrRejected))
+	}
+
+	return db.DB.DeleteScrobble(ctx, scrobble.ID)
+}
+
+// fail records a failed delivery and schedules the next attempt.
+// The scrobble is marked as failed once it runs out of attempts or if permanent is set.
+func fail(ctx context.Context, scrobble db.Scrobble, deliveryErr error, permanent bool) error {
+	scrobble.Attempts++
+	scrobble.LastError = deliveryErr.Error()
+
+	if permanent || scrobble.Attempts >= config.Conf.Scrobbling.MaxAttempts {
+		scrobble.Failed = true
+		log.Warn("Giving up on scrobble",
+			"scrobbleID", scrobble.ID, "scrobbler", scrobble.Scrobbler, "attempts", scrobble.Attempts, "err", deliveryErr)
+	} else {
+		scrobble.NextAttemptAt = time.Now().Add(backoff(scrobble.Attempts)).Unix()
+		log.Debug("Scrobble delivery failed",
+			"scrobbleID", scrobble.ID, "scrobbler", scrobble.Scrobbler, "attempts", scrobble.Attempts, "err", deliveryErr)
+	}
+
+	return db.DB.UpdateScrobble(ctx, scrobble)
+}
+
+// backoff returns how long to wait after the given number of failed attempts.
+func backoff(attempts int) time.Duration {
+	delay := config.Conf.Scrobbling.InitialBackoff
+	for range attempts - 1 {
+		delay *= 2
+		if delay >= config.Conf.Scrobbling.MaxBackoff {
+			return config.Conf.Scrobbling.MaxBackoff
+		}
+	}
+	return delay
+}
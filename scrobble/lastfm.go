@@ -0,0 +1,121 @@
+package scrobble
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+
+	"github.com/libramusic/libracore/config"
+)
+
+// Last.fm error codes that mean the request may succeed if it is sent again later.
+// See https://www.last.fm/api/errorcodes.
+var lastFMRetryableErrors = []int{8, 11, 16, 29}
+
+func init() {
+	Registry["lastfm"] = LastFM{}
+}
+
+// LastFM forwards listens to a Last.fm-compatible scrobbling API.
+// The token is the user's session key.
+type LastFM struct{}
+
+func (LastFM) ID() string {
+	return "lastfm"
+}
+
+func (LastFM) Name() string {
+	return "Last.fm"
+}
+
+func (LastFM) Enabled() bool {
+	conf := config.Conf.Scrobbling.LastFM
+	return conf.URL != "" && conf.APIKey != "" && conf.APISecret != ""
+}
+
+func (LastFM) Submit(ctx context.Context, token string, track Track, listenedAt int64) error {
+	conf := config.Conf.Scrobbling.LastFM
+
+	params := url.Values{}
+	params.Set("method", "track.scrobble")
+	params.Set("api_key", conf.APIKey)
+	params.Set("sk", token)
+	params.Set("artist", track.ArtistName)
+	params.Set("track", track.TrackName)
+	params.Set("timestamp", strconv.FormatInt(listenedAt, 10))
+	if track.ReleaseName != "" {
+		params.Set("album", track.ReleaseName)
+	}
+	if track.RecordingMBID != "" {
+		params.Set("mbid", track.RecordingMBID)
+	}
+	if track.Duration > 0 {
+		params.Set("duration", strconv.Itoa(track.Duration))
+	}
+	params.Set("api_sig", lastFMSignature(params, conf.APISecret))
+	// The format isn't part of the signature.
+	params.Set("format", "json")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, conf.URL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(body, &result)
+
+	switch {
+	case result.Error != 0 && slices.Contains(lastFMRetryableErrors, result.Error):
+		return fmt.Errorf("last.fm error %d: %s", result.Error, result.Message)
+	case result.Error != 0:
+		return fmt.Errorf("%w: last.fm error %d: %s", ErrRejected, result.Error, result.Message)
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case isRetryableStatus(resp.StatusCode):
+		return fmt.Errorf("bad status: %s", resp.Status)
+	}
+	return fmt.Errorf("%w: %s", ErrRejected, resp.Status)
+}
+
+// lastFMSignature signs request parameters as described in https://www.last.fm/api/authspec#_8-signing-calls.
+func lastFMSignature(params url.Values, secret string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(key)
+		b.WriteString(params.Get(key))
+	}
+	b.WriteString(secret)
+
+	sum := md5.Sum([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,89 @@
+package scrobble
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/goccy/go-json"
+
+	"github.com/libramusic/libracore/config"
+)
+
+func init() {
+	Registry["listenbrainz"] = ListenBrainz{}
+}
+
+// ListenBrainz forwards listens to a ListenBrainz-compatible server.
+type ListenBrainz struct{}
+
+func (ListenBrainz) ID() string {
+	return "listenbrainz"
+}
+
+func (ListenBrainz) Name() string {
+	return "ListenBrainz"
+}
+
+func (ListenBrainz) Enabled() bool {
+	return config.Conf.Scrobbling.ListenBrainz.URL != ""
+}
+
+func (ListenBrainz) Submit(ctx context.Context, token string, track Track, listenedAt int64) error {
+	additionalInfo := map[string]any{"submission_client": "Libra"}
+	if track.RecordingMBID != "" {
+		additionalInfo["recording_mbid"] = track.RecordingMBID
+	}
+	if track.ISRC != "" {
+		additionalInfo["isrc"] = track.ISRC
+	}
+	if track.Duration > 0 {
+		additionalInfo["duration_ms"] = track.Duration * 1000
+	}
+
+	trackMetadata := map[string]any{
+		"artist_name":     track.ArtistName,
+		"track_name":      track.TrackName,
+		"additional_info": additionalInfo,
+	}
+	if track.ReleaseName != "" {
+		trackMetadata["release_name"] = track.ReleaseName
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"listen_type": "single",
+		"payload": []map[string]any{{
+			"listened_at":    listenedAt,
+			"track_metadata": trackMetadata,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimSuffix(config.Conf.Scrobbling.ListenBrainz.URL, "/") + "/1/submit-listens"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if isRetryableStatus(resp.StatusCode) {
+		return fmt.Errorf("bad status: %s: %s", resp.Status, message)
+	}
+	return fmt.Errorf("%w: %s: %s", ErrRejected, resp.Status, message)
+}
@@ -0,0 +1,108 @@
+package scrobble
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+)
+
+var (
+	ErrUnknownScrobbler = errors.New("unknown scrobbler")
+	// ErrRejected is returned when a scrobbler refuses a listen in a way that retrying won't fix,
+	// such as an invalid token.
+	ErrRejected = errors.New("scrobble rejected")
+)
+
+// The additional_meta key that holds a track's MusicBrainz recording ID.
+const RecordingMBIDMetaKey = "musicbrainz_recording_id"
+
+// Registry contains every scrobbler listens can be forwarded to, keyed by ID.
+// Users link a scrobbler by storing their token for it as a db.ScrobblerLink with the scrobbler's ID.
+var Registry = map[string]Scrobbler{}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Scrobbler forwards listens to an external scrobbling service.
+type Scrobbler interface {
+	ID() string
+	Name() string
+	// Enabled reports whether the scrobbler is configured well enough to be used.
+	Enabled() bool
+	// Submit sends a single listen using the user's token for the service.
+	Submit(ctx context.Context, token string, track Track, listenedAt int64) error
+}
+
+// Track describes what was listened to using names rather than library IDs, which is what scrobblers expect.
+type Track struct {
+	ArtistName    string
+	TrackName     string
+	ReleaseName   string
+	RecordingMBID string
+	ISRC          string
+	// Duration in seconds, or 0 if unknown.
+	Duration int
+}
+
+// Describe returns the metadata of a listen.
+// Listens submitted with their own metadata use it, others are described using the library.
+func Describe(ctx context.Context, listen media.Listen) (Track, error) {
+	track := Track{
+		ArtistName:    listen.ArtistName,
+		TrackName:     listen.TrackName,
+		ReleaseName:   listen.ReleaseName,
+		RecordingMBID: listen.RecordingMBID,
+		ISRC:          listen.ISRC,
+	}
+	if !listen.IsMatched() || listen.PlayableType != "track" {
+		return track, nil
+	}
+
+	libraryTrack, err := db.DB.Track(ctx, listen.PlayableID)
+	if errors.Is(err, db.ErrNotFound) {
+		return track, nil
+	}
+	if err != nil {
+		return track, err
+	}
+	track.Duration = libraryTrack.Duration
+	if track.TrackName != "" {
+		return track, nil
+	}
+
+	track.TrackName = libraryTrack.Title
+	track.ISRC = libraryTrack.ISRC
+	track.RecordingMBID, _ = libraryTrack.AdditionalMeta[RecordingMBIDMetaKey].(string)
+
+	var artistNames []string
+	for _, artistID := range libraryTrack.ArtistIDs {
+		artist, err := db.DB.Artist(ctx, artistID)
+		if errors.Is(err, db.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return track, err
+		}
+		artistNames = append(artistNames, artist.Name)
+	}
+	track.ArtistName = strings.Join(artistNames, ", ")
+
+	if libraryTrack.PrimaryAlbumID != "" {
+		album, err := db.DB.Album(ctx, libraryTrack.PrimaryAlbumID)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			return track, err
+		}
+		track.ReleaseName = album.Title
+	}
+
+	return track, nil
+}
+
+// isRetryableStatus reports whether a failed request may succeed if it is sent again later.
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= 500
+}
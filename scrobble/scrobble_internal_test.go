@@ -0,0 +1,365 @@
+package scrobble
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+)
+
+var testTrack = Track{
+	ArtistName:    "Ipsum",
+	TrackName:     "Lorem",
+	ReleaseName:   "Dolor",
+	RecordingMBID: "8f3471b5-7e6a-48da-86a9-c1c07a0f47ae",
+	ISRC:          "USSKG1912345",
+	Duration:      180,
+}
+
+// setupScrobbling points both scrobblers at handler and restores the configuration afterwards.
+func setupScrobbling(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	previous := config.Conf.Scrobbling
+	config.Conf.Scrobbling = config.ScrobblingConfig{
+		Enabled:        true,
+		MaxAttempts:    3,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     time.Hour,
+		ListenBrainz:   config.ScrobblerConfig{URL: server.URL},
+		LastFM:         config.ScrobblerConfig{URL: server.URL, APIKey: "key", APISecret: "secret"},
+	}
+	t.Cleanup(func() {
+		config.Conf.Scrobbling = previous
+		server.Close()
+	})
+}
+
+// setupDatabase replaces db.DB with an empty SQLite database.
+func setupDatabase(t *testing.T) {
+	t.Helper()
+
+	database := db.Registry["sqlite"].WithDSN(filepath.Join(t.TempDir(), "libra.db"))
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	previous, previousIDLength := db.DB, config.Conf.General.IDLength
+	db.DB = database
+	config.Conf.General.IDLength = 11
+	t.Cleanup(func() {
+		db.DB = previous
+		config.Conf.General.IDLength = previousIDLength
+		database.Close()
+	})
+}
+
+func TestLastFMSubmit(t *testing.T) {
+	setupScrobbling(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		params := url.Values{}
+		for key, values := range r.PostForm {
+			if key != "api_sig" && key != "format" {
+				params[key] = values
+			}
+		}
+		if sig := lastFMSignature(params, "secret"); r.PostForm.Get("api_sig") != sig {
+			t.Errorf("api_sig = %q, want %q", r.PostForm.Get("api_sig"), sig)
+		}
+		want := map[string]string{
+			"method":    "track.scrobble",
+			"api_key":   "key",
+			"sk":        "session",
+			"artist":    "Ipsum",
+			"track":     "Lorem",
+			"album":     "Dolor",
+			"timestamp": "1634296980",
+			"duration":  "180",
+			"format":    "json",
+		}
+		for key, value := range want {
+			if got := r.PostForm.Get(key); got != value {
+				t.Errorf("%s = %q, want %q", key, got, value)
+			}
+		}
+		_, _ = io.WriteString(w, `{"scrobbles":{"@attr":{"accepted":1,"ignored":0}}}`)
+	})
+
+	if err := (LastFM{}).Submit(context.Background(), "session", testTrack, 1634296980); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLastFMSubmitErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		permanent bool
+	}{
+		{name: "service offline", status: http.StatusOK, body: `{"error":11,"message":"Service Offline"}`},
+		{name: "rate limited", status: http.StatusOK, body: `{"error":29,"message":"Rate Limit Exceeded"}`},
+		{
+			name:      "invalid session key",
+			status:    http.StatusForbidden,
+			body:      `{"error":9,"message":"Invalid session key"}`,
+			permanent: true,
+		},
+		{name: "server error", status: http.StatusServiceUnavailable},
+		{name: "bad request", status: http.StatusBadRequest, permanent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupScrobbling(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.body)
+			})
+
+			err := (LastFM{}).Submit(context.Background(), "session", testTrack, 1634296980)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.Is(err, ErrRejected) != tt.permanent {
+				t.Errorf("errors.Is(%v, ErrRejected) = %v, want %v", err, !tt.permanent, tt.permanent)
+			}
+		})
+	}
+}
+
+func TestListenBrainzSubmit(t *testing.T) {
+	setupScrobbling(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1/submit-listens" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Token user-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+
+		var body struct {
+			ListenType string `json:"listen_type"`
+			Payload    []struct {
+				ListenedAt    int64 `json:"listened_at"`
+				TrackMetadata struct {
+					ArtistName     string         `json:"artist_name"`
+					TrackName      string         `json:"track_name"`
+					ReleaseName    string         `json:"release_name"`
+					AdditionalInfo map[string]any `json:"additional_info"`
+				} `json:"track_metadata"`
+			} `json:"payload"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.ListenType != "single" || len(body.Payload) != 1 {
+			t.Fatalf("unexpected submission %+v", body)
+		}
+		listen := body.Payload[0]
+		if listen.ListenedAt != 1634296980 || listen.TrackMetadata.TrackName != "Lorem" ||
+			listen.TrackMetadata.ArtistName != "Ipsum" || listen.TrackMetadata.ReleaseName != "Dolor" {
+			t.Errorf("unexpected listen %+v", listen)
+		}
+		if got := listen.TrackMetadata.AdditionalInfo["recording_mbid"]; got != testTrack.RecordingMBID {
+			t.Errorf("recording_mbid = %v", got)
+		}
+		if got := listen.TrackMetadata.AdditionalInfo["duration_ms"]; got != float64(180000) {
+			t.Errorf("duration_ms = %v", got)
+		}
+		_, _ = io.WriteString(w, `{"status":"ok"}`)
+	})
+
+	if err := (ListenBrainz{}).Submit(context.Background(), "user-token", testTrack, 1634296980); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListenBrainzSubmitErrors(t *testing.T) {
+	tests := []struct {
+		status    int
+		permanent bool
+	}{
+		{status: http.StatusTooManyRequests},
+		{status: http.StatusInternalServerError},
+		{status: http.StatusUnauthorized, permanent: true},
+		{status: http.StatusBadRequest, permanent: true},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			setupScrobbling(t, func(w http.ResponseWriter, _ *http.Request) {
+				http.Error(w, `{"code":0,"error":"failed"}`, tt.status)
+			})
+
+			err := (ListenBrainz{}).Submit(context.Background(), "user-token", testTrack, 1634296980)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.Is(err, ErrRejected) != tt.permanent {
+				t.Errorf("errors.Is(%v, ErrRejected) = %v, want %v", err, !tt.permanent, tt.permanent)
+			}
+		})
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	var statuses []int
+	setupScrobbling(t, func(w http.ResponseWriter, _ *http.Request) {
+		status := http.StatusOK
+		if len(statuses) > 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		w.WriteHeader(status)
+	})
+	setupDatabase(t)
+	ctx := context.Background()
+
+	listen := media.Listen{ID: "listen00001", UserID: "user0000001", TrackName: "Lorem", ArtistName: "Ipsum"}
+	if err := db.DB.AddListen(ctx, listen); err != nil {
+		t.Fatal(err)
+	}
+	err := db.DB.SetScrobblerLink(ctx, db.ScrobblerLink{UserID: listen.UserID, Scrobbler: "listenbrainz", Token: "t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// queue enqueues the listen and returns its only queued scrobble.
+	queue := func(t *testing.T) db.Scrobble {
+		t.Helper()
+		if err := Enqueue(ctx, listen); err != nil {
+			t.Fatal(err)
+		}
+		scrobbles, err := db.DB.AllScrobbles(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(scrobbles) != 1 {
+			t.Fatalf("%d scrobbles are queued, want 1", len(scrobbles))
+		}
+		return scrobbles[0]
+	}
+	// deliverAgain delivers the scrobble and returns it as stored afterwards.
+	deliverAgain := func(t *testing.T, scrobble db.Scrobble) (db.Scrobble, error) {
+		t.Helper()
+		if err := deliver(ctx, scrobble); err != nil {
+			t.Fatal(err)
+		}
+		return db.DB.Scrobble(ctx, scrobble.ID)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		if _, err := deliverAgain(t, queue(t)); !errors.Is(err, db.ErrNotFound) {
+			t.Errorf("delivered scrobble is still queued: %v", err)
+		}
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		statuses = []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}
+		scrobble := queue(t)
+
+		before := time.Now()
+		scrobble, err := deliverAgain(t, scrobble)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scrobble.Failed || scrobble.Attempts != 1 || scrobble.LastError == "" {
+			t.Fatalf("unexpected scrobble after a retryable failure %+v", scrobble)
+		}
+		if wait := time.Unix(scrobble.NextAttemptAt, 0).Sub(before); wait < 59*time.Second {
+			t.Errorf("next attempt is in %v, want the initial backoff", wait)
+		}
+
+		scrobble, err = deliverAgain(t, scrobble)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scrobble.Failed || scrobble.Attempts != 2 {
+			t.Fatalf("unexpected scrobble after a second failure %+v", scrobble)
+		}
+
+		if _, err := deliverAgain(t, scrobble); !errors.Is(err, db.ErrNotFound) {
+			t.Errorf("scrobble delivered on retry is still queued: %v", err)
+		}
+	})
+
+	t.Run("out of attempts", func(t *testing.T) {
+		statuses = []int{http.StatusServiceUnavailable}
+		scrobble := queue(t)
+		scrobble.Attempts = config.Conf.Scrobbling.MaxAttempts - 1
+
+		scrobble, err := deliverAgain(t, scrobble)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !scrobble.Failed {
+			t.Errorf("scrobble that ran out of attempts isn't failed %+v", scrobble)
+		}
+		if err := db.DB.DeleteScrobble(ctx, scrobble.ID); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		statuses = []int{http.StatusUnauthorized}
+		scrobble, err := deliverAgain(t, queue(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !scrobble.Failed || scrobble.Attempts != 1 {
+			t.Errorf("rejected scrobble isn't failed after one attempt %+v", scrobble)
+		}
+
+		if err := Retry(ctx, scrobble); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := deliverAgain(t, scrobble); !errors.Is(err, db.ErrNotFound) {
+			t.Errorf("retried scrobble is still queued: %v", err)
+		}
+	})
+
+	t.Run("unlinked", func(t *testing.T) {
+		scrobble := queue(t)
+		if err := db.DB.DeleteScrobblerLink(ctx, listen.UserID, "listenbrainz"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := deliverAgain(t, scrobble); !errors.Is(err, db.ErrNotFound) {
+			t.Errorf("scrobble for an unlinked scrobbler is still queued: %v", err)
+		}
+
+		if err := Enqueue(ctx, listen); err != nil {
+			t.Fatal(err)
+		}
+		if scrobbles, err := db.DB.AllScrobbles(ctx); err != nil || len(scrobbles) != 0 {
+			t.Errorf("listen was queued for an unlinked scrobbler: %v, %v", scrobbles, err)
+		}
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	previous := config.Conf.Scrobbling
+	t.Cleanup(func() { config.Conf.Scrobbling = previous })
+	config.Conf.Scrobbling.InitialBackoff = time.Minute
+	config.Conf.Scrobbling.MaxBackoff = 5 * time.Minute
+
+	for attempts, want := range map[int]time.Duration{
+		1: time.Minute,
+		2: 2 * time.Minute,
+		3: 4 * time.Minute,
+		4: 5 * time.Minute,
+		9: 5 * time.Minute,
+	} {
+		if got := backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
@@ -6,10 +6,10 @@ import "github.com/swaggo/swag/v2"
 
 const docTemplate = `{
     "schemes": {{ marshal .Schemes }},
-    "components": {"schemas":{"media.Album":{"properties":{"addition_date":{"example":1634296980,"type":"integer"},"additional_meta":{"additionalProperties":{},"type":"object"},"artist_ids":{"example":["h3r3VpPvSq8","R2QTLKbHamW"],"items":{"type":"string"},"type":"array","uniqueItems":false},"description":{"example":"Lorem ipsum dolor sit amet.","type":"string"},"ean":{"example":"0012345678905","type":"string"},"favorite_count":{"example":5,"type":"integer"},"id":{"example":"BhRpYVlrMo8","type":"string"},"linked_item_ids":{"items":{"type":"string"},"type":"array","uniqueItems":false},"listen_count":{"example":150,"type":"integer"},"metadata_source":{"type":"string"},"permissions":{"additionalProperties":{"type":"string"},"type":"object"},"release_date":{"example":"2023-10-01","type":"string"},"tags":{"items":{"type":"string"},"type":"array","uniqueItems":false},"title":{"example":"Lorem Ipsum","type":"string"},"track_ids":{"example":["7nTwkcl51u4","OBTwkAXODLd"],"items":{"type":"string"},"type":"array","uniqueItems":false},"upc":{"example":"012345678905","type":"string"},"user_id":{"example":"TPkrKcIZRRq","type":"string"}},"type":"object"},"media.Artist":{"properties":{"addition_date":{"example":1634296980,"type":"integer"},"additional_meta":{"additionalProperties":{},"type":"object"},"album_ids":{"example":["BhRpYVlrMo8","poFEUbgBuwJ"],"items":{"type":"string"},"type":"array","uniqueItems":false},"creation_date":{"example":"2023-10-01","type":"string"},"description":{"example":"Artist description here.","type":"string"},"favorite_count":{"example":5,"type":"integer"},"id":{"example":"h3r3VpPvSq8","type":"string"},"linked_item_ids":{"items":{"type":"string"},"type":"array","uniqueItems":false},"listen_count":{"example":150,"type":"integer"},"metadata_source":{"type":"string"},"name":{"example":"John Doe","type":"string"},"permissions":{"additionalProperties":{"type":"string"},"type":"object"},"tags":{"items":{"type":"string"},"type":"array","uniqueItems":false},"track_ids":{"example":["7nTwkcl51u4","OBTwkAXODLd"],"items":{"type":"string"},"type":"array","uniqueItems":false},"user_id":{"example":"TPkrKcIZRRq","type":"string"}},"type":"object"},"media.Playlist":{"properties":{"addition_date":{"example":1634296980,"type":"integer"},"additional_meta":{"additionalProperties":{},"type":"object"},"creation_date":{"example":"2023-10-01","type":"string"},"description":{"example":"Lorem ipsum dolor sit amet.","type":"string"},"favorite_count":{"example":25,"type":"integer"},"id":{"example":"JpXHsNCAATt","type":"string"},"listen_count":{"example":150,"type":"integer"},"metadata_source":{"type":"string"},"permissions":{"additionalProperties":{"type":"string"},"type":"object"},"tags":{"items":{"type":"string"},"type":"array","uniqueItems":false},"title":{"example":"Lorem Ipsum Playlist","type":"string"},"track_ids":{"example":["7nTwkcl51u4","OBTwkAXODLd"],"items":{"type":"string"},"type":"array","uniqueItems":false},"user_id":{"example":"TPkrKcIZRRq","type":"string"}},"type":"object"},"media.Track":{"properties":{"addition_date":{"example":1634296980,"type":"integer"},"additional_meta":{"additionalProperties":{},"type":"object"},"album_ids":{"example":["BhRpYVlrMo8","poFEUbgBuwJ"],"items":{"type":"string"},"type":"array","uniqueItems":false},"artist_ids":{"example":["h3r3VpPvSq8","R2QTLKbHamW"],"items":{"type":"string"},"type":"array","uniqueItems":false},"content_source":{"type":"string"},"description":{"example":"Lorem ipsum dolor sit amet.","type":"string"},"duration":{"example":300,"type":"integer"},"favorite_count":{"example":5,"type":"integer"},"id":{"example":"7nTwkcl51u4","type":"string"},"isrc":{"example":"USSKG1912345","type":"string"},"linked_item_ids":{"items":{"type":"string"},"type":"array","uniqueItems":false},"listen_count":{"example":150,"type":"integer"},"lyric_sources":{"additionalProperties":{"type":"string"},"type":"object"},"lyrics":{"additionalProperties":{"type":"string"},"type":"object"},"metadata_source":{"type":"string"},"permissions":{"additionalProperties":{"type":"string"},"type":"object"},"primary_album_id":{"example":"BhRpYVlrMo8","type":"string"},"release_date":{"example":"2023-10-01","type":"string"},"tags":{"items":{"type":"string"},"type":"array","uniqueItems":false},"title":{"example":"Lorem","type":"string"},"track_number":{"example":1,"type":"integer"},"user_id":{"example":"TPkrKcIZRRq","type":"string"}},"type":"object"},"media.User":{"properties":{"creation_date":{"type":"integer"},"description":{"example":"I am a person.","type":"string"},"display_name":{"example":"John Doe","type":"string"},"email":{"example":"john.doe@example.com","type":"string"},"favorites":{"items":{"type":"string"},"type":"array","uniqueItems":false},"id":{"example":"TPkrKcIZRRq","type":"string"},"linked_artist_id":{"example":"h3r3VpPvSq8","type":"string"},"linked_sources":{"additionalProperties":{"type":"string"},"type":"object"},"listened_to":{"additionalProperties":{"type":"integer"},"type":"object"},"permissions":{"additionalProperties":{"type":"string"},"type":"object"},"public_view_count":{"example":519,"type":"integer"},"username":{"example":"JohnDoe","type":"string"}},"type":"object"},"media.Video":{"properties":{"addition_date":{"type":"integer"},"additional_meta":{"additionalProperties":{},"type":"object"},"artist_ids":{"example":["h3r3VpPvSq8","R2QTLKbHamW"],"items":{"type":"string"},"type":"array","uniqueItems":false},"content_source":{"type":"string"},"description":{"example":"Lorem ipsum dolor sit amet.","type":"string"},"duration":{"example":300,"type":"integer"},"favorite_count":{"example":10,"type":"integer"},"id":{"example":"hCNchWdmbro","type":"string"},"linked_item_ids":{"items":{"type":"string"},"type":"array","uniqueItems":false},"lyric_sources":{"additionalProperties":{"type":"string"},"type":"object"},"metadata_source":{"type":"string"},"permissions":{"additionalProperties":{"type":"string"},"type":"object"},"release_date":{"example":"2023-10-01","type":"string"},"subtitles":{"additionalProperties":{"type":"string"},"type":"object"},"tags":{"items":{"type":"string"},"type":"array","uniqueItems":false},"title":{"example":"Dolor Sit Amet","type":"string"},"user_id":{"example":"TPkrKcIZRRq","type":"string"},"watch_count":{"example":185,"type":"integer"}},"type":"object"},"routes.fakePlayable":{"type":"object"}}},
+    "components": {"schemas":{"db.AuditEvent":{"properties":{"action":{"type":"string"},"creation_date":{"type":"integer"},"details":{"type":"string"},"id":{"type":"string"},"ip":{"type":"string"},"user_id":{"description":"The user the event is about, if known, and the username it was about.","type":"string"},"username":{"type":"string"}},"type":"object"},"db.Scrobble":{"properties":{"attempts":{"type":"integer"},"creation_date":{"type":"integer"},"failed":{"type":"boolean"},"id":{"type":"string"},"last_error":{"type":"string"},"listen_id":{"type":"string"},"next_attempt_at":{"type":"integer"},"scrobbler":{"type":"string"},"user_id":{"type":"string"}},"type":"object"},"events.Event":{"properties":{"data":{},"id":{"description":"Increases with every event published since the server started.","example":42,"type":"integer"},"time":{"example":1634296980,"type":"integer"},"type":{"example":"playable.added","type":"string"}},"type":"object"},"media.AdminPermissions":{"properties":{"delete_others_content":{"type":"boolean"},"manage_sources":{"type":"boolean"},"manage_users":{"type":"boolean"},"upload":{"type":"boolean"},"view_analytics":{"type":"boolean"}},"type":"object"},"media.Album":{"properties":{"addition_date":{"example":1634296980,"type":"integer"},"additional_meta":{"additionalProperties":{},"type":"object"},"artist_ids":{"example":["h3r3VpPvSq8","R2QTLKbHamW"],"items":{"type":"string"},"type":"array","uniqueItems":false},"description":{"example":"Lorem ipsum dolor sit amet.","type":"string"},"ean":{"example":"0012345678905","type":"string"},"favorite_count":{"example":5,"type":"integer"},"favorited":{"description":"Whether the authenticated user has favorited the playable.\nOnly set in API responses to authenticated users.","type":"boolean"},"id":{"example":"BhRpYVlrMo8","type":"string"},"linked_item_ids":{"items":{"type":"string"},"type":"array","uniqueItems":false},"listen_count":{"example":150,"type":"integer"},"metadata_source":{"type":"string"},"permissions":{"additionalProperties":{"type":"string"},"type":"object"},"release_date":{"example":"2023-10-01","type":"string"},"tags":{"items":{"type":"string"},"type":"array","uniqueItems":false},"title":{"example":"Lorem Ipsum","type":"string"},"track_ids":{"example":["7nTwkcl51u4","OBTwkAXODLd"],"items":{"type":"string"},"type":"array","uniqueItems":false},"upc":{"example":"012345678905","type":"string"},"user_id":{"example":"TPkrKcIZRRq","type":"string"}},"type":"object"},"media.Artist":{"properties":{"addition_date":{"example":1634296980,"type":"integer"},"additional_meta":{"additionalProperties":{},"type":"object"},"album_ids":{"example":["BhRpYVlrMo8","poFEUbgBuwJ"],"items":{"type":"string"},"type":"array","uniqueItems":false},"creation_date":{"example":"2023-10-01","type":"string"},"description":{"example":"Artist description here.","type":"string"},"favorite_count":{"example":5,"type":"integer"},"favorited":{"description":"Whether the authenticated user has favorited the playable.\nOnly set in API responses to authenticated users.","type":"boolean"},"id":{"example":"h3r3VpPvSq8","type":"string"},"linked_item_ids":{"items":{"type":"string"},"type":"array","uniqueItems":false},"listen_count":{"example":150,"type":"integer"},"metadata_source":{"type":"string"},"name":{"example":"John Doe","type":"string"},"permissions":{"additionalProperties":{"type":"string"},"type":"object"},"tags":{"items":{"type":"string"},"type":"array","uniqueItems":false},"track_ids":{"example":["7nTwkcl51u4","OBTwkAXODLd"],"items":{"type":"string"},"type":"array","uniqueItems":false},"user_id":{"example":"TPkrKcIZRRq","type":"string"}},"type":"object"},"media.Listen":{"properties":{"artist_name":{"example":"Ipsum","type":"string"},"client":{"example":"Libra Web","type":"string"},"duration_played":{"example":180,"type":"integer"},"id":{"example":"aZ3kd9LmQ2x","type":"string"},"isrc":{"example":"USSKG1912345","type":"string"},"listened_at":{"example":1634296980,"type":"integer"},"playable_id":{"example":"7nTwkcl51u4","type":"string"},"playable_type":{"example":"track","type":"string"},"recording_mbid":{"example":"8f3471b5-7e6a-48da-86a9-c1c07a0f47ae","type":"string"},"release_name":{"example":"Dolor","type":"string"},"source":{"example":"playlist","type":"string"},"track_name":{"example":"Lorem","type":"string"},"user_id":{"example":"TPkrKcIZRRq","type":"string"}},"type":"object"},"media.PlayQueue":{"properties":{"context":{"$ref":"#/components/schemas/media.QueueContext"},"current_index":{"example":2,"type":"integer"},"device":{"example":"Phone","type":"string"},"items":{"items":{"$ref":"#/components/schemas/media.QueueItem"},"type":"array","uniqueItems":false},"position_ms":{"example":73500,"type":"integer"},"repeat":{"example":"off","type":"string"},"revision":{"example":12,"type":"integer"},"shuffle":{"type":"boolean"},"updated_at":{"example":1634296980,"type":"integer"},"user_id":{"example":"TPkrKcIZRRq","type":"string"}},"type":"object"},"media.Playlist":{"properties":{"addition_date":{"example":1634296980,"type":"integer"},"additional_meta":{"additionalProperties":{},"type":"object"},"creation_date":{"example":"2023-10-01","type":"string"},"description":{"example":"Lorem ipsum dolor sit amet.","type":"string"},"entries":{"items":{"$ref":"#/components/schemas/media.PlaylistEntry"},"type":"array","uniqueItems":false},"evaluated_at":{"example":1634296980,"type":"integer"},"favorite_count":{"example":25,"type":"integer"},"favorited":{"description":"Whether the authenticated user has favorited the playable.\nOnly set in API responses to authenticated users.","type":"boolean"},"id":{"example":"JpXHsNCAATt","type":"string"},"kind":{"example":"smart","type":"string"},"listen_count":{"example":150,"type":"integer"},"metadata_source":{"type":"string"},"permissions":{"additionalProperties":{"type":"string"},"type":"object"},"revision":{"example":3,"type":"integer"},"rules":{"example":"tag = \"jazz\" LIMIT 100","type":"string"},"tags":{"items":{"type":"string"},"type":"array","uniqueItems":false},"title":{"example":"Lorem Ipsum Playlist","type":"string"},"track_ids":{"example":["7nTwkcl51u4","OBTwkAXODLd"],"items":{"type":"string"},"type":"array","uniqueItems":false},"user_id":{"example":"TPkrKcIZRRq","type":"string"},"visibility":{"example":"private","type":"string"}},"type":"object"},"media.PlaylistEntry":{"properties":{"added_at":{"example":1634296980,"type":"integer"},"added_by":{"description":"The user who added the track to the playlist.","example":"TPkrKcIZRRq","type":"string"},"track_id":{"example":"7nTwkcl51u4","type":"string"}},"type":"object"},"media.QueueContext":{"description":"What the queue was started from. It's empty for queues that were put together by hand.","properties":{"id":{"example":"BhRpYVlrMo8","type":"string"},"type":{"example":"album","type":"string"}},"type":"object"},"media.QueueItem":{"properties":{"playable_id":{"example":"7nTwkcl51u4","type":"string"},"playable_type":{"example":"track","type":"string"}},"type":"object"},"media.Track":{"properties":{"addition_date":{"example":1634296980,"type":"integer"},"additional_meta":{"additionalProperties":{},"type":"object"},"album_ids":{"example":["BhRpYVlrMo8","poFEUbgBuwJ"],"items":{"type":"string"},"type":"array","uniqueItems":false},"artist_ids":{"example":["h3r3VpPvSq8","R2QTLKbHamW"],"items":{"type":"string"},"type":"array","uniqueItems":false},"content_source":{"type":"string"},"description":{"example":"Lorem ipsum dolor sit amet.","type":"string"},"duration":{"example":300,"type":"integer"},"favorite_count":{"example":5,"type":"integer"},"favorited":{"description":"Whether the authenticated user has favorited the playable.\nOnly set in API responses to authenticated users.","type":"boolean"},"id":{"example":"7nTwkcl51u4","type":"string"},"isrc":{"example":"USSKG1912345","type":"string"},"linked_item_ids":{"items":{"type":"string"},"type":"array","uniqueItems":false},"listen_count":{"example":150,"type":"integer"},"lyric_sources":{"additionalProperties":{"type":"string"},"type":"object"},"lyrics":{"additionalProperties":{"type":"string"},"type":"object"},"metadata_source":{"type":"string"},"permissions":{"additionalProperties":{"type":"string"},"type":"object"},"primary_album_id":{"example":"BhRpYVlrMo8","type":"string"},"release_date":{"example":"2023-10-01","type":"string"},"tags":{"items":{"type":"string"},"type":"array","uniqueItems":false},"title":{"example":"Lorem","type":"string"},"track_number":{"example":1,"type":"integer"},"user_id":{"example":"TPkrKcIZRRq","type":"string"}},"type":"object"},"media.User":{"properties":{"creation_date":{"type":"integer"},"description":{"example":"I am a person.","type":"string"},"display_name":{"example":"John Doe","type":"string"},"email":{"example":"john.doe@example.com","type":"string"},"favorites":{"items":{"type":"string"},"type":"array","uniqueItems":false},"id":{"example":"TPkrKcIZRRq","type":"string"},"linked_artist_id":{"example":"h3r3VpPvSq8","type":"string"},"listened_to":{"additionalProperties":{"type":"integer"},"type":"object"},"permissions":{"additionalProperties":{"type":"string"},"type":"object"},"public_view_count":{"example":519,"type":"integer"},"username":{"example":"JohnDoe","type":"string"}},"type":"object"},"media.Video":{"properties":{"addition_date":{"type":"integer"},"additional_meta":{"additionalProperties":{},"type":"object"},"artist_ids":{"example":["h3r3VpPvSq8","R2QTLKbHamW"],"items":{"type":"string"},"type":"array","uniqueItems":false},"content_source":{"type":"string"},"description":{"example":"Lorem ipsum dolor sit amet.","type":"string"},"duration":{"example":300,"type":"integer"},"favorite_count":{"example":10,"type":"integer"},"favorited":{"description":"Whether the authenticated user has favorited the playable.\nOnly set in API responses to authenticated users.","type":"boolean"},"id":{"example":"hCNchWdmbro","type":"string"},"linked_item_ids":{"items":{"type":"string"},"type":"array","uniqueItems":false},"lyric_sources":{"additionalProperties":{"type":"string"},"type":"object"},"metadata_source":{"type":"string"},"permissions":{"additionalProperties":{"type":"string"},"type":"object"},"release_date":{"example":"2023-10-01","type":"string"},"subtitles":{"additionalProperties":{"type":"string"},"type":"object"},"tags":{"items":{"type":"string"},"type":"array","uniqueItems":false},"title":{"example":"Dolor Sit Amet","type":"string"},"user_id":{"example":"TPkrKcIZRRq","type":"string"},"watch_count":{"example":185,"type":"integer"}},"type":"object"},"playlistfile.Entry":{"properties":{"album":{"type":"string"},"artist":{"type":"string"},"duration":{"description":"Duration in seconds, or 0 if unknown.","type":"integer"},"isrc":{"type":"string"},"location":{"description":"Where the track can be found, such as a file path or a URL.","type":"string"},"mbid":{"description":"The MusicBrainz recording ID.","type":"string"},"title":{"type":"string"}},"type":"object"},"playlistfile.ImportResult":{"properties":{"playlist":{"$ref":"#/components/schemas/media.Playlist"},"unmatched":{"description":"The entries that matched no track and were left out of the playlist.","items":{"$ref":"#/components/schemas/playlistfile.Unmatched"},"type":"array","uniqueItems":false}},"type":"object"},"playlistfile.Unmatched":{"properties":{"entry":{"$ref":"#/components/schemas/playlistfile.Entry"},"position":{"description":"The position of the entry in the file, starting at 0.","type":"integer"}},"type":"object"},"routes.addPlaylistTracksRequest":{"properties":{"position":{"description":"Where to insert the tracks. The tracks are appended if it's omitted.","type":"integer"},"track_ids":{"items":{"type":"string"},"type":"array","uniqueItems":false}},"type":"object"},"routes.eventsTicketResponse":{"properties":{"expires_in":{"description":"Seconds until the ticket expires.","type":"integer"},"ticket":{"type":"string"}},"type":"object"},"routes.fakePlayable":{"type":"object"},"routes.favoriteResponse":{"properties":{"favorited_at":{"example":1634296980,"type":"integer"},"playable":{"description":"The favorited playable, or null if it no longer exists or the requester can't see it."},"playable_id":{"example":"7nTwkcl51u4","type":"string"},"playable_type":{"example":"track","type":"string"},"user_id":{"example":"TPkrKcIZRRq","type":"string"}},"type":"object"},"routes.linkScrobblerRequest":{"properties":{"token":{"description":"The user's token for the scrobbler. For Last.fm, this is the session key.","example":"b1f2c3d4-e5f6-4789-abcd-ef0123456789","type":"string"}},"type":"object"},"routes.listenRequest":{"properties":{"client":{"example":"Libra Web","type":"string"},"duration_played":{"example":180,"type":"integer"},"listened_at":{"description":"Unix timestamp of when playback started. Defaults to now.","example":1634296980,"type":"integer"},"now_playing":{"description":"Marks the playable as currently playing instead of submitting a listen.","example":false,"type":"boolean"},"playable_id":{"example":"7nTwkcl51u4","type":"string"},"playable_type":{"example":"track","type":"string"},"source":{"example":"playlist","type":"string"}},"type":"object"},"routes.movePlaylistTrackRequest":{"properties":{"from":{"type":"integer"},"to":{"type":"integer"}},"type":"object"},"routes.permissionsResponse":{"properties":{"permissions":{"$ref":"#/components/schemas/media.AdminPermissions"},"role":{"example":"member","type":"string"}},"type":"object"},"routes.playlistTrackResponse":{"properties":{"added_at":{"example":1634296980,"type":"integer"},"added_by":{"description":"The user who added the track to the playlist.","example":"TPkrKcIZRRq","type":"string"},"position":{"description":"The position of the track in the playlist, starting at 0.","example":0,"type":"integer"},"track":{"description":"The track, or null if it no longer exists."},"track_id":{"example":"7nTwkcl51u4","type":"string"}},"type":"object"},"routes.replacePlayQueueRequest":{"properties":{"context":{"$ref":"#/components/schemas/media.QueueContext"},"current_index":{"description":"The position of the playing item in items, starting at 0.","example":2,"type":"integer"},"device":{"description":"The name of the device that is playing the queue.","example":"Phone","type":"string"},"items":{"items":{"$ref":"#/components/schemas/media.QueueItem"},"type":"array","uniqueItems":false},"position_ms":{"description":"How far into the playing item playback is, in milliseconds.","example":73500,"type":"integer"},"repeat":{"example":"off","type":"string"},"shuffle":{"type":"boolean"}},"type":"object"},"routes.replacePlaylistTracksRequest":{"properties":{"track_ids":{"items":{"type":"string"},"type":"array","uniqueItems":false}},"type":"object"},"routes.scrobblerInfo":{"properties":{"enabled":{"example":true,"type":"boolean"},"id":{"example":"listenbrainz","type":"string"},"linked":{"example":false,"type":"boolean"},"name":{"example":"ListenBrainz","type":"string"}},"type":"object"},"routes.setRoleRequest":{"properties":{"role":{"description":"One of owner, admin, member or guest.","example":"admin","type":"string"}},"type":"object"}}},
     "info": {"contact":{"name":"Libra Team","url":"https://github.com/LibraMusic/LibraCore"},"description":"{{escape .Description}}","license":{"name":"MIT","url":"https://opensource.org/licenses/MIT"},"title":"{{.Title}}","version":"{{.Version}}"},
     "externalDocs": {"description":"","url":""},
-    "paths": {"/fake":{"get":{"responses":{"200":{"content":{"application/json":{"schema":{"oneOf":[{"$ref":"#/components/schemas/media.Track"},{"$ref":"#/components/schemas/media.Album"},{"$ref":"#/components/schemas/media.Video"},{"$ref":"#/components/schemas/media.Playlist"},{"$ref":"#/components/schemas/media.Artist"},{"$ref":"#/components/schemas/media.User"}]}}},"description":"OK"}}}},"/playables":{"get":{"operationId":"getAllPlayables","responses":{"200":{"content":{"application/json":{"schema":{"items":{"$ref":"#/components/schemas/routes.fakePlayable"},"type":"array"}}},"description":"Returns a list of all playables"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get all playables"}},"/playables/{id}":{"get":{"operationId":"getUserPlayables","parameters":[{"description":"User ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"items":{"$ref":"#/components/schemas/routes.fakePlayable"},"type":"array"}}},"description":"Returns a list of user's playables"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get user's playables"}},"/search":{"get":{"operationId":"searchPlayables","parameters":[{"description":"Search query","in":"query","name":"q","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"items":{"$ref":"#/components/schemas/routes.fakePlayable"},"type":"array"}}},"description":"Returns a list of playables matching the search query"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Search for playables by query"}}},
+    "paths": {"/album":{"post":{"description":"The album is owned by the authenticated user. Its ID, owner and counts are set by the server.","operationId":"createAlbum","requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Album"}}},"description":"Album","required":true},"responses":{"201":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Album"}}},"description":"Created"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Create a album"}},"/album/{id}":{"delete":{"operationId":"deleteAlbum","parameters":[{"description":"Album ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"204":{"description":"No Content"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Delete a album"},"get":{"operationId":"getAlbum","parameters":[{"description":"Album ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Album"}}},"description":"OK"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get a album"},"patch":{"description":"Applies a JSON Merge Patch (RFC 7386). The ID, owner and counts can't be changed.","operationId":"updateAlbum","parameters":[{"description":"Album ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"requestBody":{"content":{"application/merge-patch+json":{"schema":{"$ref":"#/components/schemas/media.Album"}}},"description":"Merge patch","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Album"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"415":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unsupported Media Type"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Update a album"},"put":{"description":"Fields that are left out are cleared. The ID, owner and counts can't be changed.","operationId":"replaceAlbum","parameters":[{"description":"Album ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Album"}}},"description":"Album","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Album"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Replace a album"}},"/artist":{"post":{"description":"The artist is owned by the authenticated user. Its ID, owner and counts are set by the server.","operationId":"createArtist","requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Artist"}}},"description":"Artist","required":true},"responses":{"201":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Artist"}}},"description":"Created"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Create a artist"}},"/artist/{id}":{"delete":{"operationId":"deleteArtist","parameters":[{"description":"Artist ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"204":{"description":"No Content"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Delete a artist"},"get":{"operationId":"getArtist","parameters":[{"description":"Artist ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Artist"}}},"description":"OK"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get a artist"},"patch":{"description":"Applies a JSON Merge Patch (RFC 7386). The ID, owner and counts can't be changed.","operationId":"updateArtist","parameters":[{"description":"Artist ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"requestBody":{"content":{"application/merge-patch+json":{"schema":{"$ref":"#/components/schemas/media.Artist"}}},"description":"Merge patch","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Artist"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"415":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unsupported Media Type"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Update a artist"},"put":{"description":"Fields that are left out are cleared. The ID, owner and counts can't be changed.","operationId":"replaceArtist","parameters":[{"description":"Artist ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Artist"}}},"description":"Artist","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Artist"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Replace a artist"}},"/audit-log":{"get":{"description":"Requires the manage_users permission. The audit log records security-relevant events,\nsuch as accounts and IP addresses being locked out after too many failed logins.","operationId":"getAuditLog","parameters":[{"description":"Maximum number of events to return","in":"query","name":"limit","schema":{"default":50,"type":"integer"}},{"description":"Number of events to skip","in":"query","name":"offset","schema":{"default":0,"type":"integer"}}],"responses":{"200":{"content":{"application/json":{"schema":{"items":{"$ref":"#/components/schemas/db.AuditEvent"},"type":"array"}}},"description":"Returns the events, most recent first"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get the audit log"}},"/events":{"get":{"description":"Sends what happens on the server as it happens, limited to what the authenticated user can see.\nEvents are sent as Server-Sent Events, or as JSON text messages if the request is a WebSocket handshake.\nThe event types are playable.added, playable.updated, playable.deleted, download.progress,\nqueue.changed, now_playing.changed and scan.progress.\nClients that fall too far behind are disconnected, and events sent while a client is disconnected are lost.\nBrowsers can't send the Authorization header when opening streams, so they can send a ticket from\nPOST /events/ticket instead.","operationId":"streamEvents","parameters":[{"description":"Comma-separated event types or categories to receive, such as playable,queue.changed","in":"query","name":"types","schema":{"type":"string"}},{"description":"Stream ticket, if no token is sent in the Authorization header","in":"query","name":"ticket","schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/events.Event"}},"text/event-stream":{"schema":{"type":"string"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"}},"summary":"Stream events"}},"/events/ticket":{"post":{"description":"Returns a ticket that opens one event stream as the authenticated user when sent as the ticket query\nparameter, for clients that can't send the Authorization header when opening streams.\nIt can only be used once, within 30 seconds.","operationId":"createEventsTicket","responses":{"201":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/routes.eventsTicketResponse"}}},"description":"Created"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"}},"summary":"Get a ticket to stream events"}},"/fake":{"get":{"responses":{"200":{"content":{"application/json":{"schema":{"oneOf":[{"$ref":"#/components/schemas/media.Track"},{"$ref":"#/components/schemas/media.Album"},{"$ref":"#/components/schemas/media.Video"},{"$ref":"#/components/schemas/media.Playlist"},{"$ref":"#/components/schemas/media.Artist"},{"$ref":"#/components/schemas/media.User"}]}}},"description":"OK"}}}},"/listenbrainz/token":{"get":{"description":"Tokens are only shown when they are created, so clients that need one should reset it.","operationId":"getListenBrainzToken","responses":{"200":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"OK"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get whether the user has a ListenBrainz token"},"post":{"description":"Returns a new token, replacing the previous one. Only its hash is stored, so it can't be shown again.","operationId":"resetListenBrainzToken","responses":{"200":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"OK"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Reset the user's ListenBrainz token"}},"/listens":{"post":{"operationId":"submitListen","requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/routes.listenRequest"}}},"description":"Listen","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Listen"}}},"description":"The playable is now playing"},"201":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Listen"}}},"description":"The listen was recorded"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Submit a listen or a \"now playing\" notification"}},"/me/permissions":{"get":{"description":"Returns the authenticated user's role and what it allows them to do.","operationId":"getPermissions","responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/routes.permissionsResponse"}}},"description":"OK"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get the user's role and permissions"}},"/me/queue":{"get":{"description":"Returns what the authenticated user is listening to, so playback can continue on another device.\nUsers that never stored a queue get an empty one at revision 0.\nThe ETag header holds the queue's revision.","operationId":"getPlayQueue","responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.PlayQueue"}}},"description":"OK"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get the user's play queue"},"put":{"description":"Stores what the authenticated user is listening to, replacing their previous queue.\nEvery change increments the queue's revision.\nSend the queue's ETag in the If-Match header to only replace the queue if no other device has.\nIf another device changed the queue, the response includes its current queue to resolve the conflict.","operationId":"replacePlayQueue","parameters":[{"description":"ETag of the queue the change is based on","in":"header","name":"If-Match","schema":{"type":"string"}}],"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/routes.replacePlayQueueRequest"}}},"description":"The new play queue","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.PlayQueue"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"409":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Conflict"},"412":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Precondition Failed"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Replace the user's play queue"}},"/playables":{"get":{"operationId":"getAllPlayables","responses":{"200":{"content":{"application/json":{"schema":{"items":{"$ref":"#/components/schemas/routes.fakePlayable"},"type":"array"}}},"description":"Returns a list of all playables"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get all playables"}},"/playables/{id}":{"get":{"operationId":"getUserPlayables","parameters":[{"description":"User ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"items":{"$ref":"#/components/schemas/routes.fakePlayable"},"type":"array"}}},"description":"Returns a list of user's playables"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get user's playables"}},"/playlist":{"post":{"description":"The playlist is owned by the authenticated user. Its ID, owner and counts are set by the server.\nPlaylists are private unless a visibility is given. Permissions share the playlist with other users\nby mapping their IDs to the viewer or editor role.\nSmart playlists (kind \"smart\") select their tracks with rules instead of track IDs, for example\ntag = \"jazz\" AND release_date \u003e= 1990 AND last_played \u003c 30d ago ORDER BY listen_count DESC LIMIT 100.\nTheir tracks are re-evaluated when they're saved, when they're read after going stale and on a schedule.","operationId":"createPlaylist","requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Playlist"}}},"description":"Playlist","required":true},"responses":{"201":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Playlist"}}},"description":"Created"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Create a playlist"}},"/playlist/{id}":{"delete":{"operationId":"deletePlaylist","parameters":[{"description":"Playlist ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"204":{"description":"No Content"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Delete a playlist"},"get":{"description":"Private playlists are only found by their owner and the users they're shared with.","operationId":"getPlaylist","parameters":[{"description":"Playlist ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Playlist"}}},"description":"OK"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get a playlist"},"patch":{"description":"Applies a JSON Merge Patch (RFC 7386). The ID, owner and counts can't be changed.","operationId":"updatePlaylist","parameters":[{"description":"Playlist ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"requestBody":{"content":{"application/merge-patch+json":{"schema":{"$ref":"#/components/schemas/media.Playlist"}}},"description":"Merge patch","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Playlist"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"415":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unsupported Media Type"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Update a playlist"},"put":{"description":"Fields that are left out are cleared. The ID, owner and counts can't be changed.","operationId":"replacePlaylist","parameters":[{"description":"Playlist ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Playlist"}}},"description":"Playlist","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Playlist"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Replace a playlist"}},"/playlist/{id}/export":{"get":{"description":"Returns the playlist as an M3U8, XSPF, PLS or JSPF file.\nTracks are located by their stream URLs on this server, and tracks that no longer exist are left out.","operationId":"exportPlaylist","parameters":[{"description":"Playlist ID","in":"path","name":"id","required":true,"schema":{"type":"string"}},{"description":"File format","in":"query","name":"format","schema":{"default":"m3u8","enum":["m3u8","xspf","pls","jspf"],"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"type":"string"}},"text/plain":{"schema":{"type":"string"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Export a playlist"}},"/playlist/{id}/tracks":{"get":{"description":"Returns the playlist's tracks in order along with who added them.\nThe ETag header holds the playlist's revision.","operationId":"getPlaylistTracks","parameters":[{"description":"Playlist ID","in":"path","name":"id","required":true,"schema":{"type":"string"}},{"description":"Maximum number of tracks to return","in":"query","name":"limit","schema":{"default":100,"type":"integer"}},{"description":"Number of tracks to skip","in":"query","name":"offset","schema":{"default":0,"type":"integer"}}],"responses":{"200":{"content":{"application/json":{"schema":{"items":{"$ref":"#/components/schemas/routes.playlistTrackResponse"},"type":"array"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get the tracks of a playlist"},"post":{"description":"Inserts the tracks at the given position, or appends them if no position is given.\nThe playlist's owner and editors can add tracks, which are attributed to the user who added them.\nSend the playlist's ETag in the If-Match header to only change the playlist if nobody else has.\nThe tracks of smart playlists can't be edited.","operationId":"addPlaylistTracks","parameters":[{"description":"Playlist ID","in":"path","name":"id","required":true,"schema":{"type":"string"}},{"description":"ETag of the playlist the change is based on","in":"header","name":"If-Match","schema":{"type":"string"}}],"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/routes.addPlaylistTracksRequest"}}},"description":"Tracks to add","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Playlist"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"409":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Conflict"},"412":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Precondition Failed"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Add tracks to a playlist"},"put":{"description":"Send the playlist's ETag in the If-Match header to only change the playlist if nobody else has.\nThe tracks of smart playlists can't be edited.","operationId":"replacePlaylistTracks","parameters":[{"description":"Playlist ID","in":"path","name":"id","required":true,"schema":{"type":"string"}},{"description":"ETag of the playlist the change is based on","in":"header","name":"If-Match","schema":{"type":"string"}}],"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/routes.replacePlaylistTracksRequest"}}},"description":"The new tracks of the playlist","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Playlist"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"409":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Conflict"},"412":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Precondition Failed"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Replace the tracks of a playlist"}},"/playlist/{id}/tracks/move":{"post":{"description":"Moves the track at position from so that it ends up at position to.\nSend the playlist's ETag in the If-Match header to only change the playlist if nobody else has.\nThe tracks of smart playlists can't be edited.","operationId":"movePlaylistTrack","parameters":[{"description":"Playlist ID","in":"path","name":"id","required":true,"schema":{"type":"string"}},{"description":"ETag of the playlist the change is based on","in":"header","name":"If-Match","schema":{"type":"string"}}],"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/routes.movePlaylistTrackRequest"}}},"description":"Positions to move the track between","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Playlist"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"409":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Conflict"},"412":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Precondition Failed"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Move a track within a playlist"}},"/playlist/{id}/tracks/{position}":{"delete":{"description":"Removes the track at the given position, so only one copy of a track that appears twice is removed.\nSend the playlist's ETag in the If-Match header to only change the playlist if nobody else has.\nThe tracks of smart playlists can't be edited.","operationId":"removePlaylistTrack","parameters":[{"description":"Playlist ID","in":"path","name":"id","required":true,"schema":{"type":"string"}},{"description":"Position of the track, starting at 0","in":"path","name":"position","required":true,"schema":{"type":"integer"}},{"description":"ETag of the playlist the change is based on","in":"header","name":"If-Match","schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Playlist"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"409":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Conflict"},"412":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Precondition Failed"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Remove a track from a playlist"}},"/playlists/import":{"post":{"description":"Creates a private playlist owned by the authenticated user from an M3U8, XSPF, PLS or JSPF file\nsent as the request body. The format is detected from the file if it isn't given.\nEntries are matched against the library by path or stream URL, ISRC, MusicBrainz recording ID,\nand finally by title, artist and duration. Entries that match no track are left out and returned.","operationId":"importPlaylist","parameters":[{"description":"File format","in":"query","name":"format","schema":{"enum":["m3u8","xspf","pls","jspf"],"type":"string"}},{"description":"Title of the playlist, instead of the one in the file","in":"query","name":"title","schema":{"type":"string"}}],"requestBody":{"content":{"text/plain":{"schema":{"type":"string"}}},"description":"Playlist file","required":true},"responses":{"201":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/playlistfile.ImportResult"}}},"description":"Created"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"413":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Request Entity Too Large"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Import a playlist"}},"/scrobblers":{"get":{"operationId":"getScrobblers","responses":{"200":{"content":{"application/json":{"schema":{"items":{"$ref":"#/components/schemas/routes.scrobblerInfo"},"type":"array"}}},"description":"OK"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"List the external scrobblers listens can be forwarded to"}},"/scrobblers/failed":{"get":{"operationId":"getFailedScrobbles","responses":{"200":{"content":{"application/json":{"schema":{"items":{"$ref":"#/components/schemas/db.Scrobble"},"type":"array"}}},"description":"OK"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"List listens that could not be forwarded"}},"/scrobblers/failed/{id}/retry":{"post":{"operationId":"retryFailedScrobble","parameters":[{"description":"Scrobble ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"202":{"description":"Accepted"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Retry forwarding a listen that could not be forwarded"}},"/scrobblers/{id}":{"delete":{"description":"Listens that are still queued for the scrobbler are discarded.","operationId":"unlinkScrobbler","parameters":[{"description":"Scrobbler ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"204":{"description":"No Content"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Unlink an external scrobbler"},"put":{"description":"Listens recorded after linking are forwarded to the scrobbler.","operationId":"linkScrobbler","parameters":[{"description":"Scrobbler ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/routes.linkScrobblerRequest"}}},"description":"Token","required":true},"responses":{"204":{"description":"No Content"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Link an external scrobbler"}},"/search":{"get":{"operationId":"searchPlayables","parameters":[{"description":"Search query","in":"query","name":"q","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"items":{"$ref":"#/components/schemas/routes.fakePlayable"},"type":"array"}}},"description":"Returns a list of playables matching the search query"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Search for playables by query"}},"/track":{"post":{"description":"The track is owned by the authenticated user. Its ID, owner and counts are set by the server.","operationId":"createTrack","requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Track"}}},"description":"Track","required":true},"responses":{"201":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Track"}}},"description":"Created"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Create a track"}},"/track/{id}":{"delete":{"operationId":"deleteTrack","parameters":[{"description":"Track ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"204":{"description":"No Content"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Delete a track"},"get":{"operationId":"getTrack","parameters":[{"description":"Track ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Track"}}},"description":"OK"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get a track"},"patch":{"description":"Applies a JSON Merge Patch (RFC 7386). The ID, owner and counts can't be changed.","operationId":"updateTrack","parameters":[{"description":"Track ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"requestBody":{"content":{"application/merge-patch+json":{"schema":{"$ref":"#/components/schemas/media.Track"}}},"description":"Merge patch","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Track"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"415":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unsupported Media Type"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Update a track"},"put":{"description":"Fields that are left out are cleared. The ID, owner and counts can't be changed.","operationId":"replaceTrack","parameters":[{"description":"Track ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Track"}}},"description":"Track","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Track"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Replace a track"}},"/track/{id}/lyrics":{"get":{"operationId":"getTrackLyrics","parameters":[{"description":"Track ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"additionalProperties":{"type":"string"},"type":"object"}}},"description":"OK"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get a track's lyrics in every language"}},"/track/{id}/lyrics/{lang}":{"get":{"operationId":"getTrackLyricsLang","parameters":[{"description":"Track ID","in":"path","name":"id","required":true,"schema":{"type":"string"}},{"description":"Language","in":"path","name":"lang","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"type":"string"}},"text/plain":{"schema":{"type":"string"}}},"description":"OK"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get a track's lyrics in one language"}},"/users/{id}/favorites":{"get":{"operationId":"getUserFavorites","parameters":[{"description":"User ID","in":"path","name":"id","required":true,"schema":{"type":"string"}},{"description":"Only return favorites of this playable type","in":"query","name":"type","schema":{"enum":["track","album","video","artist","playlist"],"type":"string"}},{"description":"Maximum number of favorites to return","in":"query","name":"limit","schema":{"default":50,"type":"integer"}},{"description":"Number of favorites to skip","in":"query","name":"offset","schema":{"default":0,"type":"integer"}}],"responses":{"200":{"content":{"application/json":{"schema":{"items":{"$ref":"#/components/schemas/routes.favoriteResponse"},"type":"array"}}},"description":"Returns the user's favorites, most recently favorited first"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get a user's favorites"}},"/users/{id}/history":{"get":{"operationId":"getUserHistory","parameters":[{"description":"User ID","in":"path","name":"id","required":true,"schema":{"type":"string"}},{"description":"Maximum number of listens to return","in":"query","name":"limit","schema":{"default":50,"type":"integer"}},{"description":"Number of listens to skip","in":"query","name":"offset","schema":{"default":0,"type":"integer"}}],"responses":{"200":{"content":{"application/json":{"schema":{"items":{"$ref":"#/components/schemas/media.Listen"},"type":"array"}}},"description":"Returns the user's listens, most recent first"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get a user's listening history"}},"/users/{id}/now_playing":{"get":{"operationId":"getUserNowPlaying","parameters":[{"description":"User ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Listen"}}},"description":"OK"},"204":{"description":"The user is not playing anything"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"}},"summary":"Get what a user is currently playing"}},"/users/{id}/role":{"put":{"description":"Requires the manage_users permission. Only the owner can make users admins,\nand other users can only change the roles of users whose role is lower than theirs.\nMaking a user the owner makes the previous owner an admin.","operationId":"setUserRole","parameters":[{"description":"User ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/routes.setRoleRequest"}}},"description":"The new role","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/routes.permissionsResponse"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Change a user's role"}},"/video":{"post":{"description":"The video is owned by the authenticated user. Its ID, owner and counts are set by the server.","operationId":"createVideo","requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Video"}}},"description":"Video","required":true},"responses":{"201":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Video"}}},"description":"Created"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Create a video"}},"/video/{id}":{"delete":{"operationId":"deleteVideo","parameters":[{"description":"Video ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"204":{"description":"No Content"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Delete a video"},"get":{"operationId":"getVideo","parameters":[{"description":"Video ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Video"}}},"description":"OK"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get a video"},"patch":{"description":"Applies a JSON Merge Patch (RFC 7386). The ID, owner and counts can't be changed.","operationId":"updateVideo","parameters":[{"description":"Video ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"requestBody":{"content":{"application/merge-patch+json":{"schema":{"$ref":"#/components/schemas/media.Video"}}},"description":"Merge patch","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Video"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"415":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unsupported Media Type"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Update a video"},"put":{"description":"Fields that are left out are cleared. The ID, owner and counts can't be changed.","operationId":"replaceVideo","parameters":[{"description":"Video ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Video"}}},"description":"Video","required":true},"responses":{"200":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/media.Video"}}},"description":"OK"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"403":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Forbidden"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Replace a video"}},"/video/{id}/subtitles":{"get":{"operationId":"getVideoSubtitles","parameters":[{"description":"Video ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"additionalProperties":{"type":"string"},"type":"object"}}},"description":"OK"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get a video's subtitles in every language"}},"/video/{id}/subtitles/{lang}":{"get":{"operationId":"getVideoSubtitlesLang","parameters":[{"description":"Video ID","in":"path","name":"id","required":true,"schema":{"type":"string"}},{"description":"Language","in":"path","name":"lang","required":true,"schema":{"type":"string"}}],"responses":{"200":{"content":{"application/json":{"schema":{"type":"string"}},"text/plain":{"schema":{"type":"string"}}},"description":"OK"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Get a video's subtitles in one language"}},"/{type}/{id}/favorite":{"delete":{"operationId":"removeFavorite","parameters":[{"description":"Playable type","in":"path","name":"type","required":true,"schema":{"enum":["track","album","video","artist","playlist"],"type":"string"}},{"description":"Playable ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"204":{"description":"No Content"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Remove a playable from the user's favorites"},"put":{"description":"Favoriting a playable that is already a favorite does nothing.","operationId":"addFavorite","parameters":[{"description":"Playable type","in":"path","name":"type","required":true,"schema":{"enum":["track","album","video","artist","playlist"],"type":"string"}},{"description":"Playable ID","in":"path","name":"id","required":true,"schema":{"type":"string"}}],"responses":{"204":{"description":"No Content"},"400":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Bad Request"},"401":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Unauthorized"},"404":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Not Found"},"500":{"content":{"application/json":{"schema":{"type":"object"}}},"description":"Internal Server Error"}},"summary":"Add a playable to the user's favorites"}}},
     "openapi": "3.1.0",
     "servers": [
         {"url":"http://localhost:8080/api/v1"}
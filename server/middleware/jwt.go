@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 	echojwt "github.com/labstack/echo-jwt/v4"
@@ -12,58 +14,200 @@ import (
 	"github.com/libramusic/libracore/server/routes/auth"
 )
 
+// The context key of the scope declared by Scope.
+const scopeKey = "scope"
+
+// Scope declares the scope personal access tokens need for the routes it's used on, usually a route group.
+// It has to come before the JWT middlewares, which check it. Routes without a scope reject personal access tokens.
+func Scope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(scopeKey, scope)
+			return next(c)
+		}
+	}
+}
+
+// JWTProtected authenticates the request with the token in the Authorization header.
+// Personal access tokens are accepted as well, limited to their scopes.
 func JWTProtected(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
+		if token, ok := personalAccessToken(c); ok {
+			return accessTokenProtected(c, next, token)
+		}
+
 		key := auth.SigningKey(config.Conf.Auth.JWT.SigningMethod, config.Conf.Auth.JWT.SigningKey)
 
 		config := echojwt.Config{
-			SuccessHandler: jwtSuccessHandler,
-			ErrorHandler:   jwtErrorHandler,
-			SigningKey:     key,
-			SigningMethod:  config.Conf.Auth.JWT.SigningMethod,
+			ErrorHandler:  jwtErrorHandler,
+			SigningKey:    key,
+			SigningMethod: config.Conf.Auth.JWT.SigningMethod,
 			NewClaimsFunc: func(_ echo.Context) jwt.Claims {
 				return new(auth.TokenClaims)
 			},
 		}
 
-		return echojwt.WithConfig(config)(next)(c)
+		return echojwt.WithConfig(config)(jwtSuccessHandler(next))(c)
+	}
+}
+
+// StreamJWTProtected is like JWTProtected, but also accepts a stream ticket in the ticket query parameter,
+// since browsers can't set headers when opening event streams and WebSockets.
+// Tokens aren't accepted in the URL, where they would end up in the logs of proxies.
+// The token a ticket was issued for is checked again when it's redeemed, in case it was revoked since.
+func StreamJWTProtected(next echo.HandlerFunc) echo.HandlerFunc {
+	protected := JWTProtected(next)
+	return func(c echo.Context) error {
+		ticket := c.QueryParam("ticket")
+		if ticket == "" {
+			return protected(c)
+		}
+
+		token, ok := auth.RedeemStreamTicket(ticket)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, echo.Map{
+				"error": true,
+				"msg":   "Invalid or expired ticket",
+			})
+		}
+		if token.Claims.(*auth.TokenClaims).AccessTokenID != "" {
+			return accessTokenProtected(c, next, token.Raw)
+		}
+		c.Set("user", token)
+		return jwtSuccessHandler(next)(c)
+	}
+}
+
+// JWTOptional authenticates the request if it has a token, but allows requests without one.
+func JWTOptional(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		header := c.Request().Header
+		if header.Get(echo.HeaderAuthorization) != "" || header.Get(auth.APIKeyHeader) != "" {
+			return JWTProtected(next)(c)
+		}
+		return next(c)
 	}
 }
 
+// GlobalJWTProtected protects routes that aren't about one user's data, which require authentication if
+// auth.global_api_routes_require_auth is on. If it's off, they can be used without a token, but one that is sent
+// still has to be valid, so clients learn that it has expired instead of silently getting anonymous responses.
 func GlobalJWTProtected(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		if config.Conf.Auth.GlobalAPIRoutesRequireAuth {
 			return JWTProtected(next)(c)
 		}
-		return next(c)
+		return JWTOptional(next)(c)
 	}
 }
 
+// UserJWTProtected protects routes for the data of the user whose ID is the id path parameter.
+// They require authentication if auth.user_api_routes_require_auth is on, and if auth.user_api_require_same_user is
+// on, only that user and users who can manage users may access them.
+// Like with GlobalJWTProtected, a token that is sent has to be valid even if authentication isn't required.
 func UserJWTProtected(next echo.HandlerFunc) echo.HandlerFunc {
+	sameUser := OwnerProtected(func(c echo.Context) (string, error) {
+		return c.Param("id"), nil
+	})(next)
+
 	return func(c echo.Context) error {
+		protected := next
+		if config.Conf.Auth.UserAPIRequireSameUseUser {
+			protected = sameUser
+		}
 		if config.Conf.Auth.UserAPIRoutesRequireAuth {
-			return JWTProtected(next)(c)
+			return JWTProtected(protected)(c)
+		}
+		return JWTOptional(protected)(c)
+	}
+}
+
+// jwtSuccessHandler rejects valid tokens that were revoked by logging out or ending their session.
+// It wraps the next handler rather than being echojwt's SuccessHandler, which can't stop the request.
+func jwtSuccessHandler(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user := c.Get("user").(*jwt.Token)
+		claims := user.Claims.(*auth.TokenClaims)
+		if claims.Purpose != "" {
+			return c.JSON(http.StatusUnauthorized, echo.Map{
+				"error": true,
+				"msg":   "Not an access token",
+			})
+		}
+
+		isBlacklisted, err := db.DB.IsTokenBlacklisted(c.Request().Context(), user.Raw)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{
+				"error": true,
+				"msg":   err.Error(),
+			})
+		}
+		if isBlacklisted {
+			return c.JSON(http.StatusUnauthorized, echo.Map{
+				"error": true,
+				"msg":   "Token invalidated",
+			})
+		}
+
+		active, err := auth.CheckSession(c, claims)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{
+				"error": true,
+				"msg":   err.Error(),
+			})
+		}
+		if !active {
+			return c.JSON(http.StatusUnauthorized, echo.Map{
+				"error": true,
+				"msg":   "Session ended",
+			})
 		}
 		return next(c)
 	}
 }
 
-func jwtSuccessHandler(c echo.Context) {
-	user := c.Get("user").(*jwt.Token)
-	isBlacklisted, err := db.DB.IsTokenBlacklisted(c.Request().Context(), user.Raw)
-	if err != nil {
-		_ = c.JSON(http.StatusInternalServerError, echo.Map{
+// personalAccessToken returns the personal access token the request was made with, if any. It's taken from the
+// X-API-Key header, or the Authorization header if it looks like one.
+func personalAccessToken(c echo.Context) (string, bool) {
+	if token := c.Request().Header.Get(auth.APIKeyHeader); token != "" {
+		return token, true
+	}
+	token, _ := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	return token, strings.HasPrefix(token, auth.AccessTokenPrefix)
+}
+
+// accessTokenProtected authenticates the request with a personal access token,
+// and rejects it if the token's scopes don't allow it.
+func accessTokenProtected(c echo.Context, next echo.HandlerFunc, raw string) error {
+	claims, err := auth.AuthenticateAccessToken(c.Request().Context(), raw)
+	if errors.Is(err, auth.ErrInvalidAccessToken) || errors.Is(err, auth.ErrExpiredAccessToken) {
+		return c.JSON(http.StatusUnauthorized, echo.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
 			"error": true,
 			"msg":   err.Error(),
 		})
-		return
 	}
-	if isBlacklisted {
-		_ = c.JSON(http.StatusUnauthorized, echo.Map{
+
+	scope, _ := c.Get(scopeKey).(string)
+	if scope == "" {
+		return c.JSON(http.StatusForbidden, echo.Map{
+			"error": true,
+			"msg":   "Personal access tokens can't be used for this route",
+		})
+	}
+	if !claims.HasScope(scope) {
+		return c.JSON(http.StatusForbidden, echo.Map{
 			"error": true,
-			"msg":   "Token invalidated",
+			"msg":   "Token lacks the " + scope + " scope",
 		})
 	}
+
+	c.Set("user", &jwt.Token{Raw: raw, Claims: claims, Valid: true})
+	return next(c)
 }
 
 func jwtErrorHandler(c echo.Context, err error) error {
@@ -0,0 +1,292 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/server/middleware"
+	"github.com/libramusic/libracore/server/routes/auth"
+)
+
+const userID = "user0000001"
+
+// setup replaces db.DB with an empty SQLite database that has one user.
+func setup(t *testing.T) {
+	t.Helper()
+
+	database := db.Registry["sqlite"].WithDSN(filepath.Join(t.TempDir(), "libra.db"))
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	previous, previousIDLength := db.DB, config.Conf.General.IDLength
+	db.DB = database
+	config.Conf.General.IDLength = 11
+	t.Cleanup(func() {
+		db.DB = previous
+		config.Conf.General.IDLength = previousIDLength
+		database.Close()
+	})
+
+	user := media.DatabaseUser{User: media.User{ID: userID, Username: "alice"}}
+	if err := db.DB.CreateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// createAccessToken creates a personal access token for the user with the scopes and returns it.
+func createAccessToken(t *testing.T, scopes ...string) string {
+	t.Helper()
+
+	body, err := json.Marshal(echo.Map{"name": "test", "scopes": scopes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/tokens", strings.NewReader(string(body)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("user", &jwt.Token{Claims: &auth.TokenClaims{UserID: userID}, Valid: true})
+
+	if err := auth.CreateAccessToken(c); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("creating token: %v, %d %s", err, rec.Code, rec.Body)
+	}
+	var res struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res.Token
+}
+
+func TestScope(t *testing.T) {
+	setup(t)
+
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e := echo.New()
+	e.Group("", middleware.Scope(auth.ScopeLibraryRead)).GET("/read", ok, middleware.JWTProtected)
+	e.Group("", middleware.Scope(auth.ScopeLibraryWrite)).POST("/write", ok, middleware.JWTProtected)
+	e.Group("", middleware.Scope(auth.ScopeAdmin)).POST("/admin", ok, middleware.JWTProtected)
+	e.GET("/unscoped", ok, middleware.JWTProtected)
+
+	read := createAccessToken(t, auth.ScopeLibraryRead)
+	admin := createAccessToken(t, auth.ScopeAdmin)
+
+	tests := []struct {
+		token, method, path string
+		want                int
+	}{
+		{read, http.MethodGet, "/read", http.StatusOK},
+		{read, http.MethodPost, "/write", http.StatusForbidden},
+		{read, http.MethodPost, "/admin", http.StatusForbidden},
+		{admin, http.MethodGet, "/read", http.StatusOK},
+		{admin, http.MethodPost, "/write", http.StatusOK},
+		{admin, http.MethodPost, "/admin", http.StatusOK},
+		// Routes that don't declare a scope can't be used with personal access tokens at all.
+		{read, http.MethodGet, "/unscoped", http.StatusForbidden},
+		{admin, http.MethodGet, "/unscoped", http.StatusForbidden},
+		{auth.AccessTokenPrefix + "unknown", http.MethodGet, "/read", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, http.NoBody)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+test.token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != test.want {
+			t.Errorf("%s %s with %.14s...: got %d, want %d", test.method, test.path, test.token, rec.Code, test.want)
+		}
+	}
+}
+
+// setupSigning signs tokens with HS256 for the test.
+func setupSigning(t *testing.T) {
+	t.Helper()
+
+	previous := config.Conf.Auth.JWT
+	config.Conf.Auth.JWT.SigningMethod = "HS256"
+	config.Conf.Auth.JWT.SigningKey = "test signing key"
+	t.Cleanup(func() { config.Conf.Auth.JWT = previous })
+}
+
+// sessionToken signs an access token of the user for the session.
+func sessionToken(t *testing.T, sessionID string) string {
+	t.Helper()
+
+	conf := config.Conf.Auth.JWT
+	token, err := auth.GenerateToken(userID, sessionID, time.Hour, conf.SigningMethod, conf.SigningKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// addSession records a session of the user that ends at expiration.
+func addSession(t *testing.T, id string, expiration time.Time) {
+	t.Helper()
+
+	session := db.Session{ID: id, UserID: userID, Expiration: expiration.Unix()}
+	if err := db.DB.AddSession(context.Background(), session); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	setup(t)
+	setupSigning(t)
+
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, middleware.JWTProtected)
+
+	addSession(t, "session0001", time.Now().Add(time.Hour))
+	addSession(t, "session0002", time.Now().Add(-time.Minute))
+	addSession(t, "session0003", time.Now().Add(time.Hour))
+	revoked := sessionToken(t, "session0003")
+	if err := db.DB.BlacklistToken(context.Background(), revoked, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, token string
+		want        int
+	}{
+		{"active session", sessionToken(t, "session0001"), http.StatusOK},
+		{"expired session", sessionToken(t, "session0002"), http.StatusUnauthorized},
+		{"ended session", sessionToken(t, "session0004"), http.StatusUnauthorized},
+		{"no session", sessionToken(t, ""), http.StatusUnauthorized},
+		{"blacklisted token", revoked, http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+test.token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, rec.Code, test.want)
+		}
+	}
+}
+
+func TestStreamTicketsAreCheckedWhenRedeemed(t *testing.T) {
+	setup(t)
+	setupSigning(t)
+
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e := echo.New()
+	e.Group("", middleware.Scope(auth.ScopeLibraryRead)).GET("/stream", ok, middleware.StreamJWTProtected)
+
+	ctx := context.Background()
+	addSession(t, "session0001", time.Now().Add(time.Hour))
+	addSession(t, "session0002", time.Now().Add(time.Hour))
+	addSession(t, "session0003", time.Now().Add(time.Hour))
+	ticket := func(raw string, claims *auth.TokenClaims) string {
+		return auth.IssueStreamTicket(&jwt.Token{Raw: raw, Claims: claims, Valid: true})
+	}
+
+	raw := createAccessToken(t, auth.ScopeLibraryRead)
+	claims, err := auth.AuthenticateAccessToken(ctx, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blacklisted := sessionToken(t, "session0003")
+	tickets := []struct {
+		name, ticket string
+		want         int
+	}{
+		{
+			"active session",
+			ticket(sessionToken(t, "session0001"), &auth.TokenClaims{UserID: userID, FamilyID: "session0001"}),
+			http.StatusOK,
+		},
+		{
+			"blacklisted token",
+			ticket(blacklisted, &auth.TokenClaims{UserID: userID, FamilyID: "session0003"}),
+			http.StatusUnauthorized,
+		},
+		{
+			"ended session",
+			ticket(sessionToken(t, "session0002"), &auth.TokenClaims{UserID: userID, FamilyID: "session0002"}),
+			http.StatusUnauthorized,
+		},
+		{"revoked personal access token", ticket(raw, claims), http.StatusUnauthorized},
+	}
+
+	// Everything the tickets were issued for is revoked after they were issued.
+	if err := db.DB.BlacklistToken(ctx, blacklisted, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DB.DeleteSession(ctx, "session0002"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DB.DeleteAccessToken(ctx, claims.AccessTokenID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tickets {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream?ticket="+test.ticket, http.NoBody))
+		if rec.Code != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, rec.Code, test.want)
+		}
+	}
+}
+
+func TestGlobalJWTProtected(t *testing.T) {
+	setup(t)
+	setupSigning(t)
+	previous := config.Conf.Auth.GlobalAPIRoutesRequireAuth
+	t.Cleanup(func() { config.Conf.Auth.GlobalAPIRoutesRequireAuth = previous })
+
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		id, _ := auth.UserIDFromContext(c)
+		return c.String(http.StatusOK, id)
+	}, middleware.GlobalJWTProtected)
+
+	addSession(t, "session0001", time.Now().Add(time.Hour))
+	conf := config.Conf.Auth.JWT
+	expired, err := auth.GenerateToken(userID, "session0001", -time.Minute, conf.SigningMethod, conf.SigningKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, token string
+		required    bool
+		want        int
+		wantUser    string
+	}{
+		{"no token", "", false, http.StatusOK, ""},
+		{"valid token", sessionToken(t, "session0001"), false, http.StatusOK, userID},
+		// A token that is sent has to be valid, even if the route can be used without one.
+		{"invalid token", "invalid", false, http.StatusUnauthorized, ""},
+		{"expired token", expired, false, http.StatusUnauthorized, ""},
+		{"no token when required", "", true, http.StatusUnauthorized, ""},
+		{"valid token when required", sessionToken(t, "session0001"), true, http.StatusOK, userID},
+	}
+	for _, test := range tests {
+		config.Conf.Auth.GlobalAPIRoutesRequireAuth = test.required
+		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		if test.token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+test.token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, rec.Code, test.want)
+		} else if rec.Code == http.StatusOK && rec.Body.String() != test.wantUser {
+			t.Errorf("%s: authenticated as %q, want %q", test.name, rec.Body, test.wantUser)
+		}
+	}
+}
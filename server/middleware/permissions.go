@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/server/routes/auth"
+)
+
+// RequirePermission rejects requests from users whose role doesn't have the permission.
+// It has to come after one of the JWT middlewares, which authenticate the user.
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID, ok := auth.UserIDFromContext(c)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, echo.Map{
+					"error": true,
+					"msg":   "Authentication required",
+				})
+			}
+
+			allowed, err := auth.HasPermission(c.Request().Context(), userID, permission)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, echo.Map{
+					"error": true,
+					"msg":   err.Error(),
+				})
+			}
+			if !allowed {
+				return c.JSON(http.StatusForbidden, echo.Map{
+					"error": true,
+					"msg":   "Missing the " + permission + " permission",
+				})
+			}
+			return next(c)
+		}
+	}
+}
+
+// OwnerProtected only lets the user who owns the requested resource, or users who can manage users, through.
+// ownerOf returns the ID of the resource's owner, or db.ErrNotFound if the resource doesn't exist.
+// It has to come after one of the JWT middlewares, which authenticate the user.
+func OwnerProtected(ownerOf func(c echo.Context) (string, error)) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID, ok := auth.UserIDFromContext(c)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, echo.Map{
+					"error": true,
+					"msg":   "Authentication required",
+				})
+			}
+
+			ownerID, err := ownerOf(c)
+			if errors.Is(err, db.ErrNotFound) {
+				return c.JSON(http.StatusNotFound, echo.Map{
+					"error": true,
+					"msg":   "Not found",
+				})
+			} else if err != nil {
+				return c.JSON(http.StatusInternalServerError, echo.Map{
+					"error": true,
+					"msg":   err.Error(),
+				})
+			}
+
+			allowed, err := auth.CanActFor(c.Request().Context(), userID, ownerID, media.PermissionManageUsers)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, echo.Map{
+					"error": true,
+					"msg":   err.Error(),
+				})
+			}
+			if !allowed {
+				return c.JSON(http.StatusForbidden, echo.Map{
+					"error": true,
+					"msg":   "Not allowed to access another user's data",
+				})
+			}
+			return next(c)
+		}
+	}
+}
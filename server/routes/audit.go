@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/db"
+)
+
+const (
+	defaultAuditLogLimit = 50
+	maxAuditLogLimit     = 500
+)
+
+// @Summary	Get the audit log
+// @Description	Requires the manage_users permission. The audit log records security-relevant events,
+// @Description	such as accounts and IP addresses being locked out after too many failed logins.
+// @ID			getAuditLog
+// @Param		limit	query		int	false	"Maximum number of events to return"	default(50)
+// @Param		offset	query		int	false	"Number of events to skip"				default(0)
+// @Success	200		{array}		db.AuditEvent
+// @Success	200		"Returns the events, most recent first"
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	403		{object}	any
+// @Failure	500		{object}	any
+// @Router		/audit-log [get]
+func V1AuditLog(c echo.Context) error {
+	limit, offset, err := paginationParams(c, defaultAuditLogLimit, maxAuditLogLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
+	}
+
+	events, err := db.DB.AuditEvents(c.Request().Context(), limit, offset)
+	if err != nil {
+		log.Error("Error getting audit log", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve audit log"})
+	}
+	if events == nil {
+		events = []db.AuditEvent{}
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"events": events,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+)
+
+// AccessTokenPrefix starts every personal access token, which tells them apart from JWTs
+// and makes them easy to find if they're leaked.
+const AccessTokenPrefix = "libra_pat_"
+
+// APIKeyHeader is the header personal access tokens can be sent in instead of the Authorization header.
+const APIKeyHeader = "X-API-Key"
+
+// Scopes of personal access tokens.
+const (
+	ScopeLibraryRead  = "library:read"
+	ScopeLibraryWrite = "library:write"
+	ScopeStream       = "stream"
+
+	// Allows everything, including managing the account's sessions, second factors and tokens.
+	ScopeAdmin = "admin"
+)
+
+var accessTokenScopes = []string{ScopeLibraryRead, ScopeLibraryWrite, ScopeStream, ScopeAdmin}
+
+var (
+	ErrInvalidAccessToken = errors.New("invalid personal access token")
+	ErrExpiredAccessToken = errors.New("personal access token expired")
+)
+
+type createAccessTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+
+	// How long until the token expires, such as "90d". The token never expires if it's empty.
+	ExpiresIn string `json:"expires_in"`
+}
+
+type accessTokenResponse struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Scopes       []string `json:"scopes"`
+	CreationDate int64    `json:"creation_date"`
+	Expiration   int64    `json:"expiration"`
+	LastUsed     int64    `json:"last_used"`
+}
+
+type createAccessTokenResponse struct {
+	accessTokenResponse
+
+	// The token itself. It's only returned when the token is created.
+	Token string `json:"token"`
+}
+
+// AccessTokens lists the user's personal access tokens.
+func AccessTokens(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+
+	tokens, err := db.DB.UserAccessTokens(c.Request().Context(), claims.UserID)
+	if err != nil {
+		log.Error("Error getting personal access tokens", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	res := make([]accessTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		res = append(res, newAccessTokenResponse(token))
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// CreateAccessToken creates a personal access token for the user with the given name, scopes and expiry.
+func CreateAccessToken(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+
+	var req createAccessTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "name is required",
+		})
+	}
+	if len(req.Scopes) == 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "at least one scope is required",
+		})
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(accessTokenScopes, scope) {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"message": "unknown scope " + scope + ", expected one of " + strings.Join(accessTokenScopes, ", "),
+			})
+		}
+		// A token can't give more access than the one that created it.
+		if !claims.HasScope(scope) {
+			return c.JSON(http.StatusForbidden, echo.Map{
+				"message": "cannot grant the " + scope + " scope",
+			})
+		}
+	}
+
+	now := time.Now()
+	var expiration int64
+	if req.ExpiresIn != "" {
+		expiresIn, err := config.ParseDuration(req.ExpiresIn)
+		if err != nil || expiresIn <= 0 {
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"message": "invalid expires_in",
+			})
+		}
+		expiration = now.Add(expiresIn).Unix()
+	}
+
+	raw := AccessTokenPrefix + rand.Text()
+	token := db.AccessToken{
+		ID:           media.GenerateID(config.Conf.General.IDLength),
+		UserID:       claims.UserID,
+		Name:         req.Name,
+		TokenHash:    HashToken(raw),
+		Scopes:       slices.Compact(slices.Sorted(slices.Values(req.Scopes))),
+		CreationDate: now.Unix(),
+		Expiration:   expiration,
+	}
+	if err := db.DB.AddAccessToken(c.Request().Context(), token); err != nil {
+		log.Error("Error storing personal access token", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	return c.JSON(http.StatusCreated, createAccessTokenResponse{
+		accessTokenResponse: newAccessTokenResponse(token),
+		Token:               raw,
+	})
+}
+
+// RevokeAccessToken deletes one of the user's personal access tokens, which stops it from working immediately.
+func RevokeAccessToken(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+	ctx := c.Request().Context()
+
+	token, err := db.DB.AccessToken(ctx, c.Param("id"))
+	if errors.Is(err, db.ErrNotFound) || (err == nil && token.UserID != claims.UserID) {
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"message": "personal access token not found",
+		})
+	} else if err != nil {
+		log.Error("Error getting personal access token", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	if err := db.DB.DeleteAccessToken(ctx, token.ID); err != nil {
+		log.Error("Error deleting personal access token", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// AuthenticateAccessToken returns the claims of a request authenticated with the personal access token raw,
+// and records that the token was used.
+func AuthenticateAccessToken(ctx context.Context, raw string) (*TokenClaims, error) {
+	token, err := db.DB.AccessTokenByHash(ctx, HashToken(raw))
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, ErrInvalidAccessToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.Expiration != 0 && now.Unix() >= token.Expiration {
+		return nil, ErrExpiredAccessToken
+	}
+	if _, err := db.DB.User(ctx, token.UserID); errors.Is(err, db.ErrNotFound) {
+		return nil, ErrInvalidAccessToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	if now.Sub(time.Unix(token.LastUsed, 0)) >= activityInterval {
+		token.LastUsed = now.Unix()
+		if err := db.DB.UpdateAccessToken(ctx, token); err != nil {
+			log.Warn("Error recording personal access token use", "err", err, "userID", token.UserID)
+		}
+	}
+
+	return &TokenClaims{
+		UserID:        token.UserID,
+		AccessTokenID: token.ID,
+		Scopes:        token.Scopes,
+	}, nil
+}
+
+func newAccessTokenResponse(token db.AccessToken) accessTokenResponse {
+	return accessTokenResponse{
+		ID:           token.ID,
+		Name:         token.Name,
+		Scopes:       token.Scopes,
+		CreationDate: token.CreationDate,
+		Expiration:   token.Expiration,
+		LastUsed:     token.LastUsed,
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"net/url"
 	"slices"
 	"strings"
 
@@ -15,6 +16,7 @@ import (
 
 	"github.com/libramusic/libracore/config"
 	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/mail"
 	"github.com/libramusic/libracore/media"
 )
 
@@ -24,11 +26,20 @@ type registerRequest struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
+
+	// The name of the device, shown in the list of sessions.
+	DeviceName string `json:"device_name"`
 }
 
 type loginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+
+	// The solution of a CAPTCHA, required after too many failed logins if the server has a CAPTCHA verifier.
+	Captcha string `json:"captcha"`
+
+	// The name of the device, shown in the list of sessions.
+	DeviceName string `json:"device_name"`
 }
 
 func Register(c echo.Context) error {
@@ -51,6 +62,10 @@ func Register(c echo.Context) error {
 		})
 	}
 
+	if err := validatePassword(req.Password, req.Username); err != nil {
+		return passwordRejected(c, err)
+	}
+
 	ctx := c.Request().Context()
 
 	usernameExists, err := db.DB.UsernameExists(ctx, req.Username)
@@ -81,36 +96,43 @@ func Register(c echo.Context) error {
 		}
 	}
 
+	passwordHash, err := generatePassword(req.Password)
+	if err != nil {
+		log.Error("Error hashing password", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
 	user := media.DatabaseUser{
 		User: media.User{
 			ID:       media.GenerateID(config.Conf.General.IDLength),
 			Username: req.Username,
 			Email:    req.Email,
 		},
-		PasswordHash: generatePassword(req.Password),
+		PasswordHash: passwordHash,
 	}
-	err = db.DB.CreateUser(ctx, user)
+	err = createUser(ctx, user)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{
 			"message": err.Error(),
 		})
 	}
 
-	token, err := GenerateToken(
-		user.ID,
-		config.Conf.Auth.JWT.AccessTokenExpiration,
-		config.Conf.Auth.JWT.SigningMethod,
-		config.Conf.Auth.JWT.SigningKey,
-	)
+	if user.Email != "" && mail.Enabled() {
+		if err := sendEmailToken(ctx, user, emailTokenVerify, user.Email); err != nil {
+			log.Error("Error sending verification email", "err", err, "userID", user.ID)
+		}
+	}
+
+	tokens, err := startSession(c, user.ID, req.DeviceName)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{
 			"message": err.Error(),
 		})
 	}
 
-	return c.JSON(http.StatusOK, echo.Map{
-		"token": token,
-	})
+	return c.JSON(http.StatusOK, tokens)
 }
 
 func Login(c echo.Context) error {
@@ -124,36 +146,82 @@ func Login(c echo.Context) error {
 	ctx := c.Request().Context()
 
 	user, err := db.DB.UserByUsername(ctx, req.Username)
-	if errors.Is(err, db.ErrNotFound) {
-		return c.JSON(http.StatusNotFound, echo.Map{
-			"message": "user not found",
-		})
-	} else if err != nil {
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
 		return c.JSON(http.StatusInternalServerError, echo.Map{
 			"message": err.Error(),
 		})
 	}
+
+	account, ip := loginAccountKey(user, req.Username), c.RealIP()
+	if wait := loginWait(account, ip); wait > 0 {
+		return loginThrottled(c, wait)
+	}
+	if captchaRequired(account, ip) {
+		if req.Captcha == "" {
+			return c.JSON(http.StatusUnauthorized, echo.Map{
+				"message":          "captcha required",
+				"captcha_required": true,
+			})
+		}
+		valid, err := CaptchaVerifier(ctx, req.Captcha, ip)
+		if err != nil {
+			log.Error("Error verifying captcha", "err", err)
+			return c.JSON(http.StatusInternalServerError, echo.Map{
+				"message": "internal server error",
+			})
+		}
+		if !valid {
+			return c.JSON(http.StatusUnauthorized, echo.Map{
+				"message":          "invalid captcha",
+				"captcha_required": true,
+			})
+		}
+	}
+
+	// Users that don't exist are compared against a dummy hash, so they take as long to reject as wrong passwords.
+	if user.ID == "" {
+		comparePassword(dummyPasswordHash(), req.Password)
+		return loginFailed(c, user, req.Username)
+	}
 	if !comparePassword(user.PasswordHash, req.Password) {
-		return c.JSON(http.StatusUnauthorized, echo.Map{
-			"message": "incorrect password",
+		return loginFailed(c, user, req.Username)
+	}
+	if passwordNeedsRehash(user.PasswordHash) {
+		rehashPassword(ctx, user, req.Password)
+	}
+
+	// Users with two-factor authentication get a token to send along with their second factor instead.
+	// Their failed logins are only forgotten once that is verified too.
+	methods, err := mfaMethods(ctx, user.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": err.Error(),
+		})
+	}
+	if len(methods) > 0 {
+		mfaToken, err := generateMFAToken(user.ID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(http.StatusOK, echo.Map{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+			"mfa_methods":  methods,
 		})
 	}
 
-	token, err := GenerateToken(
-		user.ID,
-		config.Conf.Auth.JWT.AccessTokenExpiration,
-		config.Conf.Auth.JWT.SigningMethod,
-		config.Conf.Auth.JWT.SigningKey,
-	)
+	recordLoginSuccess(account)
+
+	tokens, err := startSession(c, user.ID, req.DeviceName)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{
 			"message": err.Error(),
 		})
 	}
 
-	return c.JSON(http.StatusOK, echo.Map{
-		"token": token,
-	})
+	return c.JSON(http.StatusOK, tokens)
 }
 
 func LoginProvider(c echo.Context) error {
@@ -196,6 +264,33 @@ func Logout(c echo.Context) error {
 			"message": err.Error(),
 		})
 	}
+
+	// End the session the access token belongs to, and the one of the refresh token in the body,
+	// so neither can be continued by refreshing.
+	if claims.FamilyID != "" {
+		if err := endSession(ctx, claims.FamilyID); err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{
+				"message": err.Error(),
+			})
+		}
+	}
+	var req refreshRequest
+	if err := c.Bind(&req); err == nil && req.RefreshToken != "" {
+		stored, err := db.DB.RefreshToken(ctx, HashToken(req.RefreshToken))
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			return c.JSON(http.StatusInternalServerError, echo.Map{
+				"message": err.Error(),
+			})
+		}
+		if err == nil && stored.UserID == claims.UserID && stored.FamilyID != claims.FamilyID {
+			if err := endSession(ctx, stored.FamilyID); err != nil {
+				return c.JSON(http.StatusInternalServerError, echo.Map{
+					"message": err.Error(),
+				})
+			}
+		}
+	}
+
 	c.Set("user", nil)
 	return c.NoContent(http.StatusOK)
 }
@@ -254,19 +349,33 @@ func handleExistingProviderUser(c echo.Context, user media.DatabaseUser) error {
 		})
 	}
 
-	token, err := GenerateToken(
-		user.ID,
-		config.Conf.Auth.JWT.AccessTokenExpiration,
-		config.Conf.Auth.JWT.SigningMethod,
-		config.Conf.Auth.JWT.SigningKey,
-	)
+	methods, err := mfaMethods(c.Request().Context(), user.ID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{
 			"message": err.Error(),
 		})
 	}
+	if len(methods) > 0 {
+		mfaToken, err := generateMFAToken(user.ID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, echo.Map{
+				"message": err.Error(),
+			})
+		}
+		query := url.Values{}
+		query.Set("mfa_token", mfaToken)
+		query.Set("mfa_methods", strings.Join(methods, ","))
+		return c.Redirect(http.StatusFound, appendQuery(redirectURI, query.Encode()))
+	}
 
-	redirectURL := buildRedirectURL(redirectURI, token)
+	tokens, err := startSession(c, user.ID, "")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	redirectURL := buildRedirectURL(redirectURI, tokens)
 	return c.Redirect(http.StatusFound, redirectURL)
 }
 
@@ -282,7 +391,7 @@ func handleNewProviderUser(ctx context.Context, c echo.Context, providerUser got
 
 	// TODO: Profile picture.
 
-	if err := db.DB.CreateUser(ctx, newUser); err != nil {
+	if err := createUser(ctx, newUser); err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{
 			"message": err.Error(),
 		})
@@ -301,27 +410,32 @@ func handleNewProviderUser(ctx context.Context, c echo.Context, providerUser got
 		})
 	}
 
-	token, err := GenerateToken(
-		newUser.ID,
-		config.Conf.Auth.JWT.AccessTokenExpiration,
-		config.Conf.Auth.JWT.SigningMethod,
-		config.Conf.Auth.JWT.SigningKey,
-	)
+	tokens, err := startSession(c, newUser.ID, "")
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, echo.Map{
 			"message": err.Error(),
 		})
 	}
 
-	redirectURL := buildRedirectURL(redirectURI, token)
+	redirectURL := buildRedirectURL(redirectURI, tokens)
 	return c.Redirect(http.StatusFound, redirectURL)
 }
 
-func buildRedirectURL(redirectURI, token string) string {
-	if strings.Contains(redirectURI, "?") {
-		redirectURI += "&token=" + token
+// buildRedirectURL adds the access token and a code to exchange for the refresh token to the redirect URI.
+// The refresh token itself isn't put in the URL, since it lasts for weeks.
+func buildRedirectURL(redirectURI string, tokens tokenResponse) string {
+	query := url.Values{}
+	query.Set("token", tokens.Token)
+	query.Set("code", issueLoginCode(tokens))
+	return appendQuery(redirectURI, query.Encode())
+}
+
+// appendQuery adds the encoded query to the query the URI may already have.
+func appendQuery(uri, query string) string {
+	if strings.Contains(uri, "?") {
+		return uri + "&" + query
 	}
-	return redirectURI + "?token=" + token
+	return uri + "?" + query
 }
 
 func DisconnectProvider(c echo.Context) error {
@@ -0,0 +1,585 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/mail"
+	"github.com/libramusic/libracore/media"
+)
+
+// Purposes of email tokens. Each is also the name of the template of the email the token is sent in.
+const (
+	emailTokenVerify        = mail.TemplateVerifyEmail
+	emailTokenPasswordReset = mail.TemplatePasswordReset
+	emailTokenChangeEmail   = mail.TemplateChangeEmail
+)
+
+// The paths of the web client's pages that links in emails open, with the token in the token query parameter.
+// The pages send the token to the matching API route.
+var emailLinkPaths = map[string]string{
+	emailTokenVerify:        "/verify-email",
+	emailTokenPasswordReset: "/reset-password",
+	emailTokenChangeEmail:   "/confirm-email",
+}
+
+var ErrInvalidEmailToken = errors.New("invalid or expired token")
+
+// How long has to pass after a link is mailed to a user before another one can be mailed to them for the same purpose,
+// so the routes that send links can't be used to flood someone's inbox.
+const emailTokenCooldown = time.Minute
+
+// When a link was last mailed for each user and purpose. It's only kept in memory, like failed logins.
+var (
+	emailTokensSent   = map[string]time.Time{}
+	emailTokensSentMu sync.Mutex
+)
+
+// emailCooldownError is returned by sendEmailToken when a link was mailed to the user for the purpose too recently.
+type emailCooldownError struct {
+	wait time.Duration
+}
+
+func (e emailCooldownError) Error() string {
+	return "a link was mailed too recently"
+}
+
+type emailStatusResponse struct {
+	Email    string `json:"email"`
+	Verified bool   `json:"verified"`
+}
+
+type emailTokenRequest struct {
+	Token string `json:"token"`
+}
+
+type forgotPasswordRequest struct {
+	// The username or email address of the account.
+	Username string `json:"username"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type changeEmailRequest struct {
+	Email string `json:"email"`
+
+	// The user's current password. Users without one, who log in through a provider, can leave it empty.
+	Password string `json:"password"`
+}
+
+// EmailStatus returns the user's email address and whether it's verified.
+func EmailStatus(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+	ctx := c.Request().Context()
+
+	user, err := db.DB.User(ctx, claims.UserID)
+	if err != nil {
+		log.Error("Error getting user", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	verified, err := isEmailVerified(ctx, user)
+	if err != nil {
+		log.Error("Error getting email verification", "err", err, "userID", user.ID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	return c.JSON(http.StatusOK, emailStatusResponse{Email: user.Email, Verified: verified})
+}
+
+// SendVerificationEmail mails the user a new link to verify their email address.
+func SendVerificationEmail(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+	ctx := c.Request().Context()
+
+	if !mail.Enabled() {
+		return c.JSON(http.StatusServiceUnavailable, echo.Map{
+			"message": "email is not configured on this server",
+		})
+	}
+
+	user, err := db.DB.User(ctx, claims.UserID)
+	if err != nil {
+		log.Error("Error getting user", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	if user.Email == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "no email address to verify",
+		})
+	}
+	verified, err := isEmailVerified(ctx, user)
+	if err != nil {
+		log.Error("Error getting email verification", "err", err, "userID", user.ID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	if verified {
+		return c.JSON(http.StatusConflict, echo.Map{
+			"message": "email address is already verified",
+		})
+	}
+
+	var cooldown emailCooldownError
+	if err := sendEmailToken(ctx, user, emailTokenVerify, user.Email); errors.As(err, &cooldown) {
+		return emailThrottled(c, cooldown.wait)
+	} else if err != nil {
+		log.Error("Error sending verification email", "err", err, "userID", user.ID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	return c.NoContent(http.StatusAccepted)
+}
+
+// VerifyEmail verifies the email address a verification link was sent to.
+func VerifyEmail(c echo.Context) error {
+	var req emailTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	ctx := c.Request().Context()
+	token, user, err := useEmailToken(ctx, req.Token, emailTokenVerify)
+	if errors.Is(err, ErrInvalidEmailToken) {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	} else if err != nil {
+		log.Error("Error using email token", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	if err := setEmailVerified(ctx, user.ID, token.Email); err != nil {
+		log.Error("Error verifying email", "err", err, "userID", user.ID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	return c.JSON(http.StatusOK, emailStatusResponse{Email: token.Email, Verified: true})
+}
+
+// ForgotPassword mails a password reset link to the account with the given username or email address.
+// It responds the same whether or not the account exists, so it can't be used to find out which ones do.
+func ForgotPassword(c echo.Context) error {
+	var req forgotPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+	if !mail.Enabled() {
+		return c.JSON(http.StatusServiceUnavailable, echo.Map{
+			"message": "email is not configured on this server",
+		})
+	}
+
+	ctx := c.Request().Context()
+	user, err := db.DB.UserByUsername(ctx, strings.TrimSpace(req.Username))
+	if errors.Is(err, db.ErrNotFound) || (err == nil && user.Email == "") {
+		return c.NoContent(http.StatusAccepted)
+	} else if err != nil {
+		log.Error("Error getting user", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	// A link that was just sent isn't sent again, but the response doesn't tell, so it doesn't reveal the account.
+	if err := sendEmailToken(ctx, user, emailTokenPasswordReset, user.Email); errors.As(err, &emailCooldownError{}) {
+		return c.NoContent(http.StatusAccepted)
+	} else if err != nil {
+		log.Error("Error sending password reset email", "err", err, "userID", user.ID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	return c.NoContent(http.StatusAccepted)
+}
+
+// ResetPassword sets a new password with the token from a password reset link, and logs the user out everywhere.
+func ResetPassword(c echo.Context) error {
+	var req resetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+	if req.Password == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "password is required",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	// Check the new password before using up the token, so the link can be used again with another password.
+	_, user, err := findEmailToken(ctx, req.Token, emailTokenPasswordReset)
+	if errors.Is(err, ErrInvalidEmailToken) {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	} else if err != nil {
+		log.Error("Error finding email token", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	if err := validatePassword(req.Password, user.Username); err != nil {
+		return passwordRejected(c, err)
+	}
+
+	token, user, err := useEmailToken(ctx, req.Token, emailTokenPasswordReset)
+	if errors.Is(err, ErrInvalidEmailToken) {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	} else if err != nil {
+		log.Error("Error using email token", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	user.PasswordHash, err = generatePassword(req.Password)
+	if err != nil {
+		log.Error("Error hashing password", "err", err, "userID", user.ID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	if err := db.DB.UpdateUser(ctx, user); err != nil {
+		log.Error("Error updating password", "err", err, "userID", user.ID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	// The password has already changed, so failing to log out other sessions doesn't fail the request.
+	if err := endUserSessions(ctx, user.ID, ""); err != nil {
+		log.Error("Error ending sessions", "err", err, "userID", user.ID)
+	}
+	// Following the link proved that the user can read mail sent to the address.
+	if err := setEmailVerified(ctx, user.ID, token.Email); err != nil {
+		log.Warn("Error verifying email", "err", err, "userID", user.ID)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// ChangeEmail mails a link to the new email address to confirm it. The address only changes once it's confirmed.
+func ChangeEmail(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+
+	var req changeEmailRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+	if !mail.Enabled() {
+		return c.JSON(http.StatusServiceUnavailable, echo.Map{
+			"message": "email is not configured on this server",
+		})
+	}
+
+	req.Email = strings.TrimSpace(req.Email)
+	if address, err := netmail.ParseAddress(req.Email); err != nil || address.Address != req.Email {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "invalid email address",
+		})
+	}
+
+	ctx := c.Request().Context()
+	user, err := db.DB.User(ctx, claims.UserID)
+	if err != nil {
+		log.Error("Error getting user", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	if user.PasswordHash != "" && !comparePassword(user.PasswordHash, req.Password) {
+		return c.JSON(http.StatusUnauthorized, echo.Map{
+			"message": "incorrect password",
+		})
+	}
+	if strings.EqualFold(req.Email, user.Email) {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "that is already your email address",
+		})
+	}
+	emailExists, err := db.DB.EmailExists(ctx, req.Email)
+	if err != nil {
+		log.Error("Error checking if email exists", "err", err, "userID", user.ID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	if emailExists {
+		return c.JSON(http.StatusConflict, echo.Map{
+			"message": "email already exists",
+		})
+	}
+
+	var cooldown emailCooldownError
+	if err := sendEmailToken(ctx, user, emailTokenChangeEmail, req.Email); errors.As(err, &cooldown) {
+		return emailThrottled(c, cooldown.wait)
+	} else if err != nil {
+		log.Error("Error sending email change confirmation", "err", err, "userID", user.ID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	return c.NoContent(http.StatusAccepted)
+}
+
+// ConfirmEmailChange changes the user's email address to the one a confirmation link was sent to.
+func ConfirmEmailChange(c echo.Context) error {
+	var req emailTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	ctx := c.Request().Context()
+	token, user, err := useEmailToken(ctx, req.Token, emailTokenChangeEmail)
+	if errors.Is(err, ErrInvalidEmailToken) {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	} else if err != nil {
+		log.Error("Error using email token", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	// Another account may have taken the address since the link was sent.
+	emailExists, err := db.DB.EmailExists(ctx, token.Email)
+	if err != nil {
+		log.Error("Error checking if email exists", "err", err, "userID", user.ID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	if emailExists {
+		return c.JSON(http.StatusConflict, echo.Map{
+			"message": "email already exists",
+		})
+	}
+
+	user.Email = token.Email
+	if err := db.DB.UpdateUser(ctx, user); err != nil {
+		log.Error("Error updating email", "err", err, "userID", user.ID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	if err := setEmailVerified(ctx, user.ID, token.Email); err != nil {
+		log.Error("Error verifying email", "err", err, "userID", user.ID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	// Links sent to the old address no longer apply.
+	for _, purpose := range []string{emailTokenVerify, emailTokenPasswordReset} {
+		if err := db.DB.DeleteEmailTokens(ctx, user.ID, purpose); err != nil {
+			log.Warn("Error deleting email tokens", "err", err, "userID", user.ID)
+		}
+	}
+	return c.JSON(http.StatusOK, emailStatusResponse{Email: user.Email, Verified: true})
+}
+
+// sendEmailToken creates a token for the purpose and mails a link with it to the address email.
+// Only the latest link for each purpose works. The email is sent in the background, so failing to
+// deliver it is only logged. It returns an emailCooldownError if a link for the purpose was mailed to the user
+// less than emailTokenCooldown ago.
+func sendEmailToken(ctx context.Context, user media.DatabaseUser, purpose, email string) (err error) {
+	if wait := reserveEmailToken(user.ID, purpose, time.Now()); wait > 0 {
+		return emailCooldownError{wait: wait}
+	}
+	defer func() {
+		// Nothing was mailed, so another link can be requested right away.
+		if err != nil {
+			emailTokensSentMu.Lock()
+			delete(emailTokensSent, user.ID+":"+purpose)
+			emailTokensSentMu.Unlock()
+		}
+	}()
+
+	expiresIn := config.Conf.Auth.EmailVerificationExpiration
+	if purpose == emailTokenPasswordReset {
+		expiresIn = config.Conf.Auth.PasswordResetExpiration
+	}
+
+	if err := db.DB.DeleteEmailTokens(ctx, user.ID, purpose); err != nil {
+		return err
+	}
+
+	raw := rand.Text()
+	now := time.Now()
+	err = db.DB.AddEmailToken(ctx, db.EmailToken{
+		ID:           media.GenerateID(config.Conf.General.IDLength),
+		UserID:       user.ID,
+		Purpose:      purpose,
+		TokenHash:    HashToken(raw),
+		Email:        email,
+		CreationDate: now.Unix(),
+		Expiration:   now.Add(expiresIn).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	msg, err := mail.Render(purpose, email, mail.TemplateData{
+		Username:   user.Username,
+		ServerName: config.Conf.Application.SourceName,
+		Link:       emailLink(purpose, raw),
+		ExpiresIn:  expiresIn,
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := mail.Send(context.WithoutCancel(ctx), msg); err != nil {
+			log.Error("Error sending email", "err", err, "userID", user.ID, "template", purpose)
+		}
+	}()
+	return nil
+}
+
+// reserveEmailToken records that a link for the purpose is being mailed to the user at now.
+// If one was mailed less than emailTokenCooldown ago, it records nothing and returns how long is left to wait.
+func reserveEmailToken(userID, purpose string, now time.Time) time.Duration {
+	emailTokensSentMu.Lock()
+	defer emailTokensSentMu.Unlock()
+
+	key := userID + ":" + purpose
+	if wait := emailTokensSent[key].Add(emailTokenCooldown).Sub(now); wait > 0 {
+		return wait
+	}
+	emailTokensSent[key] = now
+	return 0
+}
+
+// emailThrottled responds that a link was mailed too recently and when another one can be requested.
+func emailThrottled(c echo.Context, wait time.Duration) error {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+	return c.JSON(http.StatusTooManyRequests, echo.Map{
+		"message":     "a link was mailed recently, try again later",
+		"retry_after": seconds,
+	})
+}
+
+// findEmailToken returns the email token raw and its user without using the token up.
+// It returns ErrInvalidEmailToken if the token doesn't exist, has expired or is for another purpose,
+// or if the user's address changed since a verification or password reset link was sent.
+func findEmailToken(ctx context.Context, raw, purpose string) (db.EmailToken, media.DatabaseUser, error) {
+	var user media.DatabaseUser
+
+	token, err := db.DB.EmailTokenByHash(ctx, HashToken(raw))
+	if errors.Is(err, db.ErrNotFound) {
+		return token, user, ErrInvalidEmailToken
+	} else if err != nil {
+		return token, user, err
+	}
+	if token.Purpose != purpose || time.Now().Unix() >= token.Expiration {
+		return token, user, ErrInvalidEmailToken
+	}
+
+	user, err = db.DB.User(ctx, token.UserID)
+	if errors.Is(err, db.ErrNotFound) {
+		return token, user, ErrInvalidEmailToken
+	} else if err != nil {
+		return token, user, err
+	}
+	if purpose != emailTokenChangeEmail && !strings.EqualFold(user.Email, token.Email) {
+		return token, user, ErrInvalidEmailToken
+	}
+	return token, user, nil
+}
+
+// useEmailToken is like findEmailToken, but also deletes the token so it can't be used again.
+func useEmailToken(ctx context.Context, raw, purpose string) (db.EmailToken, media.DatabaseUser, error) {
+	token, user, err := findEmailToken(ctx, raw, purpose)
+	if err != nil {
+		return token, user, err
+	}
+
+	used, err := db.DB.UseEmailToken(ctx, token.ID)
+	if err != nil {
+		return token, user, err
+	}
+	if !used {
+		return token, user, ErrInvalidEmailToken
+	}
+	return token, user, nil
+}
+
+// isEmailVerified reports whether the user verified their current email address.
+func isEmailVerified(ctx context.Context, user media.DatabaseUser) (bool, error) {
+	if user.Email == "" {
+		return false, nil
+	}
+	verification, err := db.DB.EmailVerification(ctx, user.ID)
+	if errors.Is(err, db.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(verification.Email, user.Email), nil
+}
+
+func setEmailVerified(ctx context.Context, userID, email string) error {
+	return db.DB.SetEmailVerification(ctx, db.EmailVerification{
+		UserID:           userID,
+		Email:            email,
+		VerificationDate: time.Now().Unix(),
+	})
+}
+
+// emailLink returns the link to the web client's page for the purpose with the token.
+func emailLink(purpose, token string) string {
+	return strings.TrimSuffix(config.Conf.Application.PublicURL, "/") + emailLinkPaths[purpose] +
+		"?token=" + url.QueryEscape(token)
+}
+
+// sweepEmailTokensSent forgets when links were mailed once another can be.
+func sweepEmailTokensSent(now time.Time) {
+	emailTokensSentMu.Lock()
+	defer emailTokensSentMu.Unlock()
+	for key, sent := range emailTokensSent {
+		if now.Sub(sent) >= emailTokenCooldown {
+			delete(emailTokensSent, key)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReserveEmailToken(t *testing.T) {
+	t.Cleanup(func() {
+		emailTokensSentMu.Lock()
+		defer emailTokensSentMu.Unlock()
+		clear(emailTokensSent)
+	})
+
+	now := time.Now()
+	if wait := reserveEmailToken("alice", emailTokenPasswordReset, now); wait != 0 {
+		t.Fatalf("first link waits %v", wait)
+	}
+	if wait := reserveEmailToken("alice", emailTokenPasswordReset, now.Add(20*time.Second)); wait != 40*time.Second {
+		t.Errorf("link 20s later waits %v, want 40s", wait)
+	}
+	// The cooldown is per user and purpose.
+	if wait := reserveEmailToken("alice", emailTokenVerify, now); wait != 0 {
+		t.Errorf("verification link after a reset link waits %v", wait)
+	}
+	if wait := reserveEmailToken("bob", emailTokenPasswordReset, now); wait != 0 {
+		t.Errorf("reset link to another user waits %v", wait)
+	}
+	if wait := reserveEmailToken("alice", emailTokenPasswordReset, now.Add(emailTokenCooldown)); wait != 0 {
+		t.Errorf("link after the cooldown waits %v", wait)
+	}
+
+	sweepEmailTokensSent(now.Add(emailTokenCooldown))
+	emailTokensSentMu.Lock()
+	defer emailTokensSentMu.Unlock()
+	if _, ok := emailTokensSent["bob:"+emailTokenPasswordReset]; ok {
+		t.Error("link sent before the cooldown is kept")
+	}
+	if _, ok := emailTokensSent["alice:"+emailTokenPasswordReset]; !ok {
+		t.Error("link still in its cooldown is forgotten")
+	}
+}
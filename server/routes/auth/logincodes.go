@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"crypto/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// How long the code a provider login redirects with can be exchanged for the refresh token.
+const loginCodeExpiration = time.Minute
+
+// Refresh tokens of provider logins waiting to be picked up with the code the login redirected with.
+// Refresh tokens last for weeks, so they're never put in the redirect URL, where they would end up
+// in browser history, logs and Referer headers. Codes are only kept in memory and can only be used once.
+var (
+	loginCodes   = map[string]loginCode{}
+	loginCodesMu sync.Mutex
+)
+
+type loginCode struct {
+	tokens     tokenResponse
+	expiration time.Time
+}
+
+type exchangeLoginCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// ExchangeLoginCode returns the access token and refresh token of the provider login that redirected with the code.
+func ExchangeLoginCode(c echo.Context) error {
+	var req exchangeLoginCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	tokens, ok := redeemLoginCode(req.Code)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{
+			"message": "invalid or expired code",
+		})
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// issueLoginCode returns a code that can be exchanged for the tokens once, within loginCodeExpiration.
+func issueLoginCode(tokens tokenResponse) string {
+	code := rand.Text()
+
+	loginCodesMu.Lock()
+	defer loginCodesMu.Unlock()
+	loginCodes[code] = loginCode{tokens: tokens, expiration: time.Now().Add(loginCodeExpiration)}
+	return code
+}
+
+// redeemLoginCode uses up the code and returns the tokens it was issued for.
+func redeemLoginCode(code string) (tokenResponse, bool) {
+	loginCodesMu.Lock()
+	defer loginCodesMu.Unlock()
+
+	c, ok := loginCodes[code]
+	if !ok {
+		return tokenResponse{}, false
+	}
+	delete(loginCodes, code)
+	if time.Now().After(c.expiration) {
+		return tokenResponse{}, false
+	}
+	return c.tokens, true
+}
+
+// sweepLoginCodes forgets expired login codes that were never redeemed.
+func sweepLoginCodes(now time.Time) {
+	loginCodesMu.Lock()
+	defer loginCodesMu.Unlock()
+	for code, c := range loginCodes {
+		if now.After(c.expiration) {
+			delete(loginCodes, code)
+		}
+	}
+}
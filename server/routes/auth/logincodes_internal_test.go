@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestBuildRedirectURL(t *testing.T) {
+	t.Cleanup(func() {
+		loginCodesMu.Lock()
+		defer loginCodesMu.Unlock()
+		clear(loginCodes)
+	})
+
+	tokens := tokenResponse{Token: "access+token&x=1", RefreshToken: "refresh"}
+	redirect, err := url.Parse(buildRedirectURL("https://app.example.com/done?from=login", tokens))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := redirect.Query()
+	if query.Get("from") != "login" || query.Get("token") != tokens.Token || query.Has("x") {
+		t.Errorf("redirect query is %v", query)
+	}
+	if strings.Contains(redirect.String(), tokens.RefreshToken) {
+		t.Errorf("refresh token is in the redirect URL %s", redirect)
+	}
+
+	exchange := func(code string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/exchange", strings.NewReader(`{"code":"`+code+`"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		if err := ExchangeLoginCode(echo.New().NewContext(req, rec)); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+	code := query.Get("code")
+	rec := exchange(code)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"refresh_token":"refresh"`) {
+		t.Errorf("exchanging the code: %d %s", rec.Code, rec.Body)
+	}
+	if rec := exchange(code); rec.Code != http.StatusUnauthorized {
+		t.Errorf("exchanging the code again: %d", rec.Code)
+	}
+}
+
+func TestSweepLoginCodes(t *testing.T) {
+	t.Cleanup(func() {
+		loginCodesMu.Lock()
+		defer loginCodesMu.Unlock()
+		clear(loginCodes)
+	})
+
+	code := issueLoginCode(tokenResponse{Token: "token"})
+	sweepLoginCodes(time.Now().Add(loginCodeExpiration + time.Second))
+	loginCodesMu.Lock()
+	defer loginCodesMu.Unlock()
+	if _, ok := loginCodes[code]; ok {
+		t.Error("expired login code is kept")
+	}
+}
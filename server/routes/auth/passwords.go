@@ -1,17 +1,322 @@
 package auth
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
-func generatePassword(p string) string {
-	hash, _ := bcrypt.GenerateFromPassword([]byte(p), bcrypt.DefaultCost)
-	return string(hash)
+	"github.com/c2h5oh/datasize"
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+)
+
+// Values of auth.password_hashing.algorithm.
+const (
+	HashArgon2id = "argon2id"
+	HashBcrypt   = "bcrypt"
+)
+
+const (
+	argon2idSaltLength = 16
+	argon2idKeyLength  = 32
+)
+
+// errInvalidHash is returned when parsing a password hash that isn't a PHC-formatted Argon2id hash.
+var errInvalidHash = errors.New("invalid argon2id hash")
+
+// passwordPolicyError is returned for passwords that break the password policy, and says why.
+type passwordPolicyError struct {
+	reason string
 }
 
+func (e *passwordPolicyError) Error() string {
+	return e.reason
+}
+
+type argon2idParams struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	keyLength   uint32
+}
+
+func (p argon2idParams) key(password string, salt []byte) []byte {
+	return argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, p.keyLength)
+}
+
+// generatePassword hashes the password with the algorithm in the config.
+func generatePassword(p string) (string, error) {
+	conf := config.Conf.Auth.PasswordHashing
+	switch conf.Algorithm {
+	case HashArgon2id:
+		return hashArgon2id(p, configArgon2idParams())
+	case HashBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(p), conf.BcryptCost)
+		return string(hash), err
+	default:
+		return "", fmt.Errorf("unknown auth.password_hashing.algorithm %q, expected argon2id or bcrypt", conf.Algorithm)
+	}
+}
+
+// comparePassword reports whether the password matches the hash, which can be an Argon2id or a bcrypt hash.
 func comparePassword(hashedPassword, password string) bool {
 	// Make sure we don't allow empty passwords since accounts using providers may not have a password.
 	if password == "" {
 		return false
 	}
+	if strings.HasPrefix(hashedPassword, "$"+HashArgon2id+"$") {
+		params, salt, key, err := parseArgon2id(hashedPassword)
+		if err != nil {
+			return false
+		}
+		return subtle.ConstantTimeCompare(key, params.key(password, salt)) == 1
+	}
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	return err == nil
 }
+
+// passwordNeedsRehash reports whether the hash was made with another algorithm or other settings than the ones in
+// the config, so it should be replaced the next time the password is known.
+func passwordNeedsRehash(hashedPassword string) bool {
+	conf := config.Conf.Auth.PasswordHashing
+	switch conf.Algorithm {
+	case HashArgon2id:
+		params, _, _, err := parseArgon2id(hashedPassword)
+		return err != nil || params != configArgon2idParams()
+	case HashBcrypt:
+		cost, err := bcrypt.Cost([]byte(hashedPassword))
+		return err != nil || cost != conf.BcryptCost
+	default:
+		return false
+	}
+}
+
+func configArgon2idParams() argon2idParams {
+	// The settings are checked to fit when the config is loaded.
+	conf := config.Conf.Auth.PasswordHashing
+	return argon2idParams{
+		memory:      uint32(conf.Argon2idMemory / datasize.KB),
+		iterations:  uint32(conf.Argon2idIterations),
+		parallelism: uint8(conf.Argon2idParallelism),
+		keyLength:   argon2idKeyLength,
+	}
+}
+
+// hashArgon2id hashes the password with a random salt, and encodes the hash in the PHC string format,
+// e.g. $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>.
+func hashArgon2id(password string, params argon2idParams) (string, error) {
+	salt := make([]byte, argon2idSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := params.key(password, salt)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		HashArgon2id, argon2.Version, params.memory, params.iterations, params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// parseArgon2id returns the parameters, salt and key of a PHC-formatted Argon2id hash.
+func parseArgon2id(hash string) (argon2idParams, []byte, []byte, error) {
+	var params argon2idParams
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != HashArgon2id {
+		return params, nil, nil, errInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errInvalidHash
+	}
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism)
+	if err != nil || params.iterations == 0 || params.parallelism == 0 {
+		return params, nil, nil, errInvalidHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errInvalidHash
+	}
+	params.keyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
+
+// validatePassword checks a new password of the user against the password policy in the config.
+// It returns a *passwordPolicyError if the password breaks it.
+func validatePassword(password, username string) error {
+	policy := config.Conf.Auth.PasswordPolicy
+
+	length := utf8.RuneCountInString(password)
+	if length < policy.MinLength {
+		return &passwordPolicyError{"password must be at least " + strconv.Itoa(policy.MinLength) + " characters"}
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		return &passwordPolicyError{"password must be at most " + strconv.Itoa(policy.MaxLength) + " characters"}
+	}
+	// bcrypt only uses the first 72 bytes, and refuses to hash longer passwords.
+	if config.Conf.Auth.PasswordHashing.Algorithm == HashBcrypt && len(password) > 72 {
+		return &passwordPolicyError{"password must be at most 72 bytes"}
+	}
+	if policy.DisallowUsername && username != "" &&
+		strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return &passwordPolicyError{"password must not contain the username"}
+	}
+
+	if policy.BreachedPasswordsFile != "" {
+		breached, err := isPasswordBreached(policy.BreachedPasswordsFile, password)
+		if err != nil {
+			return fmt.Errorf("failed to check breached passwords: %w", err)
+		}
+		if breached {
+			return &passwordPolicyError{"password has appeared in a data breach, choose another one"}
+		}
+	}
+
+	return nil
+}
+
+// isPasswordBreached looks up the password in a local copy of the Pwned Passwords list. The path is either a file
+// of HASH:COUNT lines sorted by hash, or a directory of k-anonymity range files, each named after the first
+// five characters of the hashes in it and holding SUFFIX:COUNT lines.
+func isPasswordBreached(path, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if !info.IsDir() {
+		return sortedHashFileContains(path, info.Size(), hash)
+	}
+
+	prefix, suffix := hash[:5], hash[5:]
+	f, err := os.Open(filepath.Join(path, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.EqualFold(hashOfLine(scanner.Text()), suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// sortedHashFileContains binary searches a file of HASH:COUNT lines sorted by hash for the hash,
+// so the whole list never has to be read.
+func sortedHashFileContains(path string, size int64, hash string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	// Find the first line starting at or after an offset whose hash isn't smaller than the one looked for.
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		line, err := lineStartingAfter(f, size, mid)
+		if err != nil {
+			return false, err
+		}
+		if line == "" || strings.ToUpper(hashOfLine(line)) >= hash {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	line, err := lineStartingAfter(f, size, lo)
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(hashOfLine(line), hash), nil
+}
+
+// lineStartingAfter returns the first line of the file that starts at or after the offset,
+// or "" if there is none.
+func lineStartingAfter(f *os.File, size, offset int64) (string, error) {
+	if offset >= size {
+		return "", nil
+	}
+
+	start := max(offset-1, 0)
+	reader := bufio.NewReader(io.NewSectionReader(f, start, size-start))
+	if offset > 0 {
+		// Skip the rest of the line the offset is in. If the byte before it ends a line, the offset starts one.
+		if _, err := reader.ReadString('\n'); errors.Is(err, io.EOF) {
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// hashOfLine returns the hash of a HASH:COUNT line.
+func hashOfLine(line string) string {
+	hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	return hash
+}
+
+// rehashPassword replaces the user's password hash with one made with the current algorithm and settings.
+// Failing to do so is logged, and tried again on the next login.
+func rehashPassword(ctx context.Context, user media.DatabaseUser, password string) {
+	hash, err := generatePassword(password)
+	if err != nil {
+		log.Error("Error hashing password", "err", err, "userID", user.ID)
+		return
+	}
+	user.PasswordHash = hash
+	if err := db.DB.UpdateUser(ctx, user); err != nil {
+		log.Error("Error updating password hash", "err", err, "userID", user.ID)
+	}
+}
+
+// passwordRejected responds to a new password that validatePassword returned the error for.
+func passwordRejected(c echo.Context, err error) error {
+	var policyErr *passwordPolicyError
+	if errors.As(err, &policyErr) {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": policyErr.Error(),
+		})
+	}
+	log.Error("Error checking password", "err", err)
+	return c.JSON(http.StatusInternalServerError, echo.Map{
+		"message": "internal server error",
+	})
+}
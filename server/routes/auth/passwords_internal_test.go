@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/c2h5oh/datasize"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/libramusic/libracore/config"
+)
+
+func setupPasswords(t *testing.T) {
+	t.Helper()
+
+	previous := config.Conf.Auth
+	t.Cleanup(func() { config.Conf.Auth = previous })
+	config.Conf.Auth.PasswordHashing = config.PasswordHashingAuthConfig{
+		Algorithm:           HashArgon2id,
+		Argon2idMemory:      64 * datasize.KB,
+		Argon2idIterations:  1,
+		Argon2idParallelism: 1,
+		BcryptCost:          bcrypt.MinCost,
+	}
+	config.Conf.Auth.PasswordPolicy = config.PasswordPolicyAuthConfig{
+		MinLength:        8,
+		MaxLength:        64,
+		DisallowUsername: true,
+	}
+}
+
+func TestArgon2idHash(t *testing.T) {
+	setupPasswords(t)
+
+	hash, err := generatePassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("hash %q isn't in the PHC format", hash)
+	}
+	if !comparePassword(hash, "correct horse") {
+		t.Error("password doesn't match its hash")
+	}
+	if comparePassword(hash, "correct horsE") {
+		t.Error("wrong password matches")
+	}
+	if passwordNeedsRehash(hash) {
+		t.Error("hash with the current settings needs rehashing")
+	}
+
+	config.Conf.Auth.PasswordHashing.Argon2idIterations = 2
+	if !passwordNeedsRehash(hash) {
+		t.Error("hash with old settings doesn't need rehashing")
+	}
+	if !comparePassword(hash, "correct horse") {
+		t.Error("hash with old settings doesn't match anymore")
+	}
+}
+
+func TestBcryptHashIsUpgraded(t *testing.T) {
+	setupPasswords(t)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !comparePassword(string(hash), "correct horse") {
+		t.Error("password doesn't match its bcrypt hash")
+	}
+	if !passwordNeedsRehash(string(hash)) {
+		t.Error("bcrypt hash doesn't need rehashing to argon2id")
+	}
+
+	config.Conf.Auth.PasswordHashing.Algorithm = HashBcrypt
+	if passwordNeedsRehash(string(hash)) {
+		t.Error("bcrypt hash needs rehashing when bcrypt is the algorithm")
+	}
+}
+
+func TestGeneratePasswordFailsForLongBcryptPasswords(t *testing.T) {
+	setupPasswords(t)
+	config.Conf.Auth.PasswordHashing.Algorithm = HashBcrypt
+
+	hash, err := generatePassword(strings.Repeat("a", 73))
+	if err == nil {
+		t.Errorf("hashed a 73-byte password to %q", hash)
+	}
+	var policyErr *passwordPolicyError
+	if err := validatePassword(strings.Repeat("a", 73), "alice"); !errors.As(err, &policyErr) {
+		t.Errorf("policy allows a 73-byte password for bcrypt: %v", err)
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	setupPasswords(t)
+
+	tests := []struct {
+		password string
+		valid    bool
+	}{
+		{"short", false},
+		{"long enough", true},
+		{"ümläütß!", true},
+		{strings.Repeat("a", 65), false},
+		{"my name is Alice", false},
+	}
+	for _, test := range tests {
+		err := validatePassword(test.password, "alice")
+		var policyErr *passwordPolicyError
+		if err != nil && !errors.As(err, &policyErr) {
+			t.Fatalf("%q: %v", test.password, err)
+		}
+		if (err == nil) != test.valid {
+			t.Errorf("%q: got %v, want valid %t", test.password, err, test.valid)
+		}
+	}
+}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func TestBreachedPasswordsFile(t *testing.T) {
+	setupPasswords(t)
+
+	breached := []string{"password1", "qwertyuiop", "letmein123"}
+	lines := []string{sha1Hex("breached") + ":1"}
+	for i := range 200 {
+		lines = append(lines, sha1Hex("filler"+strconv.Itoa(i))+":"+strconv.Itoa(i))
+	}
+	for _, password := range breached {
+		lines = append(lines, sha1Hex(password)+":12345")
+	}
+	slices.Sort(lines)
+
+	path := filepath.Join(t.TempDir(), "pwned-passwords.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config.Conf.Auth.PasswordPolicy.BreachedPasswordsFile = path
+
+	for _, password := range append(breached, "breached") {
+		if err := validatePassword(password, "alice"); err == nil {
+			t.Errorf("breached password %q is allowed", password)
+		}
+	}
+	// Every line is found, wherever it is in the file.
+	for i := range 200 {
+		if found, err := isPasswordBreached(path, "filler"+strconv.Itoa(i)); err != nil || !found {
+			t.Errorf("filler%d not found: %v", i, err)
+		}
+	}
+	for _, password := range []string{"not breached", "filler200", "zzzzzzzzzz"} {
+		if err := validatePassword(password, "alice"); err != nil {
+			t.Errorf("%q: %v", password, err)
+		}
+	}
+}
+
+func TestBreachedPasswordsRanges(t *testing.T) {
+	setupPasswords(t)
+
+	dir := t.TempDir()
+	hash := sha1Hex("password1")
+	ranges := "0018A45C4D1DEF81644B54AB7F969B88D65:1\n" + strings.ToLower(hash[5:]) + ":2427523\n"
+	if err := os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte(ranges), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config.Conf.Auth.PasswordPolicy.BreachedPasswordsFile = dir
+
+	if err := validatePassword("password1", "alice"); err == nil {
+		t.Error("breached password is allowed")
+	}
+	if err := validatePassword("not breached", "alice"); err != nil {
+		t.Error(err)
+	}
+}
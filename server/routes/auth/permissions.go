@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+)
+
+var (
+	ErrUnknownRole     = errors.New("unknown role")
+	ErrRoleNotAllowed  = errors.New("not allowed to give this role")
+	ErrUserOutranks    = errors.New("not allowed to change the role of this user")
+	ErrOwnerRoleNeeded = errors.New("the owner can't give up their role without passing it on")
+)
+
+// RolePermissions returns the permissions of a role, which can be changed in general.admin_permissions.
+// The owner always has every permission.
+func RolePermissions(role string) media.AdminPermissions {
+	if role == media.RoleOwner {
+		return media.DefaultAdminPermissions(role)
+	}
+	if permissions, ok := config.Conf.General.AdminPermissions[role]; ok {
+		return permissions
+	}
+	return media.DefaultAdminPermissions(role)
+}
+
+// HasPermission reports whether the user's role has the permission.
+func HasPermission(ctx context.Context, userID, permission string) (bool, error) {
+	user, err := db.DB.User(ctx, userID)
+	if errors.Is(err, db.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return RolePermissions(user.Role()).Has(permission), nil
+}
+
+// CanActFor reports whether the requester may access or change data belonging to the user ownerID.
+// Users always can for their own data, and users whose role has the permission can for everyone's.
+func CanActFor(ctx context.Context, requesterID, ownerID, permission string) (bool, error) {
+	if requesterID == "" {
+		return false, nil
+	}
+	if requesterID == ownerID {
+		return true, nil
+	}
+	return HasPermission(ctx, requesterID, permission)
+}
+
+// ChangeRole gives target the role on behalf of actor, who needs the manage_users permission.
+// Only the owner can make users admins or pass on ownership, and other users can only change the roles of users
+// whose role is lower than theirs.
+func ChangeRole(ctx context.Context, actor, target media.DatabaseUser, role string) error {
+	actorRole := actor.Role()
+	switch {
+	case !isRole(role):
+		return ErrUnknownRole
+	case actor.ID == target.ID && actorRole == media.RoleOwner:
+		return ErrOwnerRoleNeeded
+	case actorRole == media.RoleOwner:
+	case !media.RoleOutranks(actorRole, target.Role()):
+		return ErrUserOutranks
+	case !media.RoleOutranks(actorRole, role):
+		return ErrRoleNotAllowed
+	}
+	return AssignRole(ctx, target, role)
+}
+
+// AssignRole gives the user the role without checking who is asking. Making a user the owner makes the previous
+// owner an admin, since there is only one owner.
+func AssignRole(ctx context.Context, user media.DatabaseUser, role string) error {
+	if !isRole(role) {
+		return ErrUnknownRole
+	}
+
+	if role == media.RoleOwner {
+		users, err := db.DB.Users(ctx)
+		if err != nil {
+			return err
+		}
+		for _, other := range users {
+			if other.ID != user.ID && other.Role() == media.RoleOwner {
+				if err := setRole(ctx, other, media.RoleAdmin); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return setRole(ctx, user, role)
+}
+
+// createUser stores a new user. The first user becomes the owner, and the others members.
+// The users are counted in the transaction that creates the user, so two users registering at once
+// can't both become the owner.
+func createUser(ctx context.Context, user media.DatabaseUser) error {
+	return db.DB.Transaction(ctx, func(ctx context.Context) error {
+		count, err := db.DB.CountRows(ctx, "users")
+		if err != nil {
+			return err
+		}
+
+		role := media.RoleMember
+		if count == 0 {
+			role = media.RoleOwner
+		}
+		if user.Permissions == nil {
+			user.Permissions = map[string]string{}
+		}
+		user.Permissions[media.PermissionRole] = role
+		return db.DB.CreateUser(ctx, user)
+	})
+}
+
+func setRole(ctx context.Context, user media.DatabaseUser, role string) error {
+	if user.Permissions == nil {
+		user.Permissions = map[string]string{}
+	}
+	user.Permissions[media.PermissionRole] = role
+	return db.DB.UpdateUser(ctx, user)
+}
+
+func isRole(role string) bool {
+	return slices.Contains(media.Roles, role)
+}
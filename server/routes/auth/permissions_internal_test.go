@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+)
+
+// setupDatabase replaces db.DB with an empty SQLite database for the test.
+func setupDatabase(t *testing.T) {
+	t.Helper()
+
+	database := db.Registry["sqlite"].WithDSN(filepath.Join(t.TempDir(), "libra.db"))
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	previous := db.DB
+	db.DB = database
+	t.Cleanup(func() {
+		db.DB = previous
+		database.Close()
+	})
+}
+
+// addUser stores a user with the role and returns its ID.
+func addUser(t *testing.T, id, role string) string {
+	t.Helper()
+
+	user := media.DatabaseUser{User: media.User{
+		ID:          id,
+		Username:    id,
+		Permissions: map[string]string{media.PermissionRole: role},
+	}}
+	if err := db.DB.CreateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestCanActFor(t *testing.T) {
+	setupDatabase(t)
+	previous := config.Conf.General.AdminPermissions
+	config.Conf.General.AdminPermissions = map[string]media.AdminPermissions{
+		// Moderators can change others' content, but not their accounts.
+		media.RoleAdmin: {DeleteOthersContent: true},
+	}
+	t.Cleanup(func() { config.Conf.General.AdminPermissions = previous })
+
+	ctx := context.Background()
+	owner := addUser(t, "user0000001", media.RoleOwner)
+	moderator := addUser(t, "user0000002", media.RoleAdmin)
+	member := addUser(t, "user0000003", media.RoleMember)
+
+	tests := []struct {
+		requester, owner, permission string
+		want                         bool
+	}{
+		{member, member, media.PermissionManageUsers, true},
+		{member, owner, media.PermissionDeleteOthersContent, false},
+		{"", "", media.PermissionManageUsers, false},
+		{owner, member, media.PermissionManageUsers, true},
+		{owner, member, media.PermissionDeleteOthersContent, true},
+		{moderator, member, media.PermissionDeleteOthersContent, true},
+		{moderator, member, media.PermissionManageUsers, false},
+		{"unknown0001", member, media.PermissionDeleteOthersContent, false},
+	}
+	for _, test := range tests {
+		allowed, err := CanActFor(ctx, test.requester, test.owner, test.permission)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != test.want {
+			t.Errorf("%q acting for %q with %s: got %t", test.requester, test.owner, test.permission, allowed)
+		}
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	setupDatabase(t)
+	previous := config.Conf.General.AdminPermissions
+	config.Conf.General.AdminPermissions = map[string]media.AdminPermissions{
+		// The owner's permissions can't be taken away.
+		media.RoleOwner: {},
+		media.RoleAdmin: {ManageUsers: true},
+	}
+	t.Cleanup(func() { config.Conf.General.AdminPermissions = previous })
+
+	ctx := context.Background()
+	owner := addUser(t, "user0000001", media.RoleOwner)
+	admin := addUser(t, "user0000002", media.RoleAdmin)
+	member := addUser(t, "user0000003", media.RoleMember)
+	guest := addUser(t, "user0000004", media.RoleGuest)
+
+	tests := []struct {
+		userID, permission string
+		want               bool
+	}{
+		{owner, media.PermissionManageSources, true},
+		{owner, media.PermissionViewAnalytics, true},
+		{admin, media.PermissionManageUsers, true},
+		{admin, media.PermissionUpload, false},
+		{member, media.PermissionUpload, true},
+		{member, media.PermissionManageUsers, false},
+		{guest, media.PermissionUpload, false},
+		{"unknown0001", media.PermissionUpload, false},
+	}
+	for _, test := range tests {
+		allowed, err := HasPermission(ctx, test.userID, test.permission)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != test.want {
+			t.Errorf("%q with %s: got %t", test.userID, test.permission, allowed)
+		}
+	}
+}
+
+func TestChangeRole(t *testing.T) {
+	setupDatabase(t)
+	ctx := context.Background()
+	user := func(id string) media.DatabaseUser {
+		t.Helper()
+		u, err := db.DB.User(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	owner := addUser(t, "user0000001", media.RoleOwner)
+	admin := addUser(t, "user0000002", media.RoleAdmin)
+	member := addUser(t, "user0000003", media.RoleMember)
+	guest := addUser(t, "user0000004", media.RoleGuest)
+
+	tests := []struct {
+		actor, target, role string
+		want                error
+	}{
+		{owner, member, "superuser", ErrUnknownRole},
+		{owner, owner, media.RoleAdmin, ErrOwnerRoleNeeded},
+		{admin, owner, media.RoleMember, ErrUserOutranks},
+		{admin, member, media.RoleAdmin, ErrRoleNotAllowed},
+		{member, guest, media.RoleMember, ErrRoleNotAllowed},
+		{admin, guest, media.RoleMember, nil},
+		{owner, member, media.RoleAdmin, nil},
+	}
+	for _, test := range tests {
+		err := ChangeRole(ctx, user(test.actor), user(test.target), test.role)
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s making %s %s: got %v, want %v", test.actor, test.target, test.role, err, test.want)
+		}
+	}
+	if role := user(member).Role(); role != media.RoleAdmin {
+		t.Errorf("member was made %s, want admin", role)
+	}
+
+	// Passing on ownership makes the previous owner an admin.
+	if err := ChangeRole(ctx, user(owner), user(admin), media.RoleOwner); err != nil {
+		t.Fatal(err)
+	}
+	if role := user(admin).Role(); role != media.RoleOwner {
+		t.Errorf("new owner is %s", role)
+	}
+	if role := user(owner).Role(); role != media.RoleAdmin {
+		t.Errorf("previous owner is %s, want admin", role)
+	}
+}
+
+func TestCreateUserMakesFirstUserOwner(t *testing.T) {
+	setupDatabase(t)
+	ctx := context.Background()
+
+	// Users registering at once on an empty server can't all become the owner.
+	ids := []string{"user0000001", "user0000002", "user0000003", "user0000004"}
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Go(func() {
+			if err := createUser(ctx, media.DatabaseUser{User: media.User{ID: id, Username: id}}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+
+	owners := 0
+	for _, id := range ids {
+		user, err := db.DB.User(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch user.Role() {
+		case media.RoleOwner:
+			owners++
+		case media.RoleMember:
+		default:
+			t.Errorf("%s is %s", id, user.Role())
+		}
+	}
+	if owners != 1 {
+		t.Errorf("%d owners, want 1", owners)
+	}
+
+	if err := createUser(ctx, media.DatabaseUser{User: media.User{ID: "user0000005", Username: "late"}}); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := db.DB.User(ctx, "user0000005"); err != nil || user.Role() != media.RoleMember {
+		t.Errorf("later user is %s, %v, want member", user.Role(), err)
+	}
+}
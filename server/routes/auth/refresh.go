@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+)
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type tokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Refresh exchanges a refresh token for a new access token and refresh token.
+// Every refresh token can only be exchanged once. Using one again means it was stolen,
+// so its whole family is revoked and its session ended, and both the thief and the user have to log in again.
+func Refresh(c echo.Context) error {
+	var req refreshRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+	if req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "refresh_token is required",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	stored, err := db.DB.RefreshToken(ctx, HashToken(req.RefreshToken))
+	if errors.Is(err, db.ErrNotFound) {
+		return c.JSON(http.StatusUnauthorized, echo.Map{
+			"message": "invalid refresh token",
+		})
+	} else if err != nil {
+		log.Error("Error getting refresh token", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	now := time.Now().Unix()
+	if stored.Revoked || stored.Expiration <= now {
+		return c.JSON(http.StatusUnauthorized, echo.Map{
+			"message": "refresh token has expired or was revoked",
+		})
+	}
+
+	unused, err := db.DB.UseRefreshToken(ctx, stored.TokenHash, now)
+	if err != nil {
+		log.Error("Error using refresh token", "err", err, "userID", stored.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	if !unused {
+		if err := endSession(ctx, stored.FamilyID); err != nil {
+			log.Error("Error ending session", "err", err, "userID", stored.UserID)
+			return c.JSON(http.StatusInternalServerError, echo.Map{
+				"message": "internal server error",
+			})
+		}
+		log.Warn("Refresh token was used twice, ending its session", "userID", stored.UserID)
+		return c.JSON(http.StatusUnauthorized, echo.Map{
+			"message": "refresh token was already used",
+		})
+	}
+
+	if _, err := db.DB.User(ctx, stored.UserID); errors.Is(err, db.ErrNotFound) {
+		return c.JSON(http.StatusUnauthorized, echo.Map{
+			"message": "user not found",
+		})
+	} else if err != nil {
+		log.Error("Error getting user", "err", err, "userID", stored.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	// Ending a session revokes its refresh tokens before deleting it,
+	// so a session that's gone was ended while the token was being exchanged.
+	session, err := db.DB.Session(ctx, stored.FamilyID)
+	if errors.Is(err, db.ErrNotFound) {
+		return c.JSON(http.StatusUnauthorized, echo.Map{
+			"message": "session has ended",
+		})
+	} else if err != nil {
+		log.Error("Error getting session", "err", err, "userID", stored.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	session.UserAgent = c.Request().UserAgent()
+	session.IP = c.RealIP()
+	session.LastUsed = now
+	session.Expiration = time.Unix(now, 0).Add(config.Conf.Auth.JWT.RefreshTokenExpiration).Unix()
+	if err := db.DB.UpdateSession(ctx, session); err != nil {
+		log.Error("Error updating session", "err", err, "userID", stored.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	tokens, err := issueTokens(ctx, stored.UserID, stored.FamilyID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// issueTokens returns a new access token and refresh token in the session identified by familyID.
+func issueTokens(ctx context.Context, userID, familyID string) (tokenResponse, error) {
+	token, err := GenerateToken(
+		userID,
+		familyID,
+		config.Conf.Auth.JWT.AccessTokenExpiration,
+		config.Conf.Auth.JWT.SigningMethod,
+		config.Conf.Auth.JWT.SigningKey,
+	)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	refreshToken := rand.Text()
+	now := time.Now()
+	err = db.DB.AddRefreshToken(ctx, db.RefreshToken{
+		TokenHash:    HashToken(refreshToken),
+		UserID:       userID,
+		FamilyID:     familyID,
+		CreationDate: now.Unix(),
+		Expiration:   now.Add(config.Conf.Auth.JWT.RefreshTokenExpiration).Unix(),
+	})
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	return tokenResponse{Token: token, RefreshToken: refreshToken}, nil
+}
+
+// HashToken returns the hash that tokens and recovery codes are stored by.
+// They are random, so they don't need a slow password hash.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+)
+
+// How often the last use of a session or personal access token is recorded.
+// Recording every request would write to the database on every request.
+const activityInterval = time.Minute
+
+type sessionResponse struct {
+	db.Session
+
+	// Whether the session is the one the request was made with.
+	Current bool `json:"current"`
+}
+
+// Sessions lists the devices the user is logged in on.
+func Sessions(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+
+	sessions, err := db.DB.UserSessions(c.Request().Context(), claims.UserID)
+	if err != nil {
+		log.Error("Error getting sessions", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	res := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		res = append(res, sessionResponse{Session: session, Current: session.ID == claims.FamilyID})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// RevokeSession logs the user out on one device.
+func RevokeSession(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+	ctx := c.Request().Context()
+
+	session, err := db.DB.Session(ctx, c.Param("id"))
+	if errors.Is(err, db.ErrNotFound) || (err == nil && session.UserID != claims.UserID) {
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"message": "session not found",
+		})
+	} else if err != nil {
+		log.Error("Error getting session", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	if err := endSession(ctx, session.ID); err != nil {
+		log.Error("Error ending session", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// RevokeSessions logs the user out everywhere.
+// If the except_current query parameter is true, the session the request was made with is kept.
+func RevokeSessions(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+
+	keep := ""
+	if c.QueryParam("except_current") == "true" {
+		keep = claims.FamilyID
+	}
+	if err := endUserSessions(c.Request().Context(), claims.UserID, keep); err != nil {
+		log.Error("Error ending sessions", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// CheckSession reports whether the session an access token belongs to is still active,
+// and records that it was used. Tokens that don't belong to a session are never active.
+func CheckSession(c echo.Context, claims *TokenClaims) (bool, error) {
+	if claims.FamilyID == "" {
+		return false, nil
+	}
+
+	ctx := c.Request().Context()
+	session, err := db.DB.Session(ctx, claims.FamilyID)
+	if errors.Is(err, db.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	if session.UserID != claims.UserID || now.Unix() >= session.Expiration {
+		return false, nil
+	}
+
+	if now.Sub(time.Unix(session.LastUsed, 0)) >= activityInterval {
+		session.LastUsed = now.Unix()
+		session.IP = c.RealIP()
+		if err := db.DB.UpdateSession(ctx, session); err != nil {
+			log.Warn("Error recording session activity", "err", err, "userID", session.UserID)
+		}
+	}
+	return true, nil
+}
+
+// startSession records a new session for the user and returns its first tokens.
+func startSession(c echo.Context, userID, deviceName string) (tokenResponse, error) {
+	now := time.Now()
+	session := db.Session{
+		ID:           media.GenerateID(config.Conf.General.IDLength),
+		UserID:       userID,
+		DeviceName:   deviceName,
+		UserAgent:    c.Request().UserAgent(),
+		IP:           c.RealIP(),
+		CreationDate: now.Unix(),
+		LastUsed:     now.Unix(),
+		Expiration:   now.Add(config.Conf.Auth.JWT.RefreshTokenExpiration).Unix(),
+	}
+	if err := db.DB.AddSession(c.Request().Context(), session); err != nil {
+		return tokenResponse{}, err
+	}
+	return issueTokens(c.Request().Context(), userID, session.ID)
+}
+
+// endUserSessions ends every session of the user except the one with the ID keep, if any.
+func endUserSessions(ctx context.Context, userID, keep string) error {
+	sessions, err := db.DB.UserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.ID == keep {
+			continue
+		}
+		if err := endSession(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// endSession revokes the session's refresh tokens and deletes it, which also invalidates its access tokens.
+func endSession(ctx context.Context, sessionID string) error {
+	if err := db.DB.RevokeRefreshTokenFamily(ctx, sessionID); err != nil {
+		return err
+	}
+	return db.DB.DeleteSession(ctx, sessionID)
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// How often expired auth state is forgotten.
+const authStateSweepInterval = time.Minute
+
+// SweepAuthState forgets the state the auth routes keep in memory once it has expired, every minute until ctx
+// is canceled, so it doesn't pile up. That's failed logins that are too old to count, the attempts of expired
+// MFA tokens, when links were mailed once another can be, and expired stream tickets and login codes.
+func SweepAuthState(ctx context.Context) {
+	ticker := time.NewTicker(authStateSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sweepAuthState(now)
+		}
+	}
+}
+
+func sweepAuthState(now time.Time) {
+	sweepLoginFailures(now)
+	sweepMFAAttempts(now)
+	sweepEmailTokensSent(now)
+	sweepStreamTickets(now)
+	sweepLoginCodes(now)
+}
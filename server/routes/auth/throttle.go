@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+)
+
+// Actions of the audit log entries written by the auth routes.
+const (
+	AuditAccountLocked = "account_locked"
+	AuditIPLocked      = "ip_locked"
+)
+
+// CaptchaVerifier checks the CAPTCHA solution a client sent along with a login. Once a username or IP address has
+// failed auth.login_throttling.captcha_attempts logins, logins without a valid solution are rejected.
+// It's nil by default, which disables CAPTCHAs; builds that offer one set it before starting the server.
+var CaptchaVerifier func(ctx context.Context, solution, ip string) (bool, error)
+
+// Failed logins per account and per IP address, so passwords can't be guessed by trying many of them.
+// They are only kept in memory, so restarting the server lifts lockouts.
+var (
+	accountLoginFailures = map[string]loginFailures{}
+	ipLoginFailures      = map[string]loginFailures{}
+	loginFailuresMu      sync.Mutex
+)
+
+type loginFailures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// The hash of a random password, compared against when the user doesn't exist,
+// so failed logins take as long whether or not the username exists.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := generatePassword(media.GenerateID(32))
+	if err != nil {
+		log.Error("Error hashing password", "err", err)
+	}
+	return hash
+})
+
+// loginAccountKey returns the key failed logins to an account are counted under.
+// Users are looked up by their username or email address, so logins with either count towards the same account,
+// and usernames that don't exist are throttled like ones that do.
+func loginAccountKey(user media.DatabaseUser, username string) string {
+	if user.ID != "" {
+		return "id:" + user.ID
+	}
+	return "name:" + strings.ToLower(username)
+}
+
+// loginWait returns how long has to pass before the account can be logged into from the IP address again.
+func loginWait(account, ip string) time.Duration {
+	conf := config.Conf.Auth.LoginThrottling
+	if !conf.Enabled {
+		return 0
+	}
+
+	loginFailuresMu.Lock()
+	defer loginFailuresMu.Unlock()
+
+	now := time.Now()
+	wait := max(
+		accountLoginFailures[account].lockedUntil.Sub(now),
+		ipLoginFailures[ip].lockedUntil.Sub(now),
+	)
+
+	// Once the free attempts are used up, each failure doubles the wait after it.
+	failures := accountLoginFailures[account]
+	if extra := failures.count - conf.FreeAttempts; failures.count > 0 && extra >= 0 {
+		backoff := time.Duration(float64(conf.InitialBackoff) * math.Pow(2, float64(extra)))
+		if backoff > conf.MaxBackoff || backoff < 0 {
+			backoff = conf.MaxBackoff
+		}
+		wait = max(wait, failures.last.Add(backoff).Sub(now))
+	}
+
+	return max(wait, 0)
+}
+
+// captchaRequired reports whether a login to the account from the IP address has to come with a CAPTCHA solution.
+func captchaRequired(account, ip string) bool {
+	conf := config.Conf.Auth.LoginThrottling
+	if !conf.Enabled || conf.CaptchaAttempts <= 0 || CaptchaVerifier == nil {
+		return false
+	}
+
+	loginFailuresMu.Lock()
+	defer loginFailuresMu.Unlock()
+	return accountLoginFailures[account].count >= conf.CaptchaAttempts ||
+		ipLoginFailures[ip].count >= conf.CaptchaAttempts
+}
+
+// recordLoginFailure counts a failed login to the account from the IP address,
+// and reports whether it locked out the account or the IP address.
+func recordLoginFailure(account, ip string) (accountLocked, ipLocked bool) {
+	conf := config.Conf.Auth.LoginThrottling
+	if !conf.Enabled {
+		return false, false
+	}
+
+	loginFailuresMu.Lock()
+	defer loginFailuresMu.Unlock()
+
+	now := time.Now()
+	accountLocked = countLoginFailure(accountLoginFailures, account, conf.LockoutAttempts, now)
+	ipLocked = countLoginFailure(ipLoginFailures, ip, conf.IPLockoutAttempts, now)
+	return accountLocked, ipLocked
+}
+
+// countLoginFailure counts a failure under the key, and locks it out once it has failed lockoutAttempts times.
+// Must be called with loginFailuresMu held.
+func countLoginFailure(failures map[string]loginFailures, key string, lockoutAttempts int, now time.Time) bool {
+	f := failures[key]
+	if now.Sub(f.last) > config.Conf.Auth.LoginThrottling.FailureWindow {
+		f.count = 0
+	}
+	f.count++
+	f.last = now
+
+	locked := lockoutAttempts > 0 && f.count >= lockoutAttempts
+	if locked {
+		// The key starts over with its free attempts once the lockout ends.
+		f.count = 0
+		f.lockedUntil = now.Add(config.Conf.Auth.LoginThrottling.LockoutDuration)
+	}
+	failures[key] = f
+	return locked
+}
+
+// sweepLoginFailures forgets failed logins that are too old to count.
+func sweepLoginFailures(now time.Time) {
+	window := config.Conf.Auth.LoginThrottling.FailureWindow
+
+	loginFailuresMu.Lock()
+	// Failures that still lock something out are kept, even once they're too old to count.
+	for _, failures := range []map[string]loginFailures{accountLoginFailures, ipLoginFailures} {
+		for key, f := range failures {
+			if now.Sub(f.last) > window && now.After(f.lockedUntil) {
+				delete(failures, key)
+			}
+		}
+	}
+	loginFailuresMu.Unlock()
+}
+
+// recordLoginSuccess forgets the account's failed logins. Failures from the IP address are kept,
+// so logging into one account doesn't allow guessing the passwords of others.
+func recordLoginSuccess(account string) {
+	loginFailuresMu.Lock()
+	defer loginFailuresMu.Unlock()
+	delete(accountLoginFailures, account)
+}
+
+// loginFailed counts the failed login, records lockouts in the audit log, and responds with the same error
+// whether the user doesn't exist or the password was wrong.
+func loginFailed(c echo.Context, user media.DatabaseUser, username string) error {
+	countFailedLogin(c, loginAccountKey(user, username), user.ID, username)
+	return c.JSON(http.StatusUnauthorized, echo.Map{
+		"message": "invalid username or password",
+	})
+}
+
+// countFailedLogin counts a failed login to the account from the client's IP address,
+// and records lockouts in the audit log.
+func countFailedLogin(c echo.Context, account, userID, username string) {
+	ip := c.RealIP()
+	accountLocked, ipLocked := recordLoginFailure(account, ip)
+
+	ctx := c.Request().Context()
+	lockout := config.Conf.Auth.LoginThrottling.LockoutDuration
+	if accountLocked {
+		log.Warn("Locked out account after failed logins", "username", username, "userID", userID, "ip", ip)
+		audit(ctx, db.AuditEvent{
+			Action:   AuditAccountLocked,
+			UserID:   userID,
+			Username: username,
+			IP:       ip,
+			Details: strconv.Itoa(config.Conf.Auth.LoginThrottling.LockoutAttempts) +
+				" failed logins, locked for " + lockout.String(),
+		})
+	}
+	if ipLocked {
+		log.Warn("Locked out IP address after failed logins", "ip", ip, "username", username, "userID", userID)
+		audit(ctx, db.AuditEvent{
+			Action:   AuditIPLocked,
+			UserID:   userID,
+			Username: username,
+			IP:       ip,
+			Details: strconv.Itoa(config.Conf.Auth.LoginThrottling.IPLockoutAttempts) +
+				" failed logins, locked for " + lockout.String(),
+		})
+	}
+}
+
+// loginThrottled responds that the client has to wait before trying to log in again.
+func loginThrottled(c echo.Context, wait time.Duration) error {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+	return c.JSON(http.StatusTooManyRequests, echo.Map{
+		"message":     "too many failed logins, try again later",
+		"retry_after": seconds,
+	})
+}
+
+// audit writes the event to the audit log. Failing to do so is logged, but doesn't fail the request.
+func audit(ctx context.Context, event db.AuditEvent) {
+	event.ID = media.GenerateID(config.Conf.General.IDLength)
+	event.CreationDate = time.Now().Unix()
+	if err := db.DB.AddAuditEvent(ctx, event); err != nil {
+		log.Error("Error writing audit log", "err", err, "action", event.Action, "userID", event.UserID)
+	}
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libramusic/libracore/config"
+)
+
+func setupLoginThrottling(t *testing.T) {
+	t.Helper()
+
+	previous := config.Conf.Auth.LoginThrottling
+	config.Conf.Auth.LoginThrottling = config.LoginThrottlingAuthConfig{
+		Enabled:           true,
+		FreeAttempts:      2,
+		InitialBackoff:    time.Minute,
+		MaxBackoff:        3 * time.Minute,
+		LockoutAttempts:   5,
+		IPLockoutAttempts: 7,
+		LockoutDuration:   time.Hour,
+		FailureWindow:     time.Hour,
+		CaptchaAttempts:   3,
+	}
+	t.Cleanup(func() {
+		config.Conf.Auth.LoginThrottling = previous
+		loginFailuresMu.Lock()
+		defer loginFailuresMu.Unlock()
+		clear(accountLoginFailures)
+		clear(ipLoginFailures)
+		CaptchaVerifier = nil
+	})
+}
+
+func TestLoginBackoffDoubles(t *testing.T) {
+	setupLoginThrottling(t)
+
+	// The wait after each failure: none for the free attempts, then doubling up to the maximum.
+	wants := []time.Duration{0, time.Minute, 2 * time.Minute, 3 * time.Minute}
+	for i, want := range wants {
+		recordLoginFailure("name:alice", "192.0.2.1")
+		wait := loginWait("name:alice", "192.0.2.1")
+		if wait > want || wait < want-time.Second {
+			t.Errorf("wait after %d failures is %s, want %s", i+1, wait, want)
+		}
+	}
+
+	if wait := loginWait("name:bob", "192.0.2.2"); wait != 0 {
+		t.Errorf("another account from another IP address has to wait %s", wait)
+	}
+
+	recordLoginSuccess("name:alice")
+	if wait := loginWait("name:alice", "192.0.2.2"); wait != 0 {
+		t.Errorf("account has to wait %s after logging in", wait)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	setupLoginThrottling(t)
+
+	for i := range 4 {
+		if accountLocked, _ := recordLoginFailure("name:alice", "192.0.2.1"); accountLocked {
+			t.Fatalf("locked out after %d failures", i+1)
+		}
+	}
+	accountLocked, ipLocked := recordLoginFailure("name:alice", "192.0.2.1")
+	if !accountLocked || ipLocked {
+		t.Fatalf("5th failure locked account %t and IP address %t, want only the account", accountLocked, ipLocked)
+	}
+	if wait := loginWait("name:alice", "192.0.2.2"); wait < time.Hour-time.Second {
+		t.Errorf("locked out account has to wait %s", wait)
+	}
+
+	// Failures for other accounts still count towards the IP address.
+	recordLoginFailure("name:bob", "192.0.2.1")
+	if _, ipLocked := recordLoginFailure("name:carol", "192.0.2.1"); !ipLocked {
+		t.Fatal("IP address isn't locked out after 7 failures")
+	}
+	if wait := loginWait("name:dave", "192.0.2.1"); wait < time.Hour-time.Second {
+		t.Errorf("locked out IP address has to wait %s", wait)
+	}
+}
+
+func TestCaptchaRequired(t *testing.T) {
+	setupLoginThrottling(t)
+
+	for range 3 {
+		recordLoginFailure("name:alice", "192.0.2.1")
+	}
+	if captchaRequired("name:alice", "192.0.2.1") {
+		t.Error("CAPTCHA required without a verifier")
+	}
+
+	CaptchaVerifier = func(context.Context, string, string) (bool, error) { return true, nil }
+	if !captchaRequired("name:alice", "192.0.2.2") {
+		t.Error("CAPTCHA not required for the account")
+	}
+	if !captchaRequired("name:bob", "192.0.2.1") {
+		t.Error("CAPTCHA not required for the IP address")
+	}
+	if captchaRequired("name:bob", "192.0.2.2") {
+		t.Error("CAPTCHA required for another account from another IP address")
+	}
+}
+
+func TestSweepLoginFailures(t *testing.T) {
+	setupLoginThrottling(t)
+	config.Conf.Auth.LoginThrottling.FailureWindow = 10 * time.Minute
+
+	for range 5 {
+		recordLoginFailure("name:alice", "192.0.2.1")
+	}
+	recordLoginFailure("name:bob", "192.0.2.2")
+
+	sweepLoginFailures(time.Now().Add(30 * time.Minute))
+	if _, ok := accountLoginFailures["name:bob"]; ok {
+		t.Error("old failures of bob are kept")
+	}
+	// Alice is locked out for an hour, so her failures are kept until that's over.
+	if _, ok := accountLoginFailures["name:alice"]; !ok {
+		t.Error("failures of the locked out alice are forgotten")
+	}
+
+	sweepLoginFailures(time.Now().Add(2 * time.Hour))
+	if _, ok := accountLoginFailures["name:alice"]; ok {
+		t.Error("failures of alice are kept after her lockout")
+	}
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// StreamTicketExpiration is how long a stream ticket can be used for after it's issued.
+const StreamTicketExpiration = 30 * time.Second
+
+// Tickets that open event streams. Browsers can't set headers when opening streams, and tickets are sent in the URL
+// instead of access tokens, since URLs end up in the logs of proxies. They're only kept in memory and can only be
+// used once, shortly after they're issued.
+var (
+	streamTickets   = map[string]streamTicket{}
+	streamTicketsMu sync.Mutex
+)
+
+type streamTicket struct {
+	token      jwt.Token
+	expiration time.Time
+}
+
+// IssueStreamTicket returns a ticket that authenticates one stream as the request the token authenticated,
+// with the same scopes.
+func IssueStreamTicket(token *jwt.Token) string {
+	ticket := rand.Text()
+
+	streamTicketsMu.Lock()
+	defer streamTicketsMu.Unlock()
+	streamTickets[ticket] = streamTicket{token: *token, expiration: time.Now().Add(StreamTicketExpiration)}
+	return ticket
+}
+
+// RedeemStreamTicket uses up the ticket and returns the token it was issued for, which may have been revoked since.
+// It returns false if the ticket doesn't exist, was already used or has expired.
+func RedeemStreamTicket(ticket string) (*jwt.Token, bool) {
+	streamTicketsMu.Lock()
+	defer streamTicketsMu.Unlock()
+
+	t, ok := streamTickets[ticket]
+	if !ok {
+		return nil, false
+	}
+	delete(streamTickets, ticket)
+	if time.Now().After(t.expiration) {
+		return nil, false
+	}
+	return &t.token, true
+}
+
+// sweepStreamTickets forgets expired stream tickets that were never redeemed.
+func sweepStreamTickets(now time.Time) {
+	streamTicketsMu.Lock()
+	defer streamTicketsMu.Unlock()
+	for ticket, t := range streamTickets {
+		if now.After(t.expiration) {
+			delete(streamTickets, ticket)
+		}
+	}
+}
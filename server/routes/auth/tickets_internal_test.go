@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestStreamTickets(t *testing.T) {
+	t.Cleanup(func() {
+		streamTicketsMu.Lock()
+		defer streamTicketsMu.Unlock()
+		clear(streamTickets)
+	})
+
+	claims := &TokenClaims{UserID: "alice", AccessTokenID: "token", Scopes: []string{ScopeLibraryRead}}
+	ticket := IssueStreamTicket(&jwt.Token{Raw: "raw", Claims: claims, Valid: true})
+	token, ok := RedeemStreamTicket(ticket)
+	if !ok || token.Raw != "raw" || token.Claims.(*TokenClaims).UserID != "alice" {
+		t.Fatalf("ticket redeemed as %+v, %v", token, ok)
+	}
+	if _, ok := RedeemStreamTicket(ticket); ok {
+		t.Error("ticket can be used twice")
+	}
+	if _, ok := RedeemStreamTicket(""); ok {
+		t.Error("empty ticket is accepted")
+	}
+
+	expired := IssueStreamTicket(&jwt.Token{Claims: &TokenClaims{UserID: "alice"}, Valid: true})
+	sweepStreamTickets(time.Now().Add(StreamTicketExpiration + time.Second))
+	if _, ok := RedeemStreamTicket(expired); ok {
+		t.Error("expired ticket is accepted")
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/rsa"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
 )
 
 var (
@@ -21,11 +23,35 @@ type TokenClaims struct {
 	jwt.RegisteredClaims
 
 	UserID string `json:"user_id"`
+
+	// The family of the refresh token issued along with the access token.
+	FamilyID string `json:"family_id,omitempty"`
+
+	// What the token is for. It's empty for access tokens, the only tokens the API accepts.
+	Purpose string `json:"purpose,omitempty"`
+
+	// The personal access token that authenticated the request, which limits it to Scopes.
+	// Both are empty for access tokens issued by logging in, which can do anything the user can.
+	AccessTokenID string   `json:"-"`
+	Scopes        []string `json:"-"`
+}
+
+// HasScope reports whether the token allows requests that need the scope.
+func (c *TokenClaims) HasScope(scope string) bool {
+	if c.AccessTokenID == "" {
+		return true
+	}
+	return slices.Contains(c.Scopes, scope) || slices.Contains(c.Scopes, ScopeAdmin)
 }
 
-func GenerateToken(id string, expiration time.Duration, signingMethod, signingKey string) (string, error) {
+// PurposeMFA is the purpose of tokens that prove the password was correct,
+// and are exchanged for an access token along with a second factor.
+const PurposeMFA = "mfa"
+
+func GenerateToken(id, familyID string, expiration time.Duration, signingMethod, signingKey string) (string, error) {
 	token := jwt.NewWithClaims(jwt.GetSigningMethod(signingMethod), &TokenClaims{
-		UserID: id,
+		UserID:   id,
+		FamilyID: familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -33,7 +59,7 @@ func GenerateToken(id string, expiration time.Duration, signingMethod, signingKe
 		},
 	})
 
-	t, err := token.SignedString(signingKey)
+	t, err := token.SignedString(privateSigningKey(signingMethod, signingKey))
 	if err != nil {
 		return "", err
 	}
@@ -41,6 +67,20 @@ func GenerateToken(id string, expiration time.Duration, signingMethod, signingKe
 	return t, nil
 }
 
+// UserIDFromContext returns the ID of the user whose token authenticated the request.
+// It returns false if the request is not authenticated.
+func UserIDFromContext(c echo.Context) (string, bool) {
+	token, ok := c.Get("user").(*jwt.Token)
+	if !ok || token == nil {
+		return "", false
+	}
+	claims, ok := token.Claims.(*TokenClaims)
+	if !ok || claims.UserID == "" {
+		return "", false
+	}
+	return claims.UserID, true
+}
+
 func LoadPrivateKey(signingMethod, signingKey string) error {
 	var err error
 
@@ -72,6 +112,22 @@ func SigningKey(signingMethod, configSigningKey string) any {
 	return key
 }
 
+// privateSigningKey returns the key used to sign tokens, as opposed to SigningKey which returns the key used to verify them.
+func privateSigningKey(signingMethod, configSigningKey string) any {
+	var key any
+	switch signingMethod {
+	case "HS256", "HS384", "HS512":
+		key = []byte(configSigningKey)
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+		key = RSAPrivateKey
+	case "ES256", "ES384", "ES512":
+		key = ECDSAPrivateKey
+	case "EdDSA":
+		key = EdDSAPrivateKey
+	}
+	return key
+}
+
 func CorrectSigningMethod(signingMethod string) string {
 	signingMethods := []string{
 		"HS256",
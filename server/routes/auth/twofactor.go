@@ -0,0 +1,529 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+)
+
+// Second factors returned in mfa_methods when logging in.
+const (
+	MFAMethodTOTP     = "totp"
+	MFAMethodWebAuthn = "webauthn"
+)
+
+const (
+	// How long the password step of a login is valid before the second factor must be given.
+	mfaTokenExpiration = 5 * time.Minute
+
+	// How many wrong codes can be sent with one MFA token before the password has to be entered again.
+	maxMFAAttempts = 5
+)
+
+// Attempts per MFA token, so codes can't be guessed by trying all of them.
+// The lock of a user in secondFactorLocks is held from checking the limits until the attempt is counted,
+// so concurrent attempts can't get past them, without making attempts of other users wait.
+// secondFactorMu guards both maps, and is only held briefly.
+var (
+	mfaAttempts       = map[string]mfaAttempt{}
+	secondFactorLocks = map[string]*secondFactorLock{}
+	secondFactorMu    sync.Mutex
+)
+
+type mfaAttempt struct {
+	count      int
+	expiration time.Time
+}
+
+// secondFactorLock serializes the second factor attempts of one user, and is forgotten once no attempt holds it.
+type secondFactorLock struct {
+	mu    sync.Mutex
+	users int
+}
+
+// lockSecondFactor waits until no other second factor attempt of the user is running,
+// and returns the function that lets the next one run.
+func lockSecondFactor(userID string) func() {
+	secondFactorMu.Lock()
+	lock, ok := secondFactorLocks[userID]
+	if !ok {
+		lock = &secondFactorLock{}
+		secondFactorLocks[userID] = lock
+	}
+	lock.users++
+	secondFactorMu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		secondFactorMu.Lock()
+		lock.users--
+		if lock.users == 0 {
+			delete(secondFactorLocks, userID)
+		}
+		secondFactorMu.Unlock()
+	}
+}
+
+type twoFactorCodeRequest struct {
+	// A code from the authenticator app, or a recovery code.
+	Code string `json:"code"`
+}
+
+type loginMFARequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+
+	// The name of the device, shown in the list of sessions.
+	DeviceName string `json:"device_name"`
+}
+
+// TwoFactorStatus reports which second factors the user has enabled.
+func TwoFactorStatus(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+
+	methods, err := mfaMethods(c.Request().Context(), claims.UserID)
+	if err != nil {
+		log.Error("Error getting second factors", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+		"totp_enabled":     slices.Contains(methods, MFAMethodTOTP),
+		"webauthn_enabled": slices.Contains(methods, MFAMethodWebAuthn),
+	})
+}
+
+// EnrollTOTP generates a new TOTP secret for the user. It only takes effect once confirmed with ConfirmTOTP.
+func EnrollTOTP(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+	ctx := c.Request().Context()
+
+	user, err := db.DB.User(ctx, claims.UserID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": err.Error(),
+		})
+	}
+	enabled, err := totpEnabled(ctx, user.ID)
+	if err != nil {
+		log.Error("Error getting TOTP secret", "err", err, "userID", user.ID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	if enabled {
+		return c.JSON(http.StatusConflict, echo.Map{
+			"message": "two-factor authentication is already enabled",
+		})
+	}
+
+	secret := db.TOTPSecret{
+		UserID:       user.ID,
+		Secret:       generateTOTPSecret(),
+		CreationDate: time.Now().Unix(),
+	}
+	if err := db.DB.SetTOTPSecret(ctx, secret); err != nil {
+		log.Error("Error storing TOTP secret", "err", err, "userID", user.ID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"secret":      secret.Secret,
+		"otpauth_uri": totpURI(secret.Secret, user.Username),
+	})
+}
+
+// ConfirmTOTP enables two-factor authentication once the user sends a code generated with the new secret,
+// and returns the user's recovery codes. They are only shown this once.
+func ConfirmTOTP(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+	ctx := c.Request().Context()
+
+	var req twoFactorCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	secret, err := db.DB.TOTPSecret(ctx, claims.UserID)
+	if errors.Is(err, db.ErrNotFound) {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "two-factor authentication enrollment was not started",
+		})
+	} else if err != nil {
+		log.Error("Error getting TOTP secret", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	if secret.Enabled {
+		return c.JSON(http.StatusConflict, echo.Map{
+			"message": "two-factor authentication is already enabled",
+		})
+	}
+
+	var step int64
+	if ok, err := verifySecondFactor(c, claims.UserID, "", time.Time{}, func() (bool, error) {
+		var ok bool
+		step, ok = matchTOTP(secret.Secret, req.Code, time.Now())
+		return ok, nil
+	}, incorrectCode(c)); !ok {
+		return err
+	}
+	secret.Enabled = true
+	secret.LastStep = step
+	if err := db.DB.SetTOTPSecret(ctx, secret); err != nil {
+		log.Error("Error storing TOTP secret", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	codes, err := replaceRecoveryCodes(ctx, claims.UserID)
+	if err != nil {
+		log.Error("Error storing recovery codes", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTOTP turns off TOTP after checking a code or recovery code. Passkeys stay a second factor.
+func DisableTOTP(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+	ctx := c.Request().Context()
+
+	var req twoFactorCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	enabled, err := totpEnabled(ctx, claims.UserID)
+	if err != nil {
+		log.Error("Error getting TOTP secret", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	if !enabled {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "two-factor authentication is not enabled",
+		})
+	}
+
+	if ok, err := verifySecondFactor(c, claims.UserID, "", time.Time{}, func() (bool, error) {
+		return checkSecondFactor(ctx, claims.UserID, req.Code)
+	}, incorrectCode(c)); !ok {
+		return err
+	}
+
+	if err := disableTOTP(ctx, claims.UserID); err != nil {
+		log.Error("Error disabling two-factor authentication", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking a code from the authenticator app.
+func RegenerateRecoveryCodes(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+	ctx := c.Request().Context()
+
+	var req twoFactorCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	if ok, err := verifySecondFactor(c, claims.UserID, "", time.Time{}, func() (bool, error) {
+		return checkTOTP(ctx, claims.UserID, req.Code)
+	}, incorrectCode(c)); !ok {
+		return err
+	}
+
+	codes, err := replaceRecoveryCodes(ctx, claims.UserID)
+	if err != nil {
+		log.Error("Error storing recovery codes", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+		"recovery_codes": codes,
+	})
+}
+
+// LoginMFA finishes a login of a user with two-factor authentication,
+// exchanging the MFA token returned by Login and a code for an access token and refresh token.
+func LoginMFA(c echo.Context) error {
+	var req loginMFARequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	claims, err := parseMFAToken(req.MFAToken)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{
+			"message": "invalid MFA token",
+		})
+	}
+
+	ctx := c.Request().Context()
+	if ok, err := verifySecondFactor(c, claims.UserID, req.MFAToken, claims.ExpiresAt.Time, func() (bool, error) {
+		return checkSecondFactor(ctx, claims.UserID, req.Code)
+	}, func() error {
+		return c.JSON(http.StatusUnauthorized, echo.Map{
+			"message": "incorrect code",
+		})
+	}); !ok {
+		return err
+	}
+
+	tokens, err := startSession(c, claims.UserID, req.DeviceName)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// ResetTwoFactor turns off two-factor authentication for the user, deleting their TOTP secret, recovery codes and
+// passkeys.
+func ResetTwoFactor(ctx context.Context, userID string) error {
+	if err := disableTOTP(ctx, userID); err != nil {
+		return err
+	}
+	credentials, err := db.DB.UserWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, credential := range credentials {
+		if err := db.DB.DeleteWebAuthnCredential(ctx, credential.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func disableTOTP(ctx context.Context, userID string) error {
+	if err := db.DB.DeleteTOTPSecret(ctx, userID); err != nil {
+		return err
+	}
+	return db.DB.DeleteRecoveryCodes(ctx, userID)
+}
+
+// mfaMethods returns the second factors the user can log in with. Logging in requires one if it's not empty.
+func mfaMethods(ctx context.Context, userID string) ([]string, error) {
+	var methods []string
+
+	enabled, err := totpEnabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		methods = append(methods, MFAMethodTOTP)
+	}
+
+	credentials, err := db.DB.UserWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) > 0 {
+		methods = append(methods, MFAMethodWebAuthn)
+	}
+
+	return methods, nil
+}
+
+// totpEnabled reports whether the user confirmed a TOTP secret.
+func totpEnabled(ctx context.Context, userID string) (bool, error) {
+	secret, err := db.DB.TOTPSecret(ctx, userID)
+	if errors.Is(err, db.ErrNotFound) {
+		return false, nil
+	}
+	return secret.Enabled, err
+}
+
+// checkSecondFactor reports whether code is a valid code from the user's authenticator app or one of their
+// recovery codes. Either can only be used once.
+func checkSecondFactor(ctx context.Context, userID, code string) (bool, error) {
+	if len(code) == totpDigits {
+		return checkTOTP(ctx, userID, code)
+	}
+	return db.DB.UseRecoveryCode(ctx, userID, HashToken(normalizeRecoveryCode(code)))
+}
+
+// checkTOTP reports whether code is a valid code from the user's authenticator app that wasn't used yet.
+func checkTOTP(ctx context.Context, userID, code string) (bool, error) {
+	secret, err := db.DB.TOTPSecret(ctx, userID)
+	if errors.Is(err, db.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if !secret.Enabled {
+		return false, nil
+	}
+
+	step, ok := matchTOTP(secret.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return db.DB.UseTOTPStep(ctx, userID, step)
+}
+
+// replaceRecoveryCodes generates new recovery codes for the user and stores them in place of the old ones.
+func replaceRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	if err := db.DB.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+	codes := generateRecoveryCodes()
+	for _, code := range codes {
+		recoveryCode := db.RecoveryCode{UserID: userID, CodeHash: HashToken(normalizeRecoveryCode(code))}
+		if err := db.DB.AddRecoveryCode(ctx, recoveryCode); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// generateMFAToken returns a token that proves the user entered their password,
+// which LoginMFA accepts along with a second factor.
+func generateMFAToken(userID string) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(config.Conf.Auth.JWT.SigningMethod), &TokenClaims{
+		UserID:  userID,
+		Purpose: PurposeMFA,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaTokenExpiration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	})
+	return token.SignedString(privateSigningKey(config.Conf.Auth.JWT.SigningMethod, config.Conf.Auth.JWT.SigningKey))
+}
+
+func parseMFAToken(raw string) (*TokenClaims, error) {
+	claims := new(TokenClaims)
+	_, err := jwt.ParseWithClaims(raw, claims, func(*jwt.Token) (any, error) {
+		return SigningKey(config.Conf.Auth.JWT.SigningMethod, config.Conf.Auth.JWT.SigningKey), nil
+	}, jwt.WithValidMethods([]string{config.Conf.Auth.JWT.SigningMethod}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != PurposeMFA || claims.UserID == "" {
+		return nil, errors.New("not an MFA token")
+	}
+	return claims, nil
+}
+
+// mfaAttemptsExceeded reports whether the MFA token has been used up or has no attempts left.
+func mfaAttemptsExceeded(token string) bool {
+	secondFactorMu.Lock()
+	defer secondFactorMu.Unlock()
+	return mfaAttempts[token].count >= maxMFAAttempts
+}
+
+// verifySecondFactor runs check to verify a second factor of the user, unless the account has to wait after failed
+// logins or the MFA token has no attempts left. Failures count towards the login throttling of the account, so
+// requesting new MFA tokens doesn't give more guesses, and towards the attempts of the MFA token, if there is one.
+// A successful attempt with an MFA token uses it up and forgets the account's failed logins.
+// If it reports false, the response has been sent, with incorrect if the second factor was wrong,
+// and the error is that of sending it.
+func verifySecondFactor(
+	c echo.Context, userID, mfaToken string, expiration time.Time, check func() (bool, error), incorrect func() error,
+) (bool, error) {
+	defer lockSecondFactor(userID)()
+
+	account, ip := loginAccountKey(media.DatabaseUser{User: media.User{ID: userID}}, ""), c.RealIP()
+	if wait := loginWait(account, ip); wait > 0 {
+		return false, loginThrottled(c, wait)
+	}
+	if mfaToken != "" && mfaAttemptsExceeded(mfaToken) {
+		return false, c.JSON(http.StatusUnauthorized, echo.Map{
+			"message": "MFA token can no longer be used, log in again",
+		})
+	}
+
+	ok, err := check()
+	if err != nil {
+		log.Error("Error checking second factor", "err", err, "userID", userID)
+		return false, c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	if mfaToken != "" {
+		recordMFAAttempt(mfaToken, expiration, ok)
+	}
+	if !ok {
+		countFailedLogin(c, account, userID, "")
+		return false, incorrect()
+	}
+	if mfaToken != "" {
+		recordLoginSuccess(account)
+	}
+	return true, nil
+}
+
+// recordMFAAttempt counts a failed attempt of an MFA token, or uses it up if the attempt succeeded,
+// so each MFA token logs in at most once.
+func recordMFAAttempt(token string, expiration time.Time, succeeded bool) {
+	secondFactorMu.Lock()
+	defer secondFactorMu.Unlock()
+
+	attempt := mfaAttempts[token]
+	attempt.count++
+	if succeeded {
+		attempt.count = maxMFAAttempts
+	}
+	attempt.expiration = expiration
+	mfaAttempts[token] = attempt
+}
+
+// incorrectCode responds that the code sent to change two-factor authentication settings was wrong.
+func incorrectCode(c echo.Context) func() error {
+	return func() error {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "incorrect code",
+		})
+	}
+}
+
+// sweepMFAAttempts forgets the attempts of expired MFA tokens.
+func sweepMFAAttempts(now time.Time) {
+	secondFactorMu.Lock()
+	defer secondFactorMu.Unlock()
+	for token, attempt := range mfaAttempts {
+		if now.After(attempt.expiration) {
+			delete(mfaAttempts, token)
+		}
+	}
+}
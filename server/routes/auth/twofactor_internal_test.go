@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/config"
+)
+
+// trySecondFactor verifies a second factor for user0000001 from 192.0.2.1 that is right if correct is,
+// and returns the status code of the response.
+func trySecondFactor(t *testing.T, mfaToken string, correct bool) int {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login/mfa", http.NoBody)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	check := func() (bool, error) { return correct, nil }
+	verified, err := verifySecondFactor(c, "user0000001", mfaToken, time.Now().Add(time.Minute), check, incorrectCode(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified {
+		return http.StatusOK
+	}
+	return rec.Code
+}
+
+func TestSecondFactorFailuresCountTowardsTheAccount(t *testing.T) {
+	setupLoginThrottling(t)
+	t.Cleanup(func() { clear(mfaAttempts) })
+
+	// Each failure counts, even with a new MFA token, so the backoff starts after the free attempts.
+	for _, token := range []string{"token1", "token2"} {
+		if code := trySecondFactor(t, token, false); code != http.StatusBadRequest {
+			t.Fatalf("wrong code with %s got %d", token, code)
+		}
+	}
+	if code := trySecondFactor(t, "token3", true); code != http.StatusTooManyRequests {
+		t.Errorf("attempt during the backoff got %d, want 429", code)
+	}
+}
+
+func TestSecondFactorSuccessForgetsFailures(t *testing.T) {
+	setupLoginThrottling(t)
+	t.Cleanup(func() { clear(mfaAttempts) })
+
+	trySecondFactor(t, "token1", false)
+	if code := trySecondFactor(t, "token1", true); code != http.StatusOK {
+		t.Fatalf("right code got %d", code)
+	}
+	if wait := loginWait("id:user0000001", "192.0.2.2"); wait != 0 {
+		t.Errorf("account has to wait %s after logging in", wait)
+	}
+	if code := trySecondFactor(t, "token1", true); code != http.StatusUnauthorized {
+		t.Errorf("used MFA token got %d, want 401", code)
+	}
+}
+
+func TestConcurrentSecondFactorsCantExceedTheTokenLimit(t *testing.T) {
+	setupLoginThrottling(t)
+	t.Cleanup(func() { clear(mfaAttempts) })
+	// Without throttling, only the attempts of the MFA token limit how often codes are checked.
+	config.Conf.Auth.LoginThrottling.Enabled = false
+
+	var checks atomic.Int32
+	var wg sync.WaitGroup
+	for range 4 * maxMFAAttempts {
+		wg.Go(func() {
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/login/mfa", http.NoBody)
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+			check := func() (bool, error) {
+				checks.Add(1)
+				return false, nil
+			}
+			_, _ = verifySecondFactor(c, "user0000001", "token1", time.Now().Add(time.Minute), check, incorrectCode(c))
+		})
+	}
+	wg.Wait()
+
+	if checks.Load() != maxMFAAttempts {
+		t.Errorf("checked %d codes with one MFA token, want %d", checks.Load(), maxMFAAttempts)
+	}
+}
+
+func TestSecondFactorsOfOtherUsersDontWait(t *testing.T) {
+	setupLoginThrottling(t)
+	t.Cleanup(func() { clear(mfaAttempts) })
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login/mfa", http.NoBody)
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+		check := func() (bool, error) {
+			close(started)
+			<-release
+			return true, nil
+		}
+		_, _ = verifySecondFactor(c, "user0000002", "token2", time.Now().Add(time.Minute), check, incorrectCode(c))
+	}()
+	<-started
+
+	// The other user's check is still running, which mustn't hold this one up.
+	if code := trySecondFactor(t, "token1", true); code != http.StatusOK {
+		t.Errorf("right code got %d while another user's check was running", code)
+	}
+	close(release)
+	<-done
+
+	secondFactorMu.Lock()
+	defer secondFactorMu.Unlock()
+	if len(secondFactorLocks) != 0 {
+		t.Errorf("%d locks left after all attempts finished", len(secondFactorLocks))
+	}
+}
+
+func TestSweepMFAAttempts(t *testing.T) {
+	t.Cleanup(func() { clear(mfaAttempts) })
+
+	mfaAttempts["token1"] = mfaAttempt{count: 1, expiration: time.Now().Add(time.Minute)}
+	mfaAttempts["token2"] = mfaAttempt{count: 1, expiration: time.Now().Add(time.Hour)}
+
+	sweepMFAAttempts(time.Now().Add(30 * time.Minute))
+	if _, ok := mfaAttempts["token1"]; ok {
+		t.Error("attempts of an expired MFA token are kept")
+	}
+	if _, ok := mfaAttempts["token2"]; !ok {
+		t.Error("attempts of an MFA token that hasn't expired are forgotten")
+	}
+}
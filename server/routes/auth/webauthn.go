@@ -0,0 +1,582 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/goccy/go-json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+)
+
+// How long the user has to respond to a registration or login ceremony.
+const webAuthnCeremonyTimeout = 5 * time.Minute
+
+// Ceremonies that were started but not finished yet, by ceremony ID. Each can only be finished once.
+var (
+	webAuthnCeremonies   = map[string]webAuthnCeremony{}
+	webAuthnCeremoniesMu sync.Mutex
+)
+
+type webAuthnCeremony struct {
+	session      webauthn.SessionData
+	registration bool
+
+	// The user the ceremony was started for. Empty for passwordless logins, where the passkey identifies the user.
+	userID string
+
+	// The MFA token of a login that uses a passkey as the second factor.
+	mfaToken string
+}
+
+// webAuthnUser adapts a user and their passkeys to the WebAuthn library.
+type webAuthnUser struct {
+	id          string
+	username    string
+	displayName string
+	credentials []webauthn.Credential
+}
+
+func (u webAuthnUser) WebAuthnID() []byte {
+	return []byte(u.id)
+}
+
+func (u webAuthnUser) WebAuthnName() string {
+	return u.username
+}
+
+func (u webAuthnUser) WebAuthnDisplayName() string {
+	if u.displayName == "" {
+		return u.username
+	}
+	return u.displayName
+}
+
+func (u webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+type webAuthnCeremonyResponse struct {
+	CeremonyID string `json:"ceremony_id"`
+
+	// Passed to navigator.credentials.create or navigator.credentials.get.
+	Options any `json:"options"`
+}
+
+type webAuthnRegistrationRequest struct {
+	CeremonyID string `json:"ceremony_id"`
+
+	// A name for the passkey, shown in the list of passkeys.
+	Name string `json:"name"`
+
+	// The PublicKeyCredential returned by navigator.credentials.create.
+	Credential json.RawMessage `json:"credential"`
+}
+
+type webAuthnLoginBeginRequest struct {
+	// The MFA token returned by Login, when using a passkey as the second factor.
+	// Without it, the login is passwordless and any of the user's passkeys can be used.
+	MFAToken string `json:"mfa_token"`
+}
+
+type webAuthnLoginFinishRequest struct {
+	CeremonyID string `json:"ceremony_id"`
+
+	// The PublicKeyCredential returned by navigator.credentials.get.
+	Credential json.RawMessage `json:"credential"`
+
+	// The name of the device, shown in the list of sessions.
+	DeviceName string `json:"device_name"`
+}
+
+type webAuthnCredentialResponse struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	CreationDate int64  `json:"creation_date"`
+	LastUsed     int64  `json:"last_used"`
+}
+
+// BeginWebAuthnRegistration starts registering a new passkey for the user.
+func BeginWebAuthnRegistration(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+	ctx := c.Request().Context()
+
+	relyingParty, err := defaultRelyingParty()
+	if err != nil {
+		log.Error("Error creating WebAuthn relying party", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	user, err := loadWebAuthnUser(ctx, claims.UserID)
+	if err != nil {
+		log.Error("Error getting passkeys", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	creation, session, err := relyingParty.BeginRegistration(user,
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		log.Error("Error starting passkey registration", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	ceremonyID := startWebAuthnCeremony(webAuthnCeremony{
+		session:      *session,
+		registration: true,
+		userID:       claims.UserID,
+	})
+	return c.JSON(http.StatusOK, webAuthnCeremonyResponse{
+		CeremonyID: ceremonyID,
+		Options:    creation,
+	})
+}
+
+// FinishWebAuthnRegistration verifies the new passkey and stores it.
+func FinishWebAuthnRegistration(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+	ctx := c.Request().Context()
+
+	var req webAuthnRegistrationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	ceremony, ok := takeWebAuthnCeremony(req.CeremonyID)
+	if !ok || !ceremony.registration || ceremony.userID != claims.UserID {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "invalid or expired ceremony",
+		})
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "invalid credential",
+		})
+	}
+
+	relyingParty, err := defaultRelyingParty()
+	if err != nil {
+		log.Error("Error creating WebAuthn relying party", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	user, err := loadWebAuthnUser(ctx, claims.UserID)
+	if err != nil {
+		log.Error("Error getting passkeys", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	credential, err := relyingParty.CreateCredential(user, ceremony.session, parsed)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "passkey verification failed",
+		})
+	}
+
+	name := req.Name
+	if name == "" {
+		name = "Passkey"
+	}
+	row, err := encodeWebAuthnCredential(claims.UserID, name, *credential)
+	if err != nil {
+		log.Error("Error encoding passkey", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	if err := db.DB.AddWebAuthnCredential(ctx, row); err != nil {
+		log.Error("Error storing passkey", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	return c.JSON(http.StatusOK, newWebAuthnCredentialResponse(row))
+}
+
+// WebAuthnCredentials lists the user's passkeys.
+func WebAuthnCredentials(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+
+	rows, err := db.DB.UserWebAuthnCredentials(c.Request().Context(), claims.UserID)
+	if err != nil {
+		log.Error("Error getting passkeys", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	credentials := make([]webAuthnCredentialResponse, 0, len(rows))
+	for _, row := range rows {
+		credentials = append(credentials, newWebAuthnCredentialResponse(row))
+	}
+	return c.JSON(http.StatusOK, credentials)
+}
+
+// DeleteWebAuthnCredential removes one of the user's passkeys.
+func DeleteWebAuthnCredential(c echo.Context) error {
+	claims := c.Get("user").(*jwt.Token).Claims.(*TokenClaims)
+	ctx := c.Request().Context()
+
+	credential, err := db.DB.WebAuthnCredential(ctx, c.Param("id"))
+	if errors.Is(err, db.ErrNotFound) || (err == nil && credential.UserID != claims.UserID) {
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"message": "passkey not found",
+		})
+	} else if err != nil {
+		log.Error("Error getting passkey", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	if err := db.DB.DeleteWebAuthnCredential(ctx, credential.ID); err != nil {
+		log.Error("Error deleting passkey", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// BeginWebAuthnLogin starts logging in with a passkey, either without a password or as the second factor of a
+// login that returned an MFA token.
+func BeginWebAuthnLogin(c echo.Context) error {
+	var req webAuthnLoginBeginRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	relyingParty, err := defaultRelyingParty()
+	if err != nil {
+		log.Error("Error creating WebAuthn relying party", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	if req.MFAToken == "" {
+		// The user picks a passkey in the browser, which requires user verification, so it counts as both factors.
+		assertion, session, err := relyingParty.BeginDiscoverableLogin(
+			webauthn.WithUserVerification(protocol.VerificationRequired),
+		)
+		if err != nil {
+			log.Error("Error starting passkey login", "err", err)
+			return c.JSON(http.StatusInternalServerError, echo.Map{
+				"message": "internal server error",
+			})
+		}
+		return c.JSON(http.StatusOK, webAuthnCeremonyResponse{
+			CeremonyID: startWebAuthnCeremony(webAuthnCeremony{session: *session}),
+			Options:    assertion,
+		})
+	}
+
+	claims, err := parseMFAToken(req.MFAToken)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{
+			"message": "invalid MFA token",
+		})
+	}
+	if mfaAttemptsExceeded(req.MFAToken) {
+		return c.JSON(http.StatusUnauthorized, echo.Map{
+			"message": "MFA token can no longer be used, log in again",
+		})
+	}
+
+	user, err := loadWebAuthnUser(c.Request().Context(), claims.UserID)
+	if err != nil {
+		log.Error("Error getting passkeys", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	if len(user.credentials) == 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "no passkeys registered",
+		})
+	}
+
+	assertion, session, err := relyingParty.BeginLogin(user)
+	if err != nil {
+		log.Error("Error starting passkey login", "err", err, "userID", claims.UserID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+	return c.JSON(http.StatusOK, webAuthnCeremonyResponse{
+		CeremonyID: startWebAuthnCeremony(webAuthnCeremony{
+			session:  *session,
+			userID:   claims.UserID,
+			mfaToken: req.MFAToken,
+		}),
+		Options: assertion,
+	})
+}
+
+// FinishWebAuthnLogin verifies the passkey's assertion and returns an access token and refresh token.
+func FinishWebAuthnLogin(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req webAuthnLoginFinishRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": err.Error(),
+		})
+	}
+
+	ceremony, ok := takeWebAuthnCeremony(req.CeremonyID)
+	if !ok || ceremony.registration {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "invalid or expired ceremony",
+		})
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "invalid credential",
+		})
+	}
+
+	relyingParty, err := defaultRelyingParty()
+	if err != nil {
+		log.Error("Error creating WebAuthn relying party", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	var (
+		userID     string
+		credential *webauthn.Credential
+	)
+	if ceremony.mfaToken == "" {
+		var user webauthn.User
+		user, credential, err = relyingParty.ValidatePasskeyLogin(discoverableUserHandler(ctx), ceremony.session, parsed)
+		if err == nil {
+			userID = string(user.WebAuthnID())
+		}
+	} else {
+		claims, parseErr := parseMFAToken(ceremony.mfaToken)
+		if parseErr != nil {
+			return c.JSON(http.StatusUnauthorized, echo.Map{
+				"message": "MFA token can no longer be used, log in again",
+			})
+		}
+		user, loadErr := loadWebAuthnUser(ctx, ceremony.userID)
+		if loadErr != nil {
+			log.Error("Error getting passkeys", "err", loadErr, "userID", ceremony.userID)
+			return c.JSON(http.StatusInternalServerError, echo.Map{
+				"message": "internal server error",
+			})
+		}
+		validate := func() (bool, error) {
+			var validateErr error
+			credential, validateErr = relyingParty.ValidateLogin(user, ceremony.session, parsed)
+			return validateErr == nil, nil
+		}
+		failed := func() error {
+			return c.JSON(http.StatusUnauthorized, echo.Map{
+				"message": "passkey verification failed",
+			})
+		}
+		verified, sendErr := verifySecondFactor(
+			c, ceremony.userID, ceremony.mfaToken, claims.ExpiresAt.Time, validate, failed,
+		)
+		if !verified {
+			return sendErr
+		}
+		userID = ceremony.userID
+	}
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, echo.Map{
+			"message": "passkey verification failed",
+		})
+	}
+
+	// A signature counter that went backwards means the authenticator may have been cloned.
+	if credential.Authenticator.CloneWarning {
+		log.Warn("Passkey signature counter went backwards, it may have been cloned", "userID", userID)
+		return c.JSON(http.StatusUnauthorized, echo.Map{
+			"message": "passkey verification failed",
+		})
+	}
+	if err := updateWebAuthnCredential(ctx, *credential); err != nil {
+		log.Error("Error storing passkey", "err", err, "userID", userID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "internal server error",
+		})
+	}
+
+	tokens, err := startSession(c, userID, req.DeviceName)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// defaultRelyingParty returns the relying party for the configured public URL.
+func defaultRelyingParty() (*webauthn.WebAuthn, error) {
+	return newRelyingParty(config.Conf.Application.PublicURL, config.Conf.Application.SourceName)
+}
+
+// newRelyingParty returns a relying party whose ID is the host name of publicURL, accepting ceremonies from its
+// origin. Passkeys are bound to the relying party ID, so changing the public URL's host name invalidates them.
+func newRelyingParty(publicURL, displayName string) (*webauthn.WebAuthn, error) {
+	u, err := url.Parse(publicURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Hostname() == "" {
+		return nil, errors.New("public URL must be an absolute URL")
+	}
+
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: webAuthnCeremonyTimeout, TimeoutUVD: webAuthnCeremonyTimeout}
+	return webauthn.New(&webauthn.Config{
+		RPID:          u.Hostname(),
+		RPDisplayName: displayName,
+		RPOrigins:     []string{u.Scheme + "://" + u.Host},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        timeout,
+			Registration: timeout,
+		},
+	})
+}
+
+// loadWebAuthnUser returns the user with their passkeys.
+func loadWebAuthnUser(ctx context.Context, userID string) (webAuthnUser, error) {
+	user, err := db.DB.User(ctx, userID)
+	if err != nil {
+		return webAuthnUser{}, err
+	}
+	rows, err := db.DB.UserWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return webAuthnUser{}, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(rows))
+	for _, row := range rows {
+		var credential webauthn.Credential
+		if err := json.Unmarshal([]byte(row.Credential), &credential); err != nil {
+			return webAuthnUser{}, err
+		}
+		credentials = append(credentials, credential)
+	}
+
+	return webAuthnUser{
+		id:          user.ID,
+		username:    user.Username,
+		displayName: user.DisplayName,
+		credentials: credentials,
+	}, nil
+}
+
+// discoverableUserHandler returns a function that finds the owner of the passkey used in a passwordless login.
+func discoverableUserHandler(ctx context.Context) webauthn.DiscoverableUserHandler {
+	return func(rawID, userHandle []byte) (webauthn.User, error) {
+		credential, err := db.DB.WebAuthnCredential(ctx, base64.RawURLEncoding.EncodeToString(rawID))
+		if err != nil {
+			return nil, err
+		}
+		if credential.UserID != string(userHandle) {
+			return nil, errors.New("passkey belongs to another user")
+		}
+		user, err := loadWebAuthnUser(ctx, credential.UserID)
+		return user, err
+	}
+}
+
+// updateWebAuthnCredential stores the credential's new signature counter and flags after a login.
+func updateWebAuthnCredential(ctx context.Context, credential webauthn.Credential) error {
+	row, err := db.DB.WebAuthnCredential(ctx, base64.RawURLEncoding.EncodeToString(credential.ID))
+	if err != nil {
+		return err
+	}
+	updated, err := encodeWebAuthnCredential(row.UserID, row.Name, credential)
+	if err != nil {
+		return err
+	}
+	updated.CreationDate = row.CreationDate
+	updated.LastUsed = time.Now().Unix()
+	return db.DB.UpdateWebAuthnCredential(ctx, updated)
+}
+
+func encodeWebAuthnCredential(userID, name string, credential webauthn.Credential) (db.WebAuthnCredential, error) {
+	encoded, err := json.Marshal(credential)
+	if err != nil {
+		return db.WebAuthnCredential{}, err
+	}
+	return db.WebAuthnCredential{
+		ID:           base64.RawURLEncoding.EncodeToString(credential.ID),
+		UserID:       userID,
+		Name:         name,
+		Credential:   string(encoded),
+		CreationDate: time.Now().Unix(),
+	}, nil
+}
+
+func newWebAuthnCredentialResponse(credential db.WebAuthnCredential) webAuthnCredentialResponse {
+	return webAuthnCredentialResponse{
+		ID:           credential.ID,
+		Name:         credential.Name,
+		CreationDate: credential.CreationDate,
+		LastUsed:     credential.LastUsed,
+	}
+}
+
+// startWebAuthnCeremony stores the ceremony until it's finished or expires and returns its ID.
+func startWebAuthnCeremony(ceremony webAuthnCeremony) string {
+	webAuthnCeremoniesMu.Lock()
+	defer webAuthnCeremoniesMu.Unlock()
+
+	// Forget ceremonies that expired, as they can't be finished anymore anyway.
+	now := time.Now()
+	for id, c := range webAuthnCeremonies {
+		if now.After(c.session.Expires) {
+			delete(webAuthnCeremonies, id)
+		}
+	}
+
+	id := rand.Text()
+	webAuthnCeremonies[id] = ceremony
+	return id
+}
+
+// takeWebAuthnCeremony returns the ceremony and removes it, so it can't be finished twice.
+func takeWebAuthnCeremony(id string) (webAuthnCeremony, bool) {
+	webAuthnCeremoniesMu.Lock()
+	defer webAuthnCeremoniesMu.Unlock()
+
+	ceremony, ok := webAuthnCeremonies[id]
+	delete(webAuthnCeremonies, id)
+	if !ok || time.Now().After(ceremony.session.Expires) {
+		return webAuthnCeremony{}, false
+	}
+	return ceremony, true
+}
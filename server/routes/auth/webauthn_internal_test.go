@@ -0,0 +1,291 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/goccy/go-json"
+)
+
+const (
+	testPublicURL = "https://libra.example.com:8443/app"
+	testOrigin    = "https://libra.example.com:8443"
+)
+
+// softwareAuthenticator is a passkey authenticator with an ES256 key that attests with the "none" format.
+type softwareAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftwareAuthenticator(t *testing.T) *softwareAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	_, _ = rand.Read(credentialID)
+	return &softwareAuthenticator{t: t, key: key, credentialID: credentialID}
+}
+
+// create responds to navigator.credentials.create.
+func (a *softwareAuthenticator) create(creation *protocol.CredentialCreation, origin string) []byte {
+	a.t.Helper()
+
+	a.userHandle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+	clientData := a.clientData(protocol.CreateCeremony, creation.Response.Challenge, origin)
+
+	publicKey, err := webauthncbor.Marshal(map[int]any{
+		1:  2,  // Key type: EC2
+		3:  -7, // Algorithm: ES256
+		-1: 1,  // Curve: P-256
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	authData := a.authData(creation.Response.RelyingParty.ID, protocol.FlagAttestedCredentialData)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.response(map[string]any{
+		"clientDataJSON":    clientData,
+		"attestationObject": attestation,
+	})
+}
+
+// get responds to navigator.credentials.get.
+func (a *softwareAuthenticator) get(assertion *protocol.CredentialAssertion, origin string) []byte {
+	a.t.Helper()
+
+	clientData := a.clientData(protocol.AssertCeremony, assertion.Response.Challenge, origin)
+	authData := a.authData(assertion.Response.RelyingPartyID, 0)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.response(map[string]any{
+		"clientDataJSON":    clientData,
+		"authenticatorData": authData,
+		"signature":         signature,
+		"userHandle":        a.userHandle,
+	})
+}
+
+func (a *softwareAuthenticator) clientData(
+	ceremony protocol.CeremonyType, challenge protocol.URLEncodedBase64, origin string,
+) []byte {
+	clientData, err := json.Marshal(map[string]any{
+		"type":      ceremony,
+		"challenge": challenge.String(),
+		"origin":    origin,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return clientData
+}
+
+// authData returns the authenticator data of a user-verified response and increments the signature counter.
+func (a *softwareAuthenticator) authData(rpID string, flags protocol.AuthenticatorFlags) []byte {
+	a.signCount++
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	authData := append(rpIDHash[:], byte(protocol.FlagUserPresent|protocol.FlagUserVerified|flags))
+	return binary.BigEndian.AppendUint32(authData, a.signCount)
+}
+
+func (a *softwareAuthenticator) response(response map[string]any) []byte {
+	for name, value := range response {
+		response[name] = base64.RawURLEncoding.EncodeToString(value.([]byte))
+	}
+	id := base64.RawURLEncoding.EncodeToString(a.credentialID)
+	body, err := json.Marshal(map[string]any{
+		"id":       id,
+		"rawId":    id,
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return body
+}
+
+// register runs a registration ceremony and returns the user with the new passkey, stored and loaded like the
+// handlers do.
+func register(t *testing.T, relyingParty *webauthn.WebAuthn, authenticator *softwareAuthenticator) webAuthnUser {
+	t.Helper()
+
+	user := webAuthnUser{id: "TPkrKcIZRRq", username: "alice"}
+	creation, session, err := relyingParty.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(authenticator.create(creation, testOrigin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := relyingParty.CreateCredential(user, *session, parsed)
+	if err != nil {
+		t.Fatalf("registration failed: %v", err)
+	}
+
+	row, err := encodeWebAuthnCredential(user.id, "Test", *credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.ID != base64.RawURLEncoding.EncodeToString(authenticator.credentialID) {
+		t.Fatalf("credential ID is %q", row.ID)
+	}
+	var stored webauthn.Credential
+	if err := json.Unmarshal([]byte(row.Credential), &stored); err != nil {
+		t.Fatal(err)
+	}
+	user.credentials = []webauthn.Credential{stored}
+	return user
+}
+
+func TestNewRelyingParty(t *testing.T) {
+	relyingParty, err := newRelyingParty(testPublicURL, "Libra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if relyingParty.Config.RPID != "libra.example.com" {
+		t.Errorf("RPID is %q", relyingParty.Config.RPID)
+	}
+	if len(relyingParty.Config.RPOrigins) != 1 || relyingParty.Config.RPOrigins[0] != testOrigin {
+		t.Errorf("RPOrigins is %v", relyingParty.Config.RPOrigins)
+	}
+
+	if _, err := newRelyingParty("libra.example.com", "Libra"); err == nil {
+		t.Error("expected an error for a relative public URL")
+	}
+}
+
+func TestWebAuthnPasskeyLogin(t *testing.T) {
+	relyingParty, err := newRelyingParty(testPublicURL, "Libra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator := newSoftwareAuthenticator(t)
+	user := register(t, relyingParty, authenticator)
+
+	assertion, session, err := relyingParty.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(authenticator.get(assertion, testOrigin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loggedIn, credential, err := relyingParty.ValidatePasskeyLogin(
+		func(rawID, userHandle []byte) (webauthn.User, error) {
+			if string(userHandle) != user.id {
+				t.Errorf("user handle is %q", userHandle)
+			}
+			return user, nil
+		}, *session, parsed)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if string(loggedIn.WebAuthnID()) != user.id {
+		t.Errorf("logged in as %q", loggedIn.WebAuthnID())
+	}
+	if credential.Authenticator.SignCount != authenticator.signCount || credential.Authenticator.CloneWarning {
+		t.Errorf("sign count is %d, clone warning %v", credential.Authenticator.SignCount,
+			credential.Authenticator.CloneWarning)
+	}
+}
+
+func TestWebAuthnSecondFactor(t *testing.T) {
+	relyingParty, err := newRelyingParty(testPublicURL, "Libra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator := newSoftwareAuthenticator(t)
+	user := register(t, relyingParty, authenticator)
+
+	assertion, session, err := relyingParty.BeginLogin(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(authenticator.get(assertion, testOrigin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := relyingParty.ValidateLogin(user, *session, parsed); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+}
+
+func TestWebAuthnRejectsOtherOrigins(t *testing.T) {
+	relyingParty, err := newRelyingParty(testPublicURL, "Libra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator := newSoftwareAuthenticator(t)
+	user := register(t, relyingParty, authenticator)
+
+	assertion, session, err := relyingParty.BeginLogin(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := authenticator.get(assertion, "https://evil.example.com")
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := relyingParty.ValidateLogin(user, *session, parsed); err == nil {
+		t.Error("expected a login from another origin to fail")
+	}
+}
+
+func TestWebAuthnCeremoniesAreSingleUse(t *testing.T) {
+	relyingParty, err := newRelyingParty(testPublicURL, "Libra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, session, err := relyingParty.BeginDiscoverableLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := startWebAuthnCeremony(webAuthnCeremony{session: *session})
+	if _, ok := takeWebAuthnCeremony(id); !ok {
+		t.Fatal("ceremony not found")
+	}
+	if _, ok := takeWebAuthnCeremony(id); ok {
+		t.Error("ceremony was finished twice")
+	}
+}
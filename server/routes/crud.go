@@ -0,0 +1,318 @@
+package routes
+
+import "github.com/labstack/echo/v4"
+
+// @Summary	Create a track
+// @Description	The track is owned by the authenticated user. Its ID, owner and counts are set by the server.
+// @ID			createTrack
+// @Accept		json
+// @Param		track	body		media.Track	true	"Track"
+// @Success	201		{object}	media.Track
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	500		{object}	any
+// @Router		/track [post]
+func V1CreateTrack(c echo.Context) error {
+	return createPlayable(c, trackResource)
+}
+
+// @Summary	Replace a track
+// @Description	Fields that are left out are cleared. The ID, owner and counts can't be changed.
+// @ID			replaceTrack
+// @Accept		json
+// @Param		id		path		string		true	"Track ID"
+// @Param		track	body		media.Track	true	"Track"
+// @Success	200		{object}	media.Track
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	403		{object}	any
+// @Failure	404		{object}	any
+// @Failure	500		{object}	any
+// @Router		/track/{id} [put]
+func V1ReplaceTrack(c echo.Context) error {
+	return replacePlayable(c, trackResource)
+}
+
+// @Summary	Update a track
+// @Description	Applies a JSON Merge Patch (RFC 7386). The ID, owner and counts can't be changed.
+// @ID			updateTrack
+// @Accept		application/merge-patch+json
+// @Param		id		path		string		true	"Track ID"
+// @Param		patch	body		media.Track	true	"Merge patch"
+// @Success	200		{object}	media.Track
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	403		{object}	any
+// @Failure	404		{object}	any
+// @Failure	415		{object}	any
+// @Failure	500		{object}	any
+// @Router		/track/{id} [patch]
+func V1UpdateTrack(c echo.Context) error {
+	return patchPlayable(c, trackResource)
+}
+
+// @Summary	Delete a track
+// @ID			deleteTrack
+// @Param		id	path	string	true	"Track ID"
+// @Success	204
+// @Failure	401	{object}	any
+// @Failure	403	{object}	any
+// @Failure	404	{object}	any
+// @Failure	500	{object}	any
+// @Router		/track/{id} [delete]
+func V1DeleteTrack(c echo.Context) error {
+	return deletePlayable(c, trackResource)
+}
+
+// @Summary	Create a album
+// @Description	The album is owned by the authenticated user. Its ID, owner and counts are set by the server.
+// @ID			createAlbum
+// @Accept		json
+// @Param		album	body		media.Album	true	"Album"
+// @Success	201		{object}	media.Album
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	500		{object}	any
+// @Router		/album [post]
+func V1CreateAlbum(c echo.Context) error {
+	return createPlayable(c, albumResource)
+}
+
+// @Summary	Replace a album
+// @Description	Fields that are left out are cleared. The ID, owner and counts can't be changed.
+// @ID			replaceAlbum
+// @Accept		json
+// @Param		id		path		string		true	"Album ID"
+// @Param		album	body		media.Album	true	"Album"
+// @Success	200		{object}	media.Album
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	403		{object}	any
+// @Failure	404		{object}	any
+// @Failure	500		{object}	any
+// @Router		/album/{id} [put]
+func V1ReplaceAlbum(c echo.Context) error {
+	return replacePlayable(c, albumResource)
+}
+
+// @Summary	Update a album
+// @Description	Applies a JSON Merge Patch (RFC 7386). The ID, owner and counts can't be changed.
+// @ID			updateAlbum
+// @Accept		application/merge-patch+json
+// @Param		id		path		string		true	"Album ID"
+// @Param		patch	body		media.Album	true	"Merge patch"
+// @Success	200		{object}	media.Album
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	403		{object}	any
+// @Failure	404		{object}	any
+// @Failure	415		{object}	any
+// @Failure	500		{object}	any
+// @Router		/album/{id} [patch]
+func V1UpdateAlbum(c echo.Context) error {
+	return patchPlayable(c, albumResource)
+}
+
+// @Summary	Delete a album
+// @ID			deleteAlbum
+// @Param		id	path	string	true	"Album ID"
+// @Success	204
+// @Failure	401	{object}	any
+// @Failure	403	{object}	any
+// @Failure	404	{object}	any
+// @Failure	500	{object}	any
+// @Router		/album/{id} [delete]
+func V1DeleteAlbum(c echo.Context) error {
+	return deletePlayable(c, albumResource)
+}
+
+// @Summary	Create a video
+// @Description	The video is owned by the authenticated user. Its ID, owner and counts are set by the server.
+// @ID			createVideo
+// @Accept		json
+// @Param		video	body		media.Video	true	"Video"
+// @Success	201		{object}	media.Video
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	500		{object}	any
+// @Router		/video [post]
+func V1CreateVideo(c echo.Context) error {
+	return createPlayable(c, videoResource)
+}
+
+// @Summary	Replace a video
+// @Description	Fields that are left out are cleared. The ID, owner and counts can't be changed.
+// @ID			replaceVideo
+// @Accept		json
+// @Param		id		path		string		true	"Video ID"
+// @Param		video	body		media.Video	true	"Video"
+// @Success	200		{object}	media.Video
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	403		{object}	any
+// @Failure	404		{object}	any
+// @Failure	500		{object}	any
+// @Router		/video/{id} [put]
+func V1ReplaceVideo(c echo.Context) error {
+	return replacePlayable(c, videoResource)
+}
+
+// @Summary	Update a video
+// @Description	Applies a JSON Merge Patch (RFC 7386). The ID, owner and counts can't be changed.
+// @ID			updateVideo
+// @Accept		application/merge-patch+json
+// @Param		id		path		string		true	"Video ID"
+// @Param		patch	body		media.Video	true	"Merge patch"
+// @Success	200		{object}	media.Video
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	403		{object}	any
+// @Failure	404		{object}	any
+// @Failure	415		{object}	any
+// @Failure	500		{object}	any
+// @Router		/video/{id} [patch]
+func V1UpdateVideo(c echo.Context) error {
+	return patchPlayable(c, videoResource)
+}
+
+// @Summary	Delete a video
+// @ID			deleteVideo
+// @Param		id	path	string	true	"Video ID"
+// @Success	204
+// @Failure	401	{object}	any
+// @Failure	403	{object}	any
+// @Failure	404	{object}	any
+// @Failure	500	{object}	any
+// @Router		/video/{id} [delete]
+func V1DeleteVideo(c echo.Context) error {
+	return deletePlayable(c, videoResource)
+}
+
+// @Summary	Create a artist
+// @Description	The artist is owned by the authenticated user. Its ID, owner and counts are set by the server.
+// @ID			createArtist
+// @Accept		json
+// @Param		artist	body		media.Artist	true	"Artist"
+// @Success	201		{object}	media.Artist
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	500		{object}	any
+// @Router		/artist [post]
+func V1CreateArtist(c echo.Context) error {
+	return createPlayable(c, artistResource)
+}
+
+// @Summary	Replace a artist
+// @Description	Fields that are left out are cleared. The ID, owner and counts can't be changed.
+// @ID			replaceArtist
+// @Accept		json
+// @Param		id		path		string		true	"Artist ID"
+// @Param		artist	body		media.Artist	true	"Artist"
+// @Success	200		{object}	media.Artist
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	403		{object}	any
+// @Failure	404		{object}	any
+// @Failure	500		{object}	any
+// @Router		/artist/{id} [put]
+func V1ReplaceArtist(c echo.Context) error {
+	return replacePlayable(c, artistResource)
+}
+
+// @Summary	Update a artist
+// @Description	Applies a JSON Merge Patch (RFC 7386). The ID, owner and counts can't be changed.
+// @ID			updateArtist
+// @Accept		application/merge-patch+json
+// @Param		id		path		string		true	"Artist ID"
+// @Param		patch	body		media.Artist	true	"Merge patch"
+// @Success	200		{object}	media.Artist
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	403		{object}	any
+// @Failure	404		{object}	any
+// @Failure	415		{object}	any
+// @Failure	500		{object}	any
+// @Router		/artist/{id} [patch]
+func V1UpdateArtist(c echo.Context) error {
+	return patchPlayable(c, artistResource)
+}
+
+// @Summary	Delete a artist
+// @ID			deleteArtist
+// @Param		id	path	string	true	"Artist ID"
+// @Success	204
+// @Failure	401	{object}	any
+// @Failure	403	{object}	any
+// @Failure	404	{object}	any
+// @Failure	500	{object}	any
+// @Router		/artist/{id} [delete]
+func V1DeleteArtist(c echo.Context) error {
+	return deletePlayable(c, artistResource)
+}
+
+// @Summary	Create a playlist
+// @Description	The playlist is owned by the authenticated user. Its ID, owner and counts are set by the server.
+// @Description	Playlists are private unless a visibility is given. Permissions share the playlist with other users
+// @Description	by mapping their IDs to the viewer or editor role.
+// @Description	Smart playlists (kind "smart") select their tracks with rules instead of track IDs, for example
+// @Description	tag = "jazz" AND release_date >= 1990 AND last_played < 30d ago ORDER BY listen_count DESC LIMIT 100.
+// @Description	Their tracks are re-evaluated when they're saved, when they're read after going stale and on a schedule.
+// @ID			createPlaylist
+// @Accept		json
+// @Param		playlist	body		media.Playlist	true	"Playlist"
+// @Success	201		{object}	media.Playlist
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	500		{object}	any
+// @Router		/playlist [post]
+func V1CreatePlaylist(c echo.Context) error {
+	return createPlayable(c, playlistResource)
+}
+
+// @Summary	Replace a playlist
+// @Description	Fields that are left out are cleared. The ID, owner and counts can't be changed.
+// @ID			replacePlaylist
+// @Accept		json
+// @Param		id		path		string		true	"Playlist ID"
+// @Param		playlist	body		media.Playlist	true	"Playlist"
+// @Success	200		{object}	media.Playlist
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	403		{object}	any
+// @Failure	404		{object}	any
+// @Failure	500		{object}	any
+// @Router		/playlist/{id} [put]
+func V1ReplacePlaylist(c echo.Context) error {
+	return replacePlayable(c, playlistResource)
+}
+
+// @Summary	Update a playlist
+// @Description	Applies a JSON Merge Patch (RFC 7386). The ID, owner and counts can't be changed.
+// @ID			updatePlaylist
+// @Accept		application/merge-patch+json
+// @Param		id		path		string		true	"Playlist ID"
+// @Param		patch	body		media.Playlist	true	"Merge patch"
+// @Success	200		{object}	media.Playlist
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	403		{object}	any
+// @Failure	404		{object}	any
+// @Failure	415		{object}	any
+// @Failure	500		{object}	any
+// @Router		/playlist/{id} [patch]
+func V1UpdatePlaylist(c echo.Context) error {
+	return patchPlayable(c, playlistResource)
+}
+
+// @Summary	Delete a playlist
+// @ID			deletePlaylist
+// @Param		id	path	string	true	"Playlist ID"
+// @Success	204
+// @Failure	401	{object}	any
+// @Failure	403	{object}	any
+// @Failure	404	{object}	any
+// @Failure	500	{object}	any
+// @Router		/playlist/{id} [delete]
+func V1DeletePlaylist(c echo.Context) error {
+	return deletePlayable(c, playlistResource)
+}
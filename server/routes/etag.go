@@ -0,0 +1,44 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerETag    = "ETag"
+	headerIfMatch = "If-Match"
+)
+
+// revisionETag returns the entity tag of a resource at the given revision.
+func revisionETag(revision int) string {
+	return `"` + strconv.Itoa(revision) + `"`
+}
+
+// matchesETag reports whether an If-Match header value allows changing a resource with the given entity tag.
+// An empty header matches every entity tag.
+func matchesETag(ifMatch, etag string) bool {
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" || ifMatch == "*" {
+		return true
+	}
+	for candidate := range strings.SplitSeq(ifMatch, ",") {
+		// If-Match uses the strong comparison, so weak entity tags never match.
+		if strings.TrimSpace(candidate) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// conflictStatus returns the status of a response to a write that lost a race with another write.
+// Clients that sent If-Match asked for a precondition, so it has failed. Otherwise the request conflicted.
+func conflictStatus(c echo.Context) int {
+	if c.Request().Header.Get(headerIfMatch) != "" {
+		return http.StatusPreconditionFailed
+	}
+	return http.StatusConflict
+}
@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestMatchesETag(t *testing.T) {
+	tests := []struct {
+		ifMatch string
+		want    bool
+	}{
+		{"", true},
+		{"*", true},
+		{`"3"`, true},
+		{` "3" `, true},
+		{`"2", "3"`, true},
+		{`"2"`, false},
+		{`3`, false},
+		{`W/"3"`, false},
+		{`"2", W/"3"`, false},
+	}
+	for _, test := range tests {
+		if got := matchesETag(test.ifMatch, revisionETag(3)); got != test.want {
+			t.Errorf("matchesETag(%q, %q) = %v, want %v", test.ifMatch, revisionETag(3), got, test.want)
+		}
+	}
+}
+
+func TestConflictStatus(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "/", http.NoBody)
+	if got := conflictStatus(echo.New().NewContext(req, httptest.NewRecorder())); got != http.StatusConflict {
+		t.Errorf("conflict without If-Match has status %d, want 409", got)
+	}
+	req.Header.Set(headerIfMatch, `"3"`)
+	if got := conflictStatus(echo.New().NewContext(req, httptest.NewRecorder())); got != http.StatusPreconditionFailed {
+		t.Errorf("conflict with If-Match has status %d, want 412", got)
+	}
+}
@@ -0,0 +1,184 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/goccy/go-json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+
+	"github.com/libramusic/libracore/events"
+	"github.com/libramusic/libracore/server/routes/auth"
+)
+
+// How often an idle event stream sends a comment, so proxies don't close it.
+const eventStreamHeartbeat = 30 * time.Second
+
+var eventTypes = []string{
+	events.PlayableAdded,
+	events.PlayableUpdated,
+	events.PlayableDeleted,
+	events.DownloadProgress,
+	events.QueueChanged,
+	events.NowPlayingChanged,
+	events.ScanProgress,
+}
+
+// @Summary	Stream events
+// @Description	Sends what happens on the server as it happens, limited to what the authenticated user can see.
+// @Description	Events are sent as Server-Sent Events, or as JSON text messages if the request is a WebSocket handshake.
+// @Description	The event types are playable.added, playable.updated, playable.deleted, download.progress,
+// @Description	queue.changed, now_playing.changed and scan.progress.
+// @Description	Clients that fall too far behind are disconnected, and events sent while a client is disconnected are lost.
+// @Description	Browsers can't send the Authorization header when opening streams, so they can send a ticket from
+// @Description	POST /events/ticket instead.
+// @ID			streamEvents
+// @Produce	text/event-stream
+// @Param		types			query		string	false	"Comma-separated event types or categories to receive, such as playable,queue.changed"
+// @Param		ticket	query		string	false	"Stream ticket, if no token is sent in the Authorization header"
+// @Success	200		{object}	events.Event
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Router		/events [get]
+func V1Events(c echo.Context) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	var types []string
+	if param := c.QueryParam("types"); param != "" {
+		for eventType := range strings.SplitSeq(param, ",") {
+			eventType = strings.TrimSpace(eventType)
+			if !slices.ContainsFunc(eventTypes, func(t string) bool {
+				return t == eventType || events.Event{Type: t}.Category() == eventType
+			}) {
+				return c.JSON(http.StatusBadRequest, echo.Map{"message": "unknown event type " + eventType})
+			}
+			types = append(types, eventType)
+		}
+	}
+
+	if c.IsWebSocket() {
+		streamEventsOverWebSocket(c, userID, types)
+		return nil
+	}
+	return streamServerSentEvents(c, userID, types)
+}
+
+type eventsTicketResponse struct {
+	Ticket string `json:"ticket"`
+	// Seconds until the ticket expires.
+	ExpiresIn int `json:"expires_in"`
+}
+
+// @Summary	Get a ticket to stream events
+// @Description	Returns a ticket that opens one event stream as the authenticated user when sent as the ticket query
+// @Description	parameter, for clients that can't send the Authorization header when opening streams.
+// @Description	It can only be used once, within 30 seconds.
+// @ID			createEventsTicket
+// @Produce	json
+// @Success	201	{object}	eventsTicketResponse
+// @Failure	401	{object}	any
+// @Router		/events/ticket [post]
+func V1EventsTicket(c echo.Context) error {
+	return c.JSON(http.StatusCreated, eventsTicketResponse{
+		Ticket:    auth.IssueStreamTicket(c.Get("user").(*jwt.Token)),
+		ExpiresIn: int(auth.StreamTicketExpiration.Seconds()),
+	})
+}
+
+// streamServerSentEvents sends the user's events as Server-Sent Events until the client disconnects.
+func streamServerSentEvents(c echo.Context, userID string, types []string) error {
+	subscription := events.Subscribe(userID, types)
+	defer subscription.Close()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	// Stops nginx from buffering the stream.
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprint(res, ": connected\n\n"); err != nil {
+		return nil
+	}
+	res.Flush()
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+		case event, ok := <-subscription.Events:
+			if !ok {
+				// The client fell behind or the server is shutting down. Closing the stream makes it reconnect.
+				return nil
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Error("Error encoding event", "err", err, "type", event.Type)
+				continue
+			}
+			if _, err := fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return nil
+			}
+		}
+		res.Flush()
+	}
+}
+
+// streamEventsOverWebSocket sends the user's events as WebSocket messages until the client disconnects.
+func streamEventsOverWebSocket(c echo.Context, userID string, types []string) {
+	server := websocket.Server{
+		// Browsers don't attach the token to WebSockets on their own, so other sites can't open streams
+		// in the user's name and there's no need to check the origin.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+
+			subscription := events.Subscribe(userID, types)
+			defer subscription.Close()
+
+			// Clients aren't expected to send anything, but reading notices when they disconnect.
+			disconnected := make(chan struct{})
+			go func() {
+				defer close(disconnected)
+				var message string
+				for websocket.Message.Receive(conn, &message) == nil {
+				}
+			}()
+
+			for {
+				select {
+				case <-disconnected:
+					return
+				case event, ok := <-subscription.Events:
+					if !ok {
+						return
+					}
+					data, err := json.Marshal(event)
+					if err != nil {
+						log.Error("Error encoding event", "err", err, "type", event.Type)
+						continue
+					}
+					if err := websocket.Message.Send(conn, string(data)); err != nil {
+						return
+					}
+				}
+			}
+		},
+	}
+	server.ServeHTTP(c.Response(), c.Request())
+}
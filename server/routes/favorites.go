@@ -0,0 +1,276 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/server/routes/auth"
+)
+
+const (
+	defaultFavoritesLimit = 50
+	maxFavoritesLimit     = 500
+)
+
+var favoritePlayableTypes = []string{"track", "album", "video", "artist", "playlist"}
+
+type favoriteResponse struct {
+	media.Favorite
+
+	// The favorited playable, or null if it no longer exists or the requester can't see it.
+	Playable media.Playable `json:"playable"`
+}
+
+// @Summary	Add a playable to the user's favorites
+// @Description	Favoriting a playable that is already a favorite does nothing.
+// @ID			addFavorite
+// @Param		type	path	string	true	"Playable type"	Enums(track, album, video, artist, playlist)
+// @Param		id		path	string	true	"Playable ID"
+// @Success	204
+// @Failure	400	{object}	any
+// @Failure	401	{object}	any
+// @Failure	404	{object}	any
+// @Failure	500	{object}	any
+// @Router		/{type}/{id}/favorite [put]
+func V1AddFavorite(c echo.Context) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	ctx := c.Request().Context()
+
+	playableType := c.Param("type")
+	playable, _, err := listenedPlayable(ctx, playableType, c.Param("id"), userID)
+	if errors.Is(err, errUnsupportedPlayableType) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, echo.Map{"message": "playable not found"})
+	}
+	if err != nil {
+		log.Error("Error getting playable", "err", err, "playableID", c.Param("id"))
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve playable"})
+	}
+
+	// The favorite and the count it adds to are stored together, so a failure can't leave the count off.
+	err = db.DB.Transaction(ctx, func(ctx context.Context) error {
+		added, err := db.DB.AddFavorite(ctx, media.Favorite{
+			UserID:       userID,
+			PlayableID:   playable.GetID(),
+			PlayableType: playableType,
+			FavoritedAt:  time.Now().Unix(),
+		})
+		if err != nil || !added {
+			return err
+		}
+		return db.DB.IncrementFavoriteCount(ctx, playableType, playable.GetID(), 1)
+	})
+	if err != nil {
+		log.Error("Error adding favorite", "err", err, "userID", userID, "playableID", playable.GetID())
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to add favorite"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary	Remove a playable from the user's favorites
+// @ID			removeFavorite
+// @Param		type	path	string	true	"Playable type"	Enums(track, album, video, artist, playlist)
+// @Param		id		path	string	true	"Playable ID"
+// @Success	204
+// @Failure	400	{object}	any
+// @Failure	401	{object}	any
+// @Failure	500	{object}	any
+// @Router		/{type}/{id}/favorite [delete]
+func V1RemoveFavorite(c echo.Context) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	playableType := c.Param("type")
+	if !slices.Contains(favoritePlayableTypes, playableType) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": errUnsupportedPlayableType.Error()})
+	}
+
+	ctx := c.Request().Context()
+
+	// Favorites of playables that were since deleted can still be removed.
+	id := c.Param("id")
+	err := db.DB.Transaction(ctx, func(ctx context.Context) error {
+		removed, err := db.DB.RemoveFavorite(ctx, userID, playableType, id)
+		if err != nil || !removed {
+			return err
+		}
+		return db.DB.IncrementFavoriteCount(ctx, playableType, id, -1)
+	})
+	if err != nil {
+		log.Error("Error removing favorite", "err", err, "userID", userID, "playableID", id)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to remove favorite"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary	Get a user's favorites
+// @ID			getUserFavorites
+// @Param		id		path		string	true	"User ID"
+// @Param		type	query		string	false	"Only return favorites of this playable type"	Enums(track, album, video, artist, playlist)
+// @Param		limit	query		int		false	"Maximum number of favorites to return"			default(50)
+// @Param		offset	query		int		false	"Number of favorites to skip"					default(0)
+// @Success	200		{array}		favoriteResponse
+// @Success	200		"Returns the user's favorites, most recently favorited first"
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	403		{object}	any
+// @Failure	500		{object}	any
+// @Router		/users/{id}/favorites [get]
+func V1UserFavorites(c echo.Context) error {
+	userID := c.Param("id")
+
+	playableType := c.QueryParam("type")
+	if playableType != "" && !slices.Contains(favoritePlayableTypes, playableType) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": errUnsupportedPlayableType.Error()})
+	}
+	limit, offset, err := paginationParams(c, defaultFavoritesLimit, maxFavoritesLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
+	}
+
+	ctx := c.Request().Context()
+
+	favorites, err := db.DB.Favorites(ctx, userID, playableType, limit, offset)
+	if err != nil {
+		log.Error("Error getting favorites", "err", err, "userID", userID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve favorites"})
+	}
+	total, err := db.DB.CountFavorites(ctx, userID, playableType)
+	if err != nil {
+		log.Error("Error counting favorites", "err", err, "userID", userID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve favorites"})
+	}
+
+	// Playlists that were made private since they were favorited are hidden like deleted ones.
+	viewerID, _ := auth.UserIDFromContext(c)
+	playables := make([]media.Playable, len(favorites))
+	for i, favorite := range favorites {
+		playable, _, err := listenedPlayable(ctx, favorite.PlayableType, favorite.PlayableID, viewerID)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			log.Error("Error getting playable", "err", err, "playableID", favorite.PlayableID)
+			return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve favorites"})
+		}
+		if err == nil {
+			playables[i] = playable
+		}
+	}
+	if err := markFavorites(c, playables); err != nil {
+		log.Error("Error getting favorites", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve favorites"})
+	}
+
+	response := make([]favoriteResponse, len(favorites))
+	for i, favorite := range favorites {
+		response[i] = favoriteResponse{Favorite: favorite, Playable: playables[i]}
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"favorites": response,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// markFavorite returns the playable with its favorited flag set for the authenticated user.
+// The playable is returned unchanged if the request isn't authenticated.
+func markFavorite(c echo.Context, playable media.Playable) (media.Playable, error) {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return playable, nil
+	}
+	favorited, err := db.DB.IsFavorite(c.Request().Context(), userID, playable.GetType(), playable.GetID())
+	if err != nil {
+		return playable, err
+	}
+	return withFavorited(playable, favorited), nil
+}
+
+// markFavorites sets the favorited flag of every playable for the authenticated user.
+// Nil playables are skipped, and nothing is changed if the request isn't authenticated.
+func markFavorites(c echo.Context, playables []media.Playable) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return nil
+	}
+
+	favorites, err := favoriteSets(c.Request().Context(), userID, playables)
+	if err != nil {
+		return err
+	}
+	for i, playable := range playables {
+		if playable == nil {
+			continue
+		}
+		_, favorited := favorites[playable.GetType()][playable.GetID()]
+		playables[i] = withFavorited(playable, favorited)
+	}
+	return nil
+}
+
+// favoriteSets returns the IDs the user has favorited for each type of the given playables.
+func favoriteSets(
+	ctx context.Context,
+	userID string,
+	playables []media.Playable,
+) (map[string]map[string]struct{}, error) {
+	favorites := map[string]map[string]struct{}{}
+	for _, playable := range playables {
+		if playable == nil {
+			continue
+		}
+		playableType := playable.GetType()
+		if _, ok := favorites[playableType]; ok || !slices.Contains(favoritePlayableTypes, playableType) {
+			continue
+		}
+
+		ids, err := db.DB.FavoriteIDs(ctx, userID, playableType)
+		if err != nil {
+			return nil, err
+		}
+		favorites[playableType] = make(map[string]struct{}, len(ids))
+		for _, id := range ids {
+			favorites[playableType][id] = struct{}{}
+		}
+	}
+	return favorites, nil
+}
+
+// withFavorited returns a copy of the playable with its favorited flag set.
+// Playables that can't be favorited are returned unchanged.
+func withFavorited(playable media.Playable, favorited bool) media.Playable {
+	switch p := playable.(type) {
+	case media.Track:
+		p.Favorited = &favorited
+		return p
+	case media.Album:
+		p.Favorited = &favorited
+		return p
+	case media.Video:
+		p.Favorited = &favorited
+		return p
+	case media.Artist:
+		p.Favorited = &favorited
+		return p
+	case media.Playlist:
+		p.Favorited = &favorited
+		return p
+	}
+	return playable
+}
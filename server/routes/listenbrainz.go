@@ -0,0 +1,523 @@
+package routes
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/scrobble"
+	"github.com/libramusic/libracore/server/routes/auth"
+)
+
+// This file implements the subset of the ListenBrainz API used by scrobbling clients.
+// See https://listenbrainz.readthedocs.io/en/latest/users/api/core.html.
+
+const (
+	maxListenBrainzListensPerRequest = 1000
+	defaultListenBrainzListenCount   = 25
+	maxListenBrainzListenCount       = 1000
+)
+
+type listenBrainzSubmission struct {
+	ListenType string               `json:"listen_type"`
+	Payload    []listenBrainzListen `json:"payload"`
+}
+
+type listenBrainzListen struct {
+	ListenedAt    int64                     `json:"listened_at,omitempty"`
+	RecordingMSID string                    `json:"recording_msid,omitempty"`
+	UserName      string                    `json:"user_name,omitempty"`
+	TrackMetadata listenBrainzTrackMetadata `json:"track_metadata"`
+}
+
+type listenBrainzTrackMetadata struct {
+	ArtistName     string                     `json:"artist_name"`
+	TrackName      string                     `json:"track_name"`
+	ReleaseName    string                     `json:"release_name,omitempty"`
+	AdditionalInfo listenBrainzAdditionalInfo `json:"additional_info"`
+}
+
+type listenBrainzAdditionalInfo struct {
+	RecordingMBID    string `json:"recording_mbid,omitempty"`
+	ISRC             string `json:"isrc,omitempty"`
+	DurationMS       int    `json:"duration_ms,omitempty"`
+	Duration         int    `json:"duration,omitempty"`
+	SubmissionClient string `json:"submission_client,omitempty"`
+	MediaPlayer      string `json:"media_player,omitempty"`
+	MusicServiceName string `json:"music_service_name,omitempty"`
+}
+
+// @Summary	Get whether the user has a ListenBrainz token
+// @Description	Tokens are only shown when they are created, so clients that need one should reset it.
+// @ID			getListenBrainzToken
+// @Success	200	{object}	any
+// @Failure	401	{object}	any
+// @Failure	500	{object}	any
+// @Router		/listenbrainz/token [get]
+func V1ListenBrainzToken(c echo.Context) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	_, err := db.DB.ListenBrainzToken(c.Request().Context(), userID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		log.Error("Error getting ListenBrainz token", "err", err, "userID", userID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve token"})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"exists": err == nil})
+}
+
+// @Summary	Reset the user's ListenBrainz token
+// @Description	Returns a new token, replacing the previous one. Only its hash is stored, so it can't be shown again.
+// @ID			resetListenBrainzToken
+// @Success	200	{object}	any
+// @Failure	401	{object}	any
+// @Failure	500	{object}	any
+// @Router		/listenbrainz/token [post]
+func V1ResetListenBrainzToken(c echo.Context) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	token, err := resetListenBrainzToken(c.Request().Context(), userID)
+	if err != nil {
+		log.Error("Error resetting ListenBrainz token", "err", err, "userID", userID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to reset token"})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"token": token})
+}
+
+func ListenBrainzValidateToken(c echo.Context) error {
+	user, err := listenBrainzUser(c)
+	if errors.Is(err, db.ErrNotFound) {
+		return c.JSON(http.StatusOK, echo.Map{"code": http.StatusOK, "message": "Token invalid.", "valid": false})
+	}
+	if err != nil {
+		log.Error("Error validating ListenBrainz token", "err", err)
+		return listenBrainzError(c, http.StatusInternalServerError, "Failed to validate token.")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"code":      http.StatusOK,
+		"message":   "Token valid.",
+		"valid":     true,
+		"user_name": user.Username,
+	})
+}
+
+func ListenBrainzSubmitListens(c echo.Context) error {
+	user, err := listenBrainzUser(c)
+	if errors.Is(err, db.ErrNotFound) {
+		return listenBrainzError(c, http.StatusUnauthorized, "Invalid authorization token.")
+	}
+	if err != nil {
+		log.Error("Error validating ListenBrainz token", "err", err)
+		return listenBrainzError(c, http.StatusInternalServerError, "Failed to validate token.")
+	}
+
+	var submission listenBrainzSubmission
+	if err := c.Bind(&submission); err != nil {
+		return listenBrainzError(c, http.StatusBadRequest, "Cannot parse JSON document.")
+	}
+	if message := validateListenBrainzSubmission(submission); message != "" {
+		return listenBrainzError(c, http.StatusBadRequest, message)
+	}
+
+	ctx := c.Request().Context()
+
+	matcher := newTrackMatcher()
+	for _, submitted := range submission.Payload {
+		listen, track, matched, err := matcher.listen(ctx, user.ID, submitted)
+		if err != nil {
+			log.Error("Error matching listen", "err", err, "userID", user.ID)
+			return listenBrainzError(c, http.StatusInternalServerError, "Failed to submit listens.")
+		}
+
+		if submission.ListenType == "playing_now" {
+			listen.ListenedAt = time.Now().Unix()
+			setNowPlaying(listen, listenBrainzDuration(submitted, track))
+			continue
+		}
+
+		duplicate, err := isDuplicateListen(ctx, listen)
+		if err != nil {
+			log.Error("Error checking for duplicate listen", "err", err, "userID", user.ID)
+			return listenBrainzError(c, http.StatusInternalServerError, "Failed to submit listens.")
+		}
+		if duplicate {
+			continue
+		}
+
+		listen.ID = media.GenerateID(config.Conf.General.IDLength)
+		if matched {
+			err = RecordListen(ctx, listen, track)
+		} else {
+			err = db.DB.AddListen(ctx, listen)
+		}
+		if err != nil {
+			log.Error("Error recording listen", "err", err, "userID", user.ID)
+			return listenBrainzError(c, http.StatusInternalServerError, "Failed to submit listens.")
+		}
+		// Imported listens are usually already known to the user's other scrobblers.
+		if submission.ListenType == "single" {
+			clearNowPlaying(user.ID, listen.PlayableID)
+			forwardListen(ctx, listen)
+		}
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok"})
+}
+
+func ListenBrainzUserListens(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, err := db.DB.UserByUsername(ctx, c.Param("name"))
+	if errors.Is(err, db.ErrNotFound) {
+		return listenBrainzError(c, http.StatusNotFound, "Cannot find user: "+c.Param("name"))
+	}
+	if err != nil {
+		log.Error("Error getting user", "err", err, "username", c.Param("name"))
+		return listenBrainzError(c, http.StatusInternalServerError, "Failed to retrieve listens.")
+	}
+
+	if config.Conf.Auth.UserAPIRoutesRequireAuth {
+		requester, err := listenBrainzUser(c)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			log.Error("Error validating ListenBrainz token", "err", err)
+			return listenBrainzError(c, http.StatusInternalServerError, "Failed to validate token.")
+		}
+		if err != nil {
+			return listenBrainzError(c, http.StatusUnauthorized, "Invalid authorization token.")
+		}
+		if config.Conf.Auth.UserAPIRequireSameUseUser {
+			allowed, err := auth.CanActFor(ctx, requester.ID, user.ID, media.PermissionManageUsers)
+			if err != nil {
+				log.Error("Error checking permission", "err", err, "userID", requester.ID)
+				return listenBrainzError(c, http.StatusInternalServerError, "Failed to retrieve listens.")
+			}
+			if !allowed {
+				return listenBrainzError(c, http.StatusForbidden, "Not allowed to view this user's listens.")
+			}
+		}
+	}
+
+	count, minTS, maxTS, message := listenBrainzRangeParams(c)
+	if message != "" {
+		return listenBrainzError(c, http.StatusBadRequest, message)
+	}
+
+	listens, err := db.DB.ListensBetween(ctx, user.ID, minTS, maxTS, count)
+	if err != nil {
+		log.Error("Error getting listens", "err", err, "userID", user.ID)
+		return listenBrainzError(c, http.StatusInternalServerError, "Failed to retrieve listens.")
+	}
+	latest, err := db.DB.ListensBetween(ctx, user.ID, 0, 0, 1)
+	if err != nil {
+		log.Error("Error getting listens", "err", err, "userID", user.ID)
+		return listenBrainzError(c, http.StatusInternalServerError, "Failed to retrieve listens.")
+	}
+	var latestListenTS int64
+	if len(latest) > 0 {
+		latestListenTS = latest[0].ListenedAt
+	}
+
+	described := make([]listenBrainzListen, 0, len(listens))
+	tracks := map[string]scrobble.Track{}
+	for _, listen := range listens {
+		metadata, err := listenBrainzMetadata(ctx, listen, tracks)
+		if err != nil {
+			log.Error("Error getting listen metadata", "err", err, "listenID", listen.ID)
+			return listenBrainzError(c, http.StatusInternalServerError, "Failed to retrieve listens.")
+		}
+		described = append(described, listenBrainzListen{
+			ListenedAt:    listen.ListenedAt,
+			RecordingMSID: listen.ID,
+			UserName:      user.Username,
+			TrackMetadata: metadata,
+		})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"payload": echo.Map{
+			"count":            len(described),
+			"latest_listen_ts": latestListenTS,
+			"listens":          described,
+			"user_id":          user.Username,
+		},
+	})
+}
+
+func resetListenBrainzToken(ctx context.Context, userID string) (string, error) {
+	token := rand.Text()
+	return token, db.DB.SetListenBrainzToken(ctx, userID, auth.HashToken(token), time.Now().Unix())
+}
+
+// listenBrainzUser returns the user whose token is in the Authorization header.
+// It returns db.ErrNotFound if the header is missing or the token is unknown.
+func listenBrainzUser(c echo.Context) (media.DatabaseUser, error) {
+	header := c.Request().Header.Get(echo.HeaderAuthorization)
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Token") || strings.TrimSpace(token) == "" {
+		return media.DatabaseUser{}, db.ErrNotFound
+	}
+
+	ctx := c.Request().Context()
+
+	userID, err := db.DB.ListenBrainzTokenUserID(ctx, auth.HashToken(strings.TrimSpace(token)))
+	if err != nil {
+		return media.DatabaseUser{}, err
+	}
+	return db.DB.User(ctx, userID)
+}
+
+func listenBrainzError(c echo.Context, status int, message string) error {
+	return c.JSON(status, echo.Map{"code": status, "error": message})
+}
+
+// validateListenBrainzSubmission returns the reason the submission is invalid, or an empty string if it is valid.
+func validateListenBrainzSubmission(submission listenBrainzSubmission) string {
+	switch submission.ListenType {
+	case "single", "playing_now":
+		if len(submission.Payload) != 1 {
+			return "JSON document should contain exactly one listen for this listen type."
+		}
+	case "import":
+		if len(submission.Payload) == 0 {
+			return "JSON document should contain at least one listen."
+		}
+		if len(submission.Payload) > maxListenBrainzListensPerRequest {
+			return "JSON document contains too many listens."
+		}
+	default:
+		return "JSON document must contain a valid listen_type key."
+	}
+
+	for _, listen := range submission.Payload {
+		if listen.TrackMetadata.TrackName == "" || listen.TrackMetadata.ArtistName == "" {
+			return "JSON document must contain track_name and artist_name in track_metadata."
+		}
+		if submission.ListenType != "playing_now" && listen.ListenedAt <= 0 {
+			return "JSON document must contain a valid listened_at timestamp."
+		}
+	}
+	return ""
+}
+
+// listenBrainzRangeParams parses the count, min_ts and max_ts query parameters.
+// The returned message is empty unless a parameter is invalid.
+func listenBrainzRangeParams(c echo.Context) (int, int64, int64, string) {
+	count := defaultListenBrainzListenCount
+	if value := c.QueryParam("count"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return 0, 0, 0, "count must be a positive integer."
+		}
+		count = min(parsed, maxListenBrainzListenCount)
+	}
+
+	var timestamps [2]int64
+	for i, name := range []string{"min_ts", "max_ts"} {
+		value := c.QueryParam(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 1 {
+			return 0, 0, 0, name + " must be a positive integer."
+		}
+		timestamps[i] = parsed
+	}
+	if timestamps[0] != 0 && timestamps[1] != 0 {
+		return 0, 0, 0, "You may only specify max_ts or min_ts, not both."
+	}
+
+	return count, timestamps[0], timestamps[1], ""
+}
+
+// isDuplicateListen reports whether the user already has a listen of the same track at the same time,
+// which happens when clients resubmit listens they weren't sure were received.
+func isDuplicateListen(ctx context.Context, listen media.Listen) (bool, error) {
+	existing, err := db.DB.ListensBetween(ctx, listen.UserID, listen.ListenedAt-1, listen.ListenedAt+1, 100)
+	if err != nil {
+		return false, err
+	}
+	for _, other := range existing {
+		if other.PlayableID == listen.PlayableID &&
+			other.TrackName == listen.TrackName &&
+			other.ArtistName == listen.ArtistName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// listenBrainzDuration returns the duration of a submitted listen in seconds, or 0 if it is unknown.
+func listenBrainzDuration(submitted listenBrainzListen, track media.Track) int {
+	info := submitted.TrackMetadata.AdditionalInfo
+	switch {
+	case info.DurationMS > 0:
+		return info.DurationMS / 1000
+	case info.Duration > 0:
+		return info.Duration
+	}
+	return track.Duration
+}
+
+// listenBrainzMetadata describes a listen the way ListenBrainz does.
+// Descriptions of listens of library tracks are cached in tracks by playable ID.
+func listenBrainzMetadata(
+	ctx context.Context,
+	listen media.Listen,
+	tracks map[string]scrobble.Track,
+) (listenBrainzTrackMetadata, error) {
+	track, ok := tracks[listen.PlayableID]
+	if !ok || listen.TrackName != "" {
+		var err error
+		track, err = scrobble.Describe(ctx, listen)
+		if err != nil {
+			return listenBrainzTrackMetadata{}, err
+		}
+		if listen.IsMatched() && listen.TrackName == "" {
+			tracks[listen.PlayableID] = track
+		}
+	}
+
+	return listenBrainzTrackMetadata{
+		ArtistName:  track.ArtistName,
+		TrackName:   track.TrackName,
+		ReleaseName: track.ReleaseName,
+		AdditionalInfo: listenBrainzAdditionalInfo{
+			RecordingMBID:    track.RecordingMBID,
+			ISRC:             track.ISRC,
+			DurationMS:       track.Duration * 1000,
+			SubmissionClient: listen.Client,
+			MusicServiceName: listen.Source,
+		},
+	}, nil
+}
+
+// trackMatcher maps submitted track metadata onto tracks in the library.
+// Tracks are matched by MusicBrainz recording ID first, then by ISRC, then by title and artist name.
+// Artist names are cached, since the listens in a submission usually share artists.
+type trackMatcher struct {
+	artistNames map[string]string
+}
+
+func newTrackMatcher() trackMatcher {
+	return trackMatcher{artistNames: map[string]string{}}
+}
+
+func trackMatcherKey(title, artistName string) string {
+	return strings.ToLower(strings.TrimSpace(title)) + "\x00" + strings.ToLower(strings.TrimSpace(artistName))
+}
+
+func (m trackMatcher) match(ctx context.Context, metadata listenBrainzTrackMetadata) (media.Track, bool, error) {
+	info := metadata.AdditionalInfo
+	if info.RecordingMBID != "" {
+		track, err := db.DB.TrackByRecordingMBID(ctx, info.RecordingMBID)
+		if !errors.Is(err, db.ErrNotFound) {
+			return track, err == nil, err
+		}
+	}
+	if info.ISRC != "" {
+		track, err := db.DB.TrackByISRC(ctx, info.ISRC)
+		if !errors.Is(err, db.ErrNotFound) {
+			return track, err == nil, err
+		}
+	}
+
+	tracks, err := db.DB.TracksByTitle(ctx, strings.TrimSpace(metadata.TrackName))
+	if err != nil {
+		return media.Track{}, false, err
+	}
+	key := trackMatcherKey(metadata.TrackName, metadata.ArtistName)
+	for _, track := range tracks {
+		names, err := m.trackArtistNames(ctx, track)
+		if err != nil {
+			return media.Track{}, false, err
+		}
+		keys := make([]string, 0, len(names)+2)
+		for _, name := range names {
+			keys = append(keys, trackMatcherKey(track.Title, name))
+		}
+		if len(names) > 1 {
+			keys = append(keys,
+				trackMatcherKey(track.Title, strings.Join(names, ", ")),
+				trackMatcherKey(track.Title, strings.Join(names, " & ")),
+			)
+		}
+		if slices.Contains(keys, key) {
+			return track, true, nil
+		}
+	}
+	return media.Track{}, false, nil
+}
+
+// trackArtistNames returns the names of the track's artists that are in the library.
+func (m trackMatcher) trackArtistNames(ctx context.Context, track media.Track) ([]string, error) {
+	var names []string
+	for _, artistID := range track.ArtistIDs {
+		name, ok := m.artistNames[artistID]
+		if !ok {
+			artist, err := db.DB.Artist(ctx, artistID)
+			if err != nil && !errors.Is(err, db.ErrNotFound) {
+				return nil, err
+			}
+			name = artist.Name
+			m.artistNames[artistID] = name
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// listen converts a submitted listen, linking it to a track in the library if one matches.
+func (m trackMatcher) listen(
+	ctx context.Context,
+	userID string,
+	submitted listenBrainzListen,
+) (media.Listen, media.Track, bool, error) {
+	metadata := submitted.TrackMetadata
+	info := metadata.AdditionalInfo
+
+	client := info.SubmissionClient
+	if client == "" {
+		client = info.MediaPlayer
+	}
+
+	listen := media.Listen{
+		UserID:        userID,
+		PlayableType:  "track",
+		ListenedAt:    submitted.ListenedAt,
+		Client:        client,
+		Source:        info.MusicServiceName,
+		TrackName:     metadata.TrackName,
+		ArtistName:    metadata.ArtistName,
+		ReleaseName:   metadata.ReleaseName,
+		RecordingMBID: info.RecordingMBID,
+		ISRC:          info.ISRC,
+	}
+
+	track, matched, err := m.match(ctx, metadata)
+	if matched {
+		listen.PlayableID = track.ID
+	}
+	return listen, track, matched, err
+}
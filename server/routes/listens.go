@@ -0,0 +1,409 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/events"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/scrobble"
+	"github.com/libramusic/libracore/server/routes/auth"
+)
+
+const (
+	// Tracks and videos this short (in seconds) are never recorded.
+	minListenableDuration = 30
+	// A listen is recorded after half of the playable or this many seconds, whichever comes first.
+	maxListenThreshold = 240
+	// How long a "now playing" notification is kept if the playable's duration is unknown.
+	defaultNowPlayingExpiration = 10 * time.Minute
+	// How far in the future listened_at may be, for clients whose clocks are a little ahead.
+	maxListenClockSkew = time.Minute
+	// The earliest listened_at that is accepted, October 2002, the same as ListenBrainz's.
+	minListenedAt = 1033430400
+
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+type listenRequest struct {
+	PlayableID   string `json:"playable_id"   example:"7nTwkcl51u4"`
+	PlayableType string `json:"playable_type" example:"track"`
+	// Unix timestamp of when playback started. Defaults to now.
+	ListenedAt     *int64 `json:"listened_at"     example:"1634296980"`
+	DurationPlayed int    `json:"duration_played" example:"180"`
+	Client         string `json:"client"          example:"Libra Web"`
+	Source         string `json:"source"          example:"playlist"`
+	// Marks the playable as currently playing instead of submitting a listen.
+	NowPlaying bool `json:"now_playing" example:"false"`
+}
+
+type nowPlayingEntry struct {
+	listen     media.Listen
+	expiration time.Time
+}
+
+var (
+	nowPlaying   = map[string]nowPlayingEntry{}
+	nowPlayingMu sync.Mutex
+)
+
+// @Summary	Submit a listen or a "now playing" notification
+// @ID			submitListen
+// @Accept		json
+// @Param		listen	body		listenRequest	true	"Listen"
+// @Success	200		{object}	media.Listen	"The playable is now playing"
+// @Success	201		{object}	media.Listen	"The listen was recorded"
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	404		{object}	any
+// @Failure	500		{object}	any
+// @Router		/listens [post]
+func V1SubmitListen(c echo.Context) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	var req listenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
+	}
+	if req.PlayableID == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": "playable_id is required"})
+	}
+	if req.PlayableType == "" {
+		req.PlayableType = "track"
+	}
+	listenedAt := time.Now().Unix()
+	if req.ListenedAt != nil {
+		listenedAt = *req.ListenedAt
+	}
+	if listenedAt > time.Now().Add(maxListenClockSkew).Unix() {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": "listened_at can't be in the future"})
+	}
+	if listenedAt < minListenedAt {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": "listened_at is too far in the past"})
+	}
+	if req.DurationPlayed < 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": "duration_played can't be negative"})
+	}
+
+	ctx := c.Request().Context()
+
+	playable, duration, err := listenedPlayable(ctx, req.PlayableType, req.PlayableID, userID)
+	if errors.Is(err, errUnsupportedPlayableType) {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, echo.Map{"message": "playable not found"})
+	}
+	if err != nil {
+		log.Error("Error getting playable", "err", err, "playableID", req.PlayableID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve playable"})
+	}
+
+	listen := media.Listen{
+		UserID:         userID,
+		PlayableID:     playable.GetID(),
+		PlayableType:   playable.GetType(),
+		ListenedAt:     listenedAt,
+		DurationPlayed: req.DurationPlayed,
+		Client:         req.Client,
+		Source:         req.Source,
+	}
+
+	if req.NowPlaying {
+		setNowPlaying(listen, duration)
+		return c.JSON(http.StatusOK, listen)
+	}
+
+	threshold, recordable, err := listenThreshold(ctx, playable, duration)
+	if err != nil {
+		log.Error("Error getting listen threshold", "err", err, "playableID", listen.PlayableID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to record listen"})
+	}
+	if !recordable || req.DurationPlayed < threshold {
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"message": "listen does not meet the submission threshold",
+		})
+	}
+
+	listen.ID = media.GenerateID(config.Conf.General.IDLength)
+	if err := RecordListen(ctx, listen, playable); err != nil {
+		log.Error("Error recording listen", "err", err, "playableID", listen.PlayableID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to record listen"})
+	}
+	clearNowPlaying(userID, listen.PlayableID)
+	forwardListen(ctx, listen)
+
+	return c.JSON(http.StatusCreated, listen)
+}
+
+// @Summary	Get a user's listening history
+// @ID			getUserHistory
+// @Param		id		path		string	true	"User ID"
+// @Param		limit	query		int		false	"Maximum number of listens to return"	default(50)
+// @Param		offset	query		int		false	"Number of listens to skip"				default(0)
+// @Success	200		{array}		media.Listen
+// @Success	200		"Returns the user's listens, most recent first"
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	403		{object}	any
+// @Failure	500		{object}	any
+// @Router		/users/{id}/history [get]
+func V1UserHistory(c echo.Context) error {
+	userID := c.Param("id")
+
+	limit, offset, err := paginationParams(c, defaultHistoryLimit, maxHistoryLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
+	}
+
+	ctx := c.Request().Context()
+
+	listens, err := db.DB.Listens(ctx, userID, limit, offset)
+	if err != nil {
+		log.Error("Error getting listens", "err", err, "userID", userID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve history"})
+	}
+	total, err := db.DB.CountListens(ctx, userID)
+	if err != nil {
+		log.Error("Error counting listens", "err", err, "userID", userID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve history"})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"listens": listens,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// @Summary	Get what a user is currently playing
+// @ID			getUserNowPlaying
+// @Param		id	path		string	true	"User ID"
+// @Success	200	{object}	media.Listen
+// @Success	204	"The user is not playing anything"
+// @Failure	401	{object}	any
+// @Failure	403	{object}	any
+// @Router		/users/{id}/now_playing [get]
+func V1UserNowPlaying(c echo.Context) error {
+	userID := c.Param("id")
+
+	nowPlayingMu.Lock()
+	entry, ok := nowPlaying[userID]
+	if ok && time.Now().After(entry.expiration) {
+		delete(nowPlaying, userID)
+		ok = false
+	}
+	nowPlayingMu.Unlock()
+
+	if !ok {
+		return c.NoContent(http.StatusNoContent)
+	}
+	return c.JSON(http.StatusOK, entry.listen)
+}
+
+// RecordListen stores a listen and updates the listen counts of the playable and its parents.
+// Album listen counts include the listens of their tracks and of the album as a whole, and artist listen counts
+// are either the sum of their content or the number of times the artist was played
+// as a whole, depending on config.Conf.General.ArtistListenCountsByTrack.
+func RecordListen(ctx context.Context, listen media.Listen, playable media.Playable) error {
+	type count struct {
+		playableType string
+		id           string
+	}
+	var counts []count
+
+	byTrack := config.Conf.General.ArtistListenCountsByTrack
+	switch p := playable.(type) {
+	case media.Track:
+		counts = append(counts, count{"track", p.ID})
+		for _, albumID := range p.AlbumIDs {
+			counts = append(counts, count{"album", albumID})
+		}
+		if byTrack {
+			for _, artistID := range p.ArtistIDs {
+				counts = append(counts, count{"artist", artistID})
+			}
+		}
+	case media.Album:
+		counts = append(counts, count{"album", p.ID})
+	case media.Video:
+		counts = append(counts, count{"video", p.ID})
+		if byTrack {
+			for _, artistID := range p.ArtistIDs {
+				counts = append(counts, count{"artist", artistID})
+			}
+		}
+	case media.Artist:
+		if !byTrack {
+			counts = append(counts, count{"artist", p.ID})
+		}
+	case media.Playlist:
+		counts = append(counts, count{"playlist", p.ID})
+	}
+
+	// The listen and the counts it adds to are stored together, so a failure can't leave the counts off.
+	return db.DB.Transaction(ctx, func(ctx context.Context) error {
+		if err := db.DB.AddListen(ctx, listen); err != nil {
+			return err
+		}
+		for _, c := range counts {
+			if err := db.DB.IncrementListenCount(ctx, c.playableType, c.id, 1); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// forwardListen queues a listen for the user's external scrobblers.
+// Failing to queue it doesn't fail the request since the listen itself was recorded.
+func forwardListen(ctx context.Context, listen media.Listen) {
+	if err := scrobble.Enqueue(ctx, listen); err != nil {
+		log.Error("Error queueing listen for scrobblers", "err", err, "listenID", listen.ID)
+	}
+}
+
+var errUnsupportedPlayableType = errors.New("unsupported playable type")
+
+// listenedPlayable returns the playable being listened to and its duration in seconds.
+// The duration is 0 for playables that are played as a collection.
+// Playlists the viewer can't see are reported as not found.
+func listenedPlayable(ctx context.Context, playableType, id, viewerID string) (media.Playable, int, error) {
+	switch playableType {
+	case "track":
+		track, err := db.DB.Track(ctx, id)
+		return track, track.Duration, err
+	case "video":
+		video, err := db.DB.Video(ctx, id)
+		return video, video.Duration, err
+	case "album":
+		album, err := db.DB.Album(ctx, id)
+		return album, 0, err
+	case "artist":
+		artist, err := db.DB.Artist(ctx, id)
+		return artist, 0, err
+	case "playlist":
+		playlist, err := db.DB.Playlist(ctx, id)
+		if err == nil && !playlist.CanView(viewerID) {
+			return playlist, 0, db.ErrNotFound
+		}
+		return playlist, 0, err
+	}
+	return nil, 0, errUnsupportedPlayableType
+}
+
+// listenThreshold returns how many seconds of the playable have to be played for a listen to be recorded,
+// and false if it's too short to be recorded at all. Tracks and videos whose duration is unknown have no threshold.
+// Collections have to be played for the thresholds of their tracks added up, as if each was listened to on its own.
+func listenThreshold(ctx context.Context, playable media.Playable, duration int) (int, bool, error) {
+	var trackIDs []string
+	switch p := playable.(type) {
+	case media.Album:
+		trackIDs = p.TrackIDs
+	case media.Artist:
+		trackIDs = p.TrackIDs
+	case media.Playlist:
+		trackIDs = p.TrackIDs
+	default:
+		if duration == 0 {
+			return 0, true, nil
+		}
+		threshold, ok := trackListenThreshold(duration)
+		return threshold, ok, nil
+	}
+
+	total := 0
+	for _, id := range trackIDs {
+		track, err := db.DB.Track(ctx, id)
+		if errors.Is(err, db.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return 0, false, err
+		}
+		// Tracks too short to be recorded on their own don't have to be played.
+		if threshold, ok := trackListenThreshold(track.Duration); ok {
+			total += threshold
+		}
+	}
+	return total, true, nil
+}
+
+// trackListenThreshold returns how many seconds of a track or video that lasts duration seconds have to be played
+// for a listen to be recorded, and false if it's too short to be recorded.
+func trackListenThreshold(duration int) (int, bool) {
+	if duration <= minListenableDuration {
+		return 0, false
+	}
+	return min(duration/2, maxListenThreshold), true
+}
+
+func setNowPlaying(listen media.Listen, duration int) {
+	expiration := time.Now().Add(defaultNowPlayingExpiration)
+	if duration > 0 {
+		expiration = time.Unix(listen.ListenedAt, 0).Add(time.Duration(duration) * time.Second)
+	}
+
+	nowPlayingMu.Lock()
+	defer nowPlayingMu.Unlock()
+	nowPlaying[listen.UserID] = nowPlayingEntry{listen: listen, expiration: expiration}
+	publishNowPlaying(listen.UserID, events.NowPlaying{
+		UserID:    listen.UserID,
+		Listen:    &listen,
+		ExpiresAt: expiration.Unix(),
+	})
+}
+
+func clearNowPlaying(userID, playableID string) {
+	nowPlayingMu.Lock()
+	defer nowPlayingMu.Unlock()
+	if entry, ok := nowPlaying[userID]; ok && entry.listen.PlayableID == playableID {
+		delete(nowPlaying, userID)
+		publishNowPlaying(userID, events.NowPlaying{UserID: userID})
+	}
+}
+
+// publishNowPlaying publishes a change of what a user is playing to the users who can view their activity.
+func publishNowPlaying(userID string, data events.NowPlaying) {
+	if !config.Conf.Auth.UserAPIRequireSameUseUser {
+		events.Publish(events.ForEveryone(events.NowPlayingChanged, data))
+		return
+	}
+	events.Publish(events.ForUser(userID, events.NowPlayingChanged, data))
+}
+
+// paginationParams parses the limit and offset query parameters.
+func paginationParams(c echo.Context, defaultLimit, maxLimit int) (int, int, error) {
+	limit := defaultLimit
+	if value := c.QueryParam("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return 0, 0, errors.New("limit must be a positive integer")
+		}
+		limit = min(parsed, maxLimit)
+	}
+
+	offset := 0
+	if value := c.QueryParam("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+		offset = parsed
+	}
+
+	return limit, offset, nil
+}
@@ -0,0 +1,210 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/server/routes"
+	"github.com/libramusic/libracore/server/routes/auth"
+)
+
+// setup replaces db.DB with an empty SQLite database that has the users.
+func setup(t *testing.T, userIDs ...string) {
+	t.Helper()
+
+	database := db.Registry["sqlite"].WithDSN(filepath.Join(t.TempDir(), "libra.db"))
+	if err := database.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	previous, previousIDLength := db.DB, config.Conf.General.IDLength
+	db.DB = database
+	config.Conf.General.IDLength = 11
+	t.Cleanup(func() {
+		db.DB = previous
+		config.Conf.General.IDLength = previousIDLength
+		database.Close()
+	})
+
+	for _, id := range userIDs {
+		user := media.DatabaseUser{User: media.User{ID: id, Username: id}}
+		if err := db.DB.CreateUser(context.Background(), user); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newRequest returns a request with body encoded as JSON, unless it's nil.
+func newRequest(t *testing.T, method, target string, body any) *http.Request {
+	t.Helper()
+
+	if body == nil {
+		return httptest.NewRequest(method, target, http.NoBody)
+	}
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, target, strings.NewReader(string(encoded)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	return req
+}
+
+// serve handles the request with the handler registered for the route, as the user, or without authentication
+// if userID is empty.
+func serve(
+	t *testing.T, route string, handler echo.HandlerFunc, req *http.Request, userID string,
+) *httptest.ResponseRecorder {
+	t.Helper()
+
+	e := echo.New()
+	e.Add(req.Method, route, handler, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if userID != "" {
+				c.Set("user", &jwt.Token{Claims: &auth.TokenClaims{UserID: userID}, Valid: true})
+			}
+			return next(c)
+		}
+	})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// addTracks stores tracks with the IDs and their durations in seconds.
+func addTracks(t *testing.T, durations map[string]int) {
+	t.Helper()
+
+	for id, duration := range durations {
+		if err := db.DB.AddTrack(context.Background(), media.Track{ID: id, Title: id, Duration: duration}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// submitListen submits the listen as the user, or without authentication if userID is empty.
+func submitListen(t *testing.T, listen echo.Map, userID string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := newRequest(t, http.MethodPost, "/api/v1/listens", listen)
+	return serve(t, "/api/v1/listens", routes.V1SubmitListen, req, userID)
+}
+
+func TestSubmitListenChecksListenedAt(t *testing.T) {
+	setup(t, "user0000001")
+	addTracks(t, map[string]int{"track000001": 200})
+
+	tests := []struct {
+		name       string
+		listenedAt any
+		want       int
+	}{
+		{"omitted", nil, http.StatusCreated},
+		{"recent", time.Now().Add(-time.Hour).Unix(), http.StatusCreated},
+		{"slightly ahead", time.Now().Add(30 * time.Second).Unix(), http.StatusCreated},
+		{"in the future", time.Now().Add(time.Hour).Unix(), http.StatusBadRequest},
+		{"zero", 0, http.StatusBadRequest},
+		{"negative", -1, http.StatusBadRequest},
+		{"before the earliest", 1033430399, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		body := echo.Map{"playable_id": "track000001", "duration_played": 100}
+		if test.listenedAt != nil {
+			body["listened_at"] = test.listenedAt
+		}
+		rec := submitListen(t, body, "user0000001")
+		if rec.Code != test.want {
+			t.Errorf("%s: got %d %s, want %d", test.name, rec.Code, rec.Body, test.want)
+		}
+	}
+
+	listens, err := db.DB.Listens(context.Background(), "user0000001", -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listens) != 3 {
+		t.Fatalf("%d listens recorded, want 3", len(listens))
+	}
+	for _, listen := range listens {
+		if listen.ListenedAt < time.Now().Add(-2*time.Hour).Unix() {
+			t.Errorf("listen recorded at %d", listen.ListenedAt)
+		}
+	}
+}
+
+func TestSubmitListenThresholds(t *testing.T) {
+	setup(t, "user0000001")
+	addTracks(t, map[string]int{"track000001": 200, "track000002": 600, "track000003": 20})
+	ctx := context.Background()
+	album := media.Album{ID: "album000001", Title: "Album"}
+	album.TrackIDs = []string{"track000001", "track000002", "track000003"}
+	if err := db.DB.AddAlbum(ctx, album); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		playableType, id string
+		played           int
+		want             int
+	}{
+		// Half of the track has to be played.
+		{"track", "track000001", 99, http.StatusBadRequest},
+		{"track", "track000001", 100, http.StatusCreated},
+		// Or four minutes of longer ones.
+		{"track", "track000002", 239, http.StatusBadRequest},
+		{"track", "track000002", 240, http.StatusCreated},
+		// Short tracks are never recorded.
+		{"track", "track000003", 20, http.StatusBadRequest},
+		// Albums have to be played for the thresholds of their tracks together, except the short one.
+		{"album", "album000001", 339, http.StatusBadRequest},
+		{"album", "album000001", 340, http.StatusCreated},
+	}
+	for _, test := range tests {
+		body := echo.Map{"playable_type": test.playableType, "playable_id": test.id, "duration_played": test.played}
+		rec := submitListen(t, body, "user0000001")
+		if rec.Code != test.want {
+			t.Errorf("%s %s played for %ds: got %d, want %d",
+				test.playableType, test.id, test.played, rec.Code, test.want)
+		}
+	}
+
+	album, err := db.DB.Album(ctx, "album000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if album.ListenCount != 1 {
+		t.Errorf("album listen count is %d, want 1", album.ListenCount)
+	}
+
+	// Marking something as playing doesn't need any of it to be played.
+	body := echo.Map{"playable_id": "track000002", "now_playing": true}
+	rec := submitListen(t, body, "user0000001")
+	if rec.Code != http.StatusOK {
+		t.Errorf("now playing got %d, want 200", rec.Code)
+	}
+	rec = serve(t, "/api/v1/users/:id/now_playing", routes.V1UserNowPlaying,
+		newRequest(t, http.MethodGet, "/api/v1/users/user0000001/now_playing", nil), "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"track000002"`) {
+		t.Errorf("now playing is %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSubmitListenRequiresAuthentication(t *testing.T) {
+	setup(t)
+
+	body := echo.Map{"playable_id": "track000001"}
+	rec := submitListen(t, body, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("got %d, want 401", rec.Code)
+	}
+}
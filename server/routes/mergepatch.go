@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"bytes"
+
+	"github.com/goccy/go-json"
+)
+
+// mergePatchContentType is the media type of JSON Merge Patch documents.
+const mergePatchContentType = "application/merge-patch+json"
+
+// mergePatch applies a JSON Merge Patch (RFC 7386) document to a JSON document.
+func mergePatch(document, patch []byte) ([]byte, error) {
+	documentValue, err := decodeJSONValue(document)
+	if err != nil {
+		return nil, err
+	}
+	patchValue, err := decodeJSONValue(patch)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(applyMergePatch(documentValue, patchValue))
+}
+
+// applyMergePatch implements the MergePatch function from RFC 7386, section 2.
+func applyMergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = applyMergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// decodeJSONValue decodes a JSON document while keeping numbers exact.
+func decodeJSONValue(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
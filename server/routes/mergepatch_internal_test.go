@@ -0,0 +1,44 @@
+package routes
+
+import "testing"
+
+func TestMergePatch(t *testing.T) {
+	// The examples of RFC 7386, appendix A.
+	tests := []struct {
+		document, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		// Numbers are kept exactly, rather than going through float64.
+		{`{"n":9007199254740993}`, `{}`, `{"n":9007199254740993}`},
+	}
+	for _, test := range tests {
+		got, err := mergePatch([]byte(test.document), []byte(test.patch))
+		if err != nil {
+			t.Errorf("%s patched with %s: %v", test.document, test.patch, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s patched with %s: got %s, want %s", test.document, test.patch, got, test.want)
+		}
+	}
+
+	for _, invalid := range [][2]string{{`{"a":"b"}`, `{`}, {`{`, `{}`}} {
+		if _, err := mergePatch([]byte(invalid[0]), []byte(invalid[1])); err == nil {
+			t.Errorf("%s patched with %s succeeded", invalid[0], invalid[1])
+		}
+	}
+}
@@ -0,0 +1,564 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/goccy/go-json"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/events"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/rules"
+	"github.com/libramusic/libracore/server/routes/auth"
+	"github.com/libramusic/libracore/smartplaylist"
+)
+
+// Request bodies larger than this are rejected when patching.
+const maxPatchSize = 1 << 20
+
+var playlistVisibilities = []string{
+	media.PlaylistVisibilityPrivate,
+	media.PlaylistVisibilityUnlisted,
+	media.PlaylistVisibilityPublic,
+}
+
+var (
+	isrcPattern = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9]{3}[0-9]{7}$`)
+	upcPattern  = regexp.MustCompile(`^[0-9]{12}$`)
+	eanPattern  = regexp.MustCompile(`^[0-9]{13}$`)
+)
+
+// playableResource describes how the routes store and validate a type of playable.
+type playableResource[T media.Playable] struct {
+	playableType string
+
+	get    func(ctx context.Context, id string) (T, error)
+	add    func(ctx context.Context, item T) error
+	update func(ctx context.Context, item T) error
+	delete func(ctx context.Context, id string) error
+
+	// newItem returns the server-managed fields of a newly created playable.
+	newItem func(id, userID string, now time.Time) T
+	// keep copies the fields clients can't change from existing to item.
+	keep func(item *T, existing T)
+	// normalize replaces nil collections with empty ones.
+	normalize func(item *T)
+	// validate returns why the playable is invalid, or an empty string if it's valid.
+	validate func(item T) string
+	// etag returns the entity tag of the playable's current state.
+	// Playables without one don't support conditional requests.
+	etag func(item T) string
+	// canView reports whether the user can see the playable. Everyone can see playables without one.
+	canView func(item T, userID string) bool
+	// prepare derives the fields computed from a valid playable before it's stored. It may be nil.
+	prepare func(ctx context.Context, item T) (T, error)
+	// refresh brings a playable that was just retrieved up to date before it's returned. It may be nil.
+	refresh func(ctx context.Context, item T) (T, error)
+}
+
+var trackResource = playableResource[media.Track]{
+	playableType: "track",
+	get:          func(ctx context.Context, id string) (media.Track, error) { return db.DB.Track(ctx, id) },
+	add:          func(ctx context.Context, track media.Track) error { return db.DB.AddTrack(ctx, track) },
+	update:       func(ctx context.Context, track media.Track) error { return db.DB.UpdateTrack(ctx, track) },
+	delete:       func(ctx context.Context, id string) error { return db.DB.DeleteTrack(ctx, id) },
+	newItem: func(id, userID string, now time.Time) media.Track {
+		return media.Track{ID: id, UserID: userID, AdditionDate: now.Unix()}
+	},
+	keep: func(track *media.Track, existing media.Track) {
+		track.ID = existing.ID
+		track.UserID = existing.UserID
+		track.ListenCount = existing.ListenCount
+		track.FavoriteCount = existing.FavoriteCount
+		track.AdditionDate = existing.AdditionDate
+		track.Favorited = nil
+	},
+	normalize: func(track *media.Track) {
+		track.ArtistIDs = emptyIfNil(track.ArtistIDs)
+		track.AlbumIDs = emptyIfNil(track.AlbumIDs)
+		track.Lyrics = emptyMapIfNil(track.Lyrics)
+		track.Tags = emptyIfNil(track.Tags)
+		track.AdditionalMeta = emptyMapIfNil(track.AdditionalMeta)
+		track.Permissions = emptyMapIfNil(track.Permissions)
+		track.LinkedItemIDs = emptyIfNil(track.LinkedItemIDs)
+		track.LyricSources = emptyMapIfNil(track.LyricSources)
+	},
+	validate: func(track media.Track) string {
+		switch {
+		case track.Title == "":
+			return "title is required"
+		case track.ISRC != "" && !isrcPattern.MatchString(track.ISRC):
+			return "isrc is not a valid ISRC"
+		case track.Duration < 0:
+			return "duration must not be negative"
+		case track.TrackNumber < 0:
+			return "track_number must not be negative"
+		case track.PrimaryAlbumID != "" && !slices.Contains(track.AlbumIDs, track.PrimaryAlbumID):
+			return "primary_album_id must be one of album_ids"
+		}
+		return ""
+	},
+}
+
+var albumResource = playableResource[media.Album]{
+	playableType: "album",
+	get:          func(ctx context.Context, id string) (media.Album, error) { return db.DB.Album(ctx, id) },
+	add:          func(ctx context.Context, album media.Album) error { return db.DB.AddAlbum(ctx, album) },
+	update:       func(ctx context.Context, album media.Album) error { return db.DB.UpdateAlbum(ctx, album) },
+	delete:       func(ctx context.Context, id string) error { return db.DB.DeleteAlbum(ctx, id) },
+	newItem: func(id, userID string, now time.Time) media.Album {
+		return media.Album{ID: id, UserID: userID, AdditionDate: now.Unix()}
+	},
+	keep: func(album *media.Album, existing media.Album) {
+		album.ID = existing.ID
+		album.UserID = existing.UserID
+		album.ListenCount = existing.ListenCount
+		album.FavoriteCount = existing.FavoriteCount
+		album.AdditionDate = existing.AdditionDate
+		album.Favorited = nil
+	},
+	normalize: func(album *media.Album) {
+		album.ArtistIDs = emptyIfNil(album.ArtistIDs)
+		album.TrackIDs = emptyIfNil(album.TrackIDs)
+		album.Tags = emptyIfNil(album.Tags)
+		album.AdditionalMeta = emptyMapIfNil(album.AdditionalMeta)
+		album.Permissions = emptyMapIfNil(album.Permissions)
+		album.LinkedItemIDs = emptyIfNil(album.LinkedItemIDs)
+	},
+	validate: func(album media.Album) string {
+		switch {
+		case album.Title == "":
+			return "title is required"
+		case album.UPC != "" && !upcPattern.MatchString(album.UPC):
+			return "upc is not a valid UPC"
+		case album.EAN != "" && !eanPattern.MatchString(album.EAN):
+			return "ean is not a valid EAN"
+		}
+		return ""
+	},
+}
+
+var videoResource = playableResource[media.Video]{
+	playableType: "video",
+	get:          func(ctx context.Context, id string) (media.Video, error) { return db.DB.Video(ctx, id) },
+	add:          func(ctx context.Context, video media.Video) error { return db.DB.AddVideo(ctx, video) },
+	update:       func(ctx context.Context, video media.Video) error { return db.DB.UpdateVideo(ctx, video) },
+	delete:       func(ctx context.Context, id string) error { return db.DB.DeleteVideo(ctx, id) },
+	newItem: func(id, userID string, now time.Time) media.Video {
+		return media.Video{ID: id, UserID: userID, AdditionDate: now.Unix()}
+	},
+	keep: func(video *media.Video, existing media.Video) {
+		video.ID = existing.ID
+		video.UserID = existing.UserID
+		video.WatchCount = existing.WatchCount
+		video.FavoriteCount = existing.FavoriteCount
+		video.AdditionDate = existing.AdditionDate
+		video.Favorited = nil
+	},
+	normalize: func(video *media.Video) {
+		video.ArtistIDs = emptyIfNil(video.ArtistIDs)
+		video.Subtitles = emptyMapIfNil(video.Subtitles)
+		video.Tags = emptyIfNil(video.Tags)
+		video.AdditionalMeta = emptyMapIfNil(video.AdditionalMeta)
+		video.Permissions = emptyMapIfNil(video.Permissions)
+		video.LinkedItemIDs = emptyIfNil(video.LinkedItemIDs)
+		video.LyricSources = emptyMapIfNil(video.LyricSources)
+	},
+	validate: func(video media.Video) string {
+		switch {
+		case video.Title == "":
+			return "title is required"
+		case video.Duration < 0:
+			return "duration must not be negative"
+		}
+		return ""
+	},
+}
+
+var artistResource = playableResource[media.Artist]{
+	playableType: "artist",
+	get:          func(ctx context.Context, id string) (media.Artist, error) { return db.DB.Artist(ctx, id) },
+	add:          func(ctx context.Context, artist media.Artist) error { return db.DB.AddArtist(ctx, artist) },
+	update:       func(ctx context.Context, artist media.Artist) error { return db.DB.UpdateArtist(ctx, artist) },
+	delete:       func(ctx context.Context, id string) error { return db.DB.DeleteArtist(ctx, id) },
+	newItem: func(id, userID string, now time.Time) media.Artist {
+		return media.Artist{ID: id, UserID: userID, AdditionDate: now.Unix()}
+	},
+	keep: func(artist *media.Artist, existing media.Artist) {
+		artist.ID = existing.ID
+		artist.UserID = existing.UserID
+		artist.ListenCount = existing.ListenCount
+		artist.FavoriteCount = existing.FavoriteCount
+		artist.AdditionDate = existing.AdditionDate
+		artist.Favorited = nil
+	},
+	normalize: func(artist *media.Artist) {
+		artist.AlbumIDs = emptyIfNil(artist.AlbumIDs)
+		artist.TrackIDs = emptyIfNil(artist.TrackIDs)
+		artist.Tags = emptyIfNil(artist.Tags)
+		artist.AdditionalMeta = emptyMapIfNil(artist.AdditionalMeta)
+		artist.Permissions = emptyMapIfNil(artist.Permissions)
+		artist.LinkedItemIDs = emptyIfNil(artist.LinkedItemIDs)
+	},
+	validate: func(artist media.Artist) string {
+		if artist.Name == "" {
+			return "name is required"
+		}
+		return ""
+	},
+}
+
+var playlistResource = playableResource[media.Playlist]{
+	playableType: "playlist",
+	get:          func(ctx context.Context, id string) (media.Playlist, error) { return db.DB.Playlist(ctx, id) },
+	add:          func(ctx context.Context, playlist media.Playlist) error { return db.DB.AddPlaylist(ctx, playlist) },
+	update: func(ctx context.Context, playlist media.Playlist) error {
+		// keep always bumps the revision, so the stored playlist must still be at the previous one.
+		return db.DB.UpdatePlaylistAtRevision(ctx, playlist, playlist.Revision-1)
+	},
+	delete: func(ctx context.Context, id string) error { return db.DB.DeletePlaylist(ctx, id) },
+	newItem: func(id, userID string, now time.Time) media.Playlist {
+		return media.Playlist{ID: id, UserID: userID, CreationDate: now.Format(time.DateOnly), AdditionDate: now.Unix()}
+	},
+	keep: func(playlist *media.Playlist, existing media.Playlist) {
+		playlist.ID = existing.ID
+		playlist.UserID = existing.UserID
+		playlist.ListenCount = existing.ListenCount
+		playlist.FavoriteCount = existing.FavoriteCount
+		playlist.CreationDate = existing.CreationDate
+		playlist.AdditionDate = existing.AdditionDate
+		playlist.Revision = existing.Revision + 1
+		playlist.EvaluatedAt = existing.EvaluatedAt
+		playlist.Favorited = nil
+		if playlist.Kind == media.PlaylistKindSmart {
+			// The rules select the tracks of smart playlists, so clients can't set them.
+			playlist.TrackIDs = existing.TrackIDs
+		}
+		// Entries are derived from the track IDs, so tracks that were already there keep their attribution.
+		playlist.Entries = media.ReconcileEntries(
+			existing.Entries, playlist.TrackIDs, existing.UserID, time.Now().Unix(),
+		)
+	},
+	normalize: func(playlist *media.Playlist) {
+		if playlist.Kind == "" {
+			playlist.Kind = media.PlaylistKindStandard
+		}
+		if playlist.Visibility == "" {
+			playlist.Visibility = media.PlaylistVisibilityPrivate
+		}
+		playlist.TrackIDs = emptyIfNil(playlist.TrackIDs)
+		playlist.Entries = emptyIfNil(playlist.Entries)
+		playlist.Tags = emptyIfNil(playlist.Tags)
+		playlist.AdditionalMeta = emptyMapIfNil(playlist.AdditionalMeta)
+		playlist.Permissions = emptyMapIfNil(playlist.Permissions)
+	},
+	validate: func(playlist media.Playlist) string {
+		if playlist.Title == "" {
+			return "title is required"
+		}
+		if !slices.Contains(playlistVisibilities, playlist.Visibility) {
+			return "visibility must be private, unlisted or public"
+		}
+		switch playlist.Kind {
+		case media.PlaylistKindStandard:
+			if playlist.Rules != "" {
+				return "only smart playlists have rules"
+			}
+		case media.PlaylistKindSmart:
+			if playlist.Rules == "" {
+				return "rules are required for smart playlists"
+			}
+			if _, err := rules.Parse(playlist.Rules); err != nil {
+				return err.Error()
+			}
+		default:
+			return "kind must be standard or smart"
+		}
+		for userID, role := range playlist.Permissions {
+			if role != media.PlaylistRoleViewer && role != media.PlaylistRoleEditor {
+				return "permissions of user " + userID + " must be viewer or editor"
+			}
+		}
+		return ""
+	},
+	etag:    playlistETag,
+	canView: media.Playlist.CanView,
+	prepare: func(ctx context.Context, playlist media.Playlist) (media.Playlist, error) {
+		if playlist.Kind != media.PlaylistKindSmart {
+			return playlist, nil
+		}
+		return smartplaylist.Evaluate(ctx, playlist)
+	},
+	refresh: smartplaylist.RefreshIfStale,
+}
+
+// readPlayable responds with a single playable.
+func readPlayable[T media.Playable](c echo.Context, r playableResource[T]) error {
+	id := c.Param("id")
+	item, err := r.get(c.Request().Context(), id)
+	if errors.Is(err, db.ErrNotFound) || (err == nil && !canViewPlayable(c, r, item)) {
+		return c.JSON(http.StatusNotFound, echo.Map{"message": r.playableType + " not found"})
+	}
+	if err != nil {
+		log.Error("Error getting "+r.playableType, "err", err, r.playableType+"ID", id)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve " + r.playableType})
+	}
+	item = refreshPlayable(c, r, item)
+
+	playable, err := markFavorite(c, item)
+	if err != nil {
+		log.Error("Error getting favorite", "err", err, r.playableType+"ID", id)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve " + r.playableType})
+	}
+	if r.etag != nil {
+		c.Response().Header().Set(headerETag, r.etag(item))
+	}
+	return c.JSON(http.StatusOK, playable)
+}
+
+// createPlayable stores a new playable owned by the authenticated user.
+func createPlayable[T media.Playable](c echo.Context, r playableResource[T]) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	var item T
+	if err := c.Bind(&item); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
+	}
+
+	r.keep(&item, r.newItem(media.GenerateID(config.Conf.General.IDLength), userID, time.Now()))
+	return savePlayable(c, r, item, http.StatusCreated, r.add)
+}
+
+// replacePlayable replaces every field of a playable that clients may change.
+func replacePlayable[T media.Playable](c echo.Context, r playableResource[T]) error {
+	existing, ok, err := ownedPlayable(c, r)
+	if !ok {
+		return err
+	}
+
+	var item T
+	if err := c.Bind(&item); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
+	}
+
+	r.keep(&item, existing)
+	return savePlayable(c, r, item, http.StatusOK, r.update)
+}
+
+// patchPlayable applies a JSON Merge Patch to a playable.
+func patchPlayable[T media.Playable](c echo.Context, r playableResource[T]) error {
+	existing, ok, err := ownedPlayable(c, r)
+	if !ok {
+		return err
+	}
+
+	contentType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if contentType != mergePatchContentType && contentType != echo.MIMEApplicationJSON {
+		return c.JSON(http.StatusUnsupportedMediaType, echo.Map{
+			"message": "content type must be " + mergePatchContentType,
+		})
+	}
+
+	patch, err := io.ReadAll(io.LimitReader(c.Request().Body, maxPatchSize+1))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": "failed to read request body"})
+	}
+	if len(patch) > maxPatchSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, echo.Map{"message": "patch is too large"})
+	}
+
+	document, err := json.Marshal(existing)
+	if err != nil {
+		log.Error("Error encoding "+r.playableType, "err", err, r.playableType+"ID", existing.GetID())
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to update " + r.playableType})
+	}
+	patched, err := mergePatch(document, patch)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": "invalid merge patch: " + err.Error()})
+	}
+
+	var item T
+	if err := json.Unmarshal(patched, &item); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": "invalid merge patch: " + err.Error()})
+	}
+
+	r.keep(&item, existing)
+	return savePlayable(c, r, item, http.StatusOK, r.update)
+}
+
+// deletePlayable deletes a playable the authenticated user may change.
+func deletePlayable[T media.Playable](c echo.Context, r playableResource[T]) error {
+	existing, ok, err := ownedPlayable(c, r)
+	if !ok {
+		return err
+	}
+
+	// Favorites of the playable are removed with it, so they don't outlive it.
+	err = db.DB.Transaction(c.Request().Context(), func(ctx context.Context) error {
+		if err := r.delete(ctx, existing.GetID()); err != nil {
+			return err
+		}
+		return db.DB.RemovePlayableFavorites(ctx, r.playableType, existing.GetID())
+	})
+	if err != nil {
+		log.Error("Error deleting "+r.playableType, "err", err, r.playableType+"ID", existing.GetID())
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to delete " + r.playableType})
+	}
+	publishPlayable(r, events.PlayableDeleted, existing)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ownedPlayable returns the playable from the request path if the authenticated user added it,
+// or their role allows changing others' content, and it still matches the request's If-Match header.
+// If ok is false, a response has already been written and err should be returned by the handler.
+func ownedPlayable[T media.Playable](c echo.Context, r playableResource[T]) (T, bool, error) {
+	return modifiablePlayable(c, r, func(item T, userID string) (bool, error) {
+		return auth.CanActFor(c.Request().Context(), userID, item.GetUserID(), media.PermissionDeleteOthersContent)
+	})
+}
+
+// modifiablePlayable is like ownedPlayable, but lets every user for whom allowed returns true modify the playable.
+func modifiablePlayable[T media.Playable](
+	c echo.Context,
+	r playableResource[T],
+	allowed func(item T, userID string) (bool, error),
+) (T, bool, error) {
+	var item T
+
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return item, false, c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	id := c.Param("id")
+	item, err := r.get(c.Request().Context(), id)
+	if errors.Is(err, db.ErrNotFound) || (err == nil && !canViewPlayable(c, r, item)) {
+		return item, false, c.JSON(http.StatusNotFound, echo.Map{"message": r.playableType + " not found"})
+	}
+	if err != nil {
+		log.Error("Error getting "+r.playableType, "err", err, r.playableType+"ID", id)
+		return item, false, c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "failed to retrieve " + r.playableType,
+		})
+	}
+
+	if ok, err := allowed(item, userID); err != nil {
+		log.Error("Error checking permission", "err", err, "userID", userID, r.playableType+"ID", id)
+		return item, false, c.JSON(http.StatusInternalServerError, echo.Map{
+			"message": "failed to check permissions",
+		})
+	} else if !ok {
+		return item, false, c.JSON(http.StatusForbidden, echo.Map{
+			"message": "not allowed to modify this " + r.playableType,
+		})
+	}
+	if r.etag != nil && !matchesETag(c.Request().Header.Get(headerIfMatch), r.etag(item)) {
+		c.Response().Header().Set(headerETag, r.etag(item))
+		return item, false, c.JSON(http.StatusPreconditionFailed, echo.Map{
+			"message": r.playableType + " was modified since it was retrieved",
+		})
+	}
+	return item, true, nil
+}
+
+// savePlayable validates a playable and stores it with save.
+func savePlayable[T media.Playable](
+	c echo.Context,
+	r playableResource[T],
+	item T,
+	status int,
+	save func(ctx context.Context, item T) error,
+) error {
+	r.normalize(&item)
+	if message := r.validate(item); message != "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": message})
+	}
+	if r.prepare != nil {
+		prepared, err := r.prepare(c.Request().Context(), item)
+		if err != nil {
+			log.Error("Error preparing "+r.playableType, "err", err, r.playableType+"ID", item.GetID())
+			return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to save " + r.playableType})
+		}
+		item = prepared
+	}
+
+	err := save(c.Request().Context(), item)
+	if errors.Is(err, db.ErrRevisionMismatch) {
+		// Another request changed the playable after it was retrieved for this one.
+		return c.JSON(conflictStatus(c), echo.Map{"message": r.playableType + " was modified concurrently"})
+	}
+	if err != nil {
+		log.Error("Error saving "+r.playableType, "err", err, r.playableType+"ID", item.GetID())
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to save " + r.playableType})
+	}
+	eventType := events.PlayableUpdated
+	if status == http.StatusCreated {
+		eventType = events.PlayableAdded
+	}
+	publishPlayable(r, eventType, item)
+
+	if r.etag != nil {
+		c.Response().Header().Set(headerETag, r.etag(item))
+	}
+	return c.JSON(status, item)
+}
+
+// publishPlayable publishes a playable event to the users who can see the playable.
+func publishPlayable[T media.Playable](r playableResource[T], eventType string, item T) {
+	var canView func(userID string) bool
+	if r.canView != nil {
+		canView = func(userID string) bool { return r.canView(item, userID) }
+	}
+	events.Publish(events.PlayableEvent(eventType, item, canView))
+}
+
+// refreshPlayable brings a playable up to date with r.refresh.
+// If that fails, the playable is returned as it was retrieved, since it's only out of date.
+func refreshPlayable[T media.Playable](c echo.Context, r playableResource[T], item T) T {
+	if r.refresh == nil {
+		return item
+	}
+	refreshed, err := r.refresh(c.Request().Context(), item)
+	if err != nil {
+		log.Error("Error refreshing "+r.playableType, "err", err, r.playableType+"ID", item.GetID())
+		return item
+	}
+	return refreshed
+}
+
+// canViewPlayable reports whether the requester can see the playable.
+// Playables the requester can't see are treated as if they didn't exist.
+func canViewPlayable[T media.Playable](c echo.Context, r playableResource[T], item T) bool {
+	if r.canView == nil {
+		return true
+	}
+	userID, _ := auth.UserIDFromContext(c)
+	return r.canView(item, userID)
+}
+
+func emptyIfNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+func emptyMapIfNil[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return map[K]V{}
+	}
+	return m
+}
@@ -0,0 +1,147 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/server/routes"
+)
+
+// addUser stores a user with the role.
+func addUser(t *testing.T, id, role string) {
+	t.Helper()
+
+	user := media.DatabaseUser{User: media.User{ID: id, Username: id}}
+	user.Permissions = map[string]string{media.PermissionRole: role}
+	if err := db.DB.CreateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// patchTrack sends the merge patch for the track as the user.
+func patchTrack(t *testing.T, id, patch, userID string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/track/"+id, strings.NewReader(patch))
+	req.Header.Set(echo.HeaderContentType, "application/merge-patch+json")
+	return serve(t, "/api/v1/track/:id", routes.V1UpdateTrack, req, userID)
+}
+
+func TestPatchTrack(t *testing.T) {
+	setup(t)
+	addUser(t, "user0000001", media.RoleMember)
+	ctx := context.Background()
+
+	track := media.Track{
+		ID:          "track000001",
+		UserID:      "user0000001",
+		Title:       "Title",
+		Description: "Description",
+		Duration:    200,
+		Tags:        []string{"jazz"},
+		Lyrics:      map[string]string{"en": "Lyrics", "de": "Liedtext"},
+		ListenCount: 3,
+	}
+	if err := db.DB.AddTrack(ctx, track); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := patchTrack(t, track.ID, `{"title":"New title","description":null,"lyrics":{"de":null}}`, "user0000001")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d %s", rec.Code, rec.Body)
+	}
+	var res media.Track
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := db.DB.Track(ctx, track.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, got := range []media.Track{res, stored} {
+		// Fields the patch leaves out are kept, and ones it sets to null are removed.
+		if got.Title != "New title" || got.Duration != 200 || len(got.Tags) != 1 {
+			t.Errorf("patched track is %+v", got)
+		}
+		if got.Description != "" {
+			t.Errorf("description set to null is %q", got.Description)
+		}
+		if len(got.Lyrics) != 1 || got.Lyrics["en"] != "Lyrics" {
+			t.Errorf("lyrics after removing one language are %v", got.Lyrics)
+		}
+	}
+
+	// Unknown fields are ignored, and fields clients can't change are kept.
+	patch := `{"unknown":"value","id":"track000002","user_id":"user0000002","listen_count":100}`
+	if rec := patchTrack(t, track.ID, patch, "user0000001"); rec.Code != http.StatusOK {
+		t.Fatalf("patch with fields that can't be changed got %d %s", rec.Code, rec.Body)
+	}
+	stored, err = db.DB.Track(ctx, track.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.UserID != "user0000001" || stored.ListenCount != 3 || stored.Title != "New title" {
+		t.Errorf("track after patching fields that can't be changed is %+v", stored)
+	}
+	if _, err := db.DB.Track(ctx, "track000002"); err == nil {
+		t.Error("patching the ID added another track")
+	}
+}
+
+func TestPatchTrackErrors(t *testing.T) {
+	setup(t)
+	addUser(t, "user0000001", media.RoleMember)
+	addUser(t, "user0000002", media.RoleMember)
+	addUser(t, "user0000003", media.RoleOwner)
+	ctx := context.Background()
+
+	track := media.Track{ID: "track000001", UserID: "user0000001", Title: "Title"}
+	if err := db.DB.AddTrack(ctx, track); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, id, patch, userID string
+		want                    int
+	}{
+		{"unauthenticated", track.ID, `{"title":"Changed"}`, "", http.StatusUnauthorized},
+		{"another user's track", track.ID, `{"title":"Changed"}`, "user0000002", http.StatusForbidden},
+		{"missing track", "track000002", `{"title":"Changed"}`, "user0000001", http.StatusNotFound},
+		{"invalid JSON", track.ID, `{"title":`, "user0000001", http.StatusBadRequest},
+		{"wrong type", track.ID, `{"duration":"long"}`, "user0000001", http.StatusBadRequest},
+		{"removing a required field", track.ID, `{"title":null}`, "user0000001", http.StatusBadRequest},
+		{"invalid value", track.ID, `{"duration":-1}`, "user0000001", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		if rec := patchTrack(t, test.id, test.patch, test.userID); rec.Code != test.want {
+			t.Errorf("%s: got %d %s, want %d", test.name, rec.Code, rec.Body, test.want)
+		}
+	}
+	if stored, err := db.DB.Track(ctx, track.ID); err != nil || stored.Title != "Title" {
+		t.Errorf("refused patches changed the track to %+v, %v", stored, err)
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/track/"+track.ID, strings.NewReader(`{"title":"Changed"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMETextPlain)
+	rec := serve(t, "/api/v1/track/:id", routes.V1UpdateTrack, req, "user0000001")
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("patch sent as text got %d, want 415", rec.Code)
+	}
+
+	// Users whose role lets them change others' content can patch any track.
+	if rec := patchTrack(t, track.ID, `{"title":"Moderated"}`, "user0000003"); rec.Code != http.StatusOK {
+		t.Errorf("owner patching another user's track got %d %s", rec.Code, rec.Body)
+	}
+	stored, err := db.DB.Track(ctx, track.ID)
+	if err != nil || stored.Title != "Moderated" || stored.UserID != track.UserID {
+		t.Errorf("track patched by the owner is %+v, %v", stored, err)
+	}
+}
@@ -0,0 +1,130 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/events"
+	"github.com/libramusic/libracore/playlistfile"
+	"github.com/libramusic/libracore/server/routes/auth"
+)
+
+// Playlist files larger than this are rejected when importing.
+const maxImportSize = 10 << 20
+
+// @Summary	Export a playlist
+// @Description	Returns the playlist as an M3U8, XSPF, PLS or JSPF file.
+// @Description	Tracks are located by their stream URLs on this server, and tracks that no longer exist are left out.
+// @ID			exportPlaylist
+// @Produce	plain
+// @Param		id		path		string	true	"Playlist ID"
+// @Param		format	query		string	false	"File format"	Enums(m3u8, xspf, pls, jspf)	default(m3u8)
+// @Success	200		{string}	string
+// @Failure	400		{object}	any
+// @Failure	404		{object}	any
+// @Failure	500		{object}	any
+// @Router		/playlist/{id}/export [get]
+func V1ExportPlaylist(c echo.Context) error {
+	format := playlistfile.M3U8
+	if name := c.QueryParam("format"); name != "" {
+		var err error
+		if format, err = playlistfile.FormatByName(name); err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"message": "format must be m3u8, xspf, pls or jspf"})
+		}
+	}
+
+	ctx := c.Request().Context()
+
+	id := c.Param("id")
+	playlist, err := db.DB.Playlist(ctx, id)
+	if errors.Is(err, db.ErrNotFound) || (err == nil && !canViewPlayable(c, playlistResource, playlist)) {
+		return c.JSON(http.StatusNotFound, echo.Map{"message": "playlist not found"})
+	}
+	if err != nil {
+		log.Error("Error getting playlist", "err", err, "playlistID", id)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve playlist"})
+	}
+	playlist = refreshPlayable(c, playlistResource, playlist)
+
+	file, err := playlistfile.Export(ctx, playlist, config.Conf.Application.PublicURL)
+	if err != nil {
+		log.Error("Error exporting playlist", "err", err, "playlistID", id)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to export playlist"})
+	}
+	var body bytes.Buffer
+	if err := format.Write(&body, file); err != nil {
+		log.Error("Error writing playlist file", "err", err, "playlistID", id, "format", format.Name)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to export playlist"})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{
+		// Path separators would make clients save the file somewhere else.
+		"filename": strings.NewReplacer("/", "_", `\`, "_").Replace(playlist.Title) + "." + format.Extension,
+	}))
+	return c.Blob(http.StatusOK, format.ContentType, body.Bytes())
+}
+
+// @Summary	Import a playlist
+// @Description	Creates a private playlist owned by the authenticated user from an M3U8, XSPF, PLS or JSPF file
+// @Description	sent as the request body. The format is detected from the file if it isn't given.
+// @Description	Entries are matched against the library by path or stream URL, ISRC, MusicBrainz recording ID,
+// @Description	and finally by title, artist and duration. Entries that match no track are left out and returned.
+// @ID			importPlaylist
+// @Accept		plain
+// @Param		format	query		string	false	"File format"	Enums(m3u8, xspf, pls, jspf)
+// @Param		title	query		string	false	"Title of the playlist, instead of the one in the file"
+// @Param		body	body		string	true	"Playlist file"
+// @Success	201		{object}	playlistfile.ImportResult
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	413		{object}	any
+// @Failure	500		{object}	any
+// @Router		/playlists/import [post]
+func V1ImportPlaylist(c echo.Context) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	data, err := io.ReadAll(io.LimitReader(c.Request().Body, maxImportSize+1))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": "failed to read request body"})
+	}
+	if len(data) > maxImportSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, echo.Map{"message": "playlist file is too large"})
+	}
+
+	format := playlistfile.Detect(data)
+	if name := c.QueryParam("format"); name != "" {
+		if format, err = playlistfile.FormatByName(name); err != nil {
+			return c.JSON(http.StatusBadRequest, echo.Map{"message": "format must be m3u8, xspf, pls or jspf"})
+		}
+	}
+	file, err := format.Parse(data)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": "invalid " + format.Name + " file: " + err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	matcher, err := playlistfile.NewMatcher(ctx)
+	if err != nil {
+		log.Error("Error getting tracks to match", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to import playlist"})
+	}
+	result, err := playlistfile.Import(ctx, matcher, userID, c.QueryParam("title"), file)
+	if err != nil {
+		log.Error("Error importing playlist", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to import playlist"})
+	}
+	publishPlayable(playlistResource, events.PlayableAdded, result.Playlist)
+	return c.JSON(http.StatusCreated, result)
+}
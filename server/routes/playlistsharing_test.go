@@ -0,0 +1,128 @@
+package routes_test
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/server/routes"
+)
+
+// patchPlaylist sends the merge patch for the playlist as the user.
+func patchPlaylist(t *testing.T, patch, userID string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/playlist/playlist001", strings.NewReader(patch))
+	req.Header.Set(echo.HeaderContentType, "application/merge-patch+json")
+	return serve(t, "/api/v1/playlist/:id", routes.V1UpdatePlaylist, req, userID)
+}
+
+// getPlaylist gets the playlist as the user, or without authentication if userID is empty.
+func getPlaylist(t *testing.T, userID string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := newRequest(t, http.MethodGet, "/api/v1/playlist/playlist001", nil)
+	return serve(t, "/api/v1/playlist/:id", routes.V1Playlist, req, userID)
+}
+
+func TestSharePlaylist(t *testing.T) {
+	setup(t, "user0000001", "user0000002", "user0000003", "user0000004")
+	addPlaylist(t, media.PlaylistKindStandard)
+
+	if rec := getPlaylist(t, "user0000004"); rec.Code != http.StatusNotFound {
+		t.Errorf("user the playlist isn't shared with got %d, want 404", rec.Code)
+	}
+
+	patch := `{"permissions":{"user0000003":null,"user0000004":"viewer"}}`
+	if rec := patchPlaylist(t, patch, "user0000001"); rec.Code != http.StatusOK {
+		t.Fatalf("sharing the playlist got %d %s", rec.Code, rec.Body)
+	}
+	if rec := getPlaylist(t, "user0000004"); rec.Code != http.StatusOK {
+		t.Errorf("user the playlist was shared with got %d, want 200", rec.Code)
+	}
+	if rec := getPlaylist(t, "user0000003"); rec.Code != http.StatusNotFound {
+		t.Errorf("user the playlist is no longer shared with got %d, want 404", rec.Code)
+	}
+
+	rec := patchPlaylist(t, `{"permissions":{"user0000004":"owner"}}`, "user0000001")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("sharing with an unknown role got %d, want 400", rec.Code)
+	}
+}
+
+func TestSharingPlaylistIsRefused(t *testing.T) {
+	setup(t, "user0000001", "user0000002", "user0000003", "user0000004")
+	addTracks(t, map[string]int{"track000001": 200})
+	addPlaylist(t, media.PlaylistKindStandard, "track000001")
+	ctx := context.Background()
+
+	tests := []struct {
+		name, patch, userID string
+		want                int
+	}{
+		{"unauthenticated", `{"permissions":{"user0000004":"viewer"}}`, "", http.StatusUnauthorized},
+		// Editors can change the tracks, but not who the playlist is shared with or who can see it.
+		{"editor sharing", `{"permissions":{"user0000004":"editor"}}`, "user0000002", http.StatusForbidden},
+		{"editor publishing", `{"visibility":"public"}`, "user0000002", http.StatusForbidden},
+		{"viewer sharing", `{"permissions":{"user0000003":"editor"}}`, "user0000003", http.StatusForbidden},
+		// Users the playlist isn't shared with can't tell that it exists.
+		{"unshared user sharing", `{"permissions":{"user0000004":"editor"}}`, "user0000004", http.StatusNotFound},
+		{"unshared user publishing", `{"visibility":"public"}`, "user0000004", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		if rec := patchPlaylist(t, test.patch, test.userID); rec.Code != test.want {
+			t.Errorf("%s: got %d %s, want %d", test.name, rec.Code, rec.Body, test.want)
+		}
+	}
+
+	req := newRequest(t, http.MethodPut, "/api/v1/playlist/playlist001", echo.Map{
+		"title":       "Playlist",
+		"visibility":  media.PlaylistVisibilityPublic,
+		"permissions": echo.Map{"user0000002": media.PlaylistRoleEditor},
+	})
+	rec := serve(t, "/api/v1/playlist/:id", routes.V1ReplacePlaylist, req, "user0000002")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("editor replacing the playlist got %d, want 403", rec.Code)
+	}
+
+	playlist, err := db.DB.Playlist(ctx, "playlist001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"user0000002": media.PlaylistRoleEditor, "user0000003": media.PlaylistRoleViewer}
+	if playlist.Visibility != media.PlaylistVisibilityPrivate || !maps.Equal(playlist.Permissions, want) {
+		t.Errorf("refused changes left the playlist %s with permissions %v", playlist.Visibility, playlist.Permissions)
+	}
+
+	// Nothing else about the playlist is revealed to users it isn't shared with.
+	req = newRequest(t, http.MethodGet, "/api/v1/playlist/playlist001/tracks", nil)
+	rec = serve(t, "/api/v1/playlist/:id/tracks", routes.V1PlaylistTracks, req, "user0000004")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unshared user getting the tracks got %d, want 404", rec.Code)
+	}
+	req = newRequest(t, http.MethodPut, "/api/v1/playlist/playlist001/favorite", nil)
+	rec = serve(t, "/api/v1/:type/:id/favorite", routes.V1AddFavorite, req, "user0000004")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unshared user favoriting the playlist got %d, want 404", rec.Code)
+	}
+	body := echo.Map{"playable_type": "playlist", "playable_id": "playlist001", "duration_played": 200}
+	if rec := submitListen(t, body, "user0000004"); rec.Code != http.StatusNotFound {
+		t.Errorf("unshared user listening to the playlist got %d, want 404", rec.Code)
+	}
+	if rec := getPlaylist(t, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unauthenticated request got %d, want 404", rec.Code)
+	}
+	for userID, listed := range map[string]bool{"user0000003": true, "user0000004": false} {
+		req = newRequest(t, http.MethodGet, "/api/v1/playables/user0000001", nil)
+		rec = serve(t, "/api/v1/playables/:id", routes.V1UserPlayables, req, userID)
+		if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "playlist001") != listed {
+			t.Errorf("owner's playables listed for %s are %d %s", userID, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/server/routes/auth"
+)
+
+const (
+	defaultPlaylistTracksLimit = 100
+	maxPlaylistTracksLimit     = 500
+)
+
+type playlistTrackResponse struct {
+	media.PlaylistEntry
+
+	// The position of the track in the playlist, starting at 0.
+	Position int `json:"position" example:"0"`
+	// The track, or null if it no longer exists.
+	Track media.Playable `json:"track"`
+}
+
+type addPlaylistTracksRequest struct {
+	TrackIDs []string `json:"track_ids"`
+	// Where to insert the tracks. The tracks are appended if it's omitted.
+	Position *int `json:"position"`
+}
+
+type movePlaylistTrackRequest struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+type replacePlaylistTracksRequest struct {
+	TrackIDs []string `json:"track_ids"`
+}
+
+// @Summary	Get the tracks of a playlist
+// @Description	Returns the playlist's tracks in order along with who added them.
+// @Description	The ETag header holds the playlist's revision.
+// @ID			getPlaylistTracks
+// @Param		id		path		string	true	"Playlist ID"
+// @Param		limit	query		int		false	"Maximum number of tracks to return"	default(100)
+// @Param		offset	query		int		false	"Number of tracks to skip"				default(0)
+// @Success	200		{array}		playlistTrackResponse
+// @Failure	400		{object}	any
+// @Failure	404		{object}	any
+// @Failure	500		{object}	any
+// @Router		/playlist/{id}/tracks [get]
+func V1PlaylistTracks(c echo.Context) error {
+	limit, offset, err := paginationParams(c, defaultPlaylistTracksLimit, maxPlaylistTracksLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
+	}
+
+	ctx := c.Request().Context()
+
+	id := c.Param("id")
+	playlist, err := db.DB.Playlist(ctx, id)
+	if errors.Is(err, db.ErrNotFound) || (err == nil && !canViewPlayable(c, playlistResource, playlist)) {
+		return c.JSON(http.StatusNotFound, echo.Map{"message": "playlist not found"})
+	}
+	if err != nil {
+		log.Error("Error getting playlist", "err", err, "playlistID", id)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve playlist"})
+	}
+	playlist = refreshPlayable(c, playlistResource, playlist)
+
+	entries := playlistEntries(playlist)
+	start := min(offset, len(entries))
+	end := min(start+limit, len(entries))
+	entries = entries[start:end]
+
+	tracks := make([]media.Playable, len(entries))
+	for i, entry := range entries {
+		track, err := db.DB.Track(ctx, entry.TrackID)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			log.Error("Error getting track", "err", err, "trackID", entry.TrackID)
+			return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve tracks"})
+		}
+		if err == nil {
+			tracks[i] = track
+		}
+	}
+	if err := markFavorites(c, tracks); err != nil {
+		log.Error("Error getting favorites", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve tracks"})
+	}
+
+	response := make([]playlistTrackResponse, len(entries))
+	for i, entry := range entries {
+		response[i] = playlistTrackResponse{PlaylistEntry: entry, Position: start + i, Track: tracks[i]}
+	}
+
+	c.Response().Header().Set(headerETag, playlistETag(playlist))
+	return c.JSON(http.StatusOK, echo.Map{
+		"tracks":   response,
+		"total":    len(playlist.TrackIDs),
+		"limit":    limit,
+		"offset":   offset,
+		"revision": playlist.Revision,
+	})
+}
+
+// @Summary	Add tracks to a playlist
+// @Description	Inserts the tracks at the given position, or appends them if no position is given.
+// @Description	The playlist's owner and editors can add tracks, which are attributed to the user who added them.
+// @Description	Send the playlist's ETag in the If-Match header to only change the playlist if nobody else has.
+// @Description	The tracks of smart playlists can't be edited.
+// @ID			addPlaylistTracks
+// @Accept		json
+// @Param		id			path		string						true	"Playlist ID"
+// @Param		If-Match	header		string						false	"ETag of the playlist the change is based on"
+// @Param		body		body		addPlaylistTracksRequest	true	"Tracks to add"
+// @Success	200			{object}	media.Playlist
+// @Failure	400			{object}	any
+// @Failure	401			{object}	any
+// @Failure	403			{object}	any
+// @Failure	404			{object}	any
+// @Failure	409			{object}	any
+// @Failure	412			{object}	any
+// @Failure	500			{object}	any
+// @Router		/playlist/{id}/tracks [post]
+func V1AddPlaylistTracks(c echo.Context) error {
+	var req addPlaylistTracksRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
+	}
+	if len(req.TrackIDs) == 0 {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": "track_ids is required"})
+	}
+	if ok, err := checkTracksExist(c, req.TrackIDs); !ok {
+		return err
+	}
+
+	return editPlaylistTracks(c, func(entries []media.PlaylistEntry, userID string) ([]media.PlaylistEntry, string) {
+		position := len(entries)
+		if req.Position != nil {
+			position = *req.Position
+		}
+		if position < 0 || position > len(entries) {
+			return nil, "position is out of range"
+		}
+		added := media.ReconcileEntries(nil, req.TrackIDs, userID, time.Now().Unix())
+		return slices.Insert(entries, position, added...), ""
+	})
+}
+
+// @Summary	Move a track within a playlist
+// @Description	Moves the track at position from so that it ends up at position to.
+// @Description	Send the playlist's ETag in the If-Match header to only change the playlist if nobody else has.
+// @Description	The tracks of smart playlists can't be edited.
+// @ID			movePlaylistTrack
+// @Accept		json
+// @Param		id			path		string						true	"Playlist ID"
+// @Param		If-Match	header		string						false	"ETag of the playlist the change is based on"
+// @Param		body		body		movePlaylistTrackRequest	true	"Positions to move the track between"
+// @Success	200			{object}	media.Playlist
+// @Failure	400			{object}	any
+// @Failure	401			{object}	any
+// @Failure	403			{object}	any
+// @Failure	404			{object}	any
+// @Failure	409			{object}	any
+// @Failure	412			{object}	any
+// @Failure	500			{object}	any
+// @Router		/playlist/{id}/tracks/move [post]
+func V1MovePlaylistTrack(c echo.Context) error {
+	var req movePlaylistTrackRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
+	}
+
+	return editPlaylistTracks(c, func(entries []media.PlaylistEntry, _ string) ([]media.PlaylistEntry, string) {
+		if req.From < 0 || req.From >= len(entries) || req.To < 0 || req.To >= len(entries) {
+			return nil, "position is out of range"
+		}
+		entry := entries[req.From]
+		entries = slices.Delete(entries, req.From, req.From+1)
+		return slices.Insert(entries, req.To, entry), ""
+	})
+}
+
+// @Summary	Remove a track from a playlist
+// @Description	Removes the track at the given position, so only one copy of a track that appears twice is removed.
+// @Description	Send the playlist's ETag in the If-Match header to only change the playlist if nobody else has.
+// @Description	The tracks of smart playlists can't be edited.
+// @ID			removePlaylistTrack
+// @Param		id			path		string	true	"Playlist ID"
+// @Param		position	path		int		true	"Position of the track, starting at 0"
+// @Param		If-Match	header		string	false	"ETag of the playlist the change is based on"
+// @Success	200			{object}	media.Playlist
+// @Failure	400			{object}	any
+// @Failure	401			{object}	any
+// @Failure	403			{object}	any
+// @Failure	404			{object}	any
+// @Failure	409			{object}	any
+// @Failure	412			{object}	any
+// @Failure	500			{object}	any
+// @Router		/playlist/{id}/tracks/{position} [delete]
+func V1RemovePlaylistTrack(c echo.Context) error {
+	position, err := strconv.Atoi(c.Param("position"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": "position must be an integer"})
+	}
+
+	return editPlaylistTracks(c, func(entries []media.PlaylistEntry, _ string) ([]media.PlaylistEntry, string) {
+		if position < 0 || position >= len(entries) {
+			return nil, "position is out of range"
+		}
+		return slices.Delete(entries, position, position+1), ""
+	})
+}
+
+// @Summary	Replace the tracks of a playlist
+// @Description	Send the playlist's ETag in the If-Match header to only change the playlist if nobody else has.
+// @Description	The tracks of smart playlists can't be edited.
+// @ID			replacePlaylistTracks
+// @Accept		json
+// @Param		id			path		string							true	"Playlist ID"
+// @Param		If-Match	header		string							false	"ETag of the playlist the change is based on"
+// @Param		body		body		replacePlaylistTracksRequest	true	"The new tracks of the playlist"
+// @Success	200			{object}	media.Playlist
+// @Failure	400			{object}	any
+// @Failure	401			{object}	any
+// @Failure	403			{object}	any
+// @Failure	404			{object}	any
+// @Failure	409			{object}	any
+// @Failure	412			{object}	any
+// @Failure	500			{object}	any
+// @Router		/playlist/{id}/tracks [put]
+func V1ReplacePlaylistTracks(c echo.Context) error {
+	var req replacePlaylistTracksRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
+	}
+	if ok, err := checkTracksExist(c, req.TrackIDs); !ok {
+		return err
+	}
+
+	return editPlaylistTracks(c, func(entries []media.PlaylistEntry, userID string) ([]media.PlaylistEntry, string) {
+		// Tracks that stay in the playlist keep their attribution.
+		return media.ReconcileEntries(entries, req.TrackIDs, userID, time.Now().Unix()), ""
+	})
+}
+
+// editPlaylistTracks applies edit to the entries of the playlist from the request path and stores the result.
+// The playlist's owner and editors can edit it, unless it's a smart playlist.
+// edit is called with the entries and the editing user's ID,
+// and returns why the edit is invalid instead of the new entries if it can't be applied.
+func editPlaylistTracks(
+	c echo.Context,
+	edit func(entries []media.PlaylistEntry, userID string) ([]media.PlaylistEntry, string),
+) error {
+	canEdit := func(playlist media.Playlist, userID string) (bool, error) { return playlist.CanEdit(userID), nil }
+	existing, ok, err := modifiablePlayable(c, playlistResource, canEdit)
+	if !ok {
+		return err
+	}
+	if existing.Kind == media.PlaylistKindSmart {
+		return c.JSON(http.StatusConflict, echo.Map{
+			"message": "the tracks of smart playlists are selected by their rules",
+		})
+	}
+
+	userID, _ := auth.UserIDFromContext(c)
+	entries, message := edit(playlistEntries(existing), userID)
+	if message != "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": message})
+	}
+
+	playlist := existing
+	playlist.TrackIDs = make([]string, len(entries))
+	for i, entry := range entries {
+		playlist.TrackIDs[i] = entry.TrackID
+	}
+	playlistResource.keep(&playlist, existing)
+	// keep attributes new tracks to the owner, but they were added by the editing user.
+	playlist.Entries = entries
+	return savePlayable(c, playlistResource, playlist, http.StatusOK, playlistResource.update)
+}
+
+// playlistEntries returns a copy of the playlist's entries that matches its track IDs.
+// Tracks without an entry are attributed to the owner at the time the playlist was added.
+func playlistEntries(playlist media.Playlist) []media.PlaylistEntry {
+	return media.ReconcileEntries(playlist.Entries, playlist.TrackIDs, playlist.UserID, playlist.AdditionDate)
+}
+
+// checkTracksExist responds with an error unless every track exists.
+// If ok is false, a response has already been written and err should be returned by the handler.
+func checkTracksExist(c echo.Context, trackIDs []string) (bool, error) {
+	ctx := c.Request().Context()
+	for _, trackID := range trackIDs {
+		_, err := db.DB.Track(ctx, trackID)
+		if errors.Is(err, db.ErrNotFound) {
+			return false, c.JSON(http.StatusBadRequest, echo.Map{"message": "track " + trackID + " not found"})
+		}
+		if err != nil {
+			log.Error("Error getting track", "err", err, "trackID", trackID)
+			return false, c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve track"})
+		}
+	}
+	return true, nil
+}
+
+// playlistETag returns the entity tag of a playlist, which changes with every revision.
+func playlistETag(playlist media.Playlist) string {
+	return revisionETag(playlist.Revision)
+}
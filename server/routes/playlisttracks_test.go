@@ -0,0 +1,244 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/server/routes"
+)
+
+// addPlaylist stores a private playlist of user0000001 with the tracks, shared with user0000002 as an editor
+// and user0000003 as a viewer.
+func addPlaylist(t *testing.T, kind string, trackIDs ...string) {
+	t.Helper()
+
+	playlist := media.Playlist{
+		ID:         "playlist001",
+		UserID:     "user0000001",
+		Title:      "Playlist",
+		TrackIDs:   trackIDs,
+		Visibility: media.PlaylistVisibilityPrivate,
+		Kind:       kind,
+		Permissions: map[string]string{
+			"user0000002": media.PlaylistRoleEditor,
+			"user0000003": media.PlaylistRoleViewer,
+		},
+	}
+	if kind == media.PlaylistKindSmart {
+		playlist.Rules = `tag = "jazz"`
+	}
+	if err := db.DB.AddPlaylist(context.Background(), playlist); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// editTracks changes the tracks of the playlist as the user, sending ifMatch in the If-Match header unless it's
+// empty.
+func editTracks(
+	t *testing.T, method, route, target string, handler echo.HandlerFunc, body any, ifMatch, userID string,
+) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := newRequest(t, method, target, body)
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	return serve(t, route, handler, req, userID)
+}
+
+// moveTrack moves a track of the playlist as the user.
+func moveTrack(t *testing.T, from, to int, ifMatch, userID string) *httptest.ResponseRecorder {
+	t.Helper()
+	return editTracks(t, http.MethodPost,
+		"/api/v1/playlist/:id/tracks/move", "/api/v1/playlist/playlist001/tracks/move", routes.V1MovePlaylistTrack,
+		echo.Map{"from": from, "to": to}, ifMatch, userID)
+}
+
+// playlistTracks returns the stored playlist's track IDs and the ETag it's served with.
+func playlistTracks(t *testing.T) ([]string, string) {
+	t.Helper()
+
+	req := newRequest(t, http.MethodGet, "/api/v1/playlist/playlist001/tracks", nil)
+	rec := serve(t, "/api/v1/playlist/:id/tracks", routes.V1PlaylistTracks, req, "user0000001")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("getting the tracks got %d %s", rec.Code, rec.Body)
+	}
+	playlist, err := db.DB.Playlist(context.Background(), "playlist001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return playlist.TrackIDs, rec.Header().Get("ETag")
+}
+
+func TestEditPlaylistTracks(t *testing.T) {
+	setup(t, "user0000001", "user0000002")
+	addTracks(t, map[string]int{"track000001": 200, "track000002": 200, "track000003": 200, "track000004": 200})
+	addPlaylist(t, media.PlaylistKindStandard, "track000001", "track000002", "track000003")
+	ctx := context.Background()
+
+	if rec := moveTrack(t, 0, 2, "", "user0000001"); rec.Code != http.StatusOK {
+		t.Fatalf("moving a track got %d %s", rec.Code, rec.Body)
+	}
+	if got, _ := playlistTracks(t); !slices.Equal(got, []string{"track000002", "track000003", "track000001"}) {
+		t.Errorf("tracks after moving the first to the end are %v", got)
+	}
+	if rec := moveTrack(t, 2, 1, "", "user0000001"); rec.Code != http.StatusOK {
+		t.Fatalf("moving a track got %d %s", rec.Code, rec.Body)
+	}
+	if got, _ := playlistTracks(t); !slices.Equal(got, []string{"track000002", "track000001", "track000003"}) {
+		t.Errorf("tracks after moving the last one back are %v", got)
+	}
+
+	// Editors can add tracks, which are attributed to them.
+	rec := editTracks(t, http.MethodPost, "/api/v1/playlist/:id/tracks", "/api/v1/playlist/playlist001/tracks",
+		routes.V1AddPlaylistTracks, echo.Map{"track_ids": []string{"track000004"}, "position": 1}, "", "user0000002")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("adding a track got %d %s", rec.Code, rec.Body)
+	}
+	want := []string{"track000002", "track000004", "track000001", "track000003"}
+	if got, _ := playlistTracks(t); !slices.Equal(got, want) {
+		t.Errorf("tracks after adding one at position 1 are %v", got)
+	}
+	playlist, err := db.DB.Playlist(ctx, "playlist001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range playlist.Entries {
+		addedBy := "user0000001"
+		if entry.TrackID == "track000004" {
+			addedBy = "user0000002"
+		}
+		if entry.AddedBy != addedBy {
+			t.Errorf("%s was added by %s, want %s", entry.TrackID, entry.AddedBy, addedBy)
+		}
+	}
+
+	rec = editTracks(t, http.MethodDelete, "/api/v1/playlist/:id/tracks/:position",
+		"/api/v1/playlist/playlist001/tracks/0", routes.V1RemovePlaylistTrack, nil, "", "user0000001")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("removing a track got %d %s", rec.Code, rec.Body)
+	}
+	if got, _ := playlistTracks(t); !slices.Equal(got, want[1:]) {
+		t.Errorf("tracks after removing the first are %v", got)
+	}
+
+	// Moving a track keeps who added it.
+	if rec := moveTrack(t, 0, 2, "", "user0000001"); rec.Code != http.StatusOK {
+		t.Fatalf("moving a track got %d %s", rec.Code, rec.Body)
+	}
+	playlist, err = db.DB.Playlist(ctx, "playlist001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := playlist.Entries[len(playlist.Entries)-1]
+	if last.TrackID != "track000004" || last.AddedBy != "user0000002" {
+		t.Errorf("moved entry is %+v", last)
+	}
+}
+
+func TestEditPlaylistTracksErrors(t *testing.T) {
+	setup(t, "user0000001", "user0000002", "user0000003", "user0000004")
+	addTracks(t, map[string]int{"track000001": 200, "track000002": 200})
+	addPlaylist(t, media.PlaylistKindStandard, "track000001", "track000002")
+
+	tests := []struct {
+		name     string
+		from, to int
+		userID   string
+		want     int
+	}{
+		{"unauthenticated", 0, 1, "", http.StatusUnauthorized},
+		{"viewer", 0, 1, "user0000003", http.StatusForbidden},
+		{"user it isn't shared with", 0, 1, "user0000004", http.StatusNotFound},
+		{"negative position", -1, 1, "user0000001", http.StatusBadRequest},
+		{"from past the end", 2, 0, "user0000001", http.StatusBadRequest},
+		{"to past the end", 0, 2, "user0000001", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		if rec := moveTrack(t, test.from, test.to, "", test.userID); rec.Code != test.want {
+			t.Errorf("%s: got %d %s, want %d", test.name, rec.Code, rec.Body, test.want)
+		}
+	}
+
+	rec := editTracks(t, http.MethodPut, "/api/v1/playlist/:id/tracks", "/api/v1/playlist/playlist001/tracks",
+		routes.V1ReplacePlaylistTracks, echo.Map{"track_ids": []string{"track000003"}}, "", "user0000001")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("replacing the tracks with a missing one got %d, want 400", rec.Code)
+	}
+	rec = editTracks(t, http.MethodDelete, "/api/v1/playlist/:id/tracks/:position",
+		"/api/v1/playlist/playlist001/tracks/first", routes.V1RemovePlaylistTrack, nil, "", "user0000001")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("removing a track at a position that isn't a number got %d, want 400", rec.Code)
+	}
+	if got, _ := playlistTracks(t); !slices.Equal(got, []string{"track000001", "track000002"}) {
+		t.Errorf("refused edits changed the tracks to %v", got)
+	}
+}
+
+func TestEditSmartPlaylistTracks(t *testing.T) {
+	setup(t, "user0000001")
+	addTracks(t, map[string]int{"track000001": 200, "track000002": 200})
+	addPlaylist(t, media.PlaylistKindSmart, "track000001", "track000002")
+
+	if rec := moveTrack(t, 0, 1, "", "user0000001"); rec.Code != http.StatusConflict {
+		t.Errorf("moving a track of a smart playlist got %d, want 409", rec.Code)
+	}
+}
+
+func TestEditPlaylistTracksIfMatch(t *testing.T) {
+	setup(t, "user0000001")
+	addTracks(t, map[string]int{"track000001": 200, "track000002": 200, "track000003": 200})
+	addPlaylist(t, media.PlaylistKindStandard, "track000001", "track000002", "track000003")
+
+	_, etag := playlistTracks(t)
+	if etag == "" {
+		t.Fatal("tracks are served without an ETag")
+	}
+
+	rec := moveTrack(t, 0, 1, etag, "user0000001")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("moving with the current ETag got %d %s", rec.Code, rec.Body)
+	}
+	newETag := rec.Header().Get("ETag")
+	if newETag == "" || newETag == etag {
+		t.Fatalf("ETag after moving is %q, before it was %q", newETag, etag)
+	}
+	if got, current := playlistTracks(t); current != newETag {
+		t.Errorf("tracks %v are served with ETag %q, the edit responded with %q", got, current, newETag)
+	}
+
+	// An edit based on the playlist before the move fails and returns the current ETag.
+	rec = moveTrack(t, 0, 2, etag, "user0000001")
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("moving with a stale ETag got %d, want 412", rec.Code)
+	}
+	if got := rec.Header().Get("ETag"); got != newETag {
+		t.Errorf("failed precondition returned ETag %q, want %q", got, newETag)
+	}
+	want := []string{"track000002", "track000001", "track000003"}
+	if got, _ := playlistTracks(t); !slices.Equal(got, want) {
+		t.Errorf("tracks after the failed move are %v, want %v", got, want)
+	}
+
+	for _, ifMatch := range []string{"W/" + newETag, `"` + strconv.Itoa(1<<20) + `"`} {
+		if rec := moveTrack(t, 0, 2, ifMatch, "user0000001"); rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("moving with If-Match %s got %d, want 412", ifMatch, rec.Code)
+		}
+	}
+	if rec := moveTrack(t, 0, 1, "*", "user0000001"); rec.Code != http.StatusOK {
+		t.Errorf("moving with If-Match * got %d %s", rec.Code, rec.Body)
+	}
+	// Any of a list of entity tags can match.
+	_, current := playlistTracks(t)
+	if rec := moveTrack(t, 0, 1, etag+", "+current, "user0000001"); rec.Code != http.StatusOK {
+		t.Errorf("moving with If-Match %s, %s got %d %s", etag, current, rec.Code, rec.Body)
+	}
+}
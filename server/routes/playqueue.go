@@ -0,0 +1,191 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/events"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/server/routes/auth"
+)
+
+// Queues longer than this are rejected, as they are stored and sent as a whole.
+const maxPlayQueueItems = 10000
+
+var (
+	queueItemTypes    = []string{"track", "video"}
+	queueContextTypes = []string{"album", "artist", "playlist", "track", "video"}
+	queueRepeatModes  = []string{media.RepeatOff, media.RepeatAll, media.RepeatOne}
+)
+
+type replacePlayQueueRequest struct {
+	Items []media.QueueItem `json:"items"`
+
+	// The position of the playing item in items, starting at 0.
+	CurrentIndex int `json:"current_index" example:"2"`
+
+	// How far into the playing item playback is, in milliseconds.
+	PositionMS int64  `json:"position_ms" example:"73500"`
+	Shuffle    bool   `json:"shuffle"`
+	Repeat     string `json:"repeat"      example:"off"`
+
+	// What the queue was started from. It's empty for queues that were put together by hand.
+	Context media.QueueContext `json:"context"`
+
+	// The name of the device that is playing the queue.
+	Device string `json:"device" example:"Phone"`
+}
+
+// @Summary	Get the user's play queue
+// @Description	Returns what the authenticated user is listening to, so playback can continue on another device.
+// @Description	Users that never stored a queue get an empty one at revision 0.
+// @Description	The ETag header holds the queue's revision.
+// @ID			getPlayQueue
+// @Success	200	{object}	media.PlayQueue
+// @Failure	401	{object}	any
+// @Failure	500	{object}	any
+// @Router		/me/queue [get]
+func V1PlayQueue(c echo.Context) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	queue, err := storedPlayQueue(c, userID)
+	if err != nil {
+		log.Error("Error getting play queue", "err", err, "userID", userID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve play queue"})
+	}
+
+	c.Response().Header().Set(headerETag, revisionETag(queue.Revision))
+	return c.JSON(http.StatusOK, queue)
+}
+
+// @Summary	Replace the user's play queue
+// @Description	Stores what the authenticated user is listening to, replacing their previous queue.
+// @Description	Every change increments the queue's revision.
+// @Description	Send the queue's ETag in the If-Match header to only replace the queue if no other device has.
+// @Description	If another device changed the queue, the response includes its current queue to resolve the conflict.
+// @ID			replacePlayQueue
+// @Accept		json
+// @Param		If-Match	header		string					false	"ETag of the queue the change is based on"
+// @Param		body		body		replacePlayQueueRequest	true	"The new play queue"
+// @Success	200			{object}	media.PlayQueue
+// @Failure	400			{object}	any
+// @Failure	401			{object}	any
+// @Failure	409			{object}	any
+// @Failure	412			{object}	any
+// @Failure	500			{object}	any
+// @Router		/me/queue [put]
+func V1ReplacePlayQueue(c echo.Context) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	var req replacePlayQueueRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
+	}
+	if req.Items == nil {
+		req.Items = []media.QueueItem{}
+	}
+	if req.Repeat == "" {
+		req.Repeat = media.RepeatOff
+	}
+	if message := validatePlayQueue(req); message != "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": message})
+	}
+
+	existing, err := storedPlayQueue(c, userID)
+	if err != nil {
+		log.Error("Error getting play queue", "err", err, "userID", userID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve play queue"})
+	}
+	if !matchesETag(c.Request().Header.Get(headerIfMatch), revisionETag(existing.Revision)) {
+		c.Response().Header().Set(headerETag, revisionETag(existing.Revision))
+		return c.JSON(http.StatusPreconditionFailed, echo.Map{
+			"message": "play queue was modified since it was retrieved",
+			"queue":   existing,
+		})
+	}
+
+	queue := media.PlayQueue{
+		UserID:       userID,
+		Items:        req.Items,
+		CurrentIndex: req.CurrentIndex,
+		PositionMS:   req.PositionMS,
+		Shuffle:      req.Shuffle,
+		Repeat:       req.Repeat,
+		Context:      req.Context,
+		Device:       req.Device,
+		Revision:     existing.Revision + 1,
+		UpdatedAt:    time.Now().Unix(),
+	}
+	err = db.DB.SavePlayQueueAtRevision(c.Request().Context(), queue, existing.Revision)
+	if errors.Is(err, db.ErrRevisionMismatch) {
+		// Another device stored its queue after the queue was retrieved for this request.
+		latest, err := storedPlayQueue(c, userID)
+		if err != nil {
+			log.Error("Error getting play queue", "err", err, "userID", userID)
+			return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve play queue"})
+		}
+		c.Response().Header().Set(headerETag, revisionETag(latest.Revision))
+		return c.JSON(conflictStatus(c), echo.Map{"message": "play queue was modified concurrently", "queue": latest})
+	}
+	if err != nil {
+		log.Error("Error saving play queue", "err", err, "userID", userID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to save play queue"})
+	}
+	events.Publish(events.ForUser(userID, events.QueueChanged, queue))
+
+	c.Response().Header().Set(headerETag, revisionETag(queue.Revision))
+	return c.JSON(http.StatusOK, queue)
+}
+
+// storedPlayQueue returns the user's play queue, or an empty queue at revision 0 if they never stored one.
+func storedPlayQueue(c echo.Context, userID string) (media.PlayQueue, error) {
+	queue, err := db.DB.PlayQueue(c.Request().Context(), userID)
+	if errors.Is(err, db.ErrNotFound) {
+		return media.PlayQueue{UserID: userID, Items: []media.QueueItem{}, Repeat: media.RepeatOff}, nil
+	}
+	return queue, err
+}
+
+// validatePlayQueue returns why a play queue is invalid, or an empty string if it's valid.
+func validatePlayQueue(req replacePlayQueueRequest) string {
+	if len(req.Items) > maxPlayQueueItems {
+		return "a play queue can have at most " + strconv.Itoa(maxPlayQueueItems) + " items"
+	}
+	for i, item := range req.Items {
+		if !slices.Contains(queueItemTypes, item.PlayableType) {
+			return "items[" + strconv.Itoa(i) + "].playable_type must be track or video"
+		}
+		if item.PlayableID == "" {
+			return "items[" + strconv.Itoa(i) + "].playable_id is required"
+		}
+	}
+	if req.CurrentIndex < 0 || (req.CurrentIndex >= len(req.Items) && req.CurrentIndex != 0) {
+		return "current_index is out of range"
+	}
+	if req.PositionMS < 0 {
+		return "position_ms must not be negative"
+	}
+	if !slices.Contains(queueRepeatModes, req.Repeat) {
+		return "repeat must be off, all or one"
+	}
+	if req.Context.Type != "" && !slices.Contains(queueContextTypes, req.Context.Type) {
+		return "context.type must be album, artist, playlist, track or video"
+	}
+	if (req.Context.Type == "") != (req.Context.ID == "") {
+		return "context.type and context.id must be given together"
+	}
+	return ""
+}
@@ -0,0 +1,247 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/server/routes"
+)
+
+// racingDatabase stores the queue of another device right before the first queue is saved after retrieving it.
+type racingDatabase struct {
+	db.Database
+
+	other media.PlayQueue
+	raced bool
+}
+
+func (d *racingDatabase) SavePlayQueueAtRevision(ctx context.Context, queue media.PlayQueue, revision int) error {
+	if !d.raced {
+		d.raced = true
+		if err := d.Database.SetPlayQueue(ctx, d.other); err != nil {
+			return err
+		}
+	}
+	return d.Database.SavePlayQueueAtRevision(ctx, queue, revision)
+}
+
+// getQueue gets the user's play queue and the ETag it's served with.
+func getQueue(t *testing.T, userID string) (media.PlayQueue, string) {
+	t.Helper()
+
+	req := newRequest(t, http.MethodGet, "/api/v1/me/queue", nil)
+	rec := serve(t, "/api/v1/me/queue", routes.V1PlayQueue, req, userID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("getting the queue got %d %s", rec.Code, rec.Body)
+	}
+	var queue media.PlayQueue
+	if err := json.Unmarshal(rec.Body.Bytes(), &queue); err != nil {
+		t.Fatal(err)
+	}
+	return queue, rec.Header().Get("ETag")
+}
+
+// putQueue replaces the user's play queue, sending ifMatch in the If-Match header unless it's empty.
+func putQueue(t *testing.T, queue echo.Map, ifMatch, userID string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := newRequest(t, http.MethodPut, "/api/v1/me/queue", queue)
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	return serve(t, "/api/v1/me/queue", routes.V1ReplacePlayQueue, req, userID)
+}
+
+// conflictingQueue decodes the queue a conflict response includes.
+func conflictingQueue(t *testing.T, rec *httptest.ResponseRecorder) media.PlayQueue {
+	t.Helper()
+
+	var res struct {
+		Queue media.PlayQueue `json:"queue"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res.Queue
+}
+
+func TestPlayQueue(t *testing.T) {
+	setup(t, "user0000001", "user0000002")
+
+	queue, etag := getQueue(t, "user0000001")
+	if queue.Revision != 0 || len(queue.Items) != 0 || queue.Repeat != media.RepeatOff || etag != `"0"` {
+		t.Errorf("queue that was never stored is %+v with ETag %s", queue, etag)
+	}
+
+	body := echo.Map{
+		"items": []echo.Map{
+			{"playable_type": "track", "playable_id": "track000001"},
+			{"playable_type": "video", "playable_id": "video000001"},
+		},
+		"current_index": 1,
+		"position_ms":   73500,
+		"shuffle":       true,
+		"repeat":        media.RepeatAll,
+		"context":       echo.Map{"type": "album", "id": "album000001"},
+		"device":        "Phone",
+	}
+	rec := putQueue(t, body, etag, "user0000001")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("storing the queue got %d %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("ETag"); got != `"1"` {
+		t.Errorf("stored queue has ETag %s, want \"1\"", got)
+	}
+
+	queue, etag = getQueue(t, "user0000001")
+	want := media.PlayQueue{
+		UserID: "user0000001",
+		Items: []media.QueueItem{
+			{PlayableType: "track", PlayableID: "track000001"},
+			{PlayableType: "video", PlayableID: "video000001"},
+		},
+		CurrentIndex: 1,
+		PositionMS:   73500,
+		Shuffle:      true,
+		Repeat:       media.RepeatAll,
+		Context:      media.QueueContext{Type: "album", ID: "album000001"},
+		Device:       "Phone",
+		Revision:     1,
+	}
+	if queue.UpdatedAt == 0 {
+		t.Error("stored queue has no update time")
+	}
+	queue.UpdatedAt = 0
+	if etag != `"1"` || !reflect.DeepEqual(queue, want) {
+		t.Errorf("stored queue is %+v with ETag %s, want %+v", queue, etag, want)
+	}
+
+	// Queues are kept per user.
+	if other, _ := getQueue(t, "user0000002"); other.Revision != 0 || len(other.Items) != 0 {
+		t.Errorf("other user's queue is %+v", other)
+	}
+
+	// Without If-Match the queue is replaced whatever its revision.
+	rec = putQueue(t, echo.Map{"device": "Laptop"}, "", "user0000001")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("replacing the queue got %d %s", rec.Code, rec.Body)
+	}
+	if queue, etag = getQueue(t, "user0000001"); etag != `"2"` || len(queue.Items) != 0 || queue.Device != "Laptop" {
+		t.Errorf("replaced queue is %+v with ETag %s", queue, etag)
+	}
+}
+
+func TestPlayQueueErrors(t *testing.T) {
+	setup(t, "user0000001")
+
+	if rec := putQueue(t, echo.Map{"device": "Phone"}, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated replace got %d, want 401", rec.Code)
+	}
+	req := newRequest(t, http.MethodGet, "/api/v1/me/queue", nil)
+	if rec := serve(t, "/api/v1/me/queue", routes.V1PlayQueue, req, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated get got %d, want 401", rec.Code)
+	}
+
+	track := echo.Map{"playable_type": "track", "playable_id": "track000001"}
+	tests := []struct {
+		name  string
+		queue echo.Map
+	}{
+		{"album item", echo.Map{"items": []echo.Map{{"playable_type": "album", "playable_id": "album000001"}}}},
+		{"item without ID", echo.Map{"items": []echo.Map{{"playable_type": "track"}}}},
+		{"negative current index", echo.Map{"items": []echo.Map{track}, "current_index": -1}},
+		{"current index past the end", echo.Map{"items": []echo.Map{track}, "current_index": 1}},
+		{"negative position", echo.Map{"items": []echo.Map{track}, "position_ms": -1}},
+		{"unknown repeat mode", echo.Map{"repeat": "forever"}},
+		{"unknown context type", echo.Map{"context": echo.Map{"type": "user", "id": "user0000001"}}},
+		{"context without ID", echo.Map{"context": echo.Map{"type": "album"}}},
+		{"context without type", echo.Map{"context": echo.Map{"id": "album000001"}}},
+	}
+	for _, test := range tests {
+		if rec := putQueue(t, test.queue, "", "user0000001"); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d %s, want 400", test.name, rec.Code, rec.Body)
+		}
+	}
+	if queue, _ := getQueue(t, "user0000001"); queue.Revision != 0 {
+		t.Errorf("invalid queues were stored as %+v", queue)
+	}
+}
+
+func TestPlayQueueIfMatch(t *testing.T) {
+	setup(t, "user0000001")
+
+	if rec := putQueue(t, echo.Map{"device": "Phone"}, `"0"`, "user0000001"); rec.Code != http.StatusOK {
+		t.Fatalf("storing the first queue got %d %s", rec.Code, rec.Body)
+	}
+	if rec := putQueue(t, echo.Map{"device": "Laptop"}, `"1"`, "user0000001"); rec.Code != http.StatusOK {
+		t.Fatalf("storing the queue at the current revision got %d %s", rec.Code, rec.Body)
+	}
+
+	// A device that last saw revision 1 is told about the queue stored since.
+	rec := putQueue(t, echo.Map{"device": "Phone"}, `"1"`, "user0000001")
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("storing at a stale revision got %d, want 412", rec.Code)
+	}
+	if got := rec.Header().Get("ETag"); got != `"2"` {
+		t.Errorf("failed precondition returned ETag %s, want \"2\"", got)
+	}
+	if queue := conflictingQueue(t, rec); queue.Device != "Laptop" || queue.Revision != 2 {
+		t.Errorf("failed precondition returned queue %+v", queue)
+	}
+	if queue, _ := getQueue(t, "user0000001"); queue.Device != "Laptop" {
+		t.Errorf("stale queue replaced the queue with %+v", queue)
+	}
+}
+
+func TestPlayQueueRevisionMismatch(t *testing.T) {
+	for _, test := range []struct {
+		name, ifMatch string
+		want          int
+	}{
+		{"with If-Match", `"1"`, http.StatusPreconditionFailed},
+		{"without If-Match", "", http.StatusConflict},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setup(t, "user0000001")
+			if rec := putQueue(t, echo.Map{"device": "Phone"}, "", "user0000001"); rec.Code != http.StatusOK {
+				t.Fatalf("storing the first queue got %d %s", rec.Code, rec.Body)
+			}
+
+			// Another device stores its queue after the queue was retrieved for the request, but before it's saved.
+			other := media.PlayQueue{
+				UserID:   "user0000001",
+				Items:    []media.QueueItem{},
+				Repeat:   media.RepeatOff,
+				Device:   "Laptop",
+				Revision: 2,
+			}
+			racing := &racingDatabase{Database: db.DB, other: other}
+			db.DB = racing
+
+			rec := putQueue(t, echo.Map{"device": "Tablet"}, test.ifMatch, "user0000001")
+			if !racing.raced {
+				t.Fatal("the queue was never saved")
+			}
+			if rec.Code != test.want {
+				t.Errorf("got %d %s, want %d", rec.Code, rec.Body, test.want)
+			}
+			if got := rec.Header().Get("ETag"); got != `"2"` {
+				t.Errorf("conflict returned ETag %s, want \"2\"", got)
+			}
+			if queue := conflictingQueue(t, rec); queue.Device != "Laptop" || queue.Revision != 2 {
+				t.Errorf("conflict returned queue %+v", queue)
+			}
+			if queue, _ := getQueue(t, "user0000001"); queue.Device != "Laptop" {
+				t.Errorf("conflicting queue replaced the other device's with %+v", queue)
+			}
+		})
+	}
+}
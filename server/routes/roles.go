@@ -0,0 +1,100 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/server/routes/auth"
+)
+
+type permissionsResponse struct {
+	Role        string                 `json:"role"        example:"member"`
+	Permissions media.AdminPermissions `json:"permissions"`
+}
+
+type setRoleRequest struct {
+	// One of owner, admin, member or guest.
+	Role string `json:"role" example:"admin"`
+}
+
+// @Summary	Get the user's role and permissions
+// @Description	Returns the authenticated user's role and what it allows them to do.
+// @ID			getPermissions
+// @Success	200	{object}	permissionsResponse
+// @Failure	401	{object}	any
+// @Failure	500	{object}	any
+// @Router		/me/permissions [get]
+func V1Permissions(c echo.Context) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	user, err := db.DB.User(c.Request().Context(), userID)
+	if err != nil {
+		log.Error("Error getting user", "err", err, "userID", userID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve permissions"})
+	}
+
+	role := user.Role()
+	return c.JSON(http.StatusOK, permissionsResponse{Role: role, Permissions: auth.RolePermissions(role)})
+}
+
+// @Summary	Change a user's role
+// @Description	Requires the manage_users permission. Only the owner can make users admins,
+// @Description	and other users can only change the roles of users whose role is lower than theirs.
+// @Description	Making a user the owner makes the previous owner an admin.
+// @ID			setUserRole
+// @Accept		json
+// @Param		id		path		string			true	"User ID"
+// @Param		body	body		setRoleRequest	true	"The new role"
+// @Success	200		{object}	permissionsResponse
+// @Failure	400		{object}	any
+// @Failure	401		{object}	any
+// @Failure	403		{object}	any
+// @Failure	404		{object}	any
+// @Failure	500		{object}	any
+// @Router		/users/{id}/role [put]
+func V1SetUserRole(c echo.Context) error {
+	actorID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	var req setRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	actor, err := db.DB.User(ctx, actorID)
+	if err != nil {
+		log.Error("Error getting user", "err", err, "userID", actorID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to change role"})
+	}
+	target, err := db.DB.User(ctx, c.Param("id"))
+	if errors.Is(err, db.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, echo.Map{"message": "user not found"})
+	} else if err != nil {
+		log.Error("Error getting user", "err", err, "userID", c.Param("id"))
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to change role"})
+	}
+
+	err = auth.ChangeRole(ctx, actor, target, req.Role)
+	switch {
+	case errors.Is(err, auth.ErrUnknownRole), errors.Is(err, auth.ErrOwnerRoleNeeded):
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
+	case errors.Is(err, auth.ErrRoleNotAllowed), errors.Is(err, auth.ErrUserOutranks):
+		return c.JSON(http.StatusForbidden, echo.Map{"message": err.Error()})
+	case err != nil:
+		log.Error("Error changing role", "err", err, "userID", target.ID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to change role"})
+	}
+
+	return c.JSON(http.StatusOK, permissionsResponse{Role: req.Role, Permissions: auth.RolePermissions(req.Role)})
+}
@@ -0,0 +1,201 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/scrobble"
+	"github.com/libramusic/libracore/server/routes/auth"
+)
+
+type scrobblerInfo struct {
+	ID      string `json:"id"      example:"listenbrainz"`
+	Name    string `json:"name"    example:"ListenBrainz"`
+	Enabled bool   `json:"enabled" example:"true"`
+	Linked  bool   `json:"linked"  example:"false"`
+}
+
+type linkScrobblerRequest struct {
+	// The user's token for the scrobbler. For Last.fm, this is the session key.
+	Token string `json:"token" example:"b1f2c3d4-e5f6-4789-abcd-ef0123456789"`
+}
+
+// @Summary	List the external scrobblers listens can be forwarded to
+// @ID			getScrobblers
+// @Success	200	{array}		scrobblerInfo
+// @Failure	401	{object}	any
+// @Failure	500	{object}	any
+// @Router		/scrobblers [get]
+func V1Scrobblers(c echo.Context) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	links, err := db.DB.UserScrobblerLinks(c.Request().Context(), userID)
+	if err != nil {
+		log.Error("Error getting scrobbler links", "err", err, "userID", userID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve scrobblers"})
+	}
+
+	scrobblers := make([]scrobblerInfo, 0, len(scrobble.Registry))
+	for id, scrobbler := range scrobble.Registry {
+		scrobblers = append(scrobblers, scrobblerInfo{
+			ID:      id,
+			Name:    scrobbler.Name(),
+			Enabled: scrobbler.Enabled(),
+			Linked: slices.ContainsFunc(links, func(link db.ScrobblerLink) bool {
+				return link.Scrobbler == id && link.Token != ""
+			}),
+		})
+	}
+	slices.SortFunc(scrobblers, func(a, b scrobblerInfo) int { return strings.Compare(a.ID, b.ID) })
+
+	return c.JSON(http.StatusOK, scrobblers)
+}
+
+// @Summary	Link an external scrobbler
+// @Description	Listens recorded after linking are forwarded to the scrobbler.
+// @ID			linkScrobbler
+// @Accept		json
+// @Param		id		path	string					true	"Scrobbler ID"
+// @Param		token	body	linkScrobblerRequest	true	"Token"
+// @Success	204
+// @Failure	400	{object}	any
+// @Failure	401	{object}	any
+// @Failure	404	{object}	any
+// @Failure	500	{object}	any
+// @Router		/scrobblers/{id} [put]
+func V1LinkScrobbler(c echo.Context) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	scrobbler, ok := scrobble.Registry[c.Param("id")]
+	if !ok {
+		return c.JSON(http.StatusNotFound, echo.Map{"message": "scrobbler not found"})
+	}
+	if !scrobbler.Enabled() {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": "scrobbler is not enabled on this server"})
+	}
+
+	var req linkScrobblerRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
+	}
+	if req.Token == "" {
+		return c.JSON(http.StatusBadRequest, echo.Map{"message": "token is required"})
+	}
+
+	if err := setLinkedScrobbler(c, userID, scrobbler.ID(), req.Token); err != nil {
+		log.Error("Error linking scrobbler", "err", err, "userID", userID, "scrobbler", scrobbler.ID())
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to link scrobbler"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary	Unlink an external scrobbler
+// @Description	Listens that are still queued for the scrobbler are discarded.
+// @ID			unlinkScrobbler
+// @Param		id	path	string	true	"Scrobbler ID"
+// @Success	204
+// @Failure	401	{object}	any
+// @Failure	404	{object}	any
+// @Failure	500	{object}	any
+// @Router		/scrobblers/{id} [delete]
+func V1UnlinkScrobbler(c echo.Context) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	id := c.Param("id")
+	if _, ok := scrobble.Registry[id]; !ok {
+		return c.JSON(http.StatusNotFound, echo.Map{"message": "scrobbler not found"})
+	}
+
+	if err := setLinkedScrobbler(c, userID, id, ""); err != nil {
+		log.Error("Error unlinking scrobbler", "err", err, "userID", userID, "scrobbler", id)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to unlink scrobbler"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary	List listens that could not be forwarded
+// @ID			getFailedScrobbles
+// @Success	200	{array}		db.Scrobble
+// @Failure	401	{object}	any
+// @Failure	500	{object}	any
+// @Router		/scrobblers/failed [get]
+func V1FailedScrobbles(c echo.Context) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	scrobbles, err := db.DB.FailedScrobbles(c.Request().Context(), userID)
+	if err != nil {
+		log.Error("Error getting failed scrobbles", "err", err, "userID", userID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve failed scrobbles"})
+	}
+	if scrobbles == nil {
+		scrobbles = []db.Scrobble{}
+	}
+	return c.JSON(http.StatusOK, scrobbles)
+}
+
+// @Summary	Retry forwarding a listen that could not be forwarded
+// @ID			retryFailedScrobble
+// @Param		id	path	string	true	"Scrobble ID"
+// @Success	202
+// @Failure	401	{object}	any
+// @Failure	404	{object}	any
+// @Failure	500	{object}	any
+// @Router		/scrobblers/failed/{id}/retry [post]
+func V1RetryFailedScrobble(c echo.Context) error {
+	userID, ok := auth.UserIDFromContext(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "authentication required"})
+	}
+
+	ctx := c.Request().Context()
+
+	failed, err := db.DB.Scrobble(ctx, c.Param("id"))
+	if errors.Is(err, db.ErrNotFound) || (err == nil && (failed.UserID != userID || !failed.Failed)) {
+		return c.JSON(http.StatusNotFound, echo.Map{"message": "failed scrobble not found"})
+	}
+	if err != nil {
+		log.Error("Error getting scrobble", "err", err, "scrobbleID", c.Param("id"))
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retry scrobble"})
+	}
+
+	if err := scrobble.Retry(ctx, failed); err != nil {
+		log.Error("Error retrying scrobble", "err", err, "scrobbleID", failed.ID)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retry scrobble"})
+	}
+	return c.NoContent(http.StatusAccepted)
+}
+
+// setLinkedScrobbler stores the user's token for a scrobbler. An empty token unlinks it.
+// Only the user's row for the scrobbler is written, so concurrent changes to the user aren't overwritten.
+func setLinkedScrobbler(c echo.Context, userID, scrobblerID, token string) error {
+	ctx := c.Request().Context()
+
+	if token == "" {
+		return db.DB.DeleteScrobblerLink(ctx, userID, scrobblerID)
+	}
+	return db.DB.SetScrobblerLink(ctx, db.ScrobblerLink{
+		UserID:       userID,
+		Scrobbler:    scrobblerID,
+		Token:        token,
+		CreationDate: time.Now().Unix(),
+	})
+}
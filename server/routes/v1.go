@@ -1,12 +1,14 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/charmbracelet/log"
 	"github.com/labstack/echo/v4"
 
 	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/server/routes/auth"
 	"github.com/libramusic/libracore/storage"
 )
 
@@ -21,11 +23,16 @@ import (
 func V1Playables(c echo.Context) error {
 	ctx := c.Request().Context()
 
-	playables, err := db.AllPlayables(ctx)
+	viewerID, _ := auth.UserIDFromContext(c)
+	playables, err := db.AllPlayables(ctx, viewerID)
 	if err != nil {
 		log.Error("Error getting playables", "err", err)
 		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve playables"})
 	}
+	if err := markFavorites(c, playables); err != nil {
+		log.Error("Error getting favorites", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve playables"})
+	}
 	return c.JSON(http.StatusOK, echo.Map{"playables": playables})
 }
 
@@ -34,17 +41,24 @@ func V1Playables(c echo.Context) error {
 // @Param		id	path	string	true	"User ID"
 // @Success	200	{array}	fakePlayable
 // @Success	200	"Returns a list of user's playables"
+// @Failure	401	{object}	any
+// @Failure	403	{object}	any
 // @Failure	500	{object}	any
 // @Router		/playables/{id} [get]
 func V1UserPlayables(c echo.Context) error {
 	ctx := c.Request().Context()
 
 	userID := c.Param("id")
-	playables, err := db.Playables(ctx, userID)
+	viewerID, _ := auth.UserIDFromContext(c)
+	playables, err := db.Playables(ctx, userID, viewerID)
 	if err != nil {
 		log.Error("Error getting playables", "err", err)
 		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve playables"})
 	}
+	if err := markFavorites(c, playables); err != nil {
+		log.Error("Error getting favorites", "err", err)
+		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve playables"})
+	}
 	return c.JSON(http.StatusOK, echo.Map{"playables": playables})
 }
 
@@ -62,16 +76,15 @@ func V1Search(c echo.Context) error {
 
 // START TO REFACTOR
 
+// @Summary	Get a track
+// @ID			getTrack
+// @Param		id	path		string	true	"Track ID"
+// @Success	200	{object}	media.Track
+// @Failure	404	{object}	any
+// @Failure	500	{object}	any
+// @Router		/track/{id} [get]
 func V1Track(c echo.Context) error {
-	ctx := c.Request().Context()
-
-	trackID := c.Param("id")
-	track, err := db.DB.Track(ctx, trackID)
-	if err != nil {
-		log.Error("Error getting track", "err", err, "trackID", trackID)
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve track"})
-	}
-	return c.JSON(http.StatusOK, track)
+	return readPlayable(c, trackResource)
 }
 
 func V1TrackIsStored(c echo.Context) error {
@@ -89,11 +102,21 @@ func V1TrackCover(c echo.Context) error {
 	return c.JSON(http.StatusOK, echo.Map{})
 }
 
+// @Summary	Get a track's lyrics in every language
+// @ID			getTrackLyrics
+// @Param		id	path		string	true	"Track ID"
+// @Success	200	{object}	map[string]string
+// @Failure	404	{object}	any
+// @Failure	500	{object}	any
+// @Router		/track/{id}/lyrics [get]
 func V1TrackLyrics(c echo.Context) error {
 	ctx := c.Request().Context()
 
 	trackID := c.Param("id")
 	track, err := db.DB.Track(ctx, trackID)
+	if errors.Is(err, db.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, echo.Map{"message": "track not found"})
+	}
 	if err != nil {
 		log.Error("Error getting track", "err", err, "trackID", trackID)
 		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve track"})
@@ -101,11 +124,23 @@ func V1TrackLyrics(c echo.Context) error {
 	return c.JSON(http.StatusOK, track.Lyrics)
 }
 
+// @Summary	Get a track's lyrics in one language
+// @ID			getTrackLyricsLang
+// @Produce	plain
+// @Param		id		path		string	true	"Track ID"
+// @Param		lang	path		string	true	"Language"
+// @Success	200		{string}	string
+// @Failure	404		{object}	any
+// @Failure	500		{object}	any
+// @Router		/track/{id}/lyrics/{lang} [get]
 func V1TrackLyricsLang(c echo.Context) error {
 	ctx := c.Request().Context()
 
 	trackID := c.Param("id")
 	track, err := db.DB.Track(ctx, trackID)
+	if errors.Is(err, db.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, echo.Map{"message": "track not found"})
+	}
 	if err != nil {
 		log.Error("Error getting track", "err", err, "trackID", trackID)
 		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve track"})
@@ -118,9 +153,15 @@ func V1TrackLyricsLang(c echo.Context) error {
 	return c.NoContent(http.StatusNotFound)
 }
 
+// @Summary	Get a album
+// @ID			getAlbum
+// @Param		id	path		string	true	"Album ID"
+// @Success	200	{object}	media.Album
+// @Failure	404	{object}	any
+// @Failure	500	{object}	any
+// @Router		/album/{id} [get]
 func V1Album(c echo.Context) error {
-	log.Error("unimplemented")
-	return c.JSON(http.StatusOK, echo.Map{})
+	return readPlayable(c, albumResource)
 }
 
 func V1AlbumCover(c echo.Context) error {
@@ -133,16 +174,15 @@ func V1AlbumTracks(c echo.Context) error {
 	return c.JSON(http.StatusOK, echo.Map{})
 }
 
+// @Summary	Get a video
+// @ID			getVideo
+// @Param		id	path		string	true	"Video ID"
+// @Success	200	{object}	media.Video
+// @Failure	404	{object}	any
+// @Failure	500	{object}	any
+// @Router		/video/{id} [get]
 func V1Video(c echo.Context) error {
-	ctx := c.Request().Context()
-
-	videoID := c.Param("id")
-	video, err := db.DB.Video(ctx, videoID)
-	if err != nil {
-		log.Error("Error getting video", "err", err, "videoID", videoID)
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve video"})
-	}
-	return c.JSON(http.StatusOK, video)
+	return readPlayable(c, videoResource)
 }
 
 func V1VideoIsStored(c echo.Context) error {
@@ -160,11 +200,21 @@ func V1VideoCover(c echo.Context) error {
 	return c.JSON(http.StatusOK, echo.Map{})
 }
 
+// @Summary	Get a video's subtitles in every language
+// @ID			getVideoSubtitles
+// @Param		id	path		string	true	"Video ID"
+// @Success	200	{object}	map[string]string
+// @Failure	404	{object}	any
+// @Failure	500	{object}	any
+// @Router		/video/{id}/subtitles [get]
 func V1VideoSubtitles(c echo.Context) error {
 	ctx := c.Request().Context()
 
 	videoID := c.Param("id")
 	video, err := db.DB.Video(ctx, videoID)
+	if errors.Is(err, db.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, echo.Map{"message": "video not found"})
+	}
 	if err != nil {
 		log.Error("Error getting video", "err", err, "videoID", videoID)
 		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve video"})
@@ -172,11 +222,23 @@ func V1VideoSubtitles(c echo.Context) error {
 	return c.JSON(http.StatusOK, video.Subtitles)
 }
 
+// @Summary	Get a video's subtitles in one language
+// @ID			getVideoSubtitlesLang
+// @Produce	plain
+// @Param		id		path		string	true	"Video ID"
+// @Param		lang	path		string	true	"Language"
+// @Success	200		{string}	string
+// @Failure	404		{object}	any
+// @Failure	500		{object}	any
+// @Router		/video/{id}/subtitles/{lang} [get]
 func V1VideoSubtitlesLang(c echo.Context) error {
 	ctx := c.Request().Context()
 
 	videoID := c.Param("id")
 	video, err := db.DB.Video(ctx, videoID)
+	if errors.Is(err, db.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, echo.Map{"message": "video not found"})
+	}
 	if err != nil {
 		log.Error("Error getting video", "err", err, "videoID", videoID)
 		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to retrieve video"})
@@ -188,9 +250,16 @@ func V1VideoSubtitlesLang(c echo.Context) error {
 	return c.NoContent(http.StatusNotFound)
 }
 
+// @Summary	Get a playlist
+// @Description	Private playlists are only found by their owner and the users they're shared with.
+// @ID			getPlaylist
+// @Param		id	path		string	true	"Playlist ID"
+// @Success	200	{object}	media.Playlist
+// @Failure	404	{object}	any
+// @Failure	500	{object}	any
+// @Router		/playlist/{id} [get]
 func V1Playlist(c echo.Context) error {
-	log.Error("unimplemented")
-	return c.JSON(http.StatusOK, echo.Map{})
+	return readPlayable(c, playlistResource)
 }
 
 func V1PlaylistCover(c echo.Context) error {
@@ -198,14 +267,15 @@ func V1PlaylistCover(c echo.Context) error {
 	return c.JSON(http.StatusOK, echo.Map{})
 }
 
-func V1PlaylistTracks(c echo.Context) error {
-	log.Error("unimplemented")
-	return c.JSON(http.StatusOK, echo.Map{})
-}
-
+// @Summary	Get a artist
+// @ID			getArtist
+// @Param		id	path		string	true	"Artist ID"
+// @Success	200	{object}	media.Artist
+// @Failure	404	{object}	any
+// @Failure	500	{object}	any
+// @Router		/artist/{id} [get]
 func V1Artist(c echo.Context) error {
-	log.Error("unimplemented")
-	return c.JSON(http.StatusOK, echo.Map{})
+	return readPlayable(c, artistResource)
 }
 
 func V1ArtistCover(c echo.Context) error {
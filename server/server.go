@@ -13,12 +13,14 @@ import (
 	"github.com/libramusic/libracore"
 	"github.com/libramusic/libracore/config"
 	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/events"
+	"github.com/libramusic/libracore/media"
 	"github.com/libramusic/libracore/server/middleware"
 	"github.com/libramusic/libracore/server/routes"
 	"github.com/libramusic/libracore/server/routes/auth"
 )
 
-//go:generate go tool swag init -g server.go -d ./,./routes/,../media/ -o . --ot go -v3.1
+//go:generate go tool swag init -g server.go -d ./,./routes/,../media/,../db/,../playlistfile/,../events/ -o . --ot go -v3.1
 
 //	@title			Libra API
 //	@version		0.1.0-DEV
@@ -56,6 +58,9 @@ func New() *echo.Echo {
 	e := echo.New()
 	e.HideBanner = true
 	e.JSONSerializer = &GoJSONSerializer{}
+	e.IPExtractor = clientIPExtractor()
+	// Event streams never end on their own, so they would hold up shutting down.
+	e.Server.RegisterOnShutdown(events.CloseAll)
 
 	e.Use(echoprometheus.NewMiddleware("libra"))
 
@@ -87,59 +92,152 @@ func New() *echo.Echo {
 
 	e.GET("/metrics", echoprometheus.NewHandler())
 
+	// ListenBrainz-compatible API for scrobbling clients, which expect it at the root of the server.
+	listenBrainzGroup := e.Group("/1")
+	listenBrainzGroup.GET("/validate-token", routes.ListenBrainzValidateToken)
+	listenBrainzGroup.POST("/submit-listens", routes.ListenBrainzSubmitListens)
+	listenBrainzGroup.GET("/user/:name/listens", routes.ListenBrainzUserListens)
+
 	apiGroup := e.Group("/api")
 
-	authGroup := apiGroup.Group("/auth")
+	// Personal access tokens can only manage the account with the admin scope.
+	authGroup := apiGroup.Group("/auth", middleware.Scope(auth.ScopeAdmin))
 	authGroup.POST("/register", auth.Register)
 	authGroup.POST("/login", auth.Login)
+	authGroup.POST("/refresh", auth.Refresh)
+	authGroup.POST("/login/mfa", auth.LoginMFA)
 	authGroup.POST("/login/:provider", auth.LoginProvider)
 	authGroup.POST("/logout", auth.Logout, middleware.JWTProtected)
+	authGroup.GET("/sessions", auth.Sessions, middleware.JWTProtected)
+	authGroup.DELETE("/sessions", auth.RevokeSessions, middleware.JWTProtected)
+	authGroup.DELETE("/sessions/:id", auth.RevokeSession, middleware.JWTProtected)
+	authGroup.GET("/2fa", auth.TwoFactorStatus, middleware.JWTProtected)
+	authGroup.POST("/2fa/totp", auth.EnrollTOTP, middleware.JWTProtected)
+	authGroup.POST("/2fa/totp/confirm", auth.ConfirmTOTP, middleware.JWTProtected)
+	authGroup.DELETE("/2fa/totp", auth.DisableTOTP, middleware.JWTProtected)
+	authGroup.POST("/2fa/recovery-codes", auth.RegenerateRecoveryCodes, middleware.JWTProtected)
+	authGroup.POST("/webauthn/register/begin", auth.BeginWebAuthnRegistration, middleware.JWTProtected)
+	authGroup.POST("/webauthn/register/finish", auth.FinishWebAuthnRegistration, middleware.JWTProtected)
+	authGroup.GET("/webauthn/credentials", auth.WebAuthnCredentials, middleware.JWTProtected)
+	authGroup.DELETE("/webauthn/credentials/:id", auth.DeleteWebAuthnCredential, middleware.JWTProtected)
+	authGroup.POST("/webauthn/login/begin", auth.BeginWebAuthnLogin)
+	authGroup.POST("/webauthn/login/finish", auth.FinishWebAuthnLogin)
+	authGroup.GET("/tokens", auth.AccessTokens, middleware.JWTProtected)
+	authGroup.POST("/tokens", auth.CreateAccessToken, middleware.JWTProtected)
+	authGroup.DELETE("/tokens/:id", auth.RevokeAccessToken, middleware.JWTProtected)
+	authGroup.GET("/email", auth.EmailStatus, middleware.JWTProtected)
+	authGroup.POST("/email", auth.ChangeEmail, middleware.JWTProtected)
+	authGroup.POST("/email/confirm", auth.ConfirmEmailChange)
+	authGroup.POST("/email/verify", auth.VerifyEmail)
+	authGroup.POST("/email/verify/send", auth.SendVerificationEmail, middleware.JWTProtected)
+	authGroup.POST("/password/forgot", auth.ForgotPassword)
+	authGroup.POST("/password/reset", auth.ResetPassword)
 	authGroup.GET("/connect/:provider", auth.ConnectProvider, middleware.JWTProtected)
 	authGroup.GET("/callback/:provider", auth.ProviderCallback)
+	authGroup.POST("/exchange", auth.ExchangeLoginCode)
 	authGroup.POST("/disconnect/:provider", auth.DisconnectProvider, middleware.JWTProtected)
 
 	v1Group := apiGroup.Group("/v1")
 
+	// Personal access tokens need the scope of the group a route is in.
+	readGroup := v1Group.Group("", middleware.Scope(auth.ScopeLibraryRead))
+	writeGroup := v1Group.Group("", middleware.Scope(auth.ScopeLibraryWrite))
+	streamGroup := v1Group.Group("", middleware.Scope(auth.ScopeStream))
+	adminGroup := v1Group.Group("", middleware.Scope(auth.ScopeAdmin))
+
 	openapidocs.ElementsDocuments(e, "/api/v1/docs", openapidocs.ElementsConfig{
 		SpecUrl: "/api/v1/openapi.json",
 		Title:   "Libra API",
 	})
 
-	v1Group.GET("/playables", routes.V1Playables)
-	routes.CreateFeedRoutes(v1Group, "/playables", "{} feed for all playables")
-	v1Group.GET("/playables/:id", routes.V1UserPlayables)
-	routes.CreateFeedRoutes(v1Group, "/playables/:id", "{} feed for user's playables")
-	v1Group.GET("/search", routes.V1Search, middleware.GlobalJWTProtected)
+	readGroup.GET("/playables", routes.V1Playables, middleware.JWTOptional)
+	routes.CreateFeedRoutes(readGroup, "/playables", "{} feed for all playables")
+	readGroup.GET("/playables/:id", routes.V1UserPlayables, middleware.UserJWTProtected)
+	routes.CreateFeedRoutes(readGroup, "/playables/:id", "{} feed for user's playables", middleware.UserJWTProtected)
+	readGroup.GET("/search", routes.V1Search, middleware.GlobalJWTProtected)
+
+	writeGroup.POST("/listens", routes.V1SubmitListen, middleware.JWTProtected)
+	readGroup.GET("/users/:id/history", routes.V1UserHistory, middleware.UserJWTProtected)
+	readGroup.GET("/users/:id/now_playing", routes.V1UserNowPlaying, middleware.UserJWTProtected)
+	readGroup.GET("/users/:id/favorites", routes.V1UserFavorites, middleware.UserJWTProtected)
+	writeGroup.PUT("/:type/:id/favorite", routes.V1AddFavorite, middleware.JWTProtected)
+	writeGroup.DELETE("/:type/:id/favorite", routes.V1RemoveFavorite, middleware.JWTProtected)
+	readGroup.GET("/listenbrainz/token", routes.V1ListenBrainzToken, middleware.JWTProtected)
+	writeGroup.POST("/listenbrainz/token", routes.V1ResetListenBrainzToken, middleware.JWTProtected)
+
+	readGroup.GET("/events", routes.V1Events, middleware.StreamJWTProtected)
+	readGroup.POST("/events/ticket", routes.V1EventsTicket, middleware.JWTProtected)
+	readGroup.GET("/me/queue", routes.V1PlayQueue, middleware.JWTProtected)
+	writeGroup.PUT("/me/queue", routes.V1ReplacePlayQueue, middleware.JWTProtected)
+	readGroup.GET("/me/permissions", routes.V1Permissions, middleware.JWTProtected)
+	adminGroup.PUT("/users/:id/role", routes.V1SetUserRole, middleware.JWTProtected,
+		middleware.RequirePermission(media.PermissionManageUsers))
+	adminGroup.GET("/audit-log", routes.V1AuditLog, middleware.JWTProtected,
+		middleware.RequirePermission(media.PermissionManageUsers))
+
+	readGroup.GET("/scrobblers", routes.V1Scrobblers, middleware.JWTProtected)
+	writeGroup.PUT("/scrobblers/:id", routes.V1LinkScrobbler, middleware.JWTProtected)
+	writeGroup.DELETE("/scrobblers/:id", routes.V1UnlinkScrobbler, middleware.JWTProtected)
+	readGroup.GET("/scrobblers/failed", routes.V1FailedScrobbles, middleware.JWTProtected)
+	writeGroup.POST("/scrobblers/failed/:id/retry", routes.V1RetryFailedScrobble, middleware.JWTProtected)
+
+	upload := middleware.RequirePermission(media.PermissionUpload)
 
 	// START TO REFRACTOR
-	v1Group.GET("/track/:id", routes.V1Track, middleware.GlobalJWTProtected)
-	v1Group.GET("/track/:id/is_stored", routes.V1TrackIsStored, middleware.GlobalJWTProtected)
-	v1Group.GET("/track/:id/stream", routes.V1TrackStream, middleware.GlobalJWTProtected)
-	v1Group.GET("/track/:id/cover", routes.V1TrackCover, middleware.GlobalJWTProtected)
-	v1Group.GET("/track/:id/lyrics", routes.V1TrackLyrics, middleware.GlobalJWTProtected)
-	v1Group.GET("/track/:id/lyrics/:lang", routes.V1TrackLyricsLang, middleware.GlobalJWTProtected)
-
-	v1Group.GET("/album/:id", routes.V1Album, middleware.GlobalJWTProtected)
-	v1Group.GET("/album/:id/cover", routes.V1AlbumCover, middleware.GlobalJWTProtected)
-	v1Group.GET("/album/:id/tracks", routes.V1AlbumTracks, middleware.GlobalJWTProtected)
-
-	v1Group.GET("/video/:id", routes.V1Video, middleware.GlobalJWTProtected)
-	v1Group.GET("/video/:id/is_stored", routes.V1VideoIsStored, middleware.GlobalJWTProtected)
-	v1Group.GET("/video/:id/stream", routes.V1VideoStream, middleware.GlobalJWTProtected)
-	v1Group.GET("/video/:id/cover", routes.V1VideoCover, middleware.GlobalJWTProtected)
-	v1Group.GET("/video/:id/subtitles", routes.V1VideoSubtitles, middleware.GlobalJWTProtected)
-	v1Group.GET("/video/:id/subtitles/:lang", routes.V1VideoSubtitlesLang, middleware.GlobalJWTProtected)
-	v1Group.GET("/video/:id/lyrics", routes.V1VideoSubtitles, middleware.GlobalJWTProtected)
-	v1Group.GET("/video/:id/lyrics/:lang", routes.V1VideoSubtitlesLang, middleware.GlobalJWTProtected)
-
-	v1Group.GET("/playlist/:id", routes.V1Playlist, middleware.GlobalJWTProtected)
-	v1Group.GET("/playlist/:id/cover", routes.V1PlaylistCover, middleware.GlobalJWTProtected)
-	v1Group.GET("/playlist/:id/tracks", routes.V1PlaylistTracks, middleware.GlobalJWTProtected)
-
-	v1Group.GET("/artist/:id", routes.V1Artist, middleware.GlobalJWTProtected)
-	v1Group.GET("/artist/:id/cover", routes.V1ArtistCover, middleware.GlobalJWTProtected)
-	v1Group.GET("/artist/:id/albums", routes.V1ArtistAlbums, middleware.GlobalJWTProtected)
-	v1Group.GET("/artist/:id/tracks", routes.V1ArtistTracks, middleware.GlobalJWTProtected)
+	writeGroup.POST("/track", routes.V1CreateTrack, middleware.JWTProtected, upload)
+	readGroup.GET("/track/:id", routes.V1Track, middleware.GlobalJWTProtected)
+	writeGroup.PUT("/track/:id", routes.V1ReplaceTrack, middleware.JWTProtected)
+	writeGroup.PATCH("/track/:id", routes.V1UpdateTrack, middleware.JWTProtected)
+	writeGroup.DELETE("/track/:id", routes.V1DeleteTrack, middleware.JWTProtected)
+	readGroup.GET("/track/:id/is_stored", routes.V1TrackIsStored, middleware.GlobalJWTProtected)
+	streamGroup.GET("/track/:id/stream", routes.V1TrackStream, middleware.GlobalJWTProtected)
+	readGroup.GET("/track/:id/cover", routes.V1TrackCover, middleware.GlobalJWTProtected)
+	readGroup.GET("/track/:id/lyrics", routes.V1TrackLyrics, middleware.GlobalJWTProtected)
+	readGroup.GET("/track/:id/lyrics/:lang", routes.V1TrackLyricsLang, middleware.GlobalJWTProtected)
+
+	writeGroup.POST("/album", routes.V1CreateAlbum, middleware.JWTProtected, upload)
+	readGroup.GET("/album/:id", routes.V1Album, middleware.GlobalJWTProtected)
+	writeGroup.PUT("/album/:id", routes.V1ReplaceAlbum, middleware.JWTProtected)
+	writeGroup.PATCH("/album/:id", routes.V1UpdateAlbum, middleware.JWTProtected)
+	writeGroup.DELETE("/album/:id", routes.V1DeleteAlbum, middleware.JWTProtected)
+	readGroup.GET("/album/:id/cover", routes.V1AlbumCover, middleware.GlobalJWTProtected)
+	readGroup.GET("/album/:id/tracks", routes.V1AlbumTracks, middleware.GlobalJWTProtected)
+
+	writeGroup.POST("/video", routes.V1CreateVideo, middleware.JWTProtected, upload)
+	readGroup.GET("/video/:id", routes.V1Video, middleware.GlobalJWTProtected)
+	writeGroup.PUT("/video/:id", routes.V1ReplaceVideo, middleware.JWTProtected)
+	writeGroup.PATCH("/video/:id", routes.V1UpdateVideo, middleware.JWTProtected)
+	writeGroup.DELETE("/video/:id", routes.V1DeleteVideo, middleware.JWTProtected)
+	readGroup.GET("/video/:id/is_stored", routes.V1VideoIsStored, middleware.GlobalJWTProtected)
+	streamGroup.GET("/video/:id/stream", routes.V1VideoStream, middleware.GlobalJWTProtected)
+	readGroup.GET("/video/:id/cover", routes.V1VideoCover, middleware.GlobalJWTProtected)
+	readGroup.GET("/video/:id/subtitles", routes.V1VideoSubtitles, middleware.GlobalJWTProtected)
+	readGroup.GET("/video/:id/subtitles/:lang", routes.V1VideoSubtitlesLang, middleware.GlobalJWTProtected)
+	readGroup.GET("/video/:id/lyrics", routes.V1VideoSubtitles, middleware.GlobalJWTProtected)
+	readGroup.GET("/video/:id/lyrics/:lang", routes.V1VideoSubtitlesLang, middleware.GlobalJWTProtected)
+
+	writeGroup.POST("/playlist", routes.V1CreatePlaylist, middleware.JWTProtected)
+	writeGroup.POST("/playlists/import", routes.V1ImportPlaylist, middleware.JWTProtected)
+	readGroup.GET("/playlist/:id", routes.V1Playlist, middleware.GlobalJWTProtected)
+	writeGroup.PUT("/playlist/:id", routes.V1ReplacePlaylist, middleware.JWTProtected)
+	writeGroup.PATCH("/playlist/:id", routes.V1UpdatePlaylist, middleware.JWTProtected)
+	writeGroup.DELETE("/playlist/:id", routes.V1DeletePlaylist, middleware.JWTProtected)
+	readGroup.GET("/playlist/:id/cover", routes.V1PlaylistCover, middleware.GlobalJWTProtected)
+	readGroup.GET("/playlist/:id/export", routes.V1ExportPlaylist, middleware.GlobalJWTProtected)
+	readGroup.GET("/playlist/:id/tracks", routes.V1PlaylistTracks, middleware.GlobalJWTProtected)
+	writeGroup.POST("/playlist/:id/tracks", routes.V1AddPlaylistTracks, middleware.JWTProtected)
+	writeGroup.PUT("/playlist/:id/tracks", routes.V1ReplacePlaylistTracks, middleware.JWTProtected)
+	writeGroup.POST("/playlist/:id/tracks/move", routes.V1MovePlaylistTrack, middleware.JWTProtected)
+	writeGroup.DELETE("/playlist/:id/tracks/:position", routes.V1RemovePlaylistTrack, middleware.JWTProtected)
+
+	writeGroup.POST("/artist", routes.V1CreateArtist, middleware.JWTProtected, upload)
+	readGroup.GET("/artist/:id", routes.V1Artist, middleware.GlobalJWTProtected)
+	writeGroup.PUT("/artist/:id", routes.V1ReplaceArtist, middleware.JWTProtected)
+	writeGroup.PATCH("/artist/:id", routes.V1UpdateArtist, middleware.JWTProtected)
+	writeGroup.DELETE("/artist/:id", routes.V1DeleteArtist, middleware.JWTProtected)
+	readGroup.GET("/artist/:id/cover", routes.V1ArtistCover, middleware.GlobalJWTProtected)
+	readGroup.GET("/artist/:id/albums", routes.V1ArtistAlbums, middleware.GlobalJWTProtected)
+	readGroup.GET("/artist/:id/tracks", routes.V1ArtistTracks, middleware.GlobalJWTProtected)
 	// END TO REFRACTOR
 
 	v1Spec := OpenAPISpec()
@@ -158,3 +256,19 @@ func New() *echo.Echo {
 
 	return e
 }
+
+// clientIPExtractor returns how to find out the client's IP address. Anyone can send X-Forwarded-For,
+// so it's only read from requests coming from application.trusted_proxies.
+func clientIPExtractor() echo.IPExtractor {
+	// The trusted proxies were checked when loading the config.
+	ranges, _ := config.Conf.Application.TrustedProxyRanges()
+	if len(ranges) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, ipRange := range ranges {
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/libramusic/libracore/config"
+)
+
+func TestClientIPExtractor(t *testing.T) {
+	previous := config.Conf.Application.TrustedProxies
+	t.Cleanup(func() { config.Conf.Application.TrustedProxies = previous })
+
+	tests := []struct {
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   string
+		want           string
+	}{
+		// Without trusted proxies, X-Forwarded-For is ignored, even from private addresses.
+		{nil, "192.0.2.1:1234", "198.51.100.7", "192.0.2.1"},
+		{nil, "10.0.0.2:1234", "198.51.100.7", "10.0.0.2"},
+		// Only trusted proxies can forward the client's address.
+		{[]string{"10.0.0.0/8"}, "10.0.0.2:1234", "198.51.100.7", "198.51.100.7"},
+		{[]string{"10.0.0.0/8"}, "192.0.2.1:1234", "198.51.100.7", "192.0.2.1"},
+		{[]string{"10.0.0.2"}, "127.0.0.1:1234", "198.51.100.7", "127.0.0.1"},
+		// Addresses the client made up are skipped up to the first one a trusted proxy added.
+		{[]string{"10.0.0.0/8"}, "10.0.0.2:1234", "203.0.113.9, 198.51.100.7, 10.0.0.3", "198.51.100.7"},
+	}
+	for _, test := range tests {
+		config.Conf.Application.TrustedProxies = test.trustedProxies
+		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		req.RemoteAddr = test.remoteAddr
+		req.Header.Set("X-Forwarded-For", test.forwardedFor)
+
+		if got := clientIPExtractor()(req); got != test.want {
+			t.Errorf("%v from %s forwarding %q: got %s, want %s",
+				test.trustedProxies, test.remoteAddr, test.forwardedFor, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package smartplaylist
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/libramusic/libracore/config"
+	"github.com/libramusic/libracore/db"
+	"github.com/libramusic/libracore/events"
+	"github.com/libramusic/libracore/media"
+	"github.com/libramusic/libracore/rules"
+)
+
+// Evaluate returns the smart playlist with its tracks set to the tracks its rules currently select.
+// Tracks that were already in the playlist keep their entries.
+func Evaluate(ctx context.Context, playlist media.Playlist) (media.Playlist, error) {
+	query, err := rules.Parse(playlist.Rules)
+	if err != nil {
+		return playlist, err
+	}
+	trackIDs, err := db.DB.SmartPlaylistTrackIDs(ctx, playlist.UserID, query, config.Conf.SmartPlaylists.MaxTracks)
+	if err != nil {
+		return playlist, err
+	}
+	if trackIDs == nil {
+		trackIDs = []string{}
+	}
+
+	now := time.Now().Unix()
+	playlist.TrackIDs = trackIDs
+	playlist.Entries = media.ReconcileEntries(playlist.Entries, trackIDs, playlist.UserID, now)
+	playlist.EvaluatedAt = now
+	return playlist, nil
+}
+
+// Refresh re-evaluates a smart playlist and stores it. Its revision only changes if its tracks did.
+// If the playlist was changed since it was read, the stored playlist is left alone and returned unchanged.
+func Refresh(ctx context.Context, playlist media.Playlist) (media.Playlist, error) {
+	refreshed, err := Evaluate(ctx, playlist)
+	if err != nil {
+		return playlist, err
+	}
+	if !slices.Equal(refreshed.TrackIDs, playlist.TrackIDs) {
+		refreshed.Revision++
+	}
+
+	err = db.DB.UpdatePlaylistAtRevision(ctx, refreshed, playlist.Revision)
+	if errors.Is(err, db.ErrRevisionMismatch) {
+		return playlist, nil
+	}
+	if err != nil {
+		return playlist, err
+	}
+	if refreshed.Revision != playlist.Revision {
+		events.Publish(events.PlayableEvent(events.PlayableUpdated, refreshed, refreshed.CanView))
+	}
+	return refreshed, nil
+}
+
+// RefreshIfStale refreshes a smart playlist if it was evaluated longer ago than the configured staleness.
+// Other playlists are returned unchanged.
+func RefreshIfStale(ctx context.Context, playlist media.Playlist) (media.Playlist, error) {
+	if playlist.Kind != media.PlaylistKindSmart {
+		return playlist, nil
+	}
+	if time.Since(time.Unix(playlist.EvaluatedAt, 0)) < config.Conf.SmartPlaylists.StaleAfter {
+		return playlist, nil
+	}
+	return Refresh(ctx, playlist)
+}
+
+// Run refreshes every smart playlist every refresh interval until ctx is canceled.
+func Run(ctx context.Context) {
+	if config.Conf.SmartPlaylists.RefreshInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(max(config.Conf.SmartPlaylists.RefreshInterval, time.Minute))
+	defer ticker.Stop()
+
+	for {
+		if err := RefreshAll(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Error("Error refreshing smart playlists", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RefreshAll refreshes every smart playlist.
+// Playlists whose rules no longer parse are skipped.
+func RefreshAll(ctx context.Context) error {
+	playlists, err := db.DB.AllPlaylists(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, playlist := range playlists {
+		if playlist.Kind != media.PlaylistKindSmart {
+			continue
+		}
+		_, err := Refresh(ctx, playlist)
+		var parseErr *rules.ParseError
+		if errors.As(err, &parseErr) {
+			log.Warn("Skipping smart playlist with invalid rules", "err", err, "playlistID", playlist.ID)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
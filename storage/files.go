@@ -5,6 +5,9 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"time"
+
+	"github.com/libramusic/libracore/events"
 )
 
 func DownloadFile(url string) ([]byte, error) {
@@ -35,7 +38,15 @@ func DownloadFile(url string) ([]byte, error) {
 	return data, nil
 }
 
+// DownloadFileTo downloads the file at url to path, publishing download.progress events while it runs.
 func DownloadFileTo(url, path string) error {
+	progress := &downloadProgress{download: events.Download{URL: url, Total: -1}}
+	err := downloadFileTo(url, path, progress)
+	progress.finish(err)
+	return err
+}
+
+func downloadFileTo(url, path string, progress *downloadProgress) error {
 	out, err := os.Create(path)
 	if err != nil {
 		return err
@@ -52,8 +63,9 @@ func DownloadFileTo(url, path string) error {
 		resp.Body.Close()
 		return fmt.Errorf("bad status: %s", resp.Status)
 	}
+	progress.download.Total = resp.ContentLength
 
-	_, err = io.Copy(out, resp.Body)
+	_, err = io.Copy(out, io.TeeReader(resp.Body, progress))
 	if err != nil {
 		out.Close()
 		resp.Body.Close()
@@ -73,3 +85,29 @@ func DownloadFileTo(url, path string) error {
 
 	return nil
 }
+
+// How often a running download reports its progress.
+const downloadProgressInterval = 500 * time.Millisecond
+
+// downloadProgress counts the bytes written to it and publishes them as download.progress events.
+type downloadProgress struct {
+	download    events.Download
+	lastPublish time.Time
+}
+
+func (p *downloadProgress) Write(data []byte) (int, error) {
+	p.download.Received += int64(len(data))
+	if time.Since(p.lastPublish) >= downloadProgressInterval {
+		p.lastPublish = time.Now()
+		events.Publish(events.ForEveryone(events.DownloadProgress, p.download))
+	}
+	return len(data), nil
+}
+
+func (p *downloadProgress) finish(err error) {
+	p.download.Done = true
+	if err != nil {
+		p.download.Error = err.Error()
+	}
+	events.Publish(events.ForEveryone(events.DownloadProgress, p.download))
+}
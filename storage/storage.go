@@ -23,7 +23,8 @@ const (
 	CoversPath  = "covers"
 )
 
-func getStoragePath() (string, error) {
+// Path returns the absolute path of the storage directory.
+func Path() (string, error) {
 	path := config.Conf.Storage.Location
 	if !filepath.IsAbs(path) && config.DataDir != "" {
 		path = filepath.Join(config.DataDir, path)
@@ -32,7 +33,7 @@ func getStoragePath() (string, error) {
 }
 
 func CleanOverfilledStorage(ctx context.Context) {
-	path, err := getStoragePath()
+	path, err := Path()
 	if err != nil {
 		log.Error("Error getting storage path", "err", err)
 		return
@@ -55,7 +56,7 @@ func CleanOverfilledStorage(ctx context.Context) {
 	})
 
 	var sum uint64
-	storagePath, err := getStoragePath()
+	storagePath, err := Path()
 	if err != nil {
 		log.Error("Error getting storage path", "err", err)
 		return
@@ -203,7 +204,7 @@ func removePlayableFiles(storagePath string, playable media.SourcePlayable) {
 }
 
 func IsContentStored(contentType, playableID string) bool {
-	path, err := getStoragePath()
+	path, err := Path()
 	if err != nil {
 		log.Error("Error getting storage path", "err", err)
 		return false
@@ -230,7 +231,7 @@ func IsContentStored(contentType, playableID string) bool {
 }
 
 func StoreContent(contentType, playableID string, data []byte, fileExtension string) {
-	path, err := getStoragePath()
+	path, err := Path()
 	if err != nil {
 		log.Error("Error getting storage path", "err", err)
 		return
@@ -244,7 +245,7 @@ func StoreContent(contentType, playableID string, data []byte, fileExtension str
 }
 
 func StoreCover(contentType, playableID string, data []byte, fileExtension string) {
-	path, err := getStoragePath()
+	path, err := Path()
 	if err != nil {
 		log.Error("Error getting storage path", "err", err)
 		return